@@ -1,7 +1,7 @@
 // Code generated by protoc-gen-gogo. DO NOT EDIT.
-// source: hotstuff.proto
+// source: gohotstuff/v1/hotstuff.proto
 
-package pb
+package gohotstuffv1
 
 import (
 	fmt "fmt"
@@ -28,17 +28,24 @@ type Message struct {
 	//	*Message_Proposal
 	//	*Message_Vote
 	//	*Message_Timeout
-	Sum                  isMessage_Sum `protobuf_oneof:"sum"`
-	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
-	XXX_unrecognized     []byte        `json:"-"`
-	XXX_sizecache        int32         `json:"-"`
+	//	*Message_Status
+	//	*Message_ProposalRequest
+	Sum isMessage_Sum `protobuf_oneof:"sum"`
+	// chain_id and epoch separate the signing domains, see crypto.SignBytes
+	ChainId string `protobuf:"bytes,7,opt,name=chain_id,json=chainId,proto3" json:"chain_id,omitempty"`
+	Epoch   int64  `protobuf:"varint,8,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	// fork_id separates the fork of the chain after an upgrade, empty means no fork
+	ForkId               string   `protobuf:"bytes,9,opt,name=fork_id,json=forkId,proto3" json:"fork_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *Message) Reset()         { *m = Message{} }
 func (m *Message) String() string { return proto.CompactTextString(m) }
 func (*Message) ProtoMessage()    {}
 func (*Message) Descriptor() ([]byte, []int) {
-	return fileDescriptor_8517aa0e19c54851, []int{0}
+	return fileDescriptor_7b4a1d4385f9b372, []int{0}
 }
 func (m *Message) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -82,10 +89,18 @@ type Message_Vote struct {
 type Message_Timeout struct {
 	Timeout *TimoutMessage `protobuf:"bytes,4,opt,name=timeout,proto3,oneof" json:"timeout,omitempty"`
 }
+type Message_Status struct {
+	Status *StatusMessage `protobuf:"bytes,5,opt,name=status,proto3,oneof" json:"status,omitempty"`
+}
+type Message_ProposalRequest struct {
+	ProposalRequest *ProposalRequestMessage `protobuf:"bytes,6,opt,name=proposal_request,json=proposalRequest,proto3,oneof" json:"proposal_request,omitempty"`
+}
 
-func (*Message_Proposal) isMessage_Sum() {}
-func (*Message_Vote) isMessage_Sum()     {}
-func (*Message_Timeout) isMessage_Sum()  {}
+func (*Message_Proposal) isMessage_Sum()        {}
+func (*Message_Vote) isMessage_Sum()            {}
+func (*Message_Timeout) isMessage_Sum()         {}
+func (*Message_Status) isMessage_Sum()          {}
+func (*Message_ProposalRequest) isMessage_Sum() {}
 
 func (m *Message) GetSum() isMessage_Sum {
 	if m != nil {
@@ -122,24 +137,67 @@ func (m *Message) GetTimeout() *TimoutMessage {
 	return nil
 }
 
+func (m *Message) GetStatus() *StatusMessage {
+	if x, ok := m.GetSum().(*Message_Status); ok {
+		return x.Status
+	}
+	return nil
+}
+
+func (m *Message) GetProposalRequest() *ProposalRequestMessage {
+	if x, ok := m.GetSum().(*Message_ProposalRequest); ok {
+		return x.ProposalRequest
+	}
+	return nil
+}
+
+func (m *Message) GetChainId() string {
+	if m != nil {
+		return m.ChainId
+	}
+	return ""
+}
+
+func (m *Message) GetEpoch() int64 {
+	if m != nil {
+		return m.Epoch
+	}
+	return 0
+}
+
+func (m *Message) GetForkId() string {
+	if m != nil {
+		return m.ForkId
+	}
+	return ""
+}
+
 // XXX_OneofWrappers is for the internal use of the proto package.
 func (*Message) XXX_OneofWrappers() []interface{} {
 	return []interface{}{
 		(*Message_Proposal)(nil),
 		(*Message_Vote)(nil),
 		(*Message_Timeout)(nil),
+		(*Message_Status)(nil),
+		(*Message_ProposalRequest)(nil),
 	}
 }
 
 type ProposalMessage struct {
-	Module               string   `protobuf:"bytes,1,opt,name=module,proto3" json:"module,omitempty"`
-	Round                int64    `protobuf:"varint,2,opt,name=round,proto3" json:"round,omitempty"`
-	Id                   []byte   `protobuf:"bytes,3,opt,name=id,proto3" json:"id,omitempty"`
-	Timestamp            int64    `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	Pid                  []byte   `protobuf:"bytes,5,opt,name=pid,proto3" json:"pid,omitempty"`
-	Pk                   []byte   `protobuf:"bytes,6,opt,name=pk,proto3" json:"pk,omitempty"`
-	Signature            []byte   `protobuf:"bytes,7,opt,name=signature,proto3" json:"signature,omitempty"`
-	Justify              []byte   `protobuf:"bytes,8,opt,name=justify,proto3" json:"justify,omitempty"`
+	Module    string   `protobuf:"bytes,1,opt,name=module,proto3" json:"module,omitempty"`
+	Round     int64    `protobuf:"varint,2,opt,name=round,proto3" json:"round,omitempty"`
+	Id        []byte   `protobuf:"bytes,3,opt,name=id,proto3" json:"id,omitempty"`
+	Timestamp int64    `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Pid       []byte   `protobuf:"bytes,5,opt,name=pid,proto3" json:"pid,omitempty"`
+	Pk        []byte   `protobuf:"bytes,6,opt,name=pk,proto3" json:"pk,omitempty"`
+	Signature []byte   `protobuf:"bytes,7,opt,name=signature,proto3" json:"signature,omitempty"`
+	Justify   []byte   `protobuf:"bytes,8,opt,name=justify,proto3" json:"justify,omitempty"`
+	Txs       [][]byte `protobuf:"bytes,9,rep,name=txs,proto3" json:"txs,omitempty"`
+	// the application state hash after the block of app_height, the latest one executed by the proposer
+	AppHeight int64  `protobuf:"varint,10,opt,name=app_height,json=appHeight,proto3" json:"app_height,omitempty"`
+	AppHash   []byte `protobuf:"bytes,11,opt,name=app_hash,json=appHash,proto3" json:"app_hash,omitempty"`
+	// the signed votes certifying the block of justify which carry the vote extensions
+	VoteExtensions       [][]byte `protobuf:"bytes,12,rep,name=vote_extensions,json=voteExtensions,proto3" json:"vote_extensions,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -149,7 +207,7 @@ func (m *ProposalMessage) Reset()         { *m = ProposalMessage{} }
 func (m *ProposalMessage) String() string { return proto.CompactTextString(m) }
 func (*ProposalMessage) ProtoMessage()    {}
 func (*ProposalMessage) Descriptor() ([]byte, []int) {
-	return fileDescriptor_8517aa0e19c54851, []int{1}
+	return fileDescriptor_7b4a1d4385f9b372, []int{1}
 }
 func (m *ProposalMessage) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -234,24 +292,54 @@ func (m *ProposalMessage) GetJustify() []byte {
 	return nil
 }
 
+func (m *ProposalMessage) GetTxs() [][]byte {
+	if m != nil {
+		return m.Txs
+	}
+	return nil
+}
+
+func (m *ProposalMessage) GetAppHeight() int64 {
+	if m != nil {
+		return m.AppHeight
+	}
+	return 0
+}
+
+func (m *ProposalMessage) GetAppHash() []byte {
+	if m != nil {
+		return m.AppHash
+	}
+	return nil
+}
+
+func (m *ProposalMessage) GetVoteExtensions() [][]byte {
+	if m != nil {
+		return m.VoteExtensions
+	}
+	return nil
+}
+
 type VoteMessage struct {
-	Module               string    `protobuf:"bytes,1,opt,name=module,proto3" json:"module,omitempty"`
-	VoteInfo             *VoteInfo `protobuf:"bytes,2,opt,name=vote_info,json=voteInfo,proto3" json:"vote_info,omitempty"`
-	CommitInfo           []byte    `protobuf:"bytes,3,opt,name=commit_info,json=commitInfo,proto3" json:"commit_info,omitempty"`
-	Timestamp            int64     `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	Pid                  []byte    `protobuf:"bytes,5,opt,name=pid,proto3" json:"pid,omitempty"`
-	Pk                   []byte    `protobuf:"bytes,6,opt,name=pk,proto3" json:"pk,omitempty"`
-	Signature            []byte    `protobuf:"bytes,7,opt,name=signature,proto3" json:"signature,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
-	XXX_unrecognized     []byte    `json:"-"`
-	XXX_sizecache        int32     `json:"-"`
+	Module     string    `protobuf:"bytes,1,opt,name=module,proto3" json:"module,omitempty"`
+	VoteInfo   *VoteInfo `protobuf:"bytes,2,opt,name=vote_info,json=voteInfo,proto3" json:"vote_info,omitempty"`
+	CommitInfo []byte    `protobuf:"bytes,3,opt,name=commit_info,json=commitInfo,proto3" json:"commit_info,omitempty"`
+	Timestamp  int64     `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Pid        []byte    `protobuf:"bytes,5,opt,name=pid,proto3" json:"pid,omitempty"`
+	Pk         []byte    `protobuf:"bytes,6,opt,name=pk,proto3" json:"pk,omitempty"`
+	Signature  []byte    `protobuf:"bytes,7,opt,name=signature,proto3" json:"signature,omitempty"`
+	// the application data attached by the voter, which is signed along with the vote
+	Extension            []byte   `protobuf:"bytes,8,opt,name=extension,proto3" json:"extension,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *VoteMessage) Reset()         { *m = VoteMessage{} }
 func (m *VoteMessage) String() string { return proto.CompactTextString(m) }
 func (*VoteMessage) ProtoMessage()    {}
 func (*VoteMessage) Descriptor() ([]byte, []int) {
-	return fileDescriptor_8517aa0e19c54851, []int{2}
+	return fileDescriptor_7b4a1d4385f9b372, []int{2}
 }
 func (m *VoteMessage) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -329,6 +417,13 @@ func (m *VoteMessage) GetSignature() []byte {
 	return nil
 }
 
+func (m *VoteMessage) GetExtension() []byte {
+	if m != nil {
+		return m.Extension
+	}
+	return nil
+}
+
 type VoteInfo struct {
 	ProposalRound        int64    `protobuf:"varint,1,opt,name=proposal_round,json=proposalRound,proto3" json:"proposal_round,omitempty"`
 	ProposalId           []byte   `protobuf:"bytes,2,opt,name=proposal_id,json=proposalId,proto3" json:"proposal_id,omitempty"`
@@ -343,7 +438,7 @@ func (m *VoteInfo) Reset()         { *m = VoteInfo{} }
 func (m *VoteInfo) String() string { return proto.CompactTextString(m) }
 func (*VoteInfo) ProtoMessage()    {}
 func (*VoteInfo) Descriptor() ([]byte, []int) {
-	return fileDescriptor_8517aa0e19c54851, []int{3}
+	return fileDescriptor_7b4a1d4385f9b372, []int{3}
 }
 func (m *VoteInfo) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -401,15 +496,18 @@ func (m *VoteInfo) GetParentId() []byte {
 }
 
 type TimoutMessage struct {
-	Module               string   `protobuf:"bytes,1,opt,name=module,proto3" json:"module,omitempty"`
-	Round                int64    `protobuf:"varint,2,opt,name=round,proto3" json:"round,omitempty"`
-	ParentRound          int64    `protobuf:"varint,3,opt,name=parent_round,json=parentRound,proto3" json:"parent_round,omitempty"`
-	ParentId             []byte   `protobuf:"bytes,4,opt,name=parent_id,json=parentId,proto3" json:"parent_id,omitempty"`
-	Index                int64    `protobuf:"varint,5,opt,name=index,proto3" json:"index,omitempty"`
-	Timestamp            int64    `protobuf:"varint,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	Pid                  []byte   `protobuf:"bytes,7,opt,name=pid,proto3" json:"pid,omitempty"`
-	Pk                   []byte   `protobuf:"bytes,8,opt,name=pk,proto3" json:"pk,omitempty"`
-	Signature            []byte   `protobuf:"bytes,9,opt,name=signature,proto3" json:"signature,omitempty"`
+	Module      string `protobuf:"bytes,1,opt,name=module,proto3" json:"module,omitempty"`
+	Round       int64  `protobuf:"varint,2,opt,name=round,proto3" json:"round,omitempty"`
+	ParentRound int64  `protobuf:"varint,3,opt,name=parent_round,json=parentRound,proto3" json:"parent_round,omitempty"`
+	ParentId    []byte `protobuf:"bytes,4,opt,name=parent_id,json=parentId,proto3" json:"parent_id,omitempty"`
+	Index       int64  `protobuf:"varint,5,opt,name=index,proto3" json:"index,omitempty"`
+	Timestamp   int64  `protobuf:"varint,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Pid         []byte `protobuf:"bytes,7,opt,name=pid,proto3" json:"pid,omitempty"`
+	Pk          []byte `protobuf:"bytes,8,opt,name=pk,proto3" json:"pk,omitempty"`
+	Signature   []byte `protobuf:"bytes,9,opt,name=signature,proto3" json:"signature,omitempty"`
+	// high_qc is the serialized highest qc of the sender, so that the next leader
+	// can propose on the freshest certified block.
+	HighQc               []byte   `protobuf:"bytes,10,opt,name=high_qc,json=highQc,proto3" json:"high_qc,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -419,7 +517,7 @@ func (m *TimoutMessage) Reset()         { *m = TimoutMessage{} }
 func (m *TimoutMessage) String() string { return proto.CompactTextString(m) }
 func (*TimoutMessage) ProtoMessage()    {}
 func (*TimoutMessage) Descriptor() ([]byte, []int) {
-	return fileDescriptor_8517aa0e19c54851, []int{4}
+	return fileDescriptor_7b4a1d4385f9b372, []int{4}
 }
 func (m *TimoutMessage) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -511,48 +609,276 @@ func (m *TimoutMessage) GetSignature() []byte {
 	return nil
 }
 
+func (m *TimoutMessage) GetHighQc() []byte {
+	if m != nil {
+		return m.HighQc
+	}
+	return nil
+}
+
+// StatusMessage is gossiped periodically so that the peers know how far each other is.
+type StatusMessage struct {
+	Module               string   `protobuf:"bytes,1,opt,name=module,proto3" json:"module,omitempty"`
+	CommittedRound       int64    `protobuf:"varint,2,opt,name=committed_round,json=committedRound,proto3" json:"committed_round,omitempty"`
+	CurrentRound         int64    `protobuf:"varint,3,opt,name=current_round,json=currentRound,proto3" json:"current_round,omitempty"`
+	HighQcRound          int64    `protobuf:"varint,4,opt,name=high_qc_round,json=highQcRound,proto3" json:"high_qc_round,omitempty"`
+	Timestamp            int64    `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Pid                  []byte   `protobuf:"bytes,6,opt,name=pid,proto3" json:"pid,omitempty"`
+	Pk                   []byte   `protobuf:"bytes,7,opt,name=pk,proto3" json:"pk,omitempty"`
+	Signature            []byte   `protobuf:"bytes,8,opt,name=signature,proto3" json:"signature,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StatusMessage) Reset()         { *m = StatusMessage{} }
+func (m *StatusMessage) String() string { return proto.CompactTextString(m) }
+func (*StatusMessage) ProtoMessage()    {}
+func (*StatusMessage) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7b4a1d4385f9b372, []int{5}
+}
+func (m *StatusMessage) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *StatusMessage) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_StatusMessage.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *StatusMessage) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StatusMessage.Merge(m, src)
+}
+func (m *StatusMessage) XXX_Size() int {
+	return m.Size()
+}
+func (m *StatusMessage) XXX_DiscardUnknown() {
+	xxx_messageInfo_StatusMessage.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StatusMessage proto.InternalMessageInfo
+
+func (m *StatusMessage) GetModule() string {
+	if m != nil {
+		return m.Module
+	}
+	return ""
+}
+
+func (m *StatusMessage) GetCommittedRound() int64 {
+	if m != nil {
+		return m.CommittedRound
+	}
+	return 0
+}
+
+func (m *StatusMessage) GetCurrentRound() int64 {
+	if m != nil {
+		return m.CurrentRound
+	}
+	return 0
+}
+
+func (m *StatusMessage) GetHighQcRound() int64 {
+	if m != nil {
+		return m.HighQcRound
+	}
+	return 0
+}
+
+func (m *StatusMessage) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func (m *StatusMessage) GetPid() []byte {
+	if m != nil {
+		return m.Pid
+	}
+	return nil
+}
+
+func (m *StatusMessage) GetPk() []byte {
+	if m != nil {
+		return m.Pk
+	}
+	return nil
+}
+
+func (m *StatusMessage) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+// ProposalRequestMessage pulls a proposal which the sender has never received,
+// the peer holding it replies with the original signed proposal.
+type ProposalRequestMessage struct {
+	Module               string   `protobuf:"bytes,1,opt,name=module,proto3" json:"module,omitempty"`
+	Round                int64    `protobuf:"varint,2,opt,name=round,proto3" json:"round,omitempty"`
+	Id                   []byte   `protobuf:"bytes,3,opt,name=id,proto3" json:"id,omitempty"`
+	Timestamp            int64    `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Pid                  []byte   `protobuf:"bytes,5,opt,name=pid,proto3" json:"pid,omitempty"`
+	Pk                   []byte   `protobuf:"bytes,6,opt,name=pk,proto3" json:"pk,omitempty"`
+	Signature            []byte   `protobuf:"bytes,7,opt,name=signature,proto3" json:"signature,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ProposalRequestMessage) Reset()         { *m = ProposalRequestMessage{} }
+func (m *ProposalRequestMessage) String() string { return proto.CompactTextString(m) }
+func (*ProposalRequestMessage) ProtoMessage()    {}
+func (*ProposalRequestMessage) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7b4a1d4385f9b372, []int{6}
+}
+func (m *ProposalRequestMessage) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ProposalRequestMessage) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ProposalRequestMessage.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *ProposalRequestMessage) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ProposalRequestMessage.Merge(m, src)
+}
+func (m *ProposalRequestMessage) XXX_Size() int {
+	return m.Size()
+}
+func (m *ProposalRequestMessage) XXX_DiscardUnknown() {
+	xxx_messageInfo_ProposalRequestMessage.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ProposalRequestMessage proto.InternalMessageInfo
+
+func (m *ProposalRequestMessage) GetModule() string {
+	if m != nil {
+		return m.Module
+	}
+	return ""
+}
+
+func (m *ProposalRequestMessage) GetRound() int64 {
+	if m != nil {
+		return m.Round
+	}
+	return 0
+}
+
+func (m *ProposalRequestMessage) GetId() []byte {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
+func (m *ProposalRequestMessage) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func (m *ProposalRequestMessage) GetPid() []byte {
+	if m != nil {
+		return m.Pid
+	}
+	return nil
+}
+
+func (m *ProposalRequestMessage) GetPk() []byte {
+	if m != nil {
+		return m.Pk
+	}
+	return nil
+}
+
+func (m *ProposalRequestMessage) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
 func init() {
-	proto.RegisterType((*Message)(nil), "gohotstuff.pb.Message")
-	proto.RegisterType((*ProposalMessage)(nil), "gohotstuff.pb.ProposalMessage")
-	proto.RegisterType((*VoteMessage)(nil), "gohotstuff.pb.VoteMessage")
-	proto.RegisterType((*VoteInfo)(nil), "gohotstuff.pb.VoteInfo")
-	proto.RegisterType((*TimoutMessage)(nil), "gohotstuff.pb.TimoutMessage")
-}
-
-func init() { proto.RegisterFile("hotstuff.proto", fileDescriptor_8517aa0e19c54851) }
-
-var fileDescriptor_8517aa0e19c54851 = []byte{
-	// 468 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x54, 0x41, 0x8b, 0xd4, 0x30,
-	0x14, 0xde, 0x4c, 0x67, 0xa6, 0xed, 0xeb, 0xcc, 0x28, 0x41, 0xd6, 0xa0, 0x4b, 0x77, 0x2d, 0x08,
-	0x7b, 0x2a, 0xa2, 0x1e, 0x04, 0x3d, 0xed, 0xc9, 0x39, 0x08, 0x12, 0xc4, 0x83, 0x97, 0xa5, 0x63,
-	0xd2, 0x31, 0xee, 0xb6, 0x09, 0x6d, 0xb2, 0xe8, 0xdf, 0xf0, 0xe4, 0x3f, 0xd2, 0xa3, 0x78, 0xf4,
-	0x24, 0xe3, 0x2f, 0xf0, 0x1f, 0x48, 0x93, 0x76, 0xeb, 0x94, 0x9d, 0x83, 0xc8, 0xde, 0xfa, 0xbe,
-	0xbc, 0xef, 0xcb, 0xfb, 0xde, 0x17, 0x0a, 0x8b, 0x77, 0x52, 0xd7, 0xda, 0xe4, 0x79, 0xaa, 0x2a,
-	0xa9, 0x25, 0x9e, 0xaf, 0x65, 0x8f, 0xac, 0x92, 0xef, 0x08, 0xfc, 0x17, 0xbc, 0xae, 0xb3, 0x35,
-	0xc7, 0xfb, 0x30, 0x2d, 0x24, 0x33, 0xe7, 0x9c, 0xa0, 0x23, 0x74, 0x1c, 0xd2, 0xb6, 0xc2, 0xcf,
-	0x20, 0x50, 0x95, 0x54, 0xb2, 0xce, 0xce, 0xc9, 0xe8, 0x08, 0x1d, 0x47, 0x0f, 0xe3, 0x74, 0x4b,
-	0x25, 0x7d, 0xd9, 0x1e, 0xb7, 0x4a, 0xcf, 0xf7, 0xe8, 0x25, 0x03, 0x3f, 0x80, 0xf1, 0x85, 0xd4,
-	0x9c, 0x78, 0x96, 0x79, 0x67, 0xc0, 0x7c, 0x2d, 0x35, 0xef, 0x59, 0xb6, 0x13, 0x3f, 0x01, 0x5f,
-	0x8b, 0x82, 0x4b, 0xa3, 0xc9, 0xd8, 0x92, 0x0e, 0x06, 0xa4, 0x57, 0xa2, 0x90, 0x46, 0xf7, 0xb4,
-	0xae, 0xfd, 0x64, 0x02, 0x5e, 0x6d, 0x8a, 0xe4, 0x0b, 0x82, 0x1b, 0x83, 0x91, 0x76, 0x9a, 0xbb,
-	0x05, 0x93, 0x4a, 0x9a, 0x92, 0x59, 0x67, 0x1e, 0x75, 0x05, 0x5e, 0xc0, 0x48, 0x30, 0x3b, 0xf2,
-	0x8c, 0x8e, 0x04, 0xc3, 0x07, 0x10, 0x36, 0x77, 0xd4, 0x3a, 0x2b, 0x94, 0x1d, 0xca, 0xa3, 0x3d,
-	0x80, 0x6f, 0x82, 0xa7, 0x04, 0x23, 0x13, 0xdb, 0xde, 0x7c, 0x36, 0x7c, 0x75, 0x46, 0xa6, 0x8e,
-	0xaf, 0xce, 0x1a, 0x7e, 0x2d, 0xd6, 0x65, 0xa6, 0x4d, 0xc5, 0x89, 0x6f, 0xe1, 0x1e, 0xc0, 0x04,
-	0xfc, 0xf7, 0xa6, 0xd6, 0x22, 0xff, 0x48, 0x02, 0x7b, 0xd6, 0x95, 0xc9, 0x0f, 0x04, 0xd1, 0x5f,
-	0x2b, 0xda, 0xe9, 0xe2, 0x31, 0x84, 0xcd, 0xea, 0x4e, 0x45, 0x99, 0xcb, 0x36, 0xa3, 0xdb, 0x57,
-	0x6c, 0x7a, 0x59, 0xe6, 0x92, 0x06, 0x17, 0xed, 0x17, 0x3e, 0x84, 0xe8, 0xad, 0x2c, 0x0a, 0xa1,
-	0x1d, 0xcf, 0xd9, 0x05, 0x07, 0xd9, 0x86, 0x6b, 0xb5, 0x9d, 0x7c, 0x42, 0x10, 0x74, 0x53, 0xe1,
-	0xfb, 0xb0, 0xe8, 0x9e, 0xcc, 0xa9, 0x0b, 0x04, 0xd9, 0xfb, 0xe6, 0x1d, 0x4a, 0x6d, 0x30, 0x87,
-	0x10, 0x5d, 0xb6, 0x09, 0x17, 0xda, 0x8c, 0x42, 0x07, 0x2d, 0x19, 0xbe, 0x07, 0x33, 0x95, 0x55,
-	0xbc, 0xd4, 0xad, 0x8a, 0x67, 0x55, 0x22, 0x87, 0x39, 0x8d, 0xbb, 0x10, 0xb6, 0x2d, 0x82, 0x59,
-	0x57, 0x33, 0x1a, 0x38, 0x60, 0xc9, 0x92, 0xdf, 0x08, 0xe6, 0x5b, 0xef, 0xeb, 0x1f, 0x5f, 0xce,
-	0x7f, 0xde, 0xdf, 0xa8, 0x8a, 0x92, 0xf1, 0x0f, 0x76, 0xad, 0x1e, 0x75, 0xc5, 0x76, 0x10, 0xd3,
-	0x1d, 0x41, 0xf8, 0xc3, 0x20, 0x82, 0xab, 0x83, 0x08, 0x07, 0x41, 0x9c, 0xec, 0x7f, 0xdd, 0xc4,
-	0xe8, 0xdb, 0x26, 0x46, 0x3f, 0x37, 0x31, 0xfa, 0xfc, 0x2b, 0xde, 0x7b, 0x33, 0x4e, 0x9f, 0xaa,
-	0xd5, 0x6a, 0x6a, 0x7f, 0x19, 0x8f, 0xfe, 0x04, 0x00, 0x00, 0xff, 0xff, 0x08, 0xf6, 0x38, 0x40,
-	0x44, 0x04, 0x00, 0x00,
+	proto.RegisterType((*Message)(nil), "gohotstuff.v1.Message")
+	proto.RegisterType((*ProposalMessage)(nil), "gohotstuff.v1.ProposalMessage")
+	proto.RegisterType((*VoteMessage)(nil), "gohotstuff.v1.VoteMessage")
+	proto.RegisterType((*VoteInfo)(nil), "gohotstuff.v1.VoteInfo")
+	proto.RegisterType((*TimoutMessage)(nil), "gohotstuff.v1.TimoutMessage")
+	proto.RegisterType((*StatusMessage)(nil), "gohotstuff.v1.StatusMessage")
+	proto.RegisterType((*ProposalRequestMessage)(nil), "gohotstuff.v1.ProposalRequestMessage")
+}
+
+func init() { proto.RegisterFile("gohotstuff/v1/hotstuff.proto", fileDescriptor_7b4a1d4385f9b372) }
+
+var fileDescriptor_7b4a1d4385f9b372 = []byte{
+	// 760 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xcc, 0x55, 0xc1, 0x6e, 0xdb, 0x46,
+	0x10, 0x35, 0x45, 0x4b, 0x24, 0x47, 0x94, 0x64, 0x2c, 0x0c, 0x9b, 0x6d, 0x55, 0x55, 0x65, 0x61,
+	0xd8, 0x27, 0xa9, 0x6e, 0x8b, 0xa2, 0x40, 0xdb, 0x8b, 0x81, 0x02, 0xd6, 0xa1, 0x48, 0xb2, 0x09,
+	0x72, 0xc8, 0x45, 0xa0, 0xc9, 0x95, 0xb8, 0xb1, 0xc9, 0xa5, 0xb9, 0x4b, 0x41, 0xf9, 0x85, 0x1c,
+	0x73, 0xca, 0x27, 0xe4, 0x03, 0x72, 0xcc, 0x07, 0xe4, 0x98, 0x4f, 0x08, 0x9c, 0x8f, 0x48, 0x8e,
+	0xc1, 0xee, 0x52, 0xa2, 0xa5, 0x48, 0x87, 0x20, 0x08, 0x90, 0x1b, 0xe7, 0xcd, 0xbc, 0xa7, 0x99,
+	0x37, 0x23, 0x12, 0xba, 0x53, 0x16, 0x33, 0xc1, 0x45, 0x31, 0x99, 0x0c, 0x67, 0xa7, 0xc3, 0xc5,
+	0xf3, 0x20, 0xcb, 0x99, 0x60, 0xa8, 0x55, 0x65, 0x07, 0xb3, 0x53, 0xff, 0x85, 0x09, 0xd6, 0xff,
+	0x84, 0xf3, 0x60, 0x4a, 0xd0, 0x01, 0x34, 0x12, 0x16, 0x15, 0x57, 0xc4, 0x33, 0xfa, 0xc6, 0x89,
+	0x83, 0xcb, 0x08, 0xfd, 0x03, 0x76, 0x96, 0xb3, 0x8c, 0xf1, 0xe0, 0xca, 0xab, 0xf5, 0x8d, 0x93,
+	0xe6, 0x6f, 0xbd, 0xc1, 0x8a, 0xca, 0xe0, 0x6e, 0x99, 0x2e, 0x95, 0xce, 0x77, 0xf0, 0x92, 0x81,
+	0x7e, 0x85, 0xdd, 0x19, 0x13, 0xc4, 0x33, 0x15, 0xf3, 0xfb, 0x35, 0xe6, 0x43, 0x26, 0x48, 0xc5,
+	0x52, 0x95, 0xe8, 0x2f, 0xb0, 0x04, 0x4d, 0x08, 0x2b, 0x84, 0xb7, 0xab, 0x48, 0xdd, 0x35, 0xd2,
+	0x03, 0x9a, 0xb0, 0x42, 0x54, 0xb4, 0x45, 0x39, 0xfa, 0x13, 0x1a, 0x5c, 0x04, 0xa2, 0xe0, 0x5e,
+	0x7d, 0x23, 0xf1, 0xbe, 0x4a, 0x56, 0xc4, 0xb2, 0x1a, 0x61, 0xd8, 0x5b, 0xf4, 0x3b, 0xce, 0xc9,
+	0x75, 0x41, 0xb8, 0xf0, 0x1a, 0x4a, 0xe1, 0x68, 0xcb, 0xa4, 0x58, 0x57, 0x55, 0x52, 0x9d, 0x6c,
+	0x35, 0x83, 0xbe, 0x03, 0x3b, 0x8c, 0x03, 0x9a, 0x8e, 0x69, 0xe4, 0x59, 0xca, 0x4f, 0x4b, 0xc5,
+	0xa3, 0x08, 0xed, 0x43, 0x9d, 0x64, 0x2c, 0x8c, 0x3d, 0xbb, 0x6f, 0x9c, 0x98, 0x58, 0x07, 0xe8,
+	0x10, 0xac, 0x09, 0xcb, 0x2f, 0x65, 0xbd, 0xa3, 0xfd, 0x97, 0xe1, 0x28, 0x3a, 0xab, 0x83, 0xc9,
+	0x8b, 0xc4, 0x7f, 0x55, 0x83, 0xce, 0x9a, 0xd1, 0x5b, 0x57, 0xb6, 0x0f, 0xf5, 0x9c, 0x15, 0x69,
+	0xa4, 0xf6, 0x65, 0x62, 0x1d, 0xa0, 0x36, 0xd4, 0x68, 0xa4, 0x16, 0xe1, 0xe2, 0x1a, 0x8d, 0x50,
+	0x17, 0x1c, 0xe9, 0x1c, 0x17, 0x41, 0x92, 0x29, 0xab, 0x4d, 0x5c, 0x01, 0x68, 0x0f, 0xcc, 0x8c,
+	0x46, 0xca, 0x49, 0x17, 0xcb, 0x47, 0xc9, 0xcf, 0x2e, 0x95, 0x31, 0x2e, 0xae, 0x65, 0x97, 0x92,
+	0xcf, 0xe9, 0x34, 0x0d, 0x44, 0x91, 0x13, 0x35, 0xa3, 0x8b, 0x2b, 0x00, 0x79, 0x60, 0x3d, 0x2e,
+	0xb8, 0xa0, 0x93, 0x27, 0x6a, 0x4e, 0x17, 0x2f, 0x42, 0xa9, 0x2c, 0xe6, 0xdc, 0x73, 0xfa, 0xa6,
+	0x54, 0x16, 0x73, 0x8e, 0x7e, 0x04, 0x08, 0xb2, 0x6c, 0x1c, 0x13, 0x3a, 0x8d, 0x85, 0x07, 0xba,
+	0x95, 0x20, 0xcb, 0xce, 0x15, 0x20, 0xbd, 0x54, 0xe9, 0x80, 0xc7, 0x5e, 0x53, 0x6b, 0xc9, 0x64,
+	0xc0, 0x63, 0x74, 0x0c, 0x1d, 0x79, 0x34, 0x63, 0x32, 0x17, 0x24, 0xe5, 0x94, 0xa5, 0xdc, 0x73,
+	0x95, 0x6e, 0x5b, 0xc2, 0xff, 0x2d, 0x51, 0xff, 0x83, 0x01, 0xcd, 0x5b, 0xd7, 0xb6, 0xd5, 0xba,
+	0x3f, 0xc0, 0x51, 0x82, 0x34, 0x9d, 0xb0, 0xf2, 0xdc, 0x0f, 0x37, 0x1c, 0xed, 0x28, 0x9d, 0x30,
+	0x6c, 0xcf, 0xca, 0x27, 0xf4, 0x13, 0x34, 0x43, 0x96, 0x24, 0x54, 0x68, 0x9e, 0xf6, 0x18, 0x34,
+	0xa4, 0x0a, 0xbe, 0xae, 0xd7, 0x5d, 0x70, 0x96, 0x06, 0x94, 0x6e, 0x57, 0x80, 0xff, 0xcc, 0x00,
+	0x7b, 0xd1, 0x33, 0x3a, 0x82, 0x76, 0x75, 0xeb, 0xea, 0x46, 0x0c, 0xd5, 0x4d, 0x6b, 0x81, 0x62,
+	0x09, 0xca, 0x81, 0x96, 0x65, 0x54, 0xdf, 0x91, 0x8b, 0x61, 0x01, 0x8d, 0x22, 0xf4, 0x33, 0xb8,
+	0x59, 0x90, 0x93, 0x54, 0x94, 0x2a, 0xa6, 0x52, 0x69, 0x6a, 0x4c, 0x6b, 0xfc, 0x00, 0x4e, 0x59,
+	0x42, 0x23, 0x35, 0xb3, 0x8b, 0x6d, 0x0d, 0x8c, 0x22, 0xff, 0x69, 0x0d, 0x5a, 0x2b, 0x7f, 0xe4,
+	0xcf, 0x3c, 0xe6, 0x2f, 0xfc, 0x7d, 0xa9, 0x4a, 0xd3, 0x88, 0xcc, 0x95, 0xe9, 0x26, 0xd6, 0xc1,
+	0xea, 0x9a, 0x1a, 0x5b, 0xd6, 0x64, 0xad, 0xaf, 0xc9, 0xde, 0xbc, 0x26, 0x67, 0x7d, 0x4d, 0x87,
+	0x60, 0xc5, 0x74, 0x1a, 0x8f, 0xaf, 0x43, 0x75, 0xe3, 0x2e, 0x6e, 0xc8, 0xf0, 0x5e, 0xe8, 0xbf,
+	0x37, 0xa0, 0xb5, 0xf2, 0x72, 0xda, 0x6a, 0xc6, 0x31, 0x74, 0xf4, 0x55, 0x09, 0x12, 0x8d, 0x6f,
+	0xdb, 0xd2, 0x5e, 0xc2, 0x7a, 0xf8, 0x5f, 0xa0, 0x15, 0x16, 0xf9, 0x27, 0x06, 0xb9, 0x25, 0xa8,
+	0x8b, 0x7c, 0x68, 0x95, 0x0d, 0x95, 0x45, 0xfa, 0x32, 0x9b, 0xba, 0x2d, 0x5d, 0xb3, 0x62, 0x49,
+	0x7d, 0x8b, 0x25, 0x8d, 0x75, 0x4b, 0xac, 0xcd, 0x96, 0xd8, 0x6b, 0x96, 0xf8, 0x2f, 0x0d, 0x38,
+	0xd8, 0xfc, 0x52, 0xfd, 0x96, 0x5f, 0x6e, 0x67, 0x77, 0x5e, 0xdf, 0xf4, 0x8c, 0x37, 0x37, 0x3d,
+	0xe3, 0xed, 0x4d, 0xcf, 0x78, 0xfe, 0xae, 0xb7, 0xf3, 0xe8, 0xdf, 0x29, 0x15, 0x71, 0x71, 0x31,
+	0x08, 0x59, 0x32, 0x0c, 0x8a, 0xb0, 0xe0, 0xc1, 0x34, 0x18, 0xde, 0xfa, 0x14, 0x07, 0x19, 0x1d,
+	0xae, 0x7c, 0x99, 0xff, 0xae, 0xa2, 0xd9, 0xe9, 0x45, 0x43, 0x7d, 0x9e, 0x7f, 0xff, 0x38, 0x00,
+	0x23, 0xb7, 0x7a, 0x3a, 0xbe, 0x07, 0x00, 0x00,
 }
 
 func (m *Message) Marshal() (dAtA []byte, err error) {
@@ -579,6 +905,25 @@ func (m *Message) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
+	if len(m.ForkId) > 0 {
+		i -= len(m.ForkId)
+		copy(dAtA[i:], m.ForkId)
+		i = encodeVarintHotstuff(dAtA, i, uint64(len(m.ForkId)))
+		i--
+		dAtA[i] = 0x4a
+	}
+	if m.Epoch != 0 {
+		i = encodeVarintHotstuff(dAtA, i, uint64(m.Epoch))
+		i--
+		dAtA[i] = 0x40
+	}
+	if len(m.ChainId) > 0 {
+		i -= len(m.ChainId)
+		copy(dAtA[i:], m.ChainId)
+		i = encodeVarintHotstuff(dAtA, i, uint64(len(m.ChainId)))
+		i--
+		dAtA[i] = 0x3a
+	}
 	if m.Sum != nil {
 		{
 			size := m.Sum.Size()
@@ -661,33 +1006,105 @@ func (m *Message_Timeout) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	}
 	return len(dAtA) - i, nil
 }
-func (m *ProposalMessage) Marshal() (dAtA []byte, err error) {
+func (m *Message_Status) MarshalTo(dAtA []byte) (int, error) {
 	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
+	return m.MarshalToSizedBuffer(dAtA[:size])
 }
 
-func (m *ProposalMessage) MarshalTo(dAtA []byte) (int, error) {
+func (m *Message_Status) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	if m.Status != nil {
+		{
+			size, err := m.Status.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintHotstuff(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x2a
+	}
+	return len(dAtA) - i, nil
+}
+func (m *Message_ProposalRequest) MarshalTo(dAtA []byte) (int, error) {
 	size := m.Size()
 	return m.MarshalToSizedBuffer(dAtA[:size])
 }
 
-func (m *ProposalMessage) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+func (m *Message_ProposalRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	if len(m.Justify) > 0 {
-		i -= len(m.Justify)
-		copy(dAtA[i:], m.Justify)
+	if m.ProposalRequest != nil {
+		{
+			size, err := m.ProposalRequest.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintHotstuff(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x32
+	}
+	return len(dAtA) - i, nil
+}
+func (m *ProposalMessage) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ProposalMessage) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *ProposalMessage) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if len(m.VoteExtensions) > 0 {
+		for iNdEx := len(m.VoteExtensions) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.VoteExtensions[iNdEx])
+			copy(dAtA[i:], m.VoteExtensions[iNdEx])
+			i = encodeVarintHotstuff(dAtA, i, uint64(len(m.VoteExtensions[iNdEx])))
+			i--
+			dAtA[i] = 0x62
+		}
+	}
+	if len(m.AppHash) > 0 {
+		i -= len(m.AppHash)
+		copy(dAtA[i:], m.AppHash)
+		i = encodeVarintHotstuff(dAtA, i, uint64(len(m.AppHash)))
+		i--
+		dAtA[i] = 0x5a
+	}
+	if m.AppHeight != 0 {
+		i = encodeVarintHotstuff(dAtA, i, uint64(m.AppHeight))
+		i--
+		dAtA[i] = 0x50
+	}
+	if len(m.Txs) > 0 {
+		for iNdEx := len(m.Txs) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Txs[iNdEx])
+			copy(dAtA[i:], m.Txs[iNdEx])
+			i = encodeVarintHotstuff(dAtA, i, uint64(len(m.Txs[iNdEx])))
+			i--
+			dAtA[i] = 0x4a
+		}
+	}
+	if len(m.Justify) > 0 {
+		i -= len(m.Justify)
+		copy(dAtA[i:], m.Justify)
 		i = encodeVarintHotstuff(dAtA, i, uint64(len(m.Justify)))
 		i--
 		dAtA[i] = 0x42
@@ -764,6 +1181,13 @@ func (m *VoteMessage) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
+	if len(m.Extension) > 0 {
+		i -= len(m.Extension)
+		copy(dAtA[i:], m.Extension)
+		i = encodeVarintHotstuff(dAtA, i, uint64(len(m.Extension)))
+		i--
+		dAtA[i] = 0x42
+	}
 	if len(m.Signature) > 0 {
 		i -= len(m.Signature)
 		copy(dAtA[i:], m.Signature)
@@ -894,6 +1318,13 @@ func (m *TimoutMessage) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
+	if len(m.HighQc) > 0 {
+		i -= len(m.HighQc)
+		copy(dAtA[i:], m.HighQc)
+		i = encodeVarintHotstuff(dAtA, i, uint64(len(m.HighQc)))
+		i--
+		dAtA[i] = 0x52
+	}
 	if len(m.Signature) > 0 {
 		i -= len(m.Signature)
 		copy(dAtA[i:], m.Signature)
@@ -952,6 +1383,153 @@ func (m *TimoutMessage) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *StatusMessage) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *StatusMessage) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *StatusMessage) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if len(m.Signature) > 0 {
+		i -= len(m.Signature)
+		copy(dAtA[i:], m.Signature)
+		i = encodeVarintHotstuff(dAtA, i, uint64(len(m.Signature)))
+		i--
+		dAtA[i] = 0x42
+	}
+	if len(m.Pk) > 0 {
+		i -= len(m.Pk)
+		copy(dAtA[i:], m.Pk)
+		i = encodeVarintHotstuff(dAtA, i, uint64(len(m.Pk)))
+		i--
+		dAtA[i] = 0x3a
+	}
+	if len(m.Pid) > 0 {
+		i -= len(m.Pid)
+		copy(dAtA[i:], m.Pid)
+		i = encodeVarintHotstuff(dAtA, i, uint64(len(m.Pid)))
+		i--
+		dAtA[i] = 0x32
+	}
+	if m.Timestamp != 0 {
+		i = encodeVarintHotstuff(dAtA, i, uint64(m.Timestamp))
+		i--
+		dAtA[i] = 0x28
+	}
+	if m.HighQcRound != 0 {
+		i = encodeVarintHotstuff(dAtA, i, uint64(m.HighQcRound))
+		i--
+		dAtA[i] = 0x20
+	}
+	if m.CurrentRound != 0 {
+		i = encodeVarintHotstuff(dAtA, i, uint64(m.CurrentRound))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.CommittedRound != 0 {
+		i = encodeVarintHotstuff(dAtA, i, uint64(m.CommittedRound))
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.Module) > 0 {
+		i -= len(m.Module)
+		copy(dAtA[i:], m.Module)
+		i = encodeVarintHotstuff(dAtA, i, uint64(len(m.Module)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *ProposalRequestMessage) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ProposalRequestMessage) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *ProposalRequestMessage) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if len(m.Signature) > 0 {
+		i -= len(m.Signature)
+		copy(dAtA[i:], m.Signature)
+		i = encodeVarintHotstuff(dAtA, i, uint64(len(m.Signature)))
+		i--
+		dAtA[i] = 0x3a
+	}
+	if len(m.Pk) > 0 {
+		i -= len(m.Pk)
+		copy(dAtA[i:], m.Pk)
+		i = encodeVarintHotstuff(dAtA, i, uint64(len(m.Pk)))
+		i--
+		dAtA[i] = 0x32
+	}
+	if len(m.Pid) > 0 {
+		i -= len(m.Pid)
+		copy(dAtA[i:], m.Pid)
+		i = encodeVarintHotstuff(dAtA, i, uint64(len(m.Pid)))
+		i--
+		dAtA[i] = 0x2a
+	}
+	if m.Timestamp != 0 {
+		i = encodeVarintHotstuff(dAtA, i, uint64(m.Timestamp))
+		i--
+		dAtA[i] = 0x20
+	}
+	if len(m.Id) > 0 {
+		i -= len(m.Id)
+		copy(dAtA[i:], m.Id)
+		i = encodeVarintHotstuff(dAtA, i, uint64(len(m.Id)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if m.Round != 0 {
+		i = encodeVarintHotstuff(dAtA, i, uint64(m.Round))
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.Module) > 0 {
+		i -= len(m.Module)
+		copy(dAtA[i:], m.Module)
+		i = encodeVarintHotstuff(dAtA, i, uint64(len(m.Module)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
 func encodeVarintHotstuff(dAtA []byte, offset int, v uint64) int {
 	offset -= sovHotstuff(v)
 	base := offset
@@ -976,6 +1554,17 @@ func (m *Message) Size() (n int) {
 	if m.Sum != nil {
 		n += m.Sum.Size()
 	}
+	l = len(m.ChainId)
+	if l > 0 {
+		n += 1 + l + sovHotstuff(uint64(l))
+	}
+	if m.Epoch != 0 {
+		n += 1 + sovHotstuff(uint64(m.Epoch))
+	}
+	l = len(m.ForkId)
+	if l > 0 {
+		n += 1 + l + sovHotstuff(uint64(l))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -1018,6 +1607,30 @@ func (m *Message_Timeout) Size() (n int) {
 	}
 	return n
 }
+func (m *Message_Status) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Status != nil {
+		l = m.Status.Size()
+		n += 1 + l + sovHotstuff(uint64(l))
+	}
+	return n
+}
+func (m *Message_ProposalRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.ProposalRequest != nil {
+		l = m.ProposalRequest.Size()
+		n += 1 + l + sovHotstuff(uint64(l))
+	}
+	return n
+}
 func (m *ProposalMessage) Size() (n int) {
 	if m == nil {
 		return 0
@@ -1054,6 +1667,25 @@ func (m *ProposalMessage) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovHotstuff(uint64(l))
 	}
+	if len(m.Txs) > 0 {
+		for _, b := range m.Txs {
+			l = len(b)
+			n += 1 + l + sovHotstuff(uint64(l))
+		}
+	}
+	if m.AppHeight != 0 {
+		n += 1 + sovHotstuff(uint64(m.AppHeight))
+	}
+	l = len(m.AppHash)
+	if l > 0 {
+		n += 1 + l + sovHotstuff(uint64(l))
+	}
+	if len(m.VoteExtensions) > 0 {
+		for _, b := range m.VoteExtensions {
+			l = len(b)
+			n += 1 + l + sovHotstuff(uint64(l))
+		}
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -1093,6 +1725,10 @@ func (m *VoteMessage) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovHotstuff(uint64(l))
 	}
+	l = len(m.Extension)
+	if l > 0 {
+		n += 1 + l + sovHotstuff(uint64(l))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -1163,43 +1799,125 @@ func (m *TimoutMessage) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovHotstuff(uint64(l))
 	}
+	l = len(m.HighQc)
+	if l > 0 {
+		n += 1 + l + sovHotstuff(uint64(l))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
 	return n
 }
 
-func sovHotstuff(x uint64) (n int) {
-	return (math_bits.Len64(x|1) + 6) / 7
-}
-func sozHotstuff(x uint64) (n int) {
-	return sovHotstuff(uint64((x << 1) ^ uint64((int64(x) >> 63))))
-}
-func (m *Message) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowHotstuff
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: Message: wiretype end group for non-group")
-		}
+func (m *StatusMessage) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Module)
+	if l > 0 {
+		n += 1 + l + sovHotstuff(uint64(l))
+	}
+	if m.CommittedRound != 0 {
+		n += 1 + sovHotstuff(uint64(m.CommittedRound))
+	}
+	if m.CurrentRound != 0 {
+		n += 1 + sovHotstuff(uint64(m.CurrentRound))
+	}
+	if m.HighQcRound != 0 {
+		n += 1 + sovHotstuff(uint64(m.HighQcRound))
+	}
+	if m.Timestamp != 0 {
+		n += 1 + sovHotstuff(uint64(m.Timestamp))
+	}
+	l = len(m.Pid)
+	if l > 0 {
+		n += 1 + l + sovHotstuff(uint64(l))
+	}
+	l = len(m.Pk)
+	if l > 0 {
+		n += 1 + l + sovHotstuff(uint64(l))
+	}
+	l = len(m.Signature)
+	if l > 0 {
+		n += 1 + l + sovHotstuff(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *ProposalRequestMessage) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Module)
+	if l > 0 {
+		n += 1 + l + sovHotstuff(uint64(l))
+	}
+	if m.Round != 0 {
+		n += 1 + sovHotstuff(uint64(m.Round))
+	}
+	l = len(m.Id)
+	if l > 0 {
+		n += 1 + l + sovHotstuff(uint64(l))
+	}
+	if m.Timestamp != 0 {
+		n += 1 + sovHotstuff(uint64(m.Timestamp))
+	}
+	l = len(m.Pid)
+	if l > 0 {
+		n += 1 + l + sovHotstuff(uint64(l))
+	}
+	l = len(m.Pk)
+	if l > 0 {
+		n += 1 + l + sovHotstuff(uint64(l))
+	}
+	l = len(m.Signature)
+	if l > 0 {
+		n += 1 + l + sovHotstuff(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func sovHotstuff(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozHotstuff(x uint64) (n int) {
+	return sovHotstuff(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (m *Message) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowHotstuff
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Message: wiretype end group for non-group")
+		}
 		if fieldNum <= 0 {
 			return fmt.Errorf("proto: Message: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
@@ -1341,6 +2059,159 @@ func (m *Message) Unmarshal(dAtA []byte) error {
 			}
 			m.Sum = &Message_Timeout{v}
 			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Status", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			v := &StatusMessage{}
+			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			m.Sum = &Message_Status{v}
+			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ProposalRequest", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			v := &ProposalRequestMessage{}
+			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			m.Sum = &Message_ProposalRequest{v}
+			iNdEx = postIndex
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ChainId", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ChainId = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Epoch", wireType)
+			}
+			m.Epoch = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Epoch |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 9:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ForkId", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ForkId = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipHotstuff(dAtA[iNdEx:])
@@ -1632,20 +2503,137 @@ func (m *ProposalMessage) Unmarshal(dAtA []byte) error {
 				m.Justify = []byte{}
 			}
 			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipHotstuff(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if (skippy < 0) || (iNdEx+skippy) < 0 {
-				return ErrInvalidLengthHotstuff
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
+		case 9:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Txs", wireType)
 			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Txs = append(m.Txs, make([]byte, postIndex-iNdEx))
+			copy(m.Txs[len(m.Txs)-1], dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 10:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field AppHeight", wireType)
+			}
+			m.AppHeight = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.AppHeight |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 11:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field AppHash", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.AppHash = append(m.AppHash[:0], dAtA[iNdEx:postIndex]...)
+			if m.AppHash == nil {
+				m.AppHash = []byte{}
+			}
+			iNdEx = postIndex
+		case 12:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field VoteExtensions", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.VoteExtensions = append(m.VoteExtensions, make([]byte, postIndex-iNdEx))
+			copy(m.VoteExtensions[len(m.VoteExtensions)-1], dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipHotstuff(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
 		}
 	}
 
@@ -1753,7 +2741,474 @@ func (m *VoteMessage) Unmarshal(dAtA []byte) error {
 			iNdEx = postIndex
 		case 3:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field CommitInfo", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field CommitInfo", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.CommitInfo = append(m.CommitInfo[:0], dAtA[iNdEx:postIndex]...)
+			if m.CommitInfo == nil {
+				m.CommitInfo = []byte{}
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Timestamp", wireType)
+			}
+			m.Timestamp = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Timestamp |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Pid", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Pid = append(m.Pid[:0], dAtA[iNdEx:postIndex]...)
+			if m.Pid == nil {
+				m.Pid = []byte{}
+			}
+			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Pk", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Pk = append(m.Pk[:0], dAtA[iNdEx:postIndex]...)
+			if m.Pk == nil {
+				m.Pk = []byte{}
+			}
+			iNdEx = postIndex
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Signature", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Signature = append(m.Signature[:0], dAtA[iNdEx:postIndex]...)
+			if m.Signature == nil {
+				m.Signature = []byte{}
+			}
+			iNdEx = postIndex
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Extension", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Extension = append(m.Extension[:0], dAtA[iNdEx:postIndex]...)
+			if m.Extension == nil {
+				m.Extension = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipHotstuff(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *VoteInfo) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowHotstuff
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: VoteInfo: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: VoteInfo: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ProposalRound", wireType)
+			}
+			m.ProposalRound = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ProposalRound |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ProposalId", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ProposalId = append(m.ProposalId[:0], dAtA[iNdEx:postIndex]...)
+			if m.ProposalId == nil {
+				m.ProposalId = []byte{}
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ParentRound", wireType)
+			}
+			m.ParentRound = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ParentRound |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ParentId", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ParentId = append(m.ParentId[:0], dAtA[iNdEx:postIndex]...)
+			if m.ParentId == nil {
+				m.ParentId = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipHotstuff(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TimoutMessage) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowHotstuff
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TimoutMessage: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TimoutMessage: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Module", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Module = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Round", wireType)
+			}
+			m.Round = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Round |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ParentRound", wireType)
+			}
+			m.ParentRound = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ParentRound |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ParentId", wireType)
 			}
 			var byteLen int
 			for shift := uint(0); ; shift += 7 {
@@ -1780,12 +3235,31 @@ func (m *VoteMessage) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.CommitInfo = append(m.CommitInfo[:0], dAtA[iNdEx:postIndex]...)
-			if m.CommitInfo == nil {
-				m.CommitInfo = []byte{}
+			m.ParentId = append(m.ParentId[:0], dAtA[iNdEx:postIndex]...)
+			if m.ParentId == nil {
+				m.ParentId = []byte{}
 			}
 			iNdEx = postIndex
-		case 4:
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Index", wireType)
+			}
+			m.Index = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Index |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
 			if wireType != 0 {
 				return fmt.Errorf("proto: wrong wireType = %d for field Timestamp", wireType)
 			}
@@ -1804,7 +3278,7 @@ func (m *VoteMessage) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
-		case 5:
+		case 7:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field Pid", wireType)
 			}
@@ -1838,7 +3312,7 @@ func (m *VoteMessage) Unmarshal(dAtA []byte) error {
 				m.Pid = []byte{}
 			}
 			iNdEx = postIndex
-		case 6:
+		case 8:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field Pk", wireType)
 			}
@@ -1872,7 +3346,7 @@ func (m *VoteMessage) Unmarshal(dAtA []byte) error {
 				m.Pk = []byte{}
 			}
 			iNdEx = postIndex
-		case 7:
+		case 9:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field Signature", wireType)
 			}
@@ -1906,6 +3380,40 @@ func (m *VoteMessage) Unmarshal(dAtA []byte) error {
 				m.Signature = []byte{}
 			}
 			iNdEx = postIndex
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field HighQc", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.HighQc = append(m.HighQc[:0], dAtA[iNdEx:postIndex]...)
+			if m.HighQc == nil {
+				m.HighQc = []byte{}
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipHotstuff(dAtA[iNdEx:])
@@ -1928,7 +3436,7 @@ func (m *VoteMessage) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *VoteInfo) Unmarshal(dAtA []byte) error {
+func (m *StatusMessage) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1951,17 +3459,17 @@ func (m *VoteInfo) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: VoteInfo: wiretype end group for non-group")
+			return fmt.Errorf("proto: StatusMessage: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: VoteInfo: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: StatusMessage: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ProposalRound", wireType)
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Module", wireType)
 			}
-			m.ProposalRound = 0
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowHotstuff
@@ -1971,14 +3479,103 @@ func (m *VoteInfo) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.ProposalRound |= int64(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Module = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CommittedRound", wireType)
+			}
+			m.CommittedRound = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.CommittedRound |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CurrentRound", wireType)
+			}
+			m.CurrentRound = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.CurrentRound |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field HighQcRound", wireType)
+			}
+			m.HighQcRound = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.HighQcRound |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Timestamp", wireType)
+			}
+			m.Timestamp = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHotstuff
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Timestamp |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ProposalId", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Pid", wireType)
 			}
 			var byteLen int
 			for shift := uint(0); ; shift += 7 {
@@ -2005,16 +3602,16 @@ func (m *VoteInfo) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.ProposalId = append(m.ProposalId[:0], dAtA[iNdEx:postIndex]...)
-			if m.ProposalId == nil {
-				m.ProposalId = []byte{}
+			m.Pid = append(m.Pid[:0], dAtA[iNdEx:postIndex]...)
+			if m.Pid == nil {
+				m.Pid = []byte{}
 			}
 			iNdEx = postIndex
-		case 3:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ParentRound", wireType)
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Pk", wireType)
 			}
-			m.ParentRound = 0
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowHotstuff
@@ -2024,14 +3621,29 @@ func (m *VoteInfo) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.ParentRound |= int64(b&0x7F) << shift
+				byteLen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 4:
+			if byteLen < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHotstuff
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Pk = append(m.Pk[:0], dAtA[iNdEx:postIndex]...)
+			if m.Pk == nil {
+				m.Pk = []byte{}
+			}
+			iNdEx = postIndex
+		case 8:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ParentId", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Signature", wireType)
 			}
 			var byteLen int
 			for shift := uint(0); ; shift += 7 {
@@ -2058,9 +3670,9 @@ func (m *VoteInfo) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.ParentId = append(m.ParentId[:0], dAtA[iNdEx:postIndex]...)
-			if m.ParentId == nil {
-				m.ParentId = []byte{}
+			m.Signature = append(m.Signature[:0], dAtA[iNdEx:postIndex]...)
+			if m.Signature == nil {
+				m.Signature = []byte{}
 			}
 			iNdEx = postIndex
 		default:
@@ -2085,7 +3697,7 @@ func (m *VoteInfo) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *TimoutMessage) Unmarshal(dAtA []byte) error {
+func (m *ProposalRequestMessage) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -2108,10 +3720,10 @@ func (m *TimoutMessage) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: TimoutMessage: wiretype end group for non-group")
+			return fmt.Errorf("proto: ProposalRequestMessage: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: TimoutMessage: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: ProposalRequestMessage: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
@@ -2166,27 +3778,8 @@ func (m *TimoutMessage) Unmarshal(dAtA []byte) error {
 				}
 			}
 		case 3:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ParentRound", wireType)
-			}
-			m.ParentRound = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowHotstuff
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.ParentRound |= int64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 4:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ParentId", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
 			}
 			var byteLen int
 			for shift := uint(0); ; shift += 7 {
@@ -2213,31 +3806,12 @@ func (m *TimoutMessage) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.ParentId = append(m.ParentId[:0], dAtA[iNdEx:postIndex]...)
-			if m.ParentId == nil {
-				m.ParentId = []byte{}
+			m.Id = append(m.Id[:0], dAtA[iNdEx:postIndex]...)
+			if m.Id == nil {
+				m.Id = []byte{}
 			}
 			iNdEx = postIndex
-		case 5:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Index", wireType)
-			}
-			m.Index = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowHotstuff
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Index |= int64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 6:
+		case 4:
 			if wireType != 0 {
 				return fmt.Errorf("proto: wrong wireType = %d for field Timestamp", wireType)
 			}
@@ -2256,7 +3830,7 @@ func (m *TimoutMessage) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
-		case 7:
+		case 5:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field Pid", wireType)
 			}
@@ -2290,7 +3864,7 @@ func (m *TimoutMessage) Unmarshal(dAtA []byte) error {
 				m.Pid = []byte{}
 			}
 			iNdEx = postIndex
-		case 8:
+		case 6:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field Pk", wireType)
 			}
@@ -2324,7 +3898,7 @@ func (m *TimoutMessage) Unmarshal(dAtA []byte) error {
 				m.Pk = []byte{}
 			}
 			iNdEx = postIndex
-		case 9:
+		case 7:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field Signature", wireType)
 			}
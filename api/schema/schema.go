@@ -0,0 +1,205 @@
+// Package schema is the registry of the msgs which go over the wire between the nodes, their schemas are
+// described from the generated code and checked against the golden file of the last release, so that a change
+// breaking the peers running the older binaries, e.g. a renumbered field, fails the build instead of forking
+// a live network. The json names are part of the schema since the msgs are signed by their json encoding.
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
+)
+
+// Wire registers the msgs sent between the nodes, a new msg of the wire must be added here
+// to be protected by the golden file.
+var Wire = []interface{}{
+	// conn.proto
+	&pb.Packet{},
+	&pb.PacketMsg{},
+	&pb.PacketPing{},
+	&pb.Handshake{},
+	// hotstuff.proto
+	&pb.Message{},
+	&pb.ProposalMessage{},
+	&pb.VoteMessage{},
+	&pb.VoteInfo{},
+	&pb.TimoutMessage{},
+	&pb.StatusMessage{},
+	&pb.ProposalRequestMessage{},
+	// mempool.proto
+	&pb.TxsMessage{},
+	// observer.proto
+	&pb.ObserverMessage{},
+	&pb.ObserverSubscribe{},
+	&pb.ObserverBlock{},
+	&pb.ObserverFetch{},
+	// join.proto
+	&pb.JoinMessage{},
+}
+
+// Field is a field of a msg as it's encoded, Type is the go type of the generated field,
+// Oneof is the oneof the field belongs to, if any.
+type Field struct {
+	Number   int    `json:"number"`
+	Name     string `json:"name"`
+	JSON     string `json:"json"`
+	Wire     string `json:"wire"`
+	Type     string `json:"type"`
+	Repeated bool   `json:"repeated,omitempty"`
+	Oneof    string `json:"oneof,omitempty"`
+}
+
+// Message is the schema of a msg, the fields are ordered by their numbers.
+type Message struct {
+	Name   string  `json:"name"`
+	Fields []Field `json:"fields"`
+}
+
+// Snapshot describes the registered msgs ordered by their names.
+func Snapshot() ([]Message, error) {
+	var msgs []Message
+	for _, m := range Wire {
+		desc, err := Describe(m)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, desc)
+	}
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].Name < msgs[j].Name })
+	return msgs, nil
+}
+
+// Describe reads the schema of the generated msg from the tags of its fields.
+func Describe(msg interface{}) (Message, error) {
+	t := reflect.TypeOf(msg)
+	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return Message{}, fmt.Errorf("want a pointer to a generated msg, has: %T", msg)
+	}
+	t = t.Elem()
+	desc := Message{Name: t.Name()}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if oneof := sf.Tag.Get("protobuf_oneof"); oneof != "" {
+			fields, err := oneofFields(msg, oneof)
+			if err != nil {
+				return Message{}, fmt.Errorf("msg: %s, err: %v", desc.Name, err)
+			}
+			desc.Fields = append(desc.Fields, fields...)
+			continue
+		}
+		if sf.Tag.Get("protobuf") == "" {
+			continue
+		}
+		f, err := parseField(sf)
+		if err != nil {
+			return Message{}, fmt.Errorf("msg: %s, err: %v", desc.Name, err)
+		}
+		desc.Fields = append(desc.Fields, f)
+	}
+	sort.Slice(desc.Fields, func(i, j int) bool { return desc.Fields[i].Number < desc.Fields[j].Number })
+	return desc, nil
+}
+
+// oneofFields describes the fields of the oneof by the wrappers of the msg.
+func oneofFields(msg interface{}, oneof string) ([]Field, error) {
+	w, ok := msg.(interface{ XXX_OneofWrappers() []interface{} })
+	if !ok {
+		return nil, fmt.Errorf("no wrappers of the oneof %s", oneof)
+	}
+	var fields []Field
+	for _, wrapper := range w.XXX_OneofWrappers() {
+		wt := reflect.TypeOf(wrapper).Elem()
+		if wt.NumField() != 1 {
+			return nil, fmt.Errorf("unexpected wrapper of the oneof %s: %s", oneof, wt.Name())
+		}
+		f, err := parseField(wt.Field(0))
+		if err != nil {
+			return nil, err
+		}
+		f.Oneof = oneof
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// parseField parses the tags of the generated field, e.g. `protobuf:"bytes,1,opt,name=log_id,json=logId,proto3"`.
+func parseField(sf reflect.StructField) (Field, error) {
+	parts := strings.Split(sf.Tag.Get("protobuf"), ",")
+	if len(parts) < 3 {
+		return Field{}, fmt.Errorf("malformed protobuf tag of the field %s", sf.Name)
+	}
+	num, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return Field{}, fmt.Errorf("malformed number of the field %s, err: %v", sf.Name, err)
+	}
+	f := Field{Number: num, Wire: parts[0], Type: sf.Type.String(), Repeated: parts[2] == "rep"}
+	for _, p := range parts[3:] {
+		if strings.HasPrefix(p, "name=") {
+			f.Name = strings.TrimPrefix(p, "name=")
+		}
+	}
+	f.JSON = strings.Split(sf.Tag.Get("json"), ",")[0]
+	return f, nil
+}
+
+// Check reports the changes from the old schema to the new one which break the peers of the old one:
+// a removed msg or field, a renumbered or renamed field, or a field of another type, json name or oneof.
+// The added msgs and fields are compatible.
+func Check(old, new []Message) []error {
+	msgs := make(map[string]Message, len(new))
+	for _, m := range new {
+		msgs[m.Name] = m
+	}
+	var errs []error
+	for _, o := range old {
+		n, ok := msgs[o.Name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("msg %s is removed", o.Name))
+			continue
+		}
+		byNumber := make(map[int]Field, len(n.Fields))
+		byName := make(map[string]Field, len(n.Fields))
+		for _, f := range n.Fields {
+			byNumber[f.Number], byName[f.Name] = f, f
+		}
+		for _, of := range o.Fields {
+			nf, ok := byNumber[of.Number]
+			if !ok {
+				if moved, ok := byName[of.Name]; ok {
+					errs = append(errs, fmt.Errorf("field %s.%s is renumbered from %d to %d", o.Name, of.Name, of.Number, moved.Number))
+				} else {
+					errs = append(errs, fmt.Errorf("field %s.%s (%d) is removed, reserve its number instead", o.Name, of.Name, of.Number))
+				}
+				continue
+			}
+			if d := fieldDiff(of, nf); d != "" {
+				errs = append(errs, fmt.Errorf("field %s.%s (%d) is changed, %s", o.Name, of.Name, of.Number, d))
+			}
+		}
+	}
+	return errs
+}
+
+func fieldDiff(o, n Field) string {
+	var diffs []string
+	for _, d := range []struct {
+		what     string
+		old, new string
+	}{
+		{"name", o.Name, n.Name},
+		{"json name, which changes the signed bytes,", o.JSON, n.JSON},
+		{"wire type", o.Wire, n.Wire},
+		{"type", o.Type, n.Type},
+		{"repeated", strconv.FormatBool(o.Repeated), strconv.FormatBool(n.Repeated)},
+		{"oneof", o.Oneof, n.Oneof},
+	} {
+		if d.old != d.new {
+			diffs = append(diffs, fmt.Sprintf("%s: %q -> %q", d.what, d.old, d.new))
+		}
+	}
+	return strings.Join(diffs, ", ")
+}
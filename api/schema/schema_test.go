@@ -0,0 +1,101 @@
+package schema
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the current schemas")
+
+const goldenPath = "testdata/wire.golden.json"
+
+// TestWireSchema fails on a change of the wire msgs breaking the peers of the golden schema, the compatible
+// changes, e.g. a new field, are accepted by rewriting the golden file: go test ./api/schema -update
+func TestWireSchema(t *testing.T) {
+	current, err := Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *update {
+		data, err := json.MarshalIndent(current, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Dir(goldenPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(goldenPath, append(data, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	data, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var golden []Message
+	if err := json.Unmarshal(data, &golden); err != nil {
+		t.Fatal(err)
+	}
+	if errs := Check(golden, current); len(errs) > 0 {
+		for _, err := range errs {
+			t.Errorf("incompatible wire change: %v", err)
+		}
+		return
+	}
+	if !reflect.DeepEqual(golden, current) {
+		t.Errorf("the wire msgs have changed compatibly, accept the change by: go test ./api/schema -update")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	vote, err := Describe(&struct {
+		Round     int64  `protobuf:"varint,1,opt,name=round,proto3" json:"round,omitempty"`
+		Id        []byte `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+		Signature []byte `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+	}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vote.Fields) != 3 || vote.Fields[1].Wire != "bytes" || vote.Fields[1].Type != "[]uint8" {
+		t.Fatalf("unexpected schema: %+v", vote)
+	}
+	old := []Message{vote}
+	change := func(f func(m *Message)) []Message {
+		m := Message{Name: vote.Name, Fields: append([]Field{}, vote.Fields...)}
+		f(&m)
+		return []Message{m}
+	}
+
+	added := change(func(m *Message) {
+		m.Fields = append(m.Fields, Field{Number: 4, Name: "extension", JSON: "extension", Wire: "bytes", Type: "[]uint8"})
+	})
+	if errs := Check(old, added); len(errs) != 0 {
+		t.Errorf("a new field should be compatible, errs: %v", errs)
+	}
+	for _, c := range []struct {
+		desc string
+		new  []Message
+		want string
+	}{
+		{"removed msg", nil, "is removed"},
+		{"renumbered", change(func(m *Message) { m.Fields[2].Number = 7 }), "renumbered from 3 to 7"},
+		{"removed field", change(func(m *Message) { m.Fields = m.Fields[:2] }), "reserve its number"},
+		{"retyped", change(func(m *Message) { m.Fields[0].Wire, m.Fields[0].Type = "bytes", "string" }), "wire type"},
+		{"json renamed", change(func(m *Message) { m.Fields[1].JSON = "block_id" }), "signed bytes"},
+		{"swapped", change(func(m *Message) {
+			m.Fields[1].Number, m.Fields[2].Number = 3, 2
+		}), "name"},
+	} {
+		errs := Check(old, c.new)
+		if len(errs) == 0 || !strings.Contains(errs[0].Error(), c.want) {
+			t.Errorf("%s: want the err containing %q, has: %v", c.desc, c.want, errs)
+		}
+	}
+}
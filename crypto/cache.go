@@ -0,0 +1,130 @@
+package crypto
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+const (
+	DefaultVerifyCacheSize = 10000
+	DefaultVerifyCacheTTL  = 10 * time.Minute
+)
+
+// VerifyCache is a LRU cache of the hashes of verified msgs,
+// entries expire after ttl so that a stale one cannot be trusted forever.
+type VerifyCache struct {
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	list    *list.List
+	// hits and misses count the lookups, atomic
+	hits   int64
+	misses int64
+
+	mtx sync.Mutex
+}
+
+type cacheEntry struct {
+	key    string
+	expire time.Time
+}
+
+func NewVerifyCache(size int, ttl time.Duration) *VerifyCache {
+	if size <= 0 {
+		size = DefaultVerifyCacheSize
+	}
+	if ttl <= 0 {
+		ttl = DefaultVerifyCacheTTL
+	}
+	return &VerifyCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element, size),
+		list:    list.New(),
+	}
+}
+
+func (c *VerifyCache) Add(key string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*cacheEntry).expire = time.Now().Add(c.ttl)
+		c.list.MoveToFront(e)
+		return
+	}
+	if c.list.Len() >= c.size {
+		if back := c.list.Back(); back != nil {
+			delete(c.entries, back.Value.(*cacheEntry).key)
+			c.list.Remove(back)
+		}
+	}
+	c.entries[key] = c.list.PushFront(&cacheEntry{key: key, expire: time.Now().Add(c.ttl)})
+}
+
+func (c *VerifyCache) Has(key string) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		atomic.AddInt64(&c.misses, 1)
+		return false
+	}
+	if time.Now().After(e.Value.(*cacheEntry).expire) {
+		delete(c.entries, key)
+		c.list.Remove(e)
+		atomic.AddInt64(&c.misses, 1)
+		return false
+	}
+	c.list.MoveToFront(e)
+	atomic.AddInt64(&c.hits, 1)
+	return true
+}
+
+// Stats returns the lookups which found a live entry and the ones which didn't.
+func (c *VerifyCache) Stats() (hits, misses int64) {
+	return atomic.LoadInt64(&c.hits), atomic.LoadInt64(&c.misses)
+}
+
+// CachedCryptoClient skips the signature verification of the msgs which have been verified before.
+type CachedCryptoClient struct {
+	CryptoClient
+	cache *VerifyCache
+}
+
+func NewCachedCryptoClient(cc CryptoClient, size int, ttl time.Duration) *CachedCryptoClient {
+	return &CachedCryptoClient{
+		CryptoClient: cc,
+		cache:        NewVerifyCache(size, ttl),
+	}
+}
+
+// Verify looks up the cache by the signed bytes, the signer key and the signature of the msg, so that
+// the same signed msg in another envelope, e.g. relayed by another peer, isn't verified again.
+// The msgs whose signed bytes can't be told are left to the wrapped client.
+func (cc *CachedCryptoClient) Verify(sign []byte, pk []byte, msgBytes []byte) (bool, error) {
+	data, sig, pub, err := signedData(msgBytes)
+	if err != nil {
+		return cc.CryptoClient.Verify(sign, pk, msgBytes)
+	}
+	key := libs.GetSum(data) + libs.GetSum(pub) + libs.GetSum(sig)
+	if cc.cache.Has(key) {
+		return true, nil
+	}
+	valid, err := cc.CryptoClient.Verify(sign, pk, msgBytes)
+	if err != nil || !valid {
+		return valid, err
+	}
+	cc.cache.Add(key)
+	return true, nil
+}
+
+// CacheStats returns the hits and the misses of the verify cache, see VerifyCache.Stats.
+func (cc *CachedCryptoClient) CacheStats() (hits, misses int64) {
+	return cc.cache.Stats()
+}
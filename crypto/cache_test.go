@@ -0,0 +1,71 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestCachedVerify(t *testing.T) {
+	sk, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	cc := NewCachedCryptoClient(&DefaultCryptoClient{SK: sk, PK: &sk.PublicKey}, 0, 0)
+	msg := &pb.Message{
+		Module:  libs.ConsensusModule,
+		ChainId: "test",
+		Sum: &pb.Message_Vote{
+			Vote: &pb.VoteMessage{Module: libs.ConsensusModule, Timestamp: 1, Pid: []byte("a")},
+		},
+	}
+	b, err := msg.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := cc.Sign(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// reenvelope decodes the signed msg, changes it and encodes it again
+	reenvelope := func(f func(msg *pb.Message)) []byte {
+		var msg pb.Message
+		if err := proto.Unmarshal(signed, &msg); err != nil {
+			t.Fatal(err)
+		}
+		f(&msg)
+		b, err := proto.Marshal(&msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	relayed := reenvelope(func(msg *pb.Message) { msg.Module = "other/" + libs.ConsensusModule })
+	tampered := reenvelope(func(msg *pb.Message) { msg.GetVote().Timestamp = 2 })
+
+	cases := []struct {
+		name         string
+		msg          []byte
+		valid        bool
+		hits, misses int64
+	}{
+		{"first", signed, true, 0, 1},
+		{"again", signed, true, 1, 1},
+		// the envelope isn't signed, the signed vote is the same
+		{"relayed", relayed, true, 2, 1},
+		// the signature doesn't match the signed bytes, which is never cached
+		{"tampered", tampered, false, 2, 2},
+		{"tampered again", tampered, false, 2, 3},
+	}
+	for _, c := range cases {
+		valid, err := cc.Verify(nil, nil, c.msg)
+		if err != nil || valid != c.valid {
+			t.Errorf("%s, want valid: %v, has: %v, err: %v", c.name, c.valid, valid, err)
+		}
+		if hits, misses := cc.CacheStats(); hits != c.hits || misses != c.misses {
+			t.Errorf("%s, want hits: %d, misses: %d, has: %d, %d", c.name, c.hits, c.misses, hits, misses)
+		}
+	}
+}
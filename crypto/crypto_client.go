@@ -10,8 +10,8 @@ import (
 	"fmt"
 	"math/big"
 
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
 	"github.com/aucusaga/gohotstuff/libs"
-	"github.com/aucusaga/gohotstuff/pb"
 	"github.com/golang/protobuf/proto"
 )
 
@@ -50,20 +50,11 @@ type Private struct {
 }
 
 func InitCryptoClient(privateBytes []byte) error {
-	privateKey := new(Private)
-	if err := json.Unmarshal(privateBytes, privateKey); err != nil {
+	SK, err := ParsePrivateKey(privateBytes)
+	if err != nil {
 		return err
 	}
-	pk := ecdsa.PublicKey{
-		Curve: elliptic.P256(),
-		X:     privateKey.X,
-		Y:     privateKey.Y,
-	}
-	SK := &ecdsa.PrivateKey{
-		PublicKey: pk,
-		D:         privateKey.D,
-	}
-	PK := &pk
+	PK := &SK.PublicKey
 
 	CryptoClientPicker = func() CryptoClient {
 		return &DefaultCryptoClient{
@@ -74,32 +65,56 @@ func InitCryptoClient(privateBytes []byte) error {
 	return nil
 }
 
+// ParsePrivateKey parses the private.key generated by GenKeyPair.
+func ParsePrivateKey(privateBytes []byte) (*ecdsa.PrivateKey, error) {
+	privateKey := new(Private)
+	if err := json.Unmarshal(privateBytes, privateKey); err != nil {
+		return nil, err
+	}
+	pk := ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     privateKey.X,
+		Y:     privateKey.Y,
+	}
+	return &ecdsa.PrivateKey{
+		PublicKey: pk,
+		D:         privateKey.D,
+	}, nil
+}
+
 // Sign uses hotstuffpb only.
 // crypto_client will load its own public_key and
 // use private_key to sign msg ignore the tag signatrue
 func (cc *DefaultCryptoClient) Sign(msgBytes []byte) ([]byte, error) {
+	return signMsg(msgBytes, cc.PublicKey(), cc.sign)
+}
+
+// signMsg rebuilds the msg with the public key pk and its signature by sign over the domain separated bytes.
+func signMsg(msgBytes []byte, pk []byte, sign func(data []byte) ([]byte, error)) ([]byte, error) {
 	var msg pb.Message
 	if err := proto.Unmarshal(msgBytes, &msg); err != nil {
 		return nil, fmt.Errorf("unmarshal bytes fail @ crypto.Sign, err: %v", err)
 	}
 
-	var new pb.Message
+	new := pb.Message{ChainId: msg.ChainId, ForkId: msg.ForkId, Epoch: msg.Epoch}
 	switch msg := msg.Sum.(type) {
 	case *pb.Message_Proposal:
 		proposal := &pb.ProposalMessage{
-			Module:    libs.ConsensusModule,
-			Round:     msg.Proposal.Round,
-			Id:        msg.Proposal.Id,
-			Timestamp: msg.Proposal.Timestamp,
-			Pid:       msg.Proposal.Pid,
-			Justify:   msg.Proposal.Justify,
-			Pk:        elliptic.Marshal(elliptic.P256(), cc.PK.X, cc.PK.Y),
+			Module:         libs.ConsensusModule,
+			Round:          msg.Proposal.Round,
+			Id:             msg.Proposal.Id,
+			Timestamp:      msg.Proposal.Timestamp,
+			Pid:            msg.Proposal.Pid,
+			Justify:        msg.Proposal.Justify,
+			Txs:            msg.Proposal.Txs,
+			Pk:             pk,
+			VoteExtensions: msg.Proposal.VoteExtensions,
 		}
 		wait, err := json.Marshal(proposal)
 		if err != nil {
 			return nil, err
 		}
-		signatrue, err := cc.sign(wait)
+		signatrue, err := sign(ForkSignBytes(new.ChainId, new.ForkId, new.Epoch, DomainProposal, wait))
 		if err != nil {
 			return nil, err
 		}
@@ -117,13 +132,14 @@ func (cc *DefaultCryptoClient) Sign(msgBytes []byte) ([]byte, error) {
 			CommitInfo: msg.Vote.CommitInfo,
 			Timestamp:  msg.Vote.Timestamp,
 			Pid:        msg.Vote.Pid,
-			Pk:         elliptic.Marshal(elliptic.P256(), cc.PK.X, cc.PK.Y),
+			Pk:         pk,
+			Extension:  msg.Vote.Extension,
 		}
 		wait, err := json.Marshal(vote)
 		if err != nil {
 			return nil, err
 		}
-		signatrue, err := cc.sign(wait)
+		signatrue, err := sign(ForkSignBytes(new.ChainId, new.ForkId, new.Epoch, DomainVote, wait))
 		if err != nil {
 			return nil, err
 		}
@@ -140,16 +156,17 @@ func (cc *DefaultCryptoClient) Sign(msgBytes []byte) ([]byte, error) {
 			Round:       msg.Timeout.Round,
 			ParentRound: msg.Timeout.ParentRound,
 			ParentId:    msg.Timeout.ParentId,
+			HighQc:      msg.Timeout.HighQc,
 			Index:       msg.Timeout.Index,
 			Timestamp:   msg.Timeout.Timestamp,
 			Pid:         msg.Timeout.Pid,
-			Pk:          elliptic.Marshal(elliptic.P256(), cc.PK.X, cc.PK.Y),
+			Pk:          pk,
 		}
 		wait, err := json.Marshal(timeout)
 		if err != nil {
 			return nil, err
 		}
-		signatrue, err := cc.sign(wait)
+		signatrue, err := sign(ForkSignBytes(new.ChainId, new.ForkId, new.Epoch, DomainTimeout, wait))
 		if err != nil {
 			return nil, err
 		}
@@ -160,33 +177,95 @@ func (cc *DefaultCryptoClient) Sign(msgBytes []byte) ([]byte, error) {
 			Timeout: timeout,
 		}
 		return proto.Marshal(&new)
+	case *pb.Message_Status:
+		status := &pb.StatusMessage{
+			Module:         libs.ConsensusModule,
+			CommittedRound: msg.Status.CommittedRound,
+			CurrentRound:   msg.Status.CurrentRound,
+			HighQcRound:    msg.Status.HighQcRound,
+			Timestamp:      msg.Status.Timestamp,
+			Pid:            msg.Status.Pid,
+			Pk:             pk,
+		}
+		wait, err := json.Marshal(status)
+		if err != nil {
+			return nil, err
+		}
+		signatrue, err := sign(ForkSignBytes(new.ChainId, new.ForkId, new.Epoch, DomainStatus, wait))
+		if err != nil {
+			return nil, err
+		}
+		status.Signature = signatrue
+
+		new.Module = libs.ConsensusModule
+		new.Sum = &pb.Message_Status{
+			Status: status,
+		}
+		return proto.Marshal(&new)
+	case *pb.Message_ProposalRequest:
+		req := &pb.ProposalRequestMessage{
+			Module:    libs.ConsensusModule,
+			Round:     msg.ProposalRequest.Round,
+			Id:        msg.ProposalRequest.Id,
+			Timestamp: msg.ProposalRequest.Timestamp,
+			Pid:       msg.ProposalRequest.Pid,
+			Pk:        pk,
+		}
+		wait, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		signatrue, err := sign(ForkSignBytes(new.ChainId, new.ForkId, new.Epoch, DomainProposalRequest, wait))
+		if err != nil {
+			return nil, err
+		}
+		req.Signature = signatrue
+
+		new.Module = libs.ConsensusModule
+		new.Sum = &pb.Message_ProposalRequest{
+			ProposalRequest: req,
+		}
+		return proto.Marshal(&new)
 	default:
 	}
 	return nil, fmt.Errorf("unknown msg_info type")
 }
 
 func (cc *DefaultCryptoClient) Verify(sign []byte, pk []byte, msgBytes []byte) (bool, error) {
+	data, sig, pub, err := signedData(msgBytes)
+	if err != nil {
+		return false, err
+	}
+	return cc.verify(data, sig, pub)
+}
+
+// signedData returns the bytes signed by the sender of the msg in its domain, along with the signature
+// and the key carried by the msg, the fields out of the signed bytes, e.g. the module of the envelope, are left.
+func signedData(msgBytes []byte) (data, sign, pk []byte, err error) {
 	var msg pb.Message
 	if err := proto.Unmarshal(msgBytes, &msg); err != nil {
-		return false, fmt.Errorf("unmarshal bytes fail @ crypto.Verify, err: %v", err)
+		return nil, nil, nil, fmt.Errorf("unmarshal bytes fail @ crypto.Verify, err: %v", err)
 	}
 
+	chainID, forkID, epoch := msg.ChainId, msg.ForkId, msg.Epoch
 	switch msg := msg.Sum.(type) {
 	case *pb.Message_Proposal:
 		proposal := &pb.ProposalMessage{
-			Module:    libs.ConsensusModule,
-			Round:     msg.Proposal.Round,
-			Id:        msg.Proposal.Id,
-			Timestamp: msg.Proposal.Timestamp,
-			Pid:       msg.Proposal.Pid,
-			Justify:   msg.Proposal.Justify,
-			Pk:        msg.Proposal.Pk,
+			Module:         libs.ConsensusModule,
+			Round:          msg.Proposal.Round,
+			Id:             msg.Proposal.Id,
+			Timestamp:      msg.Proposal.Timestamp,
+			Pid:            msg.Proposal.Pid,
+			Justify:        msg.Proposal.Justify,
+			Txs:            msg.Proposal.Txs,
+			Pk:             msg.Proposal.Pk,
+			VoteExtensions: msg.Proposal.VoteExtensions,
 		}
 		data, err := json.Marshal(proposal)
 		if err != nil {
-			return false, err
+			return nil, nil, nil, err
 		}
-		return cc.verify(data, msg.Proposal.Signature, msg.Proposal.Pk)
+		return ForkSignBytes(chainID, forkID, epoch, DomainProposal, data), msg.Proposal.Signature, msg.Proposal.Pk, nil
 	case *pb.Message_Vote:
 		vote := &pb.VoteMessage{
 			Module:     libs.ConsensusModule,
@@ -195,18 +274,20 @@ func (cc *DefaultCryptoClient) Verify(sign []byte, pk []byte, msgBytes []byte) (
 			Timestamp:  msg.Vote.Timestamp,
 			Pid:        msg.Vote.Pid,
 			Pk:         msg.Vote.Pk,
+			Extension:  msg.Vote.Extension,
 		}
 		data, err := json.Marshal(vote)
 		if err != nil {
-			return false, err
+			return nil, nil, nil, err
 		}
-		return cc.verify(data, msg.Vote.Signature, msg.Vote.Pk)
+		return ForkSignBytes(chainID, forkID, epoch, DomainVote, data), msg.Vote.Signature, msg.Vote.Pk, nil
 	case *pb.Message_Timeout:
 		timeout := &pb.TimoutMessage{
 			Module:      libs.ConsensusModule,
 			Round:       msg.Timeout.Round,
 			ParentRound: msg.Timeout.ParentRound,
 			ParentId:    msg.Timeout.ParentId,
+			HighQc:      msg.Timeout.HighQc,
 			Index:       msg.Timeout.Index,
 			Timestamp:   msg.Timeout.Timestamp,
 			Pid:         msg.Timeout.Pid,
@@ -214,15 +295,54 @@ func (cc *DefaultCryptoClient) Verify(sign []byte, pk []byte, msgBytes []byte) (
 		}
 		data, err := json.Marshal(timeout)
 		if err != nil {
-			return false, err
+			return nil, nil, nil, err
 		}
-		return cc.verify(data, msg.Timeout.Signature, msg.Timeout.Pk)
+		return ForkSignBytes(chainID, forkID, epoch, DomainTimeout, data), msg.Timeout.Signature, msg.Timeout.Pk, nil
+	case *pb.Message_Status:
+		status := &pb.StatusMessage{
+			Module:         libs.ConsensusModule,
+			CommittedRound: msg.Status.CommittedRound,
+			CurrentRound:   msg.Status.CurrentRound,
+			HighQcRound:    msg.Status.HighQcRound,
+			Timestamp:      msg.Status.Timestamp,
+			Pid:            msg.Status.Pid,
+			Pk:             msg.Status.Pk,
+		}
+		data, err := json.Marshal(status)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return ForkSignBytes(chainID, forkID, epoch, DomainStatus, data), msg.Status.Signature, msg.Status.Pk, nil
+	case *pb.Message_ProposalRequest:
+		req := &pb.ProposalRequestMessage{
+			Module:    libs.ConsensusModule,
+			Round:     msg.ProposalRequest.Round,
+			Id:        msg.ProposalRequest.Id,
+			Timestamp: msg.ProposalRequest.Timestamp,
+			Pid:       msg.ProposalRequest.Pid,
+			Pk:        msg.ProposalRequest.Pk,
+		}
+		data, err := json.Marshal(req)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return ForkSignBytes(chainID, forkID, epoch, DomainProposalRequest, data), msg.ProposalRequest.Signature, msg.ProposalRequest.Pk, nil
 	default:
 	}
-	return false, fmt.Errorf("unknown msg_info type")
+	return nil, nil, nil, fmt.Errorf("unknown msg_info type")
+}
+
+// PublicKey is the key carried by the msgs signed by the client.
+func (cc *DefaultCryptoClient) PublicKey() []byte {
+	return elliptic.Marshal(elliptic.P256(), cc.PK.X, cc.PK.Y)
+}
+
+// SignBytes signs the raw bytes, see KeySigner.
+func (cc *DefaultCryptoClient) SignBytes(data []byte) ([]byte, error) {
+	return cc.sign(data)
 }
 
-func (cc *DefaultCryptoClient) hash(msgBytes []byte) []byte {
+func hash(msgBytes []byte) []byte {
 	h := sha256.New()
 	h.Write(msgBytes)
 	s1 := h.Sum(nil)
@@ -233,7 +353,11 @@ func (cc *DefaultCryptoClient) hash(msgBytes []byte) []byte {
 }
 
 func (cc *DefaultCryptoClient) sign(msgBytes []byte) ([]byte, error) {
-	r, s, err := ecdsa.Sign(rand.Reader, cc.SK, []byte(cc.hash(msgBytes)))
+	return signP256(cc.SK, msgBytes)
+}
+
+func signP256(sk *ecdsa.PrivateKey, msgBytes []byte) ([]byte, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, sk, hash(msgBytes))
 	if err != nil {
 		return nil, err
 	}
@@ -241,7 +365,21 @@ func (cc *DefaultCryptoClient) sign(msgBytes []byte) ([]byte, error) {
 }
 
 func (cc *DefaultCryptoClient) verify(data, sign []byte, pub []byte) (bool, error) {
+	return verifySignature(data, sign, pub)
+}
+
+// verifySignature checks the signature by the P256 key pub, or the t-of-n shares if pub is a QuorumKey.
+func verifySignature(data, sign []byte, pub []byte) (bool, error) {
+	if key, ok, err := DecodeQuorumKey(pub); ok {
+		if err != nil {
+			return false, nil
+		}
+		return key.verify(data, sign), nil
+	}
 	x, y := elliptic.Unmarshal(elliptic.P256(), pub)
+	if x == nil {
+		return false, nil
+	}
 	pk := &ecdsa.PublicKey{
 		Curve: elliptic.P256(),
 		X:     x,
@@ -253,5 +391,5 @@ func (cc *DefaultCryptoClient) verify(data, sign []byte, pub []byte) (bool, erro
 	if err != nil {
 		return false, nil
 	}
-	return ecdsa.Verify(pk, cc.hash(data), sig.R, sig.S), nil
+	return ecdsa.Verify(pk, hash(data), sig.R, sig.S), nil
 }
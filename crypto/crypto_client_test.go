@@ -3,8 +3,8 @@ package crypto
 import (
 	"testing"
 
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
 	"github.com/aucusaga/gohotstuff/libs"
-	"github.com/aucusaga/gohotstuff/pb"
 )
 
 var (
@@ -47,3 +47,71 @@ func TestSignNVerify(t *testing.T) {
 		t.Errorf("sign and verify fail")
 	}
 }
+
+func TestSignNVerifyStatus(t *testing.T) {
+	if err := InitCryptoClient([]byte(priKey)); err != nil {
+		t.Errorf("init crypto client err, err: %v", err)
+		return
+	}
+
+	cc := CryptoClientPicker()
+
+	msg := &pb.Message{
+		Module: libs.ConsensusModule,
+		Sum: &pb.Message_Status{
+			Status: &pb.StatusMessage{
+				Module:       libs.ConsensusModule,
+				CurrentRound: 2,
+				HighQcRound:  1,
+			},
+		},
+	}
+	b, err := msg.Marshal()
+	if err != nil {
+		t.Errorf("marshal err, err: %v", err)
+		return
+	}
+	newMSG, err := cc.Sign(b)
+	if err != nil {
+		t.Errorf("sign err, err: %v", err)
+		return
+	}
+	valid, err := cc.Verify(nil, nil, newMSG)
+	if err != nil || !valid {
+		t.Errorf("sign and verify fail, valid: %v, err: %v", valid, err)
+	}
+}
+
+func TestSignNVerifyProposalRequest(t *testing.T) {
+	if err := InitCryptoClient([]byte(priKey)); err != nil {
+		t.Errorf("init crypto client err, err: %v", err)
+		return
+	}
+
+	cc := CryptoClientPicker()
+
+	msg := &pb.Message{
+		Module: libs.ConsensusModule,
+		Sum: &pb.Message_ProposalRequest{
+			ProposalRequest: &pb.ProposalRequestMessage{
+				Module: libs.ConsensusModule,
+				Round:  2,
+				Id:     []byte("id"),
+			},
+		},
+	}
+	b, err := msg.Marshal()
+	if err != nil {
+		t.Errorf("marshal err, err: %v", err)
+		return
+	}
+	newMSG, err := cc.Sign(b)
+	if err != nil {
+		t.Errorf("sign err, err: %v", err)
+		return
+	}
+	valid, err := cc.Verify(nil, nil, newMSG)
+	if err != nil || !valid {
+		t.Errorf("sign and verify fail, valid: %v, err: %v", valid, err)
+	}
+}
@@ -0,0 +1,51 @@
+package crypto
+
+import (
+	"encoding/binary"
+)
+
+// the msg types of the signing domains
+const (
+	DomainProposal        = "proposal"
+	DomainVote            = "vote"
+	DomainTimeout         = "timeout"
+	DomainStatus          = "status"
+	DomainProposalRequest = "proposal_request"
+
+	domainPrefix = "gohotstuff/sign/v1"
+	// forkDomainPrefix tags the domains of the forks, which the v1 tags predate
+	forkDomainPrefix = "gohotstuff/sign/v2"
+)
+
+// SignBytes prefixes the payload with the domain separation tag of the chain, the msg type and the epoch,
+// so that a signature can never be replayed as another type of msg, on another chain or in another epoch.
+// The chain id and the type are length-prefixed, which keeps the tags of different domains apart.
+func SignBytes(chainID string, epoch int64, msgType string, payload []byte) []byte {
+	return signBytes(domainPrefix, []string{chainID, msgType}, epoch, payload)
+}
+
+// ForkSignBytes is SignBytes in the fork of the chain, a signature of one fork is never valid in another,
+// nor in the chain before the fork. The empty fork is the chain itself, whose tags stay v1 for the
+// signatures made before the forks.
+func ForkSignBytes(chainID string, forkID string, epoch int64, msgType string, payload []byte) []byte {
+	if forkID == "" {
+		return SignBytes(chainID, epoch, msgType, payload)
+	}
+	return signBytes(forkDomainPrefix, []string{chainID, forkID, msgType}, epoch, payload)
+}
+
+func signBytes(prefix string, tags []string, epoch int64, payload []byte) []byte {
+	size := len(prefix) + len(payload) + (len(tags)+1)*binary.MaxVarintLen64
+	for _, t := range tags {
+		size += len(t)
+	}
+	buf := make([]byte, 0, size)
+	var n [binary.MaxVarintLen64]byte
+	buf = append(buf, prefix...)
+	for _, t := range tags {
+		buf = append(buf, n[:binary.PutUvarint(n[:], uint64(len(t)))]...)
+		buf = append(buf, t...)
+	}
+	buf = append(buf, n[:binary.PutVarint(n[:], epoch)]...)
+	return append(buf, payload...)
+}
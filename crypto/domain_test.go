@@ -0,0 +1,117 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"testing"
+
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestSignDomainSeparation(t *testing.T) {
+	if err := InitCryptoClient([]byte(priKey)); err != nil {
+		t.Fatal(err)
+	}
+	cc := CryptoClientPicker().(*DefaultCryptoClient)
+	pk := elliptic.Marshal(elliptic.P256(), cc.PK.X, cc.PK.Y)
+
+	payload := []byte(`{"round":1}`)
+	sign, err := cc.sign(SignBytes("a", 0, DomainVote, payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		chainID string
+		epoch   int64
+		msgType string
+		want    bool
+	}{
+		{"a", 0, DomainVote, true},
+		{"a", 0, DomainTimeout, false},
+		{"a", 0, DomainProposal, false},
+		{"b", 0, DomainVote, false},
+		{"a", 1, DomainVote, false},
+	}
+	for i, c := range cases {
+		valid, err := cc.verify(SignBytes(c.chainID, c.epoch, c.msgType, payload), sign, pk)
+		if err != nil || valid != c.want {
+			t.Errorf("case %d, want: %v, has: %v, err: %v", i, c.want, valid, err)
+		}
+	}
+
+	// the length prefixes keep the tags apart
+	if bytes.Equal(SignBytes("ab", 0, "c", nil), SignBytes("a", 0, "bc", nil)) {
+		t.Errorf("tags of different domains should differ")
+	}
+}
+
+func TestForkSignBytes(t *testing.T) {
+	payload := []byte(`{"round":1}`)
+	if !bytes.Equal(ForkSignBytes("a", "", 2, DomainVote, payload), SignBytes("a", 2, DomainVote, payload)) {
+		t.Errorf("the empty fork should sign as the chain itself")
+	}
+	tags := [][]byte{
+		SignBytes("a", 2, DomainVote, payload),
+		ForkSignBytes("a", "f1", 2, DomainVote, payload),
+		ForkSignBytes("a", "f2", 2, DomainVote, payload),
+		ForkSignBytes("af", "1", 2, DomainVote, payload),
+		ForkSignBytes("a", "f1", 2, DomainTimeout, payload),
+	}
+	for i := range tags {
+		for j := i + 1; j < len(tags); j++ {
+			if bytes.Equal(tags[i], tags[j]) {
+				t.Errorf("tags %d and %d should differ", i, j)
+			}
+		}
+	}
+}
+
+func TestVerifyRefusesReplayedDomain(t *testing.T) {
+	if err := InitCryptoClient([]byte(priKey)); err != nil {
+		t.Fatal(err)
+	}
+	cc := CryptoClientPicker()
+
+	msg := &pb.Message{
+		Module:  libs.ConsensusModule,
+		ChainId: "a",
+		Epoch:   3,
+		Sum: &pb.Message_Vote{
+			Vote: &pb.VoteMessage{
+				Module:   libs.ConsensusModule,
+				VoteInfo: &pb.VoteInfo{ProposalRound: 2, ProposalId: []byte("id")},
+			},
+		},
+	}
+	b, err := msg.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := cc.Sign(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid, err := cc.Verify(nil, nil, signed); err != nil || !valid {
+		t.Fatalf("sign and verify fail, valid: %v, err: %v", valid, err)
+	}
+
+	for _, replay := range []func(m *pb.Message){
+		func(m *pb.Message) { m.ChainId = "b" },
+		func(m *pb.Message) { m.Epoch = 4 },
+	} {
+		var m pb.Message
+		if err := proto.Unmarshal(signed, &m); err != nil {
+			t.Fatal(err)
+		}
+		replay(&m)
+		replayed, err := proto.Marshal(&m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if valid, _ := cc.Verify(nil, nil, replayed); valid {
+			t.Errorf("replayed msg should fail the verification, chain: %s, epoch: %d", m.ChainId, m.Epoch)
+		}
+	}
+}
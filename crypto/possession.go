@@ -0,0 +1,117 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// DomainPossession and DomainKeyRotation are the signing domains of the proofs of possession and
+// of the rotations approved by the old keys, they are signed outside of any chain and epoch
+// so that a proof is valid wherever the key is registered. DomainJoin signs the join requests
+// of the candidates, which are bound to the chain they ask to join.
+const (
+	DomainPossession  = "possession"
+	DomainKeyRotation = "key_rotation"
+	DomainJoin        = "join"
+)
+
+var (
+	ErrPossessionUnsupported = errors.New("crypto client cannot prove the possession of its key")
+)
+
+// KeySigner is implemented by the crypto clients which sign raw bytes by the consensus key,
+// i.e. the proofs of possession, rather than a consensus msg.
+type KeySigner interface {
+	PublicKey() []byte
+	SignBytes(data []byte) ([]byte, error)
+}
+
+// PossessionBytes is the payload signed by the proof of possession of the key pk registered for
+// the validator id, binding the id into it keeps a proof from being replayed for another validator.
+func PossessionBytes(id string, pk []byte) []byte {
+	return keyBytes(DomainPossession, id, pk)
+}
+
+// RotationBytes is the payload signed by the old key of the validator id to approve the new key pk.
+func RotationBytes(id string, pk []byte) []byte {
+	return keyBytes(DomainKeyRotation, id, pk)
+}
+
+func keyBytes(domain string, id string, pk []byte) []byte {
+	var n [binary.MaxVarintLen64]byte
+	payload := make([]byte, 0, len(id)+len(pk)+binary.MaxVarintLen64)
+	payload = append(payload, n[:binary.PutUvarint(n[:], uint64(len(id)))]...)
+	payload = append(payload, id...)
+	payload = append(payload, pk...)
+	return SignBytes("", 0, domain, payload)
+}
+
+// ProvePossession returns the consensus key of the client and the proof that the client holds
+// its private key, which is required to register the key for the validator id.
+func ProvePossession(cc CryptoClient, id string) (pk []byte, proof []byte, err error) {
+	ks, ok := cc.(KeySigner)
+	if !ok {
+		return nil, nil, ErrPossessionUnsupported
+	}
+	pk = ks.PublicKey()
+	proof, err = ks.SignBytes(PossessionBytes(id, pk))
+	if err != nil {
+		return nil, nil, err
+	}
+	return pk, proof, nil
+}
+
+// VerifyPossession checks the proof of possession of the key pk registered for the validator id,
+// it rejects the rogue keys which are derived from the keys of the others without their private key.
+func VerifyPossession(id string, pk []byte, proof []byte) bool {
+	ok, _ := verifySignature(PossessionBytes(id, pk), proof, pk)
+	return ok
+}
+
+// ApproveRotation signs the rotation of the key of the validator id to pk by the key of the client.
+func ApproveRotation(cc CryptoClient, id string, pk []byte) ([]byte, error) {
+	ks, ok := cc.(KeySigner)
+	if !ok {
+		return nil, ErrPossessionUnsupported
+	}
+	return ks.SignBytes(RotationBytes(id, pk))
+}
+
+// VerifyRotation checks the approval of the rotation to the key pk by the old key of the validator id.
+func VerifyRotation(id string, old []byte, pk []byte, approval []byte) bool {
+	ok, _ := verifySignature(RotationBytes(id, pk), approval, old)
+	return ok
+}
+
+// JoinBytes is the payload signed by the key pk of the candidate asking to join the chain as the validator id
+// reachable at the address, the timestamp in unix milliseconds keeps an old request from being replayed.
+func JoinBytes(chainID string, id string, pk []byte, address string, timestamp int64) []byte {
+	var n [binary.MaxVarintLen64]byte
+	payload := make([]byte, 0, len(id)+len(pk)+len(address)+4*binary.MaxVarintLen64)
+	for _, field := range [][]byte{[]byte(id), pk, []byte(address)} {
+		payload = append(payload, n[:binary.PutUvarint(n[:], uint64(len(field)))]...)
+		payload = append(payload, field...)
+	}
+	payload = append(payload, n[:binary.PutVarint(n[:], timestamp)]...)
+	return SignBytes(chainID, 0, DomainJoin, payload)
+}
+
+// SignJoin signs the join request of the validator id by the key of the client, which is returned along.
+func SignJoin(cc CryptoClient, chainID string, id string, address string, timestamp int64) (pk []byte, sig []byte, err error) {
+	ks, ok := cc.(KeySigner)
+	if !ok {
+		return nil, nil, ErrPossessionUnsupported
+	}
+	pk = ks.PublicKey()
+	sig, err = ks.SignBytes(JoinBytes(chainID, id, pk, address, timestamp))
+	if err != nil {
+		return nil, nil, err
+	}
+	return pk, sig, nil
+}
+
+// VerifyJoin checks the signature of the join request by the key pk of the candidate.
+func VerifyJoin(chainID string, id string, pk []byte, address string, timestamp int64, sig []byte) bool {
+	ok, _ := verifySignature(JoinBytes(chainID, id, pk, address, timestamp), sig, pk)
+	return ok
+}
@@ -0,0 +1,206 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultSignTimeout bounds the collecting of the signature shares of a msg.
+const DefaultSignTimeout = 2 * time.Second
+
+// quorumKeyPrefix marks the public keys which are a QuorumKey, the rest is its asn1 encoding.
+var quorumKeyPrefix = []byte("gohotstuff/quorum/v1:")
+
+var (
+	ErrInvalidQuorum    = errors.New("invalid signer quorum")
+	ErrQuorumNotReached = errors.New("signature shares are below the threshold")
+)
+
+// Signer produces a signature share over the domain separated bytes of a msg, e.g. a remote signer machine.
+type Signer interface {
+	Sign(ctx context.Context, data []byte) ([]byte, error)
+	// PublicKey is the P256 key verifying the shares, in the uncompressed form.
+	PublicKey() []byte
+}
+
+// QuorumKey is the public key of a validator whose signing is split across n signers,
+// a signature is valid once Threshold of them have signed, so a single compromised signer
+// cannot sign a msg on its own. It's carried as the pk of the msgs, see Encode.
+type QuorumKey struct {
+	Threshold int
+	Keys      [][]byte
+}
+
+// quorumShare is a signature share of the Index-th key.
+type quorumShare struct {
+	Index int
+	Sig   []byte
+}
+
+func (k *QuorumKey) Validate() error {
+	if len(k.Keys) == 0 || k.Threshold <= 0 || k.Threshold > len(k.Keys) {
+		return fmt.Errorf("%w, threshold: %d, signers: %d", ErrInvalidQuorum, k.Threshold, len(k.Keys))
+	}
+	for i, pk := range k.Keys {
+		if x, _ := elliptic.Unmarshal(elliptic.P256(), pk); x == nil {
+			return fmt.Errorf("%w, signer %d has an invalid key", ErrInvalidQuorum, i)
+		}
+		for _, other := range k.Keys[:i] {
+			if bytes.Equal(pk, other) {
+				return fmt.Errorf("%w, signer %d has a duplicate key", ErrInvalidQuorum, i)
+			}
+		}
+	}
+	return nil
+}
+
+// Encode returns the public key carried by the msgs.
+func (k *QuorumKey) Encode() ([]byte, error) {
+	raw, err := asn1.Marshal(*k)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, quorumKeyPrefix...), raw...), nil
+}
+
+// DecodeQuorumKey parses the public key, ok is false if it isn't a QuorumKey.
+func DecodeQuorumKey(pk []byte) (key *QuorumKey, ok bool, err error) {
+	if !bytes.HasPrefix(pk, quorumKeyPrefix) {
+		return nil, false, nil
+	}
+	key = new(QuorumKey)
+	rest, err := asn1.Unmarshal(pk[len(quorumKeyPrefix):], key)
+	if err != nil {
+		return nil, true, fmt.Errorf("%w, err: %v", ErrInvalidQuorum, err)
+	}
+	if len(rest) > 0 {
+		return nil, true, fmt.Errorf("%w, trailing bytes: %d", ErrInvalidQuorum, len(rest))
+	}
+	return key, true, key.Validate()
+}
+
+// verify checks that at least Threshold distinct signers have signed the data.
+func (k *QuorumKey) verify(data []byte, sign []byte) bool {
+	var shares []quorumShare
+	if rest, err := asn1.Unmarshal(sign, &shares); err != nil || len(rest) > 0 {
+		return false
+	}
+	signed := make(map[int]bool)
+	for _, share := range shares {
+		if share.Index < 0 || share.Index >= len(k.Keys) || signed[share.Index] {
+			return false
+		}
+		if ok, _ := verifySignature(data, share.Sig, k.Keys[share.Index]); !ok {
+			return false
+		}
+		signed[share.Index] = true
+	}
+	return len(signed) >= k.Threshold
+}
+
+// LocalSigner signs by the P256 key in memory, it's what a remote signer machine runs, see SignerServer.
+type LocalSigner struct {
+	SK *ecdsa.PrivateKey
+}
+
+func (s *LocalSigner) Sign(ctx context.Context, data []byte) ([]byte, error) {
+	return signP256(s.SK, data)
+}
+
+func (s *LocalSigner) PublicKey() []byte {
+	return elliptic.Marshal(elliptic.P256(), s.SK.X, s.SK.Y)
+}
+
+// QuorumCryptoClient signs the msgs by a t-of-n quorum of signers, the shares are requested
+// from all the signers at once and the first Threshold valid ones make the signature.
+type QuorumCryptoClient struct {
+	DefaultCryptoClient
+	key     *QuorumKey
+	pk      []byte
+	signers []Signer
+	timeout time.Duration
+}
+
+// NewQuorumCryptoClient returns the client signing by threshold of the signers, whose order makes the QuorumKey,
+// DefaultSignTimeout is used if timeout is not positive.
+func NewQuorumCryptoClient(threshold int, signers []Signer, timeout time.Duration) (*QuorumCryptoClient, error) {
+	if timeout <= 0 {
+		timeout = DefaultSignTimeout
+	}
+	key := &QuorumKey{Threshold: threshold}
+	for _, s := range signers {
+		key.Keys = append(key.Keys, s.PublicKey())
+	}
+	if err := key.Validate(); err != nil {
+		return nil, err
+	}
+	pk, err := key.Encode()
+	if err != nil {
+		return nil, err
+	}
+	return &QuorumCryptoClient{key: key, pk: pk, signers: signers, timeout: timeout}, nil
+}
+
+// Key returns the QuorumKey of the validator.
+func (qc *QuorumCryptoClient) Key() *QuorumKey {
+	return qc.key
+}
+
+// PublicKey is the encoded QuorumKey.
+func (qc *QuorumCryptoClient) PublicKey() []byte {
+	return qc.pk
+}
+
+// SignBytes collects the shares over the raw bytes, see KeySigner.
+func (qc *QuorumCryptoClient) SignBytes(data []byte) ([]byte, error) {
+	return qc.sign(data)
+}
+
+func (qc *QuorumCryptoClient) Sign(msgBytes []byte) ([]byte, error) {
+	return signMsg(msgBytes, qc.pk, qc.sign)
+}
+
+// sign collects the shares, the ones which don't verify are dropped, so a compromised
+// or faulty signer can only withhold its share.
+func (qc *QuorumCryptoClient) sign(data []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), qc.timeout)
+	defer cancel()
+
+	type result struct {
+		share quorumShare
+		err   error
+	}
+	results := make(chan result, len(qc.signers))
+	for i, s := range qc.signers {
+		go func(i int, s Signer) {
+			sig, err := s.Sign(ctx, data)
+			if err == nil {
+				if ok, _ := verifySignature(data, sig, qc.key.Keys[i]); !ok {
+					err = fmt.Errorf("invalid share of signer %d", i)
+				}
+			}
+			results <- result{share: quorumShare{Index: i, Sig: sig}, err: err}
+		}(i, s)
+	}
+
+	var shares []quorumShare
+	var errs []string
+	for range qc.signers {
+		r := <-results
+		if r.err != nil {
+			errs = append(errs, fmt.Sprintf("signer %d: %v", r.share.Index, r.err))
+			continue
+		}
+		shares = append(shares, r.share)
+		if len(shares) == qc.key.Threshold {
+			return asn1.Marshal(shares)
+		}
+	}
+	return nil, fmt.Errorf("%w, shares: %d, threshold: %d, errs: %v", ErrQuorumNotReached, len(shares), qc.key.Threshold, errs)
+}
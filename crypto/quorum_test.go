@@ -0,0 +1,186 @@
+package crypto
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/golang/protobuf/proto"
+)
+
+// faultySigner is a signer machine which is down or compromised.
+type faultySigner struct {
+	Signer
+	sig []byte
+	err error
+}
+
+func (s *faultySigner) Sign(ctx context.Context, data []byte) ([]byte, error) {
+	return s.sig, s.err
+}
+
+func newLocalSigners(t *testing.T, n int) []Signer {
+	var signers []Signer
+	for i := 0; i < n; i++ {
+		sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signers = append(signers, &LocalSigner{SK: sk})
+	}
+	return signers
+}
+
+func voteBytes(t *testing.T) []byte {
+	msg := &pb.Message{
+		ChainId: "test",
+		Module:  libs.ConsensusModule,
+		Sum: &pb.Message_Vote{
+			Vote: &pb.VoteMessage{Module: libs.ConsensusModule, Pid: []byte("validator")},
+		},
+	}
+	b, err := msg.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestQuorumSignNVerify(t *testing.T) {
+	signers := newLocalSigners(t, 3)
+	junk, _ := signers[0].Sign(context.Background(), []byte("another msg"))
+	// one signer is down and another one replies a share of another msg
+	signers[0] = &faultySigner{Signer: signers[0], sig: junk}
+	signers[2] = &faultySigner{Signer: signers[2], err: errors.New("down")}
+
+	qc, err := NewQuorumCryptoClient(1, signers, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := qc.Sign(voteBytes(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid, err := (&DefaultCryptoClient{}).Verify(nil, nil, signed); err != nil || !valid {
+		t.Fatalf("1-of-3 should verify, valid: %v, err: %v", valid, err)
+	}
+
+	qc, _ = NewQuorumCryptoClient(2, signers, 0)
+	if _, err := qc.Sign(voteBytes(t)); !errors.Is(err, ErrQuorumNotReached) {
+		t.Errorf("want: %v, has: %v", ErrQuorumNotReached, err)
+	}
+}
+
+func TestQuorumVerifyThreshold(t *testing.T) {
+	signers := newLocalSigners(t, 3)
+	qc, err := NewQuorumCryptoClient(2, signers, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := qc.Sign(voteBytes(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid, _ := qc.Verify(nil, nil, signed); !valid {
+		t.Fatal("2-of-3 should verify")
+	}
+
+	var msg pb.Message
+	if err := proto.Unmarshal(signed, &msg); err != nil {
+		t.Fatal(err)
+	}
+	vote := msg.GetVote()
+	var shares []quorumShare
+	if _, err := asn1.Unmarshal(vote.Signature, &shares); err != nil || len(shares) != 2 {
+		t.Fatalf("want 2 shares, has: %d, err: %v", len(shares), err)
+	}
+	forge := func(shares []quorumShare) bool {
+		vote.Signature, _ = asn1.Marshal(shares)
+		b, _ := proto.Marshal(&msg)
+		valid, _ := qc.Verify(nil, nil, b)
+		return valid
+	}
+	// a single compromised signer cannot sign alone, nor by repeating its share
+	if forge(shares[:1]) {
+		t.Error("a single share should not verify")
+	}
+	if forge([]quorumShare{shares[0], shares[0]}) {
+		t.Error("a repeated share should not verify")
+	}
+	if forge([]quorumShare{shares[0], {Index: shares[1].Index, Sig: shares[0].Sig}}) {
+		t.Error("a share under another index should not verify")
+	}
+	if forge([]quorumShare{shares[0], {Index: 3, Sig: shares[1].Sig}}) {
+		t.Error("a share out of the quorum should not verify")
+	}
+}
+
+func TestQuorumKey(t *testing.T) {
+	signers := newLocalSigners(t, 2)
+	for _, threshold := range []int{0, 3} {
+		if _, err := NewQuorumCryptoClient(threshold, signers, 0); !errors.Is(err, ErrInvalidQuorum) {
+			t.Errorf("threshold %d, want: %v, has: %v", threshold, ErrInvalidQuorum, err)
+		}
+	}
+	if _, err := NewQuorumCryptoClient(1, []Signer{signers[0], signers[0]}, 0); !errors.Is(err, ErrInvalidQuorum) {
+		t.Errorf("duplicate signers, want: %v, has: %v", ErrInvalidQuorum, err)
+	}
+
+	qc, _ := NewQuorumCryptoClient(2, signers, 0)
+	pk, err := qc.Key().Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, ok, err := DecodeQuorumKey(pk)
+	if !ok || err != nil || key.Threshold != 2 || len(key.Keys) != 2 {
+		t.Fatalf("decode quorum key fail, key: %+v, ok: %v, err: %v", key, ok, err)
+	}
+	if _, ok, _ := DecodeQuorumKey(signers[0].PublicKey()); ok {
+		t.Error("a P256 key is not a quorum key")
+	}
+}
+
+func TestRemoteSigner(t *testing.T) {
+	local := newLocalSigners(t, 1)[0]
+	srv := httptest.NewServer(NewSignerServer(local, "secret"))
+	defer srv.Close()
+
+	if _, err := NewRemoteSigner(context.Background(), srv.URL, "wrong", nil); err == nil {
+		t.Fatal("the wrong token should be refused")
+	}
+	remote, err := NewRemoteSigner(context.Background(), srv.URL, "secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(remote.PublicKey()) != string(local.PublicKey()) {
+		t.Fatal("public key mismatch")
+	}
+	data := SignBytes("test", 0, DomainVote, []byte("vote"))
+	sig, err := remote.Sign(context.Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid, _ := verifySignature(data, sig, local.PublicKey()); !valid {
+		t.Error("the remote share should verify")
+	}
+	if _, err := remote.Sign(context.Background(), []byte("arbitrary data")); err == nil {
+		t.Error("the data out of the signing domains should be refused")
+	}
+
+	resp, err := http.Get(srv.URL + signerPathPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("want: %d, has: %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+}
@@ -0,0 +1,165 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// the endpoints of a signer machine, see SignerServer
+const (
+	signerPathSign      = "/sign"
+	signerPathPublicKey = "/public_key"
+
+	// maxSignRequestBytes bounds the bytes of a sign request, the proposals of full blocks included.
+	maxSignRequestBytes = 8 << 20
+)
+
+var (
+	ErrSignerUnauthorized = errors.New("signer request unauthorized")
+	ErrSignerDomain       = errors.New("data is out of the signing domains")
+)
+
+// signRequest and signResponse are the json bodies of /sign, bytes are base64 encoded.
+type signRequest struct {
+	Data []byte `json:"data"`
+}
+
+type signResponse struct {
+	Signature []byte `json:"signature,omitempty"`
+	PublicKey []byte `json:"public_key,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// RemoteSigner requests the signature shares from a signer machine over http(s).
+type RemoteSigner struct {
+	url    string
+	token  string
+	client *http.Client
+	pk     []byte
+}
+
+// NewRemoteSigner fetches the public key of the signer at url, token authorizes the requests if it's set.
+func NewRemoteSigner(ctx context.Context, url, token string, client *http.Client) (*RemoteSigner, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	s := &RemoteSigner{url: strings.TrimSuffix(url, "/"), token: token, client: client}
+	resp, err := s.do(ctx, http.MethodGet, signerPathPublicKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch public key fail @ crypto.NewRemoteSigner, url: %s, err: %w", url, err)
+	}
+	s.pk = resp.PublicKey
+	return s, nil
+}
+
+func (s *RemoteSigner) Sign(ctx context.Context, data []byte) ([]byte, error) {
+	body, err := json.Marshal(signRequest{Data: data})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodPost, signerPathSign, body)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Signature, nil
+}
+
+func (s *RemoteSigner) PublicKey() []byte {
+	return s.pk
+}
+
+func (s *RemoteSigner) do(ctx context.Context, method, path string, body []byte) (*signResponse, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, s.url+path, r)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	httpResp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	var resp signResponse
+	if err := json.NewDecoder(io.LimitReader(httpResp.Body, maxSignRequestBytes)).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("decode signer response fail, status: %d, err: %v", httpResp.StatusCode, err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("signer replies %d: %s", httpResp.StatusCode, resp.Error)
+	}
+	return &resp, nil
+}
+
+// SignerServer serves the signer of a signer machine to the validator, it only signs the domain
+// separated bytes of the consensus msgs, see SignBytes.
+type SignerServer struct {
+	signer Signer
+	token  string
+}
+
+// NewSignerServer returns the handler of the signer, the requests must carry the token if it's set.
+func NewSignerServer(signer Signer, token string) *SignerServer {
+	return &SignerServer{signer: signer, token: token}
+}
+
+func (s *SignerServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		s.reply(w, http.StatusUnauthorized, signResponse{Error: ErrSignerUnauthorized.Error()})
+		return
+	}
+	switch {
+	case r.URL.Path == signerPathPublicKey && r.Method == http.MethodGet:
+		s.reply(w, http.StatusOK, signResponse{PublicKey: s.signer.PublicKey()})
+	case r.URL.Path == signerPathSign && r.Method == http.MethodPost:
+		raw, err := ioutil.ReadAll(io.LimitReader(r.Body, maxSignRequestBytes))
+		if err != nil {
+			s.reply(w, http.StatusBadRequest, signResponse{Error: err.Error()})
+			return
+		}
+		var req signRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			s.reply(w, http.StatusBadRequest, signResponse{Error: err.Error()})
+			return
+		}
+		if !bytes.HasPrefix(req.Data, []byte(domainPrefix)) {
+			s.reply(w, http.StatusForbidden, signResponse{Error: ErrSignerDomain.Error()})
+			return
+		}
+		sig, err := s.signer.Sign(r.Context(), req.Data)
+		if err != nil {
+			s.reply(w, http.StatusInternalServerError, signResponse{Error: err.Error()})
+			return
+		}
+		s.reply(w, http.StatusOK, signResponse{Signature: sig})
+	default:
+		s.reply(w, http.StatusNotFound, signResponse{Error: "unknown endpoint " + r.Method + " " + r.URL.Path})
+	}
+}
+
+func (s *SignerServer) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return true
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(s.token), []byte(token)) == 1
+}
+
+func (s *SignerServer) reply(w http.ResponseWriter, status int, resp signResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
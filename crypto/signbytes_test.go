@@ -0,0 +1,113 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
+	"github.com/golang/protobuf/proto"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the current sign bytes")
+
+const signBytesGolden = "testdata/signbytes.golden"
+
+// signBytesCases are the bytes signed for every domain, a drift of any of them invalidates the signatures
+// of the peers running the older binaries, i.e. forks the network.
+func signBytesCases(t *testing.T) []struct {
+	name string
+	data []byte
+} {
+	pk := []byte("public key")
+	captured := func(msg *pb.Message) []byte {
+		msg.ChainId, msg.ForkId, msg.Epoch = "golden", "fork", 7
+		msgBytes, err := proto.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data []byte
+		if _, err := signMsg(msgBytes, pk, func(b []byte) ([]byte, error) {
+			data = b
+			return []byte("signature"), nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	return []struct {
+		name string
+		data []byte
+	}{
+		{"sign_bytes", SignBytes("golden", 7, DomainVote, []byte("payload"))},
+		{"fork_sign_bytes", ForkSignBytes("golden", "fork", 7, DomainVote, []byte("payload"))},
+		{"possession", PossessionBytes("validator", pk)},
+		{"key_rotation", RotationBytes("validator", pk)},
+		{"join", JoinBytes("golden", "validator", pk, "/ip4/127.0.0.1/tcp/26656", 1600000000000)},
+		{"proposal", captured(&pb.Message{Sum: &pb.Message_Proposal{Proposal: &pb.ProposalMessage{
+			Round: 10, Id: []byte("block"), Timestamp: 1600000000, Pid: []byte("proposer"), Justify: []byte("qc"),
+			Txs: [][]byte{[]byte("tx1"), []byte("tx2")}, VoteExtensions: [][]byte{[]byte("ext")},
+		}}})},
+		{"vote", captured(&pb.Message{Sum: &pb.Message_Vote{Vote: &pb.VoteMessage{
+			VoteInfo:   &pb.VoteInfo{ProposalRound: 10, ProposalId: []byte("block"), ParentRound: 9, ParentId: []byte("parent")},
+			CommitInfo: []byte("commit"), Timestamp: 1600000000, Pid: []byte("voter"), Extension: []byte("ext"),
+		}}})},
+		{"timeout", captured(&pb.Message{Sum: &pb.Message_Timeout{Timeout: &pb.TimoutMessage{
+			Round: 11, ParentRound: 9, ParentId: []byte("parent"), Index: 2, Timestamp: 1600000000,
+			Pid: []byte("voter"), HighQc: []byte("qc"),
+		}}})},
+		{"status", captured(&pb.Message{Sum: &pb.Message_Status{Status: &pb.StatusMessage{
+			CommittedRound: 8, CurrentRound: 11, HighQcRound: 10, Timestamp: 1600000000, Pid: []byte("peer"),
+		}}})},
+		{"proposal_request", captured(&pb.Message{Sum: &pb.Message_ProposalRequest{ProposalRequest: &pb.ProposalRequestMessage{
+			Round: 10, Id: []byte("block"), Timestamp: 1600000000, Pid: []byte("peer"),
+		}}})},
+	}
+}
+
+// TestSignBytesGolden fails when the bytes signed for a msg drift, e.g. by a renamed field of the msg,
+// an intended change must come with a fork, see ForkSignBytes, before the golden file is rewritten by:
+// go test ./crypto -run TestSignBytesGolden -update
+func TestSignBytesGolden(t *testing.T) {
+	var buf bytes.Buffer
+	for _, c := range signBytesCases(t) {
+		fmt.Fprintf(&buf, "%s %s\n", c.name, hex.EncodeToString(c.data))
+	}
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(signBytesGolden), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(signBytesGolden, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	data, err := os.ReadFile(signBytesGolden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			golden[fields[0]] = fields[1]
+		}
+	}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		fields := strings.Fields(line)
+		want, ok := golden[fields[0]]
+		if !ok {
+			t.Errorf("no golden sign bytes of %s, add them by -update", fields[0])
+			continue
+		}
+		if want != fields[1] {
+			t.Errorf("sign bytes of %s drift\nwant: %s\nhas:  %s", fields[0], want, fields[1])
+		}
+	}
+}
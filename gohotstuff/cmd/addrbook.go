@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/p2p"
+	"github.com/aucusaga/gohotstuff/rpc"
+	"github.com/spf13/cobra"
+)
+
+type AddrBookCmd struct {
+	Cmd *cobra.Command
+}
+
+func GetAddrBookCmd() *AddrBookCmd {
+	cmd := new(AddrBookCmd)
+	cmd.Cmd = &cobra.Command{
+		Use:           "addrbook",
+		Short:         "export and import the address book and the known peers, see `addrbookpath` in conf.yaml.",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	var exportRPC, exportToken, output string
+	export := &cobra.Command{
+		Use:           "export",
+		Short:         "export the address book of a running node through its rpc.",
+		Example:       "gohotstuff addrbook export --rpc http://127.0.0.1:26657 --token $TOKEN -o ./addrbook.json",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return ExportAddrBook(exportRPC, exportToken, output)
+		},
+	}
+	export.Flags().StringVar(&exportRPC, "rpc", "http://127.0.0.1:26657", "rpc address of the node")
+	export.Flags().StringVar(&exportToken, "token", "", "api token of the operator role at least")
+	export.Flags().StringVarP(&output, "output", "o", "", "output file, stdout by default")
+
+	var importRPC, importToken, envCfgPath string
+	imp := &cobra.Command{
+		Use:   "import <file>",
+		Short: "import an exported address book into a running node through its rpc with --rpc, or else into the addrbookpath of the config, which is dialed on the next start.",
+		Example: "gohotstuff addrbook import --conf /home/rd/gohotstuff/conf ./addrbook.json\n" +
+			"gohotstuff addrbook import --rpc http://127.0.0.1:26657 --token $TOKEN ./addrbook.json",
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if importRPC != "" {
+				return ImportAddrBookLive(importRPC, importToken, args[0])
+			}
+			return ImportAddrBook(envCfgPath, args[0])
+		},
+	}
+	imp.Flags().StringVar(&importRPC, "rpc", "", "rpc address of the running node, empty imports into the config")
+	imp.Flags().StringVar(&importToken, "token", "", "api token of the admin role")
+	imp.Flags().StringVarP(&envCfgPath, "conf", "c", "", "engine environment config file path")
+
+	cmd.Cmd.AddCommand(export, imp)
+	return cmd
+}
+
+// ExportAddrBook writes the address book served by /addr_book_export to the output.
+func ExportAddrBook(rpcAddr, token, output string) error {
+	var e p2p.AddrBookExport
+	if err := callRPC(http.MethodGet, rpcAddr, "/addr_book_export", token, nil, &e); err != nil {
+		return err
+	}
+	if output != "" {
+		if err := p2p.WriteAddrBook(output, &e); err != nil {
+			return err
+		}
+	} else {
+		data, err := json.MarshalIndent(&e, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	}
+	fmt.Fprintf(os.Stderr, "%d known addresses and %d peers exported\n", len(e.Known), len(e.Peers))
+	return nil
+}
+
+// ImportAddrBookLive posts the exported address book to /unsafe_import_addr_book of the running node.
+func ImportAddrBookLive(rpcAddr, token, path string) error {
+	e, err := p2p.ReadAddrBook(path)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	var res struct {
+		Known int64 `json:"known"`
+		Peers int64 `json:"peers"`
+		Dials int64 `json:"dials"`
+	}
+	if err := callRPC(http.MethodPost, rpcAddr, "/unsafe_import_addr_book", token, body, &res); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d known addresses and %d peers imported, %d dials queued\n", res.Known, res.Peers, res.Dials)
+	return nil
+}
+
+// ImportAddrBook copies the exported address book to the addrbookpath of the config,
+// which the node imports and dials on its next start.
+func ImportAddrBook(envCfgPath, path string) error {
+	if len(envCfgPath) <= 0 {
+		envCfgPath = filepath.Join(libs.GetCurRootDir(), "conf/conf.yaml")
+	} else {
+		libs.SetRootDir(envCfgPath)
+		envCfgPath = filepath.Join(envCfgPath, "conf.yaml")
+	}
+	cfg, err := libs.GetConfig(envCfgPath)
+	if err != nil {
+		return fmt.Errorf("load configuration failed, err: %v", err)
+	}
+	if cfg.Addrbookpath == "" {
+		return fmt.Errorf("address book is not persisted, set addrbookpath")
+	}
+	e, err := p2p.ReadAddrBook(path)
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(libs.GetCurRootDir(), cfg.Addrbookpath)
+	if err := p2p.WriteAddrBook(dst, e); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d known addresses and %d peers imported into %s, they are dialed on the next start\n",
+		len(e.Known), len(e.Peers), dst)
+	return nil
+}
+
+// callRPC invokes the endpoint of the node and decodes the result of the json envelope into res.
+func callRPC(method, rpcAddr, path, token string, body []byte, res interface{}) error {
+	req, err := http.NewRequest(method, strings.TrimSuffix(rpcAddr, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	envelope := rpc.Response{Result: res}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("decode reply fail, status: %s, err: %v", resp.Status, err)
+	}
+	if envelope.Error != "" {
+		return fmt.Errorf("rpc fail, status: %s, err: %s", resp.Status, envelope.Error)
+	}
+	return nil
+}
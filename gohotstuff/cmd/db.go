@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/aucusaga/gohotstuff/state"
+	"github.com/spf13/cobra"
+)
+
+type DBCmd struct {
+	Cmd *cobra.Command
+}
+
+func GetDBCmd() *DBCmd {
+	cmd := new(DBCmd)
+	cmd.Cmd = &cobra.Command{
+		Use:           "db",
+		Short:         "inspect the stores of the node, see `blockspath` in conf.yaml.",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	var (
+		envCfgPath string
+		chainID    string
+		from, to   int64
+	)
+	verify := &cobra.Command{
+		Use:           "verify",
+		Short:         "check the checksums and the QCs of all the stored blocks, the corrupted ones are printed.",
+		Example:       "gohotstuff db verify --conf /home/rd/gohotstuff/conf --from 100 --to 200",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return VerifyDB(envCfgPath, chainID, from, to)
+		},
+	}
+	verify.Flags().StringVarP(&envCfgPath, "conf", "c", "", "engine environment config file path")
+	verify.Flags().StringVar(&chainID, "chain", "", "chain id, the main chain by default")
+	verify.Flags().Int64Var(&from, "from", 0, "the first height")
+	verify.Flags().Int64Var(&to, "to", 0, "the last height, 0 means the latest one")
+	cmd.Cmd.AddCommand(verify)
+
+	return cmd
+}
+
+// corruptBlock is a line printed by VerifyDB.
+type corruptBlock struct {
+	Height int64  `json:"height"`
+	Error  string `json:"error"`
+}
+
+// VerifyDB scrubs the stored blocks of the chain in [from, to] like the scrubber of a running node,
+// unlike verify-chain it goes on past the corrupted blocks, which can be fetched again by /unsafe_resync_from_height.
+func VerifyDB(envCfgPath, chainID string, from, to int64) error {
+	c, err := loadChainConfig(envCfgPath, chainID)
+	if err != nil {
+		return err
+	}
+	blocks, _, err := openChainStores(envCfgPath, chainID)
+	if err != nil {
+		return err
+	}
+	var validators []state.PeerID
+	for _, v := range c.Validators {
+		validators = append(validators, state.PeerID(v))
+	}
+	a := &state.Auditor{
+		Blocks:     blocks,
+		Election:   state.NewDefaultElection(int64(c.Round), validators),
+		StartRound: int64(c.Round),
+		ChainID:    c.Chainid,
+		ForkID:     c.Forkid,
+	}
+	enc := json.NewEncoder(os.Stdout)
+	corrupted := 0
+	n, err := a.Scrub(from, to, func(height int64, err error) {
+		corrupted++
+		enc.Encode(corruptBlock{Height: height, Error: err.Error()})
+	})
+	if err != nil {
+		return err
+	}
+	if corrupted > 0 {
+		return fmt.Errorf("%d of %d blocks are corrupted", corrupted, n)
+	}
+	fmt.Fprintf(os.Stderr, "%d blocks verified\n", n)
+	return nil
+}
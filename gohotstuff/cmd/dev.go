@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/aucusaga/gohotstuff/crypto"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/node"
+	"github.com/aucusaga/gohotstuff/p2p"
+	"github.com/aucusaga/gohotstuff/state"
+	"github.com/spf13/cobra"
+)
+
+const (
+	devChainID = "dev"
+	devStartK  = "lets_run_hotstuff"
+	devStartV  = `{"round":0,"id":"bGV0c19ydW5faG90c3R1ZmY=","sender":"","signs":{}}`
+)
+
+type DevCmd struct {
+	Cmd *cobra.Command
+}
+
+func GetDevCmd() *DevCmd {
+	cmd := new(DevCmd)
+	var rpcAddress, address string
+	var blockInterval int
+
+	cmd.Cmd = &cobra.Command{
+		Use:           "dev",
+		Short:         "Run a single-validator dev chain with a no-op executor, all data is dropped on exit.",
+		Example:       "gohotstuff dev --rpc 127.0.0.1:26657",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunDevChain(rpcAddress, address, blockInterval)
+		},
+	}
+
+	cmd.Cmd.Flags().StringVarP(&rpcAddress, "rpc", "r", "127.0.0.1:26657", "rpc listen address")
+	cmd.Cmd.Flags().StringVarP(&address, "address", "a", "/ip4/127.0.0.1/tcp/0", "p2p listen multiaddr")
+	cmd.Cmd.Flags().IntVarP(&blockInterval, "blockinterval", "b", 100, "min interval between blocks in milliseconds")
+
+	return cmd
+}
+
+// RunDevChain generates the keys into a temporary root dir, and runs the host as the only validator,
+// the chain commits every block as soon as it's proposed since the host is the quorum by itself.
+func RunDevChain(rpcAddress, address string, blockInterval int) error {
+	root, err := os.MkdirTemp("", "gohotstuff-dev-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(root)
+
+	cfg, err := DevConfig(root, rpcAddress, address, blockInterval)
+	if err != nil {
+		return err
+	}
+	libs.SetRootDir(root)
+	n, err := node.NewNode(cfg)
+	if err != nil {
+		return fmt.Errorf("new a dev node failed, err: %v", err)
+	}
+	smr, _, _ := n.Chain(devChainID)
+	if err := smr.SetExecutor(state.NopExecutor{}); err != nil {
+		return err
+	}
+	n.Start()
+	fmt.Printf("dev chain is running, validator: %s, rpc: %s, root: %s\n", cfg.Host, rpcAddress, root)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	var fatal error
+	select {
+	case <-sigs:
+	case e := <-n.Fatal():
+		fatal = fmt.Errorf("node halted by a fatal failure, module: %s, data: %+v", e.Module, e.Data)
+	}
+	n.Stop()
+	n.Wait()
+	return fatal
+}
+
+// DevConfig generates the network and crypto keys under root/conf,
+// and returns the config of the single-validator chain.
+func DevConfig(root, rpcAddress, address string, blockInterval int) (*libs.Config, error) {
+	netPath := filepath.Join(root, "conf", "netkeys")
+	keyPath := filepath.Join(root, "conf", "keys")
+	for _, dir := range []string{netPath, keyPath} {
+		if err := libs.MakeDir(dir); err != nil {
+			return nil, err
+		}
+	}
+	if err := p2p.GenerateKeyPairWithPath(netPath); err != nil {
+		return nil, fmt.Errorf("gen network key fail, err: %v", err)
+	}
+	if err := crypto.GenKeyPair(keyPath); err != nil {
+		return nil, fmt.Errorf("gen crypto key fail, err: %v", err)
+	}
+	host, err := p2p.GetPeerIDFromPath(netPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &libs.Config{
+		Host:             host,
+		Module:           "gohotstuff",
+		Filename:         "gohotstuff",
+		Address:          address,
+		Netpath:          "./netkeys",
+		Keypath:          "./keys",
+		Chainid:          devChainID,
+		Resultspath:      "./data/results",
+		Rpcaddress:       rpcAddress,
+		Minblockinterval: blockInterval,
+		Instantfinality:  true,
+		Round:            0,
+		Startk:           devStartK,
+		Startv:           devStartV,
+		Validators:       []string{host},
+	}, nil
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/storage"
+	"github.com/spf13/cobra"
+)
+
+type ExportCmd struct {
+	Cmd *cobra.Command
+}
+
+func GetExportCmd() *ExportCmd {
+	cmd := new(ExportCmd)
+	var (
+		envCfgPath string
+		chainID    string
+		from, to   int64
+		format     string
+		output     string
+	)
+
+	cmd.Cmd = &cobra.Command{
+		Use:           "export",
+		Short:         "export the committed blocks, QCs and results, see `blockspath` in conf.yaml.",
+		Example:       "gohotstuff export --conf /home/rd/gohotstuff/conf --from 100 --to 200 --format csv",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var w io.Writer = os.Stdout
+			if output != "" {
+				f, err := os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+			return ExportChain(envCfgPath, chainID, from, to, format, w)
+		},
+	}
+
+	cmd.Cmd.Flags().StringVarP(&envCfgPath, "conf", "c", "", "engine environment config file path")
+	cmd.Cmd.Flags().StringVar(&chainID, "chain", "", "chain id, the main chain by default")
+	cmd.Cmd.Flags().Int64Var(&from, "from", 0, "the first height")
+	cmd.Cmd.Flags().Int64Var(&to, "to", 0, "the last height, 0 means the latest one")
+	cmd.Cmd.Flags().StringVarP(&format, "format", "f", storage.ExportJSON, "output format: json (a block per line) | csv | car")
+	cmd.Cmd.Flags().StringVarP(&output, "output", "o", "", "output file, stdout by default")
+
+	return cmd
+}
+
+// ExportChain streams the committed blocks of the chain in [from, to] to w.
+func ExportChain(envCfgPath, chainID string, from, to int64, format string, w io.Writer) error {
+	blocks, results, err := openChainStores(envCfgPath, chainID)
+	if err != nil {
+		return err
+	}
+	n, err := storage.Export(blocks, results, from, to, format, w)
+	if err != nil {
+		return fmt.Errorf("export fail after %d blocks, err: %v", n, err)
+	}
+	fmt.Fprintf(os.Stderr, "%d blocks exported\n", n)
+	return nil
+}
+
+// loadChainConfig returns the config of the chain, the stores and the genesis of the main chain
+// are the top level ones.
+func loadChainConfig(envCfgPath, chainID string) (*libs.ChainConfig, error) {
+	if len(envCfgPath) <= 0 {
+		envCfgPath = filepath.Join(libs.GetCurRootDir(), "conf/conf.yaml")
+	} else {
+		libs.SetRootDir(envCfgPath)
+		envCfgPath = filepath.Join(envCfgPath, "conf.yaml")
+	}
+	cfg, err := libs.GetConfig(envCfgPath)
+	if err != nil {
+		return nil, fmt.Errorf("load configuration failed, err: %v", err)
+	}
+
+	if chainID == cfg.Chainid {
+		return &libs.ChainConfig{
+			Chainid:     cfg.Chainid,
+			Forkid:      cfg.Forkid,
+			Blockspath:  cfg.Blockspath,
+			Resultspath: cfg.Resultspath,
+			Round:       cfg.Round,
+			Validators:  cfg.Validators,
+		}, nil
+	}
+	for i := range cfg.Chains {
+		if cfg.Chains[i].Chainid == chainID {
+			return &cfg.Chains[i], nil
+		}
+	}
+	return nil, fmt.Errorf("chain not found in the configuration, chain: %s", chainID)
+}
+
+// openChainStores opens the block store and the result store, if it's configured, of the chain.
+func openChainStores(envCfgPath, chainID string) (storage.BlockStorage, storage.ResultStorage, error) {
+	c, err := loadChainConfig(envCfgPath, chainID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.Blockspath == "" {
+		return nil, nil, fmt.Errorf("committed blocks are not persisted, set blockspath, chain: %s", chainID)
+	}
+	blocks, err := storage.NewBlockStore(filepath.Join(libs.GetCurRootDir(), c.Blockspath))
+	if err != nil {
+		return nil, nil, err
+	}
+	// a nil store must not be wrapped into a non-nil interface
+	var results storage.ResultStorage
+	if c.Resultspath != "" {
+		rs, err := storage.NewResultStore(filepath.Join(libs.GetCurRootDir(), c.Resultspath))
+		if err != nil {
+			return nil, nil, err
+		}
+		results = rs
+	}
+	return blocks, results, nil
+}
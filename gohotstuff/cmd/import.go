@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aucusaga/gohotstuff/state"
+	"github.com/aucusaga/gohotstuff/storage"
+	"github.com/spf13/cobra"
+)
+
+type ImportCmd struct {
+	Cmd *cobra.Command
+}
+
+func GetImportCmd() *ImportCmd {
+	cmd := new(ImportCmd)
+	var (
+		envCfgPath string
+		chainID    string
+		format     string
+		input      string
+	)
+
+	cmd.Cmd = &cobra.Command{
+		Use:           "import",
+		Short:         "import the blocks exported by another node, their QC chain is verified on ingest.",
+		Example:       "gohotstuff import --conf /home/rd/gohotstuff/conf --format car --input ./blocks.car",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var r io.Reader = os.Stdin
+			if input != "" {
+				f, err := os.Open(input)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				r = f
+			}
+			return ImportChain(envCfgPath, chainID, format, r)
+		},
+	}
+
+	cmd.Cmd.Flags().StringVarP(&envCfgPath, "conf", "c", "", "engine environment config file path")
+	cmd.Cmd.Flags().StringVar(&chainID, "chain", "", "chain id, the main chain by default")
+	cmd.Cmd.Flags().StringVarP(&format, "format", "f", storage.ExportJSON, "input format: json | car")
+	cmd.Cmd.Flags().StringVarP(&input, "input", "i", "", "input file, stdin by default")
+
+	return cmd
+}
+
+// ImportChain verifies the exported blocks read from r and populates the stores of the chain,
+// the exported results are kept, the application state is populated by the embedding application
+// through state.Importer with its executor. The validator set is reconstructed from the genesis
+// validators of the configuration like VerifyChain.
+func ImportChain(envCfgPath, chainID, format string, r io.Reader) error {
+	c, err := loadChainConfig(envCfgPath, chainID)
+	if err != nil {
+		return err
+	}
+	blocks, results, err := openChainStores(envCfgPath, chainID)
+	if err != nil {
+		return err
+	}
+	br, err := storage.NewBlockReader(r, format)
+	if err != nil {
+		return err
+	}
+	var validators []state.PeerID
+	for _, v := range c.Validators {
+		validators = append(validators, state.PeerID(v))
+	}
+	im := &state.Importer{
+		Blocks:     blocks,
+		Results:    results,
+		Election:   state.NewDefaultElection(int64(c.Round), validators),
+		StartRound: int64(c.Round),
+		ChainID:    c.Chainid,
+		ForkID:     c.Forkid,
+	}
+	n, err := im.Import(br)
+	if err != nil {
+		return fmt.Errorf("import fail after %d blocks, err: %v", n, err)
+	}
+	fmt.Fprintf(os.Stderr, "%d blocks imported\n", n)
+	return nil
+}
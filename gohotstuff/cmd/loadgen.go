@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/rpc/loadgen"
+	"github.com/spf13/cobra"
+)
+
+type LoadGenCmd struct {
+	Cmd *cobra.Command
+}
+
+func GetLoadGenCmd() *LoadGenCmd {
+	cmd := new(LoadGenCmd)
+	var (
+		cfg          loadgen.Config
+		size         string
+		asJSON       bool
+		duration     time.Duration
+		commitWait   time.Duration
+		pollInterval time.Duration
+	)
+
+	cmd.Cmd = &cobra.Command{
+		Use:           "loadgen",
+		Short:         "submit synthetic txs to a running network and report the commit throughput, the latency percentiles and the dropped txs.",
+		Example:       "gohotstuff loadgen --rpc http://127.0.0.1:26657 --token $TOKEN --rate 500 --duration 5m --size 128-1024",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dist, err := loadgen.ParseSizeDist(size)
+			if err != nil {
+				return err
+			}
+			cfg.Size, cfg.Duration, cfg.CommitWait, cfg.PollInterval = dist, duration, commitWait, pollInterval
+			return RunLoadGen(cfg, asJSON)
+		},
+	}
+
+	cmd.Cmd.Flags().StringVar(&cfg.RPC, "rpc", "http://127.0.0.1:26657", "rpc address of the node")
+	cmd.Cmd.Flags().StringVar(&cfg.Token, "token", "", "api token of the operator role at least")
+	cmd.Cmd.Flags().StringVar(&cfg.Chain, "chain", "", "chain id, see `chainid` in conf.yaml")
+	cmd.Cmd.Flags().Float64Var(&cfg.Rate, "rate", 100, "txs submitted per second")
+	cmd.Cmd.Flags().DurationVar(&duration, "duration", time.Minute, "how long the txs are submitted")
+	cmd.Cmd.Flags().StringVar(&size, "size", "256", "tx size in bytes: 256 is fixed, 128-1024 is uniform, exp:512 is exponential of the mean")
+	cmd.Cmd.Flags().IntVar(&cfg.Concurrency, "concurrency", loadgen.DefaultConcurrency, "in-flight submissions, the ticks finding them all busy are skipped")
+	cmd.Cmd.Flags().DurationVar(&commitWait, "commit-wait", loadgen.DefaultCommitWait, "how long the txs are waited for after the last submission before they are dropped")
+	cmd.Cmd.Flags().DurationVar(&pollInterval, "poll", loadgen.DefaultPollInterval, "interval of polling the committed blocks")
+	cmd.Cmd.Flags().BoolVar(&asJSON, "json", false, "print the report as json")
+
+	return cmd
+}
+
+// RunLoadGen runs the load generator until the duration elapses or it's interrupted and prints the report,
+// the txs submitted by then are still waited for.
+func RunLoadGen(cfg loadgen.Config, asJSON bool) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	go func() {
+		select {
+		case <-sigs:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	fmt.Fprintf(os.Stderr, "submitting %.1f tx/s of %s bytes to %s for %v\n", cfg.Rate, cfg.Size, cfg.RPC, cfg.Duration)
+	report, err := loadgen.Run(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return json.NewEncoder(os.Stdout).Encode(report)
+	}
+	fmt.Println(report)
+	return nil
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
+	"github.com/aucusaga/gohotstuff/p2p/tap"
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/cobra"
+)
+
+type MsgsCmd struct {
+	Cmd *cobra.Command
+}
+
+func GetMsgsCmd() *MsgsCmd {
+	cmd := new(MsgsCmd)
+	cmd.Cmd = &cobra.Command{
+		Use:           "msgs",
+		Short:         "inspect the msgs captured by the tap, see `tappath` in conf.yaml.",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	var raw bool
+	dump := &cobra.Command{
+		Use:           "dump <file>",
+		Short:         "pretty-print the msgs of a tap file.",
+		Example:       "gohotstuff msgs dump ./data/tap",
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return DumpMsgs(args[0], raw, os.Stdout)
+		},
+	}
+	dump.Flags().BoolVarP(&raw, "raw", "r", false, "print the msg bytes in hex as well")
+	cmd.Cmd.AddCommand(dump)
+
+	return cmd
+}
+
+// DumpMsgs writes a line for every record of the tap file.
+func DumpMsgs(path string, raw bool, w io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	rd, err := tap.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("open tap file fail, path: %s, err: %v", path, err)
+	}
+
+	for {
+		rec, err := rd.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read tap file fail, path: %s, err: %v", path, err)
+		}
+		fmt.Fprintf(w, "%s %-3s peer: %s, channel: %d, size: %d, %s\n",
+			rec.Time.Format(time.RFC3339Nano), rec.Direction, rec.Peer, rec.Channel, len(rec.Msg), describeMsg(rec.Msg))
+		if raw {
+			fmt.Fprintf(w, "\t%s\n", hex.EncodeToString(rec.Msg))
+		}
+	}
+}
+
+func describeMsg(msg []byte) string {
+	var m pb.Message
+	if err := proto.Unmarshal(msg, &m); err != nil {
+		return fmt.Sprintf("undecodable msg: %v", err)
+	}
+	switch sum := m.Sum.(type) {
+	case *pb.Message_Proposal:
+		p := sum.Proposal
+		return fmt.Sprintf("module: %s, proposal{round: %d, id: %x, txs: %d, from: %s}",
+			m.Module, p.Round, p.Id, len(p.Txs), p.Pid)
+	case *pb.Message_Vote:
+		v := sum.Vote
+		return fmt.Sprintf("module: %s, vote{round: %d, id: %x, from: %s}",
+			m.Module, v.GetVoteInfo().GetProposalRound(), v.GetVoteInfo().GetProposalId(), v.Pid)
+	case *pb.Message_Timeout:
+		t := sum.Timeout
+		return fmt.Sprintf("module: %s, timeout{round: %d, index: %d, high_qc_round: %d, from: %s}",
+			m.Module, t.Round, t.Index, t.ParentRound, t.Pid)
+	case *pb.Message_Status:
+		st := sum.Status
+		return fmt.Sprintf("module: %s, status{round: %d, high_qc_round: %d, committed_round: %d, from: %s}",
+			m.Module, st.CurrentRound, st.HighQcRound, st.CommittedRound, st.Pid)
+	case *pb.Message_ProposalRequest:
+		r := sum.ProposalRequest
+		return fmt.Sprintf("module: %s, proposal_request{round: %d, id: %x, from: %s}",
+			m.Module, r.Round, r.Id, r.Pid)
+	}
+	return fmt.Sprintf("module: %s, unknown msg", m.Module)
+}
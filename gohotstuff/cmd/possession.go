@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aucusaga/gohotstuff/crypto"
+	"github.com/aucusaga/gohotstuff/p2p"
+	"github.com/aucusaga/gohotstuff/state"
+	"github.com/spf13/cobra"
+)
+
+type PossessionCmd struct {
+	Cmd *cobra.Command
+}
+
+func GetPossessionCmd() *PossessionCmd {
+	cmd := new(PossessionCmd)
+	var keyPath, oldKeyPath string
+
+	cmd.Cmd = &cobra.Command{
+		Use:           "possession",
+		Short:         "prove the possession of the consensus key, print the `validatorkeys` entry and the key registration tx.",
+		Example:       "gohotstuff possession --keypath ./conf/keys --oldkeypath ./conf/oldkeys",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return ProvePossession(keyPath, oldKeyPath)
+		},
+	}
+
+	cmd.Cmd.Flags().StringVar(&keyPath, "keypath", "./conf/keys", "dir of the private.key to register, see `genkey --type crypto`")
+	cmd.Cmd.Flags().StringVar(&oldKeyPath, "oldkeypath", "", "dir of the private.key registered now, which approves the rotation")
+
+	return cmd
+}
+
+// ProvePossession prints the proof of the key for the validator of the network key,
+// the quorum keys of the remote signers are proved by the node on start, see `signers` in conf.yaml.
+func ProvePossession(keyPath, oldKeyPath string) error {
+	cc, err := loadCryptoClient(keyPath)
+	if err != nil {
+		return err
+	}
+	id, err := p2p.GetPeerIDFromPath(KeyDirReady(NetworkName))
+	if err != nil {
+		return err
+	}
+	pk, proof, err := crypto.ProvePossession(cc, id)
+	if err != nil {
+		return err
+	}
+	r := state.KeyRegistration{Key: state.ValidatorKey{ID: state.PeerID(id), PublicKey: pk, Proof: proof}}
+	if oldKeyPath != "" {
+		old, err := loadCryptoClient(oldKeyPath)
+		if err != nil {
+			return err
+		}
+		if r.Approval, err = crypto.ApproveRotation(old, id, pk); err != nil {
+			return err
+		}
+	}
+	tx, err := state.EncodeKeyRegistration(r)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("validatorkeys:\n  - id: %q\n    pubkey: %q\n    proof: %q\n", id, hex.EncodeToString(pk), hex.EncodeToString(proof))
+	fmt.Printf("tx: %s\n", hex.EncodeToString(tx))
+	return nil
+}
+
+func loadCryptoClient(keyPath string) (crypto.CryptoClient, error) {
+	raw, err := os.ReadFile(filepath.Join(keyPath, "private.key"))
+	if err != nil {
+		return nil, err
+	}
+	sk, err := crypto.ParsePrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key fail, err: %v", err)
+	}
+	return &crypto.DefaultCryptoClient{SK: sk, PK: &sk.PublicKey}, nil
+}
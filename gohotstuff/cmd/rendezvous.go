@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/aucusaga/gohotstuff/p2p/rendezvous"
+	"github.com/spf13/cobra"
+)
+
+type RendezvousCmd struct {
+	Cmd *cobra.Command
+}
+
+func GetRendezvousCmd() *RendezvousCmd {
+	cmd := new(RendezvousCmd)
+	var (
+		listen, token   string
+		tlsCert, tlsKey string
+	)
+
+	cmd.Cmd = &cobra.Command{
+		Use:           "rendezvous",
+		Short:         "serve the registry where the nodes discover the peers of their chains instead of the dht, see `rendezvous` in conf.yaml.",
+		Example:       "gohotstuff rendezvous --listen 0.0.0.0:26670 --token $RENDEZVOUS_TOKEN",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunRendezvous(listen, token, tlsCert, tlsKey)
+		},
+	}
+
+	cmd.Cmd.Flags().StringVar(&listen, "listen", "127.0.0.1:26670", "listen address")
+	cmd.Cmd.Flags().StringVar(&token, "token", "", "bearer token required from the nodes, empty disables auth")
+	cmd.Cmd.Flags().StringVar(&tlsCert, "tlscert", "", "tls cert, https is enabled when both cert and key are set")
+	cmd.Cmd.Flags().StringVar(&tlsKey, "tlskey", "", "tls key")
+
+	return cmd
+}
+
+// RunRendezvous serves the registry until it's interrupted.
+func RunRendezvous(listen, token, tlsCert, tlsKey string) error {
+	srv := &http.Server{Addr: listen, Handler: rendezvous.NewServer(token, nil)}
+	errs := make(chan error, 1)
+	go func() {
+		if tlsCert != "" && tlsKey != "" {
+			errs <- srv.ListenAndServeTLS(tlsCert, tlsKey)
+			return
+		}
+		errs <- srv.ListenAndServe()
+	}()
+	fmt.Fprintf(os.Stderr, "rendezvous listens on %s\n", listen)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-errs:
+		return err
+	case <-sigs:
+		return srv.Close()
+	}
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/aucusaga/gohotstuff/crypto"
+	"github.com/spf13/cobra"
+)
+
+type SignerCmd struct {
+	Cmd *cobra.Command
+}
+
+func GetSignerCmd() *SignerCmd {
+	cmd := new(SignerCmd)
+	var (
+		keyPath, listen, token string
+		tlsCert, tlsKey        string
+	)
+
+	cmd.Cmd = &cobra.Command{
+		Use:           "signer",
+		Short:         "serve a share of the validator key to the node, see `signers` in conf.yaml.",
+		Example:       "gohotstuff signer --keypath ./conf/keys --listen 0.0.0.0:26660 --token $SIGNER_TOKEN",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunSigner(keyPath, listen, token, tlsCert, tlsKey)
+		},
+	}
+
+	cmd.Cmd.Flags().StringVar(&keyPath, "keypath", "./conf/keys", "dir of the private.key of the share, see `genkey --type crypto`")
+	cmd.Cmd.Flags().StringVar(&listen, "listen", "127.0.0.1:26660", "listen address")
+	cmd.Cmd.Flags().StringVar(&token, "token", "", "bearer token required from the node, empty disables auth")
+	cmd.Cmd.Flags().StringVar(&tlsCert, "tlscert", "", "tls cert, https is enabled when both cert and key are set")
+	cmd.Cmd.Flags().StringVar(&tlsKey, "tlskey", "", "tls key")
+
+	return cmd
+}
+
+// RunSigner serves the share until it's interrupted.
+func RunSigner(keyPath, listen, token, tlsCert, tlsKey string) error {
+	raw, err := os.ReadFile(filepath.Join(keyPath, "private.key"))
+	if err != nil {
+		return err
+	}
+	sk, err := crypto.ParsePrivateKey(raw)
+	if err != nil {
+		return fmt.Errorf("parse private key fail, err: %v", err)
+	}
+	srv := &http.Server{Addr: listen, Handler: crypto.NewSignerServer(&crypto.LocalSigner{SK: sk}, token)}
+	errs := make(chan error, 1)
+	go func() {
+		if tlsCert != "" && tlsKey != "" {
+			errs <- srv.ListenAndServeTLS(tlsCert, tlsKey)
+			return
+		}
+		errs <- srv.ListenAndServe()
+	}()
+	fmt.Fprintf(os.Stderr, "signer listens on %s\n", listen)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-errs:
+		return err
+	case <-sigs:
+		return srv.Close()
+	}
+}
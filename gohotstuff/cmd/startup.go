@@ -2,9 +2,10 @@ package cmd
 
 import (
 	"fmt"
-	"math/rand"
+	"os"
+	"os/signal"
 	"path/filepath"
-	"time"
+	"syscall"
 
 	"github.com/aucusaga/gohotstuff/libs"
 	"github.com/aucusaga/gohotstuff/node"
@@ -58,9 +59,17 @@ func StartHotstuff(envCfgPath string) error {
 		panic(fmt.Errorf("new a node failed, cfg: %+v, err: %v", cfg, err))
 	}
 	n.Start()
-	for {
-		rand.Seed(time.Now().UnixNano())
-		time.Sleep(time.Duration(rand.Intn(1000) * int(time.Millisecond)))
-		continue
+
+	// a leader hands over its round before exiting
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	var fatal error
+	select {
+	case <-sigs:
+	case e := <-n.Fatal():
+		fatal = fmt.Errorf("node halted by a fatal failure, module: %s, data: %+v", e.Module, e.Data)
 	}
+	n.Stop()
+	n.Wait()
+	return fatal
 }
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/aucusaga/gohotstuff/state"
+	"github.com/spf13/cobra"
+)
+
+type VerifyChainCmd struct {
+	Cmd *cobra.Command
+}
+
+func GetVerifyChainCmd() *VerifyChainCmd {
+	cmd := new(VerifyChainCmd)
+	var (
+		envCfgPath string
+		chainID    string
+		from, to   int64
+	)
+
+	cmd.Cmd = &cobra.Command{
+		Use:           "verify-chain",
+		Short:         "re-verify the QCs of the committed blocks against the validators of their epochs.",
+		Example:       "gohotstuff verify-chain --conf /home/rd/gohotstuff/conf --from 100 --to 200",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return VerifyChain(envCfgPath, chainID, from, to)
+		},
+	}
+
+	cmd.Cmd.Flags().StringVarP(&envCfgPath, "conf", "c", "", "engine environment config file path")
+	cmd.Cmd.Flags().StringVar(&chainID, "chain", "", "chain id, the main chain by default")
+	cmd.Cmd.Flags().Int64Var(&from, "from", 0, "the first height")
+	cmd.Cmd.Flags().Int64Var(&to, "to", 0, "the last height, 0 means the latest one")
+
+	return cmd
+}
+
+// VerifyChain verifies the committed blocks of the chain in [from, to] and prints the evidence
+// of each of them, the validator set is reconstructed from the genesis validators of the configuration.
+func VerifyChain(envCfgPath, chainID string, from, to int64) error {
+	c, err := loadChainConfig(envCfgPath, chainID)
+	if err != nil {
+		return err
+	}
+	blocks, _, err := openChainStores(envCfgPath, chainID)
+	if err != nil {
+		return err
+	}
+	var validators []state.PeerID
+	for _, v := range c.Validators {
+		validators = append(validators, state.PeerID(v))
+	}
+	a := &state.Auditor{
+		Blocks:     blocks,
+		Election:   state.NewDefaultElection(int64(c.Round), validators),
+		StartRound: int64(c.Round),
+		ChainID:    c.Chainid,
+		ForkID:     c.Forkid,
+	}
+	enc := json.NewEncoder(os.Stdout)
+	n, err := a.VerifyChain(from, to, func(v *state.BlockVerification) {
+		enc.Encode(v)
+	})
+	if err != nil {
+		return fmt.Errorf("verify fail after %d blocks, err: %v", n, err)
+	}
+	fmt.Fprintf(os.Stderr, "%d blocks verified\n", n)
+	return nil
+}
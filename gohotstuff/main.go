@@ -33,6 +33,17 @@ func NewServiceCommand() (*cobra.Command, error) {
 	rootCmd.AddCommand(cmd.GetStartCmd().Cmd)
 	rootCmd.AddCommand(cmd.GetKeyCmd().Cmd)
 	rootCmd.AddCommand(cmd.GetAddressCmd().Cmd)
+	rootCmd.AddCommand(cmd.GetMsgsCmd().Cmd)
+	rootCmd.AddCommand(cmd.GetDevCmd().Cmd)
+	rootCmd.AddCommand(cmd.GetExportCmd().Cmd)
+	rootCmd.AddCommand(cmd.GetImportCmd().Cmd)
+	rootCmd.AddCommand(cmd.GetVerifyChainCmd().Cmd)
+	rootCmd.AddCommand(cmd.GetSignerCmd().Cmd)
+	rootCmd.AddCommand(cmd.GetRendezvousCmd().Cmd)
+	rootCmd.AddCommand(cmd.GetPossessionCmd().Cmd)
+	rootCmd.AddCommand(cmd.GetAddrBookCmd().Cmd)
+	rootCmd.AddCommand(cmd.GetDBCmd().Cmd)
+	rootCmd.AddCommand(cmd.GetLoadGenCmd().Cmd)
 
 	return rootCmd, nil
 }
@@ -0,0 +1,194 @@
+package libs
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the time source of the switch and the state machine, the simulation harness
+// injects a ManualClock to control the time and reproduce the timing-sensitive bugs.
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	After(d time.Duration) <-chan time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer mirrors time.Timer, C is nil for the timers of AfterFunc.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker mirrors time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// SystemClock is backed by the package time.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return &systemTimer{time.AfterFunc(d, f)}
+}
+func (systemClock) NewTimer(d time.Duration) Timer   { return &systemTimer{time.NewTimer(d)} }
+func (systemClock) NewTicker(d time.Duration) Ticker { return &systemTicker{time.NewTicker(d)} }
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t *systemTimer) C() <-chan time.Time { return t.Timer.C }
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t *systemTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// ManualClock only moves forward by Advance, the timers and tickers due are fired in Advance,
+// like the package time, a tick is dropped if the previous one hasn't been received.
+type ManualClock struct {
+	now    time.Time
+	timers []*manualTimer
+	mtx    sync.Mutex
+}
+
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.now
+}
+
+func (c *ManualClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+func (c *ManualClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+func (c *ManualClock) AfterFunc(d time.Duration, f func()) Timer {
+	return c.schedule(d, 0, f)
+}
+
+func (c *ManualClock) NewTimer(d time.Duration) Timer {
+	return c.schedule(d, 0, nil)
+}
+
+func (c *ManualClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for ManualClock.NewTicker")
+	}
+	return &manualTicker{c.schedule(d, d, nil)}
+}
+
+// Advance moves the clock forward and fires the timers due, the funcs of AfterFunc
+// are run in their own goroutines.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mtx.Lock()
+	c.now = c.now.Add(d)
+	var funcs []func()
+	timers := c.timers[:0]
+	for _, t := range c.timers {
+		for t.active && !t.at.After(c.now) {
+			if t.f != nil {
+				funcs = append(funcs, t.f)
+			} else {
+				select {
+				case t.c <- t.at:
+				default:
+				}
+			}
+			if t.period > 0 {
+				t.at = t.at.Add(t.period)
+			} else {
+				t.active = false
+			}
+		}
+		if t.active {
+			timers = append(timers, t)
+		} else {
+			t.listed = false
+		}
+	}
+	c.timers = timers
+	c.mtx.Unlock()
+
+	for _, f := range funcs {
+		go f()
+	}
+}
+
+func (c *ManualClock) schedule(d, period time.Duration, f func()) *manualTimer {
+	t := &manualTimer{clock: c, period: period, f: f}
+	if f == nil {
+		t.c = make(chan time.Time, 1)
+	}
+	t.Reset(d)
+	return t
+}
+
+type manualTimer struct {
+	clock  *ManualClock
+	c      chan time.Time
+	at     time.Time
+	period time.Duration
+	f      func()
+	active bool
+	// listed is true while the timer is in clock.timers
+	listed bool
+}
+
+func (t *manualTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *manualTimer) Stop() bool {
+	t.clock.mtx.Lock()
+	defer t.clock.mtx.Unlock()
+
+	active := t.active
+	t.active = false
+	return active
+}
+
+func (t *manualTimer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.mtx.Lock()
+	active := t.active
+	t.active = true
+	t.at = c.now.Add(d)
+	if !t.listed {
+		t.listed = true
+		c.timers = append(c.timers, t)
+	}
+	c.mtx.Unlock()
+
+	// like the package time, a non-positive duration fires immediately
+	if d <= 0 {
+		c.Advance(0)
+	}
+	return active
+}
+
+type manualTicker struct {
+	t *manualTimer
+}
+
+func (t *manualTicker) C() <-chan time.Time { return t.t.C() }
+func (t *manualTicker) Stop()               { t.t.Stop() }
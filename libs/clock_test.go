@@ -0,0 +1,60 @@
+package libs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Unix(100, 0)
+	c := NewManualClock(start)
+	timer := c.NewTimer(time.Second)
+	ticker := c.NewTicker(400 * time.Millisecond)
+	fired := make(chan struct{}, 1)
+	c.AfterFunc(2*time.Second, func() { fired <- struct{}{} })
+
+	c.Advance(500 * time.Millisecond)
+	if has := c.Since(start); has != 500*time.Millisecond {
+		t.Errorf("unexpected elapsed time: %v", has)
+	}
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+	if tick := <-ticker.C(); !tick.Equal(start.Add(400 * time.Millisecond)) {
+		t.Errorf("unexpected tick: %v", tick)
+	}
+
+	c.Advance(500 * time.Millisecond)
+	if at := <-timer.C(); !at.Equal(start.Add(time.Second)) {
+		t.Errorf("unexpected timer: %v", at)
+	}
+	if timer.Stop() {
+		t.Errorf("a fired timer should be inactive")
+	}
+	// the tick of 1.2s is dropped since the one of 0.8s hasn't been received
+	c.Advance(300 * time.Millisecond)
+	if tick := <-ticker.C(); !tick.Equal(start.Add(800 * time.Millisecond)) {
+		t.Errorf("unexpected tick: %v", tick)
+	}
+
+	ticker.Stop()
+	timer.Reset(time.Second)
+	c.Advance(time.Second)
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("func of AfterFunc not invoked")
+	}
+	select {
+	case <-timer.C():
+	default:
+		t.Fatal("reset timer not fired")
+	}
+	select {
+	case <-ticker.C():
+		t.Fatal("stopped ticker fired")
+	default:
+	}
+}
@@ -13,13 +13,240 @@ type Config struct {
 	Address   string   `yaml:"address,omitempty"`
 	Bootstrap []string `yaml:"bootstrap,omitempty"`
 	Netpath   string   `yaml:"netpath,omitempty"`
+	Proxy     string   `yaml:"proxy,omitempty"`
 	Keypath   string   `yaml:"keypath,omitempty"`
+	Walpath   string   `yaml:"walpath,omitempty"`
+	Chainid   string   `yaml:"chainid,omitempty"`
+	Forkid    string   `yaml:"forkid,omitempty"`
+	Dumppath  string   `yaml:"dumppath,omitempty"`
+	Tappath   string   `yaml:"tappath,omitempty"`
+	// file of the peer audit log, empty keeps the latest records in memory only
+	Auditpath string `yaml:"auditpath,omitempty"`
+	// file of the address book saved on stop and imported on start, empty disables it
+	Addrbookpath string `yaml:"addrbookpath,omitempty"`
+	// pinned full multiaddrs of the validators, a peer presenting another identity is refused
+	Pinnedpeers []string `yaml:"pinnedpeers,omitempty"`
+	// dir of the executed block results, empty disables persisting them
+	Resultspath string `yaml:"resultspath,omitempty"`
+	// dir of the committed blocks and their QCs, empty disables persisting them
+	Blockspath string `yaml:"blockspath,omitempty"`
+	// a stored block is re-read every scrubinterval milliseconds and repaired from the peers if corrupted
+	Scrubinterval int `yaml:"scrubinterval,omitempty"`
+	// the committed blocks are posted to the webhooks at least once, the undelivered ones are kept under outboxpath
+	Outboxpath     string   `yaml:"outboxpath,omitempty"`
+	Commitwebhooks []string `yaml:"commitwebhooks,omitempty"`
+	// a non-validator observes the committed blocks from the peers, which serve observerrate blocks per second
+	Observe      bool `yaml:"observe,omitempty"`
+	Observerrate int  `yaml:"observerrate,omitempty"`
+	// an archive runs neither the mempool nor the consensus, it only serves its block store
+	// to the subscribers on the observer channel, the snapshots and the rpc
+	Archive bool `yaml:"archive,omitempty"`
+	// committed blocks which may wait for the executor before the host stops voting
+	Execdepth int `yaml:"execdepth,omitempty"`
+	// bytes of the application data a validator may attach to its vote, 0 means the default
+	Maxvoteextensionbytes int `yaml:"maxvoteextensionbytes,omitempty"`
+	// wal flush policy, votes are always synced: sync every record, or the buffered ones
+	// every interval in milliseconds or once they exceed the bytes
+	Walflushpermsg   bool `yaml:"walflushpermsg,omitempty"`
+	Walflushinterval int  `yaml:"walflushinterval,omitempty"`
+	Walflushsize     int  `yaml:"walflushsize,omitempty"`
+
+	// concurrent outbound dials, and the timeout of each dial in milliseconds
+	Dialconcurrency int `yaml:"dialconcurrency,omitempty"`
+	Dialtimeout     int `yaml:"dialtimeout,omitempty"`
+	// msgs waiting longer in the send queue of a slow peer are dropped, in milliseconds, 0 disables it
+	Sendqueuettl int `yaml:"sendqueuettl,omitempty"`
+	// how long the acknowledged sends, e.g. the sync responses, wait for the msg to be written in milliseconds
+	Sendresulttimeout int `yaml:"sendresulttimeout,omitempty"`
+	// streams of the non-validators idle for idletimeout are closed, the validators are pinged
+	// once idle for pinginterval, both in milliseconds, 0 disables either
+	Idletimeout  int `yaml:"idletimeout,omitempty"`
+	Pinginterval int `yaml:"pinginterval,omitempty"`
+	// the handshake of a stream and the read of a frame from its first byte on must be done in time, in milliseconds
+	Handshaketimeout int `yaml:"handshaketimeout,omitempty"`
+	Frametimeout     int `yaml:"frametimeout,omitempty"`
+	// goroutines shared by all peers which write their msgs and dispatch the ones received, 0 uses the defaults,
+	// negative runs the goroutines of every peer instead
+	Sendworkers   int `yaml:"sendworkers,omitempty"`
+	Handleworkers int `yaml:"handleworkers,omitempty"`
+	// wire features advertised in the handshake, see libs.Features, the peers lacking the required ones
+	// or below the min protocol version are refused
+	Features           []string `yaml:"features,omitempty"`
+	Requiredfeatures   []string `yaml:"requiredfeatures,omitempty"`
+	Minprotocolversion uint32   `yaml:"minprotocolversion,omitempty"`
+	// experimental subsystems shipping dark, see libs.FeatureFlags
+	Experimental []string `yaml:"experimental,omitempty"`
+	// level of the per-msg logs, debug | info | off, and the payload bytes logged in hex, negative logs the sums only
+	Msgloglevel string `yaml:"msgloglevel,omitempty"`
+	Msglogbytes int    `yaml:"msglogbytes,omitempty"`
+	// the routed peers are dialed validators first, then the sentries, then the others,
+	// each class up to its quota of outbound connections, 0 is unlimited
+	Sentrypeers         []string `yaml:"sentrypeers,omitempty"`
+	Dialquotavalidators int      `yaml:"dialquotavalidators,omitempty"`
+	Dialquotasentries   int      `yaml:"dialquotasentries,omitempty"`
+	Dialquotaothers     int      `yaml:"dialquotaothers,omitempty"`
+	// the channels of the modules only accept the msgs of the peers of the roles (validator | syncserver | observer),
+	// map[module]roles, the msgs of the others are dropped and lower their scores, syncservers are peer ids
+	Channelroles map[string][]string `yaml:"channelroles,omitempty"`
+	Syncservers  []string            `yaml:"syncservers,omitempty"`
+
+	// retries recreating a reset stream and redialing a lost connection, backoffs in milliseconds,
+	// a negative number of retries disables it
+	Streamretries      int `yaml:"streamretries,omitempty"`
+	Streamretrybackoff int `yaml:"streamretrybackoff,omitempty"`
+	Redialretries      int `yaml:"redialretries,omitempty"`
+	Redialretrybackoff int `yaml:"redialretrybackoff,omitempty"`
+
+	// dht mode (server | client | auto), routing table refresh period and
+	// query timeout in milliseconds, zero values keep the defaults
+	Dhtmode          string `yaml:"dhtmode,omitempty"`
+	Dhtrefreshperiod int    `yaml:"dhtrefreshperiod,omitempty"`
+	Dhtbucketsize    int    `yaml:"dhtbucketsize,omitempty"`
+	Dhtquerytimeout  int    `yaml:"dhtquerytimeout,omitempty"`
+
+	// the url of the rendezvous registry replacing the dht, the token authorizing it, the ttl of the registration
+	// and the period of fetching the peers in milliseconds, see `gohotstuff rendezvous`
+	Rendezvous              string `yaml:"rendezvous,omitempty"`
+	Rendezvoustoken         string `yaml:"rendezvoustoken,omitempty"`
+	Rendezvousttl           int    `yaml:"rendezvousttl,omitempty"`
+	Rendezvousrefreshperiod int    `yaml:"rendezvousrefreshperiod,omitempty"`
+
+	// the send queue delay in milliseconds of a lagging write, the consecutive lagging writes marking a peer slow,
+	// and the chunk size in bytes of the msgs to the peers reassembling them, zero values disable them
+	Slowpeerthreshold int `yaml:"slowpeerthreshold,omitempty"`
+	Slowpeersamples   int `yaml:"slowpeersamples,omitempty"`
+	Chunksize         int `yaml:"chunksize,omitempty"`
+
+	// verified msgs cache, ttl in seconds
+	Verifycachesize int `yaml:"verifycachesize,omitempty"`
+	Verifycachettl  int `yaml:"verifycachettl,omitempty"`
+
+	// urls of the remote signers splitting the validator key, a msg is signed once signerthreshold
+	// of them have signed it within signertimeout milliseconds, they replace private.key when set
+	Signers         []string `yaml:"signers,omitempty"`
+	Signerthreshold int      `yaml:"signerthreshold,omitempty"`
+	Signertoken     string   `yaml:"signertoken,omitempty"`
+	Signertimeout   int      `yaml:"signertimeout,omitempty"`
+
+	// rpc, tls is enabled when both cert and key are set,
+	// rpctokens maps the roles (readonly | operator | admin) to their api tokens,
+	// rpcauditpath appends the invocations of the /unsafe_* endpoints to the file as json lines
+	Rpcaddress   string              `yaml:"rpcaddress,omitempty"`
+	Rpctlscert   string              `yaml:"rpctlscert,omitempty"`
+	Rpctlskey    string              `yaml:"rpctlskey,omitempty"`
+	Rpctokens    map[string][]string `yaml:"rpctokens,omitempty"`
+	Rpcauditpath string              `yaml:"rpcauditpath,omitempty"`
 
 	// TODO: loading WAL instead of configuration
 	Round      int      `yaml:"round,omitempty"`
 	Startk     string   `yaml:"startk,omitempty"`
 	Startv     string   `yaml:"startv,omitempty"`
 	Validators []string `yaml:"validators,omitempty"`
+	// consensus keys of the validators with their proofs of possession, see ValidatorKey
+	Validatorkeys []ValidatorKey `yaml:"validatorkeys,omitempty"`
+	// when a new chain begins its first view in RFC3339, e.g. 2021-06-01T08:00:00Z,
+	// the validators started earlier idle until then
+	Genesistime string `yaml:"genesistime,omitempty"`
+
+	// min interval between blocks in milliseconds
+	Minblockinterval int `yaml:"minblockinterval,omitempty"`
+	// milliseconds the leader keeps collecting the votes after the quorum, 0 forms the QC at once
+	Votegrace int `yaml:"votegrace,omitempty"`
+	// skip the fixed proposal rate limit, for the single-validator dev chain
+	Instantfinality bool `yaml:"instantfinality,omitempty"`
+	// hash scheme of the proposal ids (sha256 | legacy), empty means sha256,
+	// the legacy ids of the received proposals are accepted below hashactivation only
+	Hashscheme     string `yaml:"hashscheme,omitempty"`
+	Hashactivation int64  `yaml:"hashactivation,omitempty"`
+	// block and tx size limits in bytes, proposals exceeding them are rejected
+	Maxblockbytes int `yaml:"maxblockbytes,omitempty"`
+	Maxtxbytes    int `yaml:"maxtxbytes,omitempty"`
+	// round timeout in milliseconds and epoch length in rounds, changed by the committed param changes later
+	Roundtimeout int `yaml:"roundtimeout,omitempty"`
+	Epochlength  int `yaml:"epochlength,omitempty"`
+	// how far the proposal timestamps may be ahead of the local clock in milliseconds
+	Maxclockskew int `yaml:"maxclockskew,omitempty"`
+	// how long stop flushes the decided votes and the queued msgs of the current view in milliseconds
+	Flushtimeout int `yaml:"flushtimeout,omitempty"`
+	// sla alarms, commitsla in milliseconds
+	Maxviewchanges int `yaml:"maxviewchanges,omitempty"`
+	Commitsla      int `yaml:"commitsla,omitempty"`
+	// how often the status is gossiped to the peers in milliseconds
+	Statusinterval int `yaml:"statusinterval,omitempty"`
+	// downtime tracking over the latest QCs, maxmissedpct in percent of the window
+	Downtimewindow int `yaml:"downtimewindow,omitempty"`
+	Maxmissedpct   int `yaml:"maxmissedpct,omitempty"`
+	// halt at the height for a coordinated binary upgrade, upgradeinfo is printed with the instructions
+	Upgradeheight int64  `yaml:"upgradeheight,omitempty"`
+	Upgradeinfo   string `yaml:"upgradeinfo,omitempty"`
+	// how the wal, storage and executor failures are handled, map[kind]policy
+	Failures map[string]FailureConfig `yaml:"failures,omitempty"`
+	// the writes of the wal and the stores failed for the disk are retried every diskprobeinterval milliseconds,
+	// the host stops voting until they pass
+	Diskprobeinterval int `yaml:"diskprobeinterval,omitempty"`
+	// ignore the votes and proposals of the validators caught equivocating
+	Ejectmisbehaving bool `yaml:"ejectmisbehaving,omitempty"`
+
+	// extra consensus instances sharing the same p2p switch
+	Chains []ChainConfig `yaml:"chains,omitempty"`
+}
+
+// ChainConfig describes a consensus instance of one chain.
+type ChainConfig struct {
+	Chainid string `yaml:"chainid,omitempty"`
+	Forkid  string `yaml:"forkid,omitempty"`
+	Walpath string `yaml:"walpath,omitempty"`
+	// dir of the executed block results, empty disables persisting them
+	Resultspath      string `yaml:"resultspath,omitempty"`
+	Blockspath       string `yaml:"blockspath,omitempty"`
+	Scrubinterval    int    `yaml:"scrubinterval,omitempty"`
+	Walflushpermsg   bool   `yaml:"walflushpermsg,omitempty"`
+	Walflushinterval int    `yaml:"walflushinterval,omitempty"`
+	Walflushsize     int    `yaml:"walflushsize,omitempty"`
+	// min interval between blocks in milliseconds
+	Minblockinterval      int            `yaml:"minblockinterval,omitempty"`
+	Votegrace             int            `yaml:"votegrace,omitempty"`
+	Instantfinality       bool           `yaml:"instantfinality,omitempty"`
+	Hashscheme            string         `yaml:"hashscheme,omitempty"`
+	Hashactivation        int64          `yaml:"hashactivation,omitempty"`
+	Maxblockbytes         int            `yaml:"maxblockbytes,omitempty"`
+	Maxtxbytes            int            `yaml:"maxtxbytes,omitempty"`
+	Roundtimeout          int            `yaml:"roundtimeout,omitempty"`
+	Epochlength           int            `yaml:"epochlength,omitempty"`
+	Maxviewchanges        int            `yaml:"maxviewchanges,omitempty"`
+	Commitsla             int            `yaml:"commitsla,omitempty"`
+	Statusinterval        int            `yaml:"statusinterval,omitempty"`
+	Downtimewindow        int            `yaml:"downtimewindow,omitempty"`
+	Maxmissedpct          int            `yaml:"maxmissedpct,omitempty"`
+	Upgradeheight         int64          `yaml:"upgradeheight,omitempty"`
+	Upgradeinfo           string         `yaml:"upgradeinfo,omitempty"`
+	Round                 int            `yaml:"round,omitempty"`
+	Startk                string         `yaml:"startk,omitempty"`
+	Startv                string         `yaml:"startv,omitempty"`
+	Validators            []string       `yaml:"validators,omitempty"`
+	Validatorkeys         []ValidatorKey `yaml:"validatorkeys,omitempty"`
+	Genesistime           string         `yaml:"genesistime,omitempty"`
+	Outboxpath            string         `yaml:"outboxpath,omitempty"`
+	Commitwebhooks        []string       `yaml:"commitwebhooks,omitempty"`
+	Observe               bool           `yaml:"observe,omitempty"`
+	Observerrate          int            `yaml:"observerrate,omitempty"`
+	Execdepth             int            `yaml:"execdepth,omitempty"`
+	Maxvoteextensionbytes int            `yaml:"maxvoteextensionbytes,omitempty"`
+	Maxclockskew          int            `yaml:"maxclockskew,omitempty"`
+	Flushtimeout          int            `yaml:"flushtimeout,omitempty"`
+
+	Failures          map[string]FailureConfig `yaml:"failures,omitempty"`
+	Diskprobeinterval int                      `yaml:"diskprobeinterval,omitempty"`
+	Ejectmisbehaving  bool                     `yaml:"ejectmisbehaving,omitempty"`
+}
+
+// FailureConfig is the policy of a failure kind (wal | storage | executor), the actions are
+// ignore | retry | degrade | halt, the backoff is in milliseconds, see state.FailurePolicy.
+type FailureConfig struct {
+	Action   string `yaml:"action,omitempty"`
+	Retries  int    `yaml:"retries,omitempty"`
+	Backoff  int    `yaml:"backoff,omitempty"`
+	Fallback string `yaml:"fallback,omitempty"`
 }
 
 func GetConfig(cfgFile string) (*Config, error) {
@@ -1,25 +1,91 @@
 package libs
 
+import (
+	"hash/fnv"
+)
+
+// the names and the base channels of the modules, see ModuleID.
 const (
 	ConsensusModule  = "consensus"
 	ConsensusChannel = int32(0)
+	MempoolModule    = "mempool"
+	MempoolChannel   = int32(1)
+	ObserverModule   = "observer"
+	ObserverChannel  = int32(2)
+	JoinModule       = "join"
+	JoinChannel      = int32(3)
+	P2PModule        = "p2p"
 
 	HotstuffChaindStep = 3
 )
 
-var (
-	IDToModuleMap = map[int32]string{
-		ConsensusChannel: ConsensusModule,
+// ChainModule namespaces the module by the chain id, so that several chains can share one switch.
+func ChainModule(chainID string, module string) string {
+	if chainID == "" {
+		return module
 	}
-)
+	return chainID + "/" + module
+}
+
+// ChainChannel namespaces the channel by the chain id, the low 8 bits hold the base channel
+// and the others are derived from the chain id, which should be the same among all nodes.
+func ChainChannel(chainID string, base int32) int32 {
+	if chainID == "" {
+		return base
+	}
+	h := fnv.New32a()
+	h.Write([]byte(chainID))
+	return int32(h.Sum32()&0x7fffff)<<8 | base&0xff
+}
 
 type Reactor interface {
+	// HandleFunc handles the msg received on the channel. The msgBytes are lent by the switch
+	// without copying and are recycled once HandleFunc returns, the reactor mustn't modify
+	// them or keep any part of them afterwards, e.g. in a queue or a cache, without copying it.
+	// The msgs unmarshaled by the generated protobuf code own their bytes already.
 	HandleFunc(chID int32, msgBytes []byte)
 	SetSwitch(sw Switch)
+	// AddPeer is invoked by the switch once a peer has connected.
+	AddPeer(peer Peer)
+	// RemovePeer is invoked by the switch once a peer has been stopped.
+	RemovePeer(peer Peer, reason interface{})
+}
+
+// PeerReactor is a Reactor handling the msgs by their sender, HandleFuncFrom is invoked instead of HandleFunc
+// in the order the msgs of the peer are received, e.g. to verify them in a queue of the peer so that a peer
+// can't stall the msgs of the others. It's invoked by the receive loop of the peer and mustn't block,
+// the msgBytes are lent like the ones of HandleFunc.
+type PeerReactor interface {
+	Reactor
+	HandleFuncFrom(peerID string, chID int32, msgBytes []byte)
+}
+
+// Peer is the view of a connected peer exposed to the reactors.
+type Peer interface {
+	PeerID() string
+	Send(chID int32, msgBytes []byte) bool
 }
 
 type Switch interface {
 	Broadcast(chID int32, msgBytes []byte)
+	// BroadcastToValidators sends the msg to the peers in the validator set of the channel only.
+	BroadcastToValidators(chID int32, msgBytes []byte)
+	// SetValidatorSet registers the func returning the current validators of the channel.
+	SetValidatorSet(chID int32, set func() []string)
+	// SetMessageExpiry registers the func reporting the stale msgs of the channel, which are dropped from the send queues.
+	SetMessageExpiry(chID int32, expired func(msgBytes []byte) bool)
+	// SetPullOnSlow registers the func reporting the msgs of the channel which the slow peers pull instead of being pushed.
+	SetPullOnSlow(chID int32, pull func(msgBytes []byte) bool)
 	Send(peerID string, chID int32, msgBytes []byte) error
+	// SendWithResult sends the msg like Send, the chan resolves once the msg is written to the wire, or it fails
+	// or times out, so that the critical point-to-point msgs, e.g. the sync responses, can be retried.
+	SendWithResult(peerID string, chID int32, msgBytes []byte) <-chan error
 	GetP2PID(peerID string) (string, error)
+	// StopPeerForError disconnects from a peer due to an error caused by it.
+	StopPeerForError(peerID string, reason interface{})
+	// PeerFeatures returns the features negotiated with the connected peer, i.e. enabled by both sides.
+	PeerFeatures(peerID string) (Features, bool)
+	// Reactor returns the reactor registered under the name, see ModuleID.Name, so that the modules can call
+	// each other, e.g. sw.Reactor(ModuleMempool.Name(chainID)), asserting it to the interface they need.
+	Reactor(name string) (Reactor, bool)
 }
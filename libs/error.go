@@ -2,6 +2,8 @@ package libs
 
 import (
 	"errors"
+	"strings"
+	"syscall"
 )
 
 var (
@@ -11,3 +13,20 @@ var (
 	ErrOrphanNode      = errors.New("cannot find the location where the node can be inserted")
 	ErrRepeatInsert    = errors.New("key has been inserted before")
 )
+
+// the errnos of the writes failing for the disk, which may pass again once the operators free the space or fix the device
+var diskErrnos = []syscall.Errno{syscall.ENOSPC, syscall.EDQUOT, syscall.EIO, syscall.EROFS}
+
+// IsDiskFailure reports whether err is a write failing for the disk, e.g. ENOSPC or EIO, the errors of the stores
+// which don't wrap the errnos are matched by their messages.
+func IsDiskFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, errno := range diskErrnos {
+		if errors.Is(err, errno) || strings.Contains(err.Error(), errno.Error()) {
+			return true
+		}
+	}
+	return false
+}
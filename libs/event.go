@@ -0,0 +1,241 @@
+package libs
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultEventBufferSize = 100
+)
+
+// EventType distinguishes the notifications published on the EventBus.
+type EventType string
+
+const (
+	// EventAll subscribes to every event published on the bus.
+	EventAll EventType = "*"
+	// EventPanic is published when a goroutine recovers from a panic.
+	EventPanic EventType = "panic"
+	// EventSLAViolation is published when the consensus exceeds the configured sla.
+	EventSLAViolation EventType = "sla_violation"
+	// EventIdentityMismatch is published when a pinned address presents another peer identity.
+	EventIdentityMismatch EventType = "identity_mismatch"
+	// EventBehind is published when the peers gossip a higher round than the host's.
+	EventBehind EventType = "behind"
+	// EventDowntime is published when a validator misses too many QCs of the window.
+	EventDowntime EventType = "downtime"
+	// EventUpgrade is published when the consensus halts at the upgrade height.
+	EventUpgrade EventType = "upgrade"
+	// EventConsensusFailure is published when the executed state diverges from the committed blocks.
+	EventConsensusFailure EventType = "consensus_failure"
+	// EventFatal is published when a failure halts the node by its failure policy.
+	EventFatal EventType = "fatal"
+	// EventMisbehavior is published when a validator is caught signing two votes or proposals in a round.
+	EventMisbehavior EventType = "misbehavior"
+	// EventPeerConnected is published when a peer is added to the switch.
+	EventPeerConnected EventType = "peer_connected"
+	// EventPeerDisconnected is published when a peer is removed from the switch.
+	EventPeerDisconnected EventType = "peer_disconnected"
+	// EventPeerBanned is published when the address of a peer is marked suspicious.
+	EventPeerBanned EventType = "peer_banned"
+	// EventBlockCorrupted is published when the scrubber finds a stored block failing its checks.
+	EventBlockCorrupted EventType = "block_corrupted"
+	// EventBlockRepaired is published when a corrupted block is replaced by the one fetched from a peer.
+	EventBlockRepaired EventType = "block_repaired"
+	// EventJoinRequest is published when a candidate asks to join the validators, see state.JoinRequest.
+	EventJoinRequest EventType = "join_request"
+	// EventValidatorAdmitted is published when the admission of a candidate is committed.
+	EventValidatorAdmitted EventType = "validator_admitted"
+	// EventDiskFailure is published when a write of the wal or a store fails for the disk, e.g. it's full,
+	// the host stops voting until the failed writes pass again, it's critical to the operators.
+	EventDiskFailure EventType = "disk_failure"
+	// EventDiskRecovered is published when the failed writes pass again and the host resumes voting.
+	EventDiskRecovered EventType = "disk_recovered"
+)
+
+// Event is a notification emitted by any module of the node.
+type Event struct {
+	Type      EventType
+	Module    string
+	Timestamp int64
+	Data      interface{}
+}
+
+// PanicEventData describes a recovered panic.
+type PanicEventData struct {
+	PeerID string
+	Reason string
+	Stack  string
+}
+
+// SLAEventData describes an exceeded sla.
+type SLAEventData struct {
+	Kind      string
+	Round     int64
+	Value     float64
+	Threshold float64
+}
+
+// IdentityEventData describes a peer presenting an unexpected identity,
+// Actual is empty if the handshake is refused before the identity is known.
+type IdentityEventData struct {
+	Address  string
+	Expected string
+	Actual   string
+}
+
+// BehindEventData describes the host's round and the round reached by more than 1/3 validators.
+type BehindEventData struct {
+	Round     int64
+	PeerRound int64
+}
+
+// DowntimeEventData describes a validator missing from Missed of the latest Window QCs.
+type DowntimeEventData struct {
+	Validator string
+	Round     int64
+	Missed    int
+	Window    int
+}
+
+// UpgradeEventData describes the upgrade height and the last block committed before halting.
+type UpgradeEventData struct {
+	Height         int64
+	CommittedRound int64
+	Info           string
+}
+
+// ConsensusFailureEventData is the divergence report: the committed block of Round claims
+// the state hash after the block of Height, which differs from the hash executed locally.
+type ConsensusFailureEventData struct {
+	Height         int64
+	Round          int64
+	Proposer       string
+	ClaimedHash    string
+	LocalHash      string
+	ExecutedHeight int64
+}
+
+// FatalEventData describes the failure which halts the node, e.g. a failed write of the wal.
+type FatalEventData struct {
+	Kind   string
+	Reason string
+}
+
+// MisbehaviorEventData is the evidence that Validator has signed both First and Second in Round.
+type MisbehaviorEventData struct {
+	Validator string
+	Kind      string
+	Round     int64
+	First     string
+	Second    string
+}
+
+// JoinEventData describes the candidate asking to join or admitted, PublicKey is hex encoded,
+// Start is the round from which the admitted candidate validates, 0 for a request.
+type JoinEventData struct {
+	Validator string
+	Address   string
+	PublicKey string
+	Start     int64
+}
+
+// DiskEventData describes the disk failure of Kind (wal | storage), Duration is how long
+// the host has stopped voting for it, zero for the failure.
+type DiskEventData struct {
+	Kind     string
+	Reason   string
+	Duration time.Duration
+}
+
+// PeerEventData describes a change of the peer set, Direction is inbound or outbound,
+// Latency is the ewma of the round trips measured by the host, zero if unknown.
+// Direction, Validator and Latency are unknown for a banned address.
+type PeerEventData struct {
+	Peer      string
+	Addr      string
+	Direction string
+	Validator bool
+	Latency   time.Duration
+	Reason    string
+}
+
+// StorageEventData describes a corrupted or repaired block of the chain, Peer served the repaired one.
+type StorageEventData struct {
+	ChainID string
+	Height  int64
+	Reason  string
+	Peer    string
+}
+
+// EventBus is a simple publish-subscribe hub shared by the modules of a node,
+// publishing never blocks, events are dropped when a subscriber is too slow.
+type EventBus interface {
+	Publish(e Event)
+	Subscribe(t EventType) <-chan Event
+	// Unsubscribe releases the channel returned by Subscribe, which is never closed.
+	Unsubscribe(ch <-chan Event)
+}
+
+type DefaultEventBus struct {
+	subscribers map[EventType][]chan Event
+	mtx         sync.RWMutex
+
+	log Logger
+}
+
+func NewDefaultEventBus(logger Logger) *DefaultEventBus {
+	return &DefaultEventBus{
+		subscribers: make(map[EventType][]chan Event),
+		log:         logger,
+	}
+}
+
+func (b *DefaultEventBus) Subscribe(t EventType) <-chan Event {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	ch := make(chan Event, defaultEventBufferSize)
+	b.subscribers[t] = append(b.subscribers[t], ch)
+	return ch
+}
+
+func (b *DefaultEventBus) Unsubscribe(ch <-chan Event) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	for t, subs := range b.subscribers {
+		for i, sub := range subs {
+			if sub != ch {
+				continue
+			}
+			b.subscribers[t] = append(subs[:i:i], subs[i+1:]...)
+			if len(b.subscribers[t]) == 0 {
+				delete(b.subscribers, t)
+			}
+			return
+		}
+	}
+}
+
+func (b *DefaultEventBus) Publish(e Event) {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	if e.Timestamp == 0 {
+		e.Timestamp = time.Now().UnixNano()
+	}
+	subs := make([]chan Event, 0, len(b.subscribers[e.Type])+len(b.subscribers[EventAll]))
+	subs = append(subs, b.subscribers[e.Type]...)
+	subs = append(subs, b.subscribers[EventAll]...)
+	for _, ch := range subs {
+		select {
+		case ch <- e:
+		default:
+			if b.log != nil {
+				b.log.Warn("subscriber is full, drop event @ eventbus.Publish, type: %s, module: %s", e.Type, e.Module)
+			}
+		}
+	}
+}
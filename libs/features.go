@@ -0,0 +1,71 @@
+package libs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Features is the bitmap of the optional wire features, every peer advertises the enabled ones
+// in the handshake and a feature is only used with the peers which have advertised it as well,
+// so that a feature can be rolled out across a live validator set one node at a time.
+type Features uint64
+
+const (
+	// FeatureCompression compresses the payloads of the packets.
+	FeatureCompression Features = 1 << iota
+	// FeatureBLSQC aggregates the signatures of the QCs by BLS.
+	FeatureBLSQC
+	// FeatureChunkedPayload splits the large payloads into chunks.
+	FeatureChunkedPayload
+	// FeatureChainEnvelope refuses the packets which don't carry the chain and the fork of the host,
+	// every packet is stamped anyway, but the unstamped ones of the older peers are accepted without it.
+	FeatureChainEnvelope
+)
+
+var featureNames = []struct {
+	f    Features
+	name string
+}{
+	{FeatureCompression, "compression"},
+	{FeatureBLSQC, "bls_qc"},
+	{FeatureChunkedPayload, "chunked_payload"},
+	{FeatureChainEnvelope, "chain_envelope"},
+}
+
+// Has reports whether all the features of other are set.
+func (f Features) Has(other Features) bool {
+	return f&other == other
+}
+
+// String joins the names of the features, the unknown bits are printed in hex.
+func (f Features) String() string {
+	var names []string
+	for _, n := range featureNames {
+		if f&n.f != 0 {
+			names = append(names, n.name)
+			f &^= n.f
+		}
+	}
+	if f != 0 {
+		names = append(names, fmt.Sprintf("%#x", uint64(f)))
+	}
+	return strings.Join(names, "|")
+}
+
+// ParseFeatures returns the features of the names, e.g. the ones in conf.yaml.
+func ParseFeatures(names []string) (Features, error) {
+	var f Features
+	for _, name := range names {
+		found := false
+		for _, n := range featureNames {
+			if n.name == name {
+				f, found = f|n.f, true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown feature: %s", name)
+		}
+	}
+	return f, nil
+}
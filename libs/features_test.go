@@ -0,0 +1,21 @@
+package libs
+
+import (
+	"testing"
+)
+
+func TestParseFeatures(t *testing.T) {
+	f, err := ParseFeatures([]string{"compression", "chunked_payload"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Has(FeatureCompression|FeatureChunkedPayload) || f.Has(FeatureBLSQC) {
+		t.Errorf("unexpected features: %s", f)
+	}
+	if s := (f | 1<<10).String(); s != "compression|chunked_payload|0x400" {
+		t.Errorf("unexpected string: %s", s)
+	}
+	if _, err := ParseFeatures([]string{"zstd"}); err == nil {
+		t.Errorf("unknown feature should be refused")
+	}
+}
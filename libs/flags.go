@@ -0,0 +1,83 @@
+package libs
+
+import (
+	"fmt"
+	"sort"
+)
+
+// the experimental subsystems, which ship dark and are enabled per network by the `experimental` list of conf.yaml,
+// every validator of a network should enable the same ones, the peers advertising others are warned of.
+const (
+	// FlagGossipBroadcast gossips the consensus msgs through the peers instead of broadcasting them directly.
+	FlagGossipBroadcast = "gossip_broadcast"
+	// FlagPipelining proposes the next block before the previous one is certified.
+	FlagPipelining = "pipelining"
+	// FlagVoteRelay relays the votes to the next leader through the peers connected to it.
+	FlagVoteRelay = "vote_relay"
+)
+
+var experimentalFlags = map[string]bool{
+	FlagGossipBroadcast: true,
+	FlagPipelining:      true,
+	FlagVoteRelay:       true,
+}
+
+// FeatureFlags is the registry of the features enabled on the node, both the wire features negotiated
+// with the peers, see Features, and the experimental flags, the subsystems query it by the names,
+// e.g. flags.Enabled("bls_qc"). The nil registry enables nothing.
+type FeatureFlags struct {
+	wire         Features
+	experimental map[string]bool
+}
+
+// NewFeatureFlags returns the registry of the wire features and the experimental flags,
+// the unknown flags are refused so that a typo doesn't leave a subsystem dark.
+func NewFeatureFlags(wire Features, experimental []string) (*FeatureFlags, error) {
+	f := &FeatureFlags{wire: wire, experimental: make(map[string]bool)}
+	for _, name := range experimental {
+		if !experimentalFlags[name] {
+			return nil, fmt.Errorf("unknown experimental flag: %s", name)
+		}
+		f.experimental[name] = true
+	}
+	return f, nil
+}
+
+// Enabled reports whether the wire feature or the experimental flag of the name is enabled.
+func (f *FeatureFlags) Enabled(name string) bool {
+	if f == nil {
+		return false
+	}
+	if f.experimental[name] {
+		return true
+	}
+	wire, err := ParseFeatures([]string{name})
+	return err == nil && f.wire.Has(wire)
+}
+
+// Experimental returns the enabled experimental flags in order, which are advertised in the handshake.
+func (f *FeatureFlags) Experimental() []string {
+	if f == nil {
+		return nil
+	}
+	names := make([]string, 0, len(f.experimental))
+	for name := range f.experimental {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Names returns the enabled wire features followed by the experimental flags, e.g. for /status.
+func (f *FeatureFlags) Names() []string {
+	if f == nil {
+		return nil
+	}
+	var names []string
+	for _, n := range featureNames {
+		if f.wire.Has(n.f) {
+			names = append(names, n.name)
+		}
+	}
+	return append(names, f.Experimental()...)
+}
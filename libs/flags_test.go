@@ -0,0 +1,29 @@
+package libs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	f, err := NewFeatureFlags(FeatureBLSQC, []string{FlagVoteRelay, FlagPipelining})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Enabled("bls_qc") || !f.Enabled(FlagPipelining) || !f.Enabled(FlagVoteRelay) {
+		t.Errorf("want the wire feature and the experimental flags enabled")
+	}
+	if f.Enabled("compression") || f.Enabled(FlagGossipBroadcast) || f.Enabled("unknown") {
+		t.Errorf("want the others disabled")
+	}
+	if names := f.Names(); !reflect.DeepEqual(names, []string{"bls_qc", FlagPipelining, FlagVoteRelay}) {
+		t.Errorf("unexpected names: %v", names)
+	}
+	if _, err := NewFeatureFlags(0, []string{"pipelinning"}); err == nil {
+		t.Errorf("unknown flag should be refused")
+	}
+	var none *FeatureFlags
+	if none.Enabled(FlagPipelining) || none.Names() != nil {
+		t.Errorf("the nil registry enables nothing")
+	}
+}
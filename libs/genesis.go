@@ -0,0 +1,120 @@
+package libs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+)
+
+// genesisVersion prefixes the binary encoding, it must be bumped once the encoding changes
+// so that the hash of a genesis is reproducible across the releases.
+// The genesis registering the validator keys is encoded by genesisKeysVersion, the others
+// keep the version 1 encoding so that their hashes don't change.
+const (
+	genesisVersion     = 1
+	genesisKeysVersion = 2
+)
+
+// ValidatorKey registers the consensus key of the validator ID along with the proof that
+// the validator holds its private key, both hex encoded, see crypto.ProvePossession.
+type ValidatorKey struct {
+	ID     string `json:"id" yaml:"id"`
+	Pubkey string `json:"pubkey" yaml:"pubkey"`
+	Proof  string `json:"proof" yaml:"proof"`
+}
+
+// Genesis is the initial state of a chain, validators starting from different ones
+// would silently split the chain, so its hash is checked in the p2p handshake.
+type Genesis struct {
+	ChainID    string   `json:"chain_id"`
+	Round      int64    `json:"round"`
+	StartID    string   `json:"start_id"`
+	StartValue string   `json:"start_value"`
+	Validators []string `json:"validators"`
+	// ValidatorKeys are verified by their proofs of possession before the chain starts,
+	// the validators without a registered key are free to sign by any key.
+	ValidatorKeys []ValidatorKey `json:"validator_keys,omitempty"`
+}
+
+func GenesisFromConfig(c *ChainConfig) *Genesis {
+	return &Genesis{
+		ChainID:       c.Chainid,
+		Round:         int64(c.Round),
+		StartID:       c.Startk,
+		StartValue:    c.Startv,
+		Validators:    c.Validators,
+		ValidatorKeys: c.Validatorkeys,
+	}
+}
+
+// canonical compacts the start value if it's json, e.g. the serialized root qc,
+// so that the whitespaces of a hand-edited file don't change the hash.
+// NOTE: the order of the validators is kept since it decides the leaders.
+func (g *Genesis) canonical() Genesis {
+	c := *g
+	var buf bytes.Buffer
+	if json.Valid([]byte(g.StartValue)) && json.Compact(&buf, []byte(g.StartValue)) == nil {
+		c.StartValue = buf.String()
+	}
+	if c.Validators == nil {
+		c.Validators = []string{}
+	}
+	return c
+}
+
+// MarshalCanonicalJSON returns the canonical json of the genesis, which is meant to be shared.
+func (g *Genesis) MarshalCanonicalJSON() ([]byte, error) {
+	return json.Marshal(g.canonical())
+}
+
+// MarshalBinary returns the canonical binary encoding of the genesis:
+// the version byte followed by the fields in order, strings are prefixed by their uvarint length.
+func (g *Genesis) MarshalBinary() ([]byte, error) {
+	c := g.canonical()
+	var buf bytes.Buffer
+	if len(c.ValidatorKeys) > 0 {
+		buf.WriteByte(genesisKeysVersion)
+	} else {
+		buf.WriteByte(genesisVersion)
+	}
+	writeString(&buf, c.ChainID)
+	writeVarint(&buf, c.Round)
+	writeString(&buf, c.StartID)
+	writeString(&buf, c.StartValue)
+	writeUvarint(&buf, uint64(len(c.Validators)))
+	for _, v := range c.Validators {
+		writeString(&buf, v)
+	}
+	if len(c.ValidatorKeys) > 0 {
+		writeUvarint(&buf, uint64(len(c.ValidatorKeys)))
+		for _, k := range c.ValidatorKeys {
+			writeString(&buf, k.ID)
+			writeString(&buf, k.Pubkey)
+			writeString(&buf, k.Proof)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Hash is the sha256 of the binary encoding.
+func (g *Genesis) Hash() []byte {
+	bz, _ := g.MarshalBinary()
+	sum := sha256.Sum256(bz)
+	return sum[:]
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	writeUvarint(buf, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func writeVarint(buf *bytes.Buffer, v int64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutVarint(b[:], v)])
+}
@@ -0,0 +1,83 @@
+package libs
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+func TestGenesisHash(t *testing.T) {
+	g := &Genesis{
+		ChainID:    "main",
+		Round:      0,
+		StartID:    "lets_run_hotstuff",
+		StartValue: `{"round":0,"id":"bGV0c19ydW5faG90c3R1ZmY=","sender":"","signs":{}}`,
+		Validators: []string{"QmA", "QmB"},
+	}
+	hash := g.Hash()
+
+	// the whitespaces of the start value are ignored
+	edited := *g
+	edited.StartValue = "{ \"round\": 0,\n \"id\": \"bGV0c19ydW5faG90c3R1ZmY=\", \"sender\": \"\", \"signs\": {} }"
+	if !bytes.Equal(edited.Hash(), hash) {
+		t.Errorf("formatting should not change the hash")
+	}
+	// the canonical json reproduces the hash
+	bz, err := edited.MarshalCanonicalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Genesis
+	if err := json.Unmarshal(bz, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded.Hash(), hash) {
+		t.Errorf("canonical json should reproduce the hash")
+	}
+
+	// the order of the validators decides the leaders
+	reordered := *g
+	reordered.Validators = []string{"QmB", "QmA"}
+	if bytes.Equal(reordered.Hash(), hash) {
+		t.Errorf("reordered validators should change the hash")
+	}
+	// field boundaries are unambiguous
+	shifted := *g
+	shifted.ChainID, shifted.StartID = "mainlets_run_hotstuff", ""
+	if bytes.Equal(shifted.Hash(), hash) {
+		t.Errorf("shifted fields should change the hash")
+	}
+	// empty and nil validators are the same genesis
+	if !bytes.Equal((&Genesis{}).Hash(), (&Genesis{Validators: []string{}}).Hash()) {
+		t.Errorf("nil validators should be canonicalized")
+	}
+}
+
+// TestGenesisHashStable guards the encoding, the hash of a genesis must never change across the releases.
+func TestGenesisHashStable(t *testing.T) {
+	g := &Genesis{ChainID: "main", StartID: "k", StartValue: "v", Validators: []string{"QmA"}}
+	bz, _ := g.MarshalBinary()
+	if want := "01046d61696e00016b01760103516d41"; hex.EncodeToString(bz) != want {
+		t.Errorf("binary encoding changed, want: %s, has: %x", want, bz)
+	}
+}
+
+// TestGenesisKeysHash checks the registered keys are covered by the hash.
+func TestGenesisKeysHash(t *testing.T) {
+	g := &Genesis{ChainID: "main", StartID: "k", StartValue: "v", Validators: []string{"QmA"}}
+	keyed := *g
+	keyed.ValidatorKeys = []ValidatorKey{{ID: "QmA", Pubkey: "04aa", Proof: "3045"}}
+	bz, _ := keyed.MarshalBinary()
+	if bz[0] != genesisKeysVersion {
+		t.Errorf("want version %d, has: %d", genesisKeysVersion, bz[0])
+	}
+	if bytes.Equal(keyed.Hash(), g.Hash()) {
+		t.Errorf("registered keys should change the hash")
+	}
+	rotated := keyed
+	rotated.ValidatorKeys = []ValidatorKey{{ID: "QmA", Pubkey: "04bb", Proof: "3045"}}
+	if bytes.Equal(rotated.Hash(), keyed.Hash()) {
+		t.Errorf("another key should change the hash")
+	}
+}
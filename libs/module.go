@@ -0,0 +1,158 @@
+package libs
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ModuleID enumerates the modules of the node, each is registered with its name, e.g. ConsensusModule, and the range
+// of the base channels it owns, see ChainChannel. The switch only accepts the reactors of the registered modules.
+type ModuleID uint8
+
+const (
+	ModuleConsensus ModuleID = iota + 1
+	ModuleMempool
+	ModuleObserver
+	ModuleJoin
+	// ModuleP2P owns no channel, it's the module of the events of the switch.
+	ModuleP2P
+)
+
+// ChannelRange is the range of the base channels owned by a module, both ends included,
+// the empty range has Last below First.
+type ChannelRange struct {
+	First int32
+	Last  int32
+}
+
+// Contains reports whether the base channel of the channel, i.e. its low 8 bits, is in the range.
+func (r ChannelRange) Contains(chID int32) bool {
+	base := chID & 0xff
+	return base >= r.First && base <= r.Last
+}
+
+func (r ChannelRange) overlaps(o ChannelRange) bool {
+	return r.First <= r.Last && o.First <= o.Last && r.First <= o.Last && o.First <= r.Last
+}
+
+type moduleInfo struct {
+	name     string
+	channels ChannelRange
+}
+
+var (
+	modulesMtx sync.RWMutex
+	// modules are indexed by the ids, the index 0 is the invalid id
+	modules = []moduleInfo{
+		{},
+		ModuleConsensus: {ConsensusModule, ChannelRange{ConsensusChannel, ConsensusChannel}},
+		ModuleMempool:   {MempoolModule, ChannelRange{MempoolChannel, MempoolChannel}},
+		ModuleObserver:  {ObserverModule, ChannelRange{ObserverChannel, ObserverChannel}},
+		ModuleJoin:      {JoinModule, ChannelRange{JoinChannel, JoinChannel}},
+		ModuleP2P:       {P2PModule, ChannelRange{0, -1}},
+	}
+)
+
+// RegisterModule registers a module out of the tree, e.g. of the embedding application, with its name and the range
+// of its base channels, which mustn't be taken by another module. It's expected to be invoked on the init.
+func RegisterModule(name string, channels ChannelRange) (ModuleID, error) {
+	modulesMtx.Lock()
+	defer modulesMtx.Unlock()
+
+	if name == "" || strings.Contains(name, "/") {
+		return 0, fmt.Errorf("invalid module name: %q", name)
+	}
+	if channels.First < 0 || channels.Last > 0xff {
+		return 0, fmt.Errorf("invalid channels of the module %s: [%d, %d]", name, channels.First, channels.Last)
+	}
+	if len(modules) > 0xff {
+		return 0, fmt.Errorf("too many modules, module: %s", name)
+	}
+	for _, m := range modules[1:] {
+		if m.name == name {
+			return 0, fmt.Errorf("module has been registered before, %s", name)
+		}
+		if m.channels.overlaps(channels) {
+			return 0, fmt.Errorf("channels of the module %s overlap the ones of %s", name, m.name)
+		}
+	}
+	modules = append(modules, moduleInfo{name: name, channels: channels})
+	return ModuleID(len(modules) - 1), nil
+}
+
+func (m ModuleID) info() (moduleInfo, bool) {
+	modulesMtx.RLock()
+	defer modulesMtx.RUnlock()
+
+	if m == 0 || int(m) >= len(modules) {
+		return moduleInfo{}, false
+	}
+	return modules[m], true
+}
+
+// String returns the name of the module, which is also its name on the wire.
+func (m ModuleID) String() string {
+	info, ok := m.info()
+	if !ok {
+		return fmt.Sprintf("module(%d)", uint8(m))
+	}
+	return info.name
+}
+
+// Channels returns the range of the base channels owned by the module, empty for an unknown one.
+func (m ModuleID) Channels() ChannelRange {
+	info, ok := m.info()
+	if !ok {
+		return ChannelRange{0, -1}
+	}
+	return info.channels
+}
+
+// Name returns the name of the module on the chain, under which its reactor is registered on the switch,
+// see ChainModule and Switch.Reactor.
+func (m ModuleID) Name(chainID string) string {
+	return ChainModule(chainID, m.String())
+}
+
+// Modules returns the registered modules in the order of their ids.
+func Modules() []ModuleID {
+	modulesMtx.RLock()
+	defer modulesMtx.RUnlock()
+
+	ids := make([]ModuleID, 0, len(modules)-1)
+	for id := 1; id < len(modules); id++ {
+		ids = append(ids, ModuleID(id))
+	}
+	return ids
+}
+
+// ParseModule parses the name of a module on a chain, see ModuleID.Name, the module must be registered.
+func ParseModule(name string) (ModuleID, string, error) {
+	var chainID string
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		chainID, name = name[:i], name[i+1:]
+	}
+	modulesMtx.RLock()
+	defer modulesMtx.RUnlock()
+
+	for id := 1; id < len(modules); id++ {
+		if modules[id].name == name {
+			return ModuleID(id), chainID, nil
+		}
+	}
+	return 0, "", fmt.Errorf("unknown module: %s", name)
+}
+
+// ModuleOfChannel returns the module owning the base channel of the channel.
+func ModuleOfChannel(chID int32) (ModuleID, bool) {
+	modulesMtx.RLock()
+	defer modulesMtx.RUnlock()
+
+	for id := 1; id < len(modules); id++ {
+		if modules[id].channels.Contains(chID) {
+			return ModuleID(id), true
+		}
+	}
+	return 0, false
+}
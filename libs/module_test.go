@@ -0,0 +1,77 @@
+package libs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestModules(t *testing.T) {
+	registered := append([]moduleInfo(nil), modules...)
+	t.Cleanup(func() { modules = registered })
+
+	if ModuleMempool.String() != MempoolModule || ModuleID(0).String() != "module(0)" {
+		t.Errorf("unexpected names: %s, %s", ModuleMempool, ModuleID(0))
+	}
+	if ModuleMempool.Name("side") != "side/"+MempoolModule || ModuleMempool.Name("") != MempoolModule {
+		t.Errorf("unexpected name on the chain: %s", ModuleMempool.Name("side"))
+	}
+	m, chainID, err := ParseModule(ModuleJoin.Name("a/b"))
+	if err != nil || m != ModuleJoin || chainID != "a/b" {
+		t.Errorf("unexpected module: %s, chain: %s, err: %v", m, chainID, err)
+	}
+	if _, _, err := ParseModule("side/sync"); err == nil {
+		t.Error("want the unknown module refused")
+	}
+	if m, ok := ModuleOfChannel(ChainChannel("side", ObserverChannel)); !ok || m != ModuleObserver {
+		t.Errorf("want the observer owning its channel on the chain, has: %s", m)
+	}
+	if ModuleP2P.Channels().Contains(ConsensusChannel) {
+		t.Error("want the p2p module owning no channel")
+	}
+
+	sync, err := RegisterModule("sync", ChannelRange{16, 31})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := ModuleOfChannel(ChainChannel("side", 20)); !ok || m != sync || sync.String() != "sync" {
+		t.Errorf("want the registered module owning its channels, has: %s", m)
+	}
+	if ids := Modules(); !reflect.DeepEqual(ids, []ModuleID{ModuleConsensus, ModuleMempool, ModuleObserver, ModuleJoin, ModuleP2P, sync}) {
+		t.Errorf("unexpected modules: %v", ids)
+	}
+	for _, c := range []struct {
+		name     string
+		channels ChannelRange
+	}{
+		{"sync", ChannelRange{32, 33}},
+		{"blob", ChannelRange{31, 40}},
+		{"blob", ChannelRange{MempoolChannel, MempoolChannel}},
+		{"a/b", ChannelRange{64, 64}},
+		{"blob", ChannelRange{64, 256}},
+	} {
+		if _, err := RegisterModule(c.name, c.channels); err == nil {
+			t.Errorf("want the module refused, name: %s, channels: %v", c.name, c.channels)
+		}
+	}
+}
+
+func TestChainChannel(t *testing.T) {
+	if ChainChannel("", MempoolChannel) != MempoolChannel || ChainModule("", ConsensusModule) != ConsensusModule {
+		t.Error("want the default chain keeping the base channels and modules")
+	}
+	seen := make(map[int32]string)
+	for _, chainID := range []string{"a", "b", "side", "side2", "test"} {
+		ch := ChainChannel(chainID, ObserverChannel)
+		// the channel is the same on all the nodes, and keeps its base channel in the low bits
+		if ch != ChainChannel(chainID, ObserverChannel) || ch&0xff != ObserverChannel || ch < 0 {
+			t.Errorf("unexpected channel of the chain %s: %d", chainID, ch)
+		}
+		if other, ok := seen[ch]; ok {
+			t.Errorf("want the chains on distinct channels, %s and %s: %d", chainID, other, ch)
+		}
+		seen[ch] = chainID
+		if ChainChannel(chainID, ConsensusChannel)>>8 != ch>>8 {
+			t.Errorf("want the channels of the chain %s sharing the namespace", chainID)
+		}
+	}
+}
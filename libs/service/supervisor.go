@@ -0,0 +1,239 @@
+// Package service runs the goroutine-based services of the modules, e.g. the peer loops,
+// the reactors and the wal flusher, under a supervisor which restarts the failed ones by
+// their policies and reports the failures.
+package service
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+const (
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// Restart decides whether a service is run again once it has returned.
+type Restart int
+
+const (
+	// Never runs the service once, it's the policy of the zero Policy.
+	Never Restart = iota
+	// OnFailure runs the service again right after it fails.
+	OnFailure
+	// Backoff runs the service again after it fails, the delay doubles on every failure in a row.
+	Backoff
+)
+
+func (r Restart) String() string {
+	switch r {
+	case Never:
+		return "never"
+	case OnFailure:
+		return "on-failure"
+	case Backoff:
+		return "backoff"
+	}
+	return fmt.Sprintf("restart(%d)", int(r))
+}
+
+// Policy is the restart policy of a service, a service returning nil has finished and is never restarted.
+type Policy struct {
+	Restart Restart
+	// MaxRestarts caps the restarts of the service, they are unlimited if it's not positive.
+	MaxRestarts int
+	// MinBackoff and MaxBackoff bound the delay of the Backoff policy, 100ms and 30s by default.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+func (p Policy) backoff(failures int) time.Duration {
+	if p.Restart != Backoff {
+		return 0
+	}
+	min, max := p.MinBackoff, p.MaxBackoff
+	if min <= 0 {
+		min = defaultMinBackoff
+	}
+	if max < min {
+		max = defaultMaxBackoff
+		if max < min {
+			max = min
+		}
+	}
+	d := min
+	for i := 1; i < failures && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// Failure is the error a service has exited with, the service isn't restarted anymore.
+type Failure struct {
+	Name     string
+	Restarts int
+	Err      error
+}
+
+func (f Failure) Error() string {
+	return fmt.Sprintf("service %s fails after %d restarts, err: %v", f.Name, f.Restarts, f.Err)
+}
+
+func (f Failure) Unwrap() error {
+	return f.Err
+}
+
+// Failures aggregates the failures of the services of a supervisor.
+type Failures []Failure
+
+func (fs Failures) Error() string {
+	msgs := make([]string, 0, len(fs))
+	for _, f := range fs {
+		msgs = append(msgs, f.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Supervisor runs the services in their own goroutines, the zero value is ready to use
+// and replaces a sync.WaitGroup counting the routines of a module.
+type Supervisor struct {
+	mtx      sync.Mutex
+	routines sync.WaitGroup
+	// quit is closed by Stop, it aborts the pending restarts
+	quit     chan struct{}
+	stopped  bool
+	failures Failures
+	log      libs.Logger
+}
+
+func NewSupervisor(logger libs.Logger) *Supervisor {
+	s := &Supervisor{}
+	s.SetLogger(logger)
+	return s
+}
+
+// SetLogger should be invoked before the first service is run.
+func (s *Supervisor) SetLogger(logger libs.Logger) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.log = logger
+}
+
+func (s *Supervisor) lazyInit() {
+	if s.quit == nil {
+		s.quit = make(chan struct{})
+	}
+	if s.log == nil {
+		s.log = logs.NewLogger()
+	}
+}
+
+// Go runs the service by the policy, it returns false without running it once the supervisor has stopped.
+// A panic of the service is recovered and taken as its failure.
+func (s *Supervisor) Go(name string, p Policy, service func() error) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.lazyInit()
+	if s.stopped {
+		return false
+	}
+	s.routines.Add(1)
+	log := s.log
+	go func() {
+		defer s.routines.Done()
+		s.supervise(name, p, service, log)
+	}()
+	return true
+}
+
+// Spawn runs the routine once, it's the plain `go routine()` waited by Wait.
+func (s *Supervisor) Spawn(name string, routine func()) bool {
+	return s.Go(name, Policy{}, func() error {
+		routine()
+		return nil
+	})
+}
+
+func (s *Supervisor) supervise(name string, p Policy, service func() error, log libs.Logger) {
+	restarts := 0
+	for {
+		err := run(name, service, log)
+		if err == nil {
+			return
+		}
+		if p.Restart == Never || (p.MaxRestarts > 0 && restarts >= p.MaxRestarts) {
+			s.fail(log, Failure{Name: name, Restarts: restarts, Err: err})
+			return
+		}
+		restarts++
+		delay := p.backoff(restarts)
+		log.Warn("service fails @ service.supervise, name: %s, policy: %s, restarts: %d, delay: %v, err: %v",
+			name, p.Restart, restarts, delay, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-s.quit:
+			timer.Stop()
+			s.fail(log, Failure{Name: name, Restarts: restarts - 1, Err: err})
+			return
+		}
+	}
+}
+
+func run(name string, service func() error, log libs.Logger) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("service panics @ service.run, name: %s, err: %v, stack: %s", name, r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return service()
+}
+
+func (s *Supervisor) fail(log libs.Logger, f Failure) {
+	log.Error("service exits @ service.fail, %v", f)
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.failures = append(s.failures, f)
+}
+
+// Stop refuses the new services and aborts the pending restarts, the running services
+// are stopped by their modules, e.g. by closing their quit channels.
+func (s *Supervisor) Stop() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.lazyInit()
+	if s.stopped {
+		return
+	}
+	s.stopped = true
+	close(s.quit)
+}
+
+// Wait blocks until all the services have exited and returns their failures.
+func (s *Supervisor) Wait() error {
+	s.routines.Wait()
+	return s.Err()
+}
+
+// Err returns the failures of the services which have exited so far, nil if there is none.
+func (s *Supervisor) Err() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if len(s.failures) == 0 {
+		return nil
+	}
+	return append(Failures(nil), s.failures...)
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errBroken = errors.New("broken")
+
+func TestSupervisorRestart(t *testing.T) {
+	var s Supervisor
+	var once, onFailure, backoff int32
+
+	s.Go("never", Policy{}, func() error {
+		atomic.AddInt32(&once, 1)
+		return errBroken
+	})
+	s.Go("on-failure", Policy{Restart: OnFailure, MaxRestarts: 2}, func() error {
+		atomic.AddInt32(&onFailure, 1)
+		panic("boom")
+	})
+	s.Go("backoff", Policy{Restart: Backoff, MinBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}, func() error {
+		// fails twice and then finishes
+		if atomic.AddInt32(&backoff, 1) < 3 {
+			return errBroken
+		}
+		return nil
+	})
+	s.Spawn("done", func() {})
+
+	err := s.Wait()
+	if once != 1 || onFailure != 3 || backoff != 3 {
+		t.Errorf("unexpected runs, never: %d, on-failure: %d, backoff: %d", once, onFailure, backoff)
+	}
+	var fs Failures
+	if !errors.As(err, &fs) || len(fs) != 2 {
+		t.Fatalf("want the failures of never and on-failure, has: %v", err)
+	}
+	for _, f := range fs {
+		switch f.Name {
+		case "never":
+			if f.Restarts != 0 || !errors.Is(f, errBroken) {
+				t.Errorf("unexpected failure: %v", f)
+			}
+		case "on-failure":
+			if f.Restarts != 2 {
+				t.Errorf("unexpected failure: %v", f)
+			}
+		default:
+			t.Errorf("unexpected failure: %v", f)
+		}
+	}
+}
+
+func TestSupervisorStop(t *testing.T) {
+	s := NewSupervisor(nil)
+	var runs int32
+	s.Go("flapping", Policy{Restart: Backoff, MinBackoff: time.Hour}, func() error {
+		atomic.AddInt32(&runs, 1)
+		return errBroken
+	})
+	// the pending restart is aborted
+	time.Sleep(10 * time.Millisecond)
+	s.Stop()
+	waited := make(chan error)
+	go func() { waited <- s.Wait() }()
+	select {
+	case err := <-waited:
+		if !errors.Is(err.(Failures)[0], errBroken) {
+			t.Errorf("want the broken err, has: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stop should abort the restart")
+	}
+	if runs != 1 {
+		t.Errorf("want 1 run, has: %d", runs)
+	}
+	if s.Spawn("late", func() {}) {
+		t.Errorf("stopped supervisor should refuse new services")
+	}
+}
+
+func TestPolicyBackoff(t *testing.T) {
+	p := Policy{Restart: Backoff, MinBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	for failures, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if has := p.backoff(failures); has != want {
+			t.Errorf("failures %d, want: %v, has: %v", failures, want, has)
+		}
+	}
+	if d := (Policy{Restart: OnFailure}).backoff(3); d != 0 {
+		t.Errorf("on-failure restarts at once, has: %v", d)
+	}
+}
@@ -0,0 +1,61 @@
+package mempool
+
+import (
+	"container/list"
+	"sync"
+)
+
+// TxCache is a fixed-size LRU cache of tx keys, the oldest key is evicted when it's full.
+type TxCache struct {
+	size  int
+	cache map[string]*list.Element
+	list  *list.List
+
+	mtx sync.Mutex
+}
+
+func NewTxCache(size int) *TxCache {
+	return &TxCache{
+		size:  size,
+		cache: make(map[string]*list.Element, size),
+		list:  list.New(),
+	}
+}
+
+// Push adds the key into the cache, it returns false if the key has been cached before.
+func (c *TxCache) Push(key string) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if e, ok := c.cache[key]; ok {
+		c.list.MoveToBack(e)
+		return false
+	}
+	if c.list.Len() >= c.size {
+		front := c.list.Front()
+		if front != nil {
+			delete(c.cache, front.Value.(string))
+			c.list.Remove(front)
+		}
+	}
+	c.cache[key] = c.list.PushBack(key)
+	return true
+}
+
+func (c *TxCache) Has(key string) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	_, ok := c.cache[key]
+	return ok
+}
+
+func (c *TxCache) Remove(key string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if e, ok := c.cache[key]; ok {
+		delete(c.cache, key)
+		c.list.Remove(e)
+	}
+}
@@ -0,0 +1,90 @@
+package mempool
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrTxRejected = errors.New("tx is rejected by the application")
+)
+
+// CheckTxFunc is the admission check of the application, e.g. the signature, the balance and
+// the nonce of the tx, a non-nil error rejects the tx. recheck is true when the pending tx is
+// checked again after a commit, the application may skip the stateless checks then.
+// It's invoked concurrently in the async mode.
+type CheckTxFunc func(tx Tx, recheck bool) error
+
+// SetCheckTx sets the admission check of the application, see Config.CheckTx,
+// it should be invoked before any tx is checked, e.g. before node.Start().
+func (m *DefaultMempool) SetCheckTx(f CheckTxFunc, async bool) {
+	m.cfg.CheckTx, m.cfg.AsyncCheck = f, async
+}
+
+// appCheck admits the tx once the application accepts it, the result is only logged in the async mode.
+func (m *DefaultMempool) appCheck(mt *mempoolTx) error {
+	m.mtx.Lock()
+	if _, ok := m.txsMap[mt.key]; ok {
+		m.mtx.Unlock()
+		return ErrTxInMempool
+	}
+	if _, ok := m.checking[mt.key]; ok {
+		m.mtx.Unlock()
+		return ErrTxInMempool
+	}
+	m.checking[mt.key] = struct{}{}
+	m.mtx.Unlock()
+
+	check := func() error {
+		defer func() {
+			m.mtx.Lock()
+			delete(m.checking, mt.key)
+			m.mtx.Unlock()
+		}()
+		if err := m.cfg.CheckTx(mt.tx, false); err != nil {
+			return fmt.Errorf("%w, err: %v", ErrTxRejected, err)
+		}
+		return m.admit(mt)
+	}
+	if !m.cfg.AsyncCheck {
+		return check()
+	}
+	go func() {
+		if err := check(); err != nil {
+			m.log.Warn("drop tx @ mempool.CheckTx, tx: %s, err: %v", mt.key, err)
+		}
+	}()
+	return nil
+}
+
+// recheck purges the pending txs which have turned invalid by the committed block,
+// the txs admitted during the recheck are checked after the next commit.
+func (m *DefaultMempool) recheck(round int64) {
+	m.mtx.Lock()
+	pending := make([]*mempoolTx, 0, m.txs.Len())
+	for e := m.txs.Front(); e != nil; e = e.Next() {
+		pending = append(pending, e.Value.(*mempoolTx))
+	}
+	m.mtx.Unlock()
+
+	var invalid []*mempoolTx
+	for _, mt := range pending {
+		if err := m.cfg.CheckTx(mt.tx, true); err != nil {
+			invalid = append(invalid, mt)
+		}
+	}
+	if len(invalid) == 0 {
+		return
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for _, mt := range invalid {
+		// the tx may have been committed or replaced meanwhile
+		if e, ok := m.txsMap[mt.key]; ok && e.Value.(*mempoolTx) == mt {
+			m.remove(e)
+		}
+	}
+	m.log.Info("mempool rechecked @ mempool.recheck, round: %d, checked: %d, purged: %d, size: %d",
+		round, len(pending), len(invalid), m.txs.Len())
+}
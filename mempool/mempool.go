@@ -0,0 +1,320 @@
+package mempool
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+const (
+	defaultMaxTxs             = 5000
+	defaultCommittedCacheSize = 10000
+)
+
+var (
+	ErrTxInMempool = errors.New("tx already exists in mempool")
+	ErrTxCommitted = errors.New("tx has been committed")
+	ErrMempoolFull = errors.New("mempool is full")
+	ErrEmptyTx     = errors.New("tx is empty")
+	ErrTxTooLarge  = errors.New("tx is too large")
+	// ErrTxUnderpriced is returned if the tx cannot replace the pending tx of the same sender and nonce.
+	ErrTxUnderpriced = errors.New("tx priority is too low to replace the pending one")
+	ErrInvalidTxInfo = errors.New("invalid tx info")
+)
+
+// Tx is an arbitrary byte array carried by the proposal,
+// which can be the command in database or the transaction in blockchain.
+type Tx []byte
+
+// Key is the unique identity of the tx.
+func (tx Tx) Key() string {
+	return libs.GetSum(tx)
+}
+
+// TxInfo orders the txs in the mempool, the higher priority is packed first,
+// e.g. the fee of the tx. A tx with the same Sender and Nonce as a pending one replaces it
+// only with a higher priority, an empty Sender disables the replacement.
+type TxInfo struct {
+	Priority int64
+	Sender   string
+	Nonce    uint64
+}
+
+// AdmitHook is invoked once a tx is admitted into the mempool, e.g. to gossip it.
+type AdmitHook func(tx Tx)
+
+// TxInfoFunc derives the info from the tx, e.g. by decoding the fee of the application tx.
+type TxInfoFunc func(tx Tx) (TxInfo, error)
+
+// Mempool holds the txs waiting to be packed into a proposal.
+type Mempool interface {
+	// CheckTx admits a new tx into the mempool, its info is derived by Config.TxInfo if set.
+	CheckTx(tx Tx) error
+	// CheckTxWithInfo admits a new tx with the info supplied by the user.
+	CheckTxWithInfo(tx Tx, info TxInfo) error
+	// ReapMaxTxs returns at most max txs by the priority, the txs of the same priority
+	// are in the FIFO order, max < 0 means all of them.
+	ReapMaxTxs(max int) []Tx
+	// Update removes the committed txs and remembers them,
+	// so that they won't be packed again by any other branch.
+	Update(round int64, txs []Tx) error
+	// Committed reports whether the tx has been committed recently.
+	Committed(tx Tx) bool
+	Size() int
+}
+
+// GossipMempool is a mempool whose admitted txs are gossiped by the Reactor.
+type GossipMempool interface {
+	Mempool
+	// OnAdmit registers a hook invoked after every admitted tx.
+	OnAdmit(h AdmitHook)
+}
+
+var _ GossipMempool = (*DefaultMempool)(nil)
+
+type mempoolTx struct {
+	tx   Tx
+	key  string
+	info TxInfo
+}
+
+type senderNonce struct {
+	sender string
+	nonce  uint64
+}
+
+// DefaultMempool keeps the txs sorted by the priority, the lowest one is evicted
+// for a higher one once it's full.
+type DefaultMempool struct {
+	cfg *Config
+
+	txs       *list.List
+	txsMap    map[string]*list.Element
+	senders   map[senderNonce]*list.Element
+	committed *TxCache
+	height    int64
+	// the txs being checked by the application, not stored yet
+	checking map[string]struct{}
+	hooks    []AdmitHook
+
+	mtx sync.Mutex
+	log libs.Logger
+}
+
+func NewDefaultMempool(cfg *Config, logger libs.Logger) *DefaultMempool {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	if cfg.MaxTxs <= 0 {
+		cfg.MaxTxs = defaultMaxTxs
+	}
+	if cfg.CommittedCacheSize <= 0 {
+		cfg.CommittedCacheSize = defaultCommittedCacheSize
+	}
+	if logger == nil {
+		logger = logs.NewLogger()
+	}
+	return &DefaultMempool{
+		cfg:       cfg,
+		txs:       list.New(),
+		txsMap:    make(map[string]*list.Element),
+		senders:   make(map[senderNonce]*list.Element),
+		checking:  make(map[string]struct{}),
+		committed: NewTxCache(cfg.CommittedCacheSize),
+		log:       logger,
+	}
+}
+
+func (m *DefaultMempool) CheckTx(tx Tx) error {
+	var info TxInfo
+	if m.cfg.TxInfo != nil && len(tx) > 0 {
+		var err error
+		if info, err = m.cfg.TxInfo(tx); err != nil {
+			return fmt.Errorf("%w, err: %v", ErrInvalidTxInfo, err)
+		}
+	}
+	return m.CheckTxWithInfo(tx, info)
+}
+
+func (m *DefaultMempool) CheckTxWithInfo(tx Tx, info TxInfo) error {
+	if len(tx) == 0 {
+		return ErrEmptyTx
+	}
+	if m.cfg.MaxTxBytes > 0 && len(tx) > m.cfg.MaxTxBytes {
+		return fmt.Errorf("%w, tx_bytes: %d, max: %d", ErrTxTooLarge, len(tx), m.cfg.MaxTxBytes)
+	}
+	key := tx.Key()
+	if m.committed.Has(key) {
+		return ErrTxCommitted
+	}
+	if m.cfg.CheckTx == nil {
+		return m.admit(&mempoolTx{tx: tx, key: key, info: info})
+	}
+	return m.appCheck(&mempoolTx{tx: tx, key: key, info: info})
+}
+
+// admit stores the tx which has passed all the checks, and notifies the hooks.
+func (m *DefaultMempool) admit(mt *mempoolTx) error {
+	if err := m.store(mt); err != nil {
+		return err
+	}
+	m.mtx.Lock()
+	hooks := m.hooks
+	m.mtx.Unlock()
+	for _, h := range hooks {
+		h(mt.tx)
+	}
+	return nil
+}
+
+func (m *DefaultMempool) store(mt *mempoolTx) error {
+	tx, key, info := mt.tx, mt.key, mt.info
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if _, ok := m.txsMap[key]; ok {
+		return ErrTxInMempool
+	}
+	sn := senderNonce{info.Sender, info.Nonce}
+	if e, ok := m.senders[sn]; ok && info.Sender != "" {
+		old := e.Value.(*mempoolTx)
+		if info.Priority <= old.info.Priority {
+			return fmt.Errorf("%w, sender: %s, nonce: %d, priority: %d, pending: %d",
+				ErrTxUnderpriced, info.Sender, info.Nonce, info.Priority, old.info.Priority)
+		}
+		m.remove(e)
+		m.log.Info("tx replaced @ mempool.CheckTx, sender: %s, nonce: %d, old: %s, new: %s", info.Sender, info.Nonce, old.key, key)
+	}
+	if m.txs.Len() >= m.cfg.MaxTxs {
+		lowest := m.txs.Back()
+		if lowest == nil || lowest.Value.(*mempoolTx).info.Priority >= info.Priority {
+			return ErrMempoolFull
+		}
+		m.remove(lowest)
+	}
+	m.insert(&mempoolTx{tx: tx, key: key, info: info})
+	return nil
+}
+
+// insert puts the tx behind the txs of the same or higher priority.
+func (m *DefaultMempool) insert(mt *mempoolTx) {
+	mark := m.txs.Back()
+	for mark != nil && mark.Value.(*mempoolTx).info.Priority < mt.info.Priority {
+		mark = mark.Prev()
+	}
+	var e *list.Element
+	if mark == nil {
+		e = m.txs.PushFront(mt)
+	} else {
+		e = m.txs.InsertAfter(mt, mark)
+	}
+	m.txsMap[mt.key] = e
+	if mt.info.Sender != "" {
+		m.senders[senderNonce{mt.info.Sender, mt.info.Nonce}] = e
+	}
+}
+
+func (m *DefaultMempool) remove(e *list.Element) {
+	mt := m.txs.Remove(e).(*mempoolTx)
+	delete(m.txsMap, mt.key)
+	sn := senderNonce{mt.info.Sender, mt.info.Nonce}
+	if m.senders[sn] == e {
+		delete(m.senders, sn)
+	}
+}
+
+func (m *DefaultMempool) ReapMaxTxs(max int) []Tx {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	var txs []Tx
+	for e := m.txs.Front(); e != nil; e = e.Next() {
+		if max >= 0 && len(txs) >= max {
+			break
+		}
+		txs = append(txs, e.Value.(*mempoolTx).tx)
+	}
+	return txs
+}
+
+func (m *DefaultMempool) Update(round int64, txs []Tx) error {
+	m.update(round, txs)
+	if m.cfg.CheckTx == nil {
+		return nil
+	}
+	if m.cfg.AsyncCheck {
+		go m.recheck(round)
+	} else {
+		m.recheck(round)
+	}
+	return nil
+}
+
+func (m *DefaultMempool) update(round int64, txs []Tx) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for _, tx := range txs {
+		key := tx.Key()
+		m.committed.Push(key)
+		if e, ok := m.txsMap[key]; ok {
+			m.remove(e)
+			continue
+		}
+		// the nonce has been used by the tx committed from another mempool
+		if m.cfg.TxInfo == nil {
+			continue
+		}
+		if info, err := m.cfg.TxInfo(tx); err == nil && info.Sender != "" {
+			if e, ok := m.senders[senderNonce{info.Sender, info.Nonce}]; ok {
+				m.remove(e)
+			}
+		}
+	}
+	if round > m.height {
+		m.height = round
+	}
+	m.log.Info("mempool updated @ mempool.Update, round: %d, committed: %d, size: %d", round, len(txs), m.txs.Len())
+}
+
+// OnAdmit registers a hook invoked after every admitted tx.
+func (m *DefaultMempool) OnAdmit(h AdmitHook) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.hooks = append(m.hooks, h)
+}
+
+func (m *DefaultMempool) Committed(tx Tx) bool {
+	return m.committed.Has(tx.Key())
+}
+
+func (m *DefaultMempool) Size() int {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.txs.Len()
+}
+
+// ---------------------------------------------------------------------------------------------------
+type Config struct {
+	MaxTxs int
+	// CommittedCacheSize is the number of recently committed txs remembered by the mempool.
+	CommittedCacheSize int
+	// MaxTxBytes rejects the txs which can never be packed, it should be ConsensusConfig.MaxTxBytes,
+	// 0 means unlimited.
+	MaxTxBytes int
+	// TxInfo derives the priority, the sender and the nonce of the txs admitted by CheckTx,
+	// nil means the txs are of the same priority and never replaced.
+	TxInfo TxInfoFunc
+	// CheckTx is the admission check of the application, the tx is stored only if it passes,
+	// and the pending txs are checked again after every commit, nil admits every tx.
+	CheckTx CheckTxFunc
+	// AsyncCheck makes CheckTx of the mempool return before the application checks the tx,
+	// the rejected txs are dropped silently, and the re-checks don't block the commits.
+	AsyncCheck bool
+}
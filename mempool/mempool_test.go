@@ -0,0 +1,165 @@
+package mempool
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCommittedTxEviction(t *testing.T) {
+	m := NewDefaultMempool(&Config{MaxTxs: 10, CommittedCacheSize: 2}, nil)
+	for _, tx := range []Tx{Tx("a"), Tx("b"), Tx("c")} {
+		if err := m.CheckTx(tx); err != nil {
+			t.Fatalf("check tx err, err: %v", err)
+		}
+	}
+	if err := m.CheckTx(Tx("a")); err != ErrTxInMempool {
+		t.Errorf("want in mempool err, has: %v", err)
+	}
+
+	m.Update(1, []Tx{Tx("a"), Tx("b")})
+	if m.Size() != 1 {
+		t.Errorf("committed txs should be evicted, size: %d", m.Size())
+	}
+	if err := m.CheckTx(Tx("a")); err != ErrTxCommitted {
+		t.Errorf("want committed err, has: %v", err)
+	}
+
+	// the cache is bounded, the oldest committed tx is forgotten
+	m.Update(2, []Tx{Tx("c")})
+	if m.Committed(Tx("a")) || !m.Committed(Tx("c")) {
+		t.Errorf("committed cache should be a lru cache")
+	}
+}
+
+func TestOversizeTx(t *testing.T) {
+	m := NewDefaultMempool(&Config{MaxTxBytes: 4}, nil)
+	if err := m.CheckTx(Tx("abcd")); err != nil {
+		t.Fatalf("check tx err, err: %v", err)
+	}
+	if err := m.CheckTx(Tx("abcde")); !errors.Is(err, ErrTxTooLarge) {
+		t.Errorf("want too large err, has: %v", err)
+	}
+	if m.Size() != 1 {
+		t.Errorf("oversize tx should not be admitted, size: %d", m.Size())
+	}
+}
+
+func TestTxPriority(t *testing.T) {
+	m := NewDefaultMempool(&Config{MaxTxs: 4}, nil)
+	for _, c := range []struct {
+		tx   string
+		info TxInfo
+	}{
+		{"a", TxInfo{Priority: 1}},
+		{"b", TxInfo{Priority: 5}},
+		{"c", TxInfo{Priority: 1}},
+		{"d", TxInfo{Priority: 3, Sender: "alice", Nonce: 1}},
+	} {
+		if err := m.CheckTxWithInfo(Tx(c.tx), c.info); err != nil {
+			t.Fatalf("check tx err, tx: %s, err: %v", c.tx, err)
+		}
+	}
+	reaped := func() string {
+		var s string
+		for _, tx := range m.ReapMaxTxs(-1) {
+			s += string(tx)
+		}
+		return s
+	}
+	// by the priority, and the FIFO order for the same priority
+	if has := reaped(); has != "bdac" {
+		t.Errorf("want: bdac, has: %s", has)
+	}
+
+	// the same sender and nonce replaces the pending tx only with a higher priority
+	if err := m.CheckTxWithInfo(Tx("e"), TxInfo{Priority: 3, Sender: "alice", Nonce: 1}); !errors.Is(err, ErrTxUnderpriced) {
+		t.Errorf("want underpriced err, has: %v", err)
+	}
+	if err := m.CheckTxWithInfo(Tx("e"), TxInfo{Priority: 9, Sender: "alice", Nonce: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if has := reaped(); has != "ebac" {
+		t.Errorf("want: ebac, has: %s", has)
+	}
+
+	// the lowest tx is evicted for a higher one once it's full
+	if err := m.CheckTxWithInfo(Tx("f"), TxInfo{Priority: 1}); err != ErrMempoolFull {
+		t.Errorf("want full err, has: %v", err)
+	}
+	if err := m.CheckTxWithInfo(Tx("g"), TxInfo{Priority: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if has := reaped(); has != "ebga" {
+		t.Errorf("want: ebga, has: %s", has)
+	}
+
+	m.Update(1, []Tx{Tx("e")})
+	if err := m.CheckTxWithInfo(Tx("h"), TxInfo{Priority: 1, Sender: "alice", Nonce: 1}); err != nil {
+		t.Errorf("the committed tx should release the nonce, err: %v", err)
+	}
+}
+
+func TestTxInfoFunc(t *testing.T) {
+	// the fee is the first byte of the tx
+	m := NewDefaultMempool(&Config{TxInfo: func(tx Tx) (TxInfo, error) {
+		return TxInfo{Priority: int64(tx[0])}, nil
+	}}, nil)
+	for _, tx := range []string{"1a", "3b", "2c"} {
+		if err := m.CheckTx(Tx(tx)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if txs := m.ReapMaxTxs(2); len(txs) != 2 || string(txs[0]) != "3b" || string(txs[1]) != "2c" {
+		t.Errorf("unexpected txs: %q", txs)
+	}
+}
+
+func TestAppCheckTx(t *testing.T) {
+	// the app accepts the txs of the allowed prefix only
+	var mtx sync.Mutex
+	allowed := "a"
+	check := func(tx Tx, recheck bool) error {
+		mtx.Lock()
+		defer mtx.Unlock()
+		if !strings.HasPrefix(string(tx), allowed) {
+			return errors.New("not allowed")
+		}
+		return nil
+	}
+	m := NewDefaultMempool(nil, nil)
+	m.SetCheckTx(check, false)
+	if err := m.CheckTx(Tx("a1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.CheckTx(Tx("b1")); !errors.Is(err, ErrTxRejected) {
+		t.Errorf("want rejected err, has: %v", err)
+	}
+	m.CheckTx(Tx("a2"))
+
+	// a1 is committed and the rest turn invalid
+	mtx.Lock()
+	allowed = "b"
+	mtx.Unlock()
+	m.Update(1, []Tx{Tx("a1")})
+	if m.Size() != 0 {
+		t.Errorf("invalid txs should be purged, size: %d", m.Size())
+	}
+
+	async := NewDefaultMempool(nil, nil)
+	async.SetCheckTx(check, true)
+	for _, tx := range []string{"a3", "b2"} {
+		if err := async.CheckTx(Tx(tx)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for async.Size() != 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if txs := async.ReapMaxTxs(-1); len(txs) != 1 || string(txs[0]) != "b2" {
+		t.Errorf("only the valid tx should be admitted, has: %q", txs)
+	}
+}
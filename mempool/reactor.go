@@ -0,0 +1,136 @@
+package mempool
+
+import (
+	"sync"
+
+	"github.com/astaxie/beego/logs"
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+const (
+	// peerSeenCacheSize is the number of the latest txs remembered to have been seen by each peer.
+	peerSeenCacheSize = 10000
+)
+
+// Reactor gossips the admitted txs to the peers, the txs which a peer has sent to us or has been
+// sent before are never sent to it again, so that a full mesh doesn't echo every tx back and forth.
+type Reactor struct {
+	host    string
+	module  string
+	channel int32
+	mempool GossipMempool
+	sw      libs.Switch
+
+	// the connected peers and the txs they have seen, by the peer id
+	peers map[string]*gossipPeer
+	mtx   sync.Mutex
+	log   libs.Logger
+}
+
+type gossipPeer struct {
+	peer libs.Peer
+	seen *TxCache
+}
+
+func NewReactor(host string, chainID string, m GossipMempool, logger libs.Logger) *Reactor {
+	if logger == nil {
+		logger = logs.NewLogger()
+	}
+	r := &Reactor{
+		host:    host,
+		module:  libs.ChainModule(chainID, libs.MempoolModule),
+		channel: libs.ChainChannel(chainID, libs.MempoolChannel),
+		mempool: m,
+		peers:   make(map[string]*gossipPeer),
+		log:     logger,
+	}
+	m.OnAdmit(r.gossip)
+	return r
+}
+
+func (r *Reactor) Channel() int32 {
+	return r.channel
+}
+
+func (r *Reactor) SetSwitch(sw libs.Switch) {
+	r.sw = sw
+}
+
+func (r *Reactor) AddPeer(peer libs.Peer) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.peers[peer.PeerID()] = &gossipPeer{peer: peer, seen: NewTxCache(peerSeenCacheSize)}
+}
+
+func (r *Reactor) RemovePeer(peer libs.Peer, reason interface{}) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	delete(r.peers, peer.PeerID())
+}
+
+var _ libs.PeerReactor = (*Reactor)(nil)
+
+// HandleFunc drops the txs, they're admitted from the sender told by the transport, see HandleFuncFrom,
+// the one claimed by the msg could mark the txs seen by another peer and keep them from it.
+func (r *Reactor) HandleFunc(chID int32, msgBytes []byte) {
+	if chID == r.channel {
+		r.log.Warn("txs without sender dropped @ mempool.HandleFunc, msg: %s", libs.GetSum(msgBytes))
+	}
+}
+
+// HandleFuncFrom admits the txs gossiped by the peer, they are marked as seen by it before the admission,
+// so that they aren't gossiped back to it. The txs are checked on the receive loop of the peer, so a peer
+// flooding the txs only holds up its own msgs, the slow app checks should be async, see Config.AsyncCheck.
+func (r *Reactor) HandleFuncFrom(peerID string, chID int32, msgBytes []byte) {
+	if chID != r.channel {
+		return
+	}
+	var msg pb.TxsMessage
+	if err := msg.Unmarshal(msgBytes); err != nil {
+		r.log.Error("unmarshal txs fail @ mempool.HandleFuncFrom, from: %s, err: %v", peerID, err)
+		return
+	}
+	if msg.Module != r.module {
+		return
+	}
+	r.mtx.Lock()
+	sender := r.peers[peerID]
+	r.mtx.Unlock()
+	for _, raw := range msg.Txs {
+		tx := Tx(raw)
+		if sender != nil {
+			sender.seen.Push(tx.Key())
+		}
+		if err := r.mempool.CheckTx(tx); err != nil && err != ErrTxInMempool && err != ErrTxCommitted {
+			r.log.Debug("check gossiped tx fail @ mempool.HandleFuncFrom, from: %s, tx: %s, err: %v", peerID, tx.Key(), err)
+		}
+	}
+}
+
+// gossip sends the admitted tx to the peers which haven't seen it.
+func (r *Reactor) gossip(tx Tx) {
+	msg := &pb.TxsMessage{Module: r.module, Pid: []byte(r.host), Txs: [][]byte{tx}}
+	msgBytes, err := msg.Marshal()
+	if err != nil {
+		r.log.Error("marshal txs fail @ mempool.gossip, err: %v", err)
+		return
+	}
+	key := tx.Key()
+	r.mtx.Lock()
+	var targets []libs.Peer
+	for _, p := range r.peers {
+		// Push reports false if the peer has seen the tx
+		if p.seen.Push(key) {
+			targets = append(targets, p.peer)
+		}
+	}
+	r.mtx.Unlock()
+	for _, p := range targets {
+		if !p.Send(r.channel, msgBytes) {
+			r.log.Warn("gossip tx fail @ mempool.gossip, to: %s, tx: %s", p.PeerID(), key)
+		}
+	}
+}
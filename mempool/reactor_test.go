@@ -0,0 +1,86 @@
+package mempool
+
+import (
+	"sync"
+	"testing"
+
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
+)
+
+type stubPeer struct {
+	id  string
+	mtx sync.Mutex
+	txs []string
+}
+
+func (p *stubPeer) PeerID() string {
+	return p.id
+}
+
+func (p *stubPeer) Send(chID int32, msgBytes []byte) bool {
+	var msg pb.TxsMessage
+	if err := msg.Unmarshal(msgBytes); err != nil {
+		return false
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	for _, tx := range msg.Txs {
+		p.txs = append(p.txs, string(tx))
+	}
+	return true
+}
+
+func (p *stubPeer) received() []string {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return append([]string{}, p.txs...)
+}
+
+func TestGossipSuppression(t *testing.T) {
+	m := NewDefaultMempool(&Config{MaxTxs: 10}, nil)
+	r := NewReactor("host", "", m, nil)
+	a, b := &stubPeer{id: "a"}, &stubPeer{id: "b"}
+	r.AddPeer(a)
+	r.AddPeer(b)
+
+	// the tx from a is relayed to b only, even if a claims to be b
+	msg := &pb.TxsMessage{Module: r.module, Pid: []byte("b"), Txs: [][]byte{[]byte("x")}}
+	msgBytes, err := msg.Marshal()
+	if err != nil {
+		t.Fatalf("marshal err, err: %v", err)
+	}
+	// the txs without the sender told by the transport are dropped
+	r.HandleFunc(r.Channel(), msgBytes)
+	if m.Size() != 0 {
+		t.Fatalf("want the txs dropped, has: %d", m.Size())
+	}
+	r.HandleFuncFrom("a", r.Channel(), msgBytes)
+	if len(a.received()) != 0 {
+		t.Errorf("tx should not be echoed to the sender, has: %v", a.received())
+	}
+	if got := b.received(); len(got) != 1 || got[0] != "x" {
+		t.Errorf("tx should be relayed, has: %v", got)
+	}
+
+	// b sends it back, nothing is gossiped again
+	r.HandleFuncFrom("b", r.Channel(), msgBytes)
+	if len(a.received()) != 0 || len(b.received()) != 1 {
+		t.Errorf("duplicate tx should not be gossiped, a: %v, b: %v", a.received(), b.received())
+	}
+
+	// a local tx reaches every peer once
+	if err := m.CheckTx(Tx("y")); err != nil {
+		t.Fatalf("check tx err, err: %v", err)
+	}
+	if len(a.received()) != 1 || len(b.received()) != 2 {
+		t.Errorf("local tx should be gossiped, a: %v, b: %v", a.received(), b.received())
+	}
+
+	r.RemovePeer(b, nil)
+	if err := m.CheckTx(Tx("z")); err != nil {
+		t.Fatalf("check tx err, err: %v", err)
+	}
+	if len(b.received()) != 2 {
+		t.Errorf("removed peer should not be gossiped, has: %v", b.received())
+	}
+}
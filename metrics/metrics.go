@@ -0,0 +1,279 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultRegistry is shared by the modules of the process.
+var DefaultRegistry = NewRegistry()
+
+const (
+	typeGauge   = "gauge"
+	typeCounter = "counter"
+	typeSummary = "summary"
+)
+
+// SummaryWindow is the number of the latest observations over which the quantiles of a summary are computed.
+const SummaryWindow = 1024
+
+// SummaryQuantiles are the quantiles exposed for every summary.
+var SummaryQuantiles = []float64{0.5, 0.9, 0.99}
+
+// Registry keeps the metrics and exposes them in the prometheus text format.
+type Registry struct {
+	families map[string]*family
+	mtx      sync.Mutex
+}
+
+type family struct {
+	name      string
+	help      string
+	typ       string
+	series    map[string]*value   // map[labels]value
+	summaries map[string]*Summary // map[labels]summary
+}
+
+type value struct {
+	bits uint64
+}
+
+func (v *value) get() float64  { return math.Float64frombits(atomic.LoadUint64(&v.bits)) }
+func (v *value) set(f float64) { atomic.StoreUint64(&v.bits, math.Float64bits(f)) }
+func (v *value) add(f float64) {
+	for {
+		old := atomic.LoadUint64(&v.bits)
+		next := math.Float64bits(math.Float64frombits(old) + f)
+		if atomic.CompareAndSwapUint64(&v.bits, old, next) {
+			return
+		}
+	}
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		families: make(map[string]*family),
+	}
+}
+
+// Gauge is a value which can go up and down.
+type Gauge struct {
+	v *value
+}
+
+func (g *Gauge) Set(f float64)  { g.v.set(f) }
+func (g *Gauge) Add(f float64)  { g.v.add(f) }
+func (g *Gauge) Value() float64 { return g.v.get() }
+
+// Counter is a value which only goes up.
+type Counter struct {
+	v *value
+}
+
+func (c *Counter) Inc()           { c.v.add(1) }
+func (c *Counter) Add(f float64)  { c.v.add(f) }
+func (c *Counter) Value() float64 { return c.v.get() }
+
+// Summary keeps the latest SummaryWindow observations for their quantiles, and the sum and the count of all.
+type Summary struct {
+	mtx    sync.Mutex
+	window []float64
+	next   int
+	sum    float64
+	count  uint64
+}
+
+// Observe records the value.
+func (s *Summary) Observe(f float64) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if len(s.window) < SummaryWindow {
+		s.window = append(s.window, f)
+	} else {
+		s.window[s.next] = f
+	}
+	s.next = (s.next + 1) % SummaryWindow
+	s.sum += f
+	s.count++
+}
+
+// Quantile returns the q-quantile of the latest observations, 0 if there is none.
+func (s *Summary) Quantile(q float64) float64 {
+	s.mtx.Lock()
+	sorted := append([]float64(nil), s.window...)
+	s.mtx.Unlock()
+	return quantile(sorted, q)
+}
+
+// Count returns the number of all the observations.
+func (s *Summary) Count() uint64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.count
+}
+
+// snapshot returns the quantiles of SummaryQuantiles, the sum and the count.
+func (s *Summary) snapshot() ([]float64, float64, uint64) {
+	s.mtx.Lock()
+	sorted := append([]float64(nil), s.window...)
+	sum, count := s.sum, s.count
+	s.mtx.Unlock()
+
+	sort.Float64s(sorted)
+	qs := make([]float64, len(SummaryQuantiles))
+	for i, q := range SummaryQuantiles {
+		qs[i] = quantile(sorted, q)
+	}
+	return qs, sum, count
+}
+
+// quantile returns the nearest-rank q-quantile of the values, which are sorted in place.
+func quantile(values []float64, q float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	if !sort.Float64sAreSorted(values) {
+		sort.Float64s(values)
+	}
+	i := int(math.Ceil(q*float64(len(values)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(values) {
+		i = len(values) - 1
+	}
+	return values[i]
+}
+
+// NewGauge returns the gauge of the name and labels, labels are key-value pairs,
+// the same gauge is returned if it has been created before.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{v: r.series(name, help, typeGauge, labels)}
+}
+
+// NewCounter returns the counter of the name and labels, labels are key-value pairs.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{v: r.series(name, help, typeCounter, labels)}
+}
+
+// NewSummary returns the summary of the name and labels, labels are key-value pairs.
+func (r *Registry) NewSummary(name, help string, labels ...string) *Summary {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	f := r.family(name, help, typeSummary)
+	key := formatLabels(labels)
+	s, ok := f.summaries[key]
+	if !ok {
+		s = &Summary{}
+		f.summaries[key] = s
+	}
+	return s
+}
+
+func (r *Registry) family(name, help, typ string) *family {
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{name: name, help: help, typ: typ, series: make(map[string]*value), summaries: make(map[string]*Summary)}
+		r.families[name] = f
+	}
+	if f.typ != typ {
+		panic(fmt.Sprintf("metric %s has been registered as a %s", name, f.typ))
+	}
+	return f
+}
+
+func (r *Registry) series(name, help, typ string, labels []string) *value {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	f := r.family(name, help, typ)
+	key := formatLabels(labels)
+	v, ok := f.series[key]
+	if !ok {
+		v = &value{}
+		f.series[key] = v
+	}
+	return v
+}
+
+// WriteTo writes all metrics in the prometheus text format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mtx.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		f := r.families[name]
+		fmt.Fprintf(&b, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(&b, "# TYPE %s %s\n", f.name, f.typ)
+		keys := make([]string, 0, len(f.series))
+		for k := range f.series {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%s%s %v\n", f.name, k, f.series[k].get())
+		}
+		keys = keys[:0]
+		for k := range f.summaries {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			qs, sum, count := f.summaries[k].snapshot()
+			for i, q := range SummaryQuantiles {
+				fmt.Fprintf(&b, "%s%s %v\n", f.name, withLabel(k, "quantile", fmt.Sprintf("%v", q)), qs[i])
+			}
+			fmt.Fprintf(&b, "%s_sum%s %v\n", f.name, k, sum)
+			fmt.Fprintf(&b, "%s_count%s %v\n", f.name, k, count)
+		}
+	}
+	r.mtx.Unlock()
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Handler serves the metrics for the prometheus scraper.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		r.WriteTo(w)
+	})
+}
+
+// withLabel appends the label to the formatted labels.
+func withLabel(formatted, key, value string) string {
+	label := fmt.Sprintf(`%s="%s"`, key, value)
+	if formatted == "" {
+		return "{" + label + "}"
+	}
+	return strings.TrimSuffix(formatted, "}") + "," + label + "}"
+}
+
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	if len(labels)%2 != 0 {
+		panic("labels must be key-value pairs")
+	}
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i < len(labels); i += 2 {
+		v := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[i+1])
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], v))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
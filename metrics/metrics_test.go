@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistryWriteTo(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGauge("view_changes", "consecutive view changes", "chain", "a")
+	g.Set(3)
+	r.NewGauge("view_changes", "consecutive view changes", "chain", "b").Add(1)
+	c := r.NewCounter("commits_total", "committed blocks")
+	c.Inc()
+	c.Add(2)
+	// the same series is returned
+	r.NewGauge("view_changes", "consecutive view changes", "chain", "a").Add(1)
+
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP commits_total committed blocks
+# TYPE commits_total counter
+commits_total 3
+# HELP view_changes consecutive view changes
+# TYPE view_changes gauge
+view_changes{chain="a"} 4
+view_changes{chain="b"} 1
+`
+	if b.String() != want {
+		t.Errorf("want:\n%s\nhas:\n%s", want, b.String())
+	}
+}
+
+func TestSummary(t *testing.T) {
+	r := NewRegistry()
+	s := r.NewSummary("phase_seconds", "phase durations", "phase", "vote")
+	for i := 1; i <= 100; i++ {
+		s.Observe(float64(i))
+	}
+	if q := s.Quantile(0.9); q != 90 {
+		t.Errorf("want the 0.9 quantile 90, has: %v", q)
+	}
+	// the quantiles cover the latest observations only
+	for i := 0; i < SummaryWindow; i++ {
+		s.Observe(1)
+	}
+	if q := s.Quantile(0.99); q != 1 || s.Count() != uint64(100+SummaryWindow) {
+		t.Errorf("want the old observations evicted, quantile: %v, count: %d", q, s.Count())
+	}
+
+	r = NewRegistry()
+	r.NewSummary("phase_seconds", "phase durations", "phase", "vote").Observe(2)
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP phase_seconds phase durations
+# TYPE phase_seconds summary
+phase_seconds{phase="vote",quantile="0.5"} 2
+phase_seconds{phase="vote",quantile="0.9"} 2
+phase_seconds{phase="vote",quantile="0.99"} 2
+phase_seconds_sum{phase="vote"} 2
+phase_seconds_count{phase="vote"} 1
+`
+	if b.String() != want {
+		t.Errorf("want:\n%s\nhas:\n%s", want, b.String())
+	}
+}
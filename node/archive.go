@@ -0,0 +1,45 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/p2p"
+	"github.com/aucusaga/gohotstuff/state"
+)
+
+var (
+	ErrArchive = errors.New("unsupported by an archive node")
+)
+
+// addArchiveChain serves the block store of the chain without the consensus and the mempool,
+// only the observer reactor joins the switch, which serves the stored blocks to the subscribers.
+// The consensus instance is never started, it's kept for the read-only rpc.
+func (n *Node) addArchiveChain(cfg *state.ConsensusConfig) error {
+	if cfg.BlocksPath == "" && n.components.Stores == nil {
+		return fmt.Errorf("%w, blockspath is required, chain: %s", ErrArchive, cfg.ChainID)
+	}
+	// nothing is voted or delivered by an archive
+	archived := *cfg
+	archived.WALPath, archived.OutboxPath, archived.CommitWebhooks, archived.Observe = "", "", nil, false
+
+	opts, err := n.components.stateOptions(&archived, n.log)
+	if err != nil {
+		return err
+	}
+	cons, err := createConsensus(n.cfg.name, n.cc, &archived, n.log, opts...)
+	if err != nil {
+		return err
+	}
+	observer := state.NewObserverReactor(cons, cfg.ObserverRate)
+	mo := p2p.Module(libs.ModuleObserver.Name(cfg.ChainID))
+	if err := n.p2p.AddReactor(mo, observer, observer.Channel()); err != nil {
+		return err
+	}
+	observer.SetSwitch(n.p2p)
+
+	n.chains[cfg.ChainID] = &chain{smr: cons, observer: observer, archive: true}
+	n.log.Info("serve the archive @ node.addArchiveChain, chain: %s, blocks: %s", cfg.ChainID, cfg.BlocksPath)
+	return nil
+}
@@ -0,0 +1,67 @@
+package node
+
+import (
+	"github.com/aucusaga/gohotstuff/crypto"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/mempool"
+	"github.com/aucusaga/gohotstuff/p2p"
+	"github.com/aucusaga/gohotstuff/state"
+	"github.com/aucusaga/gohotstuff/storage"
+)
+
+// Components are the parts of the node which the embedding applications can substitute without forking,
+// the nil ones are built from the config like NewNode does.
+type Components struct {
+	// Crypto signs and verifies for every chain instead of the local key or the remote signers,
+	// the verifications are still cached by verifycachesize.
+	Crypto crypto.CryptoClient
+	// Mempool builds the mempool of the chain, whose admitted txs are gossiped to the peers.
+	Mempool func(cfg *state.ConsensusConfig, logger libs.Logger) (mempool.GossipMempool, error)
+	// Stores opens the block store and the result store of the chain, either is nil if it isn't persisted.
+	Stores func(cfg *state.ConsensusConfig) (storage.BlockStorage, storage.ResultStorage, error)
+	// WAL opens the wal of the chain in place of the BaseWAL, it's only invoked if walpath is set.
+	WAL func(cfg *state.ConsensusConfig, logger libs.Logger) (state.WAL, error)
+	// SwitchOptions customize the switch shared by the chains, e.g. its host factory.
+	SwitchOptions []p2p.SwitchOption
+}
+
+func defaultMempool(cfg *state.ConsensusConfig, logger libs.Logger) (mempool.GossipMempool, error) {
+	// the block limits have been set by the consensus
+	return mempool.NewDefaultMempool(&mempool.Config{MaxTxBytes: cfg.MaxTxBytes}, logger), nil
+}
+
+func (c *Components) mempool(cfg *state.ConsensusConfig, logger libs.Logger) (mempool.GossipMempool, error) {
+	if c.Mempool == nil {
+		return defaultMempool(cfg, logger)
+	}
+	return c.Mempool(cfg, logger)
+}
+
+// stateOptions substitutes the stores and the wal of the chain if they're given,
+// otherwise the consensus opens them under the paths of the config.
+func (c *Components) stateOptions(cfg *state.ConsensusConfig, logger libs.Logger) ([]state.StateOption, error) {
+	var opts []state.StateOption
+	if c.Stores != nil {
+		blocks, results, err := c.Stores(cfg)
+		if err != nil {
+			return nil, err
+		}
+		// a nil store must not be wrapped into a non-nil option
+		if blocks != nil {
+			opts = append(opts, state.WithBlockStorage(blocks))
+		}
+		if results != nil {
+			opts = append(opts, state.WithResultStorage(results))
+		}
+	}
+	if c.WAL != nil && cfg.WALPath != "" {
+		wal, err := c.WAL(cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		if wal != nil {
+			opts = append(opts, state.WithWAL(wal))
+		}
+	}
+	return opts, nil
+}
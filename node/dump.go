@@ -0,0 +1,104 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/state"
+)
+
+const (
+	// dumpWALTail is the number of the latest wal records in a crash dump.
+	dumpWALTail = 1000
+	// minDumpInterval keeps a misbehaving peer from flooding the disk with dumps.
+	minDumpInterval = time.Minute
+	redacted        = "<redacted>"
+)
+
+// crashDump is the diagnostic bundle written on fatal errors.
+type crashDump struct {
+	Event  libs.Event                    `json:"event"`
+	Chains map[string]*state.Diagnostics `json:"chains"`
+	Peers  []string                      `json:"peers"`
+	Config *libs.Config                  `json:"config"`
+}
+
+// dumpRoutine writes a crash dump for every event, i.e. the recovered panics and the consensus failures.
+func (n *Node) dumpRoutine(events <-chan libs.Event) {
+	var last time.Time
+	for {
+		select {
+		case e := <-events:
+			if time.Since(last) < minDumpInterval {
+				n.log.Warn("skip crash dump @ node.dumpRoutine, last: %v, module: %s", last, e.Module)
+				continue
+			}
+			last = time.Now()
+			dir, err := n.writeCrashDump(e)
+			if err != nil {
+				n.log.Error("write crash dump fail @ node.dumpRoutine, err: %v", err)
+				continue
+			}
+			n.log.Warn("crash dump written @ node.dumpRoutine, dir: %s, module: %s", dir, e.Module)
+		case <-n.quit:
+			return
+		}
+	}
+}
+
+func (n *Node) writeCrashDump(e libs.Event) (string, error) {
+	dump := &crashDump{
+		Event:  e,
+		Chains: make(map[string]*state.Diagnostics),
+		Peers:  n.p2p.Peers(),
+		Config: redactConfig(n.cfg.raw),
+	}
+	for id, c := range n.chains {
+		dump.Chains[id] = c.smr.Diagnostics(dumpWALTail)
+	}
+
+	dir := filepath.Join(n.cfg.dumpDir, time.Now().Format("20060102-150405.000"))
+	if err := libs.MakeDir(dir); err != nil {
+		return "", err
+	}
+	files := map[string]interface{}{
+		"event.json":  dump.Event,
+		"chains.json": dump.Chains,
+		"peers.json":  dump.Peers,
+		"config.json": dump.Config,
+	}
+	for name, v := range files {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("marshal %s fail, err: %v", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// redactConfig returns a copy of the config without the secrets.
+func redactConfig(cfg *libs.Config) *libs.Config {
+	if cfg == nil {
+		return nil
+	}
+	c := *cfg
+	if len(c.Rpctokens) > 0 {
+		c.Rpctokens = make(map[string][]string)
+		for role := range cfg.Rpctokens {
+			c.Rpctokens[role] = []string{redacted}
+		}
+	}
+	if u, err := url.Parse(c.Proxy); err == nil && u.User != nil {
+		u.User = url.User(redacted)
+		c.Proxy = u.String()
+	}
+	return &c
+}
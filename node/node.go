@@ -1,31 +1,56 @@
 package node
 
 import (
+	"context"
+	"encoding/hex"
+	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/astaxie/beego/logs"
 	"github.com/aucusaga/gohotstuff/crypto"
 	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/libs/service"
+	"github.com/aucusaga/gohotstuff/mempool"
 	"github.com/aucusaga/gohotstuff/p2p"
+	"github.com/aucusaga/gohotstuff/rpc"
 	"github.com/aucusaga/gohotstuff/state"
 )
 
 // node is the canonical implementation of the replica
 type Node struct {
 	cfg *NodeConfig
+	// components substitute the ones built from the cfg, see NewNodeWithComponents
+	components *Components
 
-	p2p *p2p.Switch
-	smr *state.State
-	cc  crypto.CryptoClient
+	p2p      *p2p.Switch
+	smr      *state.State
+	cc       crypto.CryptoClient
+	mempool  mempool.Mempool
+	eventBus libs.EventBus
+	// consensus instances sharing the switch, map[chain_id]*chain,
+	// smr and mempool above belong to the primary chain.
+	chains map[string]*chain
+	// rpc is nil when it's disabled
+	rpc  *rpc.Server
+	quit chan struct{}
+	// stopped is closed once Stop returns, routines are the ones of the node itself
+	stopped  chan struct{}
+	routines service.Supervisor
+	// fatal delivers the failure which halts the node by its failure policy
+	fatal chan libs.Event
 	// block storage
+
+	log libs.Logger
 }
 
-func createConsensus(name string, cc crypto.CryptoClient, cfg *state.ConsensusConfig, logger libs.Logger) (*state.State, error) {
+func createConsensus(name string, cc crypto.CryptoClient, cfg *state.ConsensusConfig, logger libs.Logger,
+	opts ...state.StateOption) (*state.State, error) {
 	// ticker is a timer that schedules timeouts conditional on the height/round/step in the timeoutInfo.
 	ticker := state.NewDefaultTimeoutTicker(logger)
 
-	smr, err := state.NewState(state.PeerID(name), cc, ticker, logger, cfg)
+	smr, err := state.NewState(state.PeerID(name), cc, ticker, logger, cfg, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -48,87 +73,584 @@ func createConsensus(name string, cc crypto.CryptoClient, cfg *state.ConsensusCo
 	return smr, nil
 }
 
-func createP2P(cfg *p2p.Config, consensusReactor libs.Reactor, logger libs.Logger) (*p2p.Switch, error) {
-	sw, err := p2p.NewSwitch(cfg, logger)
+func createP2P(cfg *p2p.Config, logger libs.Logger, opts ...p2p.SwitchOption) (*p2p.Switch, error) {
+	return p2p.NewSwitch(cfg, logger, opts...)
+}
+
+func createConsensusConfig(c *libs.ChainConfig) (*state.ConsensusConfig, error) {
+	// TODO: loading WAL instead of configuration
+	var startValidators []state.PeerID
+	for _, v := range c.Validators {
+		startValidators = append(startValidators, state.PeerID(v))
+	}
+	// the malformed keys fail the verification of their proofs in state.NewState
+	var startKeys []state.ValidatorKey
+	for _, k := range c.Validatorkeys {
+		pk, _ := hex.DecodeString(k.Pubkey)
+		proof, _ := hex.DecodeString(k.Proof)
+		startKeys = append(startKeys, state.ValidatorKey{ID: state.PeerID(k.ID), PublicKey: pk, Proof: proof})
+	}
+	cfg := &state.ConsensusConfig{
+		ChainID:               c.Chainid,
+		ForkID:                c.Forkid,
+		StartRound:            int64(c.Round),
+		StartID:               c.Startk,
+		StartValue:            []byte(c.Startv),
+		StartValidators:       startValidators,
+		StartKeys:             startKeys,
+		MinBlockInterval:      time.Duration(c.Minblockinterval) * time.Millisecond,
+		VoteGrace:             time.Duration(c.Votegrace) * time.Millisecond,
+		InstantFinality:       c.Instantfinality,
+		HashScheme:            c.Hashscheme,
+		HashActivationRound:   c.Hashactivation,
+		MaxBlockBytes:         c.Maxblockbytes,
+		MaxTxBytes:            c.Maxtxbytes,
+		RoundTimeout:          time.Duration(c.Roundtimeout) * time.Millisecond,
+		EpochLength:           int64(c.Epochlength),
+		MaxClockSkew:          time.Duration(c.Maxclockskew) * time.Millisecond,
+		FlushTimeout:          time.Duration(c.Flushtimeout) * time.Millisecond,
+		MaxViewChanges:        c.Maxviewchanges,
+		CommitSLA:             time.Duration(c.Commitsla) * time.Millisecond,
+		StatusInterval:        time.Duration(c.Statusinterval) * time.Millisecond,
+		DowntimeWindow:        c.Downtimewindow,
+		MaxMissedRatio:        float64(c.Maxmissedpct) / 100,
+		UpgradeHeight:         c.Upgradeheight,
+		UpgradeInfo:           c.Upgradeinfo,
+		CommitWebhooks:        c.Commitwebhooks,
+		Observe:               c.Observe,
+		ObserverRate:          c.Observerrate,
+		ExecDepth:             c.Execdepth,
+		ScrubInterval:         time.Duration(c.Scrubinterval) * time.Millisecond,
+		MaxVoteExtensionBytes: c.Maxvoteextensionbytes,
+		EjectMisbehaving:      c.Ejectmisbehaving,
+		WALFlush: state.WALFlushPolicy{
+			PerMessage:    c.Walflushpermsg,
+			Interval:      time.Duration(c.Walflushinterval) * time.Millisecond,
+			MaxBufferSize: c.Walflushsize,
+		},
+		FailurePolicies:   failurePolicies(c.Failures),
+		DiskProbeInterval: time.Duration(c.Diskprobeinterval) * time.Millisecond,
+	}
+	if c.Genesistime != "" {
+		t, err := time.Parse(time.RFC3339, c.Genesistime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid genesis time, chain: %s, err: %v", c.Chainid, err)
+		}
+		cfg.GenesisTime = t
+	}
+	if c.Walpath != "" {
+		cfg.WALPath = filepath.Join(libs.GetCurRootDir(), c.Walpath)
+	}
+	if c.Resultspath != "" {
+		cfg.ResultsPath = filepath.Join(libs.GetCurRootDir(), c.Resultspath)
+	}
+	if c.Blockspath != "" {
+		cfg.BlocksPath = filepath.Join(libs.GetCurRootDir(), c.Blockspath)
+	}
+	if c.Outboxpath != "" {
+		cfg.OutboxPath = filepath.Join(libs.GetCurRootDir(), c.Outboxpath)
+	}
+	return cfg, nil
+}
+
+func failurePolicies(c map[string]libs.FailureConfig) map[state.FailureKind]state.FailurePolicy {
+	if len(c) == 0 {
+		return nil
+	}
+	policies := make(map[state.FailureKind]state.FailurePolicy, len(c))
+	for kind, p := range c {
+		policies[state.FailureKind(kind)] = state.FailurePolicy{
+			Action:   state.FailureAction(p.Action),
+			Retries:  p.Retries,
+			Backoff:  time.Duration(p.Backoff) * time.Millisecond,
+			Fallback: state.FailureAction(p.Fallback),
+		}
+	}
+	return policies
+}
+
+// createQuorumSigner registers the crypto client signing by the threshold of the remote signers,
+// whose order in the config makes the quorum key of the validator.
+func createQuorumSigner(config *libs.Config, logger libs.Logger) error {
+	timeout := time.Duration(config.Signertimeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = crypto.DefaultSignTimeout
+	}
+	var signers []crypto.Signer
+	for _, url := range config.Signers {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		s, err := crypto.NewRemoteSigner(ctx, url, config.Signertoken, nil)
+		cancel()
+		if err != nil {
+			return err
+		}
+		signers = append(signers, s)
+	}
+	qc, err := crypto.NewQuorumCryptoClient(config.Signerthreshold, signers, timeout)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	if err := sw.AddReactor(libs.ConsensusModule, consensusReactor); err != nil {
-		return nil, err
+	crypto.RegisterCryptoClient(func() crypto.CryptoClient { return qc })
+	logger.Info("sign by the remote signers @ node.createQuorumSigner, threshold: %d, signers: %v", config.Signerthreshold, config.Signers)
+	// the quorum key is registered in the validatorkeys by the proof of the signers
+	pk, proof, err := crypto.ProvePossession(qc, config.Host)
+	if err != nil {
+		logger.Warn("prove the quorum key fail @ node.createQuorumSigner, err: %v", err)
+		return nil
+	}
+	logger.Info("quorum key @ node.createQuorumSigner, id: %s, pubkey: %s, proof: %s", config.Host, hex.EncodeToString(pk), hex.EncodeToString(proof))
+	return nil
+}
+
+// createRetryPolicy overrides the default policy by the configured retries and backoff in milliseconds.
+func createRetryPolicy(retries int, backoff int, def p2p.RetryPolicy) p2p.RetryPolicy {
+	if retries != 0 {
+		def.MaxAttempts = retries
+	}
+	if backoff > 0 {
+		def.Backoff = time.Duration(backoff) * time.Millisecond
+	}
+	return def
+}
+
+// createChannelRoles parses the roles allowed on the channels of the modules.
+func createChannelRoles(c map[string][]string) (map[string]p2p.PeerRole, error) {
+	if len(c) == 0 {
+		return nil, nil
 	}
-	consensusReactor.SetSwitch(sw)
-	return sw, nil
+	roles := make(map[string]p2p.PeerRole, len(c))
+	for module, names := range c {
+		r, err := p2p.ParsePeerRoles(names)
+		if err != nil {
+			return nil, fmt.Errorf("invalid channel roles, module: %s, err: %v", module, err)
+		}
+		roles[module] = r
+	}
+	return roles, nil
 }
 
 func NewNode(config *libs.Config) (*Node, error) {
+	return NewNodeWithComponents(config, nil)
+}
+
+// NewNodeWithComponents creates the node of the config whose components are substituted by the given ones,
+// see Components, nil builds all of them from the config.
+func NewNodeWithComponents(config *libs.Config, components *Components) (*Node, error) {
+	if components == nil {
+		components = &Components{}
+	}
 	logger := logs.NewLogger()
 
-	// load netkeys
+	// load the node key, it is generated on the first start
 	netPath := filepath.Join(filepath.Join(libs.GetCurRootDir(), "conf"), config.Netpath)
-	netPriKey, err := os.ReadFile(filepath.Join(netPath, "private.key"))
+	if _, err := p2p.LoadOrGenNodeKey(netPath, ""); err != nil {
+		logger.Warn("load node key err, err: %+v", err)
+		panic("cannot get node key")
+	}
+	// the host is the peer id of the node key if it's not configured
+	host, err := p2p.GetPeerIDFromPath(netPath)
 	if err != nil {
-		logger.Warn("load private key err, err: %+v", err)
-		panic("cannot get private key")
+		return nil, err
+	}
+	if config.Host == "" {
+		config.Host = host
+	} else if config.Host != host {
+		logger.Warn("host mismatches the node key, host: %s, peer_id: %s", config.Host, host)
 	}
 
-	// TODO: loading WAL instead of configuration
-	var startValidators []state.PeerID
-	for _, v := range config.Validators {
-		startValidators = append(startValidators, state.PeerID(v))
+	mainChain := &libs.ChainConfig{
+		Chainid:               config.Chainid,
+		Forkid:                config.Forkid,
+		Walpath:               config.Walpath,
+		Resultspath:           config.Resultspath,
+		Blockspath:            config.Blockspath,
+		Scrubinterval:         config.Scrubinterval,
+		Outboxpath:            config.Outboxpath,
+		Commitwebhooks:        config.Commitwebhooks,
+		Observe:               config.Observe,
+		Observerrate:          config.Observerrate,
+		Execdepth:             config.Execdepth,
+		Maxvoteextensionbytes: config.Maxvoteextensionbytes,
+		Walflushpermsg:        config.Walflushpermsg,
+		Walflushinterval:      config.Walflushinterval,
+		Walflushsize:          config.Walflushsize,
+		Minblockinterval:      config.Minblockinterval,
+		Votegrace:             config.Votegrace,
+		Instantfinality:       config.Instantfinality,
+		Hashscheme:            config.Hashscheme,
+		Hashactivation:        config.Hashactivation,
+		Maxblockbytes:         config.Maxblockbytes,
+		Maxtxbytes:            config.Maxtxbytes,
+		Roundtimeout:          config.Roundtimeout,
+		Epochlength:           config.Epochlength,
+		Maxclockskew:          config.Maxclockskew,
+		Flushtimeout:          config.Flushtimeout,
+		Failures:              config.Failures,
+		Diskprobeinterval:     config.Diskprobeinterval,
+		Ejectmisbehaving:      config.Ejectmisbehaving,
+		Maxviewchanges:        config.Maxviewchanges,
+		Commitsla:             config.Commitsla,
+		Statusinterval:        config.Statusinterval,
+		Downtimewindow:        config.Downtimewindow,
+		Maxmissedpct:          config.Maxmissedpct,
+		Upgradeheight:         config.Upgradeheight,
+		Upgradeinfo:           config.Upgradeinfo,
+		Round:                 config.Round,
+		Startk:                config.Startk,
+		Startv:                config.Startv,
+		Validators:            config.Validators,
+		Validatorkeys:         config.Validatorkeys,
+		Genesistime:           config.Genesistime,
 	}
 	cfg := &NodeConfig{
-		name: config.Host,
+		name:    config.Host,
+		archive: config.Archive,
+		raw:     config,
 		p2p: &p2p.Config{
-			BootStrap:  config.Bootstrap,
-			Address:    config.Address,
-			PrivateKey: string(netPriKey),
-		},
-		state: &state.ConsensusConfig{
-			StartRound:      int64(config.Round),
-			StartID:         config.Startk,
-			StartValue:      []byte(config.Startv),
-			StartValidators: startValidators,
+			NetworkID:         config.Chainid,
+			ChainID:           config.Chainid,
+			ForkID:            config.Forkid,
+			GenesisHash:       libs.GenesisFromConfig(mainChain).Hash(),
+			BootStrap:         config.Bootstrap,
+			Address:           config.Address,
+			NodeKeyDir:        netPath,
+			ProxyAddress:      config.Proxy,
+			PinnedPeers:       config.Pinnedpeers,
+			DialConcurrency:   config.Dialconcurrency,
+			DialTimeout:       time.Duration(config.Dialtimeout) * time.Millisecond,
+			SendQueueTTL:      time.Duration(config.Sendqueuettl) * time.Millisecond,
+			SendResultTimeout: time.Duration(config.Sendresulttimeout) * time.Millisecond,
+			IdleTimeout:       time.Duration(config.Idletimeout) * time.Millisecond,
+			PingInterval:      time.Duration(config.Pinginterval) * time.Millisecond,
+			StreamRetry:       createRetryPolicy(config.Streamretries, config.Streamretrybackoff, p2p.DefaultStreamRetry),
+			RedialRetry:       createRetryPolicy(config.Redialretries, config.Redialretrybackoff, p2p.DefaultRedialRetry),
+			SentryPeers:       config.Sentrypeers,
+			SyncServers:       config.Syncservers,
+			MsgLog: p2p.MsgLogConfig{
+				Level:    config.Msgloglevel,
+				MaxBytes: config.Msglogbytes,
+			},
+			DialQuota: p2p.DialQuota{
+				Validators: config.Dialquotavalidators,
+				Sentries:   config.Dialquotasentries,
+				Others:     config.Dialquotaothers,
+			},
+			DHT: p2p.DHTConfig{
+				Mode:          config.Dhtmode,
+				RefreshPeriod: time.Duration(config.Dhtrefreshperiod) * time.Millisecond,
+				BucketSize:    config.Dhtbucketsize,
+				QueryTimeout:  time.Duration(config.Dhtquerytimeout) * time.Millisecond,
+			},
+			Rendezvous: p2p.RendezvousConfig{
+				URL:           config.Rendezvous,
+				Token:         config.Rendezvoustoken,
+				TTL:           time.Duration(config.Rendezvousttl) * time.Millisecond,
+				RefreshPeriod: time.Duration(config.Rendezvousrefreshperiod) * time.Millisecond,
+			},
+			SlowPeer: p2p.SlowPeerConfig{
+				Threshold: time.Duration(config.Slowpeerthreshold) * time.Millisecond,
+				Samples:   config.Slowpeersamples,
+				ChunkSize: config.Chunksize,
+			},
 		},
 	}
+	if cfg.state, err = createConsensusConfig(mainChain); err != nil {
+		return nil, err
+	}
+	if config.Dumppath != "" {
+		cfg.dumpDir = filepath.Join(libs.GetCurRootDir(), config.Dumppath)
+	}
+	// the peers trickling the bytes are dropped
+	cfg.p2p.HandshakeTimeout = time.Duration(config.Handshaketimeout) * time.Millisecond
+	cfg.p2p.FrameTimeout = time.Duration(config.Frametimeout) * time.Millisecond
+	cfg.p2p.SendWorkers, cfg.p2p.HandleWorkers = config.Sendworkers, config.Handleworkers
+	if config.Tappath != "" {
+		cfg.p2p.TapPath = filepath.Join(libs.GetCurRootDir(), config.Tappath)
+	}
+	if config.Auditpath != "" {
+		cfg.p2p.AuditPath = filepath.Join(libs.GetCurRootDir(), config.Auditpath)
+	}
+	if config.Addrbookpath != "" {
+		cfg.p2p.AddrBookPath = filepath.Join(libs.GetCurRootDir(), config.Addrbookpath)
+	}
+	for i := range config.Chains {
+		c, err := createConsensusConfig(&config.Chains[i])
+		if err != nil {
+			return nil, err
+		}
+		cfg.chains = append(cfg.chains, c)
+	}
+	// the required features are advertised as well
+	features, err := libs.ParseFeatures(append(append([]string{}, config.Features...), config.Requiredfeatures...))
+	if err != nil {
+		return nil, err
+	}
+	required, err := libs.ParseFeatures(config.Requiredfeatures)
+	if err != nil {
+		return nil, err
+	}
+	cfg.p2p.Features, cfg.p2p.RequiredFeatures = features, required
+	if cfg.flags, err = libs.NewFeatureFlags(features, config.Experimental); err != nil {
+		return nil, err
+	}
+	cfg.p2p.Experimental = cfg.flags.Experimental()
+	for _, c := range append([]*state.ConsensusConfig{cfg.state}, cfg.chains...) {
+		c.Flags = cfg.flags
+	}
+	cfg.p2p.MinProtocolVersion = config.Minprotocolversion
+	if cfg.p2p.ChannelRoles, err = createChannelRoles(config.Channelroles); err != nil {
+		return nil, err
+	}
+	// the chains share the switch, whose frames must carry the largest proposal of them
+	cfg.p2p.MaxMsgSize = p2p.DefaultMaxMsgSize
+	for _, c := range append([]*state.ConsensusConfig{cfg.state}, cfg.chains...) {
+		if size := state.MaxProposalBytes(c); size > cfg.p2p.MaxMsgSize {
+			cfg.p2p.MaxMsgSize = size
+		}
+	}
 
 	// load crypto keys
-	keypath := filepath.Join(filepath.Join(libs.GetCurRootDir(), "conf"), config.Keypath)
-	priKey, err := os.ReadFile(filepath.Join(keypath, "private.key"))
-	if err != nil {
-		logger.Warn("load private key err, err: %+v", err)
-		panic("cannot get private key")
+	signer := components.Crypto
+	if signer != nil {
+		logger.Info("sign by the given crypto client @ node.NewNode")
+	} else if len(config.Signers) > 0 {
+		if err := createQuorumSigner(config, logger); err != nil {
+			logger.Warn("create quorum signer err, err: %+v", err)
+			return nil, err
+		}
+	} else {
+		keypath := filepath.Join(filepath.Join(libs.GetCurRootDir(), "conf"), config.Keypath)
+		priKey, err := os.ReadFile(filepath.Join(keypath, "private.key"))
+		if err != nil {
+			logger.Warn("load private key err, err: %+v", err)
+			panic("cannot get private key")
+		}
+
+		err = crypto.InitCryptoClient(priKey)
+		if err != nil {
+			logger.Warn("init crypto client err, err: %+v", err)
+			panic("init crypto client failed")
+		}
+	}
+	if signer == nil {
+		signer = crypto.CryptoClientPicker()
 	}
+	cc := crypto.NewCachedCryptoClient(signer, config.Verifycachesize, time.Duration(config.Verifycachettl)*time.Second)
 
-	err = crypto.InitCryptoClient(priKey)
+	sw, err := createP2P(cfg.p2p, logger, components.SwitchOptions...)
 	if err != nil {
-		logger.Warn("init crypto client err, err: %+v", err)
-		panic("init crypto client failed")
+		logger.Warn("create p2p err, err: %+v", err)
+		return nil, err
 	}
-	cc := crypto.CryptoClientPicker()
 
-	cons, err := createConsensus(cfg.name, cc, cfg.state, logger)
-	if err != nil {
+	eventBus := libs.NewDefaultEventBus(logger)
+	sw.SetEventBus(eventBus)
+
+	n := &Node{
+		cfg:        cfg,
+		components: components,
+		p2p:        sw,
+		cc:         cc,
+		eventBus:   eventBus,
+		chains:     make(map[string]*chain),
+		quit:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+		fatal:      make(chan libs.Event, 1),
+		log:        logger,
+	}
+	n.routines.SetLogger(logger)
+	if err := n.AddChain(cfg.state); err != nil {
 		logger.Warn("create consensus err, err: %+v", err)
 		return nil, err
 	}
+	primary := n.chains[cfg.state.ChainID]
+	n.smr, n.mempool = primary.smr, primary.mempool
+
+	for _, c := range cfg.chains {
+		if err := n.AddChain(c); err != nil {
+			logger.Warn("create consensus err, chain: %s, err: %+v", c.ChainID, err)
+			return nil, err
+		}
+	}
+
+	if config.Rpcaddress != "" {
+		rpcCfg, err := createRPCConfig(config)
+		if err != nil {
+			logger.Warn("create rpc config err, err: %+v", err)
+			return nil, err
+		}
+		if n.rpc, err = rpc.NewServer(rpcCfg, logger); err != nil {
+			logger.Warn("create rpc err, err: %+v", err)
+			return nil, err
+		}
+		if err := n.registerRoutes(n.rpc); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
 
-	sw, err := createP2P(cfg.p2p, cons, logger)
+// AddChain creates a consensus instance of the given chain on the shared switch,
+// it should be invoked before node.Start().
+func (n *Node) AddChain(cfg *state.ConsensusConfig) error {
+	if _, ok := n.chains[cfg.ChainID]; ok {
+		return fmt.Errorf("chain has been added before, chain: %s", cfg.ChainID)
+	}
+	if cfg.Flags == nil {
+		cfg.Flags = n.cfg.flags
+	}
+	if n.cfg.archive {
+		return n.addArchiveChain(cfg)
+	}
+
+	opts, err := n.components.stateOptions(cfg, n.log)
 	if err != nil {
-		logger.Warn("create p2p err, err: %+v", err)
-		return nil, err
+		return err
+	}
+	cons, err := createConsensus(n.cfg.name, n.cc, cfg, n.log, opts...)
+	if err != nil {
+		return err
+	}
+	mp, err := n.components.mempool(cfg, n.log)
+	if err != nil {
+		return err
 	}
+	if err := cons.RegisterMempool(mp); err != nil {
+		return err
+	}
+
+	mo := p2p.Module(libs.ModuleConsensus.Name(cfg.ChainID))
+	if err := n.p2p.AddReactor(mo, cons, cons.Channel()); err != nil {
+		return err
+	}
+	cons.SetSwitch(n.p2p)
+	cons.SetEventBus(n.eventBus)
 
-	return &Node{
-		cfg: cfg,
-		p2p: sw,
-		smr: cons,
-		cc:  cc,
-	}, nil
+	gossip := mempool.NewReactor(n.cfg.name, cfg.ChainID, mp, n.log)
+	mo = p2p.Module(libs.ModuleMempool.Name(cfg.ChainID))
+	if err := n.p2p.AddReactor(mo, gossip, gossip.Channel()); err != nil {
+		return err
+	}
+	gossip.SetSwitch(n.p2p)
+
+	observer := state.NewObserverReactor(cons, cfg.ObserverRate)
+	mo = p2p.Module(libs.ModuleObserver.Name(cfg.ChainID))
+	if err := n.p2p.AddReactor(mo, observer, observer.Channel()); err != nil {
+		return err
+	}
+	observer.SetSwitch(n.p2p)
+	if cfg.Observe {
+		if err := observer.Follow(); err != nil {
+			return err
+		}
+	}
+
+	join := state.NewJoinReactor(cons)
+	mo = p2p.Module(libs.ModuleJoin.Name(cfg.ChainID))
+	if err := n.p2p.AddReactor(mo, join, join.Channel()); err != nil {
+		return err
+	}
+	join.SetSwitch(n.p2p)
+
+	n.chains[cfg.ChainID] = &chain{smr: cons, mempool: mp, observer: observer, join: join}
+	return nil
 }
 
 func (n *Node) Start() {
-	go n.p2p.Start()
-	go n.smr.Start()
+	if n.cfg.dumpDir != "" {
+		n.routines.Spawn("dump/panic", func() { n.dumpRoutine(n.eventBus.Subscribe(libs.EventPanic)) })
+		// the divergence report is kept along with the diagnostics of the halted chain
+		n.routines.Spawn("dump/failure", func() { n.dumpRoutine(n.eventBus.Subscribe(libs.EventConsensusFailure)) })
+		n.routines.Spawn("dump/fatal", func() { n.dumpRoutine(n.eventBus.Subscribe(libs.EventFatal)) })
+	}
+	n.routines.Spawn("fatal", func() { n.fatalRoutine(n.eventBus.Subscribe(libs.EventFatal)) })
+	// the failure to start the p2p is reported by Stop
+	n.routines.Go("p2p_start", service.Policy{}, n.p2p.Start)
+	for id, c := range n.chains {
+		if c.archive {
+			continue
+		}
+		n.routines.Spawn("consensus_start/"+id, c.smr.Start)
+	}
+	if n.rpc != nil {
+		if err := n.rpc.Start(); err != nil {
+			n.log.Warn("start rpc err, err: %+v", err)
+		}
+	}
+}
+
+// Stop shuts the node down in the order of the dependencies: the consensus stops proposing
+// and flushes its wal, then the rpc is closed, then the p2p, so that the msgs broadcasted
+// during stopping can still be flushed. The stores write every entry to its own file,
+// nothing is left open once the consensus has stopped.
+func (n *Node) Stop() {
+	defer close(n.stopped)
+
+	close(n.quit)
+	n.routines.Stop()
+	// the modules are started asynchronously, none is stopped halfway through its start
+	if err := n.routines.Wait(); err != nil {
+		n.log.Warn("routines fail @ node.Stop, err: %v", err)
+	}
+	for _, c := range n.chains {
+		if c.archive {
+			continue
+		}
+		c.smr.Stop()
+	}
+	if n.rpc != nil {
+		if err := n.rpc.Stop(); err != nil {
+			n.log.Warn("stop rpc err, err: %+v", err)
+		}
+	}
+	if err := n.p2p.Stop(); err != nil {
+		n.log.Warn("stop p2p err, err: %+v", err)
+	}
+}
+
+// Wait blocks until the node has stopped and all its goroutines have exited,
+// so that the embedding programs and the tests can exit without leaking any.
+func (n *Node) Wait() {
+	<-n.stopped
+	n.routines.Wait()
+	for _, c := range n.chains {
+		c.smr.Wait()
+	}
+	n.p2p.Wait()
+}
+
+// Fatal delivers the event of the failure which halts the node by its failure policy,
+// the embedding programs stop the node on it.
+func (n *Node) Fatal() <-chan libs.Event {
+	return n.fatal
+}
+
+func (n *Node) fatalRoutine(events <-chan libs.Event) {
+	select {
+	case e := <-events:
+		n.log.Error("node halted by a fatal failure @ node.fatalRoutine, module: %s, data: %+v", e.Module, e.Data)
+		n.fatal <- e
+	case <-n.quit:
+	}
+}
+
+// Chain returns the consensus instance and the mempool of the given chain.
+func (n *Node) Chain(chainID string) (*state.State, mempool.Mempool, bool) {
+	c, ok := n.chains[chainID]
+	if !ok {
+		return nil, nil, false
+	}
+	return c.smr, c.mempool, true
+}
+
+// Mempool returns the mempool where the txs wait to be packed into proposals.
+func (n *Node) Mempool() mempool.Mempool {
+	return n.mempool
+}
+
+// EventBus returns the bus on which all modules of the node publish their events.
+func (n *Node) EventBus() libs.EventBus {
+	return n.eventBus
 }
 
 // -----------------------------------------
@@ -136,4 +658,23 @@ type NodeConfig struct {
 	name  string
 	p2p   *p2p.Config
 	state *state.ConsensusConfig
+	// consensus configs of the extra chains
+	chains []*state.ConsensusConfig
+	// crash dumps are disabled when it's empty
+	dumpDir string
+	// archive serves the block stores only, see addArchiveChain
+	archive bool
+	// flags are shared by the chains, see libs.FeatureFlags
+	flags *libs.FeatureFlags
+	raw   *libs.Config
+}
+
+type chain struct {
+	smr      *state.State
+	mempool  mempool.Mempool
+	observer *state.ObserverReactor
+	// join is nil on the archive
+	join *state.JoinReactor
+	// archive runs neither the consensus nor the mempool, which is nil
+	archive bool
 }
@@ -0,0 +1,747 @@
+package node
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/mempool"
+	"github.com/aucusaga/gohotstuff/metrics"
+	"github.com/aucusaga/gohotstuff/p2p"
+	"github.com/aucusaga/gohotstuff/rpc"
+	"github.com/aucusaga/gohotstuff/state"
+	"github.com/aucusaga/gohotstuff/storage"
+)
+
+var (
+	ErrUnknownChain = errors.New("unknown chain")
+)
+
+func createRPCConfig(config *libs.Config) (*rpc.Config, error) {
+	cfg := &rpc.Config{
+		ListenAddress: config.Rpcaddress,
+		Tokens:        make(map[string]rpc.Role),
+	}
+	if config.Rpcauditpath != "" {
+		cfg.AuditPath = filepath.Join(libs.GetCurRootDir(), config.Rpcauditpath)
+	}
+	if config.Rpctlscert != "" && config.Rpctlskey != "" {
+		cfg.TLSCertFile = filepath.Join(libs.GetCurRootDir(), config.Rpctlscert)
+		cfg.TLSKeyFile = filepath.Join(libs.GetCurRootDir(), config.Rpctlskey)
+	}
+	for name, tokens := range config.Rpctokens {
+		role, err := rpc.ParseRole(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tokens {
+			cfg.Tokens[t] = role
+		}
+	}
+	return cfg, nil
+}
+
+func (n *Node) registerRoutes(s *rpc.Server) error {
+	routes := []struct {
+		path string
+		role rpc.Role
+		h    rpc.HandlerFunc
+	}{
+		{"/status", rpc.RoleReadOnly, n.rpcStatus},
+		{"/validators", rpc.RoleReadOnly, n.rpcValidators},
+		{"/blocks", rpc.RoleReadOnly, n.rpcBlocks},
+		{"/peers", rpc.RoleReadOnly, n.rpcPeers},
+		{"/peer_statuses", rpc.RoleReadOnly, n.rpcPeerStatuses},
+		{"/net_info", rpc.RoleReadOnly, n.rpcNetInfo},
+		{"/view_changes", rpc.RoleReadOnly, n.rpcViewChanges},
+		{"/participation", rpc.RoleReadOnly, n.rpcParticipation},
+		{"/validator_changes", rpc.RoleReadOnly, n.rpcValidatorChanges},
+		{"/consensus_params", rpc.RoleReadOnly, n.rpcConsensusParams},
+		{"/addr_book", rpc.RoleReadOnly, n.rpcAddrBook},
+		{"/join_requests", rpc.RoleReadOnly, n.rpcJoinRequests},
+		{"/peer_audit", rpc.RoleOperator, n.rpcPeerAudit},
+		{"/addr_book_export", rpc.RoleOperator, n.rpcExportAddrBook},
+		{"/query", rpc.RoleReadOnly, n.rpcQuery},
+		{"/block_results", rpc.RoleReadOnly, n.rpcBlockResults},
+		{"/broadcast_tx", rpc.RoleOperator, n.rpcBroadcastTx},
+		{"/request_join", rpc.RoleOperator, n.rpcRequestJoin},
+		{"/unsafe_dial_peers", rpc.RoleAdmin, n.rpcDialPeers},
+		{"/unsafe_import_addr_book", rpc.RoleAdmin, n.rpcImportAddrBook},
+		{"/unsafe_force_view_change", rpc.RoleAdmin, n.rpcForceViewChange},
+		{"/unsafe_resync_from_height", rpc.RoleAdmin, n.rpcResyncFromHeight},
+	}
+	for _, r := range routes {
+		if err := s.Register(r.path, r.role, r.h); err != nil {
+			return err
+		}
+	}
+	if err := s.Handle("/snapshot", rpc.RoleReadOnly, http.HandlerFunc(n.rpcSnapshot)); err != nil {
+		return err
+	}
+	if err := s.HandleEvents("/events", rpc.RoleReadOnly, n.eventBus); err != nil {
+		return err
+	}
+	return s.Handle("/metrics", rpc.RoleReadOnly, metrics.DefaultRegistry.Handler())
+}
+
+// rpcStatus returns the round state of all chains.
+func (n *Node) rpcStatus(r *http.Request) (interface{}, error) {
+	res := &pb.StatusResult{Name: n.cfg.name, GenesisHash: hex.EncodeToString(n.cfg.p2p.GenesisHash),
+		Features: n.cfg.flags.Names()}
+	for _, c := range n.chains {
+		rs := c.smr.GetRoundState()
+		chain := &pb.RoundState{
+			ChainId:        rs.ChainID,
+			Round:          rs.Round,
+			CommittedRound: rs.CommittedRound,
+			Leader:         string(rs.Leader),
+		}
+		for _, p := range rs.Phases {
+			chain.Phases = append(chain.Phases, &pb.PhaseTiming{
+				Phase: p.Phase,
+				Count: p.Count,
+				P50Ms: milliseconds(p.P50),
+				P90Ms: milliseconds(p.P90),
+				P99Ms: milliseconds(p.P99),
+			})
+		}
+		res.Chains = append(res.Chains, chain)
+	}
+	return res, nil
+}
+
+// rpcPeerStatuses returns the statuses gossiped by the peers ordered by the peer, e.g. /peer_statuses?chain=&page=1
+func (n *Node) rpcPeerStatuses(r *http.Request) (interface{}, error) {
+	c, ok := n.chains[r.URL.Query().Get("chain")]
+	if !ok {
+		return nil, ErrUnknownChain
+	}
+	statuses := c.smr.PeerStatuses()
+	page, idx, err := rpc.Paginate(r, len(statuses))
+	if err != nil {
+		return nil, err
+	}
+	list := []*pb.PeerStatus{}
+	for _, i := range idx {
+		st := statuses[i]
+		list = append(list, &pb.PeerStatus{
+			Peer:           string(st.Peer),
+			CommittedRound: st.CommittedRound,
+			Round:          st.Round,
+			HighQcRound:    st.HighQCRound,
+			UpdatedAt:      rfc3339(st.UpdatedAt),
+		})
+	}
+	page.Items = list
+	return page, nil
+}
+
+// rpcViewChanges returns the view changes by the reason and the recent ones, e.g. /view_changes?chain=
+func (n *Node) rpcViewChanges(r *http.Request) (interface{}, error) {
+	c, ok := n.chains[r.URL.Query().Get("chain")]
+	if !ok {
+		return nil, ErrUnknownChain
+	}
+	stats := c.smr.ViewChangeStats()
+	res := &pb.ViewChangeStats{Total: stats.Total, ByReason: make(map[string]int64, len(stats.ByReason))}
+	for reason, n := range stats.ByReason {
+		res.ByReason[string(reason)] = n
+	}
+	for _, vc := range stats.Recent {
+		res.Recent = append(res.Recent, &pb.ViewChange{
+			Round:  vc.Round,
+			Leader: string(vc.Leader),
+			Reason: string(vc.Reason),
+			Time:   rfc3339(vc.Time),
+		})
+	}
+	return res, nil
+}
+
+// rpcParticipation returns the signed and missed QCs of the validators over the window ordered by the validator,
+// e.g. /participation?chain=&page=1
+func (n *Node) rpcParticipation(r *http.Request) (interface{}, error) {
+	c, ok := n.chains[r.URL.Query().Get("chain")]
+	if !ok {
+		return nil, ErrUnknownChain
+	}
+	participation := c.smr.Participation()
+	page, idx, err := rpc.Paginate(r, len(participation))
+	if err != nil {
+		return nil, err
+	}
+	list := []*pb.Participation{}
+	for _, i := range idx {
+		p := participation[i]
+		list = append(list, &pb.Participation{
+			Validator: string(p.Validator),
+			Signed:    int64(p.Signed),
+			Missed:    int64(p.Missed),
+			Down:      p.Down,
+		})
+	}
+	page.Items = list
+	return page, nil
+}
+
+// rpcValidators returns the validator set at the height, the current round by default, in the order
+// of the election, e.g. /validators?chain=&height=10&page=1
+func (n *Node) rpcValidators(r *http.Request) (interface{}, error) {
+	q := r.URL.Query()
+	c, ok := n.chains[q.Get("chain")]
+	if !ok {
+		return nil, ErrUnknownChain
+	}
+	height, err := intParam(r, "height", c.smr.GetRoundState().Round)
+	if err != nil {
+		return nil, err
+	}
+	validators := c.smr.Validators(height)
+	page, idx, err := rpc.Paginate(r, len(validators))
+	if err != nil {
+		return nil, err
+	}
+	list := []string{}
+	for _, i := range idx {
+		list = append(list, string(validators[i]))
+	}
+	page.Items, page.Height = list, height
+	return page, nil
+}
+
+// rpcBlocks returns the persisted blocks in [from, to] ordered by the height, to is unbounded if it's 0,
+// e.g. /blocks?chain=&from=1&to=100&page=1&order=desc
+func (n *Node) rpcBlocks(r *http.Request) (interface{}, error) {
+	c, ok := n.chains[r.URL.Query().Get("chain")]
+	if !ok {
+		return nil, ErrUnknownChain
+	}
+	from, err := intParam(r, "from", 0)
+	if err != nil {
+		return nil, err
+	}
+	to, err := intParam(r, "to", 0)
+	if err != nil {
+		return nil, err
+	}
+	heights, err := c.smr.BlockHeights(from, to)
+	if err != nil {
+		return nil, err
+	}
+	page, idx, err := rpc.Paginate(r, len(heights))
+	if err != nil {
+		return nil, err
+	}
+	list := []*pb.Block{}
+	for _, i := range idx {
+		block, err := c.smr.CommittedBlock(heights[i])
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, committedBlock(block))
+	}
+	page.Items = list
+	return page, nil
+}
+
+// rpcPeers returns the multiaddrs of the connected peers in the lexical order, e.g. /peers?page=1
+func (n *Node) rpcPeers(r *http.Request) (interface{}, error) {
+	peers := n.p2p.Peers()
+	sort.Strings(peers)
+	page, idx, err := rpc.Paginate(r, len(peers))
+	if err != nil {
+		return nil, err
+	}
+	list := []string{}
+	for _, i := range idx {
+		list = append(list, peers[i])
+	}
+	page.Items = list
+	return page, nil
+}
+
+// rpcNetInfo returns the bytes sent and received by the modules, in total and by the connected peers.
+func (n *Node) rpcNetInfo(r *http.Request) (interface{}, error) {
+	info := n.p2p.NetInfo()
+	res := &pb.NetInfo{
+		NPeers:  int64(len(info.Peers)),
+		Modules: moduleTraffic(info.Modules),
+		Peers:   []*pb.PeerTraffic{},
+	}
+	for _, p := range info.Peers {
+		res.Peers = append(res.Peers, &pb.PeerTraffic{
+			Peer:     p.Peer,
+			Outbound: p.Outbound,
+			Modules:  moduleTraffic(p.Modules),
+		})
+	}
+	return res, nil
+}
+
+// rpcConsensusParams returns the consensus params in force at the height, the current round by default,
+// e.g. /consensus_params?chain=&height=10
+func (n *Node) rpcConsensusParams(r *http.Request) (interface{}, error) {
+	q := r.URL.Query()
+	c, ok := n.chains[q.Get("chain")]
+	if !ok {
+		return nil, ErrUnknownChain
+	}
+	height, err := intParam(r, "height", c.smr.GetRoundState().Round)
+	if err != nil {
+		return nil, err
+	}
+	// the scheduled steps are the changes committed but taking effect after the height
+	res := &pb.ConsensusParamsResult{Height: height, Params: consensusParams(c.smr.ParamsAt(height))}
+	for _, step := range c.smr.ParamsSteps(height, math.MaxInt64) {
+		res.Scheduled = append(res.Scheduled, &pb.ParamsStep{Start: step.Start, Params: consensusParams(step.Params)})
+	}
+	return res, nil
+}
+
+// rpcValidatorChanges returns the membership diffs in (from, to] ordered by the height,
+// e.g. /validator_changes?chain=&from=0&to=100&page=1
+func (n *Node) rpcValidatorChanges(r *http.Request) (interface{}, error) {
+	q := r.URL.Query()
+	c, ok := n.chains[q.Get("chain")]
+	if !ok {
+		return nil, ErrUnknownChain
+	}
+	from, err := intParam(r, "from", 0)
+	if err != nil {
+		return nil, err
+	}
+	to, err := intParam(r, "to", c.smr.GetRoundState().Round)
+	if err != nil {
+		return nil, err
+	}
+	if from > to {
+		return nil, fmt.Errorf("from must not be greater than to, from: %d, to: %d", from, to)
+	}
+	diffs, err := c.smr.ValidatorChanges(from, to)
+	if err != nil {
+		return nil, err
+	}
+	page, idx, err := rpc.Paginate(r, len(diffs))
+	if err != nil {
+		return nil, err
+	}
+	list := []*pb.ValidatorDiff{}
+	for _, i := range idx {
+		d := diffs[i]
+		list = append(list, &pb.ValidatorDiff{Height: d.Height, Added: peerIDs(d.Added), Removed: peerIDs(d.Removed)})
+	}
+	page.Items = list
+	return page, nil
+}
+
+// rpcBroadcastTx admits the hex encoded tx into the mempool of the chain, the priority, the sender
+// and the nonce are optional, e.g. /broadcast_tx?chain=&tx=0xabcd&priority=10&sender=alice&nonce=1
+func (n *Node) rpcBroadcastTx(r *http.Request) (interface{}, error) {
+	q := r.URL.Query()
+	c, ok := n.chains[q.Get("chain")]
+	if !ok {
+		return nil, ErrUnknownChain
+	}
+	if c.archive {
+		return nil, ErrArchive
+	}
+	tx, err := hex.DecodeString(strings.TrimPrefix(q.Get("tx"), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("tx must be hex encoded, err: %v", err)
+	}
+	if q.Get("priority") == "" && q.Get("sender") == "" {
+		if err := c.mempool.CheckTx(mempool.Tx(tx)); err != nil {
+			return nil, err
+		}
+		return &pb.BroadcastTxResult{Hash: mempool.Tx(tx).Key()}, nil
+	}
+	priority, err := intParam(r, "priority", 0)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := intParam(r, "nonce", 0)
+	if err != nil {
+		return nil, err
+	}
+	info := mempool.TxInfo{Priority: priority, Sender: q.Get("sender"), Nonce: uint64(nonce)}
+	if err := c.mempool.CheckTxWithInfo(mempool.Tx(tx), info); err != nil {
+		return nil, err
+	}
+	return &pb.BroadcastTxResult{Hash: mempool.Tx(tx).Key()}, nil
+}
+
+// rpcQuery reads the application state at the committed height, the latest one by default,
+// the hex encoded value and proof are replied, e.g. /query?chain=&path=/key&data=0xabcd&height=10
+func (n *Node) rpcQuery(r *http.Request) (interface{}, error) {
+	q := r.URL.Query()
+	c, ok := n.chains[q.Get("chain")]
+	if !ok {
+		return nil, ErrUnknownChain
+	}
+	data, err := hex.DecodeString(strings.TrimPrefix(q.Get("data"), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("data must be hex encoded, err: %v", err)
+	}
+	height, err := intParam(r, "height", 0)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.smr.Query(q.Get("path"), data, height)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.QueryResult{
+		Height: res.Height,
+		Value:  hex.EncodeToString(res.Value),
+		Proof:  hex.EncodeToString(res.Proof),
+	}, nil
+}
+
+// rpcBlockResults returns the tx results of the committed block, the latest one by default,
+// e.g. /block_results?chain=&height=10
+func (n *Node) rpcBlockResults(r *http.Request) (interface{}, error) {
+	c, ok := n.chains[r.URL.Query().Get("chain")]
+	if !ok {
+		return nil, ErrUnknownChain
+	}
+	height, err := intParam(r, "height", 0)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.smr.BlockResults(height)
+	if err != nil {
+		return nil, err
+	}
+	return blockResults(res), nil
+}
+
+// rpcAddrBook lists the pinned addresses and the ones marked suspicious ordered by the address,
+// e.g. /addr_book?page=1
+func (n *Node) rpcAddrBook(r *http.Request) (interface{}, error) {
+	known := n.p2p.AddrBook().List()
+	page, idx, err := rpc.Paginate(r, len(known))
+	if err != nil {
+		return nil, err
+	}
+	list := []*pb.KnownAddress{}
+	for _, i := range idx {
+		list = append(list, knownAddress(known[i]))
+	}
+	page.Items = list
+	return page, nil
+}
+
+// rpcPeerAudit returns the latest records of the peer audit log, oldest first,
+// e.g. /peer_audit?order=desc
+func (n *Node) rpcPeerAudit(r *http.Request) (interface{}, error) {
+	records := n.p2p.PeerAudit()
+	page, idx, err := rpc.Paginate(r, len(records))
+	if err != nil {
+		return nil, err
+	}
+	list := []*pb.PeerAuditRecord{}
+	for _, i := range idx {
+		rec := records[i]
+		list = append(list, &pb.PeerAuditRecord{
+			Time:      rfc3339(rec.Time),
+			Event:     rec.Event,
+			Peer:      rec.Peer,
+			Addr:      rec.Addr,
+			Direction: rec.Direction,
+			Reason:    rec.Reason,
+			Features:  rec.Features,
+		})
+	}
+	page.Items = list
+	return page, nil
+}
+
+// rpcDialPeers connects to the comma separated multiaddrs,
+// e.g. /unsafe_dial_peers?peers=/ip4/127.0.0.1/tcp/30002/p2p/QmQKp8pLWSgV4JiGjuULKV1JsdpxUtnDEUMP8sGaaUbwVL
+func (n *Node) rpcDialPeers(r *http.Request) (interface{}, error) {
+	peers := strings.Split(r.URL.Query().Get("peers"), ",")
+	if err := n.p2p.DialPeers(peers); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// rpcExportAddrBook returns the address book and the known peers for the disaster recovery,
+// see `gohotstuff addrbook export`.
+func (n *Node) rpcExportAddrBook(r *http.Request) (interface{}, error) {
+	return n.p2p.ExportAddrBook(), nil
+}
+
+// rpcImportAddrBook restores the address book posted as the body, as exported by /addr_book_export,
+// and dials the exported peers, see `gohotstuff addrbook import`.
+func (n *Node) rpcImportAddrBook(r *http.Request) (interface{}, error) {
+	if r.Method != http.MethodPost {
+		return nil, errors.New("the address book must be posted")
+	}
+	var e p2p.AddrBookExport
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxAddrBookBytes)).Decode(&e); err != nil {
+		return nil, fmt.Errorf("decode address book fail, err: %v", err)
+	}
+	dials, err := n.p2p.ImportAddrBook(&e)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ImportAddrBookResult{Known: int64(len(e.Known)), Peers: int64(len(e.Peers)), Dials: int64(dials)}, nil
+}
+
+// maxAddrBookBytes bounds the posted address book.
+const maxAddrBookBytes = 16 << 20
+
+// rpcSnapshot streams the committed blocks of the chain in [from, to] with their results in the format,
+// json (a block per line) by default, e.g. /snapshot?chain=&from=100&to=200&format=car
+func (n *Node) rpcSnapshot(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	c, ok := n.chains[q.Get("chain")]
+	if !ok {
+		rpc.WriteError(w, http.StatusNotFound, ErrUnknownChain)
+		return
+	}
+	from, err := intParam(r, "from", 0)
+	if err != nil {
+		rpc.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	to, err := intParam(r, "to", 0)
+	if err != nil {
+		rpc.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	format := q.Get("format")
+	if format == "" {
+		format = storage.ExportJSON
+	}
+	contentType, ok := snapshotContentTypes[format]
+	if !ok {
+		rpc.WriteError(w, http.StatusBadRequest, fmt.Errorf("%w: %s", storage.ErrUnknownExportFormat, format))
+		return
+	}
+	// the chain without a block store fails before the reply
+	if _, err := c.smr.BlockHeights(0, 0); err != nil {
+		rpc.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	// the reply can't be changed once the blocks are streamed, the client sees it truncated
+	if num, err := c.smr.Export(from, to, format, w); err != nil {
+		n.log.Warn("stream snapshot fail @ node.rpcSnapshot, chain: %s, exported: %d, err: %v", q.Get("chain"), num, err)
+	}
+}
+
+var snapshotContentTypes = map[string]string{
+	storage.ExportJSON: "application/x-ndjson",
+	storage.ExportCSV:  "text/csv",
+	storage.ExportCAR:  "application/vnd.ipld.car",
+}
+
+// rpcForceViewChange times out the current round of the chain at once, e.g. /unsafe_force_view_change?chain=
+func (n *Node) rpcForceViewChange(r *http.Request) (interface{}, error) {
+	id := r.URL.Query().Get("chain")
+	c, ok := n.chains[id]
+	if !ok {
+		return nil, ErrUnknownChain
+	}
+	if c.archive {
+		return nil, ErrArchive
+	}
+	round, err := c.smr.ForceViewChange()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ForceViewChangeResult{ChainId: id, Round: round}, nil
+}
+
+// rpcResyncFromHeight fetches the committed blocks of the chain from the height again,
+// e.g. /unsafe_resync_from_height?chain=&height=100
+func (n *Node) rpcResyncFromHeight(r *http.Request) (interface{}, error) {
+	id := r.URL.Query().Get("chain")
+	c, ok := n.chains[id]
+	if !ok {
+		return nil, ErrUnknownChain
+	}
+	if r.URL.Query().Get("height") == "" {
+		return nil, errors.New("height is required")
+	}
+	height, err := intParam(r, "height", 0)
+	if err != nil {
+		return nil, err
+	}
+	peers, err := c.observer.ResyncFrom(height)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ResyncResult{ChainId: id, FromHeight: height, Peers: int64(peers)}, nil
+}
+
+// rpcJoinRequests returns the pending requests of the candidates to join the validators, the application
+// approves one by committing its admission_tx, e.g. /join_requests?chain=&page=1
+func (n *Node) rpcJoinRequests(r *http.Request) (interface{}, error) {
+	c, ok := n.chains[r.URL.Query().Get("chain")]
+	if !ok {
+		return nil, ErrUnknownChain
+	}
+	if c.archive {
+		return nil, ErrArchive
+	}
+	reqs := c.smr.JoinRequests()
+	page, idx, err := rpc.Paginate(r, len(reqs))
+	if err != nil {
+		return nil, err
+	}
+	list := []*pb.JoinRequest{}
+	for _, i := range idx {
+		jr, err := joinRequest(reqs[i])
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, jr)
+	}
+	page.Items = list
+	return page, nil
+}
+
+// rpcRequestJoin asks the validators of the chain to admit the host, the address is the one
+// they reach the host at, e.g. /request_join?chain=&address=/ip4/1.2.3.4/tcp/26656
+func (n *Node) rpcRequestJoin(r *http.Request) (interface{}, error) {
+	q := r.URL.Query()
+	c, ok := n.chains[q.Get("chain")]
+	if !ok {
+		return nil, ErrUnknownChain
+	}
+	if c.archive {
+		return nil, ErrArchive
+	}
+	if q.Get("address") == "" {
+		return nil, errors.New("address is required")
+	}
+	req, err := c.join.RequestJoin(q.Get("address"))
+	if err != nil {
+		return nil, err
+	}
+	return joinRequest(req)
+}
+
+func joinRequest(r state.JoinRequest) (*pb.JoinRequest, error) {
+	tx, err := state.EncodeAdmission(r)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.JoinRequest{
+		Validator:   string(r.Key.ID),
+		Address:     r.Address,
+		PublicKey:   hex.EncodeToString(r.Key.PublicKey),
+		Timestamp:   r.Timestamp,
+		AdmissionTx: hex.EncodeToString(tx),
+	}, nil
+}
+
+func consensusParams(p state.ConsensusParams) *pb.ConsensusParams {
+	return &pb.ConsensusParams{
+		RoundTimeout:  int64(p.RoundTimeout),
+		MaxBlockTxs:   int64(p.MaxBlockTxs),
+		MaxBlockBytes: int64(p.MaxBlockBytes),
+		MaxTxBytes:    int64(p.MaxTxBytes),
+		EpochLength:   p.EpochLength,
+		MaxClockSkew:  int64(p.MaxClockSkew),
+	}
+}
+
+func blockResults(res *storage.BlockResults) *pb.BlockResults {
+	out := &pb.BlockResults{Height: res.Height}
+	for _, tx := range res.Txs {
+		r := &pb.TxResult{Code: tx.Code, Data: tx.Data, Log: tx.Log, GasUsed: tx.GasUsed}
+		for _, e := range tx.Events {
+			r.Events = append(r.Events, &pb.TxEvent{Type: e.Type, Attributes: e.Attributes})
+		}
+		out.Txs = append(out.Txs, r)
+	}
+	return out
+}
+
+func committedBlock(block *storage.CommittedBlock) *pb.Block {
+	b := &pb.Block{
+		Height:      block.Height,
+		Id:          hex.EncodeToString(block.ID),
+		ParentRound: block.ParentRound,
+		ParentId:    hex.EncodeToString(block.ParentID),
+		Proposer:    block.Proposer,
+		TxHashes:    []string{},
+		AppHeight:   block.AppHeight,
+		AppHash:     hex.EncodeToString(block.AppHash),
+	}
+	for _, tx := range block.Txs {
+		b.TxHashes = append(b.TxHashes, mempool.Tx(tx).Key())
+	}
+	if block.Time > 0 {
+		b.Time = rfc3339(time.Unix(block.Time, 0).UTC())
+	}
+	return b
+}
+
+func knownAddress(ka p2p.KnownAddress) *pb.KnownAddress {
+	return &pb.KnownAddress{
+		Addr:       ka.Addr,
+		Id:         ka.ID,
+		Suspicious: ka.Suspicious,
+		Reason:     ka.Reason,
+		MarkedAt:   rfc3339(ka.MarkedAt),
+		Verified:   ka.Verified,
+		VerifiedAt: rfc3339(ka.VerifiedAt),
+	}
+}
+
+func moduleTraffic(list []p2p.Traffic) []*pb.ModuleTraffic {
+	res := []*pb.ModuleTraffic{}
+	for _, t := range list {
+		res = append(res, &pb.ModuleTraffic{Module: string(t.Module), Sent: t.Sent, Received: t.Received})
+	}
+	return res
+}
+
+func peerIDs(ids []state.PeerID) []string {
+	list := make([]string, 0, len(ids))
+	for _, id := range ids {
+		list = append(list, string(id))
+	}
+	return list
+}
+
+// rfc3339 formats the time of the rpc results, the zero time is left empty.
+func rfc3339(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// milliseconds returns the duration in fractional milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func intParam(r *http.Request, key string, def int64) (int64, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return def, nil
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer, err: %v", key, err)
+	}
+	return i, nil
+}
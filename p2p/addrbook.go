@@ -0,0 +1,193 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/p2p/netaddr"
+	"github.com/multiformats/go-multiaddr"
+)
+
+var (
+	ErrIdentityMismatch = errors.New("peer identity mismatches the pinned one")
+	ErrSuspiciousAddr   = errors.New("address is marked suspicious")
+	ErrUnverifiedAddr   = errors.New("address fails the dial-back verification")
+)
+
+// KnownAddress is an entry of the address book.
+type KnownAddress struct {
+	// Addr is the transport part of the multiaddr, e.g. /ip4/127.0.0.1/tcp/30001
+	Addr string `json:"addr"`
+	// ID is the pinned peer id, which is derived from the public key of the peer,
+	// so a peer cannot present it without holding the private key.
+	ID         string    `json:"id,omitempty"`
+	Suspicious bool      `json:"suspicious"`
+	Reason     string    `json:"reason,omitempty"`
+	MarkedAt   time.Time `json:"marked_at,omitempty"`
+	// Verified means the address was gossiped and the pinned peer has been reached at it.
+	Verified   bool      `json:"verified,omitempty"`
+	VerifiedAt time.Time `json:"verified_at,omitempty"`
+}
+
+// AddrBook keeps the pinned identities of the configured addresses, the gossiped
+// addresses which passed the dial-back verification, and the addresses which
+// presented an unexpected identity.
+type AddrBook struct {
+	addrs map[string]*KnownAddress
+	mtx   sync.Mutex
+}
+
+func NewAddrBook() *AddrBook {
+	return &AddrBook{
+		addrs: make(map[string]*KnownAddress),
+	}
+}
+
+// PinMultiAddr pins the addresses of a full multiaddr, e.g. /ip4/127.0.0.1/tcp/30001/p2p/Qm...,
+// or of a host:port@id address, see netaddr.Parse.
+func (b *AddrBook) PinMultiAddr(multiAddr string) error {
+	info, err := netaddr.Parse(multiAddr)
+	if err != nil {
+		return err
+	}
+	for _, addr := range info.Addrs {
+		if err := b.Pin(addr, info.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pin expects the peer id at the addr, an addr can only be pinned to one peer.
+func (b *AddrBook) Pin(addr multiaddr.Multiaddr, id PeerID) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	ka := b.entry(addr)
+	if ka.ID != "" && ka.ID != id.Pretty() {
+		return fmt.Errorf("address has been pinned to another peer, addr: %s, pinned: %s, has: %s", ka.Addr, ka.ID, id.Pretty())
+	}
+	ka.ID = id.Pretty()
+	return nil
+}
+
+// Check returns ErrSuspiciousAddr if the addr has been marked before,
+// and ErrIdentityMismatch if the addr is pinned to another peer.
+func (b *AddrBook) Check(addr multiaddr.Multiaddr, id PeerID) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	ka, ok := b.addrs[addr.String()]
+	if !ok {
+		return nil
+	}
+	if ka.Suspicious {
+		return ErrSuspiciousAddr
+	}
+	if ka.ID != "" && ka.ID != id.Pretty() {
+		return ErrIdentityMismatch
+	}
+	return nil
+}
+
+// Expected returns the pinned peer id of the addr.
+func (b *AddrBook) Expected(addr multiaddr.Multiaddr) (string, bool) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	ka, ok := b.addrs[addr.String()]
+	if !ok || ka.ID == "" {
+		return "", false
+	}
+	return ka.ID, true
+}
+
+// AddVerified pins the gossiped addr to the peer reached at it by the dial-back.
+func (b *AddrBook) AddVerified(addr multiaddr.Multiaddr, id PeerID) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	ka := b.entry(addr)
+	if ka.Suspicious {
+		return ErrSuspiciousAddr
+	}
+	if ka.ID != "" && ka.ID != id.Pretty() {
+		return ErrIdentityMismatch
+	}
+	ka.ID = id.Pretty()
+	ka.Verified = true
+	ka.VerifiedAt = time.Now()
+	return nil
+}
+
+// Verified reports whether the addr has been verified or pinned to the peer.
+func (b *AddrBook) Verified(addr multiaddr.Multiaddr, id PeerID) bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	ka, ok := b.addrs[addr.String()]
+	return ok && !ka.Suspicious && ka.ID == id.Pretty()
+}
+
+func (b *AddrBook) MarkSuspicious(addr multiaddr.Multiaddr, reason string) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	ka := b.entry(addr)
+	ka.Suspicious = true
+	ka.Reason = reason
+	ka.MarkedAt = time.Now()
+}
+
+// List returns a copy of the entries sorted by addr.
+func (b *AddrBook) List() []KnownAddress {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	list := make([]KnownAddress, 0, len(b.addrs))
+	for _, ka := range b.addrs {
+		list = append(list, *ka)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Addr < list[j].Addr })
+	return list
+}
+
+// restore merges an exported entry, the suspicious marks are kept and an addr pinned to another peer is refused.
+func (b *AddrBook) restore(exported KnownAddress) error {
+	addr, err := multiaddr.NewMultiaddr(exported.Addr)
+	if err != nil {
+		return err
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	ka := b.entry(addr)
+	if exported.ID != "" {
+		if ka.ID != "" && ka.ID != exported.ID {
+			return ErrIdentityMismatch
+		}
+		ka.ID = exported.ID
+	}
+	if exported.Suspicious && !ka.Suspicious {
+		ka.Suspicious = true
+		ka.Reason = exported.Reason
+		ka.MarkedAt = exported.MarkedAt
+	}
+	if exported.Verified && !ka.Verified {
+		ka.Verified = true
+		ka.VerifiedAt = exported.VerifiedAt
+	}
+	return nil
+}
+
+func (b *AddrBook) entry(addr multiaddr.Multiaddr) *KnownAddress {
+	ka, ok := b.addrs[addr.String()]
+	if !ok {
+		ka = &KnownAddress{Addr: addr.String()}
+		b.addrs[ka.Addr] = ka
+	}
+	return ka
+}
@@ -0,0 +1,41 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+func TestAddrBookPin(t *testing.T) {
+	book := NewAddrBook()
+	if err := book.PinMultiAddr(node_1_id); err != nil {
+		t.Fatal(err)
+	}
+	addr, _ := multiaddr.NewMultiaddr("/ip4/127.0.0.1/tcp/30001")
+	pinned, _ := peer.Decode("Qmf2HeHe4sspGkfRCTq6257Vm3UHzvh2TeQJHHvHzzuFw6")
+	other, _ := peer.Decode("QmQKp8pLWSgV4JiGjuULKV1JsdpxUtnDEUMP8sGaaUbwVL")
+
+	if err := book.Check(addr, pinned); err != nil {
+		t.Errorf("pinned peer should pass, err: %v", err)
+	}
+	if err := book.Check(addr, other); err != ErrIdentityMismatch {
+		t.Errorf("want: %v, has: %v", ErrIdentityMismatch, err)
+	}
+	if err := book.Pin(addr, other); err == nil {
+		t.Errorf("addr should not be pinned to another peer")
+	}
+	unknown, _ := multiaddr.NewMultiaddr("/ip4/127.0.0.1/tcp/30002")
+	if err := book.Check(unknown, other); err != nil {
+		t.Errorf("unpinned addr should pass, err: %v", err)
+	}
+
+	book.MarkSuspicious(addr, "test")
+	if err := book.Check(addr, pinned); err != ErrSuspiciousAddr {
+		t.Errorf("want: %v, has: %v", ErrSuspiciousAddr, err)
+	}
+	list := book.List()
+	if len(list) != 1 || !list[0].Suspicious || list[0].ID != pinned.Pretty() {
+		t.Errorf("unexpected entries: %+v", list)
+	}
+}
@@ -0,0 +1,200 @@
+package p2p
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/peerstore"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// AddrBookVersion is the version of the exported address book.
+const AddrBookVersion = 1
+
+var (
+	ErrAddrBookVersion  = errors.New("unsupported address book version")
+	ErrSwitchNotStarted = errors.New("switch is not started")
+)
+
+// AddrBookExport is the connectivity state of a node, i.e. its address book and the addresses
+// of the peers it knows, so that a rebuilt node reconnects at once instead of rediscovering them.
+type AddrBookExport struct {
+	Version    int            `json:"version"`
+	NodeID     string         `json:"node_id,omitempty"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Known      []KnownAddress `json:"known"`
+	Peers      []PeerRecord   `json:"peers"`
+}
+
+// PeerRecord is a peer known by the peerstore at the time of the export.
+type PeerRecord struct {
+	ID        string   `json:"id"`
+	Addrs     []string `json:"addrs"`
+	Validator bool     `json:"validator,omitempty"`
+	Connected bool     `json:"connected,omitempty"`
+}
+
+// ExportAddrBook returns the address book and the peers known by the peerstore, the validators first.
+func (sw *Switch) ExportAddrBook() *AddrBookExport {
+	e := &AddrBookExport{
+		Version:    AddrBookVersion,
+		ExportedAt: time.Now(),
+		Known:      sw.book.List(),
+		Peers:      []PeerRecord{},
+	}
+	if sw.host == nil {
+		return e
+	}
+	e.NodeID = sw.host.ID().Pretty()
+	c := sw.newPeerClasser()
+	for _, id := range sw.host.Peerstore().PeersWithAddrs() {
+		if id == sw.host.ID() {
+			continue
+		}
+		rec := PeerRecord{ID: id.Pretty(), Validator: c.class(id) == PeerClassValidator}
+		for _, addr := range sw.host.Peerstore().Addrs(id) {
+			rec.Addrs = append(rec.Addrs, addr.String())
+		}
+		if _, err := sw.peers.Find(id); err == nil {
+			rec.Connected = true
+		}
+		e.Peers = append(e.Peers, rec)
+	}
+	sort.Slice(e.Peers, func(i, j int) bool {
+		if e.Peers[i].Validator != e.Peers[j].Validator {
+			return e.Peers[i].Validator
+		}
+		return e.Peers[i].ID < e.Peers[j].ID
+	})
+	return e
+}
+
+// ImportAddrBook restores the exported address book on top of the current one and queues the dials
+// of the exported peers, the validators and the formerly connected ones first. The entries
+// conflicting with the current pins are skipped, the number of the dials queued is returned.
+func (sw *Switch) ImportAddrBook(e *AddrBookExport) (int, error) {
+	if e.Version != AddrBookVersion {
+		return 0, fmt.Errorf("%w, has: %d", ErrAddrBookVersion, e.Version)
+	}
+	if sw.host == nil {
+		return 0, ErrSwitchNotStarted
+	}
+	for _, ka := range e.Known {
+		if err := sw.book.restore(ka); err != nil {
+			sw.log.Warn("restore known address fail @ p2p.ImportAddrBook, addr: %s, err: %v", ka.Addr, err)
+		}
+	}
+
+	records := append([]PeerRecord(nil), e.Peers...)
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Validator != records[j].Validator {
+			return records[i].Validator
+		}
+		return records[i].Connected && !records[j].Connected
+	})
+	queued := 0
+	for _, rec := range records {
+		id, err := peer.Decode(rec.ID)
+		if err != nil || id == sw.host.ID() {
+			continue
+		}
+		var addrs []multiaddr.Multiaddr
+		for _, s := range rec.Addrs {
+			addr, err := multiaddr.NewMultiaddr(s)
+			if err != nil {
+				sw.log.Warn("invalid peer addr @ p2p.ImportAddrBook, peer_id: %s, addr: %s, err: %v", rec.ID, s, err)
+				continue
+			}
+			// the addrs pinned to another peer or marked suspicious are refused on connect anyway
+			if sw.book.Check(addr, id) != nil {
+				continue
+			}
+			addrs = append(addrs, addr)
+		}
+		if len(addrs) == 0 {
+			continue
+		}
+		sw.host.Peerstore().AddAddrs(id, addrs, peerstore.AddressTTL)
+		if _, err := sw.peers.Find(id); err == nil {
+			continue
+		}
+		multiAddr := fmt.Sprintf("%s/p2p/%s", addrs[0], rec.ID)
+		if err := sw.dialer.Enqueue(multiAddr); err != nil && err != ErrDialPending {
+			sw.log.Warn("queue dial fail @ p2p.ImportAddrBook, remote_peer: %s, err: %v", multiAddr, err)
+			continue
+		}
+		queued++
+	}
+	return queued, nil
+}
+
+// ReadAddrBook reads the address book exported to the file.
+func ReadAddrBook(path string) (*AddrBookExport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var e AddrBookExport
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("decode address book fail, path: %s, err: %v", path, err)
+	}
+	if e.Version != AddrBookVersion {
+		return nil, fmt.Errorf("%w, path: %s, has: %d", ErrAddrBookVersion, path, e.Version)
+	}
+	return &e, nil
+}
+
+// WriteAddrBook writes the exported address book readable by the owner only, atomically,
+// the addresses of the validators are sensitive.
+func WriteAddrBook(path string, e *AddrBookExport) error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadAddrBook imports the address book saved by the last run, see Config.AddrBookPath.
+func (sw *Switch) loadAddrBook() {
+	if sw.cfg.AddrBookPath == "" {
+		return
+	}
+	e, err := ReadAddrBook(sw.cfg.AddrBookPath)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		sw.log.Warn("read address book fail @ p2p.loadAddrBook, path: %s, err: %v", sw.cfg.AddrBookPath, err)
+		return
+	}
+	queued, err := sw.ImportAddrBook(e)
+	if err != nil {
+		sw.log.Warn("import address book fail @ p2p.loadAddrBook, path: %s, err: %v", sw.cfg.AddrBookPath, err)
+		return
+	}
+	sw.log.Info("address book imported @ p2p.loadAddrBook, path: %s, known: %d, dials: %d",
+		sw.cfg.AddrBookPath, len(e.Known), queued)
+}
+
+// saveAddrBook saves the address book for the next run, see Config.AddrBookPath.
+func (sw *Switch) saveAddrBook() {
+	if sw.cfg.AddrBookPath == "" {
+		return
+	}
+	if err := WriteAddrBook(sw.cfg.AddrBookPath, sw.ExportAddrBook()); err != nil {
+		sw.log.Warn("save address book fail @ p2p.saveAddrBook, path: %s, err: %v", sw.cfg.AddrBookPath, err)
+	}
+}
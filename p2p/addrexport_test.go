@@ -0,0 +1,76 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/multiformats/go-multiaddr"
+)
+
+func TestAddrBookExportImport(t *testing.T) {
+	mn := mocknet.New(context.Background())
+	r1, r3 := newRecvReactor(), newRecvReactor()
+	sw1 := newMockSwitch(t, mn, r1)
+	sw2 := newMockSwitch(t, mn, newRecvReactor())
+	// sw3 replaces sw1 on a rebuilt machine
+	sw3 := newMockSwitch(t, mn, r3)
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	addr := fmt.Sprintf("%s/p2p/%s", sw2.host.Addrs()[0], sw2.host.ID().Pretty())
+	if err := sw1.DialPeers([]string{addr}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-r1.peers:
+	case <-time.After(3 * time.Second):
+		t.Fatal("peer should be added")
+	}
+	bad, _ := multiaddr.NewMultiaddr("/ip4/10.0.0.1/tcp/30001")
+	sw1.book.MarkSuspicious(bad, "identity mismatch")
+
+	path := filepath.Join(t.TempDir(), "addrbook.json")
+	if err := WriteAddrBook(path, sw1.ExportAddrBook()); err != nil {
+		t.Fatal(err)
+	}
+	e, err := ReadAddrBook(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported *PeerRecord
+	for i := range e.Peers {
+		if e.Peers[i].ID == sw2.host.ID().Pretty() {
+			exported = &e.Peers[i]
+		}
+	}
+	if exported == nil || !exported.Connected || len(exported.Addrs) == 0 {
+		t.Fatalf("want the connected peer exported, has: %+v", e.Peers)
+	}
+
+	dials, err := sw3.ImportAddrBook(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dials != 1 {
+		t.Errorf("want 1 dial queued, has: %d", dials)
+	}
+	select {
+	case <-r3.peers:
+	case <-time.After(3 * time.Second):
+		t.Fatal("the replacement should reconnect to the exported peer")
+	}
+	if err := sw3.book.Check(bad, sw2.host.ID()); err != ErrSuspiciousAddr {
+		t.Errorf("the suspicious mark should be imported, has: %v", err)
+	}
+
+	e.Version = AddrBookVersion + 1
+	if _, err := sw3.ImportAddrBook(e); !errors.Is(err, ErrAddrBookVersion) {
+		t.Errorf("want: %v, has: %v", ErrAddrBookVersion, err)
+	}
+}
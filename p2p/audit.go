@@ -0,0 +1,189 @@
+package p2p
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// the events of the peer audit log
+const (
+	AuditConnect    = "connect"
+	AuditDisconnect = "disconnect"
+	// AuditRefuse is a connection refused before the peer is added, e.g. by the gater or the handshake.
+	AuditRefuse = "refuse"
+	// AuditBan is an address marked suspicious, which is never dialed again.
+	AuditBan = "ban"
+)
+
+// DefaultAuditRecent is the number of the latest audit records kept for the rpc.
+const DefaultAuditRecent = 1000
+
+// AuditRecord is an entry of the peer audit log, Direction is inbound or outbound.
+type AuditRecord struct {
+	Time      time.Time `json:"time"`
+	Event     string    `json:"event"`
+	Peer      string    `json:"peer,omitempty"`
+	Addr      string    `json:"addr,omitempty"`
+	Direction string    `json:"direction,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Features  []string  `json:"features,omitempty"`
+}
+
+// auditLog appends the records to the file as json lines, each synced before it's acknowledged,
+// and keeps the latest ones in memory for the rpc.
+type auditLog struct {
+	mtx    sync.Mutex
+	file   *os.File
+	recent []AuditRecord
+	next   int
+	full   bool
+}
+
+// openAuditLog opens the file for appending, empty path keeps the records in memory only.
+func openAuditLog(path string, recent int) (*auditLog, error) {
+	if recent <= 0 {
+		recent = DefaultAuditRecent
+	}
+	a := &auditLog{recent: make([]AuditRecord, recent)}
+	if path == "" {
+		return a, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	a.file = f
+	return a, nil
+}
+
+func (a *auditLog) record(r AuditRecord) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.recent[a.next] = r
+	a.next = (a.next + 1) % len(a.recent)
+	if a.next == 0 {
+		a.full = true
+	}
+	if a.file == nil {
+		return nil
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := a.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return a.file.Sync()
+}
+
+// list returns the records kept in memory, oldest first.
+func (a *auditLog) list() []AuditRecord {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if !a.full {
+		return append([]AuditRecord{}, a.recent[:a.next]...)
+	}
+	return append(append([]AuditRecord{}, a.recent[a.next:]...), a.recent[:a.next]...)
+}
+
+func (a *auditLog) close() error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
+
+// PeerAudit returns the latest records of the peer audit log, oldest first.
+func (sw *Switch) PeerAudit() []AuditRecord {
+	if sw.audit == nil {
+		return nil
+	}
+	return sw.audit.list()
+}
+
+func (sw *Switch) auditRecord(r AuditRecord) {
+	if sw.audit == nil {
+		return
+	}
+	r.Time = sw.clock.Now()
+	if err := sw.audit.record(r); err != nil {
+		sw.log.Error("write audit record fail @ p2p.auditRecord, record: %+v, err: %v", r, err)
+	}
+}
+
+// auditPeer records the event of a peer which has been added and publishes the change of the peer set.
+func (sw *Switch) auditPeer(event string, p Peer, reason interface{}) {
+	r := AuditRecord{
+		Event:     event,
+		Peer:      p.ID().Pretty(),
+		Direction: direction(p.Outbound()),
+		Features:  featureNames(p),
+	}
+	if dp, ok := p.(*DefaultPeer); ok && dp.conn != nil && dp.conn.stream != nil {
+		r.Addr = dp.conn.stream.Conn().RemoteMultiaddr().String()
+	}
+	if reason != nil {
+		r.Reason = fmt.Sprintf("%v", reason)
+	}
+	sw.auditRecord(r)
+	sw.publishPeer(r, p)
+}
+
+// auditStream records the refused stream, whose peer has never been added.
+func (sw *Switch) auditStream(stream network.Stream, reason error) {
+	conn := stream.Conn()
+	sw.auditRecord(AuditRecord{
+		Event:     AuditRefuse,
+		Peer:      conn.RemotePeer().Pretty(),
+		Addr:      conn.RemoteMultiaddr().String(),
+		Direction: direction(conn.Stat().Direction == network.DirOutbound),
+		Reason:    reason.Error(),
+	})
+}
+
+// ban marks the address suspicious, records and publishes it.
+func (sw *Switch) ban(addr multiaddr.Multiaddr, id string, reason string) {
+	sw.book.MarkSuspicious(addr, reason)
+	r := AuditRecord{
+		Event:  AuditBan,
+		Peer:   id,
+		Addr:   addr.String(),
+		Reason: reason,
+	}
+	sw.auditRecord(r)
+	sw.publishBan(r)
+}
+
+func direction(outbound bool) string {
+	if outbound {
+		return "outbound"
+	}
+	return "inbound"
+}
+
+func featureNames(p Peer) []string {
+	f := p.Features().String()
+	if f == "" {
+		return nil
+	}
+	return strings.Split(f, "|")
+}
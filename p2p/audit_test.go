@@ -0,0 +1,75 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+func TestPeerAudit(t *testing.T) {
+	mn := mocknet.New(context.Background())
+	sw := newMockSwitch(t, mn, newRecvReactor())
+	path := filepath.Join(t.TempDir(), "audit", "peers.log")
+	audit, err := openAuditLog(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw.audit = audit
+
+	remote := PeerID(string(sw.host.ID()) + "\xff")
+	sw.addPeer(NewMockPeer(remote, true))
+	sw.addPeer(NewMockPeer(remote, false))
+	sw.stopPeerForError(remote, errors.New("bad msg"))
+	sw.ban(sw.host.Addrs()[0], remote.Pretty(), "dial-back fail")
+	audit.close()
+
+	want := []AuditRecord{
+		{Event: AuditConnect, Peer: remote.Pretty(), Direction: "outbound"},
+		{Event: AuditRefuse, Peer: remote.Pretty(), Direction: "inbound", Reason: ErrDuplicateConn.Error()},
+		{Event: AuditDisconnect, Peer: remote.Pretty(), Direction: "outbound", Reason: "bad msg"},
+		{Event: AuditBan, Peer: remote.Pretty(), Addr: sw.host.Addrs()[0].String(), Reason: "dial-back fail"},
+	}
+	same := func(has, want AuditRecord) bool {
+		return has.Event == want.Event && has.Peer == want.Peer && has.Addr == want.Addr &&
+			has.Direction == want.Direction && has.Reason == want.Reason && !has.Time.IsZero()
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var logged []AuditRecord
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		var r AuditRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		logged = append(logged, r)
+	}
+	if len(logged) != len(want) {
+		t.Fatalf("want %d records logged, has: %+v", len(want), logged)
+	}
+	for i := range want {
+		if !same(logged[i], want[i]) {
+			t.Errorf("record %d, want: %+v, has: %+v", i, want[i], logged[i])
+		}
+	}
+
+	// the oldest record has been evicted from the memory
+	recent := sw.PeerAudit()
+	if len(recent) != 3 {
+		t.Fatalf("want the latest 3 records, has: %+v", recent)
+	}
+	for i := range recent {
+		if !same(recent[i], want[i+1]) {
+			t.Errorf("recent %d, want: %+v, has: %+v", i, want[i+1], recent[i])
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+var ErrChannelUnauthorized = errors.New("peer is not authorized to send on the channel")
+
+// authorizeCbFunc reports whether the remote peer may send on the channel, the conn drops the msgs otherwise.
+type authorizeCbFunc func(id PeerID, chID int32) bool
+
+// PeerRole is the bitmap of the roles of a peer, the channels are restricted to the roles by Config.ChannelRoles.
+type PeerRole uint8
+
+const (
+	// RoleValidator is a current validator of the chain of the channel, every peer is one
+	// if the chain has no validator set registered, see SetValidatorSet.
+	RoleValidator PeerRole = 1 << iota
+	// RoleSyncServer is a peer of Config.SyncServers, which serves the blocks to the observers.
+	RoleSyncServer
+	// RoleObserver is any other peer.
+	RoleObserver
+)
+
+var peerRoleNames = []struct {
+	r    PeerRole
+	name string
+}{
+	{RoleValidator, "validator"},
+	{RoleSyncServer, "syncserver"},
+	{RoleObserver, "observer"},
+}
+
+// String joins the names of the roles, the unknown bits are printed in hex.
+func (r PeerRole) String() string {
+	var names []string
+	for _, n := range peerRoleNames {
+		if r&n.r != 0 {
+			names = append(names, n.name)
+			r &^= n.r
+		}
+	}
+	if r != 0 {
+		names = append(names, fmt.Sprintf("%#x", uint8(r)))
+	}
+	return strings.Join(names, "|")
+}
+
+// ParsePeerRoles returns the roles of the names, e.g. the ones in conf.yaml.
+func ParsePeerRoles(names []string) (PeerRole, error) {
+	var r PeerRole
+	for _, name := range names {
+		found := false
+		for _, n := range peerRoleNames {
+			if n.name == name {
+				r, found = r|n.r, true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown peer role: %s", name)
+		}
+	}
+	return r, nil
+}
+
+// authorize reports whether the peer may send on the channel, i.e. the channel isn't restricted or the peer
+// has one of the roles allowed on it. The violations cost the peer ChannelViolationPenalty of its score.
+func (sw *Switch) authorize(id PeerID, chID int32) bool {
+	allowed, ok := sw.channelRoles(chID)
+	if !ok {
+		return true
+	}
+	roles := sw.peerRoles(id, chID)
+	if roles&allowed != 0 {
+		return true
+	}
+	sw.penalize(id, ChannelViolationPenalty, fmt.Errorf("%w, channel: %d, roles: %s, allowed: %s",
+		ErrChannelUnauthorized, chID, roles, allowed))
+	return false
+}
+
+// channelRoles returns the roles allowed on the channel by the base name of its module,
+// so that the rule of a module covers its channels of all the chains, see libs.ChainModule.
+func (sw *Switch) channelRoles(chID int32) (PeerRole, bool) {
+	if len(sw.cfg.ChannelRoles) == 0 {
+		return 0, false
+	}
+	sw.mtx.Lock()
+	module, ok := sw.channels[chID]
+	sw.mtx.Unlock()
+	if !ok {
+		return 0, false
+	}
+	name := string(module)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	roles, ok := sw.cfg.ChannelRoles[name]
+	return roles, ok
+}
+
+// peerRoles returns the roles of the peer on the channel, the validators are the ones of the consensus channel
+// of the same chain.
+func (sw *Switch) peerRoles(id PeerID, chID int32) PeerRole {
+	sw.mtx.Lock()
+	set := sw.validatorSets[chID&^0xff|libs.ConsensusChannel]
+	sw.mtx.Unlock()
+
+	var roles PeerRole
+	if set == nil {
+		roles |= RoleValidator
+	} else {
+		for _, v := range set() {
+			if p2pID, err := sw.GetP2PID(v); err == nil && p2pID == id.Pretty() {
+				roles |= RoleValidator
+				break
+			}
+		}
+	}
+	for _, s := range sw.cfg.SyncServers {
+		if s == id.Pretty() {
+			roles |= RoleSyncServer
+			break
+		}
+	}
+	if roles == 0 {
+		roles = RoleObserver
+	}
+	return roles
+}
@@ -0,0 +1,84 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+func TestChannelAuthorization(t *testing.T) {
+	ids := make([]PeerID, 3)
+	for i, s := range []string{
+		"QmYyQSo1c1Ym7orWxLYvCrM2EmxFTANf8wXmmE7DWjhx5N",
+		"QmSoLPppuBtQSGwKDZT2M73ULpjvfd3aZ6ha4oFGL1KrGM",
+		"QmSoLV4Bbm51jM9C4gDYZQ9Cy3U6aXMJDAbzgu2fzaDs64",
+	} {
+		ids[i], _ = peer.Decode(s)
+	}
+	validator, syncServer, observer := ids[0], ids[1], ids[2]
+
+	roles, err := ParsePeerRoles([]string{"validator", "syncserver"})
+	if err != nil || roles != RoleValidator|RoleSyncServer {
+		t.Fatalf("unexpected roles: %s, err: %v", roles, err)
+	}
+	if _, err := ParsePeerRoles([]string{"leader"}); err == nil {
+		t.Error("want the unknown role refused")
+	}
+	sw, err := NewSwitch(&Config{
+		NetworkID:    "test",
+		ChannelRoles: map[string]PeerRole{libs.ConsensusModule: RoleValidator, libs.ObserverModule: roles},
+		SyncServers:  []string{syncServer.Pretty()},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chainCh := libs.ChainChannel("side", libs.ConsensusChannel)
+	sw.channels[chainCh] = Module(libs.ChainModule("side", libs.ConsensusModule))
+
+	// every peer is a validator without a validator set
+	if !sw.authorize(observer, libs.ConsensusChannel) {
+		t.Error("want every peer authorized without a validator set")
+	}
+	sw.SetValidatorSet(libs.ConsensusChannel, func() []string { return []string{validator.Pretty()} })
+	for _, c := range []struct {
+		id   PeerID
+		chID int32
+		want bool
+	}{
+		{validator, libs.ConsensusChannel, true},
+		{syncServer, libs.ConsensusChannel, false},
+		{observer, libs.ConsensusChannel, false},
+		{syncServer, libs.ObserverChannel, true},
+		{observer, libs.ObserverChannel, false},
+		{observer, libs.MempoolChannel, true},
+		// the rule covers the channels of the other chains, which have no validator set
+		{observer, chainCh, true},
+	} {
+		if has := sw.authorize(c.id, c.chID); has != c.want {
+			t.Errorf("want: %v, has: %v, peer_id: %s, channel: %d", c.want, has, c.id.Pretty(), c.chID)
+		}
+	}
+	if score := sw.PeerScore(observer.Pretty()); score != -2*ChannelViolationPenalty {
+		t.Errorf("want the violations scored, has: %d", score)
+	}
+
+	// the peer is stopped once its score reaches the min
+	p := NewMockPeer(observer, false)
+	if err := sw.peers.Add(p); err != nil {
+		t.Fatal(err)
+	}
+	for sw.PeerScore(observer.Pretty()) > MinPeerScore+ChannelViolationPenalty {
+		sw.authorize(observer, libs.ConsensusChannel)
+	}
+	if _, err := sw.peers.Find(observer); err != nil {
+		t.Fatal("the peer shouldn't be stopped yet")
+	}
+	sw.authorize(observer, libs.ConsensusChannel)
+	if _, err := sw.peers.Find(observer); err == nil {
+		t.Error("want the peer stopped for its score")
+	}
+	if score := sw.PeerScore(observer.Pretty()); score != 0 {
+		t.Errorf("want the score starting over, has: %d", score)
+	}
+}
@@ -2,7 +2,6 @@ package p2p
 
 import (
 	"crypto/rand"
-	"encoding/base64"
 	"errors"
 	"os"
 	"path/filepath"
@@ -12,8 +11,14 @@ import (
 	"github.com/libp2p/go-libp2p-core/peer"
 )
 
-func GenerateKeyPairWithPath(path string) error {
+func generateNodeKey() (crypto.PrivKey, error) {
 	priv, _, err := crypto.GenerateKeyPairWithReader(crypto.RSA, 2048, rand.Reader)
+	return priv, err
+}
+
+// GenerateKeyPairWithPath writes a new node key into path/node_key.json.
+func GenerateKeyPairWithPath(path string) error {
+	priv, err := generateNodeKey()
 	if err != nil {
 		return err
 	}
@@ -21,20 +26,18 @@ func GenerateKeyPairWithPath(path string) error {
 	if len(path) == 0 {
 		return errors.New("p2p key path empty")
 	}
-
-	privData, err := crypto.MarshalPrivateKey(priv)
-	if err != nil {
-		return err
-	}
-
-	return os.WriteFile(filepath.Join(path, "private.key"), []byte(base64.StdEncoding.EncodeToString(privData)), 0700)
+	return saveNodeKey(filepath.Join(path, NodeKeyFileName), priv)
 }
 
+// GetKeyPairFromPath reads path/node_key.json, or the legacy path/private.key if it's not migrated yet.
 func GetKeyPairFromPath(path string) (crypto.PrivKey, error) {
 	if len(path) <= 0 {
 		return nil, errors.New("p2p key path empty")
 	}
-	f := filepath.Join(path, "private.key")
+	if f := filepath.Join(path, NodeKeyFileName); libs.FileIsExist(f) {
+		return loadNodeKey(f)
+	}
+	f := filepath.Join(path, legacyKeyFileName)
 	if !libs.FileIsExist(f) {
 		return nil, errors.New("invalid p2p key path")
 	}
@@ -42,12 +45,7 @@ func GetKeyPairFromPath(path string) (crypto.PrivKey, error) {
 	if err != nil {
 		return nil, err
 	}
-
-	privData, err := base64.StdEncoding.DecodeString(string(data))
-	if err != nil {
-		return nil, err
-	}
-	return crypto.UnmarshalPrivateKey(privData)
+	return decodeInlineKey(string(data))
 }
 
 func GetPeerIDFromPath(path string) (string, error) {
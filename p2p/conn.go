@@ -4,13 +4,17 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"runtime/debug"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/astaxie/beego/logs"
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
 	"github.com/aucusaga/gohotstuff/libs"
-	"github.com/aucusaga/gohotstuff/pb"
-	ggio "github.com/gogo/protobuf/io"
+	"github.com/aucusaga/gohotstuff/libs/service"
+	wire "github.com/aucusaga/gohotstuff/p2p/conn"
+	"github.com/aucusaga/gohotstuff/p2p/tap"
 	"github.com/libp2p/go-libp2p-core/network"
 )
 
@@ -20,17 +24,37 @@ const (
 	defaultMaxPacketMsgPayloadSize = 1024
 	defaultSendQueueCapacity       = 1024
 	defaultRecvBufferCapacity      = 1024
+	// DefaultMaxMsgSize is the frame limit used when Config.MaxMsgSize is not set.
+	DefaultMaxMsgSize = wire.DefaultMaxPayloadSize
+	// DefaultFrameTimeout bounds the read of a frame once its first byte arrives, see Config.FrameTimeout.
+	DefaultFrameTimeout = 10 * time.Second
 )
 
 type Module string
 
 type RawConn interface {
 	Start()
+	Stop()
 	FlushStop()
 	Send(int32, []byte) bool
+	// SendWithResult queues the msg like Send, the chan resolves once the msg is written to the stream,
+	// or it fails to be queued or written, see DefaultConn.SendWithResult.
+	SendWithResult(int32, []byte) <-chan error
 }
 
+// errorCbFunc is invoked when the conn meets an unrecoverable error caused by the remote peer.
+type errorCbFunc func(id PeerID, reason interface{})
+
+// closeCbFunc is invoked once the stream of the conn fails or is closed by the remote peer,
+// it's not invoked if the conn is stopped by ourselves.
+type closeCbFunc func(conn *DefaultConn, err error)
+
 type DefaultConn struct {
+	// lastActive is the unix nano of the latest frame sent or received, atomic.
+	lastActive int64
+	// started gates the scheduling of the send queues on the sendPool, atomic.
+	started int32
+
 	peer   NodeInfo
 	stream network.Stream
 
@@ -39,52 +63,138 @@ type DefaultConn struct {
 	channelsIdx  map[int32]*Channel
 	onReceiveIdx map[Module]libs.Reactor
 
-	reader        ggio.ReadCloser
-	bufConnWriter ggio.WriteCloser
+	reader *wire.Decoder
+	writer *wire.Encoder
+	// maxMsgSize bounds the frames in both directions, the peers reject the larger ones
+	maxMsgSize int
+	// frameTimer resets the streams without read deadlines once a frame trickles in, see setFrameDeadline
+	frameTimer *time.Timer
+	// env is stamped on the msgs sent and checked on the ones received
+	env envelope
 
-	log libs.Logger
+	onError   errorCbFunc
+	onClose   closeCbFunc
+	expired   expiredCbFunc
+	authorize authorizeCbFunc
+	// lag detects the slow peer and sizes the chunks of its msgs, nil if neither is enabled
+	lag *lagTracker
+	// tap captures the msgs for debugging, nil means disabled
+	tap      tap.Recorder
+	stopOnce sync.Once
+	writeMtx sync.Mutex
+	// routines are the send loops of the channels and the recvRoutine
+	routines service.Supervisor
+	// sendPool writes the send queues instead of the send loops, and handlePool dispatches the received msgs,
+	// both are shared by the conns of the switch, nil means a goroutine of the conn for either, see workerPool.
+	sendPool   *workerPool
+	handlePool *workerPool
+
+	// msgs logs the payloads sent and received
+	msgs *MsgLogger
+	// traffic counts the bytes of the conn by the module, its parent is the meter of the switch
+	traffic *trafficMeter
+	log     libs.Logger
 }
 
-func NewDefaultConn(peer NodeInfo, netStream network.Stream,
-	onReceiveIdx map[Module]libs.Reactor, logger libs.Logger) (*DefaultConn, error) {
+func NewDefaultConn(peer NodeInfo, netStream network.Stream, onReceiveIdx map[Module]libs.Reactor,
+	channels map[int32]Module, onError errorCbFunc, onClose closeCbFunc, recorder tap.Recorder,
+	expired expiredCbFunc, authorize authorizeCbFunc, lag *lagTracker, maxMsgSize int, frameTimeout time.Duration, env envelope,
+	msgs *MsgLogger, traffic *trafficMeter, sendPool, handlePool *workerPool, logger libs.Logger) (*DefaultConn, error) {
 	if logger == nil {
 		logger = logs.NewLogger()
 	}
-	w := ggio.NewDelimitedWriter(netStream)
-	rc := ggio.NewDelimitedReader(netStream, defaultMaxPacketMsgSize)
-
+	if msgs == nil {
+		msgs, _ = NewMsgLogger(MsgLogConfig{}, nil, logger)
+	}
+	if maxMsgSize <= 0 {
+		maxMsgSize = wire.DefaultMaxPayloadSize
+	}
 	dc := &DefaultConn{
-		peer:          peer,
-		stream:        netStream,
-		quit:          make(chan struct{}, 1),
-		channels:      make([]*Channel, 0),
-		channelsIdx:   make(map[int32]*Channel),
-		onReceiveIdx:  onReceiveIdx,
-		reader:        rc,
-		bufConnWriter: w,
-		log:           logger,
+		peer:         peer,
+		stream:       netStream,
+		quit:         make(chan struct{}, 1),
+		channels:     make([]*Channel, 0),
+		channelsIdx:  make(map[int32]*Channel),
+		onReceiveIdx: onReceiveIdx,
+		reader:       wire.NewDecoder(netStream, maxMsgSize),
+		maxMsgSize:   maxMsgSize,
+		env:          env,
+		writer:       wire.NewEncoder(netStream),
+		onError:      onError,
+		onClose:      onClose,
+		tap:          recorder,
+		expired:      expired,
+		authorize:    authorize,
+		lag:          lag,
+		msgs:         msgs,
+		traffic:      newTrafficMeter(traffic, nil),
+		sendPool:     sendPool,
+		handlePool:   handlePool,
+		log:          logger,
 	}
+	if frameTimeout > 0 {
+		dc.reader.SetFrameTimeout(frameTimeout, dc.setFrameDeadline)
+	}
+	dc.touch()
+	dc.routines.SetLogger(logger)
 
-	// default 0 channel
-	dc.AddChannel(0)
+	for id, mo := range channels {
+		dc.AddChannel(id, mo)
+	}
 
 	return dc, nil
 }
 
-func (dc *DefaultConn) AddChannel(id int32) error {
+func (dc *DefaultConn) AddChannel(id int32, mo Module) error {
 	if _, ok := dc.channelsIdx[id]; ok {
 		dc.log.Warn("channel has been registered before @ AddChannel, id: %d", id)
 		return nil
 	}
-	c := NewChannel(id, dc, dc.log)
+	c := NewChannel(id, mo, dc, dc.log)
 	dc.channels = append(dc.channels, c)
 	dc.channelsIdx[id] = c
 	return nil
 }
 
 func (dc *DefaultConn) Start() {
-	go dc.sendRoutine()
-	go dc.recvRoutine()
+	if dc.sendPool == nil {
+		dc.sendRoutine()
+	} else {
+		// the msgs queued before the start are scheduled now
+		atomic.StoreInt32(&dc.started, 1)
+		for _, ch := range dc.channels {
+			if len(ch.sendQueue) > 0 {
+				ch.schedule()
+			}
+		}
+	}
+	dc.routines.Spawn("recv", dc.recvRoutine)
+}
+
+// Stop closes the connection without flushing the pending msgs.
+func (dc *DefaultConn) Stop() {
+	dc.stopOnce.Do(func() {
+		close(dc.quit)
+		dc.routines.Stop()
+		dc.stream.Reset()
+
+		// the senders waiting for the results of the pending msgs needn't wait out their timeouts
+		for _, ch := range dc.channels {
+			for len(ch.sendQueue) > 0 {
+				select {
+				case msg := <-ch.sendQueue:
+					atomic.AddInt32(&ch.sendQueueSize, -1)
+					msg.resolve(ErrConnStopped)
+				default:
+				}
+			}
+		}
+	})
+}
+
+// Outbound reports whether the stream was initiated by us.
+func (dc *DefaultConn) Outbound() bool {
+	return dc.stream.Stat().Direction == network.DirOutbound
 }
 
 func (dc *DefaultConn) Send(chID int32, msgBytes []byte) bool {
@@ -95,59 +205,79 @@ func (dc *DefaultConn) Send(chID int32, msgBytes []byte) bool {
 		return false
 	}
 
-	success := channel.sendBytes(msgBytes)
+	success := channel.sendBytes(msgBytes, nil)
 	dc.log.Info("send complete @ conn.Send, out: %v, channel :%d, peer_id: %s, msg: %s", success, chID, dc.peer.ID(), libs.GetSum(msgBytes))
 	return success
 }
 
+// SendWithResult queues the msg like Send, the returned chan receives nil once the msg is written to the stream,
+// or ErrSendQueueFull, ErrMsgExpired, ErrConnStopped or the write error, it's resolved exactly once.
+func (dc *DefaultConn) SendWithResult(chID int32, msgBytes []byte) <-chan error {
+	result := make(chan error, 1)
+	channel, ok := dc.channelsIdx[chID]
+	if !ok {
+		result <- fmt.Errorf("unknown channel %d", chID)
+		return result
+	}
+	if !channel.sendBytes(msgBytes, result) {
+		result <- ErrSendQueueFull
+	}
+	return result
+}
+
 // FlushStop replicates the logic of OnStop.
 // It additionally ensures that all successful
 // .Send() calls will get flushed before closing
 // the connection.
 func (dc *DefaultConn) FlushStop() {
-	// wait until the sendRoutine exits
-	// so we dont race on calling sendSomePacketMsgs
-	<-dc.quit
+	dc.stopOnce.Do(func() {
+		// stop the sendRoutine, writes are serialized by writeMtx
+		// so we dont race with the msg it's writing
+		close(dc.quit)
+		dc.routines.Stop()
 
-	// Send and flush all pending msgs.
-	// Since sendRoutine has exited, we can call this
-	// safely
-	for _, ch := range dc.channels {
-		if len(ch.sendQueue) == 0 {
-			continue
-		}
-		for len(ch.sendQueue) > 0 {
-			select {
-			case bytes := <-ch.sendQueue:
-				ch.writeMsgTo(bytes)
-			default:
+		// Send and flush all pending msgs.
+		for _, ch := range dc.channels {
+			for len(ch.sendQueue) > 0 {
+				select {
+				case msg := <-ch.sendQueue:
+					ch.writeQueued(msg)
+				default:
+				}
 			}
 		}
-	}
 
-	// Now we can close the connection
-	dc.stream.Close()
-	close(dc.quit)
+		// Now we can close the connection
+		dc.stream.Close()
+	})
 }
 
+// sendRoutine runs a send loop for every channel.
 func (dc *DefaultConn) sendRoutine() {
 	for _, ch := range dc.channels {
 		ch := ch
-		go func() {
+		dc.routines.Spawn(fmt.Sprintf("send/%d", ch.id), func() {
 			for {
 				select {
-				case bytes := <-ch.sendQueue:
-					ch.writeMsgTo(bytes)
+				case msg := <-ch.sendQueue:
+					ch.writeQueued(msg)
 				case <-dc.quit:
 					return
 				}
 			}
-		}()
+		})
 	}
 }
 
-// TODO: stream reset
 func (dc *DefaultConn) recvRoutine() {
+	defer func() {
+		if r := recover(); r != nil {
+			stack := debug.Stack()
+			dc.log.Error("recvRoutine panics @ conn.recvRoutine, peer_id: %s, err: %v, stack: %s", dc.peer.ID(), r, stack)
+			dc.stopForError(peerPanic{value: r, stack: string(stack)})
+		}
+	}()
+
 	for {
 		var packet pb.Packet
 
@@ -156,23 +286,79 @@ func (dc *DefaultConn) recvRoutine() {
 			dc.log.Error("meet quit @ recvRoutine, peer_id: %d", dc.peer.ID())
 			return
 		default:
-			err := dc.reader.ReadMsg(&packet)
+			// the frame is reused by the next read, its payload is detached and backs
+			// the msg handed to the reactor until HandleFunc returns.
+			var payload wire.Buffer
+			frame, err := dc.reader.Next()
+			if err == nil {
+				dc.touch()
+				payload = dc.reader.Detach()
+				err = decodePacket(frame.Payload, &packet, dc.env)
+			}
+			if err == nil && packet.GetPacketMsg().GetChannelId() != frame.Channel {
+				err = fmt.Errorf("channel mismatch, frame: %d, packet: %d", frame.Channel, packet.GetPacketMsg().GetChannelId())
+			}
+			if err == nil && packet.GetPacketMsg() != nil {
+				if ch, ok := dc.channelsIdx[frame.Channel]; ok {
+					dc.traffic.add(ch.module, 0, int64(wire.HeaderSize+len(frame.Payload)))
+				}
+			}
 			if err != nil {
-				// stopServices was invoked and we are shutting down
-				// receiving is excpected to fail since we will close the connection
+				payload.Release()
+				select {
+				case <-dc.quit:
+					// stopServices was invoked and we are shutting down
+					// receiving is excpected to fail since we will close the connection
+					return
+				default:
+				}
 				if err == io.EOF {
-					dc.log.Info("connection meets EOF @ recvRoutine (likely by the other side), peer_id: %d", dc.peer.ID())
-					continue
+					dc.log.Info("connection meets EOF @ recvRoutine (likely by the other side), peer_id: %s", dc.peer.ID())
+				} else {
+					dc.log.Error("connection failed @ recvRoutine (reading byte), peer_id: %s, err: %v", dc.peer.ID(), err)
+				}
+				dc.Stop()
+				if dc.onClose != nil {
+					dc.onClose(dc, err)
 				}
-				dc.log.Error("connection failed @ recvRoutine (reading byte), peer_id: %s, err: %v", dc.peer.ID(), err)
 				return
 			}
-			go dc.handlePkt(packet)
+			if msg := packet.GetPacketMsg(); msg != nil {
+				var complete bool
+				if complete, err = dc.reassemble(msg); err != nil {
+					payload.Release()
+					dc.log.Error("reassemble fail @ recvRoutine, peer_id: %s, err: %v", dc.peer.ID(), err)
+					dc.stopForError(err)
+					return
+				}
+				if !complete {
+					payload.Release()
+					continue
+				}
+			}
+			// the peer reactors queue the msgs in order without blocking, the others are dispatched concurrently
+			if dc.peerReactor(&packet) {
+				dc.handlePkt(packet, payload)
+			} else {
+				dc.handlePool.submit(func() { dc.handlePkt(packet, payload) })
+			}
 		}
 	}
 }
 
-func (dc *DefaultConn) handlePkt(packet pb.Packet) {
+// handlePkt dispatches the packet to the reactor, the payload backing the msg bytes
+// is released once the reactor returns, see libs.Reactor.
+func (dc *DefaultConn) handlePkt(packet pb.Packet, payload wire.Buffer) {
+	defer payload.Release()
+	// a reactor panicking on a malformed msg should only cost us the peer who sent it.
+	defer func() {
+		if r := recover(); r != nil {
+			stack := debug.Stack()
+			dc.log.Error("reactor panics @ conn.handlePkt, peer_id: %s, err: %v, stack: %s", dc.peer.ID(), r, stack)
+			dc.stopForError(peerPanic{value: r, stack: string(stack)})
+		}
+	}()
+
 	// Read more depending on packet type.
 	switch pkt := packet.Sum.(type) {
 	case *pb.Packet_PacketMsg:
@@ -190,10 +376,20 @@ func (dc *DefaultConn) handlePkt(packet pb.Packet) {
 				dc.peer.ID(), fmt.Errorf("unknown module %s", module))
 			return
 		}
+		if dc.authorize != nil && !dc.authorize(dc.peer.ID(), cid) {
+			return
+		}
 		if pkt.PacketMsg.Data != nil {
-			dc.log.Debug("received bytes, channel: %d, packet: %+v", pkt.PacketMsg.ChannelId, pkt.PacketMsg)
-			onReceive.HandleFunc(cid, pkt.PacketMsg.Data)
+			dc.msgs.Log("received bytes @ conn.handlePkt, from: %s, module: %s", cid, pkt.PacketMsg.Data, dc.peer.ID(), module)
+			dc.record(tap.Inbound, cid, pkt.PacketMsg.Data)
+			if pr, ok := onReceive.(libs.PeerReactor); ok {
+				pr.HandleFuncFrom(dc.peer.ID().Pretty(), cid, pkt.PacketMsg.Data)
+			} else {
+				onReceive.HandleFunc(cid, pkt.PacketMsg.Data)
+			}
 		}
+	case *pb.Packet_PacketPing:
+		// the frame has refreshed the activity of the conn already
 	default:
 		dc.log.Error("connection failed @ recvRoutine, peer_id: %s, err: %v",
 			dc.peer.ID(), fmt.Errorf("unknown message type %v", reflect.TypeOf(&packet)))
@@ -201,54 +397,219 @@ func (dc *DefaultConn) handlePkt(packet pb.Packet) {
 	}
 }
 
+// reassemble buffers the chunks of a msg until its last one, whose data is replaced by the whole msg.
+// It's only invoked by the recvRoutine, the msgs in a frame pass as they are.
+func (dc *DefaultConn) reassemble(msg *pb.PacketMsg) (bool, error) {
+	ch, ok := dc.channelsIdx[msg.ChannelId]
+	if !ok {
+		// handlePkt reports the unknown channel
+		return true, nil
+	}
+	if msg.Eof && len(ch.recving) == 0 {
+		return true, nil
+	}
+	if len(ch.recving)+len(msg.Data) > dc.maxMsgSize {
+		ch.recving = ch.recving[:0]
+		return false, fmt.Errorf("%w: chunked msg exceeds %d bytes, channel: %d", wire.ErrFrameTooLarge, dc.maxMsgSize, ch.id)
+	}
+	// the chunks are copied since their frames are reused by the next reads
+	ch.recving = append(ch.recving, msg.Data...)
+	if !msg.Eof {
+		return false, nil
+	}
+	// the msg is handed over, the next one starts in a fresh buffer
+	msg.Data, ch.recving = ch.recving, make([]byte, 0, defaultRecvBufferCapacity)
+	return true, nil
+}
+
+// peerReactor reports whether the msg of the packet is handled by a libs.PeerReactor.
+func (dc *DefaultConn) peerReactor(packet *pb.Packet) bool {
+	msg := packet.GetPacketMsg()
+	if msg == nil {
+		return false
+	}
+	_, ok := dc.onReceiveIdx[Module(msg.Module)].(libs.PeerReactor)
+	return ok
+}
+
+// setFrameDeadline arms the read deadline of the stream for the frame being read, the streams without
+// deadlines, e.g. the mocknet ones, are reset by the timer instead. It's only invoked by the recvRoutine.
+func (dc *DefaultConn) setFrameDeadline(t time.Time) error {
+	if err := dc.stream.SetReadDeadline(t); err == nil {
+		return nil
+	}
+	switch {
+	case t.IsZero():
+		if dc.frameTimer != nil {
+			dc.frameTimer.Stop()
+		}
+	case dc.frameTimer == nil:
+		dc.frameTimer = time.AfterFunc(time.Until(t), func() { dc.stream.Reset() })
+	default:
+		dc.frameTimer.Reset(time.Until(t))
+	}
+	return nil
+}
+
+// LastActive returns the time of the latest frame sent or received.
+func (dc *DefaultConn) LastActive() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&dc.lastActive))
+}
+
+func (dc *DefaultConn) touch() {
+	atomic.StoreInt64(&dc.lastActive, time.Now().UnixNano())
+}
+
+// Ping sends a keep-alive frame, which carries no msg of any channel.
+func (dc *DefaultConn) Ping() bool {
+	packet := &pb.Packet{Sum: &pb.Packet_PacketPing{PacketPing: &pb.PacketPing{}}}
+	dc.writeMtx.Lock()
+	err := dc.writer.EncodeMsg(wire.FlagEOF, 0, packet)
+	dc.writeMtx.Unlock()
+	if err != nil {
+		dc.log.Warn("ping fail @ conn.Ping, peer_id: %s, err: %v", dc.peer.ID(), err)
+		return false
+	}
+	dc.touch()
+	return true
+}
+
+func (dc *DefaultConn) record(dir tap.Direction, chID int32, msg []byte) {
+	if dc.tap == nil {
+		return
+	}
+	err := dc.tap.Record(&tap.Record{
+		Time:      time.Now(),
+		Direction: dir,
+		Channel:   chID,
+		Peer:      dc.peer.ID().Pretty(),
+		Msg:       msg,
+	})
+	if err != nil {
+		dc.log.Warn("tap record fail @ conn.record, peer_id: %s, err: %v", dc.peer.ID(), err)
+	}
+}
+
+func (dc *DefaultConn) stopForError(r interface{}) {
+	select {
+	case <-dc.quit:
+		// the conn has been stopped, e.g. replaced by a newer one to the same peer,
+		// reporting it would tear down the newer one.
+		return
+	default:
+	}
+	if dc.onError != nil {
+		dc.onError(dc.peer.ID(), r)
+		return
+	}
+	dc.Stop()
+}
+
 type Channel struct {
 	id            int32
+	module        Module
 	conn          *DefaultConn
-	sendQueue     chan []byte
+	sendQueue     chan queuedMsg
 	sendQueueSize int32 // atomic.
-	recving       []byte
+	// scheduled is set while a worker of the sendPool owns the send queue, atomic.
+	scheduled int32
+	recving   []byte
 
 	maxPacketMsgPayloadSize int
 
 	log libs.Logger
 }
 
-func NewChannel(id int32, conn *DefaultConn, log libs.Logger) *Channel {
+func NewChannel(id int32, mo Module, conn *DefaultConn, log libs.Logger) *Channel {
 	if log == nil {
 		log = logs.NewLogger()
 	}
 	return &Channel{
 		id:                      id,
+		module:                  mo,
 		conn:                    conn,
-		sendQueue:               make(chan []byte, defaultSendQueueCapacity),
+		sendQueue:               make(chan queuedMsg, defaultSendQueueCapacity),
 		recving:                 make([]byte, 0, defaultRecvBufferCapacity),
 		maxPacketMsgPayloadSize: defaultMaxPacketMsgPayloadSize,
 		log:                     log,
 	}
 }
 
-func (ch *Channel) sendBytes(bytes []byte) bool {
+// queuedMsg is a msg waiting in the send queue since the time.
+type queuedMsg struct {
+	bytes    []byte
+	queuedAt time.Time
+	// result receives the outcome of the transmission, nil if nobody waits for it
+	result chan error
+}
+
+func (msg queuedMsg) resolve(err error) {
+	if msg.result != nil {
+		msg.result <- err
+	}
+}
+
+func (ch *Channel) sendBytes(bytes []byte, result chan error) bool {
 	select {
-	case ch.sendQueue <- bytes:
+	case ch.sendQueue <- queuedMsg{bytes: bytes, queuedAt: time.Now(), result: result}:
 		atomic.AddInt32(&ch.sendQueueSize, 1)
+		if ch.conn.sendPool != nil {
+			ch.schedule()
+		}
 		return true
 	case <-time.After(defaultSendTimeout):
 		return false
 	}
 }
 
+// writeQueued transmits the queued msg unless it has expired meanwhile.
+func (ch *Channel) writeQueued(msg queuedMsg) error {
+	atomic.AddInt32(&ch.sendQueueSize, -1)
+	if ch.conn.expired != nil && ch.conn.expired(ch.id, msg.bytes, msg.queuedAt) {
+		ch.log.Info("drop expired msg @ conn.Send, channel: %d, to: %s, msg: %s, queued: %v",
+			ch.id, ch.conn.peer.ID(), libs.GetSum(msg.bytes), time.Since(msg.queuedAt))
+		msg.resolve(ErrMsgExpired)
+		return nil
+	}
+	ch.conn.lag.observe(time.Since(msg.queuedAt))
+	err := ch.writeMsgTo(msg.bytes)
+	msg.resolve(err)
+	return err
+}
+
+// writeMsgTo transmits the msg in a frame, or in the chunks of the lagTracker if the peer reassembles them,
+// the writeMtx is released between the chunks so that the other channels aren't stuck behind a large msg.
 func (ch *Channel) writeMsgTo(bytes []byte) error {
-	module, ok := libs.IDToModuleMap[ch.id]
-	if !ok {
-		return fmt.Errorf("channel id invalid, id: %d", ch.id)
+	if size := len(bytes); size > ch.conn.maxMsgSize {
+		// the peer would drop the connection for it
+		return fmt.Errorf("%w: %d bytes, max: %d bytes", wire.ErrFrameTooLarge, size, ch.conn.maxMsgSize)
+	}
+	logID := fmt.Sprintf("%d", libs.GenRandomID())
+	rest := bytes
+	for chunk := ch.conn.lag.chunkSize(); chunk > 0 && len(rest) > chunk; chunk = ch.conn.lag.chunkSize() {
+		if err := ch.writePacket(logID, rest[:chunk], false); err != nil {
+			return err
+		}
+		rest = rest[chunk:]
 	}
-	id := libs.GenRandomID()
+	if err := ch.writePacket(logID, rest, true); err != nil {
+		return err
+	}
+	ch.conn.record(tap.Outbound, ch.id, bytes)
+	ch.conn.msgs.Log("send succ @ conn.Send, to: %s", ch.id, bytes, ch.conn.peer.ID())
+	return nil
+}
+
+// writePacket writes the data in a frame, eof marks the last chunk of a msg.
+func (ch *Channel) writePacket(logID string, data []byte, eof bool) error {
 	packetMsg := &pb.PacketMsg{
-		LogId:     fmt.Sprintf("%d", id),
+		LogId:     logID,
 		ChannelId: ch.id,
-		Module:    module,
-		Eof:       true,
-		Data:      bytes,
+		Module:    string(ch.module),
+		Eof:       eof,
+		Data:      data,
+		ChainId:   ch.conn.env.chainID,
+		ForkId:    ch.conn.env.forkID,
 	}
 	packet := &pb.Packet{
 		Sum: &pb.Packet_PacketMsg{
@@ -256,11 +617,23 @@ func (ch *Channel) writeMsgTo(bytes []byte) error {
 		},
 	}
 
-	err := ch.conn.bufConnWriter.WriteMsg(packet)
+	size := packet.Size()
+	if size > ch.conn.maxMsgSize {
+		// the peer would drop the connection for it
+		return fmt.Errorf("%w: %d bytes, max: %d bytes", wire.ErrFrameTooLarge, size, ch.conn.maxMsgSize)
+	}
+	var flags uint8
+	if eof {
+		flags = wire.FlagEOF
+	}
+	ch.conn.writeMtx.Lock()
+	err := ch.conn.writer.EncodeMsg(flags, ch.id, packet)
+	ch.conn.writeMtx.Unlock()
 	if err != nil {
-		ch.log.Error("send fail @ conn.Send, channel: %d, to: %s, msg :%s, err: %v", ch.id, ch.conn.peer.ID(), libs.GetSum(bytes), err)
+		ch.log.Error("send fail @ conn.Send, channel: %d, to: %s, msg: %v, err: %v", ch.id, ch.conn.peer.ID(), ch.conn.msgs.Msg(ch.id, data), err)
 		return err
 	}
-	ch.log.Info("send succ @ conn.Send, channel: %d, to: %s, msg :%s", ch.id, ch.conn.peer.ID(), libs.GetSum(bytes))
+	ch.conn.touch()
+	ch.conn.traffic.add(ch.module, int64(wire.HeaderSize+size), 0)
 	return nil
 }
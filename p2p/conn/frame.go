@@ -0,0 +1,316 @@
+// Package conn defines the wire frame exchanged by the peers on a stream.
+//
+// Every frame is a fixed 16-byte header followed by the payload,
+// all integers are big-endian:
+//
+//	offset  size  field
+//	0       2     magic, always 0x4753 ("GS")
+//	2       1     version, currently 1
+//	3       1     flags, see FlagEOF
+//	4       4     channel id, signed
+//	8       4     payload length, at most the decoder's max payload size
+//	12      4     crc32 (castagnoli) of the payload
+//	16      n     payload
+//
+// A decoder rejects frames with unknown magic, unsupported version, oversized
+// or corrupted payload, the stream should be closed afterwards since the
+// frame boundary is lost.
+package conn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+const (
+	Magic   uint16 = 0x4753
+	Version uint8  = 1
+
+	// HeaderSize is the size of the fixed frame header.
+	HeaderSize = 16
+	// DefaultMaxPayloadSize is the max payload size accepted by a decoder by default.
+	DefaultMaxPayloadSize = 1 << 20
+)
+
+// FlagEOF marks the last frame of a msg.
+const FlagEOF uint8 = 1 << 0
+
+var (
+	ErrBadMagic           = errors.New("bad frame magic")
+	ErrUnsupportedVersion = errors.New("unsupported frame version")
+	ErrFrameTooLarge      = errors.New("frame payload is too large")
+	ErrChecksumMismatch   = errors.New("frame checksum mismatch")
+	// ErrFrameTimeout means the frame isn't completed in time once its first byte arrived, see SetFrameTimeout.
+	ErrFrameTimeout = errors.New("frame read timeout")
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// Frame is the unit written on the wire.
+type Frame struct {
+	Version uint8
+	Flags   uint8
+	Channel int32
+	Payload []byte
+}
+
+// MarshalBinary encodes the frame, version 0 is encoded as the current version.
+func (f *Frame) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, HeaderSize+len(f.Payload))
+	if err := f.encodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (f *Frame) encodeTo(buf []byte) error {
+	copy(buf[HeaderSize:], f.Payload)
+	return putHeader(buf[:HeaderSize], f.Version, f.Flags, f.Channel, buf[HeaderSize:HeaderSize+len(f.Payload)])
+}
+
+// putHeader encodes the header of the payload into header.
+func putHeader(header []byte, version, flags uint8, channel int32, payload []byte) error {
+	if version == 0 {
+		version = Version
+	}
+	if version != Version {
+		return fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+	if uint64(len(payload)) > uint64(^uint32(0)) {
+		return ErrFrameTooLarge
+	}
+	binary.BigEndian.PutUint16(header[0:2], Magic)
+	header[2] = version
+	header[3] = flags
+	binary.BigEndian.PutUint32(header[4:8], uint32(channel))
+	binary.BigEndian.PutUint32(header[8:12], uint32(len(payload)))
+	binary.BigEndian.PutUint32(header[12:16], crc32.Checksum(payload, crc32c))
+	return nil
+}
+
+// UnmarshalBinary decodes exactly one frame from data.
+func (f *Frame) UnmarshalBinary(data []byte) error {
+	if len(data) < HeaderSize {
+		return io.ErrUnexpectedEOF
+	}
+	length, err := f.decodeHeader(data[:HeaderSize], uint32(len(data)-HeaderSize))
+	if err != nil {
+		return err
+	}
+	if int(length) != len(data)-HeaderSize {
+		return fmt.Errorf("frame length mismatch, header: %d, data: %d", length, len(data)-HeaderSize)
+	}
+	payload := make([]byte, length)
+	copy(payload, data[HeaderSize:])
+	return f.setPayload(data[:HeaderSize], payload)
+}
+
+// decodeHeader fills the header fields of the frame and returns the payload length.
+func (f *Frame) decodeHeader(header []byte, max uint32) (uint32, error) {
+	if binary.BigEndian.Uint16(header[0:2]) != Magic {
+		return 0, ErrBadMagic
+	}
+	if header[2] != Version {
+		return 0, fmt.Errorf("%w: %d", ErrUnsupportedVersion, header[2])
+	}
+	length := binary.BigEndian.Uint32(header[8:12])
+	if length > max {
+		return 0, fmt.Errorf("%w: %d bytes, max: %d bytes", ErrFrameTooLarge, length, max)
+	}
+	f.Version = header[2]
+	f.Flags = header[3]
+	f.Channel = int32(binary.BigEndian.Uint32(header[4:8]))
+	return length, nil
+}
+
+func (f *Frame) setPayload(header []byte, payload []byte) error {
+	if crc32.Checksum(payload, crc32c) != binary.BigEndian.Uint32(header[12:16]) {
+		return ErrChecksumMismatch
+	}
+	f.Payload = payload
+	return nil
+}
+
+// Encoder writes frames to the stream, it's not safe for concurrent use.
+type Encoder struct {
+	w io.Writer
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the frame with a single Write call.
+func (e *Encoder) Encode(f *Frame) error {
+	buf := getBuffer(HeaderSize + len(f.Payload))
+	defer putBuffer(buf)
+	if err := f.encodeTo(*buf); err != nil {
+		return err
+	}
+	_, err := e.w.Write(*buf)
+	return err
+}
+
+// Marshaler is implemented by the generated protobuf msgs.
+type Marshaler interface {
+	Size() int
+	MarshalToSizedBuffer(data []byte) (int, error)
+}
+
+// EncodeMsg writes the frame whose payload is the encoding of m, the msg is marshaled
+// right behind the header into a pooled buffer, so no payload is allocated per msg.
+func (e *Encoder) EncodeMsg(flags uint8, channel int32, m Marshaler) error {
+	size := m.Size()
+	buf := getBuffer(HeaderSize + size)
+	defer putBuffer(buf)
+	payload := (*buf)[HeaderSize:]
+	n, err := m.MarshalToSizedBuffer(payload)
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return fmt.Errorf("msg size mismatch, size: %d, marshaled: %d", size, n)
+	}
+	if err := putHeader((*buf)[:HeaderSize], Version, flags, channel, payload); err != nil {
+		return err
+	}
+	_, err = e.w.Write(*buf)
+	return err
+}
+
+// Decoder reads frames from the stream, it's not safe for concurrent use.
+type Decoder struct {
+	r              io.Reader
+	maxPayloadSize uint32
+	header         [HeaderSize]byte
+
+	// frame and buf back the frames returned by Next
+	frame Frame
+	buf   *[]byte
+
+	// the read of a frame is bounded once its first byte arrives, see SetFrameTimeout
+	frameTimeout time.Duration
+	setDeadline  func(time.Time) error
+	deadline     time.Time
+}
+
+// NewDecoder returns a decoder accepting payloads up to maxPayloadSize,
+// DefaultMaxPayloadSize is used if it's not positive.
+func NewDecoder(r io.Reader, maxPayloadSize int) *Decoder {
+	if maxPayloadSize <= 0 {
+		maxPayloadSize = DefaultMaxPayloadSize
+	}
+	return &Decoder{r: r, maxPayloadSize: uint32(maxPayloadSize)}
+}
+
+// SetFrameTimeout bounds the read of every frame from its first byte on, the read fails with ErrFrameTimeout
+// if the rest of the frame trickles in slower, which protects the reader from the slow-loris peers.
+// setDeadline arms the read deadline of the stream, the zero time clears it. The wait for the first byte
+// is unbounded, the idle streams are left to the caller.
+func (d *Decoder) SetFrameTimeout(timeout time.Duration, setDeadline func(time.Time) error) {
+	d.frameTimeout, d.setDeadline = timeout, setDeadline
+}
+
+// readHeader reads the header of the next frame and arms the deadline of the frame once its first byte arrives.
+func (d *Decoder) readHeader() error {
+	if d.frameTimeout <= 0 || d.setDeadline == nil {
+		_, err := io.ReadFull(d.r, d.header[:])
+		return err
+	}
+	if _, err := io.ReadFull(d.r, d.header[:1]); err != nil {
+		return err
+	}
+	d.deadline = time.Now().Add(d.frameTimeout)
+	if err := d.setDeadline(d.deadline); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(d.r, d.header[1:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
+}
+
+// endFrame clears the deadline of the frame read, the failure after the deadline is reported as ErrFrameTimeout.
+func (d *Decoder) endFrame(err error) error {
+	if d.deadline.IsZero() {
+		return err
+	}
+	if err != nil && !time.Now().Before(d.deadline) {
+		err = fmt.Errorf("%w, timeout: %v, err: %v", ErrFrameTimeout, d.frameTimeout, err)
+	}
+	d.deadline = time.Time{}
+	if cerr := d.setDeadline(time.Time{}); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Decode reads the next frame, io.EOF is returned only if the stream ends
+// on a frame boundary, io.ErrUnexpectedEOF otherwise.
+func (d *Decoder) Decode() (f *Frame, err error) {
+	defer func() { err = d.endFrame(err) }()
+	if err := d.readHeader(); err != nil {
+		return nil, err
+	}
+	f = &Frame{}
+	length, err := f.decodeHeader(d.header[:], d.maxPayloadSize)
+	if err != nil {
+		return nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(d.r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if err := f.setPayload(d.header[:], payload); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Next reads the next frame like Decode, but the frame and its payload are reused
+// by the following call, the caller must copy whatever it retains beyond it.
+func (d *Decoder) Next() (f *Frame, err error) {
+	if d.buf != nil {
+		putBuffer(d.buf)
+		d.buf = nil
+	}
+	defer func() { err = d.endFrame(err) }()
+	if err := d.readHeader(); err != nil {
+		return nil, err
+	}
+	f = &d.frame
+	length, err := f.decodeHeader(d.header[:], d.maxPayloadSize)
+	if err != nil {
+		return nil, err
+	}
+	d.buf = getBuffer(int(length))
+	payload := *d.buf
+	if _, err := io.ReadFull(d.r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if err := f.setPayload(d.header[:], payload); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Detach takes over the payload of the frame returned by the latest Next, the decoder
+// doesn't reuse it and the caller must Release it once done.
+func (d *Decoder) Detach() Buffer {
+	b := Buffer{buf: d.buf}
+	d.buf = nil
+	return b
+}
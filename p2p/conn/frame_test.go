@@ -0,0 +1,256 @@
+package conn
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFrameGolden(t *testing.T) {
+	f := &Frame{Flags: FlagEOF, Channel: 7, Payload: []byte("hotstuff")}
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// magic | version | flags | channel | length | crc32c | payload
+	want := "4753" + "01" + "01" + "00000007" + "00000008" + "f23b6e28" + hex.EncodeToString([]byte("hotstuff"))
+	if has := hex.EncodeToString(data); has != want {
+		t.Fatalf("want: %s, has: %s", want, has)
+	}
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	frames := []*Frame{
+		{Version: Version, Channel: 0, Payload: []byte{}},
+		{Version: Version, Flags: FlagEOF, Channel: 1, Payload: []byte("a")},
+		{Version: Version, Flags: 0xff, Channel: -1, Payload: bytes.Repeat([]byte{0xab}, 4096)},
+		{Version: Version, Channel: 0x7fffffff, Payload: []byte("max channel")},
+		{Version: Version, Channel: -0x80000000, Payload: []byte("min channel")},
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, f := range frames {
+		if err := enc.Encode(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dec := NewDecoder(&buf, 0)
+	for i, want := range frames {
+		has, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(has, want) {
+			t.Errorf("frame %d, want: %+v, has: %+v", i, want, has)
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("want io.EOF at the frame boundary, has: %v", err)
+	}
+
+	for i, want := range frames {
+		data, _ := want.MarshalBinary()
+		var has Frame
+		if err := has.UnmarshalBinary(data); err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(&has, want) {
+			t.Errorf("frame %d, want: %+v, has: %+v", i, want, &has)
+		}
+	}
+}
+
+func TestFrameDecodeErrors(t *testing.T) {
+	valid, _ := (&Frame{Channel: 3, Payload: []byte("payload")}).MarshalBinary()
+	mutate := func(f func(b []byte) []byte) []byte {
+		b := make([]byte, len(valid))
+		copy(b, valid)
+		return f(b)
+	}
+
+	cases := []struct {
+		name string
+		data []byte
+		max  int
+		err  error
+	}{
+		{"empty", nil, 0, io.EOF},
+		{"short header", valid[:HeaderSize-1], 0, io.ErrUnexpectedEOF},
+		{"short payload", valid[:len(valid)-1], 0, io.ErrUnexpectedEOF},
+		{"header only", valid[:HeaderSize], 0, io.ErrUnexpectedEOF},
+		{"bad magic", mutate(func(b []byte) []byte { b[0] = 0; return b }), 0, ErrBadMagic},
+		{"bad version", mutate(func(b []byte) []byte { b[2] = 2; return b }), 0, ErrUnsupportedVersion},
+		{"too large", valid, 6, ErrFrameTooLarge},
+		{"bad checksum", mutate(func(b []byte) []byte { b[12] ^= 1; return b }), 0, ErrChecksumMismatch},
+		{"corrupted payload", mutate(func(b []byte) []byte { b[HeaderSize] ^= 1; return b }), 0, ErrChecksumMismatch},
+	}
+	for _, c := range cases {
+		_, err := NewDecoder(bytes.NewReader(c.data), c.max).Decode()
+		if !errors.Is(err, c.err) {
+			t.Errorf("%s, want: %v, has: %v", c.name, c.err, err)
+		}
+	}
+}
+
+func TestFrameUnmarshalErrors(t *testing.T) {
+	valid, _ := (&Frame{Channel: 3, Payload: []byte("payload")}).MarshalBinary()
+	var f Frame
+	if err := f.UnmarshalBinary(valid[:HeaderSize-1]); err != io.ErrUnexpectedEOF {
+		t.Errorf("want: %v, has: %v", io.ErrUnexpectedEOF, err)
+	}
+	if err := f.UnmarshalBinary(append(valid, 0)); err == nil {
+		t.Errorf("trailing bytes should be rejected")
+	}
+	if err := f.UnmarshalBinary(valid[:len(valid)-1]); err == nil {
+		t.Errorf("truncated payload should be rejected")
+	}
+}
+
+func TestFrameEncodeErrors(t *testing.T) {
+	if _, err := (&Frame{Version: 2}).MarshalBinary(); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("want: %v, has: %v", ErrUnsupportedVersion, err)
+	}
+}
+
+// rawMsg marshals as its bytes, like a generated msg.
+type rawMsg []byte
+
+func (m rawMsg) Size() int { return len(m) }
+
+func (m rawMsg) MarshalToSizedBuffer(data []byte) (int, error) {
+	return copy(data[len(data)-len(m):], m), nil
+}
+
+func TestFrameEncodeMsgNext(t *testing.T) {
+	msgs := []rawMsg{{}, rawMsg("vote"), rawMsg(bytes.Repeat([]byte{0xcd}, maxPooledBufferSize+1)), rawMsg("timeout")}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for i, m := range msgs {
+		if err := enc.EncodeMsg(FlagEOF, int32(i), m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the frames of EncodeMsg are the ones of Encode
+	var golden bytes.Buffer
+	for i, m := range msgs {
+		if err := NewEncoder(&golden).Encode(&Frame{Flags: FlagEOF, Channel: int32(i), Payload: m}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(buf.Bytes(), golden.Bytes()) {
+		t.Fatalf("EncodeMsg differs from Encode")
+	}
+
+	dec := NewDecoder(&buf, 0)
+	for i, m := range msgs {
+		has, err := dec.Next()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if has.Channel != int32(i) || has.Flags != FlagEOF || !bytes.Equal(has.Payload, m) {
+			t.Errorf("frame %d, want: %x, has: %+v", i, m, has)
+		}
+	}
+	if _, err := dec.Next(); err != io.EOF {
+		t.Errorf("want io.EOF at the frame boundary, has: %v", err)
+	}
+}
+
+// votePayload is about the size of an encoded vote msg.
+var votePayload = bytes.Repeat([]byte{0x5a}, 256)
+
+// BenchmarkFrameMarshalBinary is the send path allocating the payload and the frame per msg.
+func BenchmarkFrameMarshalBinary(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		payload := append([]byte(nil), votePayload...)
+		data, err := (&Frame{Flags: FlagEOF, Channel: 1, Payload: payload}).MarshalBinary()
+		if err != nil {
+			b.Fatal(err)
+		}
+		ioutil.Discard.Write(data)
+	}
+}
+
+func BenchmarkFrameEncodeMsg(b *testing.B) {
+	enc := NewEncoder(ioutil.Discard)
+	var m Marshaler = rawMsg(votePayload)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := enc.EncodeMsg(FlagEOF, 1, m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// repeatReader replays the same frame forever.
+type repeatReader struct {
+	data []byte
+	off  int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	n := copy(p, r.data[r.off:])
+	r.off = (r.off + n) % len(r.data)
+	return n, nil
+}
+
+func benchmarkDecode(b *testing.B, next bool) {
+	data, _ := (&Frame{Flags: FlagEOF, Channel: 1, Payload: votePayload}).MarshalBinary()
+	dec := NewDecoder(&repeatReader{data: data}, 0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if next {
+			_, err = dec.Next()
+		} else {
+			_, err = dec.Decode()
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFrameDecode(b *testing.B) { benchmarkDecode(b, false) }
+
+func BenchmarkFrameNext(b *testing.B) { benchmarkDecode(b, true) }
+
+func TestFrameTimeout(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+	d := NewDecoder(local, 0)
+	d.SetFrameTimeout(100*time.Millisecond, local.SetReadDeadline)
+	frame, _ := (&Frame{Channel: 3, Payload: []byte("payload")}).MarshalBinary()
+
+	// the idle stream isn't bounded
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		remote.Write(frame)
+	}()
+	if f, err := d.Next(); err != nil || string(f.Payload) != "payload" {
+		t.Fatalf("want the frame after the idleness, has: %+v, err: %v", f, err)
+	}
+
+	// the frame trickling in slower is refused
+	go func() {
+		for _, b := range frame {
+			if _, err := remote.Write([]byte{b}); err != nil {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}()
+	if _, err := d.Next(); !errors.Is(err, ErrFrameTimeout) {
+		t.Errorf("want ErrFrameTimeout, has: %v", err)
+	}
+}
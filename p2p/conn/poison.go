@@ -0,0 +1,9 @@
+//go:build !race
+// +build !race
+
+package conn
+
+// poisonReleased is enabled by the race detector builds only, see poison_race.go.
+const poisonReleased = false
+
+const poisonByte = 0xdb
@@ -0,0 +1,10 @@
+//go:build race
+// +build race
+
+package conn
+
+// poisonReleased overwrites the released buffers, so that the tests run with the race
+// detector catch the reactors which keep the msg bytes beyond HandleFunc without copying.
+const poisonReleased = true
+
+const poisonByte = 0xdb
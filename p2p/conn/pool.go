@@ -0,0 +1,75 @@
+package conn
+
+import "sync"
+
+const (
+	// minBufferSize covers the frames of the votes, the timeouts and the pings.
+	minBufferSize = 1 << 10
+	// maxPooledBufferSize bounds the buffers kept for reuse, the rare large frames,
+	// e.g. the proposals of full blocks, are left to the gc instead of pinning memory.
+	maxPooledBufferSize = 64 << 10
+)
+
+// bufferPool recycles the frame buffers of the encoders and the decoders,
+// a frame allocated per msg otherwise dominates the gc at high vote rates.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, minBufferSize)
+		return &buf
+	},
+}
+
+// getBuffer returns a buffer of length n, whose content is undefined.
+func getBuffer(n int) *[]byte {
+	if n > maxPooledBufferSize {
+		buf := make([]byte, n)
+		return &buf
+	}
+	buf := bufferPool.Get().(*[]byte)
+	if cap(*buf) < n {
+		size := n + n/2
+		if size > maxPooledBufferSize {
+			size = maxPooledBufferSize
+		}
+		*buf = make([]byte, n, size)
+	}
+	*buf = (*buf)[:n]
+	return buf
+}
+
+// putBuffer returns the buffer to the pool, it mustn't be used by the caller afterwards.
+func putBuffer(buf *[]byte) {
+	if cap(*buf) > maxPooledBufferSize {
+		return
+	}
+	*buf = (*buf)[:0]
+	bufferPool.Put(buf)
+}
+
+// Buffer is a pooled frame payload taken over from a decoder, see Decoder.Detach,
+// its owner hands it back by Release once nothing refers to the payload anymore.
+type Buffer struct {
+	buf *[]byte
+}
+
+// Bytes returns the payload, it's invalid after Release.
+func (b Buffer) Bytes() []byte {
+	if b.buf == nil {
+		return nil
+	}
+	return *b.buf
+}
+
+// Release recycles the payload, it must be called at most once.
+func (b Buffer) Release() {
+	if b.buf == nil {
+		return
+	}
+	if poisonReleased {
+		// the holders violating the ownership read garbage instead of the next msg by chance
+		for i := range *b.buf {
+			(*b.buf)[i] = poisonByte
+		}
+	}
+	putBuffer(b.buf)
+}
@@ -0,0 +1,67 @@
+package p2p
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/protocol"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+)
+
+const (
+	DHTModeServer = "server"
+	DHTModeClient = "client"
+	DHTModeAuto   = "auto"
+
+	defaultDHTRefreshPeriod = 3 * time.Second
+)
+
+// DHTConfig tunes the kademlia dht, the zero values keep the defaults.
+type DHTConfig struct {
+	// Mode is one of server (default), client or auto, the sentries may run
+	// in client mode so that they are never routed by the others.
+	Mode string
+	// RefreshPeriod is the routing table refresh period, 3s by default.
+	RefreshPeriod time.Duration
+	// BucketSize is the size of the k-buckets, 20 by default.
+	BucketSize int
+	// QueryTimeout bounds each query of the routing table refresh.
+	QueryTimeout time.Duration
+}
+
+func parseDHTMode(mode string) (dht.ModeOpt, error) {
+	switch strings.ToLower(mode) {
+	case "", DHTModeServer:
+		return dht.ModeServer, nil
+	case DHTModeClient:
+		return dht.ModeClient, nil
+	case DHTModeAuto:
+		return dht.ModeAuto, nil
+	}
+	return 0, fmt.Errorf("unknown dht mode: %s", mode)
+}
+
+// dhtOptions converts the config into the dht options under the protocol prefix.
+func dhtOptions(cfg DHTConfig, prefix protocol.ID) ([]dht.Option, error) {
+	mode, err := parseDHTMode(cfg.Mode)
+	if err != nil {
+		return nil, err
+	}
+	refresh := cfg.RefreshPeriod
+	if refresh <= 0 {
+		refresh = defaultDHTRefreshPeriod
+	}
+	opts := []dht.Option{
+		dht.Mode(mode),
+		dht.RoutingTableRefreshPeriod(refresh),
+		dht.ProtocolPrefix(prefix),
+	}
+	if cfg.BucketSize > 0 {
+		opts = append(opts, dht.BucketSize(cfg.BucketSize))
+	}
+	if cfg.QueryTimeout > 0 {
+		opts = append(opts, dht.RoutingTableRefreshQueryTimeout(cfg.QueryTimeout))
+	}
+	return opts, nil
+}
@@ -0,0 +1,28 @@
+package p2p
+
+import (
+	"testing"
+
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+)
+
+func TestDHTOptions(t *testing.T) {
+	for mode, want := range map[string]dht.ModeOpt{
+		"":       dht.ModeServer,
+		"server": dht.ModeServer,
+		"Client": dht.ModeClient,
+		"auto":   dht.ModeAuto,
+	} {
+		has, err := parseDHTMode(mode)
+		if err != nil || has != want {
+			t.Errorf("mode %q, want: %v, has: %v, err: %v", mode, want, has, err)
+		}
+	}
+	if _, err := dhtOptions(DHTConfig{Mode: "sentry"}, protocolID("")); err == nil {
+		t.Errorf("unknown mode should be rejected")
+	}
+	opts, err := dhtOptions(DHTConfig{BucketSize: 10, QueryTimeout: 1}, protocolID("test"))
+	if err != nil || len(opts) != 5 {
+		t.Errorf("want 5 options, has: %d, err: %v", len(opts), err)
+	}
+}
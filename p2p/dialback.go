@@ -0,0 +1,63 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aucusaga/gohotstuff/p2p/netaddr"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/peerstore"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// AddGossipedAddr adds the multiaddr gossiped by a peer, e.g. /ip4/127.0.0.1/tcp/30001/p2p/Qm...,
+// into the address book only after the dial-back verification, i.e. the host connects to the
+// address and the secure channel proves the remote peer holds the key of the claimed id.
+// The address which fails the verification is marked suspicious and never dialed again,
+// so that an attacker cannot make the host flood a victim by gossiping the victim's address.
+func (sw *Switch) AddGossipedAddr(ctx context.Context, multiAddr string) error {
+	info, err := netaddr.Parse(multiAddr)
+	if err != nil {
+		return err
+	}
+	if info.ID == sw.host.ID() {
+		return fmt.Errorf("gossiped addr points at the host itself, addr: %s", multiAddr)
+	}
+	for _, addr := range info.Addrs {
+		if err := sw.book.Check(addr, info.ID); err != nil {
+			return err
+		}
+		if sw.book.Verified(addr, info.ID) {
+			continue
+		}
+		if err := sw.dialBack(ctx, addr, info.ID); err != nil {
+			sw.log.Warn("dial-back verification fail @ p2p.AddGossipedAddr, addr: %s, peer_id: %s, err: %v", addr, info.ID.Pretty(), err)
+			return fmt.Errorf("%w, addr: %s, err: %v", ErrUnverifiedAddr, addr, err)
+		}
+		if err := sw.book.AddVerified(addr, info.ID); err != nil {
+			return err
+		}
+		sw.host.Peerstore().AddAddr(info.ID, addr, peerstore.AddressTTL)
+		sw.log.Info("gossiped addr verified @ p2p.AddGossipedAddr, addr: %s, peer_id: %s", addr, info.ID.Pretty())
+	}
+	return nil
+}
+
+// dialBack connects to the peer at the very addr, an existing conn over another address
+// proves nothing about addr, in which case the addr is left unverified but not suspicious.
+func (sw *Switch) dialBack(ctx context.Context, addr multiaddr.Multiaddr, id PeerID) error {
+	conns := sw.host.Network().ConnsToPeer(id)
+	if len(conns) == 0 {
+		if err := sw.host.Connect(ctx, peer.AddrInfo{ID: id, Addrs: []multiaddr.Multiaddr{addr}}); err != nil {
+			sw.ban(addr, id.Pretty(), fmt.Sprintf("dial-back fail: %v", err))
+			return err
+		}
+		conns = sw.host.Network().ConnsToPeer(id)
+	}
+	for _, c := range conns {
+		if c.RemoteMultiaddr().Equal(addr) {
+			return nil
+		}
+	}
+	return fmt.Errorf("peer is not reached at the addr")
+}
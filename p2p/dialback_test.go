@@ -0,0 +1,50 @@
+package p2p
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+func TestAddGossipedAddr(t *testing.T) {
+	mn := mocknet.New(context.Background())
+	sw1 := newMockSwitch(t, mn, newRecvReactor())
+	sw2 := newMockSwitch(t, mn, newRecvReactor())
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+	addr := sw2.host.Addrs()[0]
+
+	// the attacker gossips the address of sw2 as the one of a peer it doesn't host
+	_, pk, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spoofed, _ := peer.IDFromPublicKey(pk)
+	err = sw1.AddGossipedAddr(context.Background(), fmt.Sprintf("%s/p2p/%s", addr, spoofed.Pretty()))
+	if !errors.Is(err, ErrUnverifiedAddr) {
+		t.Fatalf("want: %v, has: %v", ErrUnverifiedAddr, err)
+	}
+	if err := sw1.book.Check(addr, spoofed); err != ErrSuspiciousAddr {
+		t.Errorf("the spoofed addr should be marked suspicious, has: %v", err)
+	}
+
+	// the genuine address passes once it's no longer suspicious
+	sw1.book = NewAddrBook()
+	if err := sw1.AddGossipedAddr(context.Background(), fmt.Sprintf("%s/p2p/%s", addr, sw2.host.ID().Pretty())); err != nil {
+		t.Fatal(err)
+	}
+	list := sw1.book.List()
+	if len(list) != 1 || !list[0].Verified || list[0].ID != sw2.host.ID().Pretty() {
+		t.Errorf("unexpected entries: %+v", list)
+	}
+	if !sw1.book.Verified(addr, sw2.host.ID()) {
+		t.Errorf("addr should be verified")
+	}
+}
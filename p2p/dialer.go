@@ -0,0 +1,126 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDialConcurrency = 8
+	defaultDialTimeout     = 10 * time.Second
+	defaultDialQueueSize   = 256
+)
+
+var (
+	ErrDialQueueFull = errors.New("dial queue is full")
+	ErrDialPending   = errors.New("address is being dialed")
+)
+
+// DialResult is reported once a queued dial completes.
+type DialResult struct {
+	Addr string
+	Err  error
+	Cost time.Duration
+}
+
+type dialFunc func(ctx context.Context, multiAddr string) error
+
+// dialQueue runs the dials in a worker pool so that the dead addresses cannot block
+// the caller, an address is queued at most once until its dial completes.
+type dialQueue struct {
+	dial        dialFunc
+	concurrency int
+	timeout     time.Duration
+
+	jobs    chan string
+	results chan DialResult
+	pending map[string]struct{}
+	mtx     sync.Mutex
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// newDialQueue returns a queue with the given concurrency and per-dial timeout,
+// the defaults are used if they are not positive.
+func newDialQueue(dial dialFunc, concurrency int, timeout time.Duration) *dialQueue {
+	if concurrency <= 0 {
+		concurrency = defaultDialConcurrency
+	}
+	if timeout <= 0 {
+		timeout = defaultDialTimeout
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &dialQueue{
+		dial:        dial,
+		concurrency: concurrency,
+		timeout:     timeout,
+		jobs:        make(chan string, defaultDialQueueSize),
+		results:     make(chan DialResult, defaultDialQueueSize),
+		pending:     make(map[string]struct{}),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+func (q *dialQueue) Start() {
+	q.wg.Add(q.concurrency)
+	for i := 0; i < q.concurrency; i++ {
+		go q.worker()
+	}
+}
+
+// Stop cancels the running dials and waits for the workers.
+func (q *dialQueue) Stop() {
+	q.cancel()
+	q.wg.Wait()
+}
+
+// Enqueue never blocks, the result is reported on Results.
+func (q *dialQueue) Enqueue(addr string) error {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if _, ok := q.pending[addr]; ok {
+		return ErrDialPending
+	}
+	select {
+	case q.jobs <- addr:
+		q.pending[addr] = struct{}{}
+		return nil
+	default:
+		return ErrDialQueueFull
+	}
+}
+
+func (q *dialQueue) Results() <-chan DialResult {
+	return q.results
+}
+
+func (q *dialQueue) worker() {
+	defer q.wg.Done()
+	for {
+		select {
+		case addr := <-q.jobs:
+			start := time.Now()
+			ctx, cancel := context.WithTimeout(q.ctx, q.timeout)
+			err := q.dial(ctx, addr)
+			cancel()
+
+			q.mtx.Lock()
+			delete(q.pending, addr)
+			q.mtx.Unlock()
+
+			select {
+			case q.results <- DialResult{Addr: addr, Err: err, Cost: time.Since(start)}:
+			case <-q.ctx.Done():
+				return
+			}
+		case <-q.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDialQueueConcurrency(t *testing.T) {
+	var running, peak int32
+	release := make(chan struct{})
+	dial := func(ctx context.Context, addr string) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		<-release
+		atomic.AddInt32(&running, -1)
+		return nil
+	}
+	q := newDialQueue(dial, 2, time.Second)
+	q.Start()
+	defer q.Stop()
+
+	for i := 0; i < 6; i++ {
+		if err := q.Enqueue(fmt.Sprintf("addr-%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	for i := 0; i < 6; i++ {
+		if res := <-q.Results(); res.Err != nil {
+			t.Errorf("unexpected err: %v", res.Err)
+		}
+	}
+	if peak := atomic.LoadInt32(&peak); peak != 2 {
+		t.Errorf("want at most 2 concurrent dials, has: %d", peak)
+	}
+}
+
+func TestDialQueueTimeout(t *testing.T) {
+	dial := func(ctx context.Context, addr string) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	q := newDialQueue(dial, 1, 20*time.Millisecond)
+	q.Start()
+	defer q.Stop()
+
+	if err := q.Enqueue("dead"); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Enqueue("dead"); err != ErrDialPending {
+		t.Errorf("want: %v, has: %v", ErrDialPending, err)
+	}
+	select {
+	case res := <-q.Results():
+		if res.Addr != "dead" || res.Err != context.DeadlineExceeded {
+			t.Errorf("unexpected result: %+v", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("dial should time out")
+	}
+	// the addr can be queued again once the dial completes
+	if err := q.Enqueue("dead"); err != nil {
+		t.Errorf("unexpected err: %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package p2p
+
+import (
+	"time"
+)
+
+// expiredCbFunc reports whether the msg queued for the channel at the time should be dropped
+// instead of being transmitted.
+type expiredCbFunc func(chID int32, msgBytes []byte, queuedAt time.Time) bool
+
+// SetMessageExpiry registers the func deciding whether a queued msg of the channel is stale,
+// e.g. a vote of a past view. It's invoked right before every transmission, so it must not block.
+func (sw *Switch) SetMessageExpiry(chID int32, expired func(msgBytes []byte) bool) {
+	sw.mtx.Lock()
+	defer sw.mtx.Unlock()
+
+	if sw.expiries == nil {
+		sw.expiries = make(map[int32]func([]byte) bool)
+	}
+	sw.expiries[chID] = expired
+}
+
+// msgExpired drops the msgs which have been queued longer than Config.SendQueueTTL,
+// or which are reported stale by the expiry of the channel.
+func (sw *Switch) msgExpired(chID int32, msgBytes []byte, queuedAt time.Time) bool {
+	if sw.cfg.SendQueueTTL > 0 && time.Since(queuedAt) > sw.cfg.SendQueueTTL {
+		return true
+	}
+	sw.mtx.Lock()
+	expired := sw.expiries[chID]
+	sw.mtx.Unlock()
+
+	return expired != nil && expired(msgBytes)
+}
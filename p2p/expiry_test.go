@@ -0,0 +1,32 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+func TestMsgExpired(t *testing.T) {
+	sw := &Switch{cfg: &Config{}}
+	now := time.Now()
+	if sw.msgExpired(libs.ConsensusChannel, []byte("a"), now.Add(-time.Hour)) {
+		t.Errorf("msgs never expire by default")
+	}
+
+	sw.cfg.SendQueueTTL = time.Second
+	if sw.msgExpired(libs.ConsensusChannel, []byte("a"), now) {
+		t.Errorf("fresh msg expired")
+	}
+	if !sw.msgExpired(libs.ConsensusChannel, []byte("a"), now.Add(-2*time.Second)) {
+		t.Errorf("msg beyond the ttl should expire")
+	}
+
+	sw.SetMessageExpiry(libs.ConsensusChannel, func(msg []byte) bool { return string(msg) == "stale" })
+	if !sw.msgExpired(libs.ConsensusChannel, []byte("stale"), now) {
+		t.Errorf("stale msg should expire")
+	}
+	if sw.msgExpired(libs.ConsensusChannel, []byte("a"), now) || sw.msgExpired(libs.MempoolChannel, []byte("stale"), now) {
+		t.Errorf("the expiry applies to the stale msgs of its channel only")
+	}
+}
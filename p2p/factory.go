@@ -0,0 +1,74 @@
+package p2p
+
+import (
+	"context"
+
+	"github.com/aucusaga/gohotstuff/libs"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+)
+
+// Router discovers the peers of the network, it's the kademlia dht by default.
+type Router interface {
+	Bootstrap(ctx context.Context) error
+	// ListPeers returns the peers in the routing table.
+	ListPeers() []peer.ID
+	RemovePeer(id peer.ID)
+	Close() error
+}
+
+// HostFactory builds the libp2p host from the options derived from the Config.
+type HostFactory func(ctx context.Context, opts ...libp2p.Option) (host.Host, error)
+
+// RouterFactory builds the router on the host from the options derived from the Config.
+type RouterFactory func(ctx context.Context, h host.Host, opts ...dht.Option) (Router, error)
+
+// SwitchOption customizes the switch, mostly for testing without real sockets,
+// e.g. the hosts of github.com/libp2p/go-libp2p/p2p/net/mock.
+type SwitchOption func(sw *Switch)
+
+func WithHostFactory(f HostFactory) SwitchOption {
+	return func(sw *Switch) {
+		sw.newHost = f
+	}
+}
+
+func WithRouterFactory(f RouterFactory) SwitchOption {
+	return func(sw *Switch) {
+		sw.newRouter = f
+	}
+}
+
+// WithClock replaces the system clock of the switch, e.g. a libs.ManualClock of the simulation.
+func WithClock(c libs.Clock) SwitchOption {
+	return func(sw *Switch) {
+		sw.clock = c
+	}
+}
+
+func defaultHostFactory(ctx context.Context, opts ...libp2p.Option) (host.Host, error) {
+	return libp2p.New(ctx, opts...)
+}
+
+func defaultRouterFactory(ctx context.Context, h host.Host, opts ...dht.Option) (Router, error) {
+	kdht, err := dht.New(ctx, h, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &dhtRouter{kdht}, nil
+}
+
+type dhtRouter struct {
+	*dht.IpfsDHT
+}
+
+func (r *dhtRouter) ListPeers() []peer.ID {
+	return r.RoutingTable().ListPeers()
+}
+
+func (r *dhtRouter) RemovePeer(id peer.ID) {
+	r.RoutingTable().RemovePeer(id)
+}
@@ -0,0 +1,72 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+var (
+	ErrProtocolVersion = errors.New("protocol version of the peer is below the min one")
+	ErrFeatureMissing  = errors.New("peer lacks the required features")
+)
+
+// negotiate gates the peer by its protocol version and the required features,
+// and returns the features enabled by both sides.
+func (sw *Switch) negotiate(remote *pb.Handshake) (libs.Features, error) {
+	if remote.GetProtocolVersion() < sw.cfg.MinProtocolVersion {
+		return 0, fmt.Errorf("%w, min: %d, has: %d, peer_id: %s",
+			ErrProtocolVersion, sw.cfg.MinProtocolVersion, remote.GetProtocolVersion(), remote.GetNodeId())
+	}
+	features := libs.Features(remote.GetFeatures())
+	if missing := sw.cfg.RequiredFeatures &^ features; missing != 0 {
+		return 0, fmt.Errorf("%w, missing: %s, peer_id: %s", ErrFeatureMissing, missing, remote.GetNodeId())
+	}
+	if !sameFlags(sw.cfg.Experimental, remote.GetExperimental()) {
+		sw.log.Warn("experimental flags mismatch @ p2p.negotiate, peer_id: %s, ours: %v, theirs: %v",
+			remote.GetNodeId(), sw.cfg.Experimental, remote.GetExperimental())
+	}
+	return sw.cfg.Features & features, nil
+}
+
+// sameFlags reports whether both sides have enabled the same experimental flags in any order.
+func sameFlags(ours, theirs []string) bool {
+	if len(ours) != len(theirs) {
+		return false
+	}
+	enabled := make(map[string]bool, len(ours))
+	for _, f := range ours {
+		enabled[f] = true
+	}
+	for _, f := range theirs {
+		if !enabled[f] {
+			return false
+		}
+	}
+	return true
+}
+
+// PeerFeatures returns the features negotiated with the connected peer.
+func (sw *Switch) PeerFeatures(pr string) (libs.Features, bool) {
+	id, err := peer.Decode(pr)
+	if err != nil {
+		return 0, false
+	}
+	p, err := sw.peers.Find(id)
+	if err != nil {
+		return 0, false
+	}
+	return p.Features(), true
+}
+
+// envelope returns the chain stamped on the packets of the conn with the features negotiated.
+func (sw *Switch) envelope(features libs.Features) envelope {
+	return envelope{
+		chainID: sw.cfg.ChainID,
+		forkID:  sw.cfg.ForkID,
+		strict:  features.Has(libs.FeatureChainEnvelope),
+	}
+}
@@ -0,0 +1,36 @@
+package p2p
+
+import (
+	"errors"
+	"testing"
+
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+func TestNegotiate(t *testing.T) {
+	sw := &Switch{cfg: &Config{Features: libs.FeatureCompression | libs.FeatureBLSQC}}
+	f, err := sw.negotiate(&pb.Handshake{Features: uint64(libs.FeatureCompression | libs.FeatureChunkedPayload)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f != libs.FeatureCompression {
+		t.Errorf("only the features of both sides are enabled, has: %s", f)
+	}
+	// the peers predating the features advertise none
+	if f, err := sw.negotiate(&pb.Handshake{}); err != nil || f != 0 {
+		t.Errorf("want no features, has: %s, err: %v", f, err)
+	}
+
+	sw.cfg.RequiredFeatures = libs.FeatureBLSQC
+	if _, err := sw.negotiate(&pb.Handshake{Features: uint64(libs.FeatureCompression)}); !errors.Is(err, ErrFeatureMissing) {
+		t.Errorf("want missing feature, has: %v", err)
+	}
+	sw.cfg.MinProtocolVersion = ProtocolVersion
+	if _, err := sw.negotiate(&pb.Handshake{Features: uint64(libs.FeatureBLSQC)}); !errors.Is(err, ErrProtocolVersion) {
+		t.Errorf("want old protocol, has: %v", err)
+	}
+	if _, err := sw.negotiate(&pb.Handshake{ProtocolVersion: ProtocolVersion, Features: uint64(libs.FeatureBLSQC)}); err != nil {
+		t.Errorf("want negotiated, has: %v", err)
+	}
+}
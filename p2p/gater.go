@@ -0,0 +1,78 @@
+package p2p
+
+import (
+	"errors"
+
+	"github.com/libp2p/go-libp2p-core/connmgr"
+	"github.com/libp2p/go-libp2p-core/control"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/multiformats/go-multiaddr"
+)
+
+var (
+	ErrPeerGated = errors.New("peer is refused by the connection gater")
+)
+
+// ConnectionGater applies the policy of the embedding application, e.g. geo restrictions
+// or corporate firewall rules, before the switch admits a peer, returning false refuses it.
+// It's invoked concurrently by the network and the switch and must not block.
+type ConnectionGater interface {
+	// InterceptPeerDial is invoked before the host dials the peer.
+	InterceptPeerDial(id PeerID) bool
+	// InterceptAccept is invoked once an inbound connection is accepted from the socket,
+	// the remote peer hasn't been authenticated yet.
+	InterceptAccept(addrs network.ConnMultiaddrs) bool
+	// InterceptSecured is invoked once the remote peer of the connection is authenticated,
+	// in both directions, the peer isn't added until it's allowed.
+	InterceptSecured(dir network.Direction, id PeerID, addrs network.ConnMultiaddrs) bool
+}
+
+// WithConnectionGater sets the gater consulted for every connection of the switch.
+func WithConnectionGater(g ConnectionGater) SwitchOption {
+	return func(sw *Switch) {
+		sw.gater = g
+	}
+}
+
+// allowDial reports whether the gater, if any, allows dialing the peer.
+func (sw *Switch) allowDial(id PeerID) bool {
+	return sw.gater == nil || sw.gater.InterceptPeerDial(id)
+}
+
+// allowStream checks the conn of the stream again before the peer is added,
+// since the hosts other than the default one may not honor the gater.
+func (sw *Switch) allowStream(stream network.Stream) bool {
+	if sw.gater == nil {
+		return true
+	}
+	conn := stream.Conn()
+	return sw.gater.InterceptSecured(conn.Stat().Direction, conn.RemotePeer(), conn)
+}
+
+// libp2pGater adapts the ConnectionGater to the libp2p host, the checkpoints which
+// aren't exposed to the application allow everything.
+type libp2pGater struct {
+	g ConnectionGater
+}
+
+var _ connmgr.ConnectionGater = (*libp2pGater)(nil)
+
+func (l *libp2pGater) InterceptPeerDial(id PeerID) bool {
+	return l.g.InterceptPeerDial(id)
+}
+
+func (l *libp2pGater) InterceptAddrDial(PeerID, multiaddr.Multiaddr) bool {
+	return true
+}
+
+func (l *libp2pGater) InterceptAccept(addrs network.ConnMultiaddrs) bool {
+	return l.g.InterceptAccept(addrs)
+}
+
+func (l *libp2pGater) InterceptSecured(dir network.Direction, id PeerID, addrs network.ConnMultiaddrs) bool {
+	return l.g.InterceptSecured(dir, id, addrs)
+}
+
+func (l *libp2pGater) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
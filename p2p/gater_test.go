@@ -0,0 +1,65 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/network"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+type denyGater struct {
+	mtx     sync.Mutex
+	denied  map[PeerID]bool
+	secured []PeerID
+}
+
+func (g *denyGater) InterceptPeerDial(id PeerID) bool {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	return !g.denied[id]
+}
+
+func (g *denyGater) InterceptAccept(addrs network.ConnMultiaddrs) bool { return true }
+
+func (g *denyGater) InterceptSecured(dir network.Direction, id PeerID, addrs network.ConnMultiaddrs) bool {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	g.secured = append(g.secured, id)
+	return !g.denied[id]
+}
+
+func TestConnectionGater(t *testing.T) {
+	mn := mocknet.New(context.Background())
+	r1, r2, r3 := newRecvReactor(), newRecvReactor(), newRecvReactor()
+	sw1 := newMockSwitch(t, mn, r1)
+	sw2 := newMockSwitch(t, mn, r2)
+	sw3 := newMockSwitch(t, mn, r3)
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+	g1 := &denyGater{denied: map[PeerID]bool{sw2.host.ID(): true}}
+	sw1.gater = g1
+	// sw3 refuses the inbound stream of sw1
+	sw3.gater = &denyGater{denied: map[PeerID]bool{sw1.host.ID(): true}}
+
+	addr2 := fmt.Sprintf("%s/p2p/%s", sw2.host.Addrs()[0], sw2.host.ID().Pretty())
+	if err := sw1.connect(context.Background(), addr2); err != ErrPeerGated {
+		t.Errorf("want: %v, has: %v", ErrPeerGated, err)
+	}
+	addr3 := fmt.Sprintf("%s/p2p/%s", sw3.host.Addrs()[0], sw3.host.ID().Pretty())
+	sw1.connect(context.Background(), addr3)
+	select {
+	case <-r3.peers:
+		t.Fatal("the gated peer should not be added")
+	case <-time.After(200 * time.Millisecond):
+	}
+	g1.mtx.Lock()
+	defer g1.mtx.Unlock()
+	if len(g1.secured) != 1 || g1.secured[0] != sw3.host.ID() {
+		t.Errorf("the outbound stream should be checked, has: %v", g1.secured)
+	}
+}
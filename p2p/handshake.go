@@ -0,0 +1,116 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
+	"github.com/aucusaga/gohotstuff/libs"
+	ggio "github.com/gogo/protobuf/io"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/protocol"
+)
+
+const (
+	// DefaultHandshakeTimeout bounds the handshake of a new stream, see Config.HandshakeTimeout.
+	DefaultHandshakeTimeout = 3 * time.Second
+	// ProtocolVersion is the wire protocol of this binary, it's bumped on every incompatible change,
+	// the compatible ones are rolled out as libs.Features.
+	ProtocolVersion uint32 = 1
+)
+
+var (
+	ErrGenesisMismatch = errors.New("genesis hash mismatch")
+)
+
+// protocolID returns the libp2p protocol id of the network, so that
+// nodes of different networks never negotiate a stream or share a dht.
+func protocolID(networkID string) protocol.ID {
+	if networkID == "" {
+		return protocol.ID(protocolPrefix)
+	}
+	return protocol.ID(protocolPrefix + "/" + networkID)
+}
+
+// handshake exchanges the network id and the genesis hash with the remote peer before any packet is sent,
+// the stream is rejected if they mismatch, the genesis isn't checked if genesisHash is empty.
+// The protocol version, the features and the experimental flags are advertised along, the remote handshake is returned
+// for the negotiation.
+// Both the write and the read must be done in the timeout, DefaultHandshakeTimeout if it's not positive.
+func handshake(stream network.Stream, networkID string, nodeID string, genesisHash []byte, features libs.Features,
+	experimental []string, timeout time.Duration) (*pb.Handshake, error) {
+	if timeout <= 0 {
+		timeout = DefaultHandshakeTimeout
+	}
+	if err := stream.SetDeadline(time.Now().Add(timeout)); err != nil {
+		// the stream doesn't support deadlines, e.g. the mocknet ones,
+		// reset it if the handshake isn't done in time.
+		timer := time.AfterFunc(timeout, func() { stream.Reset() })
+		defer timer.Stop()
+	} else {
+		defer stream.SetDeadline(time.Time{})
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		w := ggio.NewDelimitedWriter(stream)
+		errCh <- w.WriteMsg(&pb.Handshake{
+			NetworkId:       networkID,
+			NodeId:          nodeID,
+			GenesisHash:     genesisHash,
+			ProtocolVersion: ProtocolVersion,
+			Features:        uint64(features),
+			Experimental:    experimental,
+		})
+	}()
+
+	var remote pb.Handshake
+	if err := readHandshake(stream, &remote); err != nil {
+		return nil, fmt.Errorf("read handshake fail @ p2p.handshake, err: %v", err)
+	}
+	if err := <-errCh; err != nil {
+		return nil, fmt.Errorf("write handshake fail @ p2p.handshake, err: %v", err)
+	}
+	if remote.GetNetworkId() != networkID {
+		return nil, fmt.Errorf("network id mismatch @ p2p.handshake, want: %s, has: %s, peer_id: %s",
+			networkID, remote.GetNetworkId(), remote.GetNodeId())
+	}
+	if len(genesisHash) > 0 && !bytes.Equal(remote.GetGenesisHash(), genesisHash) {
+		return nil, fmt.Errorf("%w @ p2p.handshake, want: %x, has: %x, peer_id: %s",
+			ErrGenesisMismatch, genesisHash, remote.GetGenesisHash(), remote.GetNodeId())
+	}
+	return &remote, nil
+}
+
+// readHandshake reads a delimited msg without buffering, the bytes following
+// the handshake belong to the conn reader.
+func readHandshake(r io.Reader, msg *pb.Handshake) error {
+	length, err := binary.ReadUvarint(&byteReader{r: r})
+	if err != nil {
+		return err
+	}
+	if length > defaultMaxPacketMsgSize {
+		return fmt.Errorf("handshake is too big: %d bytes", length)
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
+	}
+	return msg.Unmarshal(buf)
+}
+
+type byteReader struct {
+	r   io.Reader
+	buf [1]byte
+}
+
+func (b *byteReader) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(b.r, b.buf[:]); err != nil {
+		return 0, err
+	}
+	return b.buf[0], nil
+}
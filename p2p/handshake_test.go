@@ -0,0 +1,86 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/libp2p/go-libp2p-core/network"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+func TestHandshakeGenesis(t *testing.T) {
+	mn := mocknet.New(context.Background())
+	h1, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		local, remote []byte
+		want          error
+	}{
+		{[]byte("a"), []byte("a"), nil},
+		{[]byte("a"), []byte("b"), ErrGenesisMismatch},
+		{[]byte("a"), nil, ErrGenesisMismatch},
+		// the genesis isn't checked without the local hash
+		{nil, []byte("b"), nil},
+	}
+	for i, c := range cases {
+		remote := c.remote
+		h2.SetStreamHandler("/test", func(s network.Stream) {
+			handshake(s, "test", h2.ID().Pretty(), remote, 0, []string{libs.FlagPipelining}, 0)
+		})
+		s, err := h1.NewStream(context.Background(), h2.ID(), "/test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		hs, err := handshake(s, "test", h1.ID().Pretty(), c.local, 0, nil, 0)
+		if !errors.Is(err, c.want) {
+			t.Errorf("case %d, want: %v, has: %v", i, c.want, err)
+		}
+		if err == nil && !sameFlags(hs.GetExperimental(), []string{libs.FlagPipelining}) {
+			t.Errorf("case %d, want the experimental flags of the remote, has: %v", i, hs.GetExperimental())
+		}
+		s.Reset()
+	}
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	mn := mocknet.New(context.Background())
+	h1, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+	// the remote trickles a byte of its handshake and stalls
+	h2.SetStreamHandler("/test", func(s network.Stream) { s.Write([]byte{10}) })
+	s, err := h1.NewStream(context.Background(), h2.ID(), "/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Reset()
+
+	start := time.Now()
+	if _, err := handshake(s, "test", h1.ID().Pretty(), nil, 0, nil, 100*time.Millisecond); err == nil {
+		t.Fatal("want the stalled handshake failed")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("want the handshake failed in its timeout, has: %v", elapsed)
+	}
+}
@@ -0,0 +1,83 @@
+package p2p
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrPeerIdle = errors.New("stream has been idle longer than the idle timeout")
+)
+
+// keepAliveRoutine checks the idleness of the streams twice every interval.
+func (sw *Switch) keepAliveRoutine() {
+	interval := sw.cfg.IdleTimeout
+	if sw.cfg.PingInterval > 0 && (interval <= 0 || sw.cfg.PingInterval < interval) {
+		interval = sw.cfg.PingInterval
+	}
+	ticker := sw.clock.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			sw.checkIdle(time.Now())
+		case <-sw.stopped:
+			return
+		}
+	}
+}
+
+// checkIdle pings the current validators once their streams have been idle for the ping interval,
+// the streams of the other peers are closed once they've been idle for the idle timeout.
+func (sw *Switch) checkIdle(now time.Time) {
+	c := sw.newPeerClasser()
+	for _, p := range sw.peers.List() {
+		idle := now.Sub(p.LastActive())
+		if c.class(p.ID()) == PeerClassValidator {
+			if sw.cfg.PingInterval > 0 && idle >= sw.cfg.PingInterval && !p.Ping() {
+				sw.log.Warn("ping validator fail @ p2p.checkIdle, peer_id: %s, idle: %v", p.ID().Pretty(), idle)
+			}
+			continue
+		}
+		if sw.cfg.IdleTimeout > 0 && idle >= sw.cfg.IdleTimeout {
+			sw.stopIdlePeer(p, now)
+		}
+	}
+}
+
+// stopIdlePeer closes the stream, the peer isn't dialed by the acceptRoutine within the idle timeout,
+// it may still connect to us.
+func (sw *Switch) stopIdlePeer(p Peer, now time.Time) {
+	if !sw.peers.Remove(p) {
+		return
+	}
+	p.Stop()
+	for _, r := range sw.reactors() {
+		r.RemovePeer(p, ErrPeerIdle)
+	}
+	sw.auditPeer(AuditDisconnect, p, ErrPeerIdle)
+
+	sw.mtx.Lock()
+	if sw.idle == nil {
+		sw.idle = make(map[PeerID]time.Time)
+	}
+	sw.idle[p.ID()] = now
+	sw.mtx.Unlock()
+	sw.log.Info("close idle stream @ p2p.stopIdlePeer, peer_id: %s, last_active: %v", p.ID().Pretty(), p.LastActive())
+}
+
+// recentlyIdle reports whether the stream of the peer has been closed for the idleness within the idle timeout.
+func (sw *Switch) recentlyIdle(id PeerID, now time.Time) bool {
+	sw.mtx.Lock()
+	defer sw.mtx.Unlock()
+
+	closed, ok := sw.idle[id]
+	if !ok {
+		return false
+	}
+	if now.Sub(closed) >= sw.cfg.IdleTimeout {
+		delete(sw.idle, id)
+		return false
+	}
+	return true
+}
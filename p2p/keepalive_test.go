@@ -0,0 +1,52 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+func TestCheckIdle(t *testing.T) {
+	sw := &Switch{
+		cfg:     &Config{IdleTimeout: time.Minute, PingInterval: 10 * time.Second},
+		peers:   NewPeerSet(),
+		reactor: make(map[Module]libs.Reactor),
+		log:     logs.NewLogger(),
+	}
+	validator, idle, busy := NewMockPeer("validator", true), NewMockPeer("idle", true), NewMockPeer("busy", false)
+	sw.SetValidatorSet(libs.ConsensusChannel, func() []string { return []string{validator.PeerID()} })
+	for _, p := range []*MockPeer{validator, idle, busy} {
+		if err := sw.peers.Add(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	validator.SetLastActive(now.Add(-2 * time.Minute))
+	idle.SetLastActive(now.Add(-2 * time.Minute))
+	busy.SetLastActive(now.Add(-time.Second))
+
+	sw.checkIdle(now)
+	if validator.Pings() != 1 {
+		t.Errorf("the idle validator should be pinged, pings: %d", validator.Pings())
+	}
+	if _, err := sw.peers.Find(validator.ID()); err != nil {
+		t.Errorf("the validator should be kept alive")
+	}
+	if _, err := sw.peers.Find(idle.ID()); err == nil {
+		t.Errorf("the idle peer should be closed")
+	}
+	if _, err := sw.peers.Find(busy.ID()); err != nil || busy.Pings() != 0 {
+		t.Errorf("the busy peer should be left alone")
+	}
+
+	// the closed peer isn't redialed within the idle timeout
+	order := sw.dialOrder([]PeerID{idle.ID()})
+	if len(order[PeerClassOther]) != 0 {
+		t.Errorf("the idle peer should not be redialed, order: %v", order)
+	}
+	if sw.recentlyIdle(idle.ID(), now.Add(time.Minute)) {
+		t.Errorf("the idle peer may be redialed after the idle timeout")
+	}
+}
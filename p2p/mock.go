@@ -0,0 +1,150 @@
+package p2p
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+// MockPeer is a Peer without any conn, it records the msgs sent to it.
+type MockPeer struct {
+	id       PeerID
+	outbound bool
+
+	started bool
+	stopped bool
+	sent    map[int32][][]byte
+	active  time.Time
+	pings   int
+	// features are negotiated with the peer, none by default
+	features libs.Features
+	mtx      sync.Mutex
+}
+
+var _ Peer = (*MockPeer)(nil)
+
+func NewMockPeer(id PeerID, outbound bool) *MockPeer {
+	return &MockPeer{
+		id:       id,
+		outbound: outbound,
+		sent:     make(map[int32][][]byte),
+		active:   time.Now(),
+	}
+}
+
+func (p *MockPeer) ID() PeerID                          { return p.id }
+func (p *MockPeer) PeerID() string                      { return p.id.Pretty() }
+func (p *MockPeer) Outbound() bool                      { return p.outbound }
+func (p *MockPeer) NetAddress() (*NetAddress, error)    { return nil, nil }
+func (p *MockPeer) Validate() error                     { return nil }
+func (p *MockPeer) CompatibleWith(other NodeInfo) error { return nil }
+
+func (p *MockPeer) Start() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.started = true
+}
+
+func (p *MockPeer) Stop() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.stopped = true
+}
+
+func (p *MockPeer) FlushStop() {
+	p.Stop()
+}
+
+// Send records the msg, it fails once the peer is stopped.
+func (p *MockPeer) Send(chID int32, msgBytes []byte) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.stopped {
+		return false
+	}
+	p.sent[chID] = append(p.sent[chID], msgBytes)
+	return true
+}
+
+// SendWithResult records the msg like Send, the result is resolved at once.
+func (p *MockPeer) SendWithResult(chID int32, msgBytes []byte) <-chan error {
+	result := make(chan error, 1)
+	if !p.Send(chID, msgBytes) {
+		result <- ErrConnStopped
+	} else {
+		result <- nil
+	}
+	return result
+}
+
+// Sent returns the msgs sent on the channel.
+func (p *MockPeer) Sent(chID int32) [][]byte {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return append([][]byte(nil), p.sent[chID]...)
+}
+
+// LastActive is the time the peer was created, or set by SetLastActive, or the latest ping.
+func (p *MockPeer) LastActive() time.Time {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return p.active
+}
+
+func (p *MockPeer) SetLastActive(t time.Time) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.active = t
+}
+
+// Ping refreshes the activity, it fails once the peer is stopped.
+func (p *MockPeer) Ping() bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.stopped {
+		return false
+	}
+	p.pings++
+	p.active = time.Now()
+	return true
+}
+
+// Pings returns the number of the pings sent.
+func (p *MockPeer) Pings() int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return p.pings
+}
+
+func (p *MockPeer) Features() libs.Features {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return p.features
+}
+
+// Traffic is nil, the mock doesn't frame the msgs.
+func (p *MockPeer) Traffic() []Traffic {
+	return nil
+}
+
+func (p *MockPeer) SetFeatures(f libs.Features) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.features = f
+}
+
+func (p *MockPeer) IsRunning() bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return p.started && !p.stopped
+}
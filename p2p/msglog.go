@@ -0,0 +1,114 @@
+package p2p
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+// DefaultMsgLogBytes is the prefix of the payloads logged when MsgLogConfig.MaxBytes is not set.
+const DefaultMsgLogBytes = 32
+
+// the levels of the per-msg logs, see MsgLogConfig
+const (
+	MsgLogOff   = "off"
+	MsgLogDebug = "debug"
+	MsgLogInfo  = "info"
+)
+
+var (
+	ErrInvalidMsgLogLevel = errors.New("invalid msg log level")
+)
+
+// MsgLogConfig controls the logs of the msgs sent and received by the switch,
+// the failures are logged at the error level regardless of it.
+type MsgLogConfig struct {
+	// Level is the level of the per-msg logs, debug by default, off disables them.
+	Level string
+	// MaxBytes truncates the payloads logged in hex, DefaultMsgLogBytes by default,
+	// negative logs the size and the sum only.
+	MaxBytes int
+}
+
+// Redactor rewrites the payload of the channel before it's logged, e.g. masks the txs of the application,
+// returning nil hides the payload but for its size and sum. It's invoked concurrently and for the enabled
+// logs only, the msgBytes are lent like the ones of libs.Reactor.HandleFunc.
+type Redactor func(chID int32, msgBytes []byte) []byte
+
+// WithRedactor sets the redactor of the msgs logged by the switch.
+func WithRedactor(r Redactor) SwitchOption {
+	return func(sw *Switch) {
+		sw.redact = r
+	}
+}
+
+// MsgLogger logs the msgs, a msg is formatted only if its log is written,
+// the nil logger logs nothing but the sums of the failed msgs.
+type MsgLogger struct {
+	log      libs.Logger
+	level    string
+	maxBytes int
+	redact   Redactor
+}
+
+func NewMsgLogger(cfg MsgLogConfig, redact Redactor, logger libs.Logger) (*MsgLogger, error) {
+	level := cfg.Level
+	switch level {
+	case "":
+		level = MsgLogDebug
+	case MsgLogOff, MsgLogDebug, MsgLogInfo:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMsgLogLevel, cfg.Level)
+	}
+	maxBytes := cfg.MaxBytes
+	if maxBytes == 0 {
+		maxBytes = DefaultMsgLogBytes
+	}
+	return &MsgLogger{log: logger, level: level, maxBytes: maxBytes, redact: redact}, nil
+}
+
+// Log writes the event of the msg at the configured level, the channel and the msg are appended to the args.
+func (l *MsgLogger) Log(format string, chID int32, msgBytes []byte, args ...interface{}) {
+	if l == nil {
+		return
+	}
+	format += ", channel: %d, msg: %v"
+	args = append(args, chID, l.Msg(chID, msgBytes))
+	switch l.level {
+	case MsgLogInfo:
+		l.log.Info(format, args...)
+	case MsgLogDebug:
+		l.log.Debug(format, args...)
+	}
+}
+
+// Msg returns the msg as a log arg, e.g. of the failures logged at the error level.
+func (l *MsgLogger) Msg(chID int32, msgBytes []byte) fmt.Stringer {
+	return loggedMsg{l: l, chID: chID, b: msgBytes}
+}
+
+// loggedMsg defers the redaction and the encoding of the msg until the logger formats it.
+type loggedMsg struct {
+	l    *MsgLogger
+	chID int32
+	b    []byte
+}
+
+func (m loggedMsg) String() string {
+	s := fmt.Sprintf("{size: %d, sum: %s", len(m.b), libs.GetSum(m.b))
+	if m.l == nil || m.l.maxBytes < 0 {
+		return s + "}"
+	}
+	b := m.b
+	if m.l.redact != nil {
+		if b = m.l.redact(m.chID, b); b == nil {
+			return s + ", bytes: <redacted>}"
+		}
+	}
+	if len(b) > m.l.maxBytes {
+		return fmt.Sprintf("%s, bytes: %s...(%d more)}", s, hex.EncodeToString(b[:m.l.maxBytes]), len(b)-m.l.maxBytes)
+	}
+	return fmt.Sprintf("%s, bytes: %s}", s, hex.EncodeToString(b))
+}
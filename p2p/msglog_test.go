@@ -0,0 +1,98 @@
+package p2p
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// levelLogger records the logs by level.
+type levelLogger struct {
+	logs map[string][]string
+}
+
+func (l *levelLogger) add(level, format string, args ...interface{}) {
+	if l.logs == nil {
+		l.logs = make(map[string][]string)
+	}
+	l.logs[level] = append(l.logs[level], fmt.Sprintf(format, args...))
+}
+
+func (l *levelLogger) Error(msg string, ctx ...interface{}) { l.add("error", msg, ctx...) }
+func (l *levelLogger) Warn(msg string, ctx ...interface{})  { l.add("warn", msg, ctx...) }
+func (l *levelLogger) Info(msg string, ctx ...interface{})  { l.add("info", msg, ctx...) }
+func (l *levelLogger) Trace(msg string, ctx ...interface{}) { l.add("trace", msg, ctx...) }
+func (l *levelLogger) Debug(msg string, ctx ...interface{}) { l.add("debug", msg, ctx...) }
+
+func TestMsgLoggerLevels(t *testing.T) {
+	msg := []byte{0xde, 0xad}
+	for level, want := range map[string]string{"": "debug", MsgLogDebug: "debug", MsgLogInfo: "info", MsgLogOff: ""} {
+		log := &levelLogger{}
+		l, err := NewMsgLogger(MsgLogConfig{Level: level}, nil, log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.Log("send succ @ test, to: %s", 3, msg, "peer")
+		total := 0
+		for _, logs := range log.logs {
+			total += len(logs)
+		}
+		if want == "" {
+			if total != 0 {
+				t.Errorf("level %q, want no log, has: %v", level, log.logs)
+			}
+			continue
+		}
+		if total != 1 || len(log.logs[want]) != 1 {
+			t.Fatalf("level %q, want a %s log, has: %v", level, want, log.logs)
+		}
+		if has := log.logs[want][0]; !strings.HasPrefix(has, "send succ @ test, to: peer, channel: 3, msg: {size: 2, sum: ") ||
+			!strings.HasSuffix(has, ", bytes: dead}") {
+			t.Errorf("level %q, has: %s", level, has)
+		}
+	}
+	if _, err := NewMsgLogger(MsgLogConfig{Level: "verbose"}, nil, &levelLogger{}); !errors.Is(err, ErrInvalidMsgLogLevel) {
+		t.Errorf("want: %v, has: %v", ErrInvalidMsgLogLevel, err)
+	}
+}
+
+func TestMsgLoggerTruncateRedact(t *testing.T) {
+	msg := bytes.Repeat([]byte{0xab}, 40)
+	l, _ := NewMsgLogger(MsgLogConfig{}, nil, &levelLogger{})
+	if has := l.Msg(1, msg).String(); !strings.HasSuffix(has, ", bytes: "+strings.Repeat("ab", DefaultMsgLogBytes)+"...(8 more)}") {
+		t.Errorf("want the payload truncated, has: %s", has)
+	}
+	l, _ = NewMsgLogger(MsgLogConfig{MaxBytes: -1}, nil, &levelLogger{})
+	if has := l.Msg(1, msg).String(); strings.Contains(has, "bytes") {
+		t.Errorf("want the size and the sum only, has: %s", has)
+	}
+
+	redact := func(chID int32, b []byte) []byte {
+		if chID == 2 {
+			return nil
+		}
+		return []byte("masked")
+	}
+	l, _ = NewMsgLogger(MsgLogConfig{}, redact, &levelLogger{})
+	if has := l.Msg(2, msg).String(); !strings.HasSuffix(has, ", bytes: <redacted>}") || strings.Contains(has, "abab") {
+		t.Errorf("want the payload redacted, has: %s", has)
+	}
+	if has := l.Msg(1, msg).String(); !strings.HasPrefix(has, "{size: 40, ") || !strings.HasSuffix(has, ", bytes: 6d61736b6564}") {
+		t.Errorf("want the payload rewritten, has: %s", has)
+	}
+}
+
+func TestMsgLoggerLazy(t *testing.T) {
+	calls := 0
+	redact := func(chID int32, b []byte) []byte {
+		calls++
+		return b
+	}
+	l, _ := NewMsgLogger(MsgLogConfig{Level: MsgLogOff}, redact, &levelLogger{})
+	l.Log("send succ @ test", 1, []byte("tx"))
+	if calls != 0 {
+		t.Errorf("the disabled logs mustn't redact the msg, calls: %d", calls)
+	}
+}
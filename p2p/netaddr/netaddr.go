@@ -0,0 +1,127 @@
+// Package netaddr parses and validates the peer addresses given by the operators, in the config or by the rpc.
+//
+// Two formats are accepted:
+//
+//	/ip4/127.0.0.1/tcp/30001/p2p/QmQKp8pLWSgV4JiGjuULKV1JsdpxUtnDEUMP8sGaaUbwVL
+//	127.0.0.1:30001@QmQKp8pLWSgV4JiGjuULKV1JsdpxUtnDEUMP8sGaaUbwVL
+//
+// the id may precede the host:port as well, e.g. QmQKp...@127.0.0.1:30001, the ipv6 hosts are bracketed,
+// e.g. [::1]:30001, and the host names are resolved by the dns component when dialing.
+package netaddr
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+var (
+	ErrEmptyAddr        = errors.New("peer address is empty")
+	ErrInvalidID        = errors.New("invalid peer id")
+	ErrInvalidHostPort  = errors.New("invalid host:port")
+	ErrInvalidMultiaddr = errors.New("invalid multiaddr")
+	ErrMissingID        = errors.New("peer address lacks the /p2p/<id> component")
+)
+
+// Parse parses the peer address in either format into the peer id and its transport addresses.
+func Parse(s string) (*peer.AddrInfo, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, ErrEmptyAddr
+	}
+	if strings.HasPrefix(s, "/") {
+		return parseMultiaddr(s)
+	}
+	i := strings.LastIndex(s, "@")
+	if i < 0 {
+		return nil, fmt.Errorf("%w, want host:port@id or a multiaddr starting with /, addr: %q", ErrInvalidHostPort, s)
+	}
+	hostPort, rawID := s[:i], s[i+1:]
+	// the id@host:port order, as the other clients print it
+	if strings.Contains(rawID, ":") && !strings.Contains(hostPort, ":") {
+		hostPort, rawID = rawID, hostPort
+	}
+	id, err := ParseID(rawID)
+	if err != nil {
+		return nil, fmt.Errorf("%w, addr: %q", err, s)
+	}
+	addr, err := hostPortMultiaddr(hostPort)
+	if err != nil {
+		return nil, fmt.Errorf("%w, addr: %q", err, s)
+	}
+	return &peer.AddrInfo{ID: id, Addrs: []multiaddr.Multiaddr{addr}}, nil
+}
+
+// Normalize returns the canonical multiaddr of the peer address, e.g. /ip4/127.0.0.1/tcp/30001/p2p/Qm...
+func Normalize(s string) (string, error) {
+	info, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	addrs, err := peer.AddrInfoToP2pAddrs(info)
+	if err != nil {
+		return "", fmt.Errorf("%w, addr: %q, err: %v", ErrInvalidMultiaddr, s, err)
+	}
+	if len(addrs) == 0 {
+		return "", fmt.Errorf("%w, no transport address, addr: %q", ErrInvalidMultiaddr, s)
+	}
+	return addrs[0].String(), nil
+}
+
+// ParseID validates the base58 peer id, e.g. QmQKp8pLWSgV4JiGjuULKV1JsdpxUtnDEUMP8sGaaUbwVL.
+func ParseID(s string) (peer.ID, error) {
+	if s == "" {
+		return "", fmt.Errorf("%w, id is empty", ErrInvalidID)
+	}
+	id, err := peer.Decode(s)
+	if err != nil {
+		return "", fmt.Errorf("%w, id: %q, err: %v", ErrInvalidID, s, err)
+	}
+	return id, nil
+}
+
+func parseMultiaddr(s string) (*peer.AddrInfo, error) {
+	maddr, err := multiaddr.NewMultiaddr(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w, addr: %q, err: %v", ErrInvalidMultiaddr, s, err)
+	}
+	if _, err := maddr.ValueForProtocol(multiaddr.P_P2P); err != nil {
+		return nil, fmt.Errorf("%w, addr: %q", ErrMissingID, s)
+	}
+	info, err := peer.AddrInfoFromP2pAddr(maddr)
+	if err != nil {
+		return nil, fmt.Errorf("%w, addr: %q, err: %v", ErrInvalidID, s, err)
+	}
+	return info, nil
+}
+
+func hostPortMultiaddr(hostPort string) (multiaddr.Multiaddr, error) {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return nil, fmt.Errorf("%w, err: %v", ErrInvalidHostPort, err)
+	}
+	if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+		return nil, fmt.Errorf("%w, port must be in [1, 65535], has: %q", ErrInvalidHostPort, port)
+	}
+	var s string
+	switch ip := net.ParseIP(host); {
+	case host == "":
+		return nil, fmt.Errorf("%w, host is empty", ErrInvalidHostPort)
+	case ip == nil:
+		s = fmt.Sprintf("/dns/%s/tcp/%s", host, port)
+	case ip.To4() != nil:
+		s = fmt.Sprintf("/ip4/%s/tcp/%s", ip, port)
+	default:
+		s = fmt.Sprintf("/ip6/%s/tcp/%s", ip, port)
+	}
+	addr, err := multiaddr.NewMultiaddr(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w, host: %q, err: %v", ErrInvalidHostPort, host, err)
+	}
+	return addr, nil
+}
@@ -0,0 +1,38 @@
+package netaddr
+
+import (
+	"errors"
+	"testing"
+)
+
+const testID = "QmQKp8pLWSgV4JiGjuULKV1JsdpxUtnDEUMP8sGaaUbwVL"
+
+func TestNormalize(t *testing.T) {
+	for addr, want := range map[string]string{
+		"/ip4/127.0.0.1/tcp/30001/p2p/" + testID: "/ip4/127.0.0.1/tcp/30001/p2p/" + testID,
+		"127.0.0.1:30001@" + testID:              "/ip4/127.0.0.1/tcp/30001/p2p/" + testID,
+		testID + "@127.0.0.1:30001":              "/ip4/127.0.0.1/tcp/30001/p2p/" + testID,
+		" [::1]:30001@" + testID + " ":           "/ip6/::1/tcp/30001/p2p/" + testID,
+		"node0.example.com:30001@" + testID:      "/dns/node0.example.com/tcp/30001/p2p/" + testID,
+	} {
+		has, err := Normalize(addr)
+		if err != nil || has != want {
+			t.Errorf("addr: %s, want: %s, has: %s, err: %v", addr, want, has, err)
+		}
+	}
+
+	for addr, want := range map[string]error{
+		"":                                   ErrEmptyAddr,
+		"127.0.0.1:30001":                    ErrInvalidHostPort,
+		"127.0.0.1@" + testID:                ErrInvalidHostPort,
+		"127.0.0.1:70000@" + testID:          ErrInvalidHostPort,
+		":30001@" + testID:                   ErrInvalidHostPort,
+		"127.0.0.1:30001@Qmbad":              ErrInvalidID,
+		"/ip4/127.0.0.1/tcp/30001":           ErrMissingID,
+		"/ip4/127.0.0.1/tcp/x/p2p/" + testID: ErrInvalidMultiaddr,
+	} {
+		if _, err := Normalize(addr); !errors.Is(err, want) {
+			t.Errorf("addr: %q, want: %v, has: %v", addr, want, err)
+		}
+	}
+}
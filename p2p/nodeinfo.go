@@ -3,6 +3,8 @@ package p2p
 import (
 	"net"
 
+	"github.com/aucusaga/gohotstuff/libs"
+
 	"github.com/libp2p/go-libp2p-core/peer"
 )
 
@@ -26,15 +28,20 @@ type NetAddress struct {
 }
 
 type DefaultNodeInfo struct {
-	addr *peer.AddrInfo
+	addr     *peer.AddrInfo
+	features libs.Features
 }
 
-func (n *DefaultNodeInfo) ID() PeerID { return n.addr.ID }
+func (n *DefaultNodeInfo) ID() PeerID     { return n.addr.ID }
+func (n *DefaultNodeInfo) PeerID() string { return n.addr.ID.Pretty() }
 func (n *DefaultNodeInfo) NetAddress() (*NetAddress, error) {
 	// /ip4/127.0.0.1/tcp/30002
 	return nil, nil
 }
 
+// Features are negotiated in the handshake.
+func (n *DefaultNodeInfo) Features() libs.Features { return n.features }
+
 // for use in the handshake.
 func (n *DefaultNodeInfo) Validate() error                     { return nil }
 func (n *DefaultNodeInfo) CompatibleWith(other NodeInfo) error { return nil }
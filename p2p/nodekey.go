@@ -0,0 +1,125 @@
+package p2p
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/libp2p/go-libp2p-core/crypto"
+)
+
+const (
+	// NodeKeyFileName is the file of the networking identity under Config.NodeKeyDir.
+	NodeKeyFileName = "node_key.json"
+	// legacyKeyFileName is the base64 key written by the former `genkey --type network`.
+	legacyKeyFileName = "private.key"
+)
+
+var (
+	ErrNodeKeyPermissions = errors.New("node key is accessible by other users, chmod 600 it")
+	ErrNodeKeyConflict    = errors.New("inline private key differs from the node key file")
+)
+
+type nodeKeyJSON struct {
+	Type    string `json:"type"`
+	PrivKey []byte `json:"priv_key"`
+}
+
+// LoadOrGenNodeKey returns the networking identity persisted in dir/node_key.json, the file is created
+// on the first start from, in order, the inline base64 key, the legacy dir/private.key or a new key.
+// The inline key is the migration path of the former Config.PrivateKey, it must match an existing file.
+func LoadOrGenNodeKey(dir string, inline string) (crypto.PrivKey, error) {
+	if dir == "" {
+		return nil, errors.New("node key dir empty")
+	}
+	path := filepath.Join(dir, NodeKeyFileName)
+	if libs.FileIsExist(path) {
+		priv, err := loadNodeKey(path)
+		if err != nil || inline == "" {
+			return priv, err
+		}
+		migrated, err := decodeInlineKey(inline)
+		if err != nil {
+			return nil, err
+		}
+		if !priv.Equals(migrated) {
+			return nil, fmt.Errorf("%w, path: %s", ErrNodeKeyConflict, path)
+		}
+		return priv, nil
+	}
+
+	var priv crypto.PrivKey
+	var err error
+	legacy := filepath.Join(dir, legacyKeyFileName)
+	switch {
+	case inline != "":
+		priv, err = decodeInlineKey(inline)
+	case libs.FileIsExist(legacy):
+		var data []byte
+		if data, err = os.ReadFile(legacy); err == nil {
+			priv, err = decodeInlineKey(string(data))
+		}
+	default:
+		priv, err = generateNodeKey()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := saveNodeKey(path, priv); err != nil {
+		return nil, err
+	}
+	return priv, nil
+}
+
+func loadNodeKey(path string) (crypto.PrivKey, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	// the permission bits are meaningless on windows
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("%w, path: %s, mode: %v", ErrNodeKeyPermissions, path, info.Mode().Perm())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var nk nodeKeyJSON
+	if err := json.Unmarshal(data, &nk); err != nil {
+		return nil, fmt.Errorf("decode node key fail, path: %s, err: %v", path, err)
+	}
+	return crypto.UnmarshalPrivateKey(nk.PrivKey)
+}
+
+// saveNodeKey writes the key readable by the owner only, atomically.
+func saveNodeKey(path string, priv crypto.PrivKey) error {
+	raw, err := crypto.MarshalPrivateKey(priv)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(nodeKeyJSON{Type: priv.Type().String(), PrivKey: raw}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func decodeInlineKey(inline string) (crypto.PrivKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(inline)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.UnmarshalPrivateKey(raw)
+}
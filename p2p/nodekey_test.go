@@ -0,0 +1,65 @@
+package p2p
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+)
+
+func TestNodeKey(t *testing.T) {
+	dir := t.TempDir()
+	priv, err := LoadOrGenNodeKey(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := LoadOrGenNodeKey(dir, "")
+	if err != nil || !priv.Equals(again) {
+		t.Fatalf("node key should be persisted, err: %v", err)
+	}
+
+	path := filepath.Join(dir, NodeKeyFileName)
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadOrGenNodeKey(dir, ""); !errors.Is(err, ErrNodeKeyPermissions) {
+		t.Errorf("want permissions err, has: %v", err)
+	}
+}
+
+func TestNodeKeyMigration(t *testing.T) {
+	sk, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := crypto.MarshalPrivateKey(sk)
+	inline := base64.StdEncoding.EncodeToString(raw)
+
+	// the inline key of the config
+	dir := t.TempDir()
+	priv, err := LoadOrGenNodeKey(dir, inline)
+	if err != nil || !priv.Equals(sk) {
+		t.Fatalf("inline key should be migrated, err: %v", err)
+	}
+	other, _, _ := crypto.GenerateEd25519Key(rand.Reader)
+	raw, _ = crypto.MarshalPrivateKey(other)
+	if _, err := LoadOrGenNodeKey(dir, base64.StdEncoding.EncodeToString(raw)); !errors.Is(err, ErrNodeKeyConflict) {
+		t.Errorf("want conflict err, has: %v", err)
+	}
+
+	// the legacy private.key
+	dir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, legacyKeyFileName), []byte(inline), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if priv, err = LoadOrGenNodeKey(dir, ""); err != nil || !priv.Equals(sk) {
+		t.Fatalf("legacy key should be migrated, err: %v", err)
+	}
+	if priv, err = GetKeyPairFromPath(dir); err != nil || !priv.Equals(sk) {
+		t.Errorf("migrated key should be read, err: %v", err)
+	}
+}
@@ -11,3 +11,26 @@ const (
 func TestNewP2P(t *testing.T) {
 
 }
+
+func TestKeepNewConn(t *testing.T) {
+	lower, higher := PeerID("QmA"), PeerID("QmB")
+	cases := []struct {
+		local, remote            PeerID
+		newOutbound, oldOutbound bool
+		keep                     bool
+	}{
+		// simultaneous dials, the one initiated by the lower id wins on both sides
+		{lower, higher, true, false, true},
+		{lower, higher, false, true, false},
+		{higher, lower, true, false, false},
+		{higher, lower, false, true, true},
+		// redial by the same initiator replaces the old one
+		{lower, higher, true, true, true},
+		{higher, lower, false, false, true},
+	}
+	for i, c := range cases {
+		if has := keepNewConn(c.local, c.remote, c.newOutbound, c.oldOutbound); has != c.keep {
+			t.Errorf("case %d, want: %v, has: %v", i, c.keep, has)
+		}
+	}
+}
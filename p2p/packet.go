@@ -0,0 +1,205 @@
+package p2p
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
+)
+
+var (
+	ErrMalformedPacket = errors.New("malformed packet")
+	ErrChainMismatch   = errors.New("packet of another chain")
+)
+
+// the protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// envelope is the chain and the fork stamped on the packet msgs, see Config.ChainID.
+type envelope struct {
+	chainID string
+	forkID  string
+	// strict refuses the unstamped msgs as well, it's set once libs.FeatureChainEnvelope is negotiated
+	strict bool
+}
+
+// check refuses the msg of another chain or fork, the unstamped msgs are accepted unless strict,
+// their senders predate the envelope and the genesis hash of the handshake has pinned their chain.
+func (e envelope) check(msg *pb.PacketMsg) error {
+	if !e.strict && msg.ChainId == "" && msg.ForkId == "" {
+		return nil
+	}
+	if msg.ChainId != e.chainID || msg.ForkId != e.forkID {
+		return fmt.Errorf("%w, want chain: %q, fork: %q, has chain: %q, fork: %q",
+			ErrChainMismatch, e.chainID, e.forkID, msg.ChainId, msg.ForkId)
+	}
+	return nil
+}
+
+// decodePacket decodes the packet like pb.Packet.Unmarshal, except that the data of the
+// msg aliases the payload instead of being copied, the payload must outlive the msg
+// handed to the reactors, see libs.Reactor for the ownership of the msg bytes.
+// The msg of another chain or fork is refused by the envelope.
+func decodePacket(payload []byte, packet *pb.Packet, env envelope) error {
+	r := wireReader{data: payload}
+	for len(r.data) > 0 {
+		num, typ, err := r.tag()
+		if err != nil {
+			return err
+		}
+		switch {
+		case num == 3 && typ == wireBytes:
+			raw, err := r.bytes()
+			if err != nil {
+				return err
+			}
+			msg := &pb.PacketMsg{}
+			if err := decodePacketMsg(raw, msg); err != nil {
+				return err
+			}
+			if err := env.check(msg); err != nil {
+				return err
+			}
+			packet.Sum = &pb.Packet_PacketMsg{PacketMsg: msg}
+		case num == 4 && typ == wireBytes:
+			if _, err := r.bytes(); err != nil {
+				return err
+			}
+			packet.Sum = &pb.Packet_PacketPing{PacketPing: &pb.PacketPing{}}
+		default:
+			if err := r.skip(typ); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func decodePacketMsg(raw []byte, msg *pb.PacketMsg) error {
+	r := wireReader{data: raw}
+	for len(r.data) > 0 {
+		num, typ, err := r.tag()
+		if err != nil {
+			return err
+		}
+		switch {
+		case num == 1 && typ == wireBytes:
+			b, err := r.bytes()
+			if err != nil {
+				return err
+			}
+			msg.LogId = string(b)
+		case num == 2 && typ == wireVarint:
+			v, err := r.varint()
+			if err != nil {
+				return err
+			}
+			msg.ChannelId = int32(v)
+		case num == 3 && typ == wireBytes:
+			b, err := r.bytes()
+			if err != nil {
+				return err
+			}
+			msg.Module = string(b)
+		case num == 4 && typ == wireVarint:
+			v, err := r.varint()
+			if err != nil {
+				return err
+			}
+			msg.Eof = v != 0
+		case num == 5 && typ == wireBytes:
+			b, err := r.bytes()
+			if err != nil {
+				return err
+			}
+			msg.Data = b
+		case num == 6 && typ == wireBytes:
+			b, err := r.bytes()
+			if err != nil {
+				return err
+			}
+			msg.ChainId = string(b)
+		case num == 7 && typ == wireBytes:
+			b, err := r.bytes()
+			if err != nil {
+				return err
+			}
+			msg.ForkId = string(b)
+		default:
+			if err := r.skip(typ); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// wireReader reads the protobuf wire format without copying.
+type wireReader struct {
+	data []byte
+}
+
+func (r *wireReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		return 0, fmt.Errorf("%w, bad varint", ErrMalformedPacket)
+	}
+	r.data = r.data[n:]
+	return v, nil
+}
+
+func (r *wireReader) tag() (int32, int, error) {
+	v, err := r.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+	num := int32(v >> 3)
+	if num <= 0 || v>>3 > 1<<29-1 {
+		return 0, 0, fmt.Errorf("%w, bad field number: %d", ErrMalformedPacket, v>>3)
+	}
+	return num, int(v & 7), nil
+}
+
+// bytes returns the length-delimited field, capped so that appending to it never
+// overwrites the rest of the payload.
+func (r *wireReader) bytes() ([]byte, error) {
+	l, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if l > uint64(len(r.data)) {
+		return nil, fmt.Errorf("%w, field length: %d, remaining: %d", ErrMalformedPacket, l, len(r.data))
+	}
+	b := r.data[:l:l]
+	r.data = r.data[l:]
+	return b, nil
+}
+
+func (r *wireReader) skip(typ int) error {
+	var n int
+	switch typ {
+	case wireVarint:
+		_, err := r.varint()
+		return err
+	case wireBytes:
+		_, err := r.bytes()
+		return err
+	case wireFixed64:
+		n = 8
+	case wireFixed32:
+		n = 4
+	default:
+		return fmt.Errorf("%w, unsupported wire type: %d", ErrMalformedPacket, typ)
+	}
+	if len(r.data) < n {
+		return fmt.Errorf("%w, truncated fixed field", ErrMalformedPacket)
+	}
+	r.data = r.data[n:]
+	return nil
+}
@@ -0,0 +1,140 @@
+package p2p
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
+	"github.com/gogo/protobuf/proto"
+)
+
+func TestDecodePacket(t *testing.T) {
+	packets := []*pb.Packet{
+		{},
+		{Sum: &pb.Packet_PacketPing{PacketPing: &pb.PacketPing{}}},
+		{Sum: &pb.Packet_PacketMsg{PacketMsg: &pb.PacketMsg{}}},
+		{Sum: &pb.Packet_PacketMsg{PacketMsg: &pb.PacketMsg{LogId: "7", ChannelId: -3, Module: "consensus", Eof: true, Data: []byte("vote")}}},
+		{Sum: &pb.Packet_PacketMsg{PacketMsg: &pb.PacketMsg{ChannelId: 0x7fffff01, Data: bytes.Repeat([]byte{0xab}, 4096)}}},
+	}
+	for i, want := range packets {
+		payload, err := want.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var has pb.Packet
+		if err := decodePacket(payload, &has, envelope{}); err != nil {
+			t.Fatalf("packet %d: %v", i, err)
+		}
+		var golden pb.Packet
+		if err := golden.Unmarshal(payload); err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(&golden, &has) {
+			t.Errorf("packet %d, want: %+v, has: %+v", i, &golden, &has)
+		}
+	}
+}
+
+func TestDecodePacketAliases(t *testing.T) {
+	packet := &pb.Packet{Sum: &pb.Packet_PacketMsg{PacketMsg: &pb.PacketMsg{Module: "consensus", Data: []byte("vote")}}}
+	payload, _ := packet.Marshal()
+	// unknown fields of a newer peer are skipped
+	payload = append(payload, 0x28, 0x01, 0x31, 1, 2, 3, 4, 5, 6, 7, 8)
+
+	var has pb.Packet
+	if err := decodePacket(payload, &has, envelope{}); err != nil {
+		t.Fatal(err)
+	}
+	data := has.GetPacketMsg().GetData()
+	if string(data) != "vote" {
+		t.Fatalf("want: vote, has: %q", data)
+	}
+	payload[bytes.Index(payload, []byte("vote"))] = 'n'
+	if string(data) != "note" {
+		t.Errorf("data should alias the payload, has: %q", data)
+	}
+	if cap(data) != len(data) {
+		t.Errorf("appending to the data mustn't overwrite the payload, cap: %d", cap(data))
+	}
+}
+
+func TestDecodePacketMalformed(t *testing.T) {
+	cases := [][]byte{
+		{0x1a},                // truncated length
+		{0x1a, 0x05, 0x2a},    // truncated msg
+		{0x1a, 0x02, 0x2a, 9}, // data longer than the msg
+		{0x80},                // truncated tag
+		{0x00},                // field 0
+		{0x23},                // group
+		{0x21, 1, 2},          // truncated fixed64
+	}
+	for i, c := range cases {
+		var packet pb.Packet
+		if err := decodePacket(c, &packet, envelope{}); !errors.Is(err, ErrMalformedPacket) {
+			t.Errorf("case %d, want: %v, has: %v", i, ErrMalformedPacket, err)
+		}
+	}
+}
+
+func TestDecodePacketEnvelope(t *testing.T) {
+	stamped := func(chainID, forkID string) []byte {
+		packet := &pb.Packet{Sum: &pb.Packet_PacketMsg{PacketMsg: &pb.PacketMsg{Module: "consensus", ChainId: chainID, ForkId: forkID}}}
+		payload, _ := packet.Marshal()
+		return payload
+	}
+	env := envelope{chainID: "main", forkID: "v2"}
+	strict := envelope{chainID: "main", forkID: "v2", strict: true}
+	cases := []struct {
+		env     envelope
+		payload []byte
+		ok      bool
+	}{
+		{env, stamped("main", "v2"), true},
+		{env, stamped("test", "v2"), false},
+		{env, stamped("main", ""), false},
+		{env, stamped("main", "v3"), false},
+		// the older peers don't stamp the packets
+		{env, stamped("", ""), true},
+		{strict, stamped("", ""), false},
+		{strict, stamped("main", "v2"), true},
+		{envelope{}, stamped("", ""), true},
+		{envelope{}, stamped("main", ""), false},
+	}
+	for i, c := range cases {
+		var packet pb.Packet
+		err := decodePacket(c.payload, &packet, c.env)
+		if c.ok && err != nil {
+			t.Errorf("case %d, want ok, has: %v", i, err)
+		}
+		if !c.ok && !errors.Is(err, ErrChainMismatch) {
+			t.Errorf("case %d, want: %v, has: %v", i, ErrChainMismatch, err)
+		}
+	}
+}
+
+func benchmarkPacket(b *testing.B, decode func(payload []byte, packet *pb.Packet) error) {
+	packet := &pb.Packet{Sum: &pb.Packet_PacketMsg{PacketMsg: &pb.PacketMsg{
+		LogId:     "42",
+		ChannelId: 1,
+		Module:    "consensus",
+		Eof:       true,
+		Data:      bytes.Repeat([]byte{0x5a}, 256),
+	}}}
+	payload, _ := packet.Marshal()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var has pb.Packet
+		if err := decode(payload, &has); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPacketUnmarshal(b *testing.B) {
+	benchmarkPacket(b, func(payload []byte, packet *pb.Packet) error { return packet.Unmarshal(payload) })
+}
+
+func BenchmarkDecodePacket(b *testing.B) {
+	benchmarkPacket(b, func(payload []byte, packet *pb.Packet) error { return decodePacket(payload, packet, envelope{}) })
+}
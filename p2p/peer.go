@@ -3,9 +3,11 @@ package p2p
 import (
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/astaxie/beego/logs"
 	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/p2p/tap"
 	"github.com/libp2p/go-libp2p-core/network"
 	pr "github.com/libp2p/go-libp2p-core/peer"
 )
@@ -16,6 +18,17 @@ type PeerID = pr.ID
 type Peer interface {
 	RawConn
 	NodeInfo
+	PeerID() string
+	// Outbound reports whether the conn was dialed by us.
+	Outbound() bool
+	// LastActive is the time of the latest frame sent or received, and Ping sends
+	// a keep-alive frame, see Config.IdleTimeout.
+	LastActive() time.Time
+	Ping() bool
+	// Features are the wire features negotiated in the handshake, see libs.Features.
+	Features() libs.Features
+	// Traffic is the bytes sent and received by the modules since the conn was established.
+	Traffic() []Traffic
 }
 
 // PeerSet is a special structure for keeping a table of peers.
@@ -75,6 +88,35 @@ func (s *PeerSet) Add(peer Peer) error {
 	return nil
 }
 
+// Remove deletes the peer from the set, it returns false if the peer cannot be found.
+func (s *PeerSet) Remove(peer Peer) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if old, ok := s.lookup[peer.ID()]; !ok || old != peer {
+		return false
+	}
+	delete(s.lookup, peer.ID())
+	var newOne []Peer
+	for _, p := range s.list {
+		if p != peer {
+			newOne = append(newOne, p)
+		}
+	}
+	s.list = newOne
+	return true
+}
+
+// List returns a copy of the peers.
+func (s *PeerSet) List() []Peer {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	list := make([]Peer, len(s.list))
+	copy(list, s.list)
+	return list
+}
+
 func (s *PeerSet) Find(id PeerID) (Peer, error) {
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
@@ -91,16 +133,21 @@ type DefaultPeer struct {
 	conn *DefaultConn
 }
 
-func NewDefaultPeer(peer *pr.AddrInfo, netStream network.Stream,
-	onReceiveIdx map[Module]libs.Reactor, logger libs.Logger) (Peer, error) {
+func NewDefaultPeer(peer *pr.AddrInfo, features libs.Features, netStream network.Stream,
+	onReceiveIdx map[Module]libs.Reactor, channels map[int32]Module, onError errorCbFunc,
+	onClose closeCbFunc, recorder tap.Recorder, expired expiredCbFunc, authorize authorizeCbFunc, lag *lagTracker,
+	maxMsgSize int,
+	frameTimeout time.Duration, env envelope, msgs *MsgLogger, traffic *trafficMeter, sendPool, handlePool *workerPool,
+	logger libs.Logger) (Peer, error) {
 	// create a new logger
 	if logger == nil {
 		logger = logs.NewLogger()
 	}
 	peerInfo := &DefaultNodeInfo{
-		addr: peer,
+		addr:     peer,
+		features: features,
 	}
-	conn, err := NewDefaultConn(peerInfo, netStream, onReceiveIdx, logger)
+	conn, err := NewDefaultConn(peerInfo, netStream, onReceiveIdx, channels, onError, onClose, recorder, expired, authorize, lag, maxMsgSize, frameTimeout, env, msgs, traffic, sendPool, handlePool, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -114,10 +161,34 @@ func (dc *DefaultPeer) Start() {
 	dc.conn.Start()
 }
 
+func (p *DefaultPeer) Stop() {
+	p.conn.Stop()
+}
+
 func (p *DefaultPeer) FlushStop() {
 	p.conn.FlushStop()
 }
 
+func (p *DefaultPeer) Outbound() bool {
+	return p.conn.Outbound()
+}
+
 func (p *DefaultPeer) Send(chID int32, msgBytes []byte) bool {
 	return p.conn.Send(chID, msgBytes)
 }
+
+func (p *DefaultPeer) SendWithResult(chID int32, msgBytes []byte) <-chan error {
+	return p.conn.SendWithResult(chID, msgBytes)
+}
+
+func (p *DefaultPeer) LastActive() time.Time {
+	return p.conn.LastActive()
+}
+
+func (p *DefaultPeer) Ping() bool {
+	return p.conn.Ping()
+}
+
+func (p *DefaultPeer) Traffic() []Traffic {
+	return p.conn.traffic.list()
+}
@@ -0,0 +1,66 @@
+package p2p
+
+import (
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/metrics"
+)
+
+// publishPeer publishes the change of the peer set recorded by auditPeer and updates its metrics,
+// the refused conns never join the peer set and are left to the audit log.
+func (sw *Switch) publishPeer(r AuditRecord, p Peer) {
+	var t libs.EventType
+	switch r.Event {
+	case AuditConnect:
+		t = libs.EventPeerConnected
+	case AuditDisconnect:
+		t = libs.EventPeerDisconnected
+	default:
+		return
+	}
+	data := libs.PeerEventData{
+		Peer:      r.Peer,
+		Addr:      r.Addr,
+		Direction: r.Direction,
+		Validator: sw.newPeerClasser().class(p.ID()) == PeerClassValidator,
+		Reason:    r.Reason,
+	}
+	if sw.host != nil {
+		data.Latency = sw.host.Peerstore().LatencyEWMA(p.ID())
+	}
+	sw.countPeerEvent(t, r.Direction)
+	sw.publish(libs.Event{
+		Type:   t,
+		Module: libs.P2PModule,
+		Data:   data,
+	})
+}
+
+// publishBan publishes the address marked suspicious by ban.
+func (sw *Switch) publishBan(r AuditRecord) {
+	sw.countPeerEvent(libs.EventPeerBanned, "")
+	sw.publish(libs.Event{
+		Type:   libs.EventPeerBanned,
+		Module: libs.P2PModule,
+		Data: libs.PeerEventData{
+			Peer:   r.Peer,
+			Addr:   r.Addr,
+			Reason: r.Reason,
+		},
+	})
+}
+
+// countPeerEvent counts the event and recounts the connected peers by the direction,
+// so that the gauges never drift from the peer set.
+func (sw *Switch) countPeerEvent(t libs.EventType, dir string) {
+	r := metrics.DefaultRegistry
+	r.NewCounter("gohotstuff_p2p_peer_events_total",
+		"Changes of the peer set by the event and the direction.", "event", string(t), "direction", dir).Inc()
+
+	peers := map[string]int{direction(true): 0, direction(false): 0}
+	for _, p := range sw.peers.List() {
+		peers[direction(p.Outbound())]++
+	}
+	for d, n := range peers {
+		r.NewGauge("gohotstuff_p2p_peers", "Connected peers by the direction.", "direction", d).Set(float64(n))
+	}
+}
@@ -0,0 +1,79 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/metrics"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+func TestPeerEvents(t *testing.T) {
+	mn := mocknet.New(context.Background())
+	sw := newMockSwitch(t, mn, newRecvReactor())
+	bus := libs.NewDefaultEventBus(nil)
+	sw.SetEventBus(bus)
+	events := bus.Subscribe(libs.EventAll)
+	banned := metrics.DefaultRegistry.NewCounter("gohotstuff_p2p_peer_events_total", "",
+		"event", string(libs.EventPeerBanned), "direction", "")
+	before := banned.Value()
+
+	remote := PeerID(string(sw.host.ID()) + "\xff")
+	sw.addPeer(NewMockPeer(remote, true))
+	// the refused conn doesn't change the peer set
+	sw.addPeer(NewMockPeer(remote, false))
+	if n := metrics.DefaultRegistry.NewGauge("gohotstuff_p2p_peers", "", "direction", "outbound").Value(); n != 1 {
+		t.Errorf("want 1 outbound peer, has: %v", n)
+	}
+	sw.stopPeerForError(remote, errors.New("bad msg"))
+	sw.ban(sw.host.Addrs()[0], remote.Pretty(), "dial-back fail")
+	sw.addPeer(NewMockPeer(remote, true))
+	sw.penalize(remote, -MinPeerScore, errors.New("spam"))
+
+	want := []struct {
+		typ       libs.EventType
+		direction string
+		reason    string
+	}{
+		{libs.EventPeerConnected, "outbound", ""},
+		{libs.EventPeerDisconnected, "outbound", "bad msg"},
+		{libs.EventPeerBanned, "", "dial-back fail"},
+		{libs.EventPeerConnected, "outbound", ""},
+		{libs.EventPeerDisconnected, "outbound", fmt.Sprintf("%v, score: %d, err: spam", ErrPeerScore, MinPeerScore)},
+	}
+	for _, w := range want {
+		// the peer stopped for the error isn't reported as a panic
+		e := <-events
+		data, ok := e.Data.(libs.PeerEventData)
+		if e.Type != w.typ || !ok {
+			t.Fatalf("want %s, has: %+v", w.typ, e)
+		}
+		if data.Peer != remote.Pretty() || data.Direction != w.direction || data.Reason != w.reason || data.Validator {
+			t.Errorf("%s, want direction: %s, reason: %s, has: %+v", w.typ, w.direction, w.reason, data)
+		}
+	}
+	if n := metrics.DefaultRegistry.NewGauge("gohotstuff_p2p_peers", "", "direction", "outbound").Value(); n != 0 {
+		t.Errorf("want no outbound peer, has: %v", n)
+	}
+	select {
+	case e := <-events:
+		t.Errorf("unexpected event: %+v", e)
+	default:
+	}
+	if banned.Value() != before+1 {
+		t.Errorf("want the ban counted, has: %v", banned.Value()-before)
+	}
+
+	bus.Unsubscribe(events)
+	sw.ban(sw.host.Addrs()[0], remote.Pretty(), "dial-back fail")
+	sw.addPeer(NewMockPeer(remote, true))
+	sw.penalize(remote, -MinPeerScore, errors.New("spam"))
+	select {
+	case e := <-events:
+		t.Errorf("want no event after unsubscribing, has: %+v", e)
+	default:
+	}
+}
@@ -0,0 +1,135 @@
+package p2p
+
+import (
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+const (
+	// DefaultSendWorkers is the size of the pool writing the send queues of all conns when Config.SendWorkers is not set.
+	DefaultSendWorkers = 32
+	// DefaultHandleWorkers is the size of the pool dispatching the received msgs when Config.HandleWorkers is not set.
+	DefaultHandleWorkers = 32
+	// sendBatch bounds the msgs a worker writes from a send queue before it yields to the other queues.
+	sendBatch = 16
+)
+
+// workerPool runs the tasks of all conns of the switch on a fixed set of goroutines, so that the idle peers
+// cost no goroutine at all, instead of a send loop for every channel and a goroutine for every msg received.
+// A task is only handed to an idle worker, the ones submitted while all workers are busy, e.g. writing
+// to the slow peers, overflow to their own goroutines and never queue behind a stuck stream.
+type workerPool struct {
+	name  string
+	tasks chan func()
+	quit  chan struct{}
+	wg    sync.WaitGroup
+	once  sync.Once
+	// overflows counts the tasks run outside the workers, atomic.
+	overflows int64
+	log       libs.Logger
+}
+
+func newWorkerPool(name string, workers int, log libs.Logger) *workerPool {
+	p := &workerPool{
+		name:  name,
+		tasks: make(chan func()),
+		quit:  make(chan struct{}),
+		log:   log,
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+func (p *workerPool) worker() {
+	defer p.wg.Done()
+	for {
+		select {
+		case task := <-p.tasks:
+			p.run(task)
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// run keeps the worker alive if the task panics, the tasks of the conns recover by themselves.
+func (p *workerPool) run(task func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.log.Error("task panics @ workerPool.run, pool: %s, err: %v, stack: %s", p.name, r, debug.Stack())
+		}
+	}()
+	task()
+}
+
+// submit runs the task on an idle worker, or on a goroutine of its own if none is idle or the pool is stopped.
+// It never blocks, a nil pool runs every task on its own goroutine.
+func (p *workerPool) submit(task func()) {
+	if p != nil {
+		select {
+		case p.tasks <- task:
+			return
+		default:
+		}
+		atomic.AddInt64(&p.overflows, 1)
+	}
+	go task()
+}
+
+// Overflows returns the tasks which found no idle worker, a steadily growing count means the pool is too small.
+func (p *workerPool) Overflows() int64 {
+	if p == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&p.overflows)
+}
+
+// stop waits for the workers to finish their tasks, the tasks submitted later run on their own goroutines.
+func (p *workerPool) stop() {
+	if p == nil {
+		return
+	}
+	p.once.Do(func() {
+		close(p.quit)
+		p.wg.Wait()
+	})
+}
+
+// schedule hands the send queue of the channel to the send pool unless a worker owns it already,
+// the owner writes the msgs in order, see drain.
+func (ch *Channel) schedule() {
+	if atomic.LoadInt32(&ch.conn.started) == 0 || !atomic.CompareAndSwapInt32(&ch.scheduled, 0, 1) {
+		return
+	}
+	ch.conn.sendPool.submit(ch.drain)
+}
+
+// drain writes up to sendBatch msgs of the send queue and gives it up, rescheduling it if more are left.
+// The msgs left by a stopped conn are resolved or flushed by Stop and FlushStop.
+func (ch *Channel) drain() {
+	for i := 0; i < sendBatch; i++ {
+		select {
+		case <-ch.conn.quit:
+			return
+		default:
+		}
+		select {
+		case msg := <-ch.sendQueue:
+			ch.writeQueued(msg)
+		default:
+			atomic.StoreInt32(&ch.scheduled, 0)
+			// a msg queued after the queue was seen empty but before it was given up is written by us
+			if len(ch.sendQueue) > 0 {
+				ch.schedule()
+			}
+			return
+		}
+	}
+	ch.conn.sendPool.submit(ch.drain)
+}
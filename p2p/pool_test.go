@@ -0,0 +1,255 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+const poolTestModule = Module("pooltest")
+
+// countReactor counts the msgs received, done is closed once want of them arrived.
+type countReactor struct {
+	n    int64
+	want int64
+	done chan struct{}
+}
+
+func (r *countReactor) HandleFunc(chID int32, msgBytes []byte) {
+	if atomic.AddInt64(&r.n, 1) == atomic.LoadInt64(&r.want) {
+		close(r.done)
+	}
+}
+
+func (r *countReactor) SetSwitch(sw libs.Switch)                      {}
+func (r *countReactor) AddPeer(peer libs.Peer)                        {}
+func (r *countReactor) RemovePeer(peer libs.Peer, reason interface{}) {}
+
+// orderReactor records the msgs in the order received, they're "<conn>/<channel>/<seq>".
+type orderReactor struct {
+	sync.Mutex
+	seqs map[string][]int
+}
+
+func (r *orderReactor) HandleFunc(chID int32, msgBytes []byte) {}
+
+func (r *orderReactor) HandleFuncFrom(peerID string, chID int32, msgBytes []byte) {
+	msg := string(msgBytes)
+	i := strings.LastIndex(msg, "/")
+	seq, _ := strconv.Atoi(msg[i+1:])
+	r.Lock()
+	r.seqs[msg[:i]] = append(r.seqs[msg[:i]], seq)
+	r.Unlock()
+}
+
+func (r *orderReactor) SetSwitch(sw libs.Switch)                      {}
+func (r *orderReactor) AddPeer(peer libs.Peer)                        {}
+func (r *orderReactor) RemovePeer(peer libs.Peer, reason interface{}) {}
+
+// connPairs opens n streams between two mocknet hosts and returns the started conns of their ends,
+// the conns have the channels 1 to chans, the received msgs go to r.
+func connPairs(tb testing.TB, n, chans int, sendPool, handlePool *workerPool, r libs.Reactor) (out, in []*DefaultConn) {
+	mn := mocknet.New(context.Background())
+	h1, err := mn.GenPeer()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	h2, err := mn.GenPeer()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if err := mn.LinkAll(); err != nil {
+		tb.Fatal(err)
+	}
+	if err := mn.ConnectAllButSelf(); err != nil {
+		tb.Fatal(err)
+	}
+	streams := make(chan network.Stream, n)
+	h2.SetStreamHandler(protocolID("pool"), func(s network.Stream) { streams <- s })
+
+	log := logs.NewLogger()
+	log.SetLevel(logs.LevelError)
+	channels := make(map[int32]Module)
+	for i := 1; i <= chans; i++ {
+		channels[int32(i)] = poolTestModule
+	}
+	newConn := func(id peer.ID, s network.Stream) *DefaultConn {
+		dc, err := NewDefaultConn(&DefaultNodeInfo{addr: &peer.AddrInfo{ID: id}}, s, map[Module]libs.Reactor{poolTestModule: r},
+			channels, nil, nil, nil, nil, nil, nil, 0, -1, envelope{}, nil, nil, sendPool, handlePool, log)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		dc.Start()
+		tb.Cleanup(dc.Stop)
+		return dc
+	}
+	for i := 0; i < n; i++ {
+		s, err := h1.NewStream(context.Background(), h2.ID(), protocolID("pool"))
+		if err != nil {
+			tb.Fatal(err)
+		}
+		// the stream is only announced to h2 once it's written
+		out = append(out, newConn(h2.ID(), s))
+		out[i].Ping()
+		select {
+		case s := <-streams:
+			in = append(in, newConn(h1.ID(), s))
+		case <-time.After(3 * time.Second):
+			tb.Fatal("want the stream accepted")
+		}
+	}
+	return out, in
+}
+
+func TestSendPoolOrder(t *testing.T) {
+	sendPool := newWorkerPool("send", 2, logs.NewLogger())
+	defer sendPool.stop()
+	r := &orderReactor{seqs: make(map[string][]int)}
+	out, _ := connPairs(t, 8, 2, sendPool, nil, r)
+
+	const msgs = 200
+	var wg sync.WaitGroup
+	for i, dc := range out {
+		for ch := int32(1); ch <= 2; ch++ {
+			wg.Add(1)
+			go func(i int, dc *DefaultConn, ch int32) {
+				defer wg.Done()
+				for seq := 0; seq < msgs; seq++ {
+					if !dc.Send(ch, []byte(fmt.Sprintf("%d/%d/%d", i, ch, seq))) {
+						t.Errorf("send fail, conn: %d, channel: %d", i, ch)
+					}
+				}
+			}(i, dc, ch)
+		}
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r.Lock()
+		complete := len(r.seqs) == len(out)*2
+		for _, seqs := range r.seqs {
+			complete = complete && len(seqs) == msgs
+		}
+		r.Unlock()
+		if complete {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("want all msgs received")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	r.Lock()
+	defer r.Unlock()
+	for key, seqs := range r.seqs {
+		for i, seq := range seqs {
+			if seq != i {
+				t.Fatalf("want the msgs of %s in order, has: %d at %d", key, seq, i)
+			}
+		}
+	}
+}
+
+func TestSendPoolGoroutines(t *testing.T) {
+	const conns, chans = 50, 4
+	goroutines := func(sendPool *workerPool) int {
+		before := runtime.NumGoroutine()
+		connPairs(t, conns, chans, sendPool, nil, &countReactor{done: make(chan struct{})})
+		return runtime.NumGoroutine() - before
+	}
+	sendPool := newWorkerPool("send", 4, logs.NewLogger())
+	defer sendPool.stop()
+
+	pooled, loops := goroutines(sendPool), goroutines(nil)
+	// either end of a conn runs a send loop for every channel without the pool
+	if loops-pooled < conns*chans {
+		t.Errorf("want the pool to save the send loops, goroutines with the pool: %d, without: %d", pooled, loops)
+	}
+}
+
+func TestWorkerPoolOverflow(t *testing.T) {
+	p := newWorkerPool("test", 1, logs.NewLogger())
+	defer p.stop()
+
+	block, done := make(chan struct{}), make(chan struct{}, 3)
+	task := func() {
+		<-block
+		done <- struct{}{}
+	}
+	// the worker may not be idle yet, the tasks never wait for it
+	for i := 0; i < 3; i++ {
+		p.submit(task)
+	}
+	if p.Overflows() < 2 {
+		t.Errorf("want the tasks overflowed while the worker is busy, overflows: %d", p.Overflows())
+	}
+	close(block)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-done:
+		case <-time.After(3 * time.Second):
+			t.Fatal("want all tasks done")
+		}
+	}
+	p.submit(func() { panic("test") })
+	p.stop()
+	// a stopped pool still runs the tasks
+	p.submit(task)
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("want the task done by the stopped pool")
+	}
+}
+
+// BenchmarkConnSend sends the msgs round robin over 512 conns, with the shared pools and with
+// the goroutines of every conn, the goroutines per conn are reported along with the throughput.
+func BenchmarkConnSend(b *testing.B) {
+	const conns, chans = 512, 4
+	for _, bc := range []struct {
+		name    string
+		workers int
+	}{
+		{"pooled", DefaultSendWorkers},
+		{"goroutines", 0},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			var sendPool, handlePool *workerPool
+			if bc.workers > 0 {
+				sendPool = newWorkerPool("send", bc.workers, logs.NewLogger())
+				handlePool = newWorkerPool("handle", DefaultHandleWorkers, logs.NewLogger())
+				defer sendPool.stop()
+				defer handlePool.stop()
+			}
+			before := runtime.NumGoroutine()
+			r := &countReactor{want: int64(b.N), done: make(chan struct{})}
+			out, _ := connPairs(b, conns, chans, sendPool, handlePool, r)
+			goroutines := runtime.NumGoroutine() - before
+			msg := make([]byte, 256)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				out[i%conns].Send(int32(i%chans)+1, msg)
+			}
+			select {
+			case <-r.done:
+			case <-time.After(time.Minute):
+				b.Fatalf("want all msgs received, has: %d of %d", atomic.LoadInt64(&r.n), b.N)
+			}
+			b.ReportMetric(float64(goroutines)/conns, "goroutines/conn")
+		})
+	}
+}
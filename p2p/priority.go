@@ -0,0 +1,139 @@
+package p2p
+
+import (
+	"fmt"
+	"time"
+)
+
+// PeerClass ranks the routed peers for the dials of the acceptRoutine, the peers of a class are
+// only dialed once the dials of the higher classes complete, so that the quorum is connected first.
+type PeerClass int
+
+const (
+	PeerClassValidator PeerClass = iota
+	PeerClassSentry
+	PeerClassOther
+)
+
+var peerClasses = []PeerClass{PeerClassValidator, PeerClassSentry, PeerClassOther}
+
+func (c PeerClass) String() string {
+	switch c {
+	case PeerClassValidator:
+		return "validator"
+	case PeerClassSentry:
+		return "sentry"
+	case PeerClassOther:
+		return "other"
+	}
+	return fmt.Sprintf("class(%d)", int(c))
+}
+
+// DialQuota bounds the outbound connections of every class dialed by the acceptRoutine,
+// 0 means unlimited. The inbound connections and the explicit dials aren't limited.
+type DialQuota struct {
+	Validators int
+	Sentries   int
+	Others     int
+}
+
+func (q DialQuota) of(c PeerClass) int {
+	switch c {
+	case PeerClassValidator:
+		return q.Validators
+	case PeerClassSentry:
+		return q.Sentries
+	}
+	return q.Others
+}
+
+// peerClasser classifies the peers by the current validators of all the channels and the configured sentries.
+type peerClasser struct {
+	validators map[string]bool
+	sentries   map[string]bool
+}
+
+func (sw *Switch) newPeerClasser() *peerClasser {
+	sw.mtx.Lock()
+	sets := make([]func() []string, 0, len(sw.validatorSets))
+	for _, set := range sw.validatorSets {
+		sets = append(sets, set)
+	}
+	sw.mtx.Unlock()
+
+	c := &peerClasser{validators: make(map[string]bool), sentries: make(map[string]bool)}
+	for _, set := range sets {
+		for _, v := range set() {
+			if p2pID, err := sw.GetP2PID(v); err == nil {
+				c.validators[p2pID] = true
+			}
+		}
+	}
+	for _, s := range sw.cfg.SentryPeers {
+		c.sentries[s] = true
+	}
+	return c
+}
+
+func (c *peerClasser) class(id PeerID) PeerClass {
+	switch {
+	case c.validators[id.Pretty()]:
+		return PeerClassValidator
+	case c.sentries[id.Pretty()]:
+		return PeerClassSentry
+	}
+	return PeerClassOther
+}
+
+// dialOrder groups the routed peers which aren't connected by the class,
+// the classes whose quota has been reached by the outbound connections are left empty,
+// the peers closed for the idleness are skipped within the idle timeout.
+func (sw *Switch) dialOrder(routed []PeerID) [][]PeerID {
+	c := sw.newPeerClasser()
+	outbound := make(map[PeerClass]int)
+	for _, p := range sw.peers.List() {
+		if p.Outbound() {
+			outbound[c.class(p.ID())]++
+		}
+	}
+
+	order := make([][]PeerID, len(peerClasses))
+	now := time.Now()
+	for _, id := range routed {
+		if _, err := sw.peers.Find(id); err == nil {
+			continue
+		}
+		class := c.class(id)
+		if class != PeerClassValidator && sw.recentlyIdle(id, now) {
+			continue
+		}
+		if quota := sw.cfg.DialQuota.of(class); quota > 0 && outbound[class]+len(order[class]) >= quota {
+			continue
+		}
+		order[class] = append(order[class], id)
+	}
+	return order
+}
+
+// dialRouted queues the dials of the routed peers class by class, a class waits until
+// no dial of the higher classes is pending.
+func (sw *Switch) dialRouted(routed []PeerID) {
+	for _, ids := range sw.dialOrder(routed) {
+		dialing := false
+		for _, id := range ids {
+			multiAddr := sw.routing.addr(id, sw.genPeerMultiID)
+			if multiAddr == "" {
+				continue
+			}
+			err := sw.dialer.Enqueue(multiAddr)
+			if err != nil && err != ErrDialPending {
+				sw.log.Warn("queue dial fail @ p2p.dialRouted, peer_id: %s, err: %v", id.Pretty(), err)
+				continue
+			}
+			dialing = true
+		}
+		if dialing {
+			return
+		}
+	}
+}
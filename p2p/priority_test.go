@@ -0,0 +1,45 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+func TestDialOrder(t *testing.T) {
+	validator, sentry, other := PeerID("validator"), PeerID("sentry"), PeerID("other")
+	sw := &Switch{
+		cfg:   &Config{SentryPeers: []string{sentry.Pretty()}},
+		peers: NewPeerSet(),
+	}
+	sw.SetValidatorSet(libs.ConsensusChannel, func() []string {
+		return []string{validator.Pretty(), PeerID("validator2").Pretty()}
+	})
+
+	order := sw.dialOrder([]PeerID{other, sentry, validator, "validator2"})
+	if len(order[PeerClassValidator]) != 2 || len(order[PeerClassSentry]) != 1 || len(order[PeerClassOther]) != 1 {
+		t.Fatalf("unexpected dial order: %v", order)
+	}
+	if order[PeerClassSentry][0] != sentry || order[PeerClassOther][0] != other {
+		t.Errorf("peers in the wrong class: %v", order)
+	}
+
+	// the connected peers are skipped and count against the quota of their class
+	sw.cfg.DialQuota = DialQuota{Validators: 2, Others: 1}
+	if err := sw.peers.Add(NewMockPeer(validator, true)); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.peers.Add(NewMockPeer("other2", false)); err != nil {
+		t.Fatal(err)
+	}
+	order = sw.dialOrder([]PeerID{other, "other3", sentry, validator, "validator2"})
+	if len(order[PeerClassValidator]) != 1 || order[PeerClassValidator][0] != "validator2" {
+		t.Errorf("the unconnected validator should be dialed, got: %v", order[PeerClassValidator])
+	}
+	if len(order[PeerClassOther]) != 1 {
+		t.Errorf("the inbound peers don't count against the quota, got: %v", order[PeerClassOther])
+	}
+	if len(order[PeerClassSentry]) != 1 {
+		t.Errorf("the sentries are unlimited, got: %v", order[PeerClassSentry])
+	}
+}
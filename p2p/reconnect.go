@@ -0,0 +1,134 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/network"
+)
+
+var (
+	// DefaultStreamRetry recreates a reset stream on the same connection quickly.
+	DefaultStreamRetry = RetryPolicy{MaxAttempts: 3, Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	// DefaultRedialRetry redials a lost connection with an exponential backoff.
+	DefaultRedialRetry = RetryPolicy{MaxAttempts: 5, Backoff: time.Second, MaxBackoff: 30 * time.Second}
+)
+
+// RetryPolicy retries at most MaxAttempts times, the backoff starts from Backoff
+// and doubles after every attempt up to MaxBackoff, a negative MaxAttempts disables the retry.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+}
+
+// backoff returns the wait before the attempt, which counts from 0.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 0; i < attempt; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return d
+}
+
+type reconnectKind int
+
+const (
+	// reconnectNone means the stream was closed on purpose by the remote peer,
+	// e.g. it lost the duplicate conn resolution or it's shutting down.
+	reconnectNone reconnectKind = iota
+	// reconnectStream recreates the stream on the living connection.
+	reconnectStream
+	// reconnectRedial dials the peer again since the connection is lost.
+	reconnectRedial
+)
+
+func (k reconnectKind) String() string {
+	switch k {
+	case reconnectStream:
+		return "stream"
+	case reconnectRedial:
+		return "redial"
+	}
+	return "none"
+}
+
+// classifyDisconnect decides how to reconnect after the stream fails with err,
+// connected reports whether the connection to the peer is still alive.
+// The peer of another chain is never reconnected.
+func classifyDisconnect(err error, connected bool) reconnectKind {
+	if err == io.EOF || errors.Is(err, ErrChainMismatch) {
+		return reconnectNone
+	}
+	if connected {
+		return reconnectStream
+	}
+	return reconnectRedial
+}
+
+// onDisconnect is invoked by the conn once its stream fails, the peer is removed
+// and the side which initiated the stream reconnects it by the matching policy.
+func (sw *Switch) onDisconnect(conn *DefaultConn, err error) {
+	id := conn.peer.ID()
+	p, ferr := sw.peers.Find(id)
+	if ferr != nil {
+		return
+	}
+	// the conn may have been replaced by a newer one to the same peer
+	if dp, ok := p.(*DefaultPeer); !ok || dp.conn != conn {
+		return
+	}
+	if !sw.peers.Remove(p) {
+		return
+	}
+	for _, r := range sw.reactors() {
+		r.RemovePeer(p, err)
+	}
+	sw.auditPeer(AuditDisconnect, p, err)
+
+	kind := classifyDisconnect(err, sw.host.Network().Connectedness(id) == network.Connected)
+	sw.log.Warn("peer disconnected @ p2p.onDisconnect, peer_id: %s, outbound: %v, reconnect: %s, err: %v",
+		id.Pretty(), p.Outbound(), kind, err)
+	if kind == reconnectNone || !p.Outbound() {
+		// the remote peer initiated the stream, it's up to it to reconnect
+		return
+	}
+
+	policy, dial := sw.cfg.StreamRetry, func(ctx context.Context) error { return sw.dialPeersAsync(ctx, id) }
+	if kind == reconnectRedial {
+		multiAddr := sw.genPeerMultiID(id)
+		if multiAddr == "" {
+			sw.log.Warn("no address to redial @ p2p.onDisconnect, peer_id: %s", id.Pretty())
+			return
+		}
+		policy, dial = sw.cfg.RedialRetry, func(ctx context.Context) error { return sw.connect(ctx, multiAddr) }
+	}
+	sw.routines.Spawn("reconnect/"+id.Pretty(), func() { sw.retry(id, kind, policy, dial) })
+}
+
+func (sw *Switch) retry(id PeerID, kind reconnectKind, policy RetryPolicy, dial func(ctx context.Context) error) {
+	for attempt := 0; attempt < policy.MaxAttempts; attempt++ {
+		select {
+		case <-sw.clock.After(policy.backoff(attempt)):
+		case <-sw.stopped:
+			return
+		}
+		if _, err := sw.peers.Find(id); err == nil {
+			// reconnected by the remote peer or the acceptRoutine
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), sw.dialer.timeout)
+		err := dial(ctx)
+		cancel()
+		if err == nil {
+			sw.log.Info("reconnect succ @ p2p.retry, peer_id: %s, reconnect: %s, attempt: %d", id.Pretty(), kind, attempt+1)
+			return
+		}
+		sw.log.Warn("reconnect fail @ p2p.retry, peer_id: %s, reconnect: %s, attempt: %d, err: %v", id.Pretty(), kind, attempt+1, err)
+	}
+}
@@ -0,0 +1,86 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if has := p.backoff(attempt); has != want {
+			t.Errorf("attempt %d, want: %v, has: %v", attempt, want, has)
+		}
+	}
+}
+
+func TestClassifyDisconnect(t *testing.T) {
+	reset := errors.New("stream reset")
+	cases := []struct {
+		err       error
+		connected bool
+		want      reconnectKind
+	}{
+		{io.EOF, true, reconnectNone},
+		{io.EOF, false, reconnectNone},
+		{reset, true, reconnectStream},
+		{reset, false, reconnectRedial},
+		{fmt.Errorf("%w, want chain: a", ErrChainMismatch), true, reconnectNone},
+	}
+	for _, c := range cases {
+		if has := classifyDisconnect(c.err, c.connected); has != c.want {
+			t.Errorf("err: %v, connected: %v, want: %s, has: %s", c.err, c.connected, c.want, has)
+		}
+	}
+}
+
+func TestSwitchRecreateResetStream(t *testing.T) {
+	mn := mocknet.New(context.Background())
+	r1, r2 := newRecvReactor(), newRecvReactor()
+	sw1 := newMockSwitch(t, mn, r1)
+	sw2 := newMockSwitch(t, mn, r2)
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+	addr := fmt.Sprintf("%s/p2p/%s", sw2.host.Addrs()[0], sw2.host.ID().Pretty())
+	if err := sw1.DialPeers([]string{addr}); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []*recvReactor{r1, r2} {
+		select {
+		case <-r.peers:
+		case <-time.After(3 * time.Second):
+			t.Fatal("peer should be added on both sides")
+		}
+	}
+
+	// reset the stream under the conn, the connection itself is still alive
+	p, err := sw2.peers.Find(sw1.host.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.(*DefaultPeer).conn.stream.Reset()
+
+	select {
+	case <-r1.peers:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the dialer should recreate the stream")
+	}
+	if err := sw1.Send(sw2.host.ID().Pretty(), 0, []byte("again")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-r2.recv:
+		if string(msg) != "again" {
+			t.Errorf("want: again, has: %s", msg)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("msg should be received on the recreated stream")
+	}
+}
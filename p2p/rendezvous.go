@@ -0,0 +1,188 @@
+package p2p
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/p2p/rendezvous"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+const (
+	defaultRendezvousRefreshPeriod = 10 * time.Second
+	// rendezvousRequestTimeout bounds every request to the registry.
+	rendezvousRequestTimeout = 5 * time.Second
+)
+
+// RendezvousConfig discovers the peers by a registry server instead of the kademlia dht, see p2p/rendezvous,
+// for the networks where the dht traffic is prohibited, the empty URL keeps the dht.
+type RendezvousConfig struct {
+	URL   string
+	Token string
+	// TTL is asked for the registration of the host, which is renewed every TTL/2, rendezvous.DefaultTTL by default.
+	TTL time.Duration
+	// RefreshPeriod is the period of fetching the registered peers, 10s by default.
+	RefreshPeriod time.Duration
+}
+
+// rendezvousRouter registers the host under its chain and routes the peers registered under it,
+// their addrs are added to the peerstore so that the acceptRoutine dials them like the routed ones of the dht.
+type rendezvousRouter struct {
+	cfg     RendezvousConfig
+	chainID string
+	host    host.Host
+	client  *rendezvous.Client
+
+	mtx   sync.Mutex
+	peers map[peer.ID]struct{}
+	// started is set once the refreshRoutine runs
+	started bool
+
+	once sync.Once
+	quit chan struct{}
+	done chan struct{}
+	log  libs.Logger
+}
+
+func newRendezvousRouter(h host.Host, cfg RendezvousConfig, chainID string, logger libs.Logger) *rendezvousRouter {
+	if cfg.TTL <= 0 {
+		cfg.TTL = rendezvous.DefaultTTL
+	}
+	if cfg.RefreshPeriod <= 0 {
+		cfg.RefreshPeriod = defaultRendezvousRefreshPeriod
+	}
+	return &rendezvousRouter{
+		cfg:     cfg,
+		chainID: chainID,
+		host:    h,
+		client:  rendezvous.NewClient(cfg.URL, cfg.Token, nil),
+		peers:   make(map[peer.ID]struct{}),
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+		log:     logger,
+	}
+}
+
+// Bootstrap registers the host and fetches the peers once, then keeps both up to date in the background,
+// an unreachable registry is retried on the next period instead of failing the start.
+func (r *rendezvousRouter) Bootstrap(ctx context.Context) error {
+	r.register()
+	r.discover()
+	r.mtx.Lock()
+	r.started = true
+	r.mtx.Unlock()
+	go r.refreshRoutine()
+	return nil
+}
+
+func (r *rendezvousRouter) refreshRoutine() {
+	defer close(r.done)
+	refresh := time.NewTicker(r.cfg.RefreshPeriod)
+	defer refresh.Stop()
+	renew := time.NewTicker(r.cfg.TTL / 2)
+	defer renew.Stop()
+	for {
+		select {
+		case <-refresh.C:
+			r.discover()
+		case <-renew.C:
+			r.register()
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+func (r *rendezvousRouter) register() {
+	var addrs []string
+	for _, addr := range r.host.Addrs() {
+		addrs = append(addrs, addr.String())
+	}
+	reg := rendezvous.Registration{ChainID: r.chainID, PeerID: r.host.ID().Pretty(), Addrs: addrs,
+		TTL: r.cfg.TTL.Milliseconds()}
+	ctx, cancel := context.WithTimeout(context.Background(), rendezvousRequestTimeout)
+	defer cancel()
+	if err := r.client.Register(ctx, reg); err != nil {
+		r.log.Warn("register fail @ p2p.rendezvous, url: %s, chain: %s, err: %v", r.cfg.URL, r.chainID, err)
+	}
+}
+
+// discover replaces the routed peers by the registered ones, the peers whose registrations have expired are dropped.
+func (r *rendezvousRouter) discover() {
+	ctx, cancel := context.WithTimeout(context.Background(), rendezvousRequestTimeout)
+	defer cancel()
+	regs, err := r.client.Discover(ctx, r.chainID, 0)
+	if err != nil {
+		r.log.Warn("discover fail @ p2p.rendezvous, url: %s, chain: %s, err: %v", r.cfg.URL, r.chainID, err)
+		return
+	}
+	peers := make(map[peer.ID]struct{}, len(regs))
+	for _, reg := range regs {
+		id, err := peer.Decode(reg.PeerID)
+		if err != nil || id == r.host.ID() {
+			continue
+		}
+		var addrs []multiaddr.Multiaddr
+		for _, s := range reg.Addrs {
+			if addr, err := multiaddr.NewMultiaddr(s); err == nil {
+				addrs = append(addrs, addr)
+			}
+		}
+		if len(addrs) == 0 {
+			continue
+		}
+		r.host.Peerstore().AddAddrs(id, addrs, r.cfg.TTL)
+		peers[id] = struct{}{}
+	}
+	r.mtx.Lock()
+	r.peers = peers
+	r.mtx.Unlock()
+}
+
+func (r *rendezvousRouter) ListPeers() []peer.ID {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	ids := make([]peer.ID, 0, len(r.peers))
+	for id := range r.peers {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// RemovePeer drops the peer until it's discovered again.
+func (r *rendezvousRouter) RemovePeer(id peer.ID) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	delete(r.peers, id)
+}
+
+// Close stops the refreshing and unregisters the host, so that the others stop dialing it at once.
+func (r *rendezvousRouter) Close() error {
+	closed := false
+	r.once.Do(func() {
+		close(r.quit)
+		closed = true
+	})
+	r.mtx.Lock()
+	started := r.started
+	r.mtx.Unlock()
+	if !closed || !started {
+		return nil
+	}
+	<-r.done
+	ctx, cancel := context.WithTimeout(context.Background(), rendezvousRequestTimeout)
+	defer cancel()
+	return r.client.Unregister(ctx, r.chainID, r.host.ID().Pretty())
+}
+
+// rendezvousChainID is the key the host registers under, the chain of the switch or its network.
+func (sw *Switch) rendezvousChainID() string {
+	if sw.cfg.ChainID != "" {
+		return sw.cfg.ChainID
+	}
+	return sw.cfg.NetworkID
+}
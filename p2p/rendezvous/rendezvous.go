@@ -0,0 +1,274 @@
+// Package rendezvous is a registry of the peers by the chain, an alternative to the kademlia dht
+// for the networks where the dht traffic is prohibited: every node registers its multiaddrs under its chain
+// and fetches the other nodes of the chain from it. The registrations expire unless they're renewed,
+// so that the stopped nodes leave the registry by themselves.
+package rendezvous
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+// the endpoints of the registry, see Server
+const (
+	pathRegister   = "/register"
+	pathUnregister = "/unregister"
+	pathDiscover   = "/discover"
+
+	// DefaultTTL is the ttl of the registrations which don't ask for one, MaxTTL bounds the asked ones.
+	DefaultTTL = 2 * time.Minute
+	MaxTTL     = time.Hour
+	// MaxPeers bounds the peers of a chain returned by a discovery and the registrations of a chain.
+	MaxPeers = 1000
+
+	maxRequestBytes = 64 << 10
+)
+
+var (
+	ErrUnauthorized = errors.New("rendezvous request unauthorized")
+	ErrChainFull    = errors.New("too many peers registered under the chain")
+	ErrInvalidPeer  = errors.New("registration lacks the chain id, the peer id or the addrs")
+)
+
+// Registration is a peer registered under a chain, Addrs are the multiaddrs of the peer without its id.
+type Registration struct {
+	ChainID string   `json:"chain_id"`
+	PeerID  string   `json:"peer_id"`
+	Addrs   []string `json:"addrs,omitempty"`
+	// TTL is asked by the peer in milliseconds, 0 uses DefaultTTL.
+	TTL int64 `json:"ttl,omitempty"`
+}
+
+type response struct {
+	Peers []Registration `json:"peers,omitempty"`
+	Error string         `json:"error,omitempty"`
+}
+
+type entry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// Server is the registry, it keeps the registrations in memory, a restarted registry is refilled
+// by the renewals of the peers within their ttl.
+type Server struct {
+	token string
+	clock libs.Clock
+
+	mtx    sync.Mutex
+	chains map[string]map[string]entry
+}
+
+// NewServer returns the handler of the registry, the requests must carry the token if it's set.
+func NewServer(token string, clock libs.Clock) *Server {
+	if clock == nil {
+		clock = libs.SystemClock
+	}
+	return &Server{token: token, clock: clock, chains: make(map[string]map[string]entry)}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		s.reply(w, http.StatusUnauthorized, response{Error: ErrUnauthorized.Error()})
+		return
+	}
+	switch {
+	case r.URL.Path == pathRegister && r.Method == http.MethodPost:
+		var reg Registration
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxRequestBytes)).Decode(&reg); err != nil {
+			s.reply(w, http.StatusBadRequest, response{Error: err.Error()})
+			return
+		}
+		if err := s.Register(reg); err != nil {
+			s.reply(w, http.StatusBadRequest, response{Error: err.Error()})
+			return
+		}
+		s.reply(w, http.StatusOK, response{})
+	case r.URL.Path == pathUnregister && r.Method == http.MethodPost:
+		var reg Registration
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxRequestBytes)).Decode(&reg); err != nil {
+			s.reply(w, http.StatusBadRequest, response{Error: err.Error()})
+			return
+		}
+		s.Unregister(reg.ChainID, reg.PeerID)
+		s.reply(w, http.StatusOK, response{})
+	case r.URL.Path == pathDiscover && r.Method == http.MethodGet:
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		s.reply(w, http.StatusOK, response{Peers: s.Discover(r.URL.Query().Get("chain_id"), limit)})
+	default:
+		s.reply(w, http.StatusNotFound, response{Error: "unknown endpoint " + r.Method + " " + r.URL.Path})
+	}
+}
+
+// Register adds or renews the peer under its chain.
+func (s *Server) Register(reg Registration) error {
+	if reg.ChainID == "" || reg.PeerID == "" || len(reg.Addrs) == 0 {
+		return ErrInvalidPeer
+	}
+	ttl := time.Duration(reg.TTL) * time.Millisecond
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if ttl > MaxTTL {
+		ttl = MaxTTL
+	}
+	now := s.clock.Now()
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	peers := s.prune(reg.ChainID, now)
+	if peers == nil {
+		peers = make(map[string]entry)
+		s.chains[reg.ChainID] = peers
+	}
+	if _, ok := peers[reg.PeerID]; !ok && len(peers) >= MaxPeers {
+		return fmt.Errorf("%w, chain: %s", ErrChainFull, reg.ChainID)
+	}
+	peers[reg.PeerID] = entry{addrs: reg.Addrs, expires: now.Add(ttl)}
+	return nil
+}
+
+// Unregister removes the peer from its chain at once, e.g. on its stop.
+func (s *Server) Unregister(chainID, peerID string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	delete(s.chains[chainID], peerID)
+	if len(s.chains[chainID]) == 0 {
+		delete(s.chains, chainID)
+	}
+}
+
+// Discover returns at most limit unexpired peers of the chain sorted by the id, limit <= 0 means MaxPeers.
+func (s *Server) Discover(chainID string, limit int) []Registration {
+	if limit <= 0 || limit > MaxPeers {
+		limit = MaxPeers
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	peers := s.prune(chainID, s.clock.Now())
+	regs := make([]Registration, 0, len(peers))
+	for id, e := range peers {
+		regs = append(regs, Registration{ChainID: chainID, PeerID: id, Addrs: e.addrs})
+	}
+	sort.Slice(regs, func(i, j int) bool { return regs[i].PeerID < regs[j].PeerID })
+	if len(regs) > limit {
+		regs = regs[:limit]
+	}
+	return regs
+}
+
+// prune drops the expired peers of the chain and returns the rest, it's called with the mtx held.
+func (s *Server) prune(chainID string, now time.Time) map[string]entry {
+	peers := s.chains[chainID]
+	for id, e := range peers {
+		if !now.Before(e.expires) {
+			delete(peers, id)
+		}
+	}
+	if peers != nil && len(peers) == 0 {
+		delete(s.chains, chainID)
+		return nil
+	}
+	return peers
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return true
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(s.token), []byte(token)) == 1
+}
+
+func (s *Server) reply(w http.ResponseWriter, status int, resp response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Client talks to the registry at the url over http(s).
+type Client struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewClient returns the client of the registry at url, token authorizes the requests if it's set.
+func NewClient(url, token string, client *http.Client) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Client{url: strings.TrimSuffix(url, "/"), token: token, client: client}
+}
+
+func (c *Client) Register(ctx context.Context, reg Registration) error {
+	_, err := c.post(ctx, pathRegister, reg)
+	return err
+}
+
+func (c *Client) Unregister(ctx context.Context, chainID, peerID string) error {
+	_, err := c.post(ctx, pathUnregister, Registration{ChainID: chainID, PeerID: peerID})
+	return err
+}
+
+// Discover fetches at most limit peers of the chain, limit <= 0 means all of them up to MaxPeers.
+func (c *Client) Discover(ctx context.Context, chainID string, limit int) ([]Registration, error) {
+	q := url.Values{"chain_id": {chainID}}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	resp, err := c.do(ctx, http.MethodGet, pathDiscover+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Peers, nil
+}
+
+func (c *Client) post(ctx context.Context, path string, reg Registration) (*response, error) {
+	body, err := json.Marshal(reg)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(ctx, http.MethodPost, path, body)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte) (*response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.url+path, r)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	httpResp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	var resp response
+	if err := json.NewDecoder(io.LimitReader(httpResp.Body, maxRequestBytes*MaxPeers)).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("decode rendezvous response fail, status: %d, err: %v", httpResp.StatusCode, err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rendezvous replies %d: %s", httpResp.StatusCode, resp.Error)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,58 @@
+package rendezvous
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+func TestRegistry(t *testing.T) {
+	clock := libs.NewManualClock(time.Unix(0, 0))
+	srv := httptest.NewServer(NewServer("secret", clock))
+	defer srv.Close()
+	ctx := context.Background()
+
+	if err := NewClient(srv.URL, "", nil).Register(ctx, Registration{ChainID: "c", PeerID: "a", Addrs: []string{"/ip4/127.0.0.1/tcp/1"}}); err == nil {
+		t.Error("want the request without the token refused")
+	}
+	c := NewClient(srv.URL, "secret", nil)
+	if err := c.Register(ctx, Registration{ChainID: "c", PeerID: "a"}); err == nil {
+		t.Error("want the registration without addrs refused")
+	}
+	for _, reg := range []Registration{
+		{ChainID: "c", PeerID: "b", Addrs: []string{"/ip4/127.0.0.1/tcp/2"}, TTL: time.Minute.Milliseconds()},
+		{ChainID: "c", PeerID: "a", Addrs: []string{"/ip4/127.0.0.1/tcp/1"}, TTL: time.Hour.Milliseconds()},
+		{ChainID: "other", PeerID: "c", Addrs: []string{"/ip4/127.0.0.1/tcp/3"}},
+	} {
+		if err := c.Register(ctx, reg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	peers, err := c.Discover(ctx, "c", 0)
+	if err != nil || len(peers) != 2 || peers[0].PeerID != "a" || peers[1].PeerID != "b" {
+		t.Fatalf("want the peers of the chain sorted, has: %+v, err: %v", peers, err)
+	}
+	if peers, _ := c.Discover(ctx, "c", 1); len(peers) != 1 {
+		t.Errorf("want the peers limited, has: %+v", peers)
+	}
+
+	// the registration expires unless it's renewed
+	clock.Advance(time.Minute)
+	if peers, _ := c.Discover(ctx, "c", 0); len(peers) != 1 || peers[0].PeerID != "a" {
+		t.Errorf("want the expired peer dropped, has: %+v", peers)
+	}
+	if err := c.Unregister(ctx, "c", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if peers, _ := c.Discover(ctx, "c", 0); len(peers) != 0 {
+		t.Errorf("want the unregistered peer dropped, has: %+v", peers)
+	}
+	// the default ttl applies to the other chain
+	clock.Advance(DefaultTTL)
+	if peers, _ := c.Discover(ctx, "other", 0); len(peers) != 0 {
+		t.Errorf("want the default ttl, has: %+v", peers)
+	}
+}
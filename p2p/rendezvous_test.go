@@ -0,0 +1,55 @@
+package p2p
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/p2p/rendezvous"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+func TestRendezvousRouter(t *testing.T) {
+	srv := httptest.NewServer(rendezvous.NewServer("", nil))
+	defer srv.Close()
+	mn := mocknet.New(context.Background())
+	cfg := RendezvousConfig{URL: srv.URL, TTL: time.Minute, RefreshPeriod: time.Hour}
+
+	var routers []*rendezvousRouter
+	for i := 0; i < 2; i++ {
+		h, err := mn.GenPeer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := newRendezvousRouter(h, cfg, "test", logs.NewLogger())
+		if err := r.Bootstrap(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		routers = append(routers, r)
+	}
+	a, b := routers[0], routers[1]
+	// a has registered before b, it finds b on the next refresh
+	if peers := b.ListPeers(); len(peers) != 1 || peers[0] != a.host.ID() {
+		t.Fatalf("want the registered peer routed, has: %v", peers)
+	}
+	a.discover()
+	peers := a.ListPeers()
+	if len(peers) != 1 || peers[0] != b.host.ID() {
+		t.Fatalf("want the registered peer routed, has: %v", peers)
+	}
+	if addrs := a.host.Peerstore().Addrs(b.host.ID()); len(addrs) == 0 {
+		t.Error("want the addrs of the routed peer in the peerstore")
+	}
+
+	// the stopped peer leaves the registry at once
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b.discover()
+	if peers := b.ListPeers(); len(peers) != 0 {
+		t.Errorf("want the unregistered peer dropped, has: %v", peers)
+	}
+	b.Close()
+}
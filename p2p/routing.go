@@ -0,0 +1,136 @@
+package p2p
+
+import (
+	"container/list"
+
+	"github.com/aucusaga/gohotstuff/p2p/netaddr"
+)
+
+// DefaultRoutedAddrCacheSize bounds the multiaddrs of the routed peers cached by the acceptRoutine.
+const DefaultRoutedAddrCacheSize = 1024
+
+// routingSnapshot is the routing table listed by the acceptRoutine on the last tick, the next listing
+// is diffed against it, so that the peerstore is only looked up for the added peers instead of
+// every routed peer on every tick. It's owned by the acceptRoutine.
+type routingSnapshot struct {
+	peers map[PeerID]struct{}
+	addrs *addrCache
+}
+
+func newRoutingSnapshot(size int) *routingSnapshot {
+	return &routingSnapshot{
+		peers: make(map[PeerID]struct{}),
+		addrs: newAddrCache(size),
+	}
+}
+
+// update takes the routed peers as the new snapshot and returns the peers added and removed since the last one,
+// the multiaddrs of the added peers are looked up and cached, the ones of the removed peers are evicted.
+func (s *routingSnapshot) update(routed []PeerID, lookup func(PeerID) string) (added []PeerID, removed []PeerID) {
+	kept := 0
+	for _, id := range routed {
+		if _, ok := s.peers[id]; ok {
+			kept++
+		} else {
+			added = append(added, id)
+		}
+	}
+	if len(added) == 0 && kept == len(s.peers) {
+		return nil, nil
+	}
+
+	peers := make(map[PeerID]struct{}, len(routed))
+	for _, id := range routed {
+		peers[id] = struct{}{}
+	}
+	for id := range s.peers {
+		if _, ok := peers[id]; !ok {
+			removed = append(removed, id)
+			s.addrs.remove(id)
+		}
+	}
+	for _, id := range added {
+		if addr := lookup(id); addr != "" {
+			s.addrs.push(id, addr)
+		}
+	}
+	s.peers = peers
+	return added, removed
+}
+
+// addr returns the cached multiaddr of the routed peer, the peers evicted by the size of the cache
+// or without an address on their last lookup are looked up again.
+func (s *routingSnapshot) addr(id PeerID, lookup func(PeerID) string) string {
+	if addr, ok := s.addrs.get(id); ok {
+		return addr
+	}
+	addr := lookup(id)
+	if addr != "" {
+		s.addrs.push(id, addr)
+	}
+	return addr
+}
+
+// forget evicts the multiaddr whose dial has failed, the peerstore may have learnt a newer one.
+func (s *routingSnapshot) forget(multiAddr string) {
+	info, err := netaddr.Parse(multiAddr)
+	if err != nil {
+		return
+	}
+	s.addrs.remove(info.ID)
+}
+
+// addrCache is a fixed-size LRU cache of the multiaddrs of the peers,
+// the least recently used one is evicted when it's full.
+type addrCache struct {
+	size  int
+	cache map[PeerID]*list.Element
+	list  *list.List
+}
+
+type addrEntry struct {
+	id   PeerID
+	addr string
+}
+
+func newAddrCache(size int) *addrCache {
+	if size <= 0 {
+		size = DefaultRoutedAddrCacheSize
+	}
+	return &addrCache{
+		size:  size,
+		cache: make(map[PeerID]*list.Element),
+		list:  list.New(),
+	}
+}
+
+func (c *addrCache) get(id PeerID) (string, bool) {
+	e, ok := c.cache[id]
+	if !ok {
+		return "", false
+	}
+	c.list.MoveToBack(e)
+	return e.Value.(*addrEntry).addr, true
+}
+
+func (c *addrCache) push(id PeerID, addr string) {
+	if e, ok := c.cache[id]; ok {
+		e.Value.(*addrEntry).addr = addr
+		c.list.MoveToBack(e)
+		return
+	}
+	if c.list.Len() >= c.size {
+		if front := c.list.Front(); front != nil {
+			delete(c.cache, front.Value.(*addrEntry).id)
+			c.list.Remove(front)
+		}
+	}
+	c.cache[id] = c.list.PushBack(&addrEntry{id: id, addr: addr})
+}
+
+func (c *addrCache) remove(id PeerID) {
+	if e, ok := c.cache[id]; ok {
+		delete(c.cache, id)
+		c.list.Remove(e)
+	}
+}
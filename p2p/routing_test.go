@@ -0,0 +1,77 @@
+package p2p
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+func TestRoutingSnapshotUpdate(t *testing.T) {
+	a, _ := peer.Decode("Qmf2HeHe4sspGkfRCTq6257Vm3UHzvh2TeQJHHvHzzuFw6")
+	lookups := map[PeerID]int{}
+	lookup := func(id PeerID) string {
+		lookups[id]++
+		if id == "c" {
+			return ""
+		}
+		return "/ip4/127.0.0.1/tcp/30001/p2p/" + id.Pretty()
+	}
+	s := newRoutingSnapshot(0)
+
+	added, removed := s.update([]PeerID{a, "b", "c"}, lookup)
+	if fmt.Sprint(added) != fmt.Sprint([]PeerID{a, "b", "c"}) || len(removed) != 0 {
+		t.Fatalf("want all added, has added: %v, removed: %v", added, removed)
+	}
+	added, removed = s.update([]PeerID{"c", "b", a}, lookup)
+	if len(added)+len(removed) != 0 {
+		t.Errorf("the same table should be unchanged, has added: %v, removed: %v", added, removed)
+	}
+	added, removed = s.update([]PeerID{a, "d"}, lookup)
+	if fmt.Sprint(added) != fmt.Sprint([]PeerID{"d"}) || len(removed) != 2 {
+		t.Errorf("want d added, b and c removed, has added: %v, removed: %v", added, removed)
+	}
+
+	for i := 0; i < 3; i++ {
+		for _, id := range []PeerID{a, "d"} {
+			if addr := s.addr(id, lookup); addr != lookup(id) {
+				t.Errorf("want the addr of %s, has: %s", id, addr)
+			}
+			lookups[id]--
+		}
+	}
+	if lookups[a] != 1 || lookups["d"] != 1 {
+		t.Errorf("the routed peers should be looked up once, has: %d, %d", lookups[a], lookups["d"])
+	}
+
+	// the peer without an address is looked up again
+	s.update([]PeerID{a, "c"}, lookup)
+	s.addr("c", lookup)
+	if lookups["c"] != 3 {
+		t.Errorf("want c looked up on every miss, has: %d", lookups["c"])
+	}
+
+	// the failed dial evicts the addr
+	s.forget(lookup(a))
+	lookups[a]--
+	s.addr(a, lookup)
+	if lookups[a] != 2 {
+		t.Errorf("want a looked up again after the failed dial, has: %d", lookups[a])
+	}
+}
+
+func TestAddrCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newAddrCache(2)
+	c.push("a", "1")
+	c.push("b", "2")
+	c.get("a")
+	c.push("c", "3")
+	if _, ok := c.get("b"); ok {
+		t.Errorf("b should be evicted")
+	}
+	for id, want := range map[PeerID]string{"a": "1", "c": "3"} {
+		if addr, ok := c.get(id); !ok || addr != want {
+			t.Errorf("want %s of %s, has: %s, %v", want, id, addr, ok)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/aucusaga/gohotstuff/metrics"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+const (
+	// ChannelViolationPenalty is the score lost by a peer for a msg on a channel it isn't authorized for.
+	ChannelViolationPenalty = 1
+	// MinPeerScore is the score at which a peer is stopped, its score starts over once it reconnects.
+	MinPeerScore = -100
+)
+
+var ErrPeerScore = errors.New("peer score is too low")
+
+// peerScores are the scores of the peers, which start at 0 and are lowered by their violations.
+type peerScores struct {
+	mtx sync.Mutex
+	m   map[PeerID]int
+}
+
+// penalize lowers the score of the peer for the violation, the peer is stopped once it reaches MinPeerScore.
+func (sw *Switch) penalize(id PeerID, penalty int, reason error) {
+	sw.scores.mtx.Lock()
+	if sw.scores.m == nil {
+		sw.scores.m = make(map[PeerID]int)
+	}
+	sw.scores.m[id] -= penalty
+	score := sw.scores.m[id]
+	if score <= MinPeerScore {
+		delete(sw.scores.m, id)
+	}
+	sw.scores.mtx.Unlock()
+
+	metrics.DefaultRegistry.NewCounter("gohotstuff_p2p_peer_violations_total",
+		"Violations of the peers lowering their scores.").Inc()
+	sw.log.Warn("peer violation @ p2p.penalize, peer_id: %s, score: %d, err: %v", id.Pretty(), score, reason)
+	if score <= MinPeerScore {
+		sw.stopPeerForError(id, fmt.Errorf("%w, score: %d, err: %v", ErrPeerScore, score, reason))
+	}
+}
+
+// PeerScore returns the score of the peer, 0 if it has no violation.
+func (sw *Switch) PeerScore(pr string) int {
+	id, err := peer.Decode(pr)
+	if err != nil {
+		return 0
+	}
+	sw.scores.mtx.Lock()
+	defer sw.scores.mtx.Unlock()
+	return sw.scores.m[id]
+}
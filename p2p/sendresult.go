@@ -0,0 +1,53 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// DefaultSendResultTimeout is used when Config.SendResultTimeout is not set.
+const DefaultSendResultTimeout = 10 * time.Second
+
+var (
+	ErrSendQueueFull = errors.New("send queue of the peer is full")
+	ErrMsgExpired    = errors.New("msg expired in the send queue")
+	ErrConnStopped   = errors.New("conn stopped before the msg was written")
+	ErrSendTimeout   = errors.New("msg isn't written in time")
+)
+
+// SendWithResult queues the msg for the peer like Send, the returned chan receives nil once the msg
+// is written to the stream of the peer, or the error if it fails to be queued, expires in the queue,
+// the conn stops, or it isn't written in Config.SendResultTimeout. The chan is resolved exactly once,
+// so that the critical msgs, e.g. the sync responses, can be retried by the caller.
+func (sw *Switch) SendWithResult(pr string, chID int32, msgBytes []byte) <-chan error {
+	result := make(chan error, 1)
+	id, err := peer.Decode(pr)
+	if err != nil {
+		result <- fmt.Errorf("invalid peer id %s, err: %v", pr, err)
+		return result
+	}
+	p, err := sw.peers.Find(id)
+	if err != nil {
+		result <- fmt.Errorf("%v, peer_id: %s", err, pr)
+		return result
+	}
+	written := p.SendWithResult(chID, msgBytes)
+	timeout := sw.cfg.SendResultTimeout
+	if timeout <= 0 {
+		timeout = DefaultSendResultTimeout
+	}
+	go func() {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case err := <-written:
+			result <- err
+		case <-timer.C:
+			result <- fmt.Errorf("%w, timeout: %v, peer_id: %s, channel: %d", ErrSendTimeout, timeout, pr, chID)
+		}
+	}()
+	return result
+}
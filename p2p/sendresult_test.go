@@ -0,0 +1,66 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+func TestSwitchSendWithResult(t *testing.T) {
+	mn := mocknet.New(context.Background())
+	r1, r2 := newRecvReactor(), newRecvReactor()
+	sw1 := newMockSwitch(t, mn, r1)
+	sw2 := newMockSwitch(t, mn, r2)
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+	addr := fmt.Sprintf("%s/p2p/%s", sw2.host.Addrs()[0], sw2.host.ID().Pretty())
+	if err := sw1.DialPeers([]string{addr}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-r1.peers:
+	case <-time.After(3 * time.Second):
+		t.Fatal("peer should be added")
+	}
+	result := func(ch <-chan error) error {
+		select {
+		case err := <-ch:
+			return err
+		case <-time.After(3 * time.Second):
+			t.Fatal("the result should be resolved")
+		}
+		return nil
+	}
+	pr := sw2.host.ID().Pretty()
+
+	if err := result(sw1.SendWithResult(pr, libs.ConsensusChannel, []byte("hotstuff"))); err != nil {
+		t.Fatalf("want the msg written, has: %v", err)
+	}
+	select {
+	case msg := <-r2.recv:
+		if string(msg) != "hotstuff" {
+			t.Errorf("want: hotstuff, has: %s", msg)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("msg should be received")
+	}
+
+	sw1.SetMessageExpiry(libs.ConsensusChannel, func([]byte) bool { return true })
+	if err := result(sw1.SendWithResult(pr, libs.ConsensusChannel, []byte("stale"))); !errors.Is(err, ErrMsgExpired) {
+		t.Errorf("want: %v, has: %v", ErrMsgExpired, err)
+	}
+
+	sw1.StopPeerForError(pr, "test")
+	if err := result(sw1.SendWithResult(pr, libs.ConsensusChannel, []byte("hotstuff"))); err == nil {
+		t.Error("want the send to the stopped peer failed")
+	}
+	if err := result(sw1.SendWithResult("invalid", libs.ConsensusChannel, []byte("hotstuff"))); err == nil {
+		t.Error("want the send to the invalid peer failed")
+	}
+}
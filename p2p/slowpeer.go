@@ -0,0 +1,170 @@
+package p2p
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/metrics"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+const (
+	DefaultSlowPeerSamples = 8
+	// MinChunkSize is the smallest chunk the msgs to a slow peer are split into.
+	MinChunkSize = 4 << 10
+)
+
+// SlowPeerConfig detects the peers which consistently lag behind the msgs queued for them, i.e. their writes
+// wait in the send queues longer than Threshold. The msgs to them are split into smaller chunks so that
+// the other channels aren't stuck behind a large msg, and the msgs which the channels let them pull,
+// see SetPullOnSlow, are no longer pushed to them.
+type SlowPeerConfig struct {
+	// Threshold is the queueing delay of a lagging write, 0 disables the detection.
+	Threshold time.Duration
+	// Samples is the number of the consecutive lagging writes marking a peer slow,
+	// and of the timely ones marking it recovered, DefaultSlowPeerSamples by default.
+	Samples int
+	// ChunkSize splits the msgs to the peers which have negotiated libs.FeatureChunkedPayload, 0 sends every msg
+	// in a frame. It's halved on every lagging write of a slow peer down to MinChunkSize and restored on recovery.
+	ChunkSize int
+}
+
+// lagTracker watches the writes of a conn, it's owned by the conn and reports the transitions to the switch.
+type lagTracker struct {
+	cfg SlowPeerConfig
+	// chunked is set if the peer reassembles the chunks
+	chunked  bool
+	onChange func(slow bool)
+
+	mtx     sync.Mutex
+	lagging int
+	timely  int
+	slow    bool
+	chunk   int
+}
+
+// newLagTracker returns the tracker of the peer, nil if neither the detection nor the chunking is enabled.
+func (sw *Switch) newLagTracker(id PeerID, features libs.Features) *lagTracker {
+	cfg := sw.cfg.SlowPeer
+	chunked := cfg.ChunkSize > 0 && features.Has(libs.FeatureChunkedPayload)
+	if cfg.Threshold <= 0 && !chunked {
+		return nil
+	}
+	if cfg.Samples <= 0 {
+		cfg.Samples = DefaultSlowPeerSamples
+	}
+	if cfg.ChunkSize > 0 && cfg.ChunkSize < MinChunkSize {
+		cfg.ChunkSize = MinChunkSize
+	}
+	return &lagTracker{
+		cfg:      cfg,
+		chunked:  chunked,
+		chunk:    cfg.ChunkSize,
+		onChange: func(slow bool) { sw.setSlowPeer(id, slow) },
+	}
+}
+
+// observe takes the queueing delay of a write, the transitions are reported outside of the lock.
+func (t *lagTracker) observe(delay time.Duration) {
+	if t == nil || t.cfg.Threshold <= 0 {
+		return
+	}
+	t.mtx.Lock()
+	changed := false
+	if delay > t.cfg.Threshold {
+		t.lagging, t.timely = t.lagging+1, 0
+		if !t.slow && t.lagging >= t.cfg.Samples {
+			t.slow, changed = true, true
+		}
+		if t.slow && t.chunk/2 >= MinChunkSize {
+			t.chunk /= 2
+		}
+	} else {
+		t.lagging, t.timely = 0, t.timely+1
+		if t.slow && t.timely >= t.cfg.Samples {
+			t.slow, changed = false, true
+			t.chunk = t.cfg.ChunkSize
+		}
+	}
+	slow := t.slow
+	t.mtx.Unlock()
+
+	if changed && t.onChange != nil {
+		t.onChange(slow)
+	}
+}
+
+// chunkSize is the size of the chunks the msgs are split into, 0 sends every msg in a frame.
+func (t *lagTracker) chunkSize() int {
+	if t == nil || !t.chunked {
+		return 0
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.chunk
+}
+
+// setSlowPeer records the transition of the peer reported by its lagTracker.
+func (sw *Switch) setSlowPeer(id PeerID, slow bool) {
+	sw.mtx.Lock()
+	if sw.slowPeers == nil {
+		sw.slowPeers = make(map[PeerID]bool)
+	}
+	if slow {
+		sw.slowPeers[id] = true
+	} else {
+		delete(sw.slowPeers, id)
+	}
+	n := len(sw.slowPeers)
+	sw.mtx.Unlock()
+
+	metrics.DefaultRegistry.NewGauge("gohotstuff_p2p_slow_peers", "Peers lagging behind the msgs queued for them.").
+		Set(float64(n))
+	if slow {
+		sw.log.Warn("peer is slow @ p2p.setSlowPeer, peer_id: %s, threshold: %v", id.Pretty(), sw.cfg.SlowPeer.Threshold)
+	} else {
+		sw.log.Info("peer has recovered @ p2p.setSlowPeer, peer_id: %s", id.Pretty())
+	}
+}
+
+// IsSlowPeer reports whether the connected peer is lagging, see SlowPeerConfig.
+func (sw *Switch) IsSlowPeer(pr string) bool {
+	id, err := peer.Decode(pr)
+	if err != nil {
+		return false
+	}
+	sw.mtx.Lock()
+	defer sw.mtx.Unlock()
+	return sw.slowPeers[id]
+}
+
+// SetPullOnSlow registers the func reporting the msgs of the channel which the slow peers pull instead
+// of being pushed, e.g. the proposals fetched by their ids, they are skipped by the broadcasts to the slow peers.
+// It's invoked once per broadcast, so it must not block.
+func (sw *Switch) SetPullOnSlow(chID int32, pull func(msgBytes []byte) bool) {
+	sw.mtx.Lock()
+	defer sw.mtx.Unlock()
+
+	if sw.pullOnSlow == nil {
+		sw.pullOnSlow = make(map[int32]func([]byte) bool)
+	}
+	sw.pullOnSlow[chID] = pull
+}
+
+// skipSlow returns the slow peers the msg of the channel isn't pushed to among the targets, none if more than
+// a third of them are slow, so that the quorum of the validators is never left to the pulls.
+func (sw *Switch) skipSlow(chID int32, msgBytes []byte, targets int) map[PeerID]bool {
+	sw.mtx.Lock()
+	pull := sw.pullOnSlow[chID]
+	slow := make(map[PeerID]bool, len(sw.slowPeers))
+	for id := range sw.slowPeers {
+		slow[id] = true
+	}
+	sw.mtx.Unlock()
+
+	if pull == nil || len(slow) == 0 || len(slow)*3 >= targets || !pull(msgBytes) {
+		return nil
+	}
+	return slow
+}
@@ -0,0 +1,149 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+func TestLagTracker(t *testing.T) {
+	sw := &Switch{cfg: &Config{SlowPeer: SlowPeerConfig{Threshold: 10 * time.Millisecond, Samples: 2, ChunkSize: 4 * MinChunkSize}},
+		log: logs.NewLogger()}
+	id := PeerID("lagging")
+	isSlow := func() bool {
+		sw.mtx.Lock()
+		defer sw.mtx.Unlock()
+		return sw.slowPeers[id]
+	}
+	if sw.newLagTracker(id, 0).chunkSize() != 0 {
+		t.Errorf("the peers without chunked_payload get the msgs in a frame")
+	}
+	lag := sw.newLagTracker(id, libs.FeatureChunkedPayload)
+	if lag.chunkSize() != 4*MinChunkSize {
+		t.Fatalf("want chunk size: %d, has: %d", 4*MinChunkSize, lag.chunkSize())
+	}
+
+	lag.observe(time.Second)
+	if isSlow() {
+		t.Fatal("a lagging write shouldn't mark the peer slow")
+	}
+	lag.observe(time.Second)
+	if !isSlow() {
+		t.Fatal("consecutive lagging writes should mark the peer slow")
+	}
+	for i := 0; i < 4; i++ {
+		lag.observe(time.Second)
+	}
+	if lag.chunkSize() != MinChunkSize {
+		t.Errorf("the chunks of a slow peer should shrink down to %d, has: %d", MinChunkSize, lag.chunkSize())
+	}
+
+	lag.observe(time.Millisecond)
+	lag.observe(time.Second)
+	lag.observe(time.Millisecond)
+	if !isSlow() {
+		t.Fatal("interleaved timely writes shouldn't recover the peer")
+	}
+	lag.observe(time.Millisecond)
+	if isSlow() || lag.chunkSize() != 4*MinChunkSize {
+		t.Errorf("consecutive timely writes should recover the peer and its chunk size, has: %d", lag.chunkSize())
+	}
+
+	// neither the detection nor the chunking is enabled
+	sw.cfg.SlowPeer = SlowPeerConfig{}
+	if sw.newLagTracker(id, libs.FeatureChunkedPayload) != nil {
+		t.Errorf("want no tracker")
+	}
+}
+
+func TestBroadcastSkipsSlowPeers(t *testing.T) {
+	sw := &Switch{cfg: &Config{}, peers: NewPeerSet(), log: logs.NewLogger()}
+	var peers []*MockPeer
+	for i := 0; i < 4; i++ {
+		p := NewMockPeer(PeerID(fmt.Sprintf("validator%d", i)), true)
+		if err := sw.peers.Add(p); err != nil {
+			t.Fatal(err)
+		}
+		peers = append(peers, p)
+	}
+	slow := peers[0]
+	sw.setSlowPeer(slow.ID(), true)
+	sw.SetPullOnSlow(libs.ConsensusChannel, func(msg []byte) bool { return bytes.HasPrefix(msg, []byte("proposal")) })
+
+	waitSent := func(p *MockPeer, n int) bool {
+		for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			if len(p.Sent(libs.ConsensusChannel)) >= n {
+				return true
+			}
+		}
+		return false
+	}
+
+	sw.BroadcastToValidators(libs.ConsensusChannel, []byte("proposal"))
+	for _, p := range peers[1:] {
+		if !waitSent(p, 1) {
+			t.Fatalf("the proposal should reach %s", p.PeerID())
+		}
+	}
+	if len(slow.Sent(libs.ConsensusChannel)) != 0 {
+		t.Errorf("the slow peer should pull the proposal")
+	}
+	sw.BroadcastToValidators(libs.ConsensusChannel, []byte("vote"))
+	if !waitSent(slow, 1) {
+		t.Errorf("the msgs which aren't pulled should reach the slow peer")
+	}
+
+	// a third of the validators are slow, the quorum can't be left to the pulls
+	sw.setSlowPeer(peers[1].ID(), true)
+	sw.BroadcastToValidators(libs.ConsensusChannel, []byte("proposal"))
+	if !waitSent(slow, 2) || !waitSent(peers[1], 2) {
+		t.Errorf("the proposal should be pushed to every validator")
+	}
+}
+
+func TestChunkedPayload(t *testing.T) {
+	mn := mocknet.New(context.Background())
+	r1, r2 := newRecvReactor(), newRecvReactor()
+	sw1 := newMockSwitch(t, mn, r1)
+	sw2 := newMockSwitch(t, mn, r2)
+	for _, sw := range []*Switch{sw1, sw2} {
+		sw.cfg.Features = libs.FeatureChunkedPayload
+		sw.cfg.SlowPeer = SlowPeerConfig{ChunkSize: MinChunkSize}
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	addr := fmt.Sprintf("%s/p2p/%s", sw2.host.Addrs()[0], sw2.host.ID().Pretty())
+	if err := sw1.DialPeers([]string{addr}); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []*recvReactor{r1, r2} {
+		select {
+		case <-r.peers:
+		case <-time.After(3 * time.Second):
+			t.Fatal("peer should be added on both sides")
+		}
+	}
+
+	large := bytes.Repeat([]byte("hotstuff"), 3*MinChunkSize/8+1)
+	for _, msg := range [][]byte{large, []byte("small"), large} {
+		if err := sw1.Send(sw2.host.ID().Pretty(), libs.ConsensusChannel, msg); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case has := <-r2.recv:
+			if !bytes.Equal(has, msg) {
+				t.Errorf("want the reassembled msg of %d bytes, has %d bytes", len(msg), len(has))
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("msg should be received")
+		}
+	}
+}
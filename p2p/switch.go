@@ -2,22 +2,24 @@ package p2p
 
 import (
 	"context"
-	"encoding/base64"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/astaxie/beego/logs"
 	"github.com/aucusaga/gohotstuff/libs"
-	ipfsaddr "github.com/ipfs/go-ipfs-addr"
+	"github.com/aucusaga/gohotstuff/libs/service"
+	"github.com/aucusaga/gohotstuff/metrics"
+	"github.com/aucusaga/gohotstuff/p2p/netaddr"
+	"github.com/aucusaga/gohotstuff/p2p/tap"
 	"github.com/libp2p/go-libp2p"
 	circuit "github.com/libp2p/go-libp2p-circuit"
 	"github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
-	"github.com/libp2p/go-libp2p-core/protocol"
-	dht "github.com/libp2p/go-libp2p-kad-dht"
 	secio "github.com/libp2p/go-libp2p-secio"
 	"github.com/multiformats/go-multiaddr"
 )
@@ -30,23 +32,70 @@ var (
 	defaultTickerTimeSec = 4
 )
 
+var (
+	ErrDuplicateConn = errors.New("duplicate connection to the same peer")
+)
+
 type Switch struct {
 	cfg *Config
 
 	id    *multiaddr.Multiaddr
 	host  host.Host
-	kdht  *dht.IpfsDHT
+	kdht  Router
 	peers *PeerSet
-	timer *time.Ticker
-	quit  chan struct{}
-
-	reactor map[Module]libs.Reactor
-	mtx     sync.Mutex
-
-	log libs.Logger
+	book  *AddrBook
+	// dialer runs the outbound dials off the acceptRoutine
+	dialer *dialQueue
+	// routing is the routing table of the last tick of the acceptRoutine
+	routing *routingSnapshot
+	// addMtx serializes the duplicate conn resolution in addPeer
+	addMtx sync.Mutex
+	timer  libs.Ticker
+	quit   chan struct{}
+	// stopped is closed once the switch stops
+	stopped chan struct{}
+	// routines are the acceptRoutine and the reconnects, see Wait
+	routines service.Supervisor
+
+	reactor  map[Module]libs.Reactor
+	channels map[int32]Module
+	mtx      sync.Mutex
+	// the current validators of the channels, see BroadcastToValidators
+	validatorSets map[int32]func() []string
+	// the staleness of the queued msgs of the channels, see SetMessageExpiry
+	expiries map[int32]func([]byte) bool
+	// the msgs the slow peers pull instead of being pushed, see SetPullOnSlow
+	pullOnSlow map[int32]func([]byte) bool
+	// the peers lagging behind their send queues, see SlowPeerConfig
+	slowPeers map[PeerID]bool
+	// the peers whose streams have been closed for the idleness, see Config.IdleTimeout
+	idle map[PeerID]time.Time
+	// scores are lowered by the violations of the peers, see PeerScore
+	scores peerScores
+
+	newHost   HostFactory
+	newRouter RouterFactory
+	clock     libs.Clock
+	// gater applies the policy of the embedding application, nil allows every peer
+	gater ConnectionGater
+
+	eventBus libs.EventBus
+	// tap captures the msgs of all peers when Config.TapPath is set
+	tap *tap.Writer
+	// audit records the connects, the disconnects and the bans of the peers
+	audit *auditLog
+	// redact rewrites the payloads before they're logged by msgs, see WithRedactor
+	redact Redactor
+	msgs   *MsgLogger
+	// traffic counts the bytes of all conns by the module, see NetInfo
+	traffic *trafficMeter
+	// sendPool and handlePool run the sends and the msg dispatches of all conns, see Config.SendWorkers
+	sendPool   *workerPool
+	handlePool *workerPool
+	log        libs.Logger
 }
 
-func NewSwitch(cfg *Config, logger libs.Logger) (*Switch, error) {
+func NewSwitch(cfg *Config, logger libs.Logger, opts ...SwitchOption) (*Switch, error) {
 	if cfg.TickerTimeSec == 0 {
 		cfg.TickerTimeSec = int64(defaultTickerTimeSec)
 	}
@@ -54,13 +103,78 @@ func NewSwitch(cfg *Config, logger libs.Logger) (*Switch, error) {
 		logger = logs.NewLogger()
 	}
 	sw := &Switch{
-		quit:    make(chan struct{}),
-		cfg:     cfg,
-		peers:   NewPeerSet(),
-		timer:   time.NewTicker(time.Duration(cfg.TickerTimeSec) * time.Second),
-		reactor: make(map[Module]libs.Reactor),
-		log:     logger,
+		quit:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+		cfg:       cfg,
+		peers:     NewPeerSet(),
+		book:      NewAddrBook(),
+		reactor:   make(map[Module]libs.Reactor),
+		channels:  make(map[int32]Module),
+		newHost:   defaultHostFactory,
+		newRouter: defaultRouterFactory,
+		clock:     libs.SystemClock,
+		traffic:   newTrafficMeter(nil, metrics.DefaultRegistry),
+		routing:   newRoutingSnapshot(DefaultRoutedAddrCacheSize),
+		log:       logger,
+	}
+	sw.routines.SetLogger(logger)
+	for _, opt := range opts {
+		opt(sw)
+	}
+	msgs, err := NewMsgLogger(cfg.MsgLog, sw.redact, logger)
+	if err != nil {
+		return nil, fmt.Errorf("invalid msg log config @ p2p.NewSwitch, err: %w", err)
+	}
+	sw.msgs = msgs
+	sw.timer = sw.clock.NewTicker(time.Duration(cfg.TickerTimeSec) * time.Second)
+	for _, m := range libs.Modules() {
+		r := m.Channels()
+		for id := r.First; id <= r.Last; id++ {
+			sw.channels[id] = Module(m.String())
+		}
+	}
+	if cfg.StreamRetry == (RetryPolicy{}) {
+		cfg.StreamRetry = DefaultStreamRetry
+	}
+	if cfg.RedialRetry == (RetryPolicy{}) {
+		cfg.RedialRetry = DefaultRedialRetry
 	}
+	if cfg.FrameTimeout == 0 {
+		cfg.FrameTimeout = DefaultFrameTimeout
+	}
+	if cfg.SendWorkers == 0 {
+		cfg.SendWorkers = DefaultSendWorkers
+	}
+	if cfg.HandleWorkers == 0 {
+		cfg.HandleWorkers = DefaultHandleWorkers
+	}
+	sw.dialer = newDialQueue(sw.connect, cfg.DialConcurrency, cfg.DialTimeout)
+	if err := normalizeAddrs(cfg); err != nil {
+		return nil, fmt.Errorf("invalid peer addr @ p2p.NewSwitch, err: %w", err)
+	}
+	for _, addr := range cfg.PinnedPeers {
+		if err := sw.book.PinMultiAddr(addr); err != nil {
+			return nil, fmt.Errorf("pin peer fail @ p2p.NewSwitch, addr: %s, err: %v", addr, err)
+		}
+	}
+	for _, addr := range cfg.BootStrap {
+		if err := sw.book.PinMultiAddr(addr); err != nil {
+			sw.log.Warn("pin bootstrap peer fail @ p2p.NewSwitch, addr: %s, err: %v", addr, err)
+		}
+	}
+	if cfg.TapPath != "" {
+		w, err := tap.NewWriter(cfg.TapPath, cfg.TapMaxFileSize, cfg.TapMaxFiles)
+		if err != nil {
+			return nil, fmt.Errorf("open tap fail @ p2p.NewSwitch, path: %s, err: %v", cfg.TapPath, err)
+		}
+		sw.tap = w
+		sw.log.Warn("msg tap is enabled, all msgs are captured @ p2p.NewSwitch, path: %s", cfg.TapPath)
+	}
+	audit, err := openAuditLog(cfg.AuditPath, cfg.AuditRecent)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log fail @ p2p.NewSwitch, path: %s, err: %v", cfg.AuditPath, err)
+	}
+	sw.audit = audit
 
 	sw.log.Info("new a switch succ, cfg: %+v", cfg)
 	return sw, nil
@@ -68,25 +182,43 @@ func NewSwitch(cfg *Config, logger libs.Logger) (*Switch, error) {
 
 // AddReactor should be invoked before switch.Start(),
 // consensus module must be registered.
-func (sw *Switch) AddReactor(mo Module, f libs.Reactor) error {
+// mo is the name of a registered module, optionally on a chain, see libs.ModuleID.Name, and chIDs are
+// the channels owned by the module besides its base ones, they must be in the channel range of the module.
+func (sw *Switch) AddReactor(mo Module, f libs.Reactor, chIDs ...int32) error {
 	sw.mtx.Lock()
 	defer sw.mtx.Unlock()
 
 	if _, ok := sw.reactor[mo]; ok {
 		return fmt.Errorf("module has been registered before, %v", mo)
 	}
+	m, _, err := libs.ParseModule(string(mo))
+	if err != nil {
+		return err
+	}
+	for _, id := range chIDs {
+		if !m.Channels().Contains(id) {
+			return fmt.Errorf("channel out of the range of the module, channel: %d, module: %v", id, mo)
+		}
+		if old, ok := sw.channels[id]; ok && old != mo {
+			return fmt.Errorf("channel has been registered by another module, channel: %d, module: %v", id, old)
+		}
+	}
 
+	for _, id := range chIDs {
+		sw.channels[id] = mo
+	}
 	sw.reactor[mo] = f
-	sw.log.Info("[module %s] registered", mo)
+	sw.log.Info("[module %s] registered, channels: %v", mo, chIDs)
 	return nil
 }
 
+// SetEventBus sets the event bus on which the switch publishes its events.
+func (sw *Switch) SetEventBus(b libs.EventBus) {
+	sw.eventBus = b
+}
+
 func (sw *Switch) Start() error {
-	privData, err := base64.StdEncoding.DecodeString(string(sw.cfg.PrivateKey))
-	if err != nil {
-		return err
-	}
-	priv, err := crypto.UnmarshalPrivateKey(privData)
+	priv, err := sw.nodeKey()
 	if err != nil {
 		return err
 	}
@@ -96,15 +228,34 @@ func (sw *Switch) Start() error {
 		libp2p.Identity(priv),
 		libp2p.Security(secio.ID, secio.New),
 	}
+	if sw.cfg.ProxyAddress != "" {
+		tpt, err := NewProxyTransport(sw.cfg.ProxyAddress)
+		if err != nil {
+			sw.log.Error("new proxy transport failed @ p2p.Start, proxy: %s, err: %v", sw.cfg.ProxyAddress, err)
+			return err
+		}
+		opts = append(opts, libp2p.Transport(tpt))
+	}
+	if sw.gater != nil {
+		opts = append(opts, libp2p.ConnectionGater(&libp2pGater{sw.gater}))
+	}
 	ctx := context.Background()
-	host, err := libp2p.New(ctx, opts...)
+	host, err := sw.newHost(ctx, opts...)
 	if err != nil {
 		sw.log.Error("new libp2p host failed @ p2p.Start, err: %v", err)
 		return err
 	}
 
 	sw.host = host
-	sw.host.SetStreamHandler(protocol.ID(protocolPrefix), sw.handleStream)
+	// the pools are shared by the conns of the streams handled from now on
+	if sw.cfg.SendWorkers > 0 {
+		sw.sendPool = newWorkerPool("send", sw.cfg.SendWorkers, sw.log)
+	}
+	if sw.cfg.HandleWorkers > 0 {
+		sw.handlePool = newWorkerPool("handle", sw.cfg.HandleWorkers, sw.log)
+	}
+	sw.host.SetStreamHandler(protocolID(sw.cfg.NetworkID), sw.handleStream)
+	sw.host.Network().Notify(&network.NotifyBundle{ConnectedF: sw.checkIdentity})
 	// Build host multiaddress
 	hostAddr, _ := multiaddr.NewMultiaddr(fmt.Sprintf("/p2p/%s", sw.host.ID().Pretty()))
 	addr := sw.host.Addrs()[0]
@@ -112,40 +263,92 @@ func (sw *Switch) Start() error {
 	sw.id = &fullAddr
 	sw.log.Info("new p2pnode @ p2p.Start host's multiaddr: %s", fullAddr)
 
-	dhtOpts := []dht.Option{
-		dht.Mode(dht.ModeServer),
-		dht.RoutingTableRefreshPeriod(3 * time.Second),
-		dht.ProtocolPrefix(protocol.ID(protocolPrefix)),
-	}
-	if sw.kdht, err = dht.New(ctx, host, dhtOpts...); err != nil {
-		sw.log.Error("new dht host failed @ p2p.Start, err: %v", err)
-		return err
+	if sw.cfg.Rendezvous.URL != "" {
+		sw.kdht = newRendezvousRouter(host, sw.cfg.Rendezvous, sw.rendezvousChainID(), sw.log)
+		sw.log.Info("discover the peers by the rendezvous @ p2p.Start, url: %s", sw.cfg.Rendezvous.URL)
+	} else {
+		dhtOpts, err := dhtOptions(sw.cfg.DHT, protocolID(sw.cfg.NetworkID))
+		if err != nil {
+			sw.log.Error("invalid dht config @ p2p.Start, err: %v", err)
+			return err
+		}
+		if sw.kdht, err = sw.newRouter(ctx, host, dhtOpts...); err != nil {
+			sw.log.Error("new dht host failed @ p2p.Start, err: %v", err)
+			return err
+		}
 	}
 
+	sw.dialer.Start()
+	// the imported peers are dialed along with the bootstrap ones
+	sw.loadAddrBook()
 	if err := sw.bootstrap(ctx); err != nil {
 		sw.log.Error("bootstrap failed @ p2p.Start, err: %v", err)
 		return err
 	}
 
-	go sw.acceptRoutine()
+	sw.routines.Spawn("accept", sw.acceptRoutine)
+	if sw.cfg.IdleTimeout > 0 || sw.cfg.PingInterval > 0 {
+		sw.routines.Spawn("keepalive", sw.keepAliveRoutine)
+	}
 
 	return nil
 }
 
+// nodeKey returns the networking identity of the host.
+func (sw *Switch) nodeKey() (crypto.PrivKey, error) {
+	if sw.cfg.NodeKeyDir != "" {
+		return LoadOrGenNodeKey(sw.cfg.NodeKeyDir, sw.cfg.PrivateKey)
+	}
+	if sw.cfg.PrivateKey == "" {
+		return nil, errors.New("neither node key dir nor private key is set")
+	}
+	return decodeInlineKey(sw.cfg.PrivateKey)
+}
+
 func (sw *Switch) Stop() error {
 	defer sw.timer.Stop()
+	// saved while the peers are still connected
+	sw.saveAddrBook()
 
 	f := func(peer Peer) bool {
 		peer.FlushStop()
+		sw.auditPeer(AuditDisconnect, peer, "switch stopped")
 		return true
 	}
 	rchan := sw.peers.Range(f)
 	<-rchan
 
-	sw.quit <- struct{}{}
+	close(sw.quit)
+	sw.mtx.Lock()
+	close(sw.stopped)
+	sw.mtx.Unlock()
+	sw.routines.Stop()
+	sw.dialer.Stop()
+	if err := sw.routines.Wait(); err != nil {
+		sw.log.Error("routines fail @ p2p.Stop, err: %v", err)
+	}
+	sw.sendPool.stop()
+	sw.handlePool.stop()
+	if sw.tap != nil {
+		sw.tap.Close()
+	}
+	if sw.audit != nil {
+		sw.audit.close()
+	}
+	if sw.kdht != nil {
+		sw.kdht.Close()
+	}
+	if sw.host != nil {
+		return sw.host.Close()
+	}
 	return nil
 }
 
+// Wait blocks until the routines of the switch have exited, i.e. after Stop.
+func (sw *Switch) Wait() {
+	sw.routines.Wait()
+}
+
 // Peers
 
 // Broadcast runs a go routine for each attempted send, which will block trying
@@ -161,25 +364,158 @@ func (sw *Switch) Broadcast(chID int32, msgBytes []byte) {
 	ch := sw.peers.Range(f)
 	<-ch
 
-	sw.log.Info("Broadcast completed @ Broadcast, chID: %d, msgBytes: %X", chID, msgBytes)
+	sw.msgs.Log("Broadcast completed @ Broadcast", chID, msgBytes)
 }
 
 func (sw *Switch) Send(pr string, chID int32, msgBytes []byte) error {
 	id, err := peer.Decode(pr)
 	if err != nil {
-		sw.log.Error("fail to convert string to id @ Send, err: %v, peer_id: %v, chID: %d, msgBytes: %X", err, pr, chID, msgBytes)
+		sw.log.Error("fail to convert string to id @ Send, err: %v, peer_id: %v, chID: %d, msg: %v", err, pr, chID, sw.msgs.Msg(chID, msgBytes))
 	}
 	p, err := sw.peers.Find(id)
 	if err != nil {
-		sw.log.Error("fail to find peer @ Send, err: %v, peer_id: %v, chID: %d, msgBytes: %X", err, pr, chID, msgBytes)
+		sw.log.Error("fail to find peer @ Send, err: %v, peer_id: %v, chID: %d, msg: %v", err, pr, chID, sw.msgs.Msg(chID, msgBytes))
 		return err
 	}
 	if !p.Send(chID, msgBytes) {
-		return fmt.Errorf("fail to send @ Send, err: %v, peer_id: %v, chID: %d, msgBytes: %X", err, pr, chID, msgBytes)
+		return fmt.Errorf("fail to send @ Send, err: %v, peer_id: %v, chID: %d, msg: %s", err, pr, chID, libs.GetSum(msgBytes))
 	}
 	return nil
 }
 
+// StopPeerForError disconnects from a peer due to an error caused by it,
+// the peer may be dialed again by the acceptRoutine later.
+func (sw *Switch) StopPeerForError(pr string, reason interface{}) {
+	id, err := peer.Decode(pr)
+	if err != nil {
+		sw.log.Error("fail to convert string to id @ StopPeerForError, err: %v, peer_id: %v", err, pr)
+		return
+	}
+	sw.stopPeerForError(id, reason)
+}
+
+func (sw *Switch) stopPeerForError(id peer.ID, reason interface{}) {
+	p, err := sw.peers.Find(id)
+	if err != nil {
+		sw.log.Warn("fail to find peer @ stopPeerForError, err: %v, peer_id: %s", err, id.Pretty())
+		return
+	}
+	if !sw.peers.Remove(p) {
+		return
+	}
+	p.Stop()
+	for _, r := range sw.reactors() {
+		r.RemovePeer(p, reason)
+	}
+	sw.auditPeer(AuditDisconnect, p, reason)
+	sw.log.Warn("stop peer for error @ stopPeerForError, peer_id: %s, reason: %v", id.Pretty(), reason)
+	// the violations and the protocol errors are left to the peer_disconnected event of auditPeer
+	if pp, ok := reason.(peerPanic); ok {
+		sw.publish(libs.Event{
+			Type:   libs.EventPanic,
+			Module: libs.P2PModule,
+			Data: libs.PanicEventData{
+				PeerID: id.Pretty(),
+				Reason: pp.String(),
+				Stack:  pp.stack,
+			},
+		})
+	}
+}
+
+// peerPanic is the reason of the peer stopped by the recovered panic of its conn, e.g. a reactor
+// panicking on its msg, only which is published as libs.EventPanic.
+type peerPanic struct {
+	value interface{}
+	stack string
+}
+
+func (p peerPanic) String() string {
+	return fmt.Sprintf("%v", p.value)
+}
+
+// AddrBook returns the pinned and suspicious addresses.
+func (sw *Switch) AddrBook() *AddrBook {
+	return sw.book
+}
+
+// checkIdentity closes the outbound conns whose remote address is pinned to another peer,
+// the inbound ones are dialed from ephemeral ports and cannot be checked.
+func (sw *Switch) checkIdentity(n network.Network, c network.Conn) {
+	if c.Stat().Direction != network.DirOutbound {
+		return
+	}
+	if err := sw.book.Check(c.RemoteMultiaddr(), c.RemotePeer()); err != ErrIdentityMismatch {
+		return
+	}
+	expected, _ := sw.book.Expected(c.RemoteMultiaddr())
+	sw.onIdentityMismatch(c.RemoteMultiaddr(), expected, c.RemotePeer().Pretty())
+	c.Close()
+	sw.host.Peerstore().ClearAddrs(c.RemotePeer())
+	if sw.kdht != nil {
+		sw.kdht.RemovePeer(c.RemotePeer())
+	}
+}
+
+func (sw *Switch) onIdentityMismatch(addr multiaddr.Multiaddr, expected string, actual string) {
+	sw.log.Error("peer identity mismatch, maybe a man-in-the-middle @ p2p.checkIdentity, addr: %s, expected: %s, actual: %s",
+		addr, expected, actual)
+	sw.ban(addr, actual, fmt.Sprintf("presented %s instead of %s", actual, expected))
+	sw.publish(libs.Event{
+		Type:   libs.EventIdentityMismatch,
+		Module: libs.P2PModule,
+		Data: libs.IdentityEventData{
+			Address:  addr.String(),
+			Expected: expected,
+			Actual:   actual,
+		},
+	})
+}
+
+// recorder returns the tap as an interface, which keeps a nil tap nil.
+func (sw *Switch) recorder() tap.Recorder {
+	if sw.tap == nil {
+		return nil
+	}
+	return sw.tap
+}
+
+func (sw *Switch) publish(e libs.Event) {
+	if sw.eventBus == nil {
+		return
+	}
+	sw.eventBus.Publish(e)
+}
+
+// Peers returns the multiaddrs of the connected peers.
+func (sw *Switch) Peers() []string {
+	var addrs []string
+	for _, p := range sw.peers.List() {
+		addr := sw.genPeerMultiID(p.ID())
+		if addr == "" {
+			addr = p.PeerID()
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// DialPeers queues the dials to the given multiaddrs, it doesn't wait for the dials,
+// the first error of queuing is returned.
+func (sw *Switch) DialPeers(multiAddrs []string) error {
+	var first error
+	for _, addr := range multiAddrs {
+		normalized, err := netaddr.Normalize(addr)
+		if err == nil {
+			err = sw.dialer.Enqueue(normalized)
+		}
+		if err != nil && err != ErrDialPending && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
 func (sw *Switch) GetP2PID(peerID string) (string, error) {
 	return peerID, nil
 }
@@ -197,20 +533,18 @@ func (sw *Switch) bootstrap(ctx context.Context) error {
 		sw.log.Error("kdht bootstrap failed @ p2p.bootstrap, err: %v", err)
 		return err
 	}
+	sw.dialBootstrap()
+	return nil
+}
 
-retry:
+// dialBootstrap queues the bootstrap peers, the acceptRoutine queues them again
+// until the routing table is not empty.
+func (sw *Switch) dialBootstrap() {
 	for _, peerMutltiID := range sw.cfg.BootStrap {
-		if err := sw.connect(peerMutltiID); err != nil {
-			continue
+		if err := sw.dialer.Enqueue(peerMutltiID); err != nil && err != ErrDialPending {
+			sw.log.Warn("queue dial fail @ p2p.bootstrap, remote_peer: %s, err: %v", peerMutltiID, err)
 		}
-		sw.log.Info("build stream success @ bootstrap remote_peer: %s", peerMutltiID)
 	}
-
-	if len(sw.kdht.RoutingTable().ListPeers()) == 0 {
-		goto retry
-	}
-
-	return nil
 }
 
 // DialPeersAsync dials a list of peers asynchronously in random order.
@@ -218,49 +552,142 @@ retry:
 // It ignores ErrNetAddressLookup. However, if there are other errors, first
 // encounter is returned.
 // Nop if there are no peers.
-func (sw *Switch) dialPeersAsync(id peer.ID) error {
+func (sw *Switch) dialPeersAsync(ctx context.Context, id peer.ID) error {
 	old, err := sw.peers.Find(id)
 	if err == nil {
 		if err := old.Validate(); err == nil {
 			return nil
 		}
 	}
-	ctx := context.Background()
-	stream, err := sw.host.NewStream(ctx, id, protocol.ID(protocolPrefix))
+	if !sw.allowDial(id) {
+		sw.auditRecord(AuditRecord{Event: AuditRefuse, Peer: id.Pretty(), Direction: direction(true), Reason: ErrPeerGated.Error()})
+		return ErrPeerGated
+	}
+	stream, err := sw.host.NewStream(ctx, id, protocolID(sw.cfg.NetworkID))
 	if err != nil {
 		sw.log.Error("host make newstream fail @ DialPeersAsync, peer_id: %s, err: %v", id.Pretty(), err)
 		return err
 	}
+	if !sw.allowStream(stream) {
+		sw.auditStream(stream, ErrPeerGated)
+		stream.Reset()
+		return ErrPeerGated
+	}
+	remote, err := handshake(stream, sw.cfg.NetworkID, sw.host.ID().Pretty(), sw.cfg.GenesisHash, sw.cfg.Features, sw.cfg.Experimental, sw.cfg.HandshakeTimeout)
+	if err != nil {
+		sw.log.Error("handshake fail @ DialPeersAsync, peer_id: %s, err: %v", id.Pretty(), err)
+		sw.auditStream(stream, err)
+		stream.Reset()
+		sw.kdht.RemovePeer(id)
+		return err
+	}
+	features, err := sw.negotiate(remote)
+	if err != nil {
+		sw.log.Warn("negotiate fail @ DialPeersAsync, peer_id: %s, err: %v", id.Pretty(), err)
+		sw.auditStream(stream, err)
+		stream.Reset()
+		return err
+	}
 	rawPeer := sw.host.Peerstore().PeerInfo(id)
-	peer, err := NewDefaultPeer(&rawPeer, stream, sw.reactor, sw.log)
+	peer, err := NewDefaultPeer(&rawPeer, features, stream, sw.reactor, sw.channels, sw.stopPeerForError, sw.onDisconnect, sw.recorder(),
+		sw.msgExpired, sw.authorize, sw.newLagTracker(id, features), sw.cfg.MaxMsgSize, sw.cfg.FrameTimeout, sw.envelope(features), sw.msgs, sw.traffic,
+		sw.sendPool, sw.handlePool, sw.log)
 	if err != nil {
 		sw.log.Error("new remote peer fail @ DialPeersAsync, peer_id: %s, err: %v", id.Pretty(), err)
 		stream.Close()
-		sw.kdht.RoutingTable().RemovePeer(id)
+		sw.kdht.RemovePeer(id)
 		return err
 	}
+	sw.addPeer(peer)
+	return nil
+}
+
+// addPeer starts the peer and notifies all reactors, it returns false if the peer
+// loses to an existing conn to the same peer and has been closed.
+func (sw *Switch) addPeer(peer Peer) bool {
+	sw.addMtx.Lock()
+	old, err := sw.peers.Find(peer.ID())
+	if err == nil && !keepNewConn(sw.host.ID(), peer.ID(), peer.Outbound(), old.Outbound()) {
+		sw.addMtx.Unlock()
+		sw.log.Info("close duplicate conn @ p2p.addPeer, peer_id: %s, outbound: %v", peer.ID().Pretty(), peer.Outbound())
+		peer.FlushStop()
+		sw.auditPeer(AuditRefuse, peer, ErrDuplicateConn)
+		return false
+	}
 	sw.peers.Add(peer)
+	sw.addMtx.Unlock()
+
+	reactors := sw.reactors()
+	if old != nil {
+		sw.log.Info("replace duplicate conn @ p2p.addPeer, peer_id: %s, outbound: %v", peer.ID().Pretty(), peer.Outbound())
+		old.FlushStop()
+		for _, r := range reactors {
+			r.RemovePeer(old, ErrDuplicateConn)
+		}
+		sw.auditPeer(AuditDisconnect, old, ErrDuplicateConn)
+	}
 	peer.Start()
-	return nil
+	sw.auditPeer(AuditConnect, peer, nil)
+	for _, r := range reactors {
+		r.AddPeer(peer)
+	}
+	return true
+}
+
+// keepNewConn decides which conn to keep when there are two to the same remote peer.
+// If both were initiated by the same side, the initiator has dialed again and the old
+// one is likely broken, otherwise the one initiated by the lower peer id is kept,
+// so that both sides pick the same stream on simultaneous dials.
+func keepNewConn(local, remote PeerID, newOutbound, oldOutbound bool) bool {
+	if newOutbound == oldOutbound {
+		return true
+	}
+	return newOutbound == (local < remote)
+}
+
+// Reactor returns the reactor registered under the name, see libs.Switch.
+func (sw *Switch) Reactor(name string) (libs.Reactor, bool) {
+	sw.mtx.Lock()
+	defer sw.mtx.Unlock()
+
+	r, ok := sw.reactor[Module(name)]
+	return r, ok
+}
+
+func (sw *Switch) reactors() []libs.Reactor {
+	sw.mtx.Lock()
+	defer sw.mtx.Unlock()
+
+	reactors := make([]libs.Reactor, 0, len(sw.reactor))
+	for _, r := range sw.reactor {
+		reactors = append(reactors, r)
+	}
+	return reactors
 }
 
 func (sw *Switch) acceptRoutine() {
 	for {
 		select {
-		case <-sw.timer.C:
-			for _, peerID := range sw.kdht.RoutingTable().ListPeers() {
-				if _, err := sw.peers.Find(peerID); err == nil {
-					continue
-				}
-				multiAddr := sw.genPeerMultiID(peerID)
-				if multiAddr == "" {
-					continue
-				}
-				if err := sw.connect(multiAddr); err != nil {
-					continue
-				}
-				sw.log.Info("connect peer from router table @ p2p.acceptRoutine, peer_id: %s", peerID.Pretty())
+		case <-sw.timer.C():
+			if sw.tap != nil {
+				sw.tap.Flush()
+			}
+			routed := sw.kdht.ListPeers()
+			if added, removed := sw.routing.update(routed, sw.genPeerMultiID); len(added)+len(removed) > 0 {
+				sw.log.Info("routing table changed @ p2p.acceptRoutine, added: %d, removed: %d, routed: %d",
+					len(added), len(removed), len(routed))
+			}
+			if len(routed) == 0 {
+				sw.dialBootstrap()
 			}
+			sw.dialRouted(routed)
+		case res := <-sw.dialer.Results():
+			if res.Err != nil {
+				sw.log.Warn("dial fail @ p2p.acceptRoutine, multi_peer: %s, cost: %v, err: %v", res.Addr, res.Cost, res.Err)
+				sw.routing.forget(res.Addr)
+				continue
+			}
+			sw.log.Info("connect peer succ @ p2p.acceptRoutine, multi_peer: %s, cost: %v", res.Addr, res.Cost)
 		case <-sw.quit:
 			sw.log.Error("switch meets end @ p2p.acceptRoutine, return")
 			return
@@ -268,52 +695,206 @@ func (sw *Switch) acceptRoutine() {
 	}
 }
 
-func (sw *Switch) connect(multiAddr string) error {
-	peerAddr, err := ipfsaddr.ParseString(multiAddr)
+// connect dials the peer and builds the stream, it's run by the dialer workers.
+func (sw *Switch) connect(ctx context.Context, multiAddr string) error {
+	addrInfo, err := netaddr.Parse(multiAddr)
 	if err != nil {
-		sw.log.Error("parse string failed @ p2p.acceptRoutine, multi_peer: %s, err: %v", multiAddr, err)
+		sw.log.Error("parse peer addr failed @ p2p.acceptRoutine, multi_peer: %s, err: %v", multiAddr, err)
 		return err
 	}
-	addrInfo, err := peer.AddrInfoFromP2pAddr(peerAddr.Multiaddr())
-	if err != nil {
-		sw.log.Error("add addrinfo failed @ p2p.acceptRoutine, multi_peer: %s, err: %v", multiAddr, err)
-		return err
+	for _, addr := range addrInfo.Addrs {
+		if err := sw.book.Check(addr, addrInfo.ID); err != nil {
+			sw.log.Error("refuse to dial @ p2p.acceptRoutine, multi_peer: %s, err: %v", multiAddr, err)
+			return err
+		}
+	}
+	if !sw.allowDial(addrInfo.ID) {
+		sw.log.Warn("refuse to dial @ p2p.acceptRoutine, multi_peer: %s, err: %v", multiAddr, ErrPeerGated)
+		sw.auditRecord(AuditRecord{Event: AuditRefuse, Peer: addrInfo.ID.Pretty(), Addr: multiAddr, Direction: direction(true),
+			Reason: ErrPeerGated.Error()})
+		return ErrPeerGated
 	}
-	if err := sw.host.Connect(context.Background(), *addrInfo); err != nil {
+	if err := sw.host.Connect(ctx, *addrInfo); err != nil {
 		sw.log.Error("host connect failed @ p2p.acceptRoutine, peer_id: %s, err: %v", addrInfo.ID.Pretty(), err)
+		// secio refuses the remote peer whose key doesn't match the dialed id
+		if strings.Contains(err.Error(), secio.ErrWrongPeer.Error()) {
+			for _, addr := range addrInfo.Addrs {
+				sw.onIdentityMismatch(addr, addrInfo.ID.Pretty(), "")
+			}
+		}
 		return err
 	}
-	if err := sw.dialPeersAsync(addrInfo.ID); err != nil {
+	if err := sw.dialPeersAsync(ctx, addrInfo.ID); err != nil {
 		sw.log.Error("dial fail @ p2p.acceptRoutine peer_id: %s, err: %v", addrInfo.ID.Pretty(), err)
+		return err
 	}
 	return nil
 }
 
+// handleStream accepts the inbound streams, an inbound stream from a connected peer
+// is resolved by addPeer, since both sides may have dialed each other simultaneously.
 func (sw *Switch) handleStream(netStream network.Stream) {
-	old, err := sw.peers.Find(netStream.Conn().RemotePeer())
-	if err == nil {
-		if err := old.Validate(); err == nil {
-			sw.log.Error("use an old one @ handleStream, peer_id: %s", netStream.Conn().RemotePeer())
-			return
-		}
+	if !sw.allowStream(netStream) {
+		sw.log.Warn("refuse the stream @ handleStream, peer_id: %s, err: %v", netStream.Conn().RemotePeer(), ErrPeerGated)
+		sw.auditStream(netStream, ErrPeerGated)
+		netStream.Reset()
+		return
+	}
+	remote, err := handshake(netStream, sw.cfg.NetworkID, sw.host.ID().Pretty(), sw.cfg.GenesisHash, sw.cfg.Features, sw.cfg.Experimental, sw.cfg.HandshakeTimeout)
+	if err != nil {
+		sw.log.Error("handshake fail @ handleStream, peer_id: %s, err: %v", netStream.Conn().RemotePeer(), err)
+		sw.auditStream(netStream, err)
+		netStream.Reset()
+		return
+	}
+	features, err := sw.negotiate(remote)
+	if err != nil {
+		sw.log.Warn("negotiate fail @ handleStream, peer_id: %s, err: %v", netStream.Conn().RemotePeer(), err)
+		sw.auditStream(netStream, err)
+		netStream.Reset()
+		return
 	}
 	p := sw.host.Peerstore().PeerInfo(netStream.Conn().RemotePeer())
-	peer, err := NewDefaultPeer(&p, netStream, sw.reactor, sw.log)
+	peer, err := NewDefaultPeer(&p, features, netStream, sw.reactor, sw.channels, sw.stopPeerForError, sw.onDisconnect, sw.recorder(),
+		sw.msgExpired, sw.authorize, sw.newLagTracker(p.ID, features), sw.cfg.MaxMsgSize, sw.cfg.FrameTimeout, sw.envelope(features), sw.msgs, sw.traffic,
+		sw.sendPool, sw.handlePool, sw.log)
 	if err != nil {
 		sw.log.Error("new remote peer fail @ handleStream, peer_id: %s, err: %v", netStream.Conn().RemotePeer(), err)
+		netStream.Reset()
+		return
+	}
+	if !sw.addPeer(peer) {
 		return
 	}
-	sw.peers.Add(peer)
-	peer.Start()
 	sw.log.Info("build stream success from a new remote peer @ handleStream, peer_id: %s", netStream.Conn().RemotePeer())
 }
 
 // ---------------------------------------------------------------------------------------------------
 type Config struct {
-	Address    string
-	BootStrap  []string
-	PrivateKey string // only for networking
+	// NetworkID namespaces the protocol id and the dht prefix,
+	// peers of other networks are rejected during the handshake.
+	NetworkID string
+	// ChainID and ForkID are stamped on every packet, the packets of another chain or fork are refused,
+	// see libs.FeatureChainEnvelope for the peers which don't stamp them.
+	ChainID string
+	ForkID  string
+	// GenesisHash is checked during the handshake as well, see libs.Genesis,
+	// empty means accepting the peers of any genesis.
+	GenesisHash []byte
+	Address     string
+	BootStrap   []string
+	// NodeKeyDir is the dir of node_key.json, the networking identity, which is generated on the first start.
+	NodeKeyDir string
+	// PrivateKey is the deprecated inline base64 key, it's migrated into NodeKeyDir if that's set,
+	// and used as is otherwise.
+	PrivateKey string
 	PublicKey  string // only for networking
+	// ProxyAddress dials the peers through a SOCKS5 or HTTP CONNECT proxy,
+	// e.g. socks5://127.0.0.1:9050, empty means dialing directly.
+	ProxyAddress string
+	// TapPath captures every msg sent and received into the rotating file for debugging,
+	// empty disables it, see `gohotstuff msgs dump`.
+	TapPath        string
+	TapMaxFileSize int64
+	TapMaxFiles    int
+	// AuditPath appends every connect, disconnect, refusal and ban of the peers to the file as json lines,
+	// empty keeps the latest AuditRecent records in memory only, which are served by PeerAudit either way.
+	AuditPath   string
+	AuditRecent int
+	// AddrBookPath saves the address book and the known peers on Stop and imports them on Start,
+	// so that a restarted or rebuilt node reconnects without the dht, empty disables it, see ExportAddrBook.
+	AddrBookPath string
+	// PinnedPeers are the addresses of the validators, full multiaddrs, e.g. /ip4/127.0.0.1/tcp/30001/p2p/Qm...,
+	// or host:port@id, see netaddr.Parse, their addresses only accept the pinned peer ids,
+	// the bootstrap peers are given in either format and pinned as well.
+	PinnedPeers []string
+	// DialConcurrency limits the concurrent outbound dials, and DialTimeout bounds
+	// the connecting and the stream negotiation of each dial.
+	DialConcurrency int
+	DialTimeout     time.Duration
+	DHT             DHTConfig
+	// Rendezvous replaces the DHT by the registry server if its URL is set.
+	Rendezvous RendezvousConfig
+	// SlowPeer detects the peers lagging behind their send queues and chunks the msgs to them.
+	SlowPeer SlowPeerConfig
+	// StreamRetry recreates the reset streams on the living connections,
+	// and RedialRetry redials the lost connections, the zero values use the defaults.
+	StreamRetry RetryPolicy
+	RedialRetry RetryPolicy
+	// MaxMsgSize is the max size of a frame sent or received, 1MB by default,
+	// it must be able to carry the largest proposal of the chains, see state.MaxProposalBytes.
+	MaxMsgSize int
+	// SentryPeers are the peer ids of the sentries, which are dialed after the current validators
+	// and before the other routed peers, DialQuota bounds the outbound connections of each of them.
+	SentryPeers []string
+	DialQuota   DialQuota
+	// ChannelRoles restricts the peers which may send on the channels of the modules by their roles, keyed by
+	// the module name, e.g. {"consensus": RoleValidator}, the channels of the other modules are open to all.
+	// The msgs of the unauthorized peers are dropped and lower their scores, see PeerScore.
+	// SyncServers are the peer ids with the RoleSyncServer.
+	ChannelRoles map[string]PeerRole
+	SyncServers  []string
+	// SendQueueTTL drops the msgs which have waited longer in the send queue of a slow peer,
+	// 0 keeps them until they are sent.
+	SendQueueTTL time.Duration
+	// SendResultTimeout bounds how long SendWithResult waits for the msg to be written, 10s by default.
+	SendResultTimeout time.Duration
+	// IdleTimeout closes the streams of the peers other than the current validators once they have had no traffic
+	// for it, the streams of the validators are kept alive by the pings every PingInterval of idleness instead,
+	// 0 disables either.
+	IdleTimeout  time.Duration
+	PingInterval time.Duration
+	// HandshakeTimeout bounds the handshake of a new stream, both the write and the read of it, and FrameTimeout
+	// bounds the read of a frame from its first byte on, so that the peers trickling the bytes are dropped
+	// instead of holding the streams. The zero HandshakeTimeout uses DefaultHandshakeTimeout, the zero
+	// FrameTimeout uses DefaultFrameTimeout, which must carry the largest frame at the slowest honest link,
+	// a negative one disables it.
+	HandshakeTimeout time.Duration
+	FrameTimeout     time.Duration
+	// SendWorkers write the send queues of all peers and HandleWorkers dispatch the msgs received from them to
+	// the reactors, so that the goroutines don't grow with the peers and their channels, the tasks finding
+	// no idle worker run on their own goroutines. The zero values use DefaultSendWorkers and DefaultHandleWorkers,
+	// a negative SendWorkers runs a send loop for every channel of every peer, a negative HandleWorkers
+	// a goroutine for every msg received.
+	SendWorkers   int
+	HandleWorkers int
+	// Features are the wire features advertised in the handshake, each is used with the peers advertising it as well,
+	// the peers lacking any of the RequiredFeatures or below the MinProtocolVersion are refused.
+	Features           libs.Features
+	RequiredFeatures   libs.Features
+	MinProtocolVersion uint32
+	// Experimental are the experimental flags advertised in the handshake, see libs.FeatureFlags,
+	// the peers advertising others are connected but warned of.
+	Experimental []string
+	// MsgLog controls the logs of the msg payloads, see WithRedactor for masking the sensitive ones.
+	MsgLog MsgLogConfig
 
 	TickerTimeSec int64
 }
+
+// normalizeAddrs rewrites the bootstrap and the pinned peers into multiaddrs and checks the sentry and sync server ids,
+// so that the dial queue and the address book see a single form of every address.
+func normalizeAddrs(cfg *Config) error {
+	for _, list := range []*[]string{&cfg.BootStrap, &cfg.PinnedPeers} {
+		normalized := make([]string, 0, len(*list))
+		for _, addr := range *list {
+			s, err := netaddr.Normalize(addr)
+			if err != nil {
+				return err
+			}
+			normalized = append(normalized, s)
+		}
+		*list = normalized
+	}
+	for _, id := range cfg.SentryPeers {
+		if _, err := netaddr.ParseID(id); err != nil {
+			return fmt.Errorf("invalid sentry peer, err: %w", err)
+		}
+	}
+	for _, id := range cfg.SyncServers {
+		if _, err := netaddr.ParseID(id); err != nil {
+			return fmt.Errorf("invalid sync server, err: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+package p2p
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+type stubRouter struct{}
+
+func (stubRouter) Bootstrap(ctx context.Context) error { return nil }
+func (stubRouter) ListPeers() []peer.ID                { return nil }
+func (stubRouter) RemovePeer(id peer.ID)               {}
+func (stubRouter) Close() error                        { return nil }
+
+type recvReactor struct {
+	recv    chan []byte
+	peers   chan libs.Peer
+	removed chan removedPeer
+}
+
+type removedPeer struct {
+	peer   libs.Peer
+	reason interface{}
+}
+
+func newRecvReactor() *recvReactor {
+	return &recvReactor{recv: make(chan []byte, 10), peers: make(chan libs.Peer, 10), removed: make(chan removedPeer, 10)}
+}
+
+func (r *recvReactor) HandleFunc(chID int32, msgBytes []byte) {
+	// the msg bytes are recycled once we return
+	r.recv <- append([]byte(nil), msgBytes...)
+}
+
+func (r *recvReactor) SetSwitch(sw libs.Switch) {}
+func (r *recvReactor) AddPeer(peer libs.Peer)   { r.peers <- peer }
+func (r *recvReactor) RemovePeer(peer libs.Peer, reason interface{}) {
+	select {
+	case r.removed <- removedPeer{peer, reason}:
+	default:
+	}
+}
+
+// newMockSwitch starts a switch on an in-memory host of the mocknet.
+func newMockSwitch(t *testing.T, mn mocknet.Mocknet, r libs.Reactor) *Switch {
+	sk, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := crypto.MarshalPrivateKey(sk)
+	cfg := &Config{
+		NetworkID:  "test",
+		PrivateKey: base64.StdEncoding.EncodeToString(raw),
+	}
+	hostFactory := func(ctx context.Context, opts ...libp2p.Option) (host.Host, error) {
+		return mn.GenPeer()
+	}
+	routerFactory := func(ctx context.Context, h host.Host, opts ...dht.Option) (Router, error) {
+		return stubRouter{}, nil
+	}
+	sw, err := NewSwitch(cfg, nil, WithHostFactory(hostFactory), WithRouterFactory(routerFactory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddReactor(Module(libs.ConsensusModule), r); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sw.Stop() })
+	return sw
+}
+
+func TestSwitchSend(t *testing.T) {
+	mn := mocknet.New(context.Background())
+	r1, r2 := newRecvReactor(), newRecvReactor()
+	sw1 := newMockSwitch(t, mn, r1)
+	sw2 := newMockSwitch(t, mn, r2)
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	addr := fmt.Sprintf("%s/p2p/%s", sw2.host.Addrs()[0], sw2.host.ID().Pretty())
+	if err := sw1.DialPeers([]string{addr}); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []*recvReactor{r1, r2} {
+		select {
+		case <-r.peers:
+		case <-time.After(3 * time.Second):
+			t.Fatal("peer should be added on both sides")
+		}
+	}
+
+	if err := sw1.Send(sw2.host.ID().Pretty(), libs.ConsensusChannel, []byte("hotstuff")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-r2.recv:
+		if string(msg) != "hotstuff" {
+			t.Errorf("want: hotstuff, has: %s", msg)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("msg should be received")
+	}
+}
+
+func TestSwitchAddPeerDuplicate(t *testing.T) {
+	mn := mocknet.New(context.Background())
+	sw := newMockSwitch(t, mn, newRecvReactor())
+
+	// the remote id is higher than ours so the conn dialed by us is kept
+	remote := PeerID(string(sw.host.ID()) + "\xff")
+	inbound, outbound := NewMockPeer(remote, false), NewMockPeer(remote, true)
+	if !sw.addPeer(inbound) {
+		t.Fatal("the first conn should be added")
+	}
+	if !sw.addPeer(outbound) {
+		t.Fatal("the conn initiated by the lower peer id should replace the other")
+	}
+	if inbound.IsRunning() || !outbound.IsRunning() {
+		t.Errorf("inbound should be stopped and outbound should be running")
+	}
+	late := NewMockPeer(remote, false)
+	if sw.addPeer(late) {
+		t.Errorf("the conn initiated by the higher peer id should be refused")
+	}
+	if p, _ := sw.peers.Find(remote); p != outbound {
+		t.Errorf("outbound should be kept")
+	}
+}
+
+func TestSwitchPeerCallbacks(t *testing.T) {
+	mn := mocknet.New(context.Background())
+	r := newRecvReactor()
+	sw := newMockSwitch(t, mn, r)
+	side := newRecvReactor()
+	if err := sw.AddReactor(Module(libs.ModuleMempool.Name("")), side); err != nil {
+		t.Fatal(err)
+	}
+
+	remote := PeerID(string(sw.host.ID()) + "\xff")
+	inbound, outbound := NewMockPeer(remote, false), NewMockPeer(remote, true)
+	sw.addPeer(inbound)
+	// the replaced conn is removed before the new one is added
+	sw.addPeer(outbound)
+	sw.stopPeerForError(remote, "bad msg")
+
+	for _, rr := range []*recvReactor{r, side} {
+		for _, want := range []libs.Peer{inbound, outbound} {
+			select {
+			case p := <-rr.peers:
+				if p != want {
+					t.Errorf("want the peer added: %v, has: %v", want, p)
+				}
+			default:
+				t.Fatal("want every reactor notified of the added peers")
+			}
+		}
+		for _, want := range []removedPeer{{inbound, ErrDuplicateConn}, {outbound, "bad msg"}} {
+			select {
+			case p := <-rr.removed:
+				if p != want {
+					t.Errorf("want the peer removed: %+v, has: %+v", want, p)
+				}
+			default:
+				t.Fatal("want every reactor notified of the removed peers")
+			}
+		}
+	}
+	// a peer unknown to the switch isn't reported
+	sw.stopPeerForError(remote, "bad msg")
+	if len(r.removed) != 0 {
+		t.Errorf("want no removal of the unknown peer, has: %d", len(r.removed))
+	}
+}
+
+func TestSwitchReactor(t *testing.T) {
+	mn := mocknet.New(context.Background())
+	cons := newRecvReactor()
+	sw := newMockSwitch(t, mn, cons)
+
+	mempool := newRecvReactor()
+	if err := sw.AddReactor(Module(libs.ModuleMempool.Name("side")), mempool, libs.ChainChannel("side", libs.MempoolChannel)); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddReactor(Module(libs.ModuleJoin.Name("side")), newRecvReactor(), libs.ChainChannel("side", libs.MempoolChannel)); err == nil {
+		t.Error("want the channel out of the range of the module refused")
+	}
+	if err := sw.AddReactor(Module("side/sync"), newRecvReactor()); err == nil {
+		t.Error("want the unregistered module refused")
+	}
+
+	if r, ok := sw.Reactor(libs.ModuleMempool.Name("side")); !ok || r != mempool {
+		t.Errorf("want the mempool of the chain, has: %v", r)
+	}
+	if r, ok := sw.Reactor(libs.ModuleConsensus.Name("")); !ok || r != cons {
+		t.Errorf("want the consensus, has: %v", r)
+	}
+	if _, ok := sw.Reactor(libs.ModuleMempool.Name("")); ok {
+		t.Error("want no mempool of the default chain")
+	}
+}
+
+// panicReactor panics on every msg received.
+type panicReactor struct {
+	*recvReactor
+}
+
+func (r panicReactor) HandleFunc(chID int32, msgBytes []byte) {
+	panic("malformed msg")
+}
+
+func TestSwitchReactorPanic(t *testing.T) {
+	mn := mocknet.New(context.Background())
+	r1, r2 := newRecvReactor(), panicReactor{newRecvReactor()}
+	sw1 := newMockSwitch(t, mn, r1)
+	sw2 := newMockSwitch(t, mn, r2)
+	bus := libs.NewDefaultEventBus(nil)
+	sw2.SetEventBus(bus)
+	events := bus.Subscribe(libs.EventPanic)
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	addr := fmt.Sprintf("%s/p2p/%s", sw2.host.Addrs()[0], sw2.host.ID().Pretty())
+	if err := sw1.DialPeers([]string{addr}); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []*recvReactor{r1, r2.recvReactor} {
+		select {
+		case <-r.peers:
+		case <-time.After(3 * time.Second):
+			t.Fatal("peer should be added on both sides")
+		}
+	}
+	if err := sw1.Send(sw2.host.ID().Pretty(), libs.ConsensusChannel, []byte("hotstuff")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the panic of the reactor only costs the peer who sent the msg
+	select {
+	case e := <-events:
+		data, ok := e.Data.(libs.PanicEventData)
+		if !ok || data.PeerID != sw1.host.ID().Pretty() || data.Reason != "malformed msg" || data.Stack == "" {
+			t.Errorf("unexpected panic event: %+v", e)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("want the panic published")
+	}
+	if _, err := sw2.peers.Find(sw1.host.ID()); err == nil {
+		t.Error("want the peer stopped")
+	}
+}
+
+func TestSwitchChains(t *testing.T) {
+	mn := mocknet.New(context.Background())
+	r1, r2 := newRecvReactor(), newRecvReactor()
+	sw1 := newMockSwitch(t, mn, r1)
+	sw2 := newMockSwitch(t, mn, r2)
+	side1, side2 := newRecvReactor(), newRecvReactor()
+	sideCh := libs.ChainChannel("side", libs.ConsensusChannel)
+	for sw, r := range map[*Switch]*recvReactor{sw1: side1, sw2: side2} {
+		if err := sw.AddReactor(Module(libs.ModuleConsensus.Name("side")), r, sideCh); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	addr := fmt.Sprintf("%s/p2p/%s", sw2.host.Addrs()[0], sw2.host.ID().Pretty())
+	if err := sw1.DialPeers([]string{addr}); err != nil {
+		t.Fatal(err)
+	}
+	// the reactors of both chains share the peers of the switch
+	for _, r := range []*recvReactor{r1, r2, side1, side2} {
+		select {
+		case <-r.peers:
+		case <-time.After(3 * time.Second):
+			t.Fatal("peer should be added to the reactors of both chains")
+		}
+	}
+
+	// the msgs of a chain only reach the reactor of the chain
+	for _, c := range []struct {
+		ch   int32
+		msg  string
+		r    *recvReactor
+		skip *recvReactor
+	}{
+		{sideCh, "side", side2, r2},
+		{libs.ConsensusChannel, "main", r2, side2},
+	} {
+		if err := sw1.Send(sw2.host.ID().Pretty(), c.ch, []byte(c.msg)); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case msg := <-c.r.recv:
+			if string(msg) != c.msg {
+				t.Errorf("want: %s, has: %s", c.msg, msg)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("msg of the chain should be received, channel: %d", c.ch)
+		}
+		select {
+		case msg := <-c.skip.recv:
+			t.Errorf("want the msg of the chain kept from the other chain, has: %s", msg)
+		default:
+		}
+	}
+}
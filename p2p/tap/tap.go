@@ -0,0 +1,235 @@
+// Package tap captures the msgs sent and received by the peers for debugging.
+//
+// A capture file starts with the 4-byte magic "GTAP" and a 1-byte version,
+// followed by records, all integers are big-endian:
+//
+//	size  field
+//	8     timestamp in unix nanoseconds
+//	1     direction, 0 for inbound and 1 for outbound
+//	4     channel id
+//	2     peer id length
+//	4     msg length
+//	n     peer id
+//	m     msg
+package tap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	magic   = "GTAP"
+	version = 1
+
+	recordHeaderSize = 19
+
+	DefaultMaxFileSize = 64 << 20
+	DefaultMaxFiles    = 5
+)
+
+var (
+	ErrBadHeader = errors.New("not a tap file")
+)
+
+type Direction uint8
+
+const (
+	Inbound Direction = iota
+	Outbound
+)
+
+func (d Direction) String() string {
+	if d == Outbound {
+		return "out"
+	}
+	return "in"
+}
+
+// Record is a captured msg.
+type Record struct {
+	Time      time.Time
+	Direction Direction
+	Channel   int32
+	Peer      string
+	Msg       []byte
+}
+
+// Recorder is implemented by the taps attached to the peer connections.
+type Recorder interface {
+	Record(r *Record) error
+}
+
+// Writer appends the records to the file at path, the file is rotated to path.1,
+// path.2, ... once it exceeds maxFileSize, and at most maxFiles files are kept.
+type Writer struct {
+	path        string
+	maxFileSize int64
+	maxFiles    int
+
+	file *os.File
+	buf  *bufio.Writer
+	size int64
+	mtx  sync.Mutex
+}
+
+func NewWriter(path string, maxFileSize int64, maxFiles int) (*Writer, error) {
+	if maxFileSize <= 0 {
+		maxFileSize = DefaultMaxFileSize
+	}
+	if maxFiles <= 0 {
+		maxFiles = DefaultMaxFiles
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	w := &Writer{
+		path:        path,
+		maxFileSize: maxFileSize,
+		maxFiles:    maxFiles,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) Record(r *Record) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.file == nil {
+		return os.ErrClosed
+	}
+	if len(r.Peer) > 0xffff {
+		return fmt.Errorf("peer id is too long: %d", len(r.Peer))
+	}
+	if w.size >= w.maxFileSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	header := make([]byte, recordHeaderSize)
+	binary.BigEndian.PutUint64(header[0:8], uint64(r.Time.UnixNano()))
+	header[8] = byte(r.Direction)
+	binary.BigEndian.PutUint32(header[9:13], uint32(r.Channel))
+	binary.BigEndian.PutUint16(header[13:15], uint16(len(r.Peer)))
+	binary.BigEndian.PutUint32(header[15:19], uint32(len(r.Msg)))
+	for _, b := range [][]byte{header, []byte(r.Peer), r.Msg} {
+		n, err := w.buf.Write(b)
+		w.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes the buffered records to the file.
+func (w *Writer) Flush() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	return w.buf.Flush()
+}
+
+func (w *Writer) Close() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.buf.Flush()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	w.file = nil
+	return err
+}
+
+func (w *Writer) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.buf = bufio.NewWriter(file)
+	w.size = 0
+	n, err := w.buf.Write(append([]byte(magic), version))
+	w.size += int64(n)
+	return err
+}
+
+func (w *Writer) rotate() error {
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	os.Remove(fmt.Sprintf("%s.%d", w.path, w.maxFiles-1))
+	for i := w.maxFiles - 2; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+	}
+	if w.maxFiles > 1 {
+		if err := os.Rename(w.path, w.path+".1"); err != nil {
+			return err
+		}
+	}
+	return w.open()
+}
+
+// Reader decodes the records of a capture file.
+type Reader struct {
+	r *bufio.Reader
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, ErrBadHeader
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, ErrBadHeader
+	}
+	if header[len(magic)] != version {
+		return nil, fmt.Errorf("unsupported tap version: %d", header[len(magic)])
+	}
+	return &Reader{r: br}, nil
+}
+
+// Next returns the next record, io.EOF is returned at the end of the file.
+func (r *Reader) Next() (*Record, error) {
+	header := make([]byte, recordHeaderSize)
+	if _, err := io.ReadFull(r.r, header); err != nil {
+		return nil, err
+	}
+	peer := make([]byte, binary.BigEndian.Uint16(header[13:15]))
+	msg := make([]byte, binary.BigEndian.Uint32(header[15:19]))
+	if _, err := io.ReadFull(r.r, peer); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if _, err := io.ReadFull(r.r, msg); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return &Record{
+		Time:      time.Unix(0, int64(binary.BigEndian.Uint64(header[0:8]))),
+		Direction: Direction(header[8]),
+		Channel:   int32(binary.BigEndian.Uint32(header[9:13])),
+		Peer:      string(peer),
+		Msg:       msg,
+	}, nil
+}
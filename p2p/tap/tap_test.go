@@ -0,0 +1,77 @@
+package tap
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriterReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tap")
+	w, err := NewWriter(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	records := []*Record{
+		{Time: now, Direction: Inbound, Channel: 0, Peer: "QmA", Msg: []byte("proposal")},
+		{Time: now.Add(time.Millisecond), Direction: Outbound, Channel: -2, Peer: "QmB", Msg: nil},
+	}
+	for _, r := range records {
+		if err := w.Record(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rd, err := NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range records {
+		has, err := rd.Next()
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if !has.Time.Equal(want.Time) || has.Direction != want.Direction || has.Channel != want.Channel ||
+			has.Peer != want.Peer || string(has.Msg) != string(want.Msg) {
+			t.Errorf("record %d, want: %+v, has: %+v", i, want, has)
+		}
+	}
+	if _, err := rd.Next(); err != io.EOF {
+		t.Errorf("want io.EOF, has: %v", err)
+	}
+}
+
+func TestWriterRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tap")
+	w, err := NewWriter(path, 64, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Record{Time: time.Now(), Peer: "QmA", Msg: make([]byte, 64)}
+	for i := 0; i < 5; i++ {
+		if err := w.Record(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+
+	for _, p := range []string{path, path + ".1", path + ".2"} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("%s should exist, err: %v", p, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("%s.3 should be removed, err: %v", path, err)
+	}
+}
@@ -0,0 +1,116 @@
+package p2p
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/aucusaga/gohotstuff/metrics"
+)
+
+// Traffic is the bytes of the frames sent and received by a module, the frame headers included.
+// The keep-alive frames belong to no module and are not counted.
+type Traffic struct {
+	Module   Module
+	Sent     int64
+	Received int64
+}
+
+// PeerTraffic is the traffic of the conn to the peer since it was established.
+type PeerTraffic struct {
+	Peer     string
+	Outbound bool
+	Modules  []Traffic
+}
+
+// NetInfo is the traffic of the switch by the module, Modules are counted since the switch
+// started including the peers gone, Peers are the connected ones.
+type NetInfo struct {
+	Modules []Traffic
+	Peers   []PeerTraffic
+}
+
+// trafficMeter counts the bytes by the module, which are added to the parent as well,
+// the switch owns the root meter exporting the metrics and every conn owns a child of it.
+type trafficMeter struct {
+	mtx     sync.Mutex
+	modules map[Module]*Traffic
+	parent  *trafficMeter
+
+	// registry is nil for the meters of the conns
+	registry *metrics.Registry
+	sent     map[Module]*metrics.Counter
+	received map[Module]*metrics.Counter
+}
+
+func newTrafficMeter(parent *trafficMeter, r *metrics.Registry) *trafficMeter {
+	return &trafficMeter{
+		modules:  make(map[Module]*Traffic),
+		parent:   parent,
+		registry: r,
+		sent:     make(map[Module]*metrics.Counter),
+		received: make(map[Module]*metrics.Counter),
+	}
+}
+
+func (m *trafficMeter) add(mo Module, sent, received int64) {
+	if m == nil {
+		return
+	}
+	m.mtx.Lock()
+	t, ok := m.modules[mo]
+	if !ok {
+		t = &Traffic{Module: mo}
+		m.modules[mo] = t
+	}
+	t.Sent += sent
+	t.Received += received
+	if m.registry != nil {
+		m.counters(mo)
+		m.sent[mo].Add(float64(sent))
+		m.received[mo].Add(float64(received))
+	}
+	m.mtx.Unlock()
+
+	m.parent.add(mo, sent, received)
+}
+
+// counters creates the metrics of the module on its first frame, mtx must be held.
+func (m *trafficMeter) counters(mo Module) {
+	if _, ok := m.sent[mo]; ok {
+		return
+	}
+	m.sent[mo] = m.registry.NewCounter("gohotstuff_p2p_sent_bytes_total",
+		"Bytes of the frames sent by the module.", "module", string(mo))
+	m.received[mo] = m.registry.NewCounter("gohotstuff_p2p_received_bytes_total",
+		"Bytes of the frames received by the module.", "module", string(mo))
+}
+
+// list returns the traffic of the modules in the lexical order.
+func (m *trafficMeter) list() []Traffic {
+	if m == nil {
+		return nil
+	}
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	list := make([]Traffic, 0, len(m.modules))
+	for _, t := range m.modules {
+		list = append(list, *t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Module < list[j].Module })
+	return list
+}
+
+// NetInfo returns the traffic of the modules and of the connected peers in the lexical order.
+func (sw *Switch) NetInfo() NetInfo {
+	info := NetInfo{Modules: sw.traffic.list()}
+	for _, p := range sw.peers.List() {
+		info.Peers = append(info.Peers, PeerTraffic{
+			Peer:     p.PeerID(),
+			Outbound: p.Outbound(),
+			Modules:  p.Traffic(),
+		})
+	}
+	sort.Slice(info.Peers, func(i, j int) bool { return info.Peers[i].Peer < info.Peers[j].Peer })
+	return info
+}
@@ -0,0 +1,91 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/metrics"
+	wire "github.com/aucusaga/gohotstuff/p2p/conn"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+func TestTrafficMeter(t *testing.T) {
+	r := metrics.NewRegistry()
+	root := newTrafficMeter(nil, r)
+	a, b := newTrafficMeter(root, nil), newTrafficMeter(root, nil)
+
+	a.add("consensus", 10, 0)
+	a.add("mempool", 0, 5)
+	b.add("consensus", 1, 2)
+
+	want := []Traffic{{Module: "consensus", Sent: 11, Received: 2}, {Module: "mempool", Received: 5}}
+	if got := root.list(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("want: %v, has: %v", want, got)
+	}
+	if got := b.list(); len(got) != 1 || got[0] != (Traffic{Module: "consensus", Sent: 1, Received: 2}) {
+		t.Errorf("the meter of the conn should count its own bytes only, has: %v", got)
+	}
+	if v := r.NewCounter("gohotstuff_p2p_sent_bytes_total", "", "module", "consensus").Value(); v != 11 {
+		t.Errorf("want: 11 sent bytes exported, has: %v", v)
+	}
+	if v := r.NewCounter("gohotstuff_p2p_received_bytes_total", "", "module", "mempool").Value(); v != 5 {
+		t.Errorf("want: 5 received bytes exported, has: %v", v)
+	}
+}
+
+func TestSwitchNetInfo(t *testing.T) {
+	mn := mocknet.New(context.Background())
+	r1, r2 := newRecvReactor(), newRecvReactor()
+	sw1 := newMockSwitch(t, mn, r1)
+	sw2 := newMockSwitch(t, mn, r2)
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+	addr := fmt.Sprintf("%s/p2p/%s", sw2.host.Addrs()[0], sw2.host.ID().Pretty())
+	if err := sw1.DialPeers([]string{addr}); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []*recvReactor{r1, r2} {
+		select {
+		case <-r.peers:
+		case <-time.After(3 * time.Second):
+			t.Fatal("peer should be added on both sides")
+		}
+	}
+
+	if err := sw1.Send(sw2.host.ID().Pretty(), libs.ConsensusChannel, []byte("hotstuff")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-r2.recv:
+	case <-time.After(3 * time.Second):
+		t.Fatal("msg should be received")
+	}
+
+	recv := sw2.NetInfo()
+	if len(recv.Modules) != 1 || recv.Modules[0].Module != libs.ConsensusModule || recv.Modules[0].Received <= wire.HeaderSize {
+		t.Fatalf("the received bytes should be attributed to the consensus, has: %+v", recv.Modules)
+	}
+	if len(recv.Peers) != 1 || recv.Peers[0].Peer != sw1.host.ID().Pretty() || recv.Peers[0].Outbound {
+		t.Fatalf("want the inbound peer %s, has: %+v", sw1.host.ID().Pretty(), recv.Peers)
+	}
+	if fmt.Sprint(recv.Peers[0].Modules) != fmt.Sprint(recv.Modules) {
+		t.Errorf("the only peer should account all bytes, want: %v, has: %v", recv.Modules, recv.Peers[0].Modules)
+	}
+
+	// the sender counts the frame once it's written, which may be after the peer got it
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		sent := sw1.NetInfo().Modules
+		if len(sent) == 1 && sent[0].Sent == recv.Modules[0].Received {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("want %d bytes sent by the consensus, has: %+v", recv.Modules[0].Received, sent)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
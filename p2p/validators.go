@@ -0,0 +1,54 @@
+package p2p
+
+// SetValidatorSet registers the current validators of the channel for BroadcastToValidators,
+// set returns their host names and is invoked on every broadcast, so it must not block.
+func (sw *Switch) SetValidatorSet(chID int32, set func() []string) {
+	sw.mtx.Lock()
+	defer sw.mtx.Unlock()
+
+	if sw.validatorSets == nil {
+		sw.validatorSets = make(map[int32]func() []string)
+	}
+	sw.validatorSets[chID] = set
+}
+
+// BroadcastToValidators sends the msg only to the connected peers which are the current validators
+// of the channel, the observers and the full nodes connected for the sync are skipped.
+// Every peer is a validator if no validator set is registered for the channel.
+// The slow validators are skipped if the msg is pulled by them instead, see SetPullOnSlow.
+func (sw *Switch) BroadcastToValidators(chID int32, msgBytes []byte) {
+	sw.mtx.Lock()
+	set := sw.validatorSets[chID]
+	sw.mtx.Unlock()
+
+	var validators map[string]bool
+	if set != nil {
+		validators = make(map[string]bool)
+		for _, v := range set() {
+			p2pID, err := sw.GetP2PID(v)
+			if err != nil {
+				continue
+			}
+			validators[p2pID] = true
+		}
+	}
+	targets := len(validators)
+	if validators == nil {
+		targets = len(sw.peers.List())
+	}
+	skipped := sw.skipSlow(chID, msgBytes, targets)
+	f := func(p Peer) bool {
+		if validators != nil && !validators[p.PeerID()] {
+			return true
+		}
+		if skipped[p.ID()] {
+			sw.log.Info("skip the slow peer @ BroadcastToValidators, peer_id: %s, channel: %d", p.PeerID(), chID)
+			return true
+		}
+		return p.Send(chID, msgBytes)
+	}
+	ch := sw.peers.Range(f)
+	<-ch
+
+	sw.msgs.Log("Broadcast to validators completed @ BroadcastToValidators, validators: %d", chID, msgBytes, len(validators))
+}
@@ -0,0 +1,44 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+func TestBroadcastToValidators(t *testing.T) {
+	sw := &Switch{peers: NewPeerSet(), log: logs.NewLogger()}
+	validator, observer := NewMockPeer(PeerID("validator"), true), NewMockPeer(PeerID("observer"), false)
+	for _, p := range []*MockPeer{validator, observer} {
+		if err := sw.peers.Add(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the sends are asynchronous
+	waitSent := func(p *MockPeer, n int) bool {
+		for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			if len(p.Sent(libs.ConsensusChannel)) >= n {
+				return true
+			}
+		}
+		return false
+	}
+
+	// every peer is reached without a validator set
+	sw.BroadcastToValidators(libs.ConsensusChannel, []byte("a"))
+	if !waitSent(validator, 1) || !waitSent(observer, 1) {
+		t.Fatalf("want broadcast to every peer")
+	}
+
+	sw.SetValidatorSet(libs.ConsensusChannel, func() []string { return []string{validator.PeerID()} })
+	sw.BroadcastToValidators(libs.ConsensusChannel, []byte("b"))
+	if !waitSent(validator, 2) {
+		t.Errorf("validator should receive the msg")
+	}
+	if len(observer.Sent(libs.ConsensusChannel)) != 1 {
+		t.Errorf("observer should be skipped")
+	}
+}
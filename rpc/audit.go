@@ -0,0 +1,154 @@
+package rpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Caller is the identity of an authenticated request, the api token is never kept but its fingerprint.
+type Caller struct {
+	// Token is the fingerprint of the api token, empty when the auth is disabled
+	Token  string
+	Role   Role
+	Remote string
+}
+
+func (c Caller) String() string {
+	if c.Token == "" {
+		return "anonymous"
+	}
+	return "token:" + c.Token
+}
+
+type callerKey struct{}
+
+// CallerFrom returns the caller of the request, which is set once the request passes the guard.
+func CallerFrom(r *http.Request) (Caller, bool) {
+	c, ok := r.Context().Value(callerKey{}).(Caller)
+	return c, ok
+}
+
+func withCaller(r *http.Request, c Caller) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), callerKey{}, c))
+}
+
+// fingerprint identifies the token in the logs without revealing it.
+func fingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:4])
+}
+
+// the results of the audit records
+const (
+	AuditOK           = "ok"
+	AuditFail         = "fail"
+	AuditUnauthorized = "unauthorized"
+	AuditForbidden    = "forbidden"
+)
+
+// AuditRecord is an invocation of an unsafe endpoint, the refused ones included.
+type AuditRecord struct {
+	Time   time.Time `json:"time"`
+	Path   string    `json:"path"`
+	Query  string    `json:"query,omitempty"`
+	Caller string    `json:"caller"`
+	Role   string    `json:"role,omitempty"`
+	Remote string    `json:"remote"`
+	Result string    `json:"result"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// auditLog appends the records to the file as json lines, each synced before the reply.
+type auditLog struct {
+	mtx  sync.Mutex
+	file *os.File
+}
+
+// openAuditLog opens the file for appending, empty path logs the records only.
+func openAuditLog(path string) (*auditLog, error) {
+	a := &auditLog{}
+	if path == "" {
+		return a, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	a.file = f
+	return a, nil
+}
+
+func (a *auditLog) record(r AuditRecord) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if a.file == nil {
+		return nil
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := a.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return a.file.Sync()
+}
+
+func (a *auditLog) close() error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
+
+// audited records every invocation of the handler along with its caller and its result.
+func (s *Server) audited(path string, h HandlerFunc) HandlerFunc {
+	return func(r *http.Request) (interface{}, error) {
+		caller, _ := CallerFrom(r)
+		res, err := h(r)
+		result := AuditOK
+		if err != nil {
+			result = AuditFail
+		}
+		s.audit(path, r, caller, result, err)
+		return res, err
+	}
+}
+
+func (s *Server) audit(path string, r *http.Request, caller Caller, result string, err error) {
+	rec := AuditRecord{
+		Time:   time.Now(),
+		Path:   path,
+		Query:  r.URL.RawQuery,
+		Caller: caller.String(),
+		Remote: r.RemoteAddr,
+		Result: result,
+	}
+	if result != AuditUnauthorized {
+		rec.Role = caller.Role.String()
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	s.log.Warn("rpc audit @ rpc.audit, path: %s, query: %s, caller: %s, role: %s, remote: %s, result: %s, err: %v",
+		rec.Path, rec.Query, rec.Caller, rec.Role, rec.Remote, rec.Result, err)
+	if err := s.auditLog.record(rec); err != nil {
+		s.log.Error("write audit record fail @ rpc.audit, record: %+v, err: %v", rec, err)
+	}
+}
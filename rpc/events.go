@@ -0,0 +1,100 @@
+package rpc
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	eventWriteTimeout = 10 * time.Second
+	eventPingInterval = 30 * time.Second
+	// a client missing the pongs for it is gone
+	eventPongTimeout = 2 * eventPingInterval
+)
+
+// EventMsg is an event of the bus as streamed to the websocket clients.
+type EventMsg struct {
+	Type      string      `json:"type"`
+	Module    string      `json:"module"`
+	Timestamp int64       `json:"timestamp"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+}
+
+// HandleEvents streams the events published on the bus to the websocket clients of the path as json,
+// the events are picked by the repeated type query, e.g. /events?type=peer_connected&type=peer_banned,
+// all of them are streamed without it. A client too slow to keep up loses the events like any subscriber.
+func (s *Server) HandleEvents(path string, role Role, bus libs.EventBus) error {
+	return s.Handle(path, role, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var types map[libs.EventType]bool
+		if q := r.URL.Query()["type"]; len(q) > 0 {
+			types = make(map[libs.EventType]bool, len(q))
+			for _, t := range q {
+				types[libs.EventType(t)] = true
+			}
+		}
+		// subscribe before the upgrade, so that the client gets every event published once it's connected
+		events := bus.Subscribe(libs.EventAll)
+		defer bus.Unsubscribe(events)
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// the upgrader has replied the error
+			s.log.Warn("upgrade websocket fail @ rpc.HandleEvents, remote: %s, err: %v", r.RemoteAddr, err)
+			return
+		}
+		defer conn.Close()
+		s.streamEvents(conn, events, types)
+	}))
+}
+
+// streamEvents writes the events of the types until the client or the server goes away,
+// the client is only read for its pongs and its close.
+func (s *Server) streamEvents(conn *websocket.Conn, events <-chan libs.Event, types map[libs.EventType]bool) {
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		conn.SetReadDeadline(time.Now().Add(eventPongTimeout))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(eventPongTimeout))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(eventPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case e := <-events:
+			if types != nil && !types[e.Type] {
+				continue
+			}
+			conn.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+			msg := EventMsg{Type: string(e.Type), Module: e.Module, Timestamp: e.Timestamp, Data: e.Data}
+			if err := conn.WriteJSON(msg); err != nil {
+				s.log.Warn("write event fail @ rpc.streamEvents, remote: %s, type: %s, err: %v", conn.RemoteAddr(), e.Type, err)
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(eventWriteTimeout)); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		case <-s.quit:
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server stopped"), time.Now().Add(eventWriteTimeout))
+			return
+		}
+	}
+}
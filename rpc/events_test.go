@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/gorilla/websocket"
+)
+
+func TestHandleEvents(t *testing.T) {
+	s, err := NewServer(&Config{Tokens: map[string]Role{"read": RoleReadOnly}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := libs.NewDefaultEventBus(nil)
+	s.HandleEvents("/events", RoleReadOnly, bus)
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/events?type=peer_connected&type=peer_banned"
+
+	if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("want the anonymous client refused, has: %v", err)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": {"Bearer read"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	bus.Publish(libs.Event{Type: libs.EventPeerDisconnected, Module: libs.P2PModule})
+	bus.Publish(libs.Event{Type: libs.EventPeerConnected, Module: libs.P2PModule,
+		Data: libs.PeerEventData{Peer: "a", Direction: "outbound", Validator: true}})
+	bus.Publish(libs.Event{Type: libs.EventPeerBanned, Module: libs.P2PModule, Data: libs.PeerEventData{Peer: "b"}})
+
+	for _, want := range []libs.EventType{libs.EventPeerConnected, libs.EventPeerBanned} {
+		var msg struct {
+			EventMsg
+			Data libs.PeerEventData `json:"data"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type != string(want) || msg.Module != libs.P2PModule || msg.Timestamp == 0 {
+			t.Errorf("want %s, has: %+v", want, msg)
+		}
+		if want == libs.EventPeerConnected && (msg.Data.Peer != "a" || !msg.Data.Validator) {
+			t.Errorf("want the data of the peer, has: %+v", msg.Data)
+		}
+	}
+
+	// the stream ends with the server
+	s.Stop()
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("want the going away close, has: %v", err)
+	}
+}
@@ -0,0 +1,422 @@
+// Package loadgen submits synthetic txs to a running network over the rpc of a node and measures
+// how fast they are committed, it's the engine of `gohotstuff loadgen` for the capacity tests.
+package loadgen
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mrand "math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/mempool"
+	"github.com/aucusaga/gohotstuff/rpc"
+)
+
+const (
+	DefaultConcurrency  = 8
+	DefaultPollInterval = 200 * time.Millisecond
+	DefaultCommitWait   = 30 * time.Second
+
+	// txPrefix marks the synthetic txs, it's followed by the run id and the sequence of the tx
+	txPrefix = "loadgen:"
+	// minTxSize fits the prefix, the run id and the sequence
+	minTxSize = len(txPrefix) + 16
+)
+
+var ErrInvalidSizeDist = errors.New("invalid size distribution")
+
+// SizeDist draws the sizes of the txs.
+type SizeDist struct {
+	kind     string
+	min, max int
+	mean     int
+}
+
+// ParseSizeDist parses the size distribution of the txs in bytes: `256` is fixed, `128-1024` is uniform
+// in the range and `exp:512` is exponential of the mean, the sizes are capped at 64 times the mean.
+func ParseSizeDist(s string) (SizeDist, error) {
+	atoi := func(v string) (int, error) {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("%w: %s", ErrInvalidSizeDist, s)
+		}
+		return n, nil
+	}
+	if strings.HasPrefix(s, "exp:") {
+		mean, err := atoi(strings.TrimPrefix(s, "exp:"))
+		return SizeDist{kind: "exp", mean: mean, min: minTxSize, max: 64 * mean}, err
+	}
+	if i := strings.Index(s, "-"); i > 0 {
+		lo, err := atoi(s[:i])
+		if err != nil {
+			return SizeDist{}, err
+		}
+		hi, err := atoi(s[i+1:])
+		if err != nil {
+			return SizeDist{}, err
+		}
+		if lo > hi {
+			return SizeDist{}, fmt.Errorf("%w: %s", ErrInvalidSizeDist, s)
+		}
+		return SizeDist{kind: "uniform", min: lo, max: hi}, nil
+	}
+	n, err := atoi(s)
+	return SizeDist{kind: "fixed", min: n, max: n}, err
+}
+
+func (d SizeDist) draw(r *mrand.Rand) int {
+	n := d.min
+	switch d.kind {
+	case "uniform":
+		n = d.min + r.Intn(d.max-d.min+1)
+	case "exp":
+		n = int(r.ExpFloat64() * float64(d.mean))
+		if n > d.max {
+			n = d.max
+		}
+	}
+	if n < minTxSize {
+		n = minTxSize
+	}
+	return n
+}
+
+func (d SizeDist) String() string {
+	switch d.kind {
+	case "uniform":
+		return fmt.Sprintf("%d-%d", d.min, d.max)
+	case "exp":
+		return fmt.Sprintf("exp:%d", d.mean)
+	}
+	return strconv.Itoa(d.min)
+}
+
+// Config is a run of the load generator.
+type Config struct {
+	// RPC is the rpc address of the node, e.g. http://127.0.0.1:26657
+	RPC string
+	// Token authorizes the requests, /broadcast_tx needs the operator role
+	Token string
+	Chain string
+	// Rate is the txs submitted per second
+	Rate     float64
+	Duration time.Duration
+	Size     SizeDist
+	// Concurrency is the number of the in-flight submissions, the ticks finding them all busy
+	// are skipped, i.e. the node can't take the rate
+	Concurrency int
+	// CommitWait is how long the submitted txs are waited for after the last submission,
+	// the ones not committed by then are dropped
+	CommitWait time.Duration
+	// PollInterval is the interval of polling the committed blocks
+	PollInterval time.Duration
+	Client       *http.Client
+}
+
+// Report is the outcome of a run, the latencies are from the submission to the poll finding the tx committed,
+// so they are overestimated by up to the PollInterval.
+type Report struct {
+	Submitted int64 `json:"submitted"`
+	// Rejected are refused by the node, e.g. by the full mempool
+	Rejected int64 `json:"rejected"`
+	// Skipped are the ticks finding every submission in flight
+	Skipped   int64 `json:"skipped"`
+	Committed int64 `json:"committed"`
+	// Dropped are accepted but not committed within the CommitWait
+	Dropped int64 `json:"dropped"`
+	Blocks  int64 `json:"blocks"`
+	Bytes   int64 `json:"bytes"`
+	// Throughput is the committed txs per second over the run
+	Throughput float64       `json:"throughput"`
+	Elapsed    time.Duration `json:"elapsed"`
+	P50        time.Duration `json:"p50"`
+	P90        time.Duration `json:"p90"`
+	P99        time.Duration `json:"p99"`
+	Max        time.Duration `json:"max"`
+}
+
+func (r *Report) String() string {
+	return fmt.Sprintf("submitted: %d, rejected: %d, skipped: %d, committed: %d, dropped: %d, blocks: %d, "+
+		"throughput: %.1f tx/s, latency p50: %v, p90: %v, p99: %v, max: %v",
+		r.Submitted, r.Rejected, r.Skipped, r.Committed, r.Dropped, r.Blocks,
+		r.Throughput, r.P50, r.P90, r.P99, r.Max)
+}
+
+type generator struct {
+	cfg   Config
+	runID []byte
+
+	mtx       sync.Mutex
+	pending   map[string]time.Time
+	latencies []time.Duration
+	report    Report
+	// next is the next height to poll
+	next int64
+}
+
+// Run submits the txs at the rate for the duration, waits for them to be committed and reports the run,
+// it stops early once ctx is done.
+func Run(ctx context.Context, cfg Config) (*Report, error) {
+	if cfg.Rate <= 0 || cfg.Duration <= 0 {
+		return nil, fmt.Errorf("rate and duration must be positive, rate: %v, duration: %v", cfg.Rate, cfg.Duration)
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = DefaultConcurrency
+	}
+	if cfg.CommitWait <= 0 {
+		cfg.CommitWait = DefaultCommitWait
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+	if cfg.Size.kind == "" {
+		cfg.Size = SizeDist{kind: "fixed", min: minTxSize, max: minTxSize}
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	cfg.RPC = strings.TrimSuffix(cfg.RPC, "/")
+	g := &generator{cfg: cfg, runID: make([]byte, 8), pending: make(map[string]time.Time)}
+	if _, err := rand.Read(g.runID); err != nil {
+		return nil, err
+	}
+	// the blocks committed before the run aren't polled
+	latest, err := g.latestHeight(ctx)
+	if err != nil {
+		return nil, err
+	}
+	g.next = latest + 1
+
+	start := time.Now()
+	polled := make(chan struct{})
+	pollCtx, stopPoll := context.WithCancel(ctx)
+	defer stopPoll()
+	go func() {
+		defer close(polled)
+		g.pollRoutine(pollCtx)
+	}()
+
+	g.submit(ctx)
+	// wait for the pending txs
+	deadline := time.Now().Add(cfg.CommitWait)
+	for time.Now().Before(deadline) && ctx.Err() == nil && g.pendingCount() > 0 {
+		time.Sleep(cfg.PollInterval)
+	}
+	stopPoll()
+	<-polled
+
+	return g.finish(time.Since(start)), nil
+}
+
+// submit sends the txs at the rate until the duration elapses.
+func (g *generator) submit(ctx context.Context) {
+	interval := time.Duration(float64(time.Second) / g.cfg.Rate)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	end := time.After(g.cfg.Duration)
+	slots := make(chan struct{}, g.cfg.Concurrency)
+	var wg sync.WaitGroup
+	r := mrand.New(mrand.NewSource(time.Now().UnixNano()))
+	var seq uint64
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-end:
+			wg.Wait()
+			return
+		case <-ticker.C:
+		}
+		select {
+		case slots <- struct{}{}:
+		default:
+			g.mtx.Lock()
+			g.report.Skipped++
+			g.mtx.Unlock()
+			continue
+		}
+		seq++
+		tx := g.newTx(r, seq)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			g.broadcast(ctx, tx)
+		}()
+	}
+}
+
+// newTx fills the tx of the drawn size, the run id and the sequence keep it unique.
+func (g *generator) newTx(r *mrand.Rand, seq uint64) []byte {
+	tx := make([]byte, g.cfg.Size.draw(r))
+	n := copy(tx, txPrefix)
+	n += copy(tx[n:], g.runID)
+	binary.BigEndian.PutUint64(tx[n:], seq)
+	r.Read(tx[n+8:])
+	return tx
+}
+
+func (g *generator) broadcast(ctx context.Context, tx []byte) {
+	// the tx is pending before it's sent, it may be committed before the reply
+	hash := mempool.Tx(tx).Key()
+	g.mtx.Lock()
+	g.pending[hash] = time.Now()
+	g.mtx.Unlock()
+
+	q := url.Values{"chain": {g.cfg.Chain}, "tx": {hex.EncodeToString(tx)}}
+	err := g.call(ctx, "/broadcast_tx?"+q.Encode(), nil)
+
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	if err != nil {
+		delete(g.pending, hash)
+		g.report.Rejected++
+		return
+	}
+	g.report.Submitted++
+	g.report.Bytes += int64(len(tx))
+}
+
+func (g *generator) pollRoutine(ctx context.Context) {
+	ticker := time.NewTicker(g.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// the last poll catches the blocks committed since the previous one
+			g.poll(context.Background())
+			return
+		case <-ticker.C:
+			g.poll(ctx)
+		}
+	}
+}
+
+// poll fetches the blocks committed since the last poll and settles their synthetic txs,
+// a failed poll is retried on the next tick.
+func (g *generator) poll(ctx context.Context) {
+	for {
+		q := url.Values{"chain": {g.cfg.Chain}, "from": {strconv.FormatInt(g.next, 10)},
+			"per_page": {strconv.Itoa(rpc.MaxPerPage)}}
+		var blocks []struct {
+			Height   int64    `json:"height"`
+			TxHashes []string `json:"tx_hashes"`
+		}
+		if err := g.call(ctx, "/blocks?"+q.Encode(), &rpc.Page{Items: &blocks}); err != nil {
+			return
+		}
+		now := time.Now()
+		g.mtx.Lock()
+		for _, b := range blocks {
+			g.report.Blocks++
+			for _, h := range b.TxHashes {
+				if sent, ok := g.pending[h]; ok {
+					delete(g.pending, h)
+					g.report.Committed++
+					g.latencies = append(g.latencies, now.Sub(sent))
+				}
+			}
+			g.next = b.Height + 1
+		}
+		g.mtx.Unlock()
+		if len(blocks) < rpc.MaxPerPage {
+			return
+		}
+	}
+}
+
+func (g *generator) latestHeight(ctx context.Context) (int64, error) {
+	q := url.Values{"chain": {g.cfg.Chain}, "per_page": {"1"}, "order": {rpc.OrderDesc}}
+	var blocks []struct {
+		Height int64 `json:"height"`
+	}
+	if err := g.call(ctx, "/blocks?"+q.Encode(), &rpc.Page{Items: &blocks}); err != nil {
+		return 0, err
+	}
+	if len(blocks) == 0 {
+		return 0, nil
+	}
+	return blocks[0].Height, nil
+}
+
+func (g *generator) pendingCount() int {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	return len(g.pending)
+}
+
+func (g *generator) finish(elapsed time.Duration) *Report {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	r := g.report
+	r.Dropped = int64(len(g.pending))
+	r.Elapsed = elapsed
+	if elapsed > 0 {
+		r.Throughput = float64(r.Committed) / elapsed.Seconds()
+	}
+	lat := append([]time.Duration{}, g.latencies...)
+	sort.Slice(lat, func(i, j int) bool { return lat[i] < lat[j] })
+	r.P50, r.P90, r.P99 = percentile(lat, 0.5), percentile(lat, 0.9), percentile(lat, 0.99)
+	if len(lat) > 0 {
+		r.Max = lat[len(lat)-1]
+	}
+	return &r
+}
+
+// percentile returns the nearest-rank percentile of the sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p*float64(len(sorted))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// call invokes the endpoint of the node and decodes the result of the json envelope into res.
+func (g *generator) call(ctx context.Context, path string, res interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, g.cfg.RPC+path, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if g.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.cfg.Token)
+	}
+	resp, err := g.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	envelope := rpc.Response{Result: res}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("decode reply fail, status: %s, err: %v", resp.Status, err)
+	}
+	if envelope.Error != "" {
+		return fmt.Errorf("rpc fail, status: %s, err: %s", resp.Status, envelope.Error)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	mrand "math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/mempool"
+	"github.com/aucusaga/gohotstuff/rpc"
+)
+
+// fakeNode commits the accepted txs into a block on every poll, except the txs larger than maxSize,
+// which are refused, and every third accepted tx, which is never committed.
+type fakeNode struct {
+	maxSize int
+
+	mtx      sync.Mutex
+	accepted int
+	pending  []string
+	blocks   []map[string]interface{}
+}
+
+func (f *fakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	reply := func(res interface{}, err error) {
+		resp := &rpc.Response{Result: res}
+		if err != nil {
+			resp = &rpc.Response{Error: err.Error()}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}
+	q := r.URL.Query()
+	switch r.URL.Path {
+	case "/broadcast_tx":
+		tx, _ := hex.DecodeString(q.Get("tx"))
+		if len(tx) > f.maxSize {
+			reply(nil, errors.New("tx too large"))
+			return
+		}
+		f.accepted++
+		if f.accepted%3 != 0 {
+			f.pending = append(f.pending, mempool.Tx(tx).Key())
+		}
+		reply(map[string]string{"hash": mempool.Tx(tx).Key()}, nil)
+	case "/blocks":
+		if len(f.pending) > 0 {
+			f.blocks = append(f.blocks, map[string]interface{}{"height": len(f.blocks) + 1, "tx_hashes": f.pending})
+			f.pending = nil
+		}
+		from, _ := strconv.Atoi(q.Get("from"))
+		list := []map[string]interface{}{}
+		if q.Get("order") == rpc.OrderDesc {
+			list = append(list, f.blocks[len(f.blocks)-1])
+		} else {
+			for i := from - 1; i >= 0 && i < len(f.blocks); i++ {
+				list = append(list, f.blocks[i])
+			}
+		}
+		reply(&rpc.Page{Items: list, Total: len(list)}, nil)
+	}
+}
+
+func TestParseSizeDist(t *testing.T) {
+	r := mrand.New(mrand.NewSource(1))
+	for _, c := range []struct {
+		s        string
+		min, max int
+	}{
+		{"256", 256, 256},
+		{"100-200", 100, 200},
+		{"1", minTxSize, minTxSize},
+		{"exp:64", minTxSize, 64 * 64},
+	} {
+		d, err := ParseSizeDist(c.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.String() != c.s {
+			t.Errorf("want %s, has: %s", c.s, d)
+		}
+		for i := 0; i < 100; i++ {
+			if n := d.draw(r); n < c.min || n > c.max {
+				t.Errorf("dist %s, size %d out of [%d, %d]", c.s, n, c.min, c.max)
+			}
+		}
+	}
+	for _, s := range []string{"", "0", "200-100", "exp:", "a-b"} {
+		if _, err := ParseSizeDist(s); !errors.Is(err, ErrInvalidSizeDist) {
+			t.Errorf("dist %q, want invalid size distribution err, has: %v", s, err)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	node := &fakeNode{maxSize: 150, blocks: []map[string]interface{}{{"height": 1, "tx_hashes": []string{"genesis"}}}}
+	srv := httptest.NewServer(node)
+	defer srv.Close()
+
+	size, _ := ParseSizeDist("100-200")
+	report, err := Run(context.Background(), Config{
+		RPC:          srv.URL,
+		Rate:         200,
+		Duration:     300 * time.Millisecond,
+		Size:         size,
+		CommitWait:   100 * time.Millisecond,
+		PollInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Submitted == 0 || report.Rejected == 0 {
+		t.Fatalf("the txs should be both accepted and refused, has: %s", report)
+	}
+	if dropped := report.Submitted / 3; report.Dropped != dropped || report.Committed != report.Submitted-dropped {
+		t.Errorf("every third accepted tx should be dropped, has: %s", report)
+	}
+	if report.P50 <= 0 || report.P50 > report.P99 || report.P99 > report.Max || report.Throughput <= 0 {
+		t.Errorf("unexpected latencies, has: %s", report)
+	}
+	if report.Blocks == 0 || int(report.Blocks) != len(node.blocks)-1 {
+		t.Errorf("the blocks before the run shouldn't be counted, has: %d, blocks: %d", report.Blocks, len(node.blocks))
+	}
+
+	if _, err := Run(context.Background(), Config{RPC: srv.URL}); err == nil {
+		t.Errorf("want the run without the rate refused")
+	}
+}
@@ -0,0 +1,74 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	DefaultPerPage = 30
+	MaxPerPage     = 100
+
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+var (
+	ErrInvalidPage = errors.New("invalid pagination params")
+)
+
+// Page is the result of the list endpoints, Items is the requested page of the whole list,
+// which is sorted by the key of the endpoint, e.g. the height, in the requested order.
+type Page struct {
+	Items   interface{} `json:"items"`
+	Total   int         `json:"total"`
+	Page    int         `json:"page"`
+	PerPage int         `json:"per_page"`
+	Order   string      `json:"order"`
+	// Height is the height which the list is taken at, it's set by the endpoints of a height, e.g. /validators.
+	Height int64 `json:"height,omitempty"`
+}
+
+// Paginate parses the page (1-based), per_page and order (asc | desc) params of the request,
+// and returns the indexes of the page within the total items sorted ascending, in the requested order.
+// A page beyond the last one is empty, per_page is capped at MaxPerPage.
+func Paginate(r *http.Request, total int) (*Page, []int, error) {
+	q := r.URL.Query()
+	p := &Page{Total: total, Page: 1, PerPage: DefaultPerPage, Order: OrderAsc}
+	for key, v := range map[string]*int{"page": &p.Page, "per_page": &p.PerPage} {
+		s := q.Get(key)
+		if s == "" {
+			continue
+		}
+		i, err := strconv.Atoi(s)
+		if err != nil || i < 1 {
+			return nil, nil, fmt.Errorf("%w, %s must be a positive integer, has: %s", ErrInvalidPage, key, s)
+		}
+		*v = i
+	}
+	if p.PerPage > MaxPerPage {
+		p.PerPage = MaxPerPage
+	}
+	switch o := q.Get("order"); o {
+	case "", OrderAsc:
+	case OrderDesc:
+		p.Order = OrderDesc
+	default:
+		return nil, nil, fmt.Errorf("%w, order must be asc or desc, has: %s", ErrInvalidPage, o)
+	}
+
+	idx := []int{}
+	if p.Page-1 > total/p.PerPage {
+		return p, idx, nil
+	}
+	for n := (p.Page - 1) * p.PerPage; n < total && len(idx) < p.PerPage; n++ {
+		if p.Order == OrderDesc {
+			idx = append(idx, total-1-n)
+		} else {
+			idx = append(idx, n)
+		}
+	}
+	return p, idx, nil
+}
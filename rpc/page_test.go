@@ -0,0 +1,45 @@
+package rpc
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	cases := []struct {
+		query string
+		total int
+		want  []int
+	}{
+		{"", 3, []int{0, 1, 2}},
+		{"?per_page=2", 5, []int{0, 1}},
+		{"?per_page=2&page=3", 5, []int{4}},
+		{"?per_page=2&page=4", 5, []int{}},
+		{"?per_page=2&order=desc", 5, []int{4, 3}},
+		{"?per_page=2&page=3&order=desc", 5, []int{0}},
+		{"?page=9223372036854775807", 5, []int{}},
+		{"", 0, []int{}},
+	}
+	for _, c := range cases {
+		page, idx, err := Paginate(httptest.NewRequest(http.MethodGet, "/blocks"+c.query, nil), c.total)
+		if err != nil {
+			t.Fatalf("query: %s, err: %v", c.query, err)
+		}
+		if !reflect.DeepEqual(idx, c.want) || page.Total != c.total {
+			t.Errorf("query: %s, want: %v, has: %v, page: %+v", c.query, c.want, idx, page)
+		}
+	}
+
+	page, idx, _ := Paginate(httptest.NewRequest(http.MethodGet, "/blocks?per_page=1000", nil), 1000)
+	if page.PerPage != MaxPerPage || len(idx) != MaxPerPage {
+		t.Errorf("want per_page capped, has: %d, items: %d", page.PerPage, len(idx))
+	}
+	for _, q := range []string{"?page=0", "?per_page=-1", "?page=x", "?order=random"} {
+		if _, _, err := Paginate(httptest.NewRequest(http.MethodGet, "/blocks"+q, nil), 10); !errors.Is(err, ErrInvalidPage) {
+			t.Errorf("query: %s, want invalid page, has: %v", q, err)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+const (
+	defaultListenAddress   = "127.0.0.1:26657"
+	defaultShutdownTimeout = 3 * time.Second
+
+	// UnsafePrefix marks the endpoints which can change the state of the node,
+	// they always require the admin role.
+	UnsafePrefix = "/unsafe_"
+)
+
+var (
+	ErrUnauthorized  = errors.New("missing or unknown api token")
+	ErrForbidden     = errors.New("role is not allowed to access the endpoint")
+	ErrExposedNoAuth = errors.New("rpc can't listen beyond localhost without api tokens")
+)
+
+// Role is the access level of an api token, a higher role includes the lower ones.
+type Role int
+
+const (
+	RoleReadOnly Role = iota
+	RoleOperator
+	RoleAdmin
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleReadOnly:
+		return "readonly"
+	case RoleOperator:
+		return "operator"
+	case RoleAdmin:
+		return "admin"
+	}
+	return fmt.Sprintf("role(%d)", int(r))
+}
+
+// ParseRole parses the role name used in the config file.
+func ParseRole(s string) (Role, error) {
+	switch strings.ToLower(s) {
+	case "readonly", "read-only", "read":
+		return RoleReadOnly, nil
+	case "operator":
+		return RoleOperator, nil
+	case "admin":
+		return RoleAdmin, nil
+	}
+	return 0, fmt.Errorf("unknown rpc role: %s", s)
+}
+
+// HandlerFunc serves an endpoint, the result is encoded as json.
+type HandlerFunc func(r *http.Request) (interface{}, error)
+
+// Response is the json envelope of all endpoints.
+type Response struct {
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// Server exposes the node over http(s), every endpoint is guarded by the role
+// mapped from the api token in the `Authorization: Bearer <token>` header.
+type Server struct {
+	cfg *Config
+
+	mux    *http.ServeMux
+	srv    *http.Server
+	routes map[string]Role
+	mtx    sync.Mutex
+
+	// auditLog records the invocations of the unsafe endpoints
+	auditLog *auditLog
+	// quit ends the event streams, whose hijacked conns are left by the shutdown
+	quit chan struct{}
+	log  libs.Logger
+}
+
+func NewServer(cfg *Config, logger libs.Logger) (*Server, error) {
+	if logger == nil {
+		logger = logs.NewLogger()
+	}
+	if cfg.ListenAddress == "" {
+		cfg.ListenAddress = defaultListenAddress
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, fmt.Errorf("both tls cert and key are required @ rpc.NewServer, cert: %s, key: %s",
+			cfg.TLSCertFile, cfg.TLSKeyFile)
+	}
+	audit, err := openAuditLog(cfg.AuditPath)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log fail @ rpc.NewServer, path: %s, err: %v", cfg.AuditPath, err)
+	}
+	s := &Server{
+		cfg:      cfg,
+		mux:      http.NewServeMux(),
+		routes:   make(map[string]Role),
+		auditLog: audit,
+		quit:     make(chan struct{}),
+		log:      logger,
+	}
+	s.srv = &http.Server{Handler: s.mux}
+	return s, nil
+}
+
+// Register binds the handler to the path, the endpoints prefixed with UnsafePrefix
+// always require the admin role and every invocation of them is audited.
+func (s *Server) Register(path string, role Role, h HandlerFunc) error {
+	if strings.HasPrefix(path, UnsafePrefix) {
+		h = s.audited(path, h)
+	}
+	return s.Handle(path, role, envelope(h))
+}
+
+// Handle binds a raw http handler to the path, which doesn't reply the json envelope,
+// e.g. the prometheus metrics.
+func (s *Server) Handle(path string, role Role, h http.Handler) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if _, ok := s.routes[path]; ok {
+		return fmt.Errorf("path has been registered before, path: %s", path)
+	}
+	if strings.HasPrefix(path, UnsafePrefix) {
+		role = RoleAdmin
+	}
+	s.routes[path] = role
+	s.mux.HandleFunc(path, s.guard(path, role, h.ServeHTTP))
+	return nil
+}
+
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.cfg.ListenAddress)
+	if err != nil {
+		s.log.Error("listen fail @ rpc.Start, addr: %s, err: %v", s.cfg.ListenAddress, err)
+		return err
+	}
+	// every caller is an admin without the tokens, which only the local ones may be
+	if !s.authEnabled() && !isLoopback(ln.Addr()) {
+		ln.Close()
+		s.log.Error("rpc is exposed beyond localhost without api tokens @ rpc.Start, addr: %s", ln.Addr())
+		return fmt.Errorf("%w, addr: %s", ErrExposedNoAuth, ln.Addr())
+	}
+
+	go func() {
+		var err error
+		if s.cfg.TLSCertFile != "" {
+			err = s.srv.ServeTLS(ln, s.cfg.TLSCertFile, s.cfg.TLSKeyFile)
+		} else {
+			err = s.srv.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			s.log.Error("rpc server meets end @ rpc.Start, err: %v", err)
+		}
+	}()
+	s.log.Info("rpc server started @ rpc.Start, addr: %s, tls: %v, auth: %v",
+		ln.Addr(), s.cfg.TLSCertFile != "", s.authEnabled())
+	return nil
+}
+
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+	err := s.srv.Shutdown(ctx)
+	close(s.quit)
+	s.auditLog.close()
+	return err
+}
+
+// Handler returns the http handler, which helps to test or embed the server.
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// envelope replies the result of the handler in the json envelope.
+func envelope(h HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res, err := h(r)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, &Response{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, &Response{Result: res})
+	}
+}
+
+// guard rejects the requests whose role is lower than the wanted one, the caller is passed
+// to the handler in the context of the request, see CallerFrom.
+func (s *Server) guard(path string, want Role, next http.HandlerFunc) http.HandlerFunc {
+	unsafe := strings.HasPrefix(path, UnsafePrefix)
+	return func(w http.ResponseWriter, r *http.Request) {
+		caller, err := s.authenticate(r)
+		if err != nil {
+			s.log.Warn("rpc unauthorized @ rpc.serve, path: %s, remote: %s", path, r.RemoteAddr)
+			if unsafe {
+				s.audit(path, r, caller, AuditUnauthorized, err)
+			}
+			writeJSON(w, http.StatusUnauthorized, &Response{Error: err.Error()})
+			return
+		}
+		if caller.Role < want {
+			s.log.Warn("rpc forbidden @ rpc.serve, path: %s, remote: %s, role: %s, want: %s", path, r.RemoteAddr, caller.Role, want)
+			if unsafe {
+				s.audit(path, r, caller, AuditForbidden, ErrForbidden)
+			}
+			writeJSON(w, http.StatusForbidden, &Response{Error: ErrForbidden.Error()})
+			return
+		}
+		next(w, withCaller(r, caller))
+	}
+}
+
+// authenticate returns the caller of the request, everyone is an anonymous admin when no token is configured,
+// which Start only allows on a loopback address.
+// The fingerprint of an unknown token is returned along with ErrUnauthorized.
+func (s *Server) authenticate(r *http.Request) (Caller, error) {
+	caller := Caller{Remote: r.RemoteAddr}
+	if !s.authEnabled() {
+		caller.Role = RoleAdmin
+		return caller, nil
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return caller, ErrUnauthorized
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+	caller.Token = fingerprint(token)
+	for t, role := range s.cfg.Tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			caller.Role = role
+			return caller, nil
+		}
+	}
+	return caller, ErrUnauthorized
+}
+
+func (s *Server) authEnabled() bool {
+	return len(s.cfg.Tokens) > 0
+}
+
+// WriteError replies the err in the json envelope, e.g. by the raw handlers failing before they reply.
+func WriteError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, &Response{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, resp *Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func isLoopback(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	return ok && tcpAddr.IP.IsLoopback()
+}
+
+// ---------------------------------------------------------------------------------------------------
+type Config struct {
+	ListenAddress string
+	// TLSCertFile and TLSKeyFile enable https when both are set.
+	TLSCertFile string
+	TLSKeyFile  string
+	// Tokens maps the api tokens to their roles, auth is disabled when it's empty, which requires
+	// a loopback ListenAddress.
+	Tokens map[string]Role
+	// AuditPath appends the invocations of the unsafe endpoints to the file as json lines,
+	// they are only logged when it's empty.
+	AuditPath string
+}
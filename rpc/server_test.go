@@ -0,0 +1,152 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServerRoles(t *testing.T) {
+	s, err := NewServer(&Config{
+		Tokens: map[string]Role{
+			"read":  RoleReadOnly,
+			"op":    RoleOperator,
+			"admin": RoleAdmin,
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok := func(r *http.Request) (interface{}, error) { return "ok", nil }
+	s.Register("/status", RoleReadOnly, ok)
+	s.Register("/broadcast_tx", RoleOperator, ok)
+	// unsafe endpoints are promoted to admin
+	s.Register("/unsafe_dial_peers", RoleReadOnly, ok)
+
+	cases := []struct {
+		path  string
+		token string
+		code  int
+	}{
+		{"/status", "", http.StatusUnauthorized},
+		{"/status", "unknown", http.StatusUnauthorized},
+		{"/status", "read", http.StatusOK},
+		{"/broadcast_tx", "read", http.StatusForbidden},
+		{"/broadcast_tx", "op", http.StatusOK},
+		{"/unsafe_dial_peers", "op", http.StatusForbidden},
+		{"/unsafe_dial_peers", "admin", http.StatusOK},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, req)
+		if w.Code != c.code {
+			t.Errorf("path: %s, token: %s, want: %d, has: %d", c.path, c.token, c.code, w.Code)
+		}
+	}
+}
+
+func TestServerNoAuth(t *testing.T) {
+	s, err := NewServer(&Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Register("/unsafe_dial_peers", RoleAdmin, func(r *http.Request) (interface{}, error) { return nil, nil })
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unsafe_dial_peers", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("want: %d, has: %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestServerExposedNoAuth(t *testing.T) {
+	for _, c := range []struct {
+		addr   string
+		tokens map[string]Role
+		want   error
+	}{
+		{"0.0.0.0:0", nil, ErrExposedNoAuth},
+		{"0.0.0.0:0", map[string]Role{"admin": RoleAdmin}, nil},
+		{"127.0.0.1:0", nil, nil},
+	} {
+		s, err := NewServer(&Config{ListenAddress: c.addr, Tokens: c.tokens}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.Start()
+		if !errors.Is(err, c.want) {
+			t.Errorf("addr: %s, tokens: %d, want: %v, has: %v", c.addr, len(c.tokens), c.want, err)
+		}
+		if err == nil {
+			s.Stop()
+		}
+	}
+}
+
+func TestServerAudit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "rpc.log")
+	s, err := NewServer(&Config{
+		Tokens:    map[string]Role{"op": RoleOperator, "admin": RoleAdmin},
+		AuditPath: path,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var callers []Caller
+	s.Register("/status", RoleReadOnly, func(r *http.Request) (interface{}, error) { return nil, nil })
+	s.Register("/unsafe_force_view_change", RoleAdmin, func(r *http.Request) (interface{}, error) {
+		c, _ := CallerFrom(r)
+		callers = append(callers, c)
+		return nil, errors.New("halted")
+	})
+
+	for _, c := range []struct{ path, token string }{
+		{"/status", "admin"},
+		{"/unsafe_force_view_change?chain=", ""},
+		{"/unsafe_force_view_change?chain=", "op"},
+		{"/unsafe_force_view_change?chain=", "admin"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		s.Handler().ServeHTTP(httptest.NewRecorder(), req)
+	}
+	s.Stop()
+
+	if len(callers) != 1 || callers[0].Role != RoleAdmin || callers[0].Token != fingerprint("admin") {
+		t.Fatalf("want the admin passed to the handler, has: %+v", callers)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var rec AuditRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Path != "/unsafe_force_view_change" || rec.Query != "chain=" {
+			t.Errorf("only the unsafe endpoint should be audited, has: %+v", rec)
+		}
+		results = append(results, rec.Caller+"/"+rec.Result)
+	}
+	want := []string{
+		"anonymous/" + AuditUnauthorized,
+		"token:" + fingerprint("op") + "/" + AuditForbidden,
+		"token:" + fingerprint("admin") + "/" + AuditFail,
+	}
+	if strings.Join(results, ",") != strings.Join(want, ",") {
+		t.Errorf("want: %v, has: %v", want, results)
+	}
+}
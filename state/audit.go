@@ -0,0 +1,200 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/aucusaga/gohotstuff/crypto"
+	"github.com/aucusaga/gohotstuff/storage"
+)
+
+var (
+	ErrNoBlockStore      = errors.New("committed blocks are not persisted")
+	ErrBlockHashMismatch = errors.New("committed block mismatches the hash")
+	ErrQuorumNotReached  = errors.New("qc isn't signed by more than 2/3 validators")
+)
+
+// Auditor re-verifies the committed blocks of a store independently of the node which committed them,
+// the validator set of every block is reconstructed by the election from the genesis validators.
+type Auditor struct {
+	Blocks   storage.BlockStorage
+	Election ProposerElection
+	// StartRound is the genesis round, the QCs up to it aren't signed by anyone.
+	StartRound int64
+	// Deserialize decodes the QCs, DefaultDeserialize if it's nil.
+	Deserialize func(input []byte) (QuorumCert, error)
+	// ChainID and ForkID are the sign domain of the chain, the epoch of a round is told by the Election
+	// if it's an EpochElection.
+	ChainID string
+	ForkID  string
+	// Crypto verifies the signed votes and timeouts carried by the QCs, a DefaultCryptoClient if it's nil.
+	Crypto crypto.CryptoClient
+	// Keys returns the key registered for the validator at the round, see State.ValidatorKey,
+	// the keys carried by the signed msgs are trusted if it's nil or none is registered.
+	Keys func(round int64, v PeerID) ([]byte, bool)
+}
+
+// BlockVerification is the evidence of a verified block.
+type BlockVerification struct {
+	Height     int64    `json:"height"`
+	ID         []byte   `json:"id"`
+	Proposer   string   `json:"proposer"`
+	Validators []PeerID `json:"validators"`
+	Signers    []PeerID `json:"signers"`
+}
+
+// VerifyCommittedBlock checks the stored block of the height has the hash, is certified by its QC
+// and extends its parent if the parent is stored, and that its QC is signed by a quorum of the validators
+// of the height. The signers are the validators the QC carries the signatures of, see QuorumCert.Signatures.
+func (a *Auditor) VerifyCommittedBlock(height int64, blockHash []byte) (*BlockVerification, error) {
+	b, err := a.Blocks.Load(height)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(b.ID, blockHash) {
+		return nil, fmt.Errorf("%w, height: %d, stored: %x, want: %x", ErrBlockHashMismatch, height, b.ID, blockHash)
+	}
+	parent, err := a.Blocks.Load(b.ParentRound)
+	if errors.Is(err, storage.ErrBlockNotFound) {
+		// the anchor of an imported chain or the genesis
+		parent, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a.verify(parent, b)
+}
+
+// VerifyChain verifies the stored blocks in [from, to], to <= 0 means the latest one, and that every block
+// extends the previous one. It stops at the first invalid block and returns the number of the verified ones.
+func (a *Auditor) VerifyChain(from, to int64, f func(*BlockVerification)) (int, error) {
+	heights, err := a.Blocks.Heights(from, to)
+	if err != nil {
+		return 0, err
+	}
+	var prev *storage.CommittedBlock
+	if len(heights) > 0 {
+		if prev, err = a.Blocks.Load(heights[0]); err != nil {
+			return 0, err
+		}
+		if prev, err = a.Blocks.Load(prev.ParentRound); errors.Is(err, storage.ErrBlockNotFound) {
+			prev, err = nil, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	for n, h := range heights {
+		b, err := a.Blocks.Load(h)
+		if err != nil {
+			return n, err
+		}
+		v, err := a.verify(prev, b)
+		if err != nil {
+			return n, err
+		}
+		if f != nil {
+			f(v)
+		}
+		prev = b
+	}
+	return len(heights), nil
+}
+
+// ScrubBlock re-reads the stored block of the height and checks what the stored data alone can tell:
+// its checksum, see storage.ErrBlockCorrupted, that its id is the hash of its content and agrees with its QC,
+// and that it extends its stored parent. A missing or corrupted parent is blamed on its own, so that the block
+// itself is only checked against its QC. The signatures are left to VerifyCommittedBlock, the QCs stored before
+// they carried the signed votes would never pass them and no peer could repair them.
+func (a *Auditor) ScrubBlock(height int64) error {
+	b, err := a.Blocks.Load(height)
+	if err != nil {
+		return err
+	}
+	parent, err := a.Blocks.Load(b.ParentRound)
+	if err != nil {
+		parent = nil
+	}
+	return verifyCommittedBlock(a.deserialize(), parent, b)
+}
+
+// Scrub checks every stored block in [from, to], to <= 0 means the latest one, with ScrubBlock. Unlike
+// VerifyChain it goes on past the invalid blocks, which are reported to f, and returns the number of the blocks checked.
+func (a *Auditor) Scrub(from, to int64, f func(height int64, err error)) (int, error) {
+	heights, err := a.Blocks.Heights(from, to)
+	if err != nil {
+		return 0, err
+	}
+	for _, h := range heights {
+		if err := a.ScrubBlock(h); err != nil && f != nil {
+			f(h, err)
+		}
+	}
+	return len(heights), nil
+}
+
+func (a *Auditor) deserialize() func(input []byte) (QuorumCert, error) {
+	if a.Deserialize == nil {
+		return DefaultDeserialize
+	}
+	return a.Deserialize
+}
+
+func (a *Auditor) verifier() *qcVerifier {
+	qv := &qcVerifier{crypto: a.Crypto, chainID: a.ChainID, forkID: a.ForkID, key: a.Keys}
+	if qv.crypto == nil {
+		qv.crypto = &crypto.DefaultCryptoClient{}
+	}
+	if e, ok := a.Election.(EpochElection); ok {
+		qv.epoch = e.Epoch
+	}
+	return qv
+}
+
+func (a *Auditor) verify(prev, b *storage.CommittedBlock) (*BlockVerification, error) {
+	deserialize := a.deserialize()
+	if err := verifyCommittedBlock(deserialize, prev, b); err != nil {
+		return nil, err
+	}
+	qc, err := deserialize(b.QC)
+	if err != nil {
+		return nil, err
+	}
+	v := &BlockVerification{
+		Height:     b.Height,
+		ID:         b.ID,
+		Proposer:   b.Proposer,
+		Validators: a.Election.Validators(b.Height, nil),
+	}
+	v.Signers = a.verifier().signers(qc, v.Validators)
+	proposer := false
+	for _, validator := range v.Validators {
+		proposer = proposer || string(validator) == b.Proposer
+	}
+	if !proposer {
+		return nil, fmt.Errorf("%w, proposer isn't a validator, height: %d, proposer: %s", ErrBrokenQCChain, b.Height, b.Proposer)
+	}
+	if b.Height > a.StartRound && len(v.Signers) <= len(v.Validators)*2/3 {
+		return nil, fmt.Errorf("%w, height: %d, signers: %d, validators: %d", ErrQuorumNotReached, b.Height, len(v.Signers), len(v.Validators))
+	}
+	return v, nil
+}
+
+// VerifyCommittedBlock re-verifies the committed block of the height against the validators it was committed by.
+func (s *State) VerifyCommittedBlock(height int64, blockHash []byte) (*BlockVerification, error) {
+	if s.blocks == nil {
+		return nil, ErrNoBlockStore
+	}
+	return s.auditor().VerifyCommittedBlock(height, blockHash)
+}
+
+// auditor verifies the stored blocks against the validators they were committed by.
+func (s *State) auditor() *Auditor {
+	a := &Auditor{Blocks: s.blocks, Election: s.election, StartRound: s.cfg.StartRound,
+		ChainID: s.cfg.ChainID, ForkID: s.cfg.ForkID, Crypto: s.crypto, Keys: s.ValidatorKey}
+	if s.tree != nil {
+		a.Deserialize = s.tree.DeserializeF
+	}
+	return a
+}
@@ -0,0 +1,153 @@
+package state_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/state"
+	"github.com/aucusaga/gohotstuff/state/statetest"
+	"github.com/aucusaga/gohotstuff/storage"
+)
+
+// committedChain runs a network of 4 validators on the chain "test" until the first node commits the height,
+// and returns the validators and the blocks committed by the node, whose QCs carry the signed votes.
+// The last validator is isolated from the others after the first blocks if partitioned is set.
+func committedChain(t *testing.T, height int64, partitioned bool) ([]statetest.Validator, []*storage.CommittedBlock) {
+	vs, err := statetest.NewValidatorSet(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	net, err := statetest.NewNetwork(statetest.Genesis("test", vs), vs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	net.Start()
+	defer net.Stop()
+
+	nodes := net.Nodes()
+	if partitioned {
+		if err := net.Run(func() bool { return nodes[0].Height() >= 3 }, 10*time.Second); err != nil {
+			t.Fatalf("the network should commit, height: %d", nodes[0].Height())
+		}
+		net.Partition(statetest.IDs(vs[:3]))
+	}
+	if err := net.Run(func() bool { return nodes[0].Height() >= height }, 10*time.Second); err != nil {
+		t.Fatalf("the network should commit, height: %d", nodes[0].Height())
+	}
+	return vs, nodes[0].Committed()
+}
+
+func storeBlocks(t *testing.T, committed []*storage.CommittedBlock) *storage.BlockStore {
+	blocks, err := storage.NewBlockStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range committed {
+		if err := blocks.Save(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return blocks
+}
+
+func TestAuditor(t *testing.T) {
+	vs, committed := committedChain(t, 5, false)
+	blocks := storeBlocks(t, committed)
+	newAuditor := func(blocks storage.BlockStorage) *state.Auditor {
+		return &state.Auditor{Blocks: blocks, Election: state.NewDefaultElection(0, statetest.IDs(vs)), ChainID: "test"}
+	}
+	a := newAuditor(blocks)
+
+	last := committed[len(committed)-1]
+	v, err := a.VerifyCommittedBlock(last.Height, last.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Validators) != 4 || len(v.Signers) < 3 {
+		t.Errorf("unexpected evidence: %+v", v)
+	}
+	if _, err := a.VerifyCommittedBlock(last.Height, []byte{4}); !errors.Is(err, state.ErrBlockHashMismatch) {
+		t.Errorf("want hash mismatch, has: %v", err)
+	}
+	var verified []int64
+	n, err := a.VerifyChain(0, 0, func(v *state.BlockVerification) { verified = append(verified, v.Height) })
+	if n != len(committed) || err != nil {
+		t.Fatalf("want the committed chain verified, has: %d of %d, err: %v", n, len(committed), err)
+	}
+	if verified[len(verified)-1] != last.Height {
+		t.Errorf("unexpected verified blocks: %v", verified)
+	}
+
+	// the signatures are verified against the domain of the chain
+	other := newAuditor(blocks)
+	other.ChainID = "other"
+	if _, err := other.VerifyCommittedBlock(last.Height, last.ID); !errors.Is(err, state.ErrQuorumNotReached) {
+		t.Errorf("want no quorum on another chain, has: %v", err)
+	}
+
+	// the votes of a QC can't be taken by another block, nor the QC keep the votes of too few signers
+	forge := func(b *storage.CommittedBlock, f func(qc *state.DefaultQuorumCert)) *storage.CommittedBlock {
+		raw, err := state.DefaultDeserialize(b.QC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		qc := raw.(state.DefaultQuorumCert)
+		f(&qc)
+		forged := *b
+		forged.QC, _ = qc.Serialize()
+		return &forged
+	}
+	prev := committed[len(committed)-2]
+	stolen := forge(last, func(qc *state.DefaultQuorumCert) {
+		prevQC, _ := state.DefaultDeserialize(prev.QC)
+		qc.Signs = prevQC.(state.DefaultQuorumCert).Signs
+	})
+	few := forge(last, func(qc *state.DefaultQuorumCert) {
+		for _, id := range statetest.IDs(vs)[:2] {
+			delete(qc.Signs, string(id))
+		}
+	})
+	for name, b := range map[string]*storage.CommittedBlock{"stolen": stolen, "few": few} {
+		forged := storeBlocks(t, append(append([]*storage.CommittedBlock(nil), committed[:len(committed)-1]...), b))
+		if _, err := newAuditor(forged).VerifyCommittedBlock(b.Height, b.ID); !errors.Is(err, state.ErrQuorumNotReached) {
+			t.Errorf("want no quorum of the %s signatures, has: %v", name, err)
+		}
+	}
+}
+
+func TestScrubCommittedChain(t *testing.T) {
+	vs, committed := committedChain(t, 5, false)
+	// the QCs stored before they carried the signed votes
+	var legacy []*storage.CommittedBlock
+	for _, b := range committed {
+		qc, err := state.DefaultDeserialize(b.QC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bare, _ := qc.Certify(nil)
+		stripped := *b
+		stripped.QC, _ = bare.Serialize()
+		legacy = append(legacy, &stripped)
+	}
+	for name, chain := range map[string][]*storage.CommittedBlock{"signed": committed, "legacy": legacy} {
+		blocks := storeBlocks(t, chain)
+		cfg := statetest.Genesis("test", vs)
+		s, err := state.NewState(vs[0].ID, vs[0].Crypto, state.NewClockTimeoutTicker(nil, libs.SystemClock), nil, cfg,
+			state.WithBlockStorage(blocks))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RegisterElection(state.NewDefaultElection(0, statetest.IDs(vs))); err != nil {
+			t.Fatal(err)
+		}
+		var repairs []int64
+		s.OnCorruptBlock(func(height int64) { repairs = append(repairs, height) })
+
+		heights, err := s.ScrubBlocks(0, 0)
+		if err != nil || len(heights) != 0 || len(repairs) != 0 {
+			t.Errorf("want no %s block flagged, has: %v, repairs: %v, err: %v", name, heights, repairs, err)
+		}
+	}
+}
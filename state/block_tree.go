@@ -143,25 +143,27 @@ func (t *BlockTree) ExecuteNInsert(qc QuorumCert) error {
 	return nil
 }
 
-func (t *BlockTree) ProcessCommit(key string) error {
+// ProcessCommit makes the commit node of the key the root of the tree, it returns false if the key isn't
+// the commit node, e.g. none has been formed or the 3-chain of the key is broken, nothing is committed then.
+func (t *BlockTree) ProcessCommit(key string) (bool, error) {
 	t.mutex.RLock()
 	defer t.mutex.RUnlock()
 
 	if t.commit == nil {
 		t.log.Warn("commit qc is nil")
-		return nil
+		return false, nil
 	}
 	if t.commit.ID != key {
 		t.log.Warn("commit key invalid, want: %s, have: %s", t.commit.ID, key)
-		return nil
+		return false, nil
 	}
 	if err := t.tree.Reset(t.commit); err != nil {
 		t.log.Error("reset commit qc fail, err: %v", err)
-		return err
+		return false, err
 	}
 
 	// TODO: clear voteMap
-	return nil
+	return true, nil
 }
 
 func (t *BlockTree) ProcessVote(qc QuorumCert, validators []PeerID) error {
@@ -185,6 +187,51 @@ func (t *BlockTree) ProcessVote(qc QuorumCert, validators []PeerID) error {
 	return nil
 }
 
+// Certify replaces the value of the node certified by the qc, i.e. the qc carrying the signs of the node,
+// value is the serialized qc. The qc must certify the same block extending the same parent as the node.
+func (t *BlockTree) Certify(qc QuorumCert, value []byte) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	round, id, err := qc.Proposal()
+	if err != nil {
+		return err
+	}
+	node, err := t.queryWithoutLock(t.FF(id))
+	if err != nil {
+		return err
+	}
+	old, err := t.DeserializeF(node.Value)
+	if err != nil {
+		return err
+	}
+	oldParentRound, oldParentID, err := old.ParentProposal()
+	if err != nil {
+		return err
+	}
+	parentRound, parentID, err := qc.ParentProposal()
+	if err != nil {
+		return err
+	}
+	if node.Round != round || oldParentRound != parentRound || !bytes.Equal(oldParentID, parentID) {
+		return libs.ErrWrongElement
+	}
+	node.Value = value
+	return nil
+}
+
+// HighBranch returns the uncommitted nodes from the high node up to the committed one.
+func (t *BlockTree) HighBranch() []*bt.Node {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	var branch []*bt.Node
+	for n := t.high; n != nil && n != t.commit; n = n.Parent {
+		branch = append(branch, n)
+	}
+	return branch
+}
+
 func (t *BlockTree) Search(round int64, id []byte) (*bt.Node, error) {
 	t.mutex.RLock()
 	defer t.mutex.RUnlock()
@@ -0,0 +1,44 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+// proposalContent is what the versioned id of the proposal commits to, the parent is the one of its JustifyParent.
+func proposalContent(p *types.ProposalMsg, parentRound int64, parentID []byte) *types.BlockContent {
+	return &types.BlockContent{Round: p.Round, ParentRound: parentRound, ParentID: parentID, Txs: p.Txs,
+		AppHeight: p.AppHeight, AppHash: p.AppHash, Timestamp: p.Timestamp, VoteExtensions: p.VoteExtensions}
+}
+
+// verifyBlockID checks the id of the received proposal commits to its content, the legacy ids commit
+// to nothing, so they're only accepted if the host keeps them or below ConsensusConfig.HashActivationRound.
+func (s *State) verifyBlockID(id types.BlockID, c *types.BlockContent) error {
+	if id.Scheme() == types.HashSchemeLegacy && s.hashScheme != types.HashSchemeLegacy && c.Round >= s.hashActivation {
+		return fmt.Errorf("%w, round: %d, id: %s, activation: %d", types.ErrLegacyBlockID, c.Round, id, s.hashActivation)
+	}
+	return id.Verify(c)
+}
+
+// hashProposal replaces the random id of the proposal by its hash unless the host keeps the legacy ids,
+// it's invoked once the payload, the app hash, the extensions and the timestamp of the proposal are settled.
+func (s *State) hashProposal(p *types.ProposalMsg) error {
+	if s.hashScheme == types.HashSchemeLegacy {
+		return nil
+	}
+	parentQC, err := s.tree.DeserializeF(p.JustifyParent)
+	if err != nil {
+		return err
+	}
+	parentRound, parentID, err := parentQC.Proposal()
+	if err != nil {
+		return err
+	}
+	id, err := types.NewBlockID(s.hashScheme, proposalContent(p, parentRound, parentID))
+	if err != nil {
+		return err
+	}
+	p.ID = id
+	return nil
+}
@@ -0,0 +1,96 @@
+package state
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aucusaga/gohotstuff/storage"
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+func TestBlockID(t *testing.T) {
+	content := &types.BlockContent{Round: 5, ParentRound: 4, ParentID: []byte("genesis"), Txs: [][]byte{[]byte("tx")},
+		Timestamp: 100, VoteExtensions: [][]byte{[]byte("ext")}}
+	id, err := types.NewBlockID(types.HashSchemeSHA256, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Scheme() != types.HashSchemeSHA256 || !strings.HasPrefix(id.String(), "sha256:") {
+		t.Errorf("unexpected id: %s", id)
+	}
+	if err := id.Verify(content); err != nil {
+		t.Error(err)
+	}
+	// the timestamp and the extensions can't be replaced under the id either
+	for name, forged := range map[string]types.BlockContent{
+		"timestamp":  {Round: 5, ParentRound: 4, ParentID: []byte("genesis"), Txs: content.Txs, Timestamp: 101, VoteExtensions: content.VoteExtensions},
+		"extensions": {Round: 5, ParentRound: 4, ParentID: []byte("genesis"), Txs: content.Txs, Timestamp: 100},
+	} {
+		if err := id.Verify(&forged); !errors.Is(err, types.ErrBlockIDMismatch) {
+			t.Errorf("want the forged %s refused, err: %v", name, err)
+		}
+	}
+	// the legacy ids commit to nothing
+	if legacy := types.BlockID("1234"); legacy.Scheme() != types.HashSchemeLegacy || legacy.Verify(content) != nil {
+		t.Error("want the legacy id passing")
+	}
+
+	// the stored block whose txs are replaced fails its id
+	qc, err := NewDefaultQuorumCert("leader", nil, content.Round, id, content.ParentRound, content.ParentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := qc.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &storage.CommittedBlock{Height: 5, ID: id, ParentRound: 4, ParentID: []byte("genesis"), Proposer: "leader",
+		Txs: content.Txs, QC: raw, Time: content.Timestamp, VoteExtensions: content.VoteExtensions}
+	if err := verifyCommittedBlock(DefaultDeserialize, nil, b); err != nil {
+		t.Fatal(err)
+	}
+	b.Txs = [][]byte{[]byte("forged")}
+	if err := verifyCommittedBlock(DefaultDeserialize, nil, b); !errors.Is(err, ErrBrokenQCChain) ||
+		!strings.Contains(err.Error(), types.ErrBlockIDMismatch.Error()) {
+		t.Errorf("want the forged block refused, err: %v", err)
+	}
+
+	if _, err := types.ParseHashScheme("blake3"); err == nil {
+		t.Error("want the unknown scheme refused")
+	}
+}
+
+func TestLegacyBlockID(t *testing.T) {
+	content := &types.BlockContent{Round: 5, ParentRound: 4, ParentID: []byte("genesis")}
+	legacy := types.BlockID("1234")
+	for _, c := range []struct {
+		name       string
+		scheme     types.HashScheme
+		activation int64
+		accepted   bool
+	}{
+		{"enforced", types.HashSchemeSHA256, 0, false},
+		{"activated", types.HashSchemeSHA256, 5, false},
+		{"before the activation", types.HashSchemeSHA256, 6, true},
+		{"legacy host", types.HashSchemeLegacy, 0, true},
+	} {
+		s := &State{hashScheme: c.scheme, hashActivation: c.activation}
+		err := s.verifyBlockID(legacy, content)
+		if c.accepted && err != nil {
+			t.Errorf("%s: want the legacy id accepted, err: %v", c.name, err)
+		}
+		if !c.accepted && !errors.Is(err, types.ErrLegacyBlockID) {
+			t.Errorf("%s: want the legacy id refused, err: %v", c.name, err)
+		}
+	}
+	// the versioned ids are verified whatever the scheme of the host
+	id, err := types.NewBlockID(types.HashSchemeSHA256, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &State{hashScheme: types.HashSchemeLegacy}
+	if err := s.verifyBlockID(id, &types.BlockContent{Round: 5, ParentRound: 3}); !errors.Is(err, types.ErrBlockIDMismatch) {
+		t.Errorf("want the forged block refused, err: %v", err)
+	}
+}
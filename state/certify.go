@@ -0,0 +1,214 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
+	"github.com/aucusaga/gohotstuff/crypto"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/types"
+	"github.com/golang/protobuf/proto"
+)
+
+var (
+	ErrInvalidQCSign = errors.New("invalid signature carried by the qc")
+)
+
+// qcSigns are the signed votes and timeouts counted by the host, which certify the QCs it forms,
+// map[round][proposal_id or timeout_id][validator], guarded by the procedure mutex.
+type qcSigns map[int64]map[string]map[string]DefaultSign
+
+// qcVerifier verifies the signed msgs carried by the QCs, see QuorumCert.SignedMsg, in the sign domain
+// of the chain and the epoch of the QC, against the keys registered for the validators.
+type qcVerifier struct {
+	crypto  crypto.CryptoClient
+	chainID string
+	forkID  string
+	// epoch of the round, epoch 0 if it's nil
+	epoch func(round int64) int64
+	// key registered for the validator at the round, the key carried by the msg is trusted if
+	// it's nil or none is registered, as well as checkKey
+	key func(round int64, v PeerID) ([]byte, bool)
+}
+
+// signers returns the validators whose signed msgs carried by the QC are valid, a vote must be for the
+// block of the QC, a timeout for the round and the index of the timeout QC.
+func (qv *qcVerifier) signers(qc QuorumCert, validators []PeerID) []PeerID {
+	var signers []PeerID
+	for _, v := range validators {
+		if err := qv.verify(qc, v); err == nil {
+			signers = append(signers, v)
+		}
+	}
+	return signers
+}
+
+func (qv *qcVerifier) verify(qc QuorumCert, v PeerID) error {
+	round, id, err := qc.Proposal()
+	if err != nil {
+		return err
+	}
+	msgbytes, err := qc.SignedMsg(string(v))
+	if err != nil {
+		return err
+	}
+	if valid, err := qv.crypto.Verify(nil, nil, msgbytes); err != nil || !valid {
+		return fmt.Errorf("%w, validator: %s, valid: %v, err: %v", ErrInvalidQCSign, v, valid, err)
+	}
+	m, err := ConsMsgFromProto(msgbytes)
+	if err != nil {
+		return err
+	}
+	switch m := m.(type) {
+	case *types.VoteMsg:
+		if m.Round != round || !bytes.Equal(m.ID, id) {
+			return fmt.Errorf("%w, vote for another block, validator: %s, round: %d", ErrInvalidQCSign, v, m.Round)
+		}
+	case *types.TimeoutMsg:
+		if m.Round != round || !bytes.Equal(timeoutID(m.Round, m.Index), id) {
+			return fmt.Errorf("%w, timeout of another round, validator: %s, round: %d", ErrInvalidQCSign, v, m.Round)
+		}
+	default:
+		return fmt.Errorf("%w, validator: %s, type: %T", ErrInvalidQCSign, v, m)
+	}
+	if msgSender(m) != string(v) {
+		return fmt.Errorf("%w, signed by %s instead of %s", ErrInvalidQCSign, msgSender(m), v)
+	}
+	chainID, forkID, epoch, err := MsgDomain(msgbytes)
+	if err != nil {
+		return err
+	}
+	var want int64
+	if qv.epoch != nil {
+		want = qv.epoch(round)
+	}
+	if chainID != qv.chainID || forkID != qv.forkID || epoch != want {
+		return fmt.Errorf("%w, validator: %s, domain: %s/%s/%d", ErrDomainMismatch, v, chainID, forkID, epoch)
+	}
+	if qv.key == nil {
+		return nil
+	}
+	if pk, ok := qv.key(round, v); ok && !bytes.Equal(pk, msgPublicKey(m)) {
+		return fmt.Errorf("%w, validator: %s, round: %d", ErrKeyMismatch, v, round)
+	}
+	return nil
+}
+
+// qcVerifier verifies the QCs of the chain of the host.
+func (s *State) qcVerifier() *qcVerifier {
+	return &qcVerifier{crypto: s.crypto, chainID: s.cfg.ChainID, forkID: s.cfg.ForkID, epoch: s.epoch, key: s.ValidatorKey}
+}
+
+// collectSign keeps the signed vote or timeout counted for the QC of the round and the id.
+func (s *State) collectSign(round int64, id []byte, m MsgInfo) {
+	var signed, sig []byte
+	var err error
+	switch m := m.(type) {
+	case *types.VoteMsg:
+		signed, err = s.signedVote(m)
+		sig = m.Signature
+	case *types.TimeoutMsg:
+		signed, err = s.signedTimeout(m)
+		sig = m.Signature
+	default:
+		err = fmt.Errorf("unexpected type: %T", m)
+	}
+	if err != nil {
+		s.log.Error("rebuild signed msg fail @ state.collectSign, round: %d, id: %s, err: %v", round, libs.F(id), err)
+		return
+	}
+	if s.certSigns == nil {
+		s.certSigns = make(qcSigns)
+	}
+	for r := range s.certSigns {
+		if r <= s.committedRound {
+			delete(s.certSigns, r)
+		}
+	}
+	if _, ok := s.certSigns[round]; !ok {
+		s.certSigns[round] = make(map[string]map[string]DefaultSign)
+	}
+	key := libs.F(id)
+	if _, ok := s.certSigns[round][key]; !ok {
+		s.certSigns[round][key] = make(map[string]DefaultSign)
+	}
+	sender := msgSender(m)
+	s.certSigns[round][key][sender] = DefaultSign{PeerID: sender, PublicKey: msgPublicKey(m), Sign: sig, Msg: signed}
+}
+
+// certify makes the tree node of the round and the id carry the collected signs, it's invoked once
+// the node becomes the high qc.
+func (s *State) certify(round int64, id []byte) {
+	node, err := s.tree.Search(round, id)
+	if err != nil {
+		s.log.Warn("search node fail @ state.certify, round: %d, id: %s, err: %v", round, libs.F(id), err)
+		return
+	}
+	qc, err := s.tree.DeserializeF(node.Value)
+	if err != nil {
+		s.log.Error("deserialize qc fail @ state.certify, round: %d, err: %v", round, err)
+		return
+	}
+	certified, err := qc.Certify(s.certSigns[round][libs.F(id)])
+	if err != nil {
+		s.log.Error("certify qc fail @ state.certify, round: %d, err: %v", round, err)
+		return
+	}
+	if err := s.certifyNode(certified); err != nil {
+		s.log.Error("certify node fail @ state.certify, round: %d, err: %v", round, err)
+	}
+}
+
+// certifyJustify makes the local node of the justify carry its signs, if they're signed by a quorum
+// of the validators, so that the blocks committed by a replica are certified as well as by the leaders.
+func (s *State) certifyJustify(justify QuorumCert) {
+	round, _, err := justify.Proposal()
+	if err != nil || round <= s.cfg.StartRound {
+		return
+	}
+	validators := s.election.Validators(round, s.timeoutSet.GetTimeoutIdxMap())
+	signers := s.qcVerifier().signers(justify, validators)
+	if len(signers) <= len(validators)*2/3 {
+		s.log.Warn("qc isn't signed by the quorum @ state.certifyJustify, qc: %s, signers: %d, validators: %d",
+			justify.String(), len(signers), len(validators))
+		return
+	}
+	if err := s.certifyNode(justify); err != nil {
+		s.log.Warn("certify node fail @ state.certifyJustify, qc: %s, err: %v", justify.String(), err)
+	}
+}
+
+func (s *State) certifyNode(qc QuorumCert) error {
+	value, err := qc.Serialize()
+	if err != nil {
+		return err
+	}
+	return s.tree.Certify(qc, value)
+}
+
+// signedTimeout rebuilds the timeout as signed by its sender, which has passed the checks of the domain.
+func (s *State) signedTimeout(timeout *types.TimeoutMsg) ([]byte, error) {
+	msgbytes, err := ForkProtoFromConsMsg(timeout, s.cfg.ChainID, s.cfg.ForkID, s.epoch(timeout.Round))
+	if err != nil {
+		return nil, err
+	}
+	var msg pb.Message
+	if err := proto.Unmarshal(msgbytes, &msg); err != nil {
+		return nil, err
+	}
+	msg.GetTimeout().Pk = timeout.PublicKey
+	msg.GetTimeout().Signature = timeout.Signature
+	return proto.Marshal(&msg)
+}
+
+// bareQC serializes the QC without its signs, e.g. the high qc piggybacked on a timeout msg,
+// which would otherwise nest the signed timeouts of the previous rounds.
+func bareQC(qc QuorumCert) ([]byte, error) {
+	bare, err := qc.Certify(nil)
+	if err != nil {
+		return nil, err
+	}
+	return bare.Serialize()
+}
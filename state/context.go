@@ -23,6 +23,11 @@ type WAL interface {
 	FlushAndSync() error
 
 	SearchForEndHeight(height int64) (rd io.ReadCloser, found bool, err error)
+	// Truncate drops the records of the rounds below the committed round, which have been pruned,
+	// it may defer the rewrite, e.g. until the wal grows large enough.
+	Truncate(committedRound int64) error
+	// NewReader returns a reader from the very beginning of the wal.
+	NewReader() (io.ReadCloser, error)
 
 	// service methods
 	Start() error
@@ -0,0 +1,147 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+const (
+	// DefaultDiskProbeInterval is the interval of retrying the writes failed for the disk.
+	DefaultDiskProbeInterval = 5 * time.Second
+	// maxDiskProbes bounds the failed writes kept for the retries, e.g. the blocks committed by the peers
+	// meanwhile, the older ones are given up and left to the scrubber and the resync.
+	maxDiskProbes = 1024
+)
+
+var ErrDiskSuspended = errors.New("voting is suspended by a disk failure")
+
+// diskGuard keeps the host from voting while the wal or the stores fail to write for the disk, e.g. it's full,
+// a vote which isn't persisted may be contradicted after a restart. The failed writes are retried
+// every DiskProbeInterval and the host resumes voting once they all pass.
+type diskGuard struct {
+	sync.Mutex
+	suspended bool
+	kind      FailureKind
+	err       error
+	since     time.Time
+	// probes retry the failed writes in the order of the failures
+	probes  []func() error
+	probing bool
+}
+
+// suspendOnDisk suspends the voting if err is a disk failure of the write of the kind, probe retries the write.
+// It's invoked before the failure policy, which may still degrade or halt the host.
+func (s *State) suspendOnDisk(kind FailureKind, err error, probe func() error) bool {
+	if kind == FailureExecutor || !libs.IsDiskFailure(err) {
+		return false
+	}
+	if kind == FailureWAL && s.wal != nil {
+		// the records are kept in the buffer of the wal, flushing them is the retry
+		probe = s.wal.FlushAndSync
+	}
+	s.disk.Lock()
+	if len(s.disk.probes) >= maxDiskProbes {
+		s.log.Warn("too many failed writes, give up the oldest @ state.suspendOnDisk, kind: %s", kind)
+		s.disk.probes = s.disk.probes[1:]
+	}
+	s.disk.probes = append(s.disk.probes, probe)
+	first := !s.disk.suspended
+	if first {
+		s.disk.suspended, s.disk.kind, s.disk.err, s.disk.since = true, kind, err, s.clock.Now()
+	}
+	spawn := !s.disk.probing
+	s.disk.probing = true
+	s.disk.Unlock()
+
+	if first {
+		s.log.Error("disk failure, voting suspended @ state.suspendOnDisk, kind: %s, err: %v", kind, err)
+		if s.metrics != nil {
+			s.metrics.diskSuspended.Set(1)
+		}
+		s.publish(libs.Event{
+			Type:   libs.EventDiskFailure,
+			Module: libs.ChainModule(s.cfg.ChainID, libs.ConsensusModule),
+			Data:   libs.DiskEventData{Kind: string(kind), Reason: err.Error()},
+		})
+	}
+	if spawn {
+		s.routines.Spawn("disk_probe", s.diskProbeRoutine)
+	}
+	return true
+}
+
+func (s *State) diskProbeRoutine() {
+	for {
+		select {
+		case <-s.clock.After(s.cfg.DiskProbeInterval):
+		case <-s.quit:
+			return
+		}
+		if s.probeDisk() {
+			return
+		}
+	}
+}
+
+// probeDisk retries the failed writes in order and resumes the voting once they all pass,
+// the writes failed meanwhile are retried as well.
+func (s *State) probeDisk() bool {
+	s.disk.Lock()
+	probes := s.disk.probes
+	s.disk.Unlock()
+
+	for i, probe := range probes {
+		if err := probe(); err != nil {
+			s.disk.Lock()
+			s.disk.probes, s.disk.err = s.disk.probes[i:], err
+			s.disk.Unlock()
+			s.log.Warn("disk still failing @ state.probeDisk, pending writes: %d, err: %v", len(probes)-i, err)
+			return false
+		}
+	}
+
+	s.disk.Lock()
+	s.disk.probes = s.disk.probes[len(probes):]
+	if len(s.disk.probes) > 0 {
+		s.disk.Unlock()
+		return false
+	}
+	kind, since := s.disk.kind, s.disk.since
+	s.disk.suspended, s.disk.probing, s.disk.err, s.disk.probes = false, false, nil, nil
+	s.disk.Unlock()
+
+	d := s.clock.Since(since)
+	s.log.Info("disk recovered, voting resumed @ state.probeDisk, kind: %s, suspended: %v", kind, d)
+	if s.metrics != nil {
+		s.metrics.diskSuspended.Set(0)
+	}
+	s.publish(libs.Event{
+		Type:   libs.EventDiskRecovered,
+		Module: libs.ChainModule(s.cfg.ChainID, libs.ConsensusModule),
+		Data:   libs.DiskEventData{Kind: string(kind), Duration: d},
+	})
+	return true
+}
+
+// DiskFailure returns the disk failure the voting is suspended for, nil if it isn't.
+func (s *State) DiskFailure() error {
+	s.disk.Lock()
+	defer s.disk.Unlock()
+
+	if !s.disk.suspended {
+		return nil
+	}
+	return fmt.Errorf("%w, kind: %s, since: %s, err: %v", ErrDiskSuspended, s.disk.kind,
+		s.disk.since.Format(time.RFC3339), s.disk.err)
+}
+
+func (s *State) votingSuspended() bool {
+	s.disk.Lock()
+	defer s.disk.Unlock()
+
+	return s.disk.suspended
+}
@@ -0,0 +1,122 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+// fullDisk accepts limit bytes, the writes beyond fail with ENOSPC until the limit is raised.
+type fullDisk struct {
+	bytes.Buffer
+	limit int
+}
+
+func (d *fullDisk) Write(p []byte) (int, error) {
+	n := d.limit - d.Len()
+	if n >= len(p) {
+		return d.Buffer.Write(p)
+	}
+	if n > 0 {
+		d.Buffer.Write(p[:n])
+	} else {
+		n = 0
+	}
+	return n, &os.PathError{Op: "write", Path: "wal", Err: syscall.ENOSPC}
+}
+
+func TestWALBufferKeepsUnflushed(t *testing.T) {
+	disk := &fullDisk{limit: 4}
+	b := &walBuffer{w: disk}
+	b.Write([]byte("abc"))
+	b.Write([]byte("def"))
+	if err := b.Flush(); !libs.IsDiskFailure(err) {
+		t.Fatalf("want the disk failure, has: %v", err)
+	}
+	b.Write([]byte("g"))
+	if err := b.Flush(); err == nil {
+		t.Fatal("want the disk still full")
+	}
+	disk.limit = 1 << 10
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if disk.String() != "abcdefg" {
+		t.Errorf("want the records in order, has: %q", disk.String())
+	}
+}
+
+func TestDiskFailureSuspendsVoting(t *testing.T) {
+	bus := libs.NewDefaultEventBus(logs.NewLogger())
+	events := bus.Subscribe(libs.EventAll)
+	s := &State{
+		cfg:      &ConsensusConfig{DiskProbeInterval: 10 * time.Millisecond},
+		eventBus: bus,
+		quit:     make(chan struct{}),
+		clock:    libs.SystemClock,
+		log:      logs.NewLogger(),
+	}
+	defer close(s.quit)
+	waitEvent := func(want libs.EventType) libs.DiskEventData {
+		for {
+			select {
+			case e := <-events:
+				if e.Type == want {
+					return e.Data.(libs.DiskEventData)
+				}
+			case <-time.After(3 * time.Second):
+				t.Fatalf("want the %s event", want)
+			}
+		}
+	}
+
+	// the other failures leave the voting alone
+	if err := s.handleFailure(FailureStorage, false, errors.New("checksum mismatch"), nil); err == nil || s.DiskFailure() != nil {
+		t.Fatalf("want the failure ignored without suspending, err: %v", err)
+	}
+
+	full := int32(1)
+	saved := 0
+	save := func() error {
+		if atomic.LoadInt32(&full) == 1 {
+			return fmt.Errorf("save block fail, err: %w", &os.PathError{Op: "write", Path: "blocks/1", Err: syscall.ENOSPC})
+		}
+		saved++
+		return nil
+	}
+	if err := s.handleFailure(FailureStorage, false, save(), save); !libs.IsDiskFailure(err) {
+		t.Fatalf("want the disk failure returned by the ignore policy, has: %v", err)
+	}
+	if data := waitEvent(libs.EventDiskFailure); data.Kind != string(FailureStorage) {
+		t.Errorf("unexpected disk failure event: %+v", data)
+	}
+	if err := s.DiskFailure(); !errors.Is(err, ErrDiskSuspended) {
+		t.Errorf("want the voting suspended, has: %v", err)
+	}
+	for _, m := range []MsgInfo{&types.VoteMsg{Round: 2}, &types.TimeoutMsg{Round: 2}, &types.ProposalMsg{Round: 2}} {
+		if err := s.schedule(m); !errors.Is(err, ErrDiskSuspended) {
+			t.Errorf("want %s refused while suspended, has: %v", m, err)
+		}
+	}
+	if s.Halted() {
+		t.Error("the disk failure shouldn't halt the host")
+	}
+
+	// the space is freed, the failed write passes on the next probe
+	atomic.StoreInt32(&full, 0)
+	waitEvent(libs.EventDiskRecovered)
+	s.disk.Lock()
+	defer s.disk.Unlock()
+	if s.disk.suspended || saved != 1 || len(s.disk.probes) != 0 {
+		t.Errorf("want the voting resumed after the failed write, suspended: %v, saved: %d", s.disk.suspended, saved)
+	}
+}
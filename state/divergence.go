@@ -0,0 +1,74 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+// appHashHistory is the number of the latest executed heights whose state hashes are kept,
+// the claims of older heights can't be checked.
+const appHashHistory = 256
+
+// AppHasher is implemented by the executors which commit the application state to a hash,
+// e.g. a merkle root. The proposals then carry the hash of the proposer, and every replica
+// halts once its own hash at the same height differs from the one of a committed block.
+type AppHasher interface {
+	// AppHash returns the hash of the state after the latest executed block.
+	AppHash() []byte
+}
+
+// appHashClaim returns the latest executed height and its state hash, which the next proposal carries.
+func (s *State) appHashClaim() (int64, []byte) {
+	s.pipeline.Lock()
+	defer s.pipeline.Unlock()
+
+	hash, ok := s.pipeline.hashes[s.pipeline.executed]
+	if !ok {
+		return 0, nil
+	}
+	return s.pipeline.executed, hash
+}
+
+// checkAppHash compares the state hash claimed by the committed block with the local one,
+// the claims which can't be checked, e.g. from before the restart, are accepted.
+func (s *State) checkAppHash(job execJob) (libs.ConsensusFailureEventData, bool) {
+	report := libs.ConsensusFailureEventData{
+		Height:      job.appHeight,
+		Round:       job.height,
+		Proposer:    job.proposer,
+		ClaimedHash: fmt.Sprintf("%x", job.appHash),
+	}
+	if len(job.appHash) == 0 {
+		return report, true
+	}
+
+	s.pipeline.Lock()
+	defer s.pipeline.Unlock()
+
+	report.ExecutedHeight = s.pipeline.executed
+	if s.pipeline.hashes == nil || job.appHeight < s.pipeline.hashFrom {
+		return report, true
+	}
+	local := s.pipeline.hashes[job.appHeight]
+	report.LocalHash = fmt.Sprintf("%x", local)
+	return report, bytes.Equal(local, job.appHash)
+}
+
+// haltForDivergence stops the host from voting and committing once the executed state diverges
+// from the committed one, the reads of the application state are still served as of the divergence.
+func (s *State) haltForDivergence(report libs.ConsensusFailureEventData) {
+	s.mtx.Lock()
+	s.halted = true
+	s.mtx.Unlock()
+
+	s.log.Error("state diverges from the committed block, consensus halted @ state.haltForDivergence, "+
+		"round: %d, proposer: %s, height: %d, claimed: %s, local: %s, executed: %d", report.Round, report.Proposer,
+		report.Height, report.ClaimedHash, report.LocalHash, report.ExecutedHeight)
+	s.publish(libs.Event{
+		Type:   libs.EventConsensusFailure,
+		Module: libs.ChainModule(s.cfg.ChainID, libs.ConsensusModule),
+		Data:   report,
+	})
+}
@@ -0,0 +1,66 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/metrics"
+)
+
+// hashExecutor hashes the state as the last executed height.
+type hashExecutor struct {
+	heightExecutor
+}
+
+func (e *hashExecutor) AppHash() []byte {
+	return []byte{byte(e.executed[len(e.executed)-1])}
+}
+
+func TestStateDivergence(t *testing.T) {
+	bus := libs.NewDefaultEventBus(logs.NewLogger())
+	failures := bus.Subscribe(libs.EventConsensusFailure)
+	e := &hashExecutor{}
+	s := &State{
+		cfg:      &ConsensusConfig{},
+		pipeline: newExecPipeline(0),
+		metrics:  newConsensusMetrics(metrics.NewRegistry(), "test"),
+		eventBus: bus,
+		quit:     make(chan struct{}),
+		log:      logs.NewLogger(),
+	}
+	if err := s.SetExecutor(e); err != nil {
+		t.Fatal(err)
+	}
+	go s.execRoutine()
+	defer close(s.quit)
+
+	s.enqueueExec(execJob{height: 1})
+	// a claim from before the first executed height can't be checked
+	s.enqueueExec(execJob{height: 2, appHeight: 0, appHash: []byte{9}})
+	s.enqueueExec(execJob{height: 3, appHeight: 1, appHash: []byte{1}})
+	s.enqueueExec(execJob{height: 4, appHeight: 3, appHash: []byte{2}, proposer: "a"})
+	s.enqueueExec(execJob{height: 5})
+
+	select {
+	case ev := <-failures:
+		report := ev.Data.(libs.ConsensusFailureEventData)
+		if report.Round != 4 || report.Height != 3 || report.Proposer != "a" || report.ClaimedHash != "02" ||
+			report.LocalHash != "03" || report.ExecutedHeight != 3 {
+			t.Errorf("unexpected report: %+v", report)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("divergence is not reported")
+	}
+	if !s.Halted() {
+		t.Errorf("want halted")
+	}
+	// the state is left at the divergence, and still served
+	if res, err := s.Query("/key", nil, 0); err != nil || res.Height != 3 {
+		t.Errorf("unexpected query, res: %+v, err: %v", res, err)
+	}
+	if height, hash := s.appHashClaim(); height != 3 || hash[0] != 3 {
+		t.Errorf("unexpected claim, height: %d, hash: %x", height, hash)
+	}
+}
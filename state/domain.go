@@ -0,0 +1,46 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrDomainMismatch = errors.New("msg is signed in another domain")
+)
+
+// epoch returns the epoch of the round, it's always 0 if the election doesn't number the epochs.
+func (s *State) epoch(round int64) int64 {
+	if e, ok := s.election.(EpochElection); ok {
+		return e.Epoch(round)
+	}
+	return 0
+}
+
+// signMsg signs the msg in the domain of the chain, its fork and the epoch of its round.
+func (s *State) signMsg(m MsgInfo) ([]byte, error) {
+	msgbytes, err := ForkProtoFromConsMsg(m, s.cfg.ChainID, s.cfg.ForkID, s.epoch(msgRound(m)))
+	if err != nil {
+		return nil, err
+	}
+	return s.crypto.Sign(msgbytes)
+}
+
+// checkDomain refuses the msg signed for another chain, another fork or another epoch of its round,
+// the signature has been verified over the domain carried by the msg.
+func (s *State) checkDomain(msgbytes []byte, m MsgInfo) error {
+	chainID, forkID, epoch, err := MsgDomain(msgbytes)
+	if err != nil {
+		return err
+	}
+	if chainID != s.cfg.ChainID {
+		return fmt.Errorf("%w, want chain: %s, has: %s", ErrDomainMismatch, s.cfg.ChainID, chainID)
+	}
+	if forkID != s.cfg.ForkID {
+		return fmt.Errorf("%w, want fork: %s, has: %s", ErrDomainMismatch, s.cfg.ForkID, forkID)
+	}
+	if want := s.epoch(msgRound(m)); epoch != want {
+		return fmt.Errorf("%w, want epoch: %d, has: %d", ErrDomainMismatch, want, epoch)
+	}
+	return nil
+}
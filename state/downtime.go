@@ -0,0 +1,154 @@
+package state
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+// DefaultMaxMissedRatio is used when the downtime tracking is enabled without a threshold.
+const DefaultMaxMissedRatio = 0.5
+
+// DowntimeInfo describes a validator whose votes are missing from too many QCs of the window.
+type DowntimeInfo struct {
+	Validator PeerID
+	// Round is the round of the QC which pushes the validator over the threshold.
+	Round  int64
+	Missed int
+	Window int
+}
+
+// DowntimeHook is invoked synchronously by the receiveRoutine once a validator goes down,
+// the application may jail or slash it, the hook must not call back into the state.
+type DowntimeHook func(info DowntimeInfo)
+
+// Participation is the record of a validator over the sliding window of QCs.
+type Participation struct {
+	Validator PeerID `json:"validator"`
+	Signed    int    `json:"signed"`
+	Missed    int    `json:"missed"`
+	// Down means the validator has missed more than the threshold of a full window.
+	Down bool `json:"down"`
+}
+
+type downtimeTracker struct {
+	sync.Mutex
+	// the latest signed rounds of each validator, true means its vote is included in the QC
+	records map[PeerID][]bool
+	down    map[PeerID]bool
+	// the round of the latest QC tracked, QCs are tracked at most once
+	lastRound int64
+	hooks     []DowntimeHook
+}
+
+// OnDowntime registers a hook invoked when a validator misses more than ConsensusConfig.MaxMissedRatio
+// of the last ConsensusConfig.DowntimeWindow QCs, it's invoked again only after the validator recovers.
+func (s *State) OnDowntime(h DowntimeHook) {
+	s.downtime.Lock()
+	defer s.downtime.Unlock()
+
+	s.downtime.hooks = append(s.downtime.hooks, h)
+}
+
+// Participation returns the records of the validators sorted by the peer id.
+func (s *State) Participation() []Participation {
+	s.downtime.Lock()
+	defer s.downtime.Unlock()
+
+	list := make([]Participation, 0, len(s.downtime.records))
+	for v, record := range s.downtime.records {
+		p := Participation{Validator: v, Down: s.downtime.down[v]}
+		for _, signed := range record {
+			if signed {
+				p.Signed++
+			} else {
+				p.Missed++
+			}
+		}
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Validator < list[j].Validator })
+	return list
+}
+
+// trackParticipation records the signers of the parent QC carried by an accepted proposal.
+func (s *State) trackParticipation(proposal *types.ProposalMsg) {
+	if s.cfg.DowntimeWindow <= 0 {
+		return
+	}
+	s.mtx.RLock()
+	qc, err := s.tree.DeserializeF(proposal.JustifyParent)
+	if err != nil {
+		s.mtx.RUnlock()
+		return
+	}
+	round, _, err := qc.Proposal()
+	if err != nil {
+		s.mtx.RUnlock()
+		return
+	}
+	validators := s.election.Validators(round, s.timeoutSet.GetTimeoutIdxMap())
+	s.mtx.RUnlock()
+
+	// a validator took part only if the QC carries its valid signed vote or timeout
+	downs, hooks := s.recordQC(round, s.qcVerifier().signers(qc, validators), validators)
+	for _, info := range downs {
+		s.log.Warn("validator is down @ state.trackParticipation, validator: %s, round: %d, missed: %d, window: %d",
+			info.Validator, info.Round, info.Missed, info.Window)
+		s.publish(libs.Event{
+			Type:   libs.EventDowntime,
+			Module: libs.ChainModule(s.cfg.ChainID, libs.ConsensusModule),
+			Data: libs.DowntimeEventData{
+				Validator: string(info.Validator),
+				Round:     info.Round,
+				Missed:    info.Missed,
+				Window:    info.Window,
+			},
+		})
+		for _, h := range hooks {
+			h(info)
+		}
+	}
+}
+
+// recordQC appends the QC of the round signed by the signers into the window of every validator, and returns
+// the validators going down, a validator is judged only once its window is full.
+func (s *State) recordQC(round int64, signers, validators []PeerID) ([]DowntimeInfo, []DowntimeHook) {
+	s.downtime.Lock()
+	defer s.downtime.Unlock()
+
+	// the genesis QC isn't signed by anyone
+	if round <= s.downtime.lastRound || round <= s.cfg.StartRound {
+		return nil, nil
+	}
+	s.downtime.lastRound = round
+
+	signed := make(map[PeerID]bool, len(signers))
+	for _, v := range signers {
+		signed[v] = true
+	}
+	window := s.cfg.DowntimeWindow
+	var downs []DowntimeInfo
+	for _, v := range validators {
+		record := append(s.downtime.records[v], signed[v])
+		if len(record) > window {
+			record = record[len(record)-window:]
+		}
+		s.downtime.records[v] = record
+
+		missed := 0
+		for _, signed := range record {
+			if !signed {
+				missed++
+			}
+		}
+		over := len(record) == window && float64(missed) > s.cfg.MaxMissedRatio*float64(window)
+		if over && !s.downtime.down[v] {
+			downs = append(downs, DowntimeInfo{Validator: v, Round: round, Missed: missed, Window: window})
+		}
+		s.downtime.down[v] = over
+	}
+	return downs, s.downtime.hooks
+}
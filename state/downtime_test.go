@@ -0,0 +1,67 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/crypto"
+)
+
+func TestRecordQCDowntime(t *testing.T) {
+	s := &State{
+		cfg:      &ConsensusConfig{DowntimeWindow: 4, MaxMissedRatio: 0.5},
+		downtime: downtimeTracker{records: make(map[PeerID][]bool), down: make(map[PeerID]bool)},
+		log:      logs.NewLogger(),
+	}
+	validators := []PeerID{"a", "b", "c", "d"}
+	qc := func(round int64, signers ...PeerID) []PeerID { return signers }
+
+	// d misses 3 of the first 4 QCs, it's judged only once the window is full
+	for round := int64(1); round <= 3; round++ {
+		if downs, _ := s.recordQC(round, qc(round, "a", "b", "c"), validators); len(downs) != 0 {
+			t.Fatalf("round %d, window isn't full, has: %+v", round, downs)
+		}
+	}
+	downs, _ := s.recordQC(4, qc(4, "a", "b", "c", "d"), validators)
+	if len(downs) != 1 || downs[0].Validator != "d" || downs[0].Missed != 3 || downs[0].Window != 4 {
+		t.Fatalf("d should be down, has: %+v", downs)
+	}
+	// a QC is tracked once, and a down validator is reported again only after it recovers
+	if downs, _ := s.recordQC(4, qc(4), validators); len(downs) != 0 {
+		t.Errorf("duplicated qc should be ignored, has: %+v", downs)
+	}
+	if downs, _ := s.recordQC(5, qc(5, "a", "b", "c"), validators); len(downs) != 0 {
+		t.Errorf("d has been reported, has: %+v", downs)
+	}
+	for round := int64(6); round <= 7; round++ {
+		s.recordQC(round, qc(round, "a", "b", "c", "d"), validators)
+	}
+	for _, p := range s.Participation() {
+		if p.Down || (p.Validator == "d" && p.Missed != 1) {
+			t.Errorf("unexpected participation: %+v", p)
+		}
+	}
+	s.recordQC(8, qc(8, "a", "b", "c"), validators)
+	s.recordQC(9, qc(9, "a", "b", "c"), validators)
+	if downs, _ := s.recordQC(10, qc(10, "a", "b", "c"), validators); len(downs) != 1 || downs[0].Round != 10 {
+		t.Errorf("d should be down again, has: %+v", downs)
+	}
+}
+
+func TestParticipationSigners(t *testing.T) {
+	s := &State{
+		crypto:   &crypto.DefaultCryptoClient{},
+		cfg:      &ConsensusConfig{ChainID: "test", DowntimeWindow: 4},
+		election: NewDefaultElection(1, []PeerID{"a", "b", "c", "d"}),
+	}
+	b, _ := newSignedChain(t, map[int64][]string{1: {"a", "b"}}, 1).Load(1)
+	raw, _ := DefaultDeserialize(b.QC)
+	qc := raw.(DefaultQuorumCert)
+	// the signs without a valid signed vote don't count, nor the vote signed by another validator
+	qc.Signs["c"] = DefaultSign{PeerID: "c"}
+	qc.Signs["d"] = qc.Signs["a"]
+	signers := s.qcVerifier().signers(qc, s.election.Validators(1, nil))
+	if len(signers) != 2 || signers[0] != "a" || signers[1] != "b" {
+		t.Errorf("want the signers a and b, has: %v", signers)
+	}
+}
@@ -0,0 +1,146 @@
+package state
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+const (
+	DoubleVote     = "double_vote"
+	DoubleProposal = "double_proposal"
+)
+
+var (
+	ErrValidatorExcluded = errors.New("validator is excluded for its misbehavior")
+)
+
+// Misbehavior is the local evidence of an equivocating validator: it has signed both First and Second
+// in the round, i.e. two votes or two proposals.
+type Misbehavior struct {
+	Validator PeerID `json:"validator"`
+	Kind      string `json:"kind"`
+	Round     int64  `json:"round"`
+	First     string `json:"first"`
+	Second    string `json:"second"`
+	// Ejected is set once the application has confirmed the ejection, the validator is suspended until then.
+	Ejected bool `json:"ejected"`
+}
+
+// EjectionJudge is implemented by the executors which decide the ejection of the misbehaving validators.
+// It's consulted by the execRoutine once the block of an epoch boundary is executed, so that the decision
+// is made on the application state of the boundary.
+type EjectionJudge interface {
+	// JudgeEjection returns whether the validator is ejected for the misbehavior,
+	// decided is false if the application defers the decision to a later epoch.
+	JudgeEjection(height int64, m Misbehavior) (eject, decided bool)
+}
+
+type ejectionTracker struct {
+	sync.Mutex
+	// the first evidence of every misbehaving validator, they are excluded with the defensive mode on
+	evidences map[PeerID]Misbehavior
+	// the latest epoch whose boundary has been judged
+	judgedEpoch int64
+}
+
+// Misbehaviors returns the evidences recorded by the host sorted by the validator.
+func (s *State) Misbehaviors() []Misbehavior {
+	s.ejection.Lock()
+	defer s.ejection.Unlock()
+
+	var list []Misbehavior
+	for _, m := range s.ejection.evidences {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Validator < list[j].Validator })
+	return list
+}
+
+// excluded reports whether the future votes and proposals of the validator are ignored by the host.
+// The validator still counts in the quorum of every round, only its msgs are dropped, so that the
+// ejection never lowers the quorum which the QCs formed before and after it need.
+func (s *State) excluded(v PeerID) bool {
+	if !s.cfg.EjectMisbehaving {
+		return false
+	}
+	s.ejection.Lock()
+	defer s.ejection.Unlock()
+
+	_, ok := s.ejection.evidences[v]
+	return ok
+}
+
+// reportMisbehavior records the evidence, the validator is suspended with the defensive mode on,
+// only the first evidence of a validator is kept.
+func (s *State) reportMisbehavior(m Misbehavior) {
+	s.ejection.Lock()
+	if _, ok := s.ejection.evidences[m.Validator]; ok {
+		s.ejection.Unlock()
+		return
+	}
+	s.ejection.evidences[m.Validator] = m
+	s.ejection.Unlock()
+
+	s.log.Warn("validator misbehaves @ state.reportMisbehavior, validator: %s, kind: %s, round: %d, first: %s, second: %s, excluded: %v",
+		m.Validator, m.Kind, m.Round, m.First, m.Second, s.cfg.EjectMisbehaving)
+	s.publish(libs.Event{
+		Type:   libs.EventMisbehavior,
+		Module: libs.ChainModule(s.cfg.ChainID, libs.ConsensusModule),
+		Data: libs.MisbehaviorEventData{
+			Validator: string(m.Validator),
+			Kind:      m.Kind,
+			Round:     m.Round,
+			First:     m.First,
+			Second:    m.Second,
+		},
+	})
+}
+
+// checkDoubleProposal reports the proposer if it has proposed another payload in the same round,
+// it's invoked with the procedure mutex held before the payload of the proposal is kept.
+func (s *State) checkDoubleProposal(round int64, id string, proposer string) {
+	for other, p := range s.payloads {
+		if p.round == round && p.proposer == proposer && other != id {
+			s.reportMisbehavior(Misbehavior{Validator: PeerID(proposer), Kind: DoubleProposal, Round: round, First: other, Second: id})
+			return
+		}
+	}
+}
+
+// judgeEjections asks the application about the suspended validators once the first block of an epoch
+// is executed, the rejected ones are reinstated, the undecided ones stay suspended.
+func (s *State) judgeEjections(height int64) {
+	judge, ok := s.executor.(EjectionJudge)
+	if !ok || !s.cfg.EjectMisbehaving {
+		return
+	}
+	s.ejection.Lock()
+	defer s.ejection.Unlock()
+
+	// the block of the boundary round itself may be missing after a view change
+	epoch := height / s.ParamsAt(height).EpochLength
+	if epoch <= s.ejection.judgedEpoch {
+		return
+	}
+	s.ejection.judgedEpoch = epoch
+
+	for v, m := range s.ejection.evidences {
+		if m.Ejected {
+			continue
+		}
+		eject, decided := judge.JudgeEjection(height, m)
+		if !decided {
+			continue
+		}
+		if eject {
+			m.Ejected = true
+			s.ejection.evidences[v] = m
+		} else {
+			delete(s.ejection.evidences, v)
+		}
+		s.log.Info("ejection judged @ state.judgeEjections, height: %d, validator: %s, ejected: %v", height, v, eject)
+	}
+}
@@ -0,0 +1,75 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+// judgeExecutor ejects the validators in eject, rejects the ones in keep and defers the rest.
+type judgeExecutor struct {
+	heightExecutor
+	eject, keep map[PeerID]bool
+}
+
+func (e *judgeExecutor) JudgeEjection(height int64, m Misbehavior) (bool, bool) {
+	return e.eject[m.Validator], e.eject[m.Validator] || e.keep[m.Validator]
+}
+
+func TestEjectMisbehaving(t *testing.T) {
+	bus := libs.NewDefaultEventBus(logs.NewLogger())
+	events := bus.Subscribe(libs.EventMisbehavior)
+	cfg := &ConsensusConfig{EpochLength: 10, EjectMisbehaving: true}
+	s := &State{
+		cfg:      cfg,
+		params:   newParamsHistory(cfg),
+		payloads: make(map[string]pendingPayload),
+		ejection: ejectionTracker{evidences: make(map[PeerID]Misbehavior)},
+		executor: &judgeExecutor{eject: map[PeerID]bool{"a": true}, keep: map[PeerID]bool{"b": true}},
+		eventBus: bus,
+		log:      logs.NewLogger(),
+	}
+	s.payloads["x"] = pendingPayload{round: 3, proposer: "a"}
+	s.checkDoubleProposal(3, "x", "a")
+	s.checkDoubleProposal(4, "y", "a")
+	if s.excluded("a") {
+		t.Fatal("a hasn't equivocated yet")
+	}
+	s.checkDoubleProposal(3, "z", "a")
+	for _, v := range []PeerID{"b", "c"} {
+		s.reportMisbehavior(Misbehavior{Validator: v, Kind: DoubleVote, Round: 5, First: "p", Second: "q"})
+	}
+	select {
+	case e := <-events:
+		if data := e.Data.(libs.MisbehaviorEventData); data.Validator != "a" || data.Kind != DoubleProposal || data.First != "x" {
+			t.Errorf("unexpected evidence: %+v", data)
+		}
+	default:
+		t.Error("want the misbehavior event")
+	}
+	for _, v := range []PeerID{"a", "b", "c"} {
+		if !s.excluded(v) {
+			t.Errorf("want %s excluded", v)
+		}
+	}
+
+	// judged once the first block of the next epoch is executed
+	s.judgeEjections(9)
+	if len(s.Misbehaviors()) != 3 {
+		t.Errorf("want every validator suspended in the epoch, has: %+v", s.Misbehaviors())
+	}
+	s.judgeEjections(12)
+	list := s.Misbehaviors()
+	if len(list) != 2 || !list[0].Ejected || list[1].Validator != "c" || list[1].Ejected {
+		t.Errorf("want a ejected, b reinstated and c suspended, has: %+v", list)
+	}
+	if !s.excluded("a") || s.excluded("b") || !s.excluded("c") {
+		t.Error("unexpected exclusion after judging")
+	}
+
+	s.cfg.EjectMisbehaving = false
+	if s.excluded("a") {
+		t.Error("nothing is excluded without the defensive mode")
+	}
+}
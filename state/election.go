@@ -15,6 +15,26 @@ type ProposerElection interface {
 	Update(round int64, next []PeerID) error
 }
 
+// ValidatorHistory is implemented by the elections which keep every epoch,
+// so that the membership changes can be queried cheaply.
+type ValidatorHistory interface {
+	// ValidatorChanges returns the diffs of the epochs started in (from, to].
+	ValidatorChanges(from, to int64) []ValidatorDiff
+}
+
+// EpochElection is implemented by the elections which number the epochs, the epoch of the round
+// separates the signing domains, so that a msg signed in an epoch is never valid in another one.
+type EpochElection interface {
+	Epoch(round int64) int64
+}
+
+// ValidatorDiff is the membership change when a new epoch starts at the height.
+type ValidatorDiff struct {
+	Height  int64    `json:"height"`
+	Added   []PeerID `json:"added,omitempty"`
+	Removed []PeerID `json:"removed,omitempty"`
+}
+
 func NewDefaultElection(start int64, init []PeerID) *DefaultElection {
 	var validatorsStep []StepValidators
 	validatorsStep = append(validatorsStep, StepValidators{
@@ -58,16 +78,78 @@ func (e *DefaultElection) Validators(round int64, roundTimeoutIdxMap map[int64]i
 	return nil
 }
 
-// Leader indicates that the system cannot rollback.
+// Leader indicates that the system cannot rollback, the leaders rotate among the validators of the round,
+// so that the validators scheduled for a later epoch don't change the leaders before it starts.
 func (e *DefaultElection) Leader(round int64, roundTimeoutIdxMap map[int64]int64) PeerID {
-	idx := round % int64(len(e.validators))
-	return e.validators[int(idx)]
+	validators := e.Validators(round, roundTimeoutIdxMap)
+	if len(validators) == 0 {
+		e.mtx.Lock()
+		validators = e.validatorsStep[0].Validators
+		e.mtx.Unlock()
+	}
+	idx := round % int64(len(validators))
+	return validators[int(idx)]
+}
+
+// Epoch returns the start round of the validator set of the round.
+func (e *DefaultElection) Epoch(round int64) int64 {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	for i := len(e.validatorsStep) - 1; i >= 0; i-- {
+		if round >= e.validatorsStep[i].Start {
+			return e.validatorsStep[i].Start
+		}
+	}
+	return 0
+}
+
+func (e *DefaultElection) ValidatorChanges(from, to int64) []ValidatorDiff {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	var diffs []ValidatorDiff
+	for i := 1; i < len(e.validatorsStep); i++ {
+		step := e.validatorsStep[i]
+		if step.Start <= from || step.Start > to {
+			continue
+		}
+		diffs = append(diffs, diffValidators(step.Start, e.validatorsStep[i-1].Validators, step.Validators))
+	}
+	return diffs
+}
+
+func diffValidators(height int64, prev, next []PeerID) ValidatorDiff {
+	diff := ValidatorDiff{Height: height}
+	prevSet := make(map[PeerID]struct{}, len(prev))
+	nextSet := make(map[PeerID]struct{}, len(next))
+	for _, v := range prev {
+		prevSet[v] = struct{}{}
+	}
+	for _, v := range next {
+		nextSet[v] = struct{}{}
+		if _, ok := prevSet[v]; !ok {
+			diff.Added = append(diff.Added, v)
+		}
+	}
+	for _, v := range prev {
+		if _, ok := nextSet[v]; !ok {
+			diff.Removed = append(diff.Removed, v)
+		}
+	}
+	return diff
 }
 
 func (e *DefaultElection) Update(round int64, next []PeerID) error {
 	e.mtx.Lock()
 	defer e.mtx.Unlock()
 
+	// the changes scheduled for the same round after the initial validators are merged
+	if last := len(e.validatorsStep) - 1; round == e.start && last > 0 {
+		e.validatorsStep[last].Validators = next
+		e.validators = next
+		return nil
+	}
 	if round <= e.start {
 		return fmt.Errorf("round invalid, has been occupied")
 	}
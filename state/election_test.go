@@ -0,0 +1,30 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidatorChanges(t *testing.T) {
+	e := NewDefaultElection(0, []PeerID{"a", "b", "c"})
+	if err := e.Update(10, []PeerID{"a", "b", "d"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Update(20, []PeerID{"b", "d"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ValidatorDiff{
+		{Height: 10, Added: []PeerID{"d"}, Removed: []PeerID{"c"}},
+		{Height: 20, Removed: []PeerID{"a"}},
+	}
+	if diffs := e.ValidatorChanges(0, 20); !reflect.DeepEqual(diffs, want) {
+		t.Errorf("want: %+v, has: %+v", want, diffs)
+	}
+	if diffs := e.ValidatorChanges(10, 19); len(diffs) != 0 {
+		t.Errorf("want no diffs, has: %+v", diffs)
+	}
+	if vals := e.Validators(15, nil); !reflect.DeepEqual(vals, []PeerID{"a", "b", "d"}) {
+		t.Errorf("unexpected validators at 15: %+v", vals)
+	}
+}
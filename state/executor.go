@@ -0,0 +1,194 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aucusaga/gohotstuff/state/bt"
+	"github.com/aucusaga/gohotstuff/storage"
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+var (
+	ErrNoExecutor         = errors.New("executor is not set")
+	ErrHeightNotCommitted = errors.New("height has not been committed")
+	ErrNoResultStore      = errors.New("block results are not persisted")
+)
+
+// Executor applies the committed blocks to the application state, and serves the reads of it.
+// The height is the round of the committed block.
+type Executor interface {
+	// Execute is invoked in the order of the commits, txs is empty for an empty block,
+	// the results are indexed by the tx index in the block.
+	Execute(height int64, txs [][]byte) ([]types.TxResult, error)
+	// Query reads the value at path as of the committed height, the proof ties the value
+	// to the state after the block at the height, e.g. a merkle proof against the app hash.
+	Query(path string, data []byte, height int64) (value []byte, proof []byte, err error)
+}
+
+// QueryResult is the answer of the executor at a committed height.
+type QueryResult struct {
+	Height int64
+	Value  []byte
+	Proof  []byte
+}
+
+// SetExecutor makes the committed blocks applied by the executor.
+func (s *State) SetExecutor(e Executor) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.executor != nil {
+		return ErrComponentsOccupied
+	}
+	s.executor = e
+	return nil
+}
+
+// Query reads the application state at the executed height, the latest one if height is 0.
+func (s *State) Query(path string, data []byte, height int64) (*QueryResult, error) {
+	s.mtx.RLock()
+	executor := s.executor
+	s.mtx.RUnlock()
+	executed := s.ExecutedHeight()
+
+	if executor == nil {
+		return nil, ErrNoExecutor
+	}
+	if height == 0 {
+		height = executed
+	}
+	if height < 0 || height > executed {
+		return nil, fmt.Errorf("%w, height: %d, executed: %d", ErrHeightNotCommitted, height, executed)
+	}
+	value, proof, err := executor.Query(path, data, height)
+	if err != nil {
+		return nil, err
+	}
+	return &QueryResult{Height: height, Value: value, Proof: proof}, nil
+}
+
+// BlockResults returns the tx results of the executed block, the latest one if height is 0.
+func (s *State) BlockResults(height int64) (*storage.BlockResults, error) {
+	executed := s.ExecutedHeight()
+	if s.results == nil {
+		return nil, ErrNoResultStore
+	}
+	if height == 0 {
+		height = executed
+	}
+	if height < 0 || height > executed {
+		return nil, fmt.Errorf("%w, height: %d, executed: %d", ErrHeightNotCommitted, height, executed)
+	}
+	return s.results.Load(height)
+}
+
+// BlockHeights returns the heights of the persisted blocks in [from, to] ascending, to is unbounded if it's 0.
+func (s *State) BlockHeights(from, to int64) ([]int64, error) {
+	if s.blocks == nil {
+		return nil, ErrNoBlockStore
+	}
+	return s.blocks.Heights(from, to)
+}
+
+// Export streams the persisted blocks in [from, to] with their results to w in the format, see storage.Export.
+func (s *State) Export(from, to int64, format string, w io.Writer) (int, error) {
+	if s.blocks == nil {
+		return 0, ErrNoBlockStore
+	}
+	return storage.Export(s.blocks, s.results, from, to, format, w)
+}
+
+// CommittedBlock returns the persisted block of the height with its QC.
+func (s *State) CommittedBlock(height int64) (*storage.CommittedBlock, error) {
+	if s.blocks == nil {
+		return nil, ErrNoBlockStore
+	}
+	return s.blocks.Load(height)
+}
+
+// execute applies the committed block and persists its results, it's invoked by the execRoutine
+// in the order of the commits, the application state advances once the block is applied.
+// It returns false if the failure policy halts the host, the later blocks mustn't be applied then.
+func (s *State) execute(height int64, txs [][]byte, exts []VoteExtension) bool {
+	if s.executor == nil {
+		return true
+	}
+	var results []types.TxResult
+	exec := func() (err error) {
+		if e, ok := s.executor.(ExtensionExecutor); ok {
+			results, err = e.ExecuteExtended(height, txs, exts)
+			return err
+		}
+		results, err = s.executor.Execute(height, txs)
+		return err
+	}
+	if err := s.handleFailure(FailureExecutor, false, exec(), exec); err != nil {
+		s.log.Error("execute block fail @ state.execute, height: %d, err: %v", height, err)
+		return !errors.Is(err, ErrDegraded)
+	}
+	defer s.advanceExecuted(height)
+	if len(results) != len(txs) {
+		s.log.Warn("results mismatch the txs @ state.execute, height: %d, results: %d, txs: %d", height, len(results), len(txs))
+	}
+	if s.results == nil {
+		return true
+	}
+	save := func() error { return s.results.Save(&storage.BlockResults{Height: height, Txs: results}) }
+	if err := s.handleFailure(FailureStorage, false, save(), save); err != nil {
+		s.log.Error("save block results fail @ state.execute, height: %d, err: %v", height, err)
+	}
+	return true
+}
+
+// committedBlock builds the record of the committed node with its QC.
+func (s *State) committedBlock(node *bt.Node, p pendingPayload) (*storage.CommittedBlock, error) {
+	qc, err := s.tree.DeserializeF(node.Value)
+	if err != nil {
+		return nil, err
+	}
+	_, id, err := qc.Proposal()
+	if err != nil {
+		return nil, err
+	}
+	parentRound, parentID, err := qc.ParentProposal()
+	if err != nil {
+		return nil, err
+	}
+	return &storage.CommittedBlock{
+		Height:         node.Round,
+		ID:             id,
+		ParentRound:    parentRound,
+		ParentID:       parentID,
+		Proposer:       qc.Sender(),
+		Txs:            p.txs,
+		QC:             node.Value,
+		AppHeight:      p.appHeight,
+		AppHash:        p.appHash,
+		Time:           p.timestamp,
+		VoteExtensions: p.signedExtensions,
+	}, nil
+}
+
+// saveBlock persists the committed block, it's invoked with the procedure mutex held.
+func (s *State) saveBlock(block *storage.CommittedBlock) {
+	if s.blocks == nil {
+		return
+	}
+	save := func() error { return s.blocks.Save(block) }
+	if err := s.handleFailure(FailureStorage, true, save(), save); err != nil {
+		s.log.Error("save committed block fail @ state.saveBlock, round: %d, err: %v", block.Height, err)
+	}
+}
+
+// NopExecutor accepts every tx without any application state, e.g. for the dev chain.
+type NopExecutor struct{}
+
+func (NopExecutor) Execute(height int64, txs [][]byte) ([]types.TxResult, error) {
+	return make([]types.TxResult, len(txs)), nil
+}
+
+func (NopExecutor) Query(path string, data []byte, height int64) ([]byte, []byte, error) {
+	return nil, nil, nil
+}
@@ -0,0 +1,207 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/types"
+	"github.com/golang/protobuf/proto"
+)
+
+// DefaultMaxVoteExtensionBytes bounds the extension attached to a vote.
+const DefaultMaxVoteExtensionBytes = 1024
+
+var (
+	ErrInvalidVoteExtension = errors.New("invalid vote extension")
+)
+
+// VoteExtender attaches the application data to the votes of the host, e.g. the price oracle data,
+// and verifies the ones of the others. The signed votes certifying a block are carried by the next
+// proposal, and their extensions are delivered to the executor along with its block, see ExtensionExecutor.
+// Both are invoked by the receiveRoutine, so they must not block.
+type VoteExtender interface {
+	// ExtendVote returns the extension of the vote of the host for the proposal, empty attaches nothing.
+	ExtendVote(round int64, id []byte) ([]byte, error)
+	// VerifyVoteExtension rejects the vote whose extension is invalid, ext is empty if nothing is attached.
+	VerifyVoteExtension(validator PeerID, round int64, id []byte, ext []byte) error
+}
+
+// VoteExtension is the application data attached by Validator to its vote for the block of Round.
+type VoteExtension struct {
+	Validator PeerID
+	Round     int64
+	ID        []byte
+	Extension []byte
+}
+
+// ExtensionExecutor is the executor receiving the vote extensions carried by the committed blocks,
+// ExecuteExtended is invoked instead of Execute. The extensions of a block may be carried again by
+// a later block certifying it after a view change, they are told apart by their round.
+type ExtensionExecutor interface {
+	Executor
+	ExecuteExtended(height int64, txs [][]byte, exts []VoteExtension) ([]types.TxResult, error)
+}
+
+// voteExtensions are the signed votes carrying the extensions collected by the leader,
+// map[round][proposal_id][]signed vote, guarded by the procedure mutex.
+type voteExtensions map[int64]map[string][][]byte
+
+// RegisterVoteExtender makes the host attach the application data to its votes and verify the ones of the others.
+func (s *State) RegisterVoteExtender(e VoteExtender) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.extender != nil {
+		return ErrComponentsOccupied
+	}
+	s.extender = e
+	return nil
+}
+
+// extendVote attaches the application data to the vote of the host, which goes without it on failure.
+func (s *State) extendVote(vote *types.VoteMsg) {
+	if s.extender == nil {
+		return
+	}
+	ext, err := s.extender.ExtendVote(vote.Round, vote.ID)
+	if err != nil {
+		s.log.Error("extend vote fail @ state.extendVote, round: %d, id: %s, err: %v", vote.Round, libs.F(vote.ID), err)
+		return
+	}
+	if len(ext) > s.cfg.MaxVoteExtensionBytes {
+		s.log.Error("oversize vote extension @ state.extendVote, round: %d, size: %d, max: %d",
+			vote.Round, len(ext), s.cfg.MaxVoteExtensionBytes)
+		return
+	}
+	vote.Extension = ext
+}
+
+// checkVoteExtension verifies the extension of the vote before it's counted.
+func (s *State) checkVoteExtension(vote *types.VoteMsg) error {
+	if len(vote.Extension) > s.cfg.MaxVoteExtensionBytes {
+		return fmt.Errorf("%w, validator: %s, size: %d, max: %d", ErrInvalidVoteExtension,
+			vote.SendID, len(vote.Extension), s.cfg.MaxVoteExtensionBytes)
+	}
+	if s.extender == nil {
+		return nil
+	}
+	if err := s.extender.VerifyVoteExtension(PeerID(vote.SendID), vote.Round, vote.ID, vote.Extension); err != nil {
+		return fmt.Errorf("%w, validator: %s, err: %v", ErrInvalidVoteExtension, vote.SendID, err)
+	}
+	return nil
+}
+
+// collectVoteExtension keeps the counted vote carrying an extension for the proposal certifying its block.
+func (s *State) collectVoteExtension(vote *types.VoteMsg) {
+	if len(vote.Extension) == 0 {
+		return
+	}
+	signed, err := s.signedVote(vote)
+	if err != nil {
+		s.log.Error("rebuild signed vote fail @ state.collectVoteExtension, vote: %s, err: %v", vote.String(), err)
+		return
+	}
+	if s.extensions == nil {
+		s.extensions = make(voteExtensions)
+	}
+	for round := range s.extensions {
+		if round <= s.committedRound {
+			delete(s.extensions, round)
+		}
+	}
+	if _, ok := s.extensions[vote.Round]; !ok {
+		s.extensions[vote.Round] = make(map[string][][]byte)
+	}
+	s.extensions[vote.Round][libs.F(vote.ID)] = append(s.extensions[vote.Round][libs.F(vote.ID)], signed)
+}
+
+// certifiedExtensions returns the signed votes carrying the extensions of the block certified by the justify.
+func (s *State) certifiedExtensions(justify []byte) [][]byte {
+	qc, err := s.tree.DeserializeF(justify)
+	if err != nil {
+		return nil
+	}
+	round, id, err := qc.Proposal()
+	if err != nil {
+		return nil
+	}
+	return s.extensions[round][libs.F(id)]
+}
+
+// verifyVoteExtensions verifies the signed votes carried by the proposal, each must be a vote of a distinct
+// validator for the block of round certified by the justify, whose extension passes checkVoteExtension.
+func (s *State) verifyVoteExtensions(signed [][]byte, round int64, id []byte) ([]VoteExtension, error) {
+	if len(signed) == 0 {
+		return nil, nil
+	}
+	validators := make(map[PeerID]bool)
+	for _, v := range s.election.Validators(round, s.timeoutSet.GetTimeoutIdxMap()) {
+		validators[v] = true
+	}
+	exts := make([]VoteExtension, 0, len(signed))
+	seen := make(map[PeerID]bool, len(signed))
+	for i, msgbytes := range signed {
+		vote, err := s.decodeSignedVote(msgbytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w, index: %d, err: %v", ErrInvalidVoteExtension, i, err)
+		}
+		sender := PeerID(vote.SendID)
+		switch {
+		case vote.Round != round || !bytes.Equal(vote.ID, id):
+			return nil, fmt.Errorf("%w, index: %d, want: %d, has: %d", ErrInvalidVoteExtension, i, round, vote.Round)
+		case !validators[sender]:
+			return nil, fmt.Errorf("%w, index: %d, not a validator: %s", ErrInvalidVoteExtension, i, sender)
+		case seen[sender]:
+			return nil, fmt.Errorf("%w, index: %d, duplicated validator: %s", ErrInvalidVoteExtension, i, sender)
+		case len(vote.Extension) == 0:
+			return nil, fmt.Errorf("%w, index: %d, empty extension of %s", ErrInvalidVoteExtension, i, sender)
+		}
+		if err := s.checkVoteExtension(vote); err != nil {
+			return nil, err
+		}
+		seen[sender] = true
+		exts = append(exts, VoteExtension{Validator: sender, Round: vote.Round, ID: vote.ID, Extension: vote.Extension})
+	}
+	return exts, nil
+}
+
+// decodeSignedVote verifies the signed vote as it's received from the peers.
+func (s *State) decodeSignedVote(msgbytes []byte) (*types.VoteMsg, error) {
+	valid, err := s.crypto.Verify(nil, nil, msgbytes)
+	if err != nil || !valid {
+		return nil, fmt.Errorf("invalid signature, valid: %v, err: %v", valid, err)
+	}
+	msg, err := ConsMsgFromProto(msgbytes)
+	if err != nil {
+		return nil, err
+	}
+	vote, ok := msg.(*types.VoteMsg)
+	if !ok {
+		return nil, fmt.Errorf("not a vote, type: %T", msg)
+	}
+	if err := s.checkDomain(msgbytes, vote); err != nil {
+		return nil, err
+	}
+	if err := s.checkKey(vote); err != nil {
+		return nil, err
+	}
+	return vote, nil
+}
+
+// signedVote rebuilds the vote as signed by its sender, which has passed the checks of the domain.
+func (s *State) signedVote(vote *types.VoteMsg) ([]byte, error) {
+	msgbytes, err := ForkProtoFromConsMsg(vote, s.cfg.ChainID, s.cfg.ForkID, s.epoch(vote.Round))
+	if err != nil {
+		return nil, err
+	}
+	var msg pb.Message
+	if err := proto.Unmarshal(msgbytes, &msg); err != nil {
+		return nil, err
+	}
+	msg.GetVote().Pk = vote.PublicKey
+	msg.GetVote().Signature = vote.Signature
+	return proto.Marshal(&msg)
+}
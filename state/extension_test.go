@@ -0,0 +1,108 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+// quoteExtender attaches the quote of the host and refuses the quotes of zero.
+type quoteExtender struct {
+	quote string
+}
+
+func (e *quoteExtender) ExtendVote(round int64, id []byte) ([]byte, error) {
+	return []byte(e.quote), nil
+}
+
+func (e *quoteExtender) VerifyVoteExtension(validator PeerID, round int64, id []byte, ext []byte) error {
+	if string(ext) == "0" {
+		return fmt.Errorf("zero quote of %s", validator)
+	}
+	return nil
+}
+
+func TestVoteExtensions(t *testing.T) {
+	qa, qb, qc := keyPeers[0], keyPeers[1], keyPeers[2]
+	cfg := &ConsensusConfig{ChainID: "test", MaxVoteExtensionBytes: 8}
+	s := &State{
+		cfg:        cfg,
+		params:     newParamsHistory(cfg),
+		crypto:     newKeyClient(t),
+		election:   NewDefaultElection(0, []PeerID{qa, qb}),
+		timeoutSet: NewTimeoutSet(0, 0),
+		log:        logs.NewLogger(),
+	}
+	if err := s.RegisterVoteExtender(&quoteExtender{quote: "42"}); err != nil {
+		t.Fatal(err)
+	}
+	id := []byte("block")
+	sign := func(v PeerID, round int64, ext string) (*types.VoteMsg, []byte) {
+		vote := &types.VoteMsg{Round: round, ID: id, SendID: string(v), Extension: []byte(ext)}
+		signed, err := s.signMsg(vote)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := s.decodeSignedVote(signed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return decoded, signed
+	}
+
+	vote := &types.VoteMsg{Round: 5, ID: id, SendID: string(qa)}
+	s.extendVote(vote)
+	if string(vote.Extension) != "42" {
+		t.Fatalf("want the quote attached, has: %q", vote.Extension)
+	}
+
+	va, _ := sign(qa, 5, "42")
+	vb, _ := sign(qb, 5, "43")
+	for _, v := range []*types.VoteMsg{va, vb} {
+		if err := s.checkVoteExtension(v); err != nil {
+			t.Fatal(err)
+		}
+		s.collectVoteExtension(v)
+	}
+	collected := s.extensions[5][string(id)]
+	if len(collected) != 2 {
+		t.Fatalf("want 2 signed votes collected, has: %d", len(collected))
+	}
+	exts, err := s.verifyVoteExtensions(collected, 5, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exts) != 2 || exts[0].Validator != qa || string(exts[1].Extension) != "43" {
+		t.Fatalf("unexpected extensions: %+v", exts)
+	}
+
+	_, stranger := sign(qc, 5, "42")
+	_, zero := sign(qb, 5, "0")
+	_, oversize := sign(qb, 5, "123456789")
+	_, late := sign(qb, 6, "42")
+	tampered := append([]byte(nil), collected[1]...)
+	tampered[len(tampered)-1] ^= 0xff
+	for i, signed := range [][][]byte{
+		{collected[0], collected[0]},
+		{collected[0], stranger},
+		{collected[0], zero},
+		{collected[0], oversize},
+		{collected[0], late},
+		{collected[0], tampered},
+	} {
+		if _, err := s.verifyVoteExtensions(signed, 5, id); !errors.Is(err, ErrInvalidVoteExtension) {
+			t.Errorf("case %d: want invalid vote extension err, has: %v", i, err)
+		}
+	}
+
+	// the collected votes are dropped once their round is committed
+	s.committedRound = 5
+	s.collectVoteExtension(&types.VoteMsg{Round: 7, ID: id, SendID: string(qa), Extension: []byte("42"),
+		PublicKey: va.PublicKey, Signature: va.Signature})
+	if _, ok := s.extensions[5]; ok {
+		t.Errorf("want the votes of the committed round pruned")
+	}
+}
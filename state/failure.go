@@ -0,0 +1,151 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+// DefaultFailureBackoff is the first backoff of FailureRetry, it doubles on every retry.
+const DefaultFailureBackoff = 100 * time.Millisecond
+
+var (
+	ErrWALWrite             = errors.New("write wal fail")
+	ErrDegraded             = errors.New("consensus is halted by the failure policy")
+	ErrInvalidFailurePolicy = errors.New("invalid failure policy")
+)
+
+// FailureKind names the failures whose handling is decided by the operators, see FailurePolicy.
+type FailureKind string
+
+const (
+	// FailureWAL is a failed write of the wal.
+	FailureWAL FailureKind = "wal"
+	// FailureStorage is a failed write of the committed blocks, the block results or the outboxes of the commit sinks.
+	FailureStorage FailureKind = "storage"
+	// FailureExecutor is a block refused by the executor, it's retried with the same txs,
+	// so the executor must not apply a failed block partially.
+	FailureExecutor FailureKind = "executor"
+)
+
+// FailureAction is how the host reacts to a failure.
+type FailureAction string
+
+const (
+	// FailureIgnore logs the failure and goes on, it's the default of every kind.
+	FailureIgnore FailureAction = "ignore"
+	// FailureRetry retries the failed operation with backoff, the failure is handled
+	// by the fallback action once the retries run out.
+	FailureRetry FailureAction = "retry"
+	// FailureDegrade halts the consensus, the host stops voting and committing
+	// but keeps serving the reads of the committed blocks and the application state.
+	FailureDegrade FailureAction = "degrade"
+	// FailureHalt degrades the host and publishes libs.EventFatal, which the node stops on.
+	FailureHalt FailureAction = "halt"
+)
+
+// FailurePolicy decides the handling of a failure kind.
+type FailurePolicy struct {
+	Action FailureAction
+	// Retries and Backoff bound FailureRetry, the backoff is DefaultFailureBackoff if it's 0,
+	// Fallback handles the failure after the last retry, FailureIgnore if it's empty.
+	Retries  int
+	Backoff  time.Duration
+	Fallback FailureAction
+}
+
+func (p FailurePolicy) Validate() error {
+	switch p.Action {
+	case "", FailureIgnore, FailureDegrade, FailureHalt:
+	case FailureRetry:
+		if p.Retries <= 0 || p.Backoff < 0 {
+			return fmt.Errorf("%w, retry needs positive retries, policy: %+v", ErrInvalidFailurePolicy, p)
+		}
+	default:
+		return fmt.Errorf("%w, unknown action: %s", ErrInvalidFailurePolicy, p.Action)
+	}
+	switch p.Fallback {
+	case "", FailureIgnore, FailureDegrade, FailureHalt:
+		return nil
+	}
+	return fmt.Errorf("%w, unknown fallback: %s", ErrInvalidFailurePolicy, p.Fallback)
+}
+
+func validateFailurePolicies(policies map[FailureKind]FailurePolicy) error {
+	for kind, p := range policies {
+		switch kind {
+		case FailureWAL, FailureStorage, FailureExecutor:
+		default:
+			return fmt.Errorf("%w, unknown kind: %s", ErrInvalidFailurePolicy, kind)
+		}
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleFailure applies the policy of the kind to the err of the operation, retry runs the operation again,
+// the last err is returned if it still fails, wrapped in ErrDegraded if the host is halted for it.
+// mtxHeld tells whether the caller holds the procedure mutex, the retries block the caller meanwhile.
+func (s *State) handleFailure(kind FailureKind, mtxHeld bool, err error, retry func() error) error {
+	if err == nil {
+		return nil
+	}
+	// the voting stops at once on a disk failure, whatever the policy
+	s.suspendOnDisk(kind, err, retry)
+	p := s.cfg.FailurePolicies[kind]
+	action := p.Action
+	if action == FailureRetry {
+		backoff := p.Backoff
+		if backoff <= 0 {
+			backoff = DefaultFailureBackoff
+		}
+		for i := 0; i < p.Retries && err != nil; i++ {
+			s.log.Warn("retry the failed operation @ state.handleFailure, kind: %s, retry: %d, backoff: %v, err: %v",
+				kind, i+1, backoff, err)
+			select {
+			case <-s.clock.After(backoff):
+			case <-s.quit:
+				return err
+			}
+			backoff *= 2
+			err = retry()
+		}
+		if err == nil {
+			return nil
+		}
+		action = p.Fallback
+	}
+	switch action {
+	case FailureDegrade, FailureHalt:
+		if !mtxHeld {
+			s.mtx.Lock()
+			defer s.mtx.Unlock()
+		}
+		s.degrade(kind, action, err)
+		return fmt.Errorf("%w, kind: %s, err: %v", ErrDegraded, kind, err)
+	}
+	return err
+}
+
+// degrade halts the consensus on the failure, it's invoked with the procedure mutex held.
+func (s *State) degrade(kind FailureKind, action FailureAction, err error) {
+	if !s.halted {
+		s.halted = true
+		s.log.Error("consensus halted by the failure policy @ state.degrade, kind: %s, action: %s, err: %v", kind, action, err)
+	}
+	if action != FailureHalt {
+		return
+	}
+	s.publish(libs.Event{
+		Type:   libs.EventFatal,
+		Module: libs.ChainModule(s.cfg.ChainID, libs.ConsensusModule),
+		Data: libs.FatalEventData{
+			Kind:   string(kind),
+			Reason: err.Error(),
+		},
+	})
+}
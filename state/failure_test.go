@@ -0,0 +1,74 @@
+package state
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+func TestFailurePolicies(t *testing.T) {
+	bus := libs.NewDefaultEventBus(logs.NewLogger())
+	fatal := bus.Subscribe(libs.EventFatal)
+	s := &State{
+		cfg: &ConsensusConfig{FailurePolicies: map[FailureKind]FailurePolicy{
+			FailureStorage:  {Action: FailureRetry, Retries: 2, Backoff: time.Millisecond, Fallback: FailureDegrade},
+			FailureExecutor: {Action: FailureHalt},
+		}},
+		eventBus: bus,
+		quit:     make(chan struct{}),
+		clock:    libs.SystemClock,
+		log:      logs.NewLogger(),
+	}
+	errDisk := errors.New("disk is full")
+	failing := func(n int) func() error {
+		return func() error {
+			if n--; n >= 0 {
+				return errDisk
+			}
+			return nil
+		}
+	}
+
+	// the wal failures are ignored by default
+	if err := s.handleFailure(FailureWAL, true, errDisk, failing(10)); err != errDisk || s.halted {
+		t.Errorf("want the failure ignored, err: %v, halted: %v", err, s.halted)
+	}
+	// recovered by the second retry
+	if err := s.handleFailure(FailureStorage, true, errDisk, failing(1)); err != nil || s.halted {
+		t.Errorf("want the failure retried, err: %v, halted: %v", err, s.halted)
+	}
+	if err := s.handleFailure(FailureStorage, false, errDisk, failing(2)); !errors.Is(err, ErrDegraded) || !s.Halted() {
+		t.Errorf("want the host degraded, err: %v, halted: %v", err, s.halted)
+	}
+	select {
+	case e := <-fatal:
+		t.Errorf("degrading shouldn't be fatal, event: %+v", e)
+	default:
+	}
+
+	if err := s.handleFailure(FailureExecutor, false, errDisk, failing(0)); !errors.Is(err, ErrDegraded) {
+		t.Errorf("want the host halted, err: %v", err)
+	}
+	select {
+	case e := <-fatal:
+		if data := e.Data.(libs.FatalEventData); data.Kind != string(FailureExecutor) {
+			t.Errorf("unexpected fatal event: %+v", data)
+		}
+	case <-time.After(time.Second):
+		t.Error("want the fatal event")
+	}
+
+	for _, policies := range []map[FailureKind]FailurePolicy{
+		{"db": {Action: FailureHalt}},
+		{FailureWAL: {Action: "panic"}},
+		{FailureWAL: {Action: FailureRetry}},
+		{FailureWAL: {Action: FailureRetry, Retries: 1, Fallback: FailureRetry}},
+	} {
+		if err := validateFailurePolicies(policies); !errors.Is(err, ErrInvalidFailurePolicy) {
+			t.Errorf("want invalid policy, policies: %+v, err: %v", policies, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package state
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+const (
+	// maxCachedProposals bounds the signed proposals kept for the peers missing them.
+	maxCachedProposals = 256
+	// maxOrphanProposals bounds the proposals waiting for their missing parents.
+	maxOrphanProposals = 16
+	// fetchInterval throttles the requests of the same proposal.
+	fetchInterval = time.Second
+	// replyRetries is the number of the resends of a proposal reply which fails to be written.
+	replyRetries = 2
+)
+
+var (
+	ErrProposalNotCached = errors.New("proposal is not cached")
+)
+
+type cachedProposal struct {
+	round int64
+	msg   []byte
+}
+
+// proposalFetcher pulls the proposals which the host has seen the votes or the qc of but never received,
+// and serves the proposals it holds to the peers in the same situation.
+type proposalFetcher struct {
+	sync.Mutex
+	// the signed proposals by the id, they are replied as they are so that the signature of the leader holds
+	cache map[string]cachedProposal
+	// the proposals rejected for the missing parent, by the parent id
+	orphans map[string][]*types.ProposalMsg
+	// the missing proposals requested, by the id
+	requested map[string]fetchRequest
+}
+
+type fetchRequest struct {
+	round int64
+	at    time.Time
+}
+
+func newProposalFetcher() proposalFetcher {
+	return proposalFetcher{
+		cache:     make(map[string]cachedProposal),
+		orphans:   make(map[string][]*types.ProposalMsg),
+		requested: make(map[string]fetchRequest),
+	}
+}
+
+// cacheProposal keeps the signed proposal, the lowest rounds are evicted once the cache is full.
+func (s *State) cacheProposal(round int64, id []byte, msg []byte) {
+	s.fetcher.Lock()
+	defer s.fetcher.Unlock()
+
+	s.fetcher.cache[libs.F(id)] = cachedProposal{round: round, msg: msg}
+	if len(s.fetcher.cache) <= maxCachedProposals {
+		return
+	}
+	ids := make([]string, 0, len(s.fetcher.cache))
+	for id := range s.fetcher.cache {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return s.fetcher.cache[ids[i]].round < s.fetcher.cache[ids[j]].round })
+	for _, id := range ids[:len(ids)-maxCachedProposals] {
+		delete(s.fetcher.cache, id)
+	}
+}
+
+// requestProposal asks the peer who has shown the evidence of the proposal for it,
+// the orphan, if any, is processed again once the proposal arrives.
+func (s *State) requestProposal(round int64, id []byte, from PeerID, orphan *types.ProposalMsg) {
+	if from == s.host {
+		return
+	}
+	key := libs.F(id)
+	s.fetcher.Lock()
+	if orphan != nil && len(s.fetcher.orphans[key]) < maxOrphanProposals {
+		s.fetcher.orphans[key] = append(s.fetcher.orphans[key], orphan)
+	}
+	last, ok := s.fetcher.requested[key]
+	if ok && s.clock.Since(last.at) < fetchInterval {
+		s.fetcher.Unlock()
+		return
+	}
+	s.fetcher.requested[key] = fetchRequest{round: round, at: s.clock.Now()}
+	s.fetcher.Unlock()
+
+	s.log.Info("request the missing proposal @ state.requestProposal, round: %d, id: %s, to: %s", round, key, from)
+	s.senderQueue <- ProposalRequestMsg(round, id, string(from))
+}
+
+// onProposalFetched processes the orphans of the accepted proposal again.
+func (s *State) onProposalFetched(proposal *types.ProposalMsg) {
+	key := libs.F(proposal.ID)
+	s.fetcher.Lock()
+	orphans := s.fetcher.orphans[key]
+	delete(s.fetcher.orphans, key)
+	delete(s.fetcher.requested, key)
+	s.fetcher.Unlock()
+
+	for _, o := range orphans {
+		select {
+		case s.peerMsgQueue <- o:
+		default:
+			s.log.Warn("msg queue is full, drop the orphan @ state.onProposalFetched, proposal: %s", o.String())
+		}
+	}
+}
+
+// pruneFetches gives up the missing proposals which can no longer be committed.
+func (s *State) pruneFetches(committedRound int64) {
+	s.fetcher.Lock()
+	defer s.fetcher.Unlock()
+
+	for id, req := range s.fetcher.requested {
+		if req.round <= committedRound {
+			delete(s.fetcher.requested, id)
+			delete(s.fetcher.orphans, id)
+		}
+	}
+}
+
+// onReceiveProposalRequest replies the cached proposal to the requester.
+func (s *State) onReceiveProposalRequest(req *types.ProposalRequestMsg) error {
+	s.fetcher.Lock()
+	cached, ok := s.fetcher.cache[libs.F(req.ID)]
+	s.fetcher.Unlock()
+	if !ok {
+		return ErrProposalNotCached
+	}
+	p2pID, err := s.p2p.GetP2PID(req.SendID)
+	if err != nil {
+		return err
+	}
+	go s.awaitReply(s.p2p.SendWithResult(p2pID, s.channel, cached.msg), p2pID, req.Round, cached.msg)
+	return nil
+}
+
+// awaitReply resends the reply of a proposal request up to replyRetries times until it's written to the requester,
+// which would otherwise wait out the fetchInterval before requesting it again.
+func (s *State) awaitReply(result <-chan error, p2pID string, round int64, msg []byte) {
+	err := <-result
+	for i := 0; err != nil && i < replyRetries; i++ {
+		s.log.Warn("reply proposal fail, retry @ state.awaitReply, peer_id: %s, round: %d, retry: %d, err: %v",
+			p2pID, round, i+1, err)
+		err = <-s.p2p.SendWithResult(p2pID, s.channel, msg)
+	}
+	if err != nil {
+		s.log.Error("reply proposal fail @ state.awaitReply, peer_id: %s, round: %d, err: %v", p2pID, round, err)
+	}
+}
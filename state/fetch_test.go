@@ -0,0 +1,118 @@
+package state
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+type sentMsg struct {
+	to  string
+	msg []byte
+}
+
+type stubSwitch struct {
+	sent    []sentMsg
+	mtx     sync.Mutex
+	stopped []string
+}
+
+func (sw *stubSwitch) Broadcast(chID int32, msgBytes []byte)                  {}
+func (sw *stubSwitch) BroadcastToValidators(chID int32, msgBytes []byte)      {}
+func (sw *stubSwitch) SetValidatorSet(chID int32, set func() []string)        {}
+func (sw *stubSwitch) SetMessageExpiry(chID int32, expired func([]byte) bool) {}
+func (sw *stubSwitch) SetPullOnSlow(chID int32, pull func([]byte) bool)       {}
+func (sw *stubSwitch) Send(peerID string, chID int32, msgBytes []byte) error {
+	sw.sent = append(sw.sent, sentMsg{peerID, msgBytes})
+	return nil
+}
+func (sw *stubSwitch) SendWithResult(peerID string, chID int32, msgBytes []byte) <-chan error {
+	result := make(chan error, 1)
+	result <- sw.Send(peerID, chID, msgBytes)
+	return result
+}
+func (sw *stubSwitch) GetP2PID(peerID string) (string, error) { return peerID, nil }
+func (sw *stubSwitch) StopPeerForError(peerID string, reason interface{}) {
+	sw.mtx.Lock()
+	defer sw.mtx.Unlock()
+	sw.stopped = append(sw.stopped, fmt.Sprintf("%s: %v", peerID, reason))
+}
+func (sw *stubSwitch) PeerFeatures(peerID string) (libs.Features, bool) { return 0, false }
+func (sw *stubSwitch) Reactor(name string) (libs.Reactor, bool)         { return nil, false }
+
+func TestProposalFetcher(t *testing.T) {
+	clock := libs.NewManualClock(time.Unix(100, 0))
+	sw := &stubSwitch{}
+	s := &State{
+		host:         "a",
+		p2p:          sw,
+		peerMsgQueue: make(chan MsgInfo, MsgQueueSize),
+		senderQueue:  make(chan MsgInfo, MsgQueueSize),
+		fetcher:      newProposalFetcher(),
+		clock:        clock,
+		log:          logs.NewLogger(),
+	}
+
+	// the orphan waits for its parent, the parent is requested at most once per interval
+	orphan := &types.ProposalMsg{Round: 3, ID: []byte("c")}
+	s.requestProposal(2, []byte("b"), "b", orphan)
+	s.requestProposal(2, []byte("b"), "c", nil)
+	if len(s.senderQueue) != 1 {
+		t.Fatalf("want 1 request, has: %d", len(s.senderQueue))
+	}
+	req := (<-s.senderQueue).(*types.ProposalRequestMsg)
+	if req.To != "b" || req.Round != 2 || string(req.ID) != "b" {
+		t.Errorf("unexpected request: %s", req.String())
+	}
+	clock.Advance(fetchInterval)
+	s.requestProposal(2, []byte("b"), "c", nil)
+	if len(s.senderQueue) != 1 {
+		t.Fatalf("the request should be retried after the interval")
+	}
+	<-s.senderQueue
+
+	s.onProposalFetched(&types.ProposalMsg{Round: 2, ID: []byte("b")})
+	select {
+	case m := <-s.peerMsgQueue:
+		if m != orphan {
+			t.Errorf("want the orphan, has: %s", m.String())
+		}
+	default:
+		t.Fatal("the orphan should be processed again")
+	}
+	if len(s.fetcher.requested) != 0 || len(s.fetcher.orphans) != 0 {
+		t.Errorf("the fetch should be done")
+	}
+
+	// the requests of the committed rounds are given up
+	s.requestProposal(4, []byte("d"), "b", orphan)
+	s.pruneFetches(4)
+	if len(s.fetcher.requested) != 0 || len(s.fetcher.orphans) != 0 {
+		t.Errorf("the fetch should be pruned")
+	}
+
+	// the cached proposals are replied as they are
+	if err := s.onReceiveProposalRequest(&types.ProposalRequestMsg{ID: []byte("e"), SendID: "b"}); err != ErrProposalNotCached {
+		t.Errorf("want: %v, has: %v", ErrProposalNotCached, err)
+	}
+	for i := 0; i <= maxCachedProposals; i++ {
+		s.cacheProposal(int64(i), []byte(fmt.Sprint(i)), []byte(fmt.Sprint(i)))
+	}
+	if len(s.fetcher.cache) != maxCachedProposals {
+		t.Errorf("want %d cached, has: %d", maxCachedProposals, len(s.fetcher.cache))
+	}
+	if _, ok := s.fetcher.cache[libs.F([]byte("0"))]; ok {
+		t.Errorf("the lowest round should be evicted")
+	}
+	if err := s.onReceiveProposalRequest(&types.ProposalRequestMsg{ID: []byte("5"), SendID: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(sw.sent) != 1 || sw.sent[0].to != "b" || string(sw.sent[0].msg) != "5" {
+		t.Errorf("unexpected reply: %+v", sw.sent)
+	}
+}
@@ -0,0 +1,66 @@
+package state
+
+import (
+	"time"
+
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+// DefaultFlushTimeout bounds the flush of Stop when FlushTimeout isn't set.
+const DefaultFlushTimeout = 2 * time.Second
+
+// stopReceiving asks the receiveRoutine to flush the msgs queued by the host and exit, it gives up after
+// FlushTimeout, e.g. the receiveRoutine has never been started or is stuck in a step.
+func (s *State) stopReceiving() {
+	timer := time.NewTimer(s.cfg.FlushTimeout)
+	defer timer.Stop()
+
+	done := make(chan struct{})
+	select {
+	case s.flushQueue <- done:
+	case <-timer.C:
+		s.log.Warn("receiveRoutine is not responding @ state.stopReceiving, timeout: %v", s.cfg.FlushTimeout)
+		return
+	}
+	select {
+	case <-done:
+	case <-timer.C:
+		s.log.Warn("flush timeout @ state.stopReceiving, timeout: %v", s.cfg.FlushTimeout)
+	}
+}
+
+// flush signs and sends the msgs the host has queued for the current view, e.g. the votes already decided,
+// and records its own msgs looped back on the peerMsgQueue, so that the vote set and the wal persist them,
+// before the state quits. The msgs of the earlier rounds, the proposal requests and the msgs of the peers
+// are dropped. It runs on the receiveRoutine, no step interleaves with it.
+func (s *State) flush() {
+	deadline := time.Now().Add(s.cfg.FlushTimeout)
+	round := s.pacemaker.GetCurrentRound()
+	var flushed, dropped int
+	for time.Now().Before(deadline) {
+		select {
+		case m := <-s.senderQueue:
+			if _, ok := m.(*types.ProposalRequestMsg); ok || msgRound(m) < round-1 {
+				dropped++
+				continue
+			}
+			s.runStep(ScheduleProcess, func() { s.schedule(m) })
+			flushed++
+			continue
+		default:
+		}
+		select {
+		case m := <-s.peerMsgQueue:
+			if PeerID(msgSender(m)) != s.host {
+				dropped++
+				continue
+			}
+			s.runStep(stepName(m), func() { s.handleMsg(m) })
+			continue
+		default:
+		}
+		break
+	}
+	s.log.Info("flush the queued msgs @ state.flush, round: %d, flushed: %d, dropped: %d, left: %d",
+		round, flushed, dropped, len(s.senderQueue))
+}
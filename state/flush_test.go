@@ -0,0 +1,72 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+func TestStopFlushes(t *testing.T) {
+	host, leader := keyPeers[0], keyPeers[1]
+	newFlushState := func() (*State, *stubSwitch) {
+		log := logs.NewLogger()
+		cfg := &ConsensusConfig{ChainID: "test", StartRound: 5, StartID: "genesis", StartValue: []byte("genesis"),
+			StartValidators: []PeerID{host, leader}, FlushTimeout: 200 * time.Millisecond}
+		s, err := NewState(host, newKeyClient(t), NewDefaultTimeoutTicker(log), log, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.RegisterPaceMaker(NewDefaultPacemaker(cfg.StartRound))
+		s.RegisterElection(NewDefaultElection(cfg.StartRound, cfg.StartValidators))
+		s.RegisterSaftyrules(NewDefaultSafetyRules(s))
+		sw := &stubSwitch{}
+		s.SetSwitch(sw)
+		return s, sw
+	}
+
+	// the vote of the previous round is sent, the stale vote, the request and the msgs of the peers are dropped
+	s, sw := newFlushState()
+	s.senderQueue <- VoteMsg(2, []byte("stale"), 1, []byte("genesis"), string(leader))
+	s.senderQueue <- VoteMsg(5, []byte("decided"), 4, []byte("genesis"), string(leader))
+	s.senderQueue <- &types.ProposalRequestMsg{Round: 5, ID: []byte("decided"), To: string(leader)}
+	s.peerMsgQueue <- &types.VoteMsg{Round: 5, ID: []byte("decided"), SendID: string(leader)}
+	s.flush()
+	if len(sw.sent) != 1 || sw.sent[0].to != string(leader) {
+		t.Fatalf("want the decided vote sent to the leader, has: %+v", sw.sent)
+	}
+	msg, err := ConsMsgFromProto(sw.sent[0].msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vote, ok := msg.(*types.VoteMsg); !ok || string(vote.ID) != "decided" || vote.SendID != string(host) {
+		t.Errorf("unexpected msg sent: %s", msg.String())
+	}
+	if len(s.senderQueue) != 0 || len(s.peerMsgQueue) != 0 {
+		t.Errorf("want the queues drained, sender: %d, peer: %d", len(s.senderQueue), len(s.peerMsgQueue))
+	}
+
+	// the queued vote goes out when the running state stops
+	s, sw = newFlushState()
+	s.Start()
+	s.mtx.Lock()
+	s.senderQueue <- VoteMsg(5, []byte("decided"), 4, []byte("genesis"), string(leader))
+	s.mtx.Unlock()
+	start := time.Now()
+	s.Stop()
+	if time.Since(start) > time.Second {
+		t.Errorf("stop should be bounded by the flush timeout, has: %v", time.Since(start))
+	}
+	var votes int
+	for _, m := range sw.sent {
+		if msg, err := ConsMsgFromProto(m.msg); err == nil {
+			if _, ok := msg.(*types.VoteMsg); ok {
+				votes++
+			}
+		}
+	}
+	if votes != 1 {
+		t.Errorf("want the decided vote sent before stopping, has: %d", votes)
+	}
+}
@@ -0,0 +1,163 @@
+package state
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/storage"
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+// StepInfo describes a single step of the state machine, which handles one msg or timeout.
+type StepInfo struct {
+	Step   string
+	Round  int64 // current round of the pacemaker
+	Height int64 // latest committed round
+	// Duration is only set for the AfterStep hooks.
+	Duration time.Duration
+}
+
+// StepHook is invoked synchronously by the receiveRoutine, a blocking hook pauses the state machine,
+// which makes it possible for a simulation harness to step nodes deterministically.
+type StepHook func(info StepInfo)
+
+// CommitHook is invoked synchronously with every committed block, it must not block.
+type CommitHook func(block *storage.CommittedBlock)
+
+// QCInfo describes a QC formed by the host as the leader.
+type QCInfo struct {
+	Round int64
+	ID    []byte
+	// Voters and Validators are the numbers of the collected votes and of the validators of the round.
+	Voters     int
+	Validators int
+	// Waited is how long the QC waited for the extra votes after the quorum, see ConsensusConfig.VoteGrace.
+	Waited time.Duration
+}
+
+// QCHook is invoked synchronously once a QC is formed, before the next proposal is built, it must not block.
+type QCHook func(info QCInfo)
+
+// CorruptHook is invoked by the scrubber with the height of a corrupted block, it must not block.
+type CorruptHook func(height int64)
+
+type stepHooks struct {
+	before  []StepHook
+	after   []StepHook
+	commit  []CommitHook
+	qc      []QCHook
+	corrupt []CorruptHook
+	mtx     sync.RWMutex
+}
+
+// BeforeStep registers a hook invoked before every step.
+func (s *State) BeforeStep(h StepHook) {
+	s.hooks.mtx.Lock()
+	defer s.hooks.mtx.Unlock()
+
+	s.hooks.before = append(s.hooks.before, h)
+}
+
+// AfterStep registers a hook invoked after every step.
+func (s *State) AfterStep(h StepHook) {
+	s.hooks.mtx.Lock()
+	defer s.hooks.mtx.Unlock()
+
+	s.hooks.after = append(s.hooks.after, h)
+}
+
+// OnCommitBlock registers a hook invoked with every committed block.
+func (s *State) OnCommitBlock(h CommitHook) {
+	s.hooks.mtx.Lock()
+	defer s.hooks.mtx.Unlock()
+
+	s.hooks.commit = append(s.hooks.commit, h)
+}
+
+// OnQC registers a hook invoked with every QC formed by the host.
+func (s *State) OnQC(h QCHook) {
+	s.hooks.mtx.Lock()
+	defer s.hooks.mtx.Unlock()
+
+	s.hooks.qc = append(s.hooks.qc, h)
+}
+
+// OnCorruptBlock registers a hook invoked with every corrupted block found by the scrubber.
+func (s *State) OnCorruptBlock(h CorruptHook) {
+	s.hooks.mtx.Lock()
+	defer s.hooks.mtx.Unlock()
+
+	s.hooks.corrupt = append(s.hooks.corrupt, h)
+}
+
+func (s *State) runCorruptHooks(height int64) {
+	s.hooks.mtx.RLock()
+	corrupt := s.hooks.corrupt
+	s.hooks.mtx.RUnlock()
+
+	for _, h := range corrupt {
+		h(height)
+	}
+}
+
+func (s *State) runQCHooks(info QCInfo) {
+	s.hooks.mtx.RLock()
+	qc := s.hooks.qc
+	s.hooks.mtx.RUnlock()
+
+	for _, h := range qc {
+		h(info)
+	}
+}
+
+func (s *State) runCommitHooks(block *storage.CommittedBlock) {
+	s.hooks.mtx.RLock()
+	commit := s.hooks.commit
+	s.hooks.mtx.RUnlock()
+
+	for _, h := range commit {
+		h(block)
+	}
+}
+
+// runStep wraps the step with the registered hooks.
+func (s *State) runStep(step string, f func()) {
+	s.hooks.mtx.RLock()
+	before, after := s.hooks.before, s.hooks.after
+	s.hooks.mtx.RUnlock()
+
+	info := StepInfo{
+		Step:   step,
+		Round:  s.pacemaker.GetCurrentRound(),
+		Height: s.committedRound,
+	}
+	for _, h := range before {
+		h(info)
+	}
+	start := s.clock.Now()
+	f()
+	info.Duration = s.clock.Since(start)
+	info.Round = s.pacemaker.GetCurrentRound()
+	info.Height = s.committedRound
+	atomic.StoreInt64(&s.view, info.Round)
+	for _, h := range after {
+		h(info)
+	}
+}
+
+func stepName(m MsgInfo) string {
+	switch m.(type) {
+	case *types.ProposalMsg:
+		return ProposalProcess
+	case *types.VoteMsg:
+		return VoteProcess
+	case *types.TimeoutMsg:
+		return TimeoutProcess
+	case *types.StatusMsg:
+		return StatusProcess
+	case *types.ProposalRequestMsg:
+		return FetchProcess
+	}
+	return UnknownProcess
+}
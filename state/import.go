@@ -0,0 +1,150 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aucusaga/gohotstuff/storage"
+)
+
+var (
+	ErrBrokenQCChain = errors.New("imported blocks don't form a qc chain")
+	ErrNoValidators  = errors.New("validators to verify the imported blocks are not set")
+)
+
+// Importer ingests the blocks exported by another node, e.g. to bootstrap an archive node
+// from an offline transfer. Every block is verified against its QC, whose signatures must come from a quorum
+// of the validators of its epoch, and chained to the previous one, the first block is chained to the latest
+// block of the store, or taken as the anchor if the store is empty.
+type Importer struct {
+	Blocks storage.BlockStorage
+	// Election reconstructs the validators of every block from the genesis validators, it's required.
+	Election ProposerElection
+	// StartRound is the genesis round, the QCs up to it aren't signed by anyone.
+	StartRound int64
+	// ChainID and ForkID are the sign domain of the chain, see Auditor.
+	ChainID string
+	ForkID  string
+	// Results keeps the results of the imported blocks if it's set.
+	Results storage.ResultStorage
+	// Executor re-executes the imported blocks to populate the application state if it's set,
+	// the exported results are replaced by its own, otherwise they are kept as exported.
+	Executor Executor
+	// Deserialize decodes the QCs, DefaultDeserialize if it's nil.
+	Deserialize func(input []byte) (QuorumCert, error)
+}
+
+// Import reads the blocks until io.EOF, it returns the number of the imported blocks.
+// The blocks which have been in the store are skipped if they are identical, so an import can be resumed.
+func (im *Importer) Import(r *storage.BlockReader) (int, error) {
+	if im.Election == nil {
+		return 0, ErrNoValidators
+	}
+	if im.Deserialize == nil {
+		im.Deserialize = DefaultDeserialize
+	}
+	a := &Auditor{Election: im.Election, StartRound: im.StartRound, Deserialize: im.Deserialize,
+		ChainID: im.ChainID, ForkID: im.ForkID}
+	prev, err := im.tip()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for {
+		b, err := r.Next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if prev != nil && b.Height <= prev.Height {
+			if err := im.checkExisting(b); err != nil {
+				return n, err
+			}
+			continue
+		}
+		if _, err := a.verify(prev, &b.CommittedBlock); err != nil {
+			return n, err
+		}
+		if err := im.apply(b); err != nil {
+			return n, err
+		}
+		prev = &b.CommittedBlock
+		n++
+	}
+}
+
+// tip returns the latest block of the store, nil if it's empty.
+func (im *Importer) tip() (*storage.CommittedBlock, error) {
+	heights, err := im.Blocks.Heights(0, 0)
+	if err != nil || len(heights) == 0 {
+		return nil, err
+	}
+	return im.Blocks.Load(heights[len(heights)-1])
+}
+
+func (im *Importer) checkExisting(b *storage.ExportedBlock) error {
+	old, err := im.Blocks.Load(b.Height)
+	if errors.Is(err, storage.ErrBlockNotFound) {
+		// a round without a committed block in our chain
+		return fmt.Errorf("%w, height: %d isn't committed locally", ErrBrokenQCChain, b.Height)
+	}
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(old.ID, b.ID) {
+		return fmt.Errorf("%w, conflicting block, height: %d, local: %x, imported: %x", ErrBrokenQCChain, b.Height, old.ID, b.ID)
+	}
+	return nil
+}
+
+// verifyCommittedBlock checks the block is certified by its QC and extends the previous block if any.
+func verifyCommittedBlock(deserialize func([]byte) (QuorumCert, error), prev, b *storage.CommittedBlock) error {
+	qc, err := deserialize(b.QC)
+	if err != nil {
+		return fmt.Errorf("%w, undecodable qc, height: %d, err: %v", ErrBrokenQCChain, b.Height, err)
+	}
+	round, id, err := qc.Proposal()
+	if err != nil {
+		return err
+	}
+	parentRound, parentID, err := qc.ParentProposal()
+	if err != nil {
+		return err
+	}
+	if round != b.Height || !bytes.Equal(id, b.ID) || parentRound != b.ParentRound ||
+		!bytes.Equal(parentID, b.ParentID) || qc.Sender() != b.Proposer {
+		return fmt.Errorf("%w, block mismatches its qc, height: %d, qc: %s", ErrBrokenQCChain, b.Height, qc.String())
+	}
+	if parentRound >= round {
+		return fmt.Errorf("%w, parent round isn't lower, height: %d, parent_round: %d", ErrBrokenQCChain, round, parentRound)
+	}
+	if err := b.ID.Verify(b.Content()); err != nil {
+		return fmt.Errorf("%w, height: %d, err: %v", ErrBrokenQCChain, b.Height, err)
+	}
+	if prev != nil && (parentRound != prev.Height || !bytes.Equal(parentID, prev.ID)) {
+		return fmt.Errorf("%w, height: %d, parent: %d/%x, previous: %d/%x",
+			ErrBrokenQCChain, round, parentRound, parentID, prev.Height, prev.ID)
+	}
+	return nil
+}
+
+func (im *Importer) apply(b *storage.ExportedBlock) error {
+	results := b.Results
+	if im.Executor != nil {
+		var err error
+		if results, err = im.Executor.Execute(b.Height, b.Txs); err != nil {
+			return fmt.Errorf("execute imported block fail, height: %d, err: %v", b.Height, err)
+		}
+	}
+	if im.Results != nil && results != nil {
+		if err := im.Results.Save(&storage.BlockResults{Height: b.Height, Txs: results}); err != nil {
+			return err
+		}
+	}
+	// the block goes last, so that a resumed import re-applies the block whose results weren't saved
+	return im.Blocks.Save(&b.CommittedBlock)
+}
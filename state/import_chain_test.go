@@ -0,0 +1,32 @@
+package state_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aucusaga/gohotstuff/state"
+	"github.com/aucusaga/gohotstuff/state/statetest"
+	"github.com/aucusaga/gohotstuff/storage"
+)
+
+func TestImportPartitionedChain(t *testing.T) {
+	// the view changes of the isolated leader make the blocks committed implicitly
+	vs, committed := committedChain(t, 14, true)
+	src := storeBlocks(t, committed)
+	for _, format := range []string{storage.ExportJSON, storage.ExportCAR} {
+		var buf bytes.Buffer
+		if _, err := storage.Export(src, nil, 0, 0, format, &buf); err != nil {
+			t.Fatal(err)
+		}
+		br, err := storage.NewBlockReader(&buf, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst, _ := storage.NewBlockStore(t.TempDir())
+		im := &state.Importer{Blocks: dst, Executor: state.NopExecutor{},
+			Election: state.NewDefaultElection(0, statetest.IDs(vs)), ChainID: "test"}
+		if n, err := im.Import(br); err != nil || n != len(committed) {
+			t.Fatalf("import fail, format: %s, imported: %d of %d, err: %v", format, n, len(committed), err)
+		}
+	}
+}
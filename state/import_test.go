@@ -0,0 +1,142 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/aucusaga/gohotstuff/storage"
+)
+
+// newExportedChain commits the blocks of the rounds, each is chained to the previous one.
+func newExportedChain(t *testing.T, rounds ...int64) *storage.BlockStore {
+	blocks, err := storage.NewBlockStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentRound, parentID := int64(0), []byte("genesis")
+	for _, r := range rounds {
+		id := []byte{byte(r)}
+		qc, _ := NewDefaultQuorumCert("a", nil, r, id, parentRound, parentID)
+		raw, _ := qc.Serialize()
+		b := &storage.CommittedBlock{Height: r, ID: id, ParentRound: parentRound, ParentID: parentID, Proposer: "a",
+			Txs: [][]byte{[]byte("tx")}, QC: raw}
+		if err := blocks.Save(b); err != nil {
+			t.Fatal(err)
+		}
+		parentRound, parentID = r, id
+	}
+	return blocks
+}
+
+func importExport(t *testing.T, src *storage.BlockStore, format string, im *Importer) (int, error) {
+	var buf bytes.Buffer
+	if _, err := storage.Export(src, nil, 0, 0, format, &buf); err != nil {
+		t.Fatal(err)
+	}
+	br, err := storage.NewBlockReader(&buf, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return im.Import(br)
+}
+
+// newImporter imports the chains signed by the validator "a", see newSignedChain.
+func newImporter(blocks storage.BlockStorage) *Importer {
+	return &Importer{Blocks: blocks, Election: NewDefaultElection(1, []PeerID{"a"}), ChainID: "test"}
+}
+
+func TestImport(t *testing.T) {
+	src := newSignedChain(t, signedByA, 1, 2, 4)
+	for _, format := range []string{storage.ExportJSON, storage.ExportCAR} {
+		dst, _ := storage.NewBlockStore(t.TempDir())
+		results, _ := storage.NewResultStore(t.TempDir())
+		im := newImporter(dst)
+		im.Results, im.Executor = results, NopExecutor{}
+		n, err := importExport(t, src, format, im)
+		if err != nil || n != 3 {
+			t.Fatalf("import fail, format: %s, n: %d, err: %v", format, n, err)
+		}
+		if _, err := results.Load(4); err != nil {
+			t.Errorf("imported blocks should be executed, err: %v", err)
+		}
+		// importing again is a no-op
+		if n, err := importExport(t, src, format, im); err != nil || n != 0 {
+			t.Errorf("re-import should skip the blocks, n: %d, err: %v", n, err)
+		}
+	}
+	dst, _ := storage.NewBlockStore(t.TempDir())
+	if _, err := importExport(t, src, storage.ExportJSON, &Importer{Blocks: dst}); !errors.Is(err, ErrNoValidators) {
+		t.Errorf("want the validators required, has: %v", err)
+	}
+}
+
+func TestImportBrokenChain(t *testing.T) {
+	src := newSignedChain(t, signedByA, 1, 2, 3)
+	// the block of round 2 claims another parent than its qc
+	b, _ := src.Load(2)
+	b.ParentID = []byte("forged")
+	if err := src.Save(b); err != nil {
+		t.Fatal(err)
+	}
+	dst, _ := storage.NewBlockStore(t.TempDir())
+	n, err := importExport(t, src, storage.ExportJSON, newImporter(dst))
+	if !errors.Is(err, ErrBrokenQCChain) || n != 1 {
+		t.Errorf("want broken chain err after 1 block, n: %d, err: %v", n, err)
+	}
+
+	// a chain which doesn't extend the local one is refused
+	other := newSignedChain(t, signedByA, 5, 6)
+	if _, err := importExport(t, other, storage.ExportJSON, newImporter(dst)); !errors.Is(err, ErrBrokenQCChain) {
+		t.Errorf("want broken chain err, has: %v", err)
+	}
+}
+
+func TestImportForgedQC(t *testing.T) {
+	// the QC of round 2 is signed by someone other than the validator
+	forged := newSignedChain(t, map[int64][]string{1: {"a"}, 2: {"b"}, 3: {"a"}}, 1, 2, 3)
+	// the signed vote of round 2 is replayed for round 3
+	replayed := newSignedChain(t, signedByA, 1, 2, 3)
+	b2, _ := replayed.Load(2)
+	b3, _ := replayed.Load(3)
+	qc2, _ := DefaultDeserialize(b2.QC)
+	qc3, _ := DefaultDeserialize(b3.QC)
+	certified, _ := qc3.Certify(qc2.(DefaultQuorumCert).Signs)
+	b3.QC, _ = certified.Serialize()
+	replayed.Save(b3)
+
+	for name, c := range map[string]struct {
+		src  *storage.BlockStore
+		want int
+	}{
+		"forged":   {forged, 1},
+		"replayed": {replayed, 2},
+		"unsigned": {newExportedChain(t, 1, 2, 3), 0},
+	} {
+		dst, _ := storage.NewBlockStore(t.TempDir())
+		n, err := importExport(t, c.src, storage.ExportJSON, newImporter(dst))
+		if !errors.Is(err, ErrQuorumNotReached) || n != c.want {
+			t.Errorf("want no quorum of the %s qc after %d blocks, n: %d, err: %v", name, c.want, n, err)
+		}
+	}
+}
+
+func TestStateExport(t *testing.T) {
+	s := newObserverState("a", newExportedChain(t, 1, 2, 4))
+	var buf bytes.Buffer
+	if n, err := s.Export(2, 0, storage.ExportJSON, &buf); err != nil || n != 2 {
+		t.Fatalf("want 2 blocks exported, has: %d, err: %v", n, err)
+	}
+	br, err := storage.NewBlockReader(&buf, storage.ExportJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := br.Next(); err != nil || b.Height != 2 {
+		t.Errorf("want the block 2 first, has: %+v, err: %v", b, err)
+	}
+
+	s.blocks = nil
+	if _, err := s.Export(0, 0, storage.ExportJSON, &buf); !errors.Is(err, ErrNoBlockStore) {
+		t.Errorf("want ErrNoBlockStore, has: %v", err)
+	}
+}
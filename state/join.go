@@ -0,0 +1,362 @@
+package state
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
+	"github.com/aucusaga/gohotstuff/crypto"
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+const (
+	// JoinRequestTTL bounds the age of the join requests kept for the application, a candidate
+	// which hasn't been admitted in time asks again.
+	JoinRequestTTL = time.Hour
+	// maxJoinRequests bounds the pending join requests kept by the host.
+	maxJoinRequests = 100
+)
+
+var (
+	// AdmissionPrefix marks the txs admitting a candidate, the rest of the tx is the json encoding of its JoinRequest.
+	AdmissionPrefix = []byte("gohotstuff/admission/v1:")
+
+	ErrInvalidJoinRequest  = errors.New("invalid join request")
+	ErrTooManyJoinRequests = errors.New("too many pending join requests")
+)
+
+// JoinRequest is the request of a candidate to join the validators of the chain, it carries the consensus key
+// of the candidate with its proof of possession and the address the validators reach it at, and it's signed
+// by the key, see crypto.SignJoin. The request is only surfaced to the application, which approves it
+// by committing the admission tx, see EncodeAdmission.
+type JoinRequest struct {
+	ChainID string       `json:"chain_id"`
+	Key     ValidatorKey `json:"key"`
+	Address string       `json:"address"`
+	// Timestamp is the unix milliseconds when the request is signed
+	Timestamp int64  `json:"timestamp"`
+	Signature []byte `json:"signature"`
+}
+
+// NewJoinRequest signs the request of the candidate id by the key of the client.
+func NewJoinRequest(cc crypto.CryptoClient, chainID string, id PeerID, address string, now time.Time) (JoinRequest, error) {
+	r := JoinRequest{ChainID: chainID, Address: address, Timestamp: now.UnixNano() / int64(time.Millisecond)}
+	pk, proof, err := crypto.ProvePossession(cc, string(id))
+	if err != nil {
+		return r, err
+	}
+	r.Key = ValidatorKey{ID: id, PublicKey: pk, Proof: proof}
+	if _, r.Signature, err = crypto.SignJoin(cc, chainID, string(id), address, r.Timestamp); err != nil {
+		return r, err
+	}
+	return r, r.Verify()
+}
+
+func (r JoinRequest) Verify() error {
+	if err := r.Key.Verify(); err != nil {
+		return err
+	}
+	if r.Address == "" {
+		return fmt.Errorf("%w, candidate: %s, no address", ErrInvalidJoinRequest, r.Key.ID)
+	}
+	if !crypto.VerifyJoin(r.ChainID, string(r.Key.ID), r.Key.PublicKey, r.Address, r.Timestamp, r.Signature) {
+		return fmt.Errorf("%w, candidate: %s, invalid signature", ErrInvalidJoinRequest, r.Key.ID)
+	}
+	return nil
+}
+
+// EncodeAdmission builds the tx admitting the candidate of the request, it's committed by the application
+// as an ordinary tx once the request is approved and takes effect at the second epoch boundary after the commit,
+// when the candidate joins the validators with its key registered.
+func EncodeAdmission(r JoinRequest) ([]byte, error) {
+	if err := r.Verify(); err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, AdmissionPrefix...), raw...), nil
+}
+
+// DecodeAdmission parses the tx, ok is false if the tx doesn't carry an admission.
+func DecodeAdmission(tx []byte) (r JoinRequest, ok bool, err error) {
+	if !bytes.HasPrefix(tx, AdmissionPrefix) {
+		return r, false, nil
+	}
+	if err := json.Unmarshal(tx[len(AdmissionPrefix):], &r); err != nil {
+		return r, true, fmt.Errorf("%w, err: %v", ErrInvalidJoinRequest, err)
+	}
+	return r, true, r.Verify()
+}
+
+// joinPool keeps the latest request of every candidate until it's admitted or expires.
+type joinPool struct {
+	sync.Mutex
+	pending map[PeerID]JoinRequest
+}
+
+// SubmitJoinRequest keeps the request of a candidate for the application and publishes it on the event bus,
+// the requests of the validators, of other chains and the stale ones are refused.
+func (s *State) SubmitJoinRequest(r JoinRequest) error {
+	if r.ChainID != s.cfg.ChainID {
+		return fmt.Errorf("%w, candidate: %s, chain: %s", ErrInvalidJoinRequest, r.Key.ID, r.ChainID)
+	}
+	now := s.clock.Now()
+	if age := now.Sub(time.Unix(0, r.Timestamp*int64(time.Millisecond))); age > JoinRequestTTL || age < -JoinRequestTTL {
+		return fmt.Errorf("%w, candidate: %s, signed %v ago", ErrInvalidJoinRequest, r.Key.ID, age)
+	}
+	if err := r.Verify(); err != nil {
+		return err
+	}
+	if s.isValidator(r.Key.ID) {
+		return fmt.Errorf("%w, candidate: %s, already a validator", ErrInvalidJoinRequest, r.Key.ID)
+	}
+
+	s.joins.Lock()
+	if s.joins.pending == nil {
+		s.joins.pending = make(map[PeerID]JoinRequest)
+	}
+	s.pruneJoinRequests(now)
+	old, ok := s.joins.pending[r.Key.ID]
+	if ok && old.Timestamp >= r.Timestamp {
+		// the duplicates relayed by the peers
+		s.joins.Unlock()
+		return nil
+	}
+	if !ok && len(s.joins.pending) >= maxJoinRequests {
+		s.joins.Unlock()
+		return fmt.Errorf("%w, candidate: %s", ErrTooManyJoinRequests, r.Key.ID)
+	}
+	s.joins.pending[r.Key.ID] = r
+	s.joins.Unlock()
+
+	s.log.Info("join request received @ state.SubmitJoinRequest, candidate: %s, address: %s", r.Key.ID, r.Address)
+	s.publish(libs.Event{
+		Type:   libs.EventJoinRequest,
+		Module: libs.ChainModule(s.cfg.ChainID, libs.JoinModule),
+		Data: libs.JoinEventData{
+			Validator: string(r.Key.ID),
+			Address:   r.Address,
+			PublicKey: hex.EncodeToString(r.Key.PublicKey),
+		},
+	})
+	return nil
+}
+
+// JoinRequests returns the pending requests sorted by the candidate.
+func (s *State) JoinRequests() []JoinRequest {
+	s.joins.Lock()
+	defer s.joins.Unlock()
+
+	s.pruneJoinRequests(s.clock.Now())
+	list := make([]JoinRequest, 0, len(s.joins.pending))
+	for _, r := range s.joins.pending {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key.ID < list[j].Key.ID })
+	return list
+}
+
+// pruneJoinRequests drops the expired requests, it's called with the joins mutex held.
+func (s *State) pruneJoinRequests(now time.Time) {
+	for id, r := range s.joins.pending {
+		if now.Sub(time.Unix(0, r.Timestamp*int64(time.Millisecond))) > JoinRequestTTL {
+			delete(s.joins.pending, id)
+		}
+	}
+}
+
+// isValidator reports whether v validates the current round or is scheduled to.
+func (s *State) isValidator(v PeerID) bool {
+	for _, id := range s.validatorIDs() {
+		if PeerID(id) == v {
+			return true
+		}
+	}
+	return false
+}
+
+// applyAdmissions schedules the candidates admitted at the height for the second epoch boundary after it,
+// along with their keys, the invalid admissions are ignored by every validator alike.
+func (s *State) applyAdmissions(height int64, txs [][]byte) {
+	var admitted []JoinRequest
+	for _, tx := range txs {
+		r, ok, err := DecodeAdmission(tx)
+		if !ok {
+			continue
+		}
+		if err == nil && r.ChainID != s.cfg.ChainID {
+			err = fmt.Errorf("%w, candidate: %s, chain: %s", ErrInvalidJoinRequest, r.Key.ID, r.ChainID)
+		}
+		if err != nil {
+			s.log.Warn("ignore admission @ state.applyAdmissions, height: %d, err: %v", height, err)
+			continue
+		}
+		admitted = append(admitted, r)
+	}
+	if len(admitted) == 0 || s.election == nil {
+		return
+	}
+
+	epochLength := s.ParamsAt(height).EpochLength
+	start := (height/epochLength + 2) * epochLength
+	current := s.election.Validators(start, s.timeoutSet.GetTimeoutIdxMap())
+	next := append([]PeerID{}, current...)
+	var keyTxs [][]byte
+	for _, r := range admitted {
+		if containsPeer(next, r.Key.ID) {
+			continue
+		}
+		next = append(next, r.Key.ID)
+		if tx, err := EncodeKeyRegistration(KeyRegistration{Key: r.Key}); err == nil {
+			keyTxs = append(keyTxs, tx)
+		}
+	}
+	if len(next) == len(current) {
+		return
+	}
+	if err := s.election.Update(start, next); err != nil {
+		s.log.Error("schedule admission fail @ state.applyAdmissions, height: %d, start: %d, err: %v", height, start, err)
+		return
+	}
+	s.applyKeyRegistrations(height, keyTxs)
+
+	s.joins.Lock()
+	for _, r := range admitted {
+		delete(s.joins.pending, r.Key.ID)
+	}
+	s.joins.Unlock()
+	for _, r := range admitted {
+		s.log.Info("admission scheduled @ state.applyAdmissions, height: %d, start: %d, candidate: %s", height, start, r.Key.ID)
+		s.publish(libs.Event{
+			Type:   libs.EventValidatorAdmitted,
+			Module: libs.ChainModule(s.cfg.ChainID, libs.JoinModule),
+			Data: libs.JoinEventData{
+				Validator: string(r.Key.ID),
+				Address:   r.Address,
+				PublicKey: hex.EncodeToString(r.Key.PublicKey),
+				Start:     start,
+			},
+		})
+	}
+}
+
+func containsPeer(ids []PeerID, v PeerID) bool {
+	for _, id := range ids {
+		if id == v {
+			return true
+		}
+	}
+	return false
+}
+
+// JoinReactor carries the join requests on the join channel: the candidates send their requests to every peer,
+// the validators keep the verified ones for the application, see State.SubmitJoinRequest.
+type JoinReactor struct {
+	state   *State
+	module  string
+	channel int32
+	sw      libs.Switch
+
+	mtx   sync.Mutex
+	peers map[string]libs.Peer
+	// the request of the host, it's sent to the peers connected later as well
+	request *JoinRequest
+	log     libs.Logger
+}
+
+func NewJoinReactor(s *State) *JoinReactor {
+	return &JoinReactor{
+		state:   s,
+		module:  libs.ChainModule(s.cfg.ChainID, libs.JoinModule),
+		channel: libs.ChainChannel(s.cfg.ChainID, libs.JoinChannel),
+		peers:   make(map[string]libs.Peer),
+		log:     s.log,
+	}
+}
+
+func (r *JoinReactor) Channel() int32 {
+	return r.channel
+}
+
+func (r *JoinReactor) SetSwitch(sw libs.Switch) {
+	r.sw = sw
+}
+
+func (r *JoinReactor) AddPeer(peer libs.Peer) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.peers[peer.PeerID()] = peer
+	if r.request != nil {
+		r.send(peer, *r.request)
+	}
+}
+
+func (r *JoinReactor) RemovePeer(peer libs.Peer, reason interface{}) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	delete(r.peers, peer.PeerID())
+}
+
+func (r *JoinReactor) HandleFunc(chID int32, msgBytes []byte) {
+	if chID != r.channel {
+		return
+	}
+	var msg pb.JoinMessage
+	if err := msg.Unmarshal(msgBytes); err != nil {
+		r.log.Error("unmarshal join msg fail @ state.JoinReactor, err: %v", err)
+		return
+	}
+	if msg.Module != r.module {
+		return
+	}
+	var req JoinRequest
+	if err := json.Unmarshal(msg.Request, &req); err != nil {
+		r.log.Warn("decode join request fail @ state.JoinReactor, from: %s, err: %v", msg.Pid, err)
+		return
+	}
+	if err := r.state.SubmitJoinRequest(req); err != nil {
+		r.log.Warn("refuse join request @ state.JoinReactor, from: %s, err: %v", msg.Pid, err)
+	}
+}
+
+// RequestJoin signs the request of the host to join the validators at the address, e.g. its public multiaddr,
+// and sends it to the connected peers and the ones connected later, until the host is restarted.
+func (r *JoinReactor) RequestJoin(address string) (JoinRequest, error) {
+	s := r.state
+	req, err := NewJoinRequest(s.crypto, s.cfg.ChainID, s.host, address, s.clock.Now())
+	if err != nil {
+		return req, err
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.request = &req
+	for _, peer := range r.peers {
+		r.send(peer, req)
+	}
+	s.log.Info("join requested @ state.JoinReactor, candidate: %s, address: %s, peers: %d", s.host, address, len(r.peers))
+	return req, nil
+}
+
+func (r *JoinReactor) send(peer libs.Peer, req JoinRequest) {
+	raw, err := json.Marshal(req)
+	if err != nil {
+		return
+	}
+	msg := &pb.JoinMessage{Module: r.module, Pid: []byte(r.state.host), Request: raw}
+	msgBytes, err := msg.Marshal()
+	if err != nil {
+		return
+	}
+	peer.Send(r.channel, msgBytes)
+}
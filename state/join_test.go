@@ -0,0 +1,82 @@
+package state
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+func TestJoinRequests(t *testing.T) {
+	qa, qb, qc := keyPeers[0], keyPeers[1], keyPeers[2]
+	a, b, c := newKeyClient(t), newKeyClient(t), newKeyClient(t)
+	now := time.Unix(1000, 0)
+	cfg := &ConsensusConfig{ChainID: "join", EpochLength: 10, StartKeys: []ValidatorKey{proveKey(t, a, qa)}}
+	keys, err := genesisKeys(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &State{
+		cfg:        cfg,
+		params:     newParamsHistory(cfg),
+		keys:       keyRegistry{steps: keys},
+		election:   NewDefaultElection(0, []PeerID{qa}),
+		pacemaker:  NewDefaultPacemaker(0),
+		timeoutSet: NewTimeoutSet(0, 0),
+		clock:      libs.NewManualClock(now),
+		log:        logs.NewLogger(),
+	}
+
+	rb, err := NewJoinRequest(b, "join", qb, "/ip4/127.0.0.1/tcp/26656", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SubmitJoinRequest(rb); err != nil {
+		t.Fatal(err)
+	}
+	// the candidate can't be impersonated by another key
+	forged := rb
+	forged.Key = proveKey(t, c, qb)
+	otherChain, _ := NewJoinRequest(c, "other", qc, "/ip4/127.0.0.1/tcp/26657", now)
+	stale, _ := NewJoinRequest(c, "join", qc, "/ip4/127.0.0.1/tcp/26657", now.Add(-2*JoinRequestTTL))
+	validator, _ := NewJoinRequest(a, "join", qa, "/ip4/127.0.0.1/tcp/26658", now)
+	for _, r := range []JoinRequest{forged, otherChain, stale, validator} {
+		if err := s.SubmitJoinRequest(r); !errors.Is(err, ErrInvalidJoinRequest) {
+			t.Errorf("candidate %s, want invalid join request err, has: %v", r.Key.ID, err)
+		}
+	}
+	if reqs := s.JoinRequests(); len(reqs) != 1 || reqs[0].Key.ID != qb {
+		t.Fatalf("want the request of %s pending, has: %v", qb, reqs)
+	}
+
+	tx, err := EncodeAdmission(rb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := DecodeAdmission([]byte("gohotstuff/admission/v1:{")); !ok || err == nil {
+		t.Errorf("malformed admission should be refused, ok: %v, err: %v", ok, err)
+	}
+	s.applyAdmissions(13, [][]byte{[]byte("tx"), tx})
+
+	if vs := s.election.Validators(29, nil); len(vs) != 1 {
+		t.Errorf("the candidate shouldn't validate before the second epoch boundary, has: %v", vs)
+	}
+	if vs := s.election.Validators(30, nil); len(vs) != 2 || vs[1] != qb {
+		t.Errorf("the candidate should validate from the second epoch boundary, has: %v", vs)
+	}
+	if has, _ := s.ValidatorKey(30, qb); string(has) != string(b.PublicKey()) {
+		t.Errorf("the key of the candidate should be registered along with the admission")
+	}
+	if reqs := s.JoinRequests(); len(reqs) != 0 {
+		t.Errorf("the admitted request should be removed, has: %v", reqs)
+	}
+
+	s.clock.(*libs.ManualClock).Advance(2 * JoinRequestTTL)
+	rc, _ := NewJoinRequest(c, "join", qc, "/ip4/127.0.0.1/tcp/26657", now)
+	s.joins.pending[qc] = rc
+	if reqs := s.JoinRequests(); len(reqs) != 0 {
+		t.Errorf("the expired requests should be pruned, has: %v", reqs)
+	}
+}
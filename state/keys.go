@@ -0,0 +1,195 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/aucusaga/gohotstuff/crypto"
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+var (
+	// KeyRegistrationPrefix marks the txs carrying a KeyRegistration, the rest of the tx is its json encoding.
+	KeyRegistrationPrefix = []byte("gohotstuff/validator_key/v1:")
+
+	ErrInvalidPossession = errors.New("invalid proof of possession of the validator key")
+	ErrKeyMismatch       = errors.New("msg is signed by a key other than the registered one")
+)
+
+// ValidatorKey is the consensus key registered for the validator, Proof is its proof of possession,
+// see crypto.ProvePossession.
+type ValidatorKey struct {
+	ID        PeerID `json:"id"`
+	PublicKey []byte `json:"public_key"`
+	Proof     []byte `json:"proof"`
+}
+
+func (k ValidatorKey) Verify() error {
+	if k.ID == "" || !crypto.VerifyPossession(string(k.ID), k.PublicKey, k.Proof) {
+		return fmt.Errorf("%w, validator: %s", ErrInvalidPossession, k.ID)
+	}
+	return nil
+}
+
+// KeyRegistration is the reconfiguration tx registering or rotating the key of a validator,
+// it takes effect at the second epoch boundary after the commit as well as a ParamChange.
+// Rotating a registered key requires the Approval of the old key, see crypto.ApproveRotation,
+// the first key of a validator is accepted on its proof of possession alone.
+type KeyRegistration struct {
+	Key      ValidatorKey `json:"key"`
+	Approval []byte       `json:"approval,omitempty"`
+}
+
+// EncodeKeyRegistration builds the tx carrying the registration.
+func EncodeKeyRegistration(r KeyRegistration) ([]byte, error) {
+	if err := r.Key.Verify(); err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, KeyRegistrationPrefix...), raw...), nil
+}
+
+// DecodeKeyRegistration parses the tx, ok is false if the tx doesn't carry a registration.
+func DecodeKeyRegistration(tx []byte) (r KeyRegistration, ok bool, err error) {
+	if !bytes.HasPrefix(tx, KeyRegistrationPrefix) {
+		return r, false, nil
+	}
+	if err := json.Unmarshal(tx[len(KeyRegistrationPrefix):], &r); err != nil {
+		return r, true, fmt.Errorf("%w, err: %v", ErrInvalidPossession, err)
+	}
+	return r, true, r.Key.Verify()
+}
+
+// KeysStep is the registered keys in force from the round Start on.
+type KeysStep struct {
+	Start int64             `json:"start"`
+	Keys  map[PeerID][]byte `json:"keys"`
+}
+
+// keyRegistry keeps every step of the registered keys, so that the msgs of any round
+// are checked against the keys in force at the round.
+type keyRegistry struct {
+	sync.Mutex
+	steps []KeysStep
+}
+
+// genesisKeys verifies the proofs of the genesis keys, a validator is registered once at most.
+func genesisKeys(cfg *ConsensusConfig) ([]KeysStep, error) {
+	keys := make(map[PeerID][]byte, len(cfg.StartKeys))
+	for _, k := range cfg.StartKeys {
+		if err := k.Verify(); err != nil {
+			return nil, err
+		}
+		if _, ok := keys[k.ID]; ok {
+			return nil, fmt.Errorf("%w, validator: %s, registered twice", ErrInvalidPossession, k.ID)
+		}
+		keys[k.ID] = k.PublicKey
+	}
+	return []KeysStep{{Start: cfg.StartRound, Keys: keys}}, nil
+}
+
+// ValidatorKey returns the key registered for the validator at the round.
+func (s *State) ValidatorKey(round int64, v PeerID) ([]byte, bool) {
+	s.keys.Lock()
+	defer s.keys.Unlock()
+
+	for i := len(s.keys.steps) - 1; i >= 0; i-- {
+		if round >= s.keys.steps[i].Start {
+			pk, ok := s.keys.steps[i].Keys[v]
+			return pk, ok
+		}
+	}
+	return nil, false
+}
+
+// checkKey refuses the msg of a validator signed by a key other than its registered one,
+// the signature has been verified by the key carried by the msg.
+func (s *State) checkKey(m MsgInfo) error {
+	sender := PeerID(msgSender(m))
+	pk, ok := s.ValidatorKey(msgRound(m), sender)
+	if !ok {
+		return nil
+	}
+	if !bytes.Equal(pk, msgPublicKey(m)) {
+		return fmt.Errorf("%w, validator: %s, round: %d", ErrKeyMismatch, sender, msgRound(m))
+	}
+	return nil
+}
+
+// applyKeyRegistrations schedules the registrations committed at the height on top of the pending ones,
+// the invalid ones are ignored by every validator alike.
+func (s *State) applyKeyRegistrations(height int64, txs [][]byte) {
+	epochLength := s.ParamsAt(height).EpochLength
+
+	s.keys.Lock()
+	defer s.keys.Unlock()
+
+	if len(s.keys.steps) == 0 {
+		s.keys.steps = []KeysStep{{Start: s.cfg.StartRound, Keys: make(map[PeerID][]byte)}}
+	}
+	last := &s.keys.steps[len(s.keys.steps)-1]
+	next := make(map[PeerID][]byte, len(last.Keys))
+	for v, pk := range last.Keys {
+		next[v] = pk
+	}
+	changed := false
+	for _, tx := range txs {
+		r, ok, err := DecodeKeyRegistration(tx)
+		if !ok {
+			continue
+		}
+		if err == nil {
+			err = approveRegistration(next, r)
+		}
+		if err != nil {
+			s.log.Warn("ignore key registration @ state.applyKeyRegistrations, height: %d, err: %v", height, err)
+			continue
+		}
+		next[r.Key.ID], changed = r.Key.PublicKey, true
+	}
+	if !changed {
+		return
+	}
+	start := (height/epochLength + 2) * epochLength
+	// the registrations scheduled for the same boundary are merged
+	if last.Start == start {
+		last.Keys = next
+	} else {
+		s.keys.steps = append(s.keys.steps, KeysStep{Start: start, Keys: next})
+	}
+	s.log.Info("key registration scheduled @ state.applyKeyRegistrations, height: %d, start: %d, keys: %d", height, start, len(next))
+}
+
+// approveRegistration requires the rotation of a registered key to be approved by it.
+func approveRegistration(keys map[PeerID][]byte, r KeyRegistration) error {
+	old, ok := keys[r.Key.ID]
+	if !ok || bytes.Equal(old, r.Key.PublicKey) {
+		return nil
+	}
+	if !crypto.VerifyRotation(string(r.Key.ID), old, r.Key.PublicKey, r.Approval) {
+		return fmt.Errorf("%w, validator: %s, the rotation isn't approved by the registered key", ErrInvalidPossession, r.Key.ID)
+	}
+	return nil
+}
+
+func msgPublicKey(msg MsgInfo) []byte {
+	switch msg := msg.(type) {
+	case *types.ProposalMsg:
+		return msg.PublicKey
+	case *types.VoteMsg:
+		return msg.PublicKey
+	case *types.TimeoutMsg:
+		return msg.PublicKey
+	case *types.StatusMsg:
+		return msg.PublicKey
+	case *types.ProposalRequestMsg:
+		return msg.PublicKey
+	}
+	return nil
+}
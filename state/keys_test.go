@@ -0,0 +1,123 @@
+package state
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/crypto"
+	"github.com/aucusaga/gohotstuff/types"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// the registrations carry the ids by their base58 json encoding
+var keyPeers = func() (ids []PeerID) {
+	for _, s := range []string{
+		"QmYyQSo1c1Ym7orWxLYvCrM2EmxFTANf8wXmmE7DWjhx5N",
+		"QmSoLPppuBtQSGwKDZT2M73ULpjvfd3aZ6ha4oFGL1KrGM",
+		"QmSoLV4Bbm51jM9C4gDYZQ9Cy3U6aXMJDAbzgu2fzaDs64",
+	} {
+		id, _ := peer.Decode(s)
+		ids = append(ids, id)
+	}
+	return ids
+}()
+
+func newKeyClient(t *testing.T) *crypto.DefaultCryptoClient {
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &crypto.DefaultCryptoClient{SK: sk, PK: &sk.PublicKey}
+}
+
+func proveKey(t *testing.T, cc crypto.CryptoClient, id PeerID) ValidatorKey {
+	pk, proof, err := crypto.ProvePossession(cc, string(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ValidatorKey{ID: id, PublicKey: pk, Proof: proof}
+}
+
+func TestGenesisKeys(t *testing.T) {
+	qa, qb := keyPeers[0], keyPeers[1]
+	a, b := newKeyClient(t), newKeyClient(t)
+	ka := proveKey(t, a, qa)
+	if _, err := genesisKeys(&ConsensusConfig{StartKeys: []ValidatorKey{ka, proveKey(t, b, qb)}}); err != nil {
+		t.Fatal(err)
+	}
+	// a proof can't be replayed for another validator
+	stolen := ka
+	stolen.ID = qb
+	// the rogue key is registered without its private key
+	rogue := ValidatorKey{ID: qb, PublicKey: b.PublicKey(), Proof: ka.Proof}
+	for _, keys := range [][]ValidatorKey{{stolen}, {rogue}, {ka, ka}} {
+		if _, err := genesisKeys(&ConsensusConfig{StartKeys: keys}); !errors.Is(err, ErrInvalidPossession) {
+			t.Errorf("want invalid possession err, has: %v", err)
+		}
+	}
+}
+
+func TestKeyRegistrations(t *testing.T) {
+	qa, qb, qc := keyPeers[0], keyPeers[1], keyPeers[2]
+	a, rotated, stranger := newKeyClient(t), newKeyClient(t), newKeyClient(t)
+	cfg := &ConsensusConfig{EpochLength: 10, StartKeys: []ValidatorKey{proveKey(t, a, qa)}}
+	keys, err := genesisKeys(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &State{cfg: cfg, params: newParamsHistory(cfg), keys: keyRegistry{steps: keys}, log: logs.NewLogger()}
+
+	// the first key of qb is accepted on its proof alone
+	first, err := EncodeKeyRegistration(KeyRegistration{Key: proveKey(t, stranger, qb)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the rotation of qa must be approved by its registered key
+	unapproved, _ := EncodeKeyRegistration(KeyRegistration{Key: proveKey(t, rotated, qa)})
+	if _, ok, err := DecodeKeyRegistration([]byte("gohotstuff/validator_key/v1:{")); !ok || err == nil {
+		t.Errorf("malformed registration should be refused, ok: %v, err: %v", ok, err)
+	}
+	s.applyKeyRegistrations(5, [][]byte{[]byte("tx"), first, unapproved})
+
+	rk := proveKey(t, rotated, qa)
+	approval, err := crypto.ApproveRotation(a, string(qa), rk.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	approved, _ := EncodeKeyRegistration(KeyRegistration{Key: rk, Approval: approval})
+	s.applyKeyRegistrations(13, [][]byte{approved})
+
+	for _, c := range []struct {
+		round int64
+		v     PeerID
+		want  []byte
+	}{
+		{19, qa, a.PublicKey()},
+		{19, qb, nil},
+		{20, qa, a.PublicKey()},
+		{20, qb, stranger.PublicKey()},
+		{30, qa, rotated.PublicKey()},
+	} {
+		if has, _ := s.ValidatorKey(c.round, c.v); string(has) != string(c.want) {
+			t.Errorf("round %d, validator %s, unexpected key", c.round, c.v)
+		}
+	}
+
+	// the msgs are checked against the key in force at their rounds
+	vote := &types.VoteMsg{Round: 25, SendID: string(qa), PublicKey: rotated.PublicKey()}
+	if err := s.checkKey(vote); !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("want key mismatch err, has: %v", err)
+	}
+	vote.Round = 30
+	if err := s.checkKey(vote); err != nil {
+		t.Errorf("rotated key should be accepted, err: %v", err)
+	}
+	// the unregistered validators sign by any key
+	if err := s.checkKey(&types.VoteMsg{Round: 30, SendID: string(qc), PublicKey: a.PublicKey()}); err != nil {
+		t.Errorf("unregistered validator should be accepted, err: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package state
+
+import (
+	"time"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/metrics"
+)
+
+const (
+	SLAViewChanges   = "view_changes"
+	SLACommitLatency = "commit_latency"
+)
+
+type consensusMetrics struct {
+	viewChanges   *metrics.Gauge
+	commitLatency *metrics.Gauge
+	slaViolated   *metrics.Gauge
+	commits       *metrics.Counter
+	roundsBehind  *metrics.Gauge
+	execPending   *metrics.Gauge
+	// blocks found corrupted by the scrubber and the ones repaired from the peers
+	corruptBlocks  *metrics.Counter
+	repairedBlocks *metrics.Counter
+	diskSuspended  *metrics.Gauge
+	// view changes since the start by the reason
+	viewChangesByReason map[ViewChangeReason]*metrics.Counter
+	// durations of the phases of the latest committed blocks, see phaseTracker
+	phases map[string]*metrics.Summary
+}
+
+func newConsensusMetrics(r *metrics.Registry, chainID string) *consensusMetrics {
+	m := &consensusMetrics{
+		viewChanges: r.NewGauge("gohotstuff_consensus_consecutive_view_changes",
+			"Number of view changes since the latest commit.", "chain", chainID),
+		commitLatency: r.NewGauge("gohotstuff_consensus_commit_latency_seconds",
+			"Seconds elapsed between the latest two commits.", "chain", chainID),
+		slaViolated: r.NewGauge("gohotstuff_consensus_sla_violated",
+			"1 if the view changes or the commit latency exceed the configured sla.", "chain", chainID),
+		commits: r.NewCounter("gohotstuff_consensus_commits_total",
+			"Number of committed blocks.", "chain", chainID),
+		roundsBehind: r.NewGauge("gohotstuff_consensus_rounds_behind",
+			"Rounds the host is behind more than 1/3 of the validators, gossiped by the status msgs.", "chain", chainID),
+		execPending: r.NewGauge("gohotstuff_consensus_exec_pending_blocks",
+			"Committed blocks waiting for the executor.", "chain", chainID),
+		corruptBlocks: r.NewCounter("gohotstuff_storage_corrupt_blocks_total",
+			"Stored blocks found corrupted by the scrubber.", "chain", chainID),
+		repairedBlocks: r.NewCounter("gohotstuff_storage_repaired_blocks_total",
+			"Corrupted blocks replaced by the ones fetched from the peers.", "chain", chainID),
+		diskSuspended: r.NewGauge("gohotstuff_consensus_disk_suspended",
+			"1 if the voting is suspended by a failed write of the wal or the stores.", "chain", chainID),
+		viewChangesByReason: make(map[ViewChangeReason]*metrics.Counter),
+		phases:              newPhaseSummaries(r, chainID),
+	}
+	for _, reason := range viewChangeReasons {
+		m.viewChangesByReason[reason] = r.NewCounter("gohotstuff_consensus_view_changes_total",
+			"Number of view changes by the reason.", "chain", chainID, "reason", string(reason))
+	}
+	return m
+}
+
+// onViewChange is invoked when the host times out the round.
+func (s *State) onViewChange(ti timeoutInfo) {
+	round := ti.Round
+	validators := s.election.Validators(round, s.timeoutSet.GetTimeoutIdxMap())
+	reason := s.classifyViewChange(ti, validators)
+	leader := s.election.Leader(round, s.timeoutSet.GetTimeoutIdxMap())
+	s.log.Warn("view change @ state.onViewChange, round: %d, leader: %s, reason: %s", round, leader, reason)
+	s.recordViewChange(ViewChange{Round: round, Leader: leader, Reason: reason, Time: s.clock.Now()})
+
+	s.viewChanges++
+	s.metrics.viewChanges.Set(float64(s.viewChanges))
+	s.checkSLA(round, s.clock.Since(s.lastCommitTime))
+}
+
+// onCommitSLA is invoked once a block is committed.
+func (s *State) onCommitSLA(round int64) {
+	now := s.clock.Now()
+	latency := now.Sub(s.lastCommitTime)
+	s.lastCommitTime = now
+	s.viewChanges = 0
+	s.metrics.commits.Inc()
+	s.metrics.viewChanges.Set(0)
+	s.metrics.commitLatency.Set(latency.Seconds())
+	s.checkSLA(round, latency)
+}
+
+// checkSLA publishes a warning event for every exceeded sla,
+// the sla gauge keeps 1 until both of them are met again.
+func (s *State) checkSLA(round int64, latency time.Duration) {
+	violated := false
+	if s.cfg.MaxViewChanges > 0 && s.viewChanges > s.cfg.MaxViewChanges {
+		violated = true
+		s.log.Warn("view changes exceed the sla @ state.checkSLA, round: %d, view_changes: %d, max: %d",
+			round, s.viewChanges, s.cfg.MaxViewChanges)
+		s.publish(libs.Event{
+			Type:   libs.EventSLAViolation,
+			Module: libs.ChainModule(s.cfg.ChainID, libs.ConsensusModule),
+			Data: libs.SLAEventData{
+				Kind:      SLAViewChanges,
+				Round:     round,
+				Value:     float64(s.viewChanges),
+				Threshold: float64(s.cfg.MaxViewChanges),
+			},
+		})
+	}
+	if s.cfg.CommitSLA > 0 && latency > s.cfg.CommitSLA {
+		violated = true
+		s.log.Warn("commit latency exceeds the sla @ state.checkSLA, round: %d, latency: %v, sla: %v",
+			round, latency, s.cfg.CommitSLA)
+		s.publish(libs.Event{
+			Type:   libs.EventSLAViolation,
+			Module: libs.ChainModule(s.cfg.ChainID, libs.ConsensusModule),
+			Data: libs.SLAEventData{
+				Kind:      SLACommitLatency,
+				Round:     round,
+				Value:     latency.Seconds(),
+				Threshold: s.cfg.CommitSLA.Seconds(),
+			},
+		})
+	}
+	if violated {
+		s.metrics.slaViolated.Set(1)
+	} else {
+		s.metrics.slaViolated.Set(0)
+	}
+}
@@ -2,9 +2,10 @@ package state
 
 import (
 	"fmt"
+	"sync/atomic"
 
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
 	"github.com/aucusaga/gohotstuff/libs"
-	"github.com/aucusaga/gohotstuff/pb"
 	"github.com/aucusaga/gohotstuff/types"
 	"github.com/golang/protobuf/proto"
 )
@@ -22,13 +23,17 @@ func ConsMsgFromProto(msgbytes []byte) (MsgInfo, error) {
 	switch msg := msg.Sum.(type) {
 	case *pb.Message_Proposal:
 		consMsg = &types.ProposalMsg{
-			Round:         msg.Proposal.Round,
-			ID:            msg.Proposal.Id,
-			JustifyParent: msg.Proposal.Justify,
-			PeerID:        string(msg.Proposal.Pid),
-			PublicKey:     msg.Proposal.Pk,
-			Signature:     msg.Proposal.Signature,
-			Timestamp:     msg.Proposal.Timestamp,
+			Round:          msg.Proposal.Round,
+			ID:             msg.Proposal.Id,
+			JustifyParent:  msg.Proposal.Justify,
+			PeerID:         string(msg.Proposal.Pid),
+			PublicKey:      msg.Proposal.Pk,
+			Signature:      msg.Proposal.Signature,
+			Timestamp:      msg.Proposal.Timestamp,
+			Txs:            msg.Proposal.Txs,
+			AppHeight:      msg.Proposal.AppHeight,
+			AppHash:        msg.Proposal.AppHash,
+			VoteExtensions: msg.Proposal.VoteExtensions,
 		}
 	case *pb.Message_Vote:
 		consMsg = &types.VoteMsg{
@@ -41,6 +46,7 @@ func ConsMsgFromProto(msgbytes []byte) (MsgInfo, error) {
 			PublicKey:   msg.Vote.Pk,
 			Signature:   msg.Vote.Signature,
 			Timestamp:   msg.Vote.Timestamp,
+			Extension:   msg.Vote.Extension,
 		}
 	case *pb.Message_Timeout:
 		consMsg = &types.TimeoutMsg{
@@ -48,11 +54,34 @@ func ConsMsgFromProto(msgbytes []byte) (MsgInfo, error) {
 			Index:       msg.Timeout.Index,
 			ParentRound: msg.Timeout.ParentRound,
 			ParentID:    msg.Timeout.ParentId,
+			HighQC:      msg.Timeout.HighQc,
 			SendID:      string(msg.Timeout.Pid),
 			PublicKey:   msg.Timeout.Pk,
 			Signature:   msg.Timeout.Signature,
 			Timestamp:   msg.Timeout.Timestamp,
 		}
+	case *pb.Message_Status:
+		consMsg = &types.StatusMsg{
+			CommittedRound: msg.Status.CommittedRound,
+			Round:          msg.Status.CurrentRound,
+			HighQCRound:    msg.Status.HighQcRound,
+			SendID:         string(msg.Status.Pid),
+			PublicKey:      msg.Status.Pk,
+			Signature:      msg.Status.Signature,
+			Timestamp:      msg.Status.Timestamp,
+		}
+	case *pb.Message_ProposalRequest:
+		consMsg = &types.ProposalRequestMsg{
+			Round:     msg.ProposalRequest.Round,
+			ID:        msg.ProposalRequest.Id,
+			SendID:    string(msg.ProposalRequest.Pid),
+			PublicKey: msg.ProposalRequest.Pk,
+			Signature: msg.ProposalRequest.Signature,
+			Timestamp: msg.ProposalRequest.Timestamp,
+		}
+	}
+	if consMsg == nil {
+		return nil, fmt.Errorf("unknown msg type @ ConsMsgFromProto, type: %T", msg.Sum)
 	}
 
 	if err := consMsg.Validate(); err != nil {
@@ -62,20 +91,39 @@ func ConsMsgFromProto(msgbytes []byte) (MsgInfo, error) {
 }
 
 func ProtoFromConsMsg(msg MsgInfo) ([]byte, error) {
+	return DomainProtoFromConsMsg(msg, "", 0)
+}
+
+// DomainProtoFromConsMsg tags the proto message with the signing domain of the chain and the epoch,
+// see crypto.SignBytes.
+func DomainProtoFromConsMsg(msg MsgInfo, chainID string, epoch int64) ([]byte, error) {
+	return ForkProtoFromConsMsg(msg, chainID, "", epoch)
+}
+
+// ForkProtoFromConsMsg tags the proto message with the signing domain of the fork of the chain,
+// see crypto.ForkSignBytes.
+func ForkProtoFromConsMsg(msg MsgInfo, chainID string, forkID string, epoch int64) ([]byte, error) {
 	proto := pb.Message{
-		Module: libs.ConsensusModule,
+		Module:  libs.ConsensusModule,
+		ChainId: chainID,
+		ForkId:  forkID,
+		Epoch:   epoch,
 	}
 
 	switch msg := msg.(type) {
 	case *types.ProposalMsg:
 		proto.Sum = &pb.Message_Proposal{
 			Proposal: &pb.ProposalMessage{
-				Module:    libs.ConsensusModule,
-				Round:     msg.Round,
-				Id:        msg.ID,
-				Justify:   msg.JustifyParent,
-				Timestamp: msg.Timestamp,
-				Pid:       []byte(msg.PeerID),
+				Module:         libs.ConsensusModule,
+				Round:          msg.Round,
+				Id:             msg.ID,
+				Justify:        msg.JustifyParent,
+				Timestamp:      msg.Timestamp,
+				Pid:            []byte(msg.PeerID),
+				Txs:            msg.Txs,
+				AppHeight:      msg.AppHeight,
+				AppHash:        msg.AppHash,
+				VoteExtensions: msg.VoteExtensions,
 			},
 		}
 	case *types.VoteMsg:
@@ -91,6 +139,7 @@ func ProtoFromConsMsg(msg MsgInfo) ([]byte, error) {
 				CommitInfo: msg.CommitInfo,
 				Timestamp:  msg.Timestamp,
 				Pid:        []byte(msg.SendID),
+				Extension:  msg.Extension,
 			},
 		}
 	case *types.TimeoutMsg:
@@ -100,16 +149,47 @@ func ProtoFromConsMsg(msg MsgInfo) ([]byte, error) {
 				Round:       msg.Round,
 				ParentRound: msg.ParentRound,
 				ParentId:    msg.ParentID,
+				HighQc:      msg.HighQC,
 				Index:       msg.Index,
 				Timestamp:   msg.Timestamp,
 				Pid:         []byte(msg.SendID),
 			},
 		}
+	case *types.StatusMsg:
+		proto.Sum = &pb.Message_Status{
+			Status: &pb.StatusMessage{
+				Module:         libs.ConsensusModule,
+				CommittedRound: msg.CommittedRound,
+				CurrentRound:   msg.Round,
+				HighQcRound:    msg.HighQCRound,
+				Timestamp:      msg.Timestamp,
+				Pid:            []byte(msg.SendID),
+			},
+		}
+	case *types.ProposalRequestMsg:
+		proto.Sum = &pb.Message_ProposalRequest{
+			ProposalRequest: &pb.ProposalRequestMessage{
+				Module:    libs.ConsensusModule,
+				Round:     msg.Round,
+				Id:        msg.ID,
+				Timestamp: msg.Timestamp,
+				Pid:       []byte(msg.SendID),
+			},
+		}
 	}
 
 	return proto.Marshal()
 }
 
+// MsgDomain returns the signing domain of the proto message.
+func MsgDomain(msgbytes []byte) (chainID string, forkID string, epoch int64, err error) {
+	var msg pb.Message
+	if err := proto.Unmarshal(msgbytes, &msg); err != nil {
+		return "", "", 0, fmt.Errorf("unmarshal bytes fail @ MsgDomain, err: %v", err)
+	}
+	return msg.ChainId, msg.ForkId, msg.Epoch, nil
+}
+
 func VoteMsg(round int64, id []byte, pround int64, pid []byte, to string) *types.VoteMsg {
 	return &types.VoteMsg{
 		Round:       round,
@@ -120,19 +200,88 @@ func VoteMsg(round int64, id []byte, pround int64, pid []byte, to string) *types
 	}
 }
 
-func ProposalMsg(round int64, id []byte, justifyParent []byte) *types.ProposalMsg {
+func ProposalMsg(round int64, id []byte, justifyParent []byte, txs [][]byte) *types.ProposalMsg {
 	return &types.ProposalMsg{
 		Round:         round,
 		ID:            id,
 		JustifyParent: justifyParent,
+		Txs:           txs,
 	}
 }
 
-func TimeoutMsg(round int64, pround int64, pid []byte, idx int64) *types.TimeoutMsg {
+func TimeoutMsg(round int64, pround int64, pid []byte, highQC []byte, idx int64) *types.TimeoutMsg {
 	return &types.TimeoutMsg{
 		Round:       round,
 		Index:       idx,
 		ParentRound: pround,
 		ParentID:    pid,
+		HighQC:      highQC,
+	}
+}
+
+func ProposalRequestMsg(round int64, id []byte, to string) *types.ProposalRequestMsg {
+	return &types.ProposalRequestMsg{
+		Round: round,
+		ID:    id,
+		To:    to,
+	}
+}
+
+// msgRound returns the round which decides the epoch of the msg.
+func msgRound(msg MsgInfo) int64 {
+	switch msg := msg.(type) {
+	case *types.ProposalMsg:
+		return msg.Round
+	case *types.VoteMsg:
+		return msg.Round
+	case *types.TimeoutMsg:
+		return msg.Round
+	case *types.StatusMsg:
+		return msg.Round
+	case *types.ProposalRequestMsg:
+		return msg.Round
+	}
+	return 0
+}
+
+// msgExpired reports the votes and the timeouts of the rounds before the previous one, which are
+// useless once the view has advanced. The proposals are kept, the lagging peers fetch them as well.
+func (s *State) msgExpired(msgBytes []byte) bool {
+	msg, err := ConsMsgFromProto(msgBytes)
+	if err != nil {
+		return false
+	}
+	switch msg.(type) {
+	case *types.VoteMsg, *types.TimeoutMsg:
+		return msgRound(msg) < atomic.LoadInt64(&s.view)-1
+	}
+	return false
+}
+
+// isProposal reports the proposals, which the slow peers pull once the votes, the timeouts
+// or the children referring to them arrive, see requestProposal.
+func isProposal(msgBytes []byte) bool {
+	msg, err := ConsMsgFromProto(msgBytes)
+	if err != nil {
+		return false
+	}
+	_, ok := msg.(*types.ProposalMsg)
+	return ok
+}
+
+// msgSender returns the peer who claims to send the msg.
+func msgSender(msg MsgInfo) string {
+	switch msg := msg.(type) {
+	case *types.ProposalMsg:
+		return msg.PeerID
+	case *types.VoteMsg:
+		return msg.SendID
+	case *types.TimeoutMsg:
+		return msg.SendID
+	case *types.StatusMsg:
+		return msg.SendID
+	case *types.ProposalRequestMsg:
+		return msg.SendID
 	}
+	return ""
 }
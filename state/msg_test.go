@@ -0,0 +1,112 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+func TestStatusMsgRoundTrip(t *testing.T) {
+	want := &types.StatusMsg{
+		CommittedRound: 3,
+		Round:          7,
+		HighQCRound:    5,
+		SendID:         "QmA",
+		Timestamp:      1,
+	}
+	msgbytes, err := ProtoFromConsMsg(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	has, err := ConsMsgFromProto(msgbytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(has, want) {
+		t.Errorf("want: %+v, has: %+v", want, has)
+	}
+	if msgSender(has) != "QmA" {
+		t.Errorf("unexpected sender: %s", msgSender(has))
+	}
+
+	invalid := &types.StatusMsg{CommittedRound: 6, Round: 7, HighQCRound: 5}
+	if msgbytes, err = ProtoFromConsMsg(invalid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ConsMsgFromProto(msgbytes); err == nil {
+		t.Errorf("committed round beyond the high qc should be rejected")
+	}
+}
+
+func TestTimeoutMsgRoundTrip(t *testing.T) {
+	want := TimeoutMsg(7, 5, []byte("b"), []byte(`{"round":5}`), 1)
+	want.SendID = "QmA"
+	want.Timestamp = 1
+	msgbytes, err := ProtoFromConsMsg(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	has, err := ConsMsgFromProto(msgbytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(has, want) {
+		t.Errorf("want: %+v, has: %+v", want, has)
+	}
+}
+
+func TestMsgExpired(t *testing.T) {
+	s := &State{view: 10}
+	encode := func(m MsgInfo) []byte {
+		msgbytes, err := ProtoFromConsMsg(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msgbytes
+	}
+	if !s.msgExpired(encode(VoteMsg(8, []byte("a"), 7, []byte("b"), "QmA"))) {
+		t.Errorf("vote of a past view should expire")
+	}
+	if s.msgExpired(encode(VoteMsg(9, []byte("a"), 8, []byte("b"), "QmA"))) {
+		t.Errorf("vote for the leader of the current view expired")
+	}
+	if !s.msgExpired(encode(TimeoutMsg(8, 7, []byte("b"), nil, 0))) || s.msgExpired(encode(TimeoutMsg(10, 9, []byte("b"), nil, 0))) {
+		t.Errorf("only the timeouts of the past views expire")
+	}
+	// the lagging peers fetch the past proposals
+	if s.msgExpired(encode(ProposalMsg(3, []byte("a"), nil, nil))) {
+		t.Errorf("proposal expired")
+	}
+}
+
+func TestCheckDomain(t *testing.T) {
+	election := NewDefaultElection(0, []PeerID{"a"})
+	if err := election.Update(10, []PeerID{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	s := &State{cfg: &ConsensusConfig{ChainID: "a", ForkID: "f"}, election: election}
+
+	vote := VoteMsg(12, []byte("id"), 11, []byte("pid"), "a")
+	cases := []struct {
+		chainID string
+		forkID  string
+		epoch   int64
+		ok      bool
+	}{
+		{"a", "f", 10, true},
+		{"b", "f", 10, false},
+		{"a", "f", 0, false},
+		{"a", "", 10, false},
+		{"a", "g", 10, false},
+	}
+	for i, c := range cases {
+		msgbytes, err := ForkProtoFromConsMsg(vote, c.chainID, c.forkID, c.epoch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.checkDomain(msgbytes, vote); (err == nil) != c.ok {
+			t.Errorf("case %d, want ok: %v, err: %v", i, c.ok, err)
+		}
+	}
+}
@@ -0,0 +1,502 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/storage"
+)
+
+const (
+	// DefaultObserverRate is the number of blocks sent to a subscriber per second, a second of them can burst.
+	DefaultObserverRate = 50
+	// observerQueueSize bounds the new blocks queued for a subscriber, a subscriber lagging further is dropped.
+	observerQueueSize = 256
+)
+
+// ObserverReactor serves the committed blocks and their QCs to the peers subscribed on the observer channel,
+// so that the non-validators follow the chain without joining the consensus channel.
+// Every subscriber is rate limited, the blocks before the new ones are served from the block store.
+type ObserverReactor struct {
+	state   *State
+	module  string
+	channel int32
+	rate    int
+	sw      libs.Switch
+
+	// the subscribers of the host, the blocks are published to them by the commit hooks
+	subs   map[string]*subscriber
+	subMtx sync.Mutex
+
+	peers map[string]libs.Peer
+	// the subscription of the host, see Follow
+	following bool
+	last      *storage.CommittedBlock
+	// resyncUntil ends the subscription of a host which doesn't follow the chain, see ResyncFrom
+	resyncUntil int64
+	// the heights of the corrupted blocks fetched from the peers, see Repair
+	repairs map[int64]bool
+	mtx     sync.Mutex
+	log     libs.Logger
+}
+
+type subscriber struct {
+	peer   libs.Peer
+	bucket tokenBucket
+	queue  chan *storage.CommittedBlock
+	quit   chan struct{}
+	// the new blocks are queued once the backfill from the block store is done
+	live bool
+}
+
+// NewObserverReactor serves the committed blocks of the state, rate is the blocks sent per second to a subscriber.
+func NewObserverReactor(s *State, rate int) *ObserverReactor {
+	if rate <= 0 {
+		rate = DefaultObserverRate
+	}
+	r := &ObserverReactor{
+		state:   s,
+		module:  libs.ChainModule(s.cfg.ChainID, libs.ObserverModule),
+		channel: libs.ChainChannel(s.cfg.ChainID, libs.ObserverChannel),
+		rate:    rate,
+		peers:   make(map[string]libs.Peer),
+		subs:    make(map[string]*subscriber),
+		repairs: make(map[int64]bool),
+		log:     s.log,
+	}
+	s.OnCommitBlock(r.publish)
+	s.OnCorruptBlock(r.Repair)
+	return r
+}
+
+func (r *ObserverReactor) Channel() int32 {
+	return r.channel
+}
+
+func (r *ObserverReactor) SetSwitch(sw libs.Switch) {
+	r.sw = sw
+}
+
+func (r *ObserverReactor) AddPeer(peer libs.Peer) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.peers[peer.PeerID()] = peer
+	if r.following {
+		r.sendSubscribe(peer, r.nextHeight())
+	}
+}
+
+func (r *ObserverReactor) RemovePeer(peer libs.Peer, reason interface{}) {
+	r.mtx.Lock()
+	delete(r.peers, peer.PeerID())
+	r.mtx.Unlock()
+
+	r.subMtx.Lock()
+	defer r.subMtx.Unlock()
+	if sub, ok := r.subs[peer.PeerID()]; ok {
+		close(sub.quit)
+		delete(r.subs, peer.PeerID())
+	}
+}
+
+// HandleFunc drops the msg, the subscriptions and the fetches are served to the sender told by the transport,
+// see HandleFuncFrom, never to the one claimed by the msg.
+func (r *ObserverReactor) HandleFunc(chID int32, msgBytes []byte) {
+	if chID == r.channel {
+		r.log.Warn("observer msg without sender dropped @ state.ObserverReactor, msg: %s", libs.GetSum(msgBytes))
+	}
+}
+
+// HandleFuncFrom handles the observer msg of the peer, see libs.PeerReactor.
+func (r *ObserverReactor) HandleFuncFrom(peerID string, chID int32, msgBytes []byte) {
+	if chID != r.channel {
+		return
+	}
+	var msg pb.ObserverMessage
+	if err := msg.Unmarshal(msgBytes); err != nil {
+		r.log.Error("unmarshal observer msg fail @ state.ObserverReactor, err: %v", err)
+		return
+	}
+	if msg.Module != r.module {
+		return
+	}
+	switch sum := msg.Sum.(type) {
+	case *pb.ObserverMessage_Subscribe:
+		r.subscribe(peerID, sum.Subscribe.FromHeight)
+	case *pb.ObserverMessage_Fetch:
+		r.serveFetch(peerID, sum.Fetch.Height)
+	case *pb.ObserverMessage_Block:
+		var block storage.CommittedBlock
+		if err := json.Unmarshal(sum.Block.Block, &block); err != nil {
+			r.log.Warn("decode observed block fail @ state.ObserverReactor, from: %s, err: %v", peerID, err)
+			return
+		}
+		if r.repair(peerID, &block) {
+			return
+		}
+		r.observe(peerID, &block)
+	}
+}
+
+// Repair fetches the stored block of the height from the peers to replace the corrupted one,
+// the first block passing the checks of the scrubber is kept, see State.OnCorruptBlock.
+func (r *ObserverReactor) Repair(height int64) {
+	if r.state.blocks == nil {
+		return
+	}
+	msg := &pb.ObserverMessage{
+		Module: r.module,
+		Pid:    []byte(r.state.host),
+		Sum:    &pb.ObserverMessage_Fetch{Fetch: &pb.ObserverFetch{Height: height}},
+	}
+	msgBytes, err := msg.Marshal()
+	if err != nil {
+		return
+	}
+
+	r.mtx.Lock()
+	r.repairs[height] = true
+	peers := make([]libs.Peer, 0, len(r.peers))
+	for _, peer := range r.peers {
+		peers = append(peers, peer)
+	}
+	r.mtx.Unlock()
+
+	for _, peer := range peers {
+		peer.Send(r.channel, msgBytes)
+	}
+}
+
+// repair handles the block fetched for a repair, it returns false if the height isn't being repaired.
+func (r *ObserverReactor) repair(pid string, block *storage.CommittedBlock) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if !r.repairs[block.Height] {
+		return false
+	}
+	if err := r.state.repairBlock(pid, block); err != nil {
+		r.log.Warn("invalid repairing block @ state.ObserverReactor, from: %s, height: %d, err: %v", pid, block.Height, err)
+		return true
+	}
+	delete(r.repairs, block.Height)
+	return true
+}
+
+// serveFetch replies the stored block of the height to the peer, the missing ones are ignored.
+func (r *ObserverReactor) serveFetch(pid string, height int64) {
+	r.mtx.Lock()
+	peer, ok := r.peers[pid]
+	r.mtx.Unlock()
+	if !ok || r.state.blocks == nil {
+		return
+	}
+	block, err := r.state.blocks.Load(height)
+	if err != nil {
+		r.log.Warn("load fetched block fail @ state.ObserverReactor, peer: %s, height: %d, err: %v", pid, height, err)
+		return
+	}
+	if msgBytes, err := r.blockMsg(block); err == nil {
+		peer.Send(r.channel, msgBytes)
+	}
+}
+
+// subscribe starts serving the peer from the height, a former subscription of the peer is replaced.
+func (r *ObserverReactor) subscribe(pid string, from int64) {
+	r.mtx.Lock()
+	peer, ok := r.peers[pid]
+	r.mtx.Unlock()
+	if !ok {
+		return
+	}
+
+	r.subMtx.Lock()
+	defer r.subMtx.Unlock()
+	if old, ok := r.subs[pid]; ok {
+		close(old.quit)
+	}
+	sub := &subscriber{
+		peer:   peer,
+		bucket: tokenBucket{rate: float64(r.rate), burst: float64(r.rate)},
+		queue:  make(chan *storage.CommittedBlock, observerQueueSize),
+		quit:   make(chan struct{}),
+	}
+	r.subs[pid] = sub
+	r.log.Info("observer subscribed @ state.ObserverReactor, peer: %s, from: %d", pid, from)
+	r.state.routines.Spawn("observer/"+pid, func() { r.serve(sub, from) })
+}
+
+// publish queues the committed block for the live subscribers, it's invoked in the procedure mutex.
+func (r *ObserverReactor) publish(block *storage.CommittedBlock) {
+	r.subMtx.Lock()
+	defer r.subMtx.Unlock()
+
+	for pid, sub := range r.subs {
+		if !sub.live {
+			continue
+		}
+		select {
+		case sub.queue <- block:
+		default:
+			r.log.Warn("observer lags too far, unsubscribe it @ state.ObserverReactor, peer: %s, height: %d", pid, block.Height)
+			close(sub.quit)
+			delete(r.subs, pid)
+		}
+	}
+}
+
+func (r *ObserverReactor) serve(sub *subscriber, from int64) {
+	sent := from - 1
+	backfill := func() bool {
+		if r.state.blocks == nil {
+			return true
+		}
+		heights, err := r.state.blocks.Heights(sent+1, 0)
+		if err != nil {
+			r.log.Warn("list blocks fail @ state.ObserverReactor, err: %v", err)
+			return true
+		}
+		for _, h := range heights {
+			block, err := r.state.blocks.Load(h)
+			if err != nil {
+				r.log.Warn("load block fail @ state.ObserverReactor, height: %d, err: %v", h, err)
+				continue
+			}
+			if !r.send(sub, block) {
+				return false
+			}
+			sent = h
+		}
+		return true
+	}
+	if !backfill() {
+		return
+	}
+	// the blocks committed during the backfill are either in the store or queued from now on
+	r.subMtx.Lock()
+	sub.live = true
+	r.subMtx.Unlock()
+	if !backfill() {
+		return
+	}
+	for {
+		select {
+		case block := <-sub.queue:
+			if block.Height <= sent {
+				continue
+			}
+			if !r.send(sub, block) {
+				return
+			}
+			sent = block.Height
+		case <-sub.quit:
+			return
+		case <-r.state.quit:
+			return
+		}
+	}
+}
+
+// send waits for the rate limit of the subscriber, it returns false once the subscription ends.
+func (r *ObserverReactor) send(sub *subscriber, block *storage.CommittedBlock) bool {
+	if wait := sub.bucket.take(r.state.clock.Now()); wait > 0 {
+		select {
+		case <-r.state.clock.After(wait):
+		case <-sub.quit:
+			return false
+		case <-r.state.quit:
+			return false
+		}
+	}
+	msgBytes, err := r.blockMsg(block)
+	if err != nil {
+		return true
+	}
+	if !sub.peer.Send(r.channel, msgBytes) {
+		r.log.Warn("send block to observer fail @ state.ObserverReactor, peer: %s, height: %d", sub.peer.PeerID(), block.Height)
+	}
+	return true
+}
+
+func (r *ObserverReactor) blockMsg(block *storage.CommittedBlock) ([]byte, error) {
+	data, err := json.Marshal(block)
+	if err != nil {
+		r.log.Error("marshal block fail @ state.ObserverReactor, height: %d, err: %v", block.Height, err)
+		return nil, err
+	}
+	msg := &pb.ObserverMessage{
+		Module: r.module,
+		Pid:    []byte(r.state.host),
+		Sum:    &pb.ObserverMessage_Block{Block: &pb.ObserverBlock{Height: block.Height, Block: data}},
+	}
+	return msg.Marshal()
+}
+
+// Follow subscribes the host to its peers, the observed blocks are verified against their QCs and the validators
+// of their rounds, chained to the previous one or the genesis, and handled like the committed ones: persisted
+// to the block store, delivered to the commit sinks and relayed to the subscribers of the host.
+func (r *ObserverReactor) Follow() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.state.blocks != nil && r.last == nil {
+		heights, err := r.state.blocks.Heights(0, 0)
+		if err != nil {
+			return err
+		}
+		if len(heights) > 0 {
+			if r.last, err = r.state.blocks.Load(heights[len(heights)-1]); err != nil {
+				return err
+			}
+		}
+	}
+	r.following = true
+	r.resyncUntil = 0
+	for _, peer := range r.peers {
+		r.sendSubscribe(peer, r.nextHeight())
+	}
+	return nil
+}
+
+// ResyncFrom subscribes the host to its peers from the height again, the blocks served are verified
+// against the last stored block below the height and overwrite the stored ones. It recovers a host whose
+// block store has a gap or a corrupted block without restarting it, and returns the number of peers subscribed.
+// A host which doesn't follow the chain stops observing once it reaches the highest round committed
+// by itself or its peers, the later blocks are left to the consensus.
+func (r *ObserverReactor) ResyncFrom(height int64) (int, error) {
+	if height < 0 {
+		return 0, fmt.Errorf("resync height must not be negative, height: %d", height)
+	}
+	r.state.mtx.RLock()
+	until := r.state.committedRound
+	r.state.mtx.RUnlock()
+	if best, ok := r.state.BestPeer(); ok && best.CommittedRound > until {
+		until = best.CommittedRound
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var last *storage.CommittedBlock
+	if r.state.blocks != nil && height > 0 {
+		heights, err := r.state.blocks.Heights(0, height-1)
+		if err != nil {
+			return 0, err
+		}
+		if len(heights) > 0 {
+			if last, err = r.state.blocks.Load(heights[len(heights)-1]); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if !r.following || r.resyncUntil > 0 {
+		if until < height {
+			return 0, fmt.Errorf("nothing to resync, height: %d, committed: %d", height, until)
+		}
+		r.resyncUntil = until
+	}
+	r.last = last
+	r.following = true
+	r.log.Warn("resync observed blocks @ state.ObserverReactor, from: %d, until: %d, peers: %d", height, r.resyncUntil, len(r.peers))
+	for _, peer := range r.peers {
+		r.sendSubscribe(peer, height)
+	}
+	return len(r.peers), nil
+}
+
+func (r *ObserverReactor) nextHeight() int64 {
+	if r.last == nil {
+		return 0
+	}
+	return r.last.Height + 1
+}
+
+func (r *ObserverReactor) sendSubscribe(peer libs.Peer, from int64) {
+	msg := &pb.ObserverMessage{
+		Module: r.module,
+		Pid:    []byte(r.state.host),
+		Sum:    &pb.ObserverMessage_Subscribe{Subscribe: &pb.ObserverSubscribe{FromHeight: from}},
+	}
+	msgBytes, err := msg.Marshal()
+	if err != nil {
+		return
+	}
+	peer.Send(r.channel, msgBytes)
+}
+
+// observe handles a block served by the peer, every peer serves the same chain so the duplicates are skipped.
+func (r *ObserverReactor) observe(pid string, block *storage.CommittedBlock) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if !r.following || (r.last != nil && block.Height <= r.last.Height) {
+		return
+	}
+	a := r.state.auditor()
+	if err := verifyCommittedBlock(a.deserialize(), r.last, block); err != nil {
+		r.log.Warn("invalid observed block @ state.ObserverReactor, from: %s, height: %d, err: %v", pid, block.Height, err)
+		// a block may be missed once the peer dropped us, catch up from the missing one
+		if peer, ok := r.peers[pid]; ok && block.ParentRound > r.nextHeight()-1 {
+			r.sendSubscribe(peer, r.nextHeight())
+		}
+		return
+	}
+	if err := r.certified(a, block); err != nil {
+		r.log.Warn("uncertified observed block @ state.ObserverReactor, from: %s, height: %d, err: %v", pid, block.Height, err)
+		return
+	}
+	r.last = block
+	r.state.saveBlock(block)
+	r.state.enqueueCommit(block)
+	r.state.runCommitHooks(block)
+	if r.resyncUntil > 0 && block.Height >= r.resyncUntil {
+		r.log.Info("resync done @ state.ObserverReactor, height: %d", block.Height)
+		r.following, r.resyncUntil = false, 0
+	}
+}
+
+// certified checks the observed block is anchored, i.e. the first block observed by a host with an empty store
+// extends the genesis, and that its QC is signed by a quorum of the validators of its round, so that a peer
+// can't make the host follow a chain of its own.
+func (r *ObserverReactor) certified(a *Auditor, block *storage.CommittedBlock) error {
+	cfg := r.state.cfg
+	if r.last == nil && (block.ParentRound != cfg.StartRound || string(block.ParentID) != cfg.StartID) {
+		return fmt.Errorf("%w, unanchored block, height: %d, parent: %d/%x", ErrBrokenQCChain, block.Height, block.ParentRound, block.ParentID)
+	}
+	if a.Election == nil {
+		return fmt.Errorf("%w, no validator set, height: %d", ErrQuorumNotReached, block.Height)
+	}
+	_, err := a.verify(r.last, block)
+	return err
+}
+
+// tokenBucket allows rate takes per second, up to burst of them at once.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// take consumes a token, it returns how long to wait until the token is available.
+func (b *tokenBucket) take(now time.Time) time.Duration {
+	if b.last.IsZero() {
+		b.tokens = b.burst
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
@@ -0,0 +1,182 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	pb "github.com/aucusaga/gohotstuff/api/gohotstuff/v1"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/storage"
+)
+
+// pipePeer hands the msgs to the reactor of the peer as sent by from.
+type pipePeer struct {
+	id   string
+	from string
+	to   *ObserverReactor
+}
+
+func (p *pipePeer) PeerID() string { return p.id }
+
+func (p *pipePeer) Send(chID int32, msgBytes []byte) bool {
+	p.to.HandleFuncFrom(p.from, chID, msgBytes)
+	return true
+}
+
+func newObserverState(host string, blocks *storage.BlockStore) *State {
+	return &State{
+		host:     PeerID(host),
+		cfg:      &ConsensusConfig{ChainID: "test", StartID: "genesis"},
+		election: NewDefaultElection(1, []PeerID{"a"}),
+		blocks:   blocks,
+		clock:    libs.SystemClock,
+		quit:     make(chan struct{}),
+		log:      logs.NewLogger(),
+	}
+}
+
+// pipe connects the reactors of the hosts "v" and "o".
+func pipe(rv, ro *ObserverReactor) {
+	rv.AddPeer(&pipePeer{id: "o", from: "v", to: ro})
+	ro.AddPeer(&pipePeer{id: "v", from: "o", to: rv})
+}
+
+func waitHeights(t *testing.T, blocks *storage.BlockStore, want int) []int64 {
+	heights, _ := blocks.Heights(0, 0)
+	for deadline := time.Now().Add(5 * time.Second); len(heights) < want && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		heights, _ = blocks.Heights(0, 0)
+	}
+	return heights
+}
+
+func TestObserver(t *testing.T) {
+	served := newSignedChain(t, signedByA, 1, 2, 4)
+	observed, _ := storage.NewBlockStore(t.TempDir())
+	validator, observer := newObserverState("v", served), newObserverState("o", observed)
+	defer close(validator.quit)
+	defer close(observer.quit)
+	rv, ro := NewObserverReactor(validator, 1000), NewObserverReactor(observer, 1000)
+	pipe(rv, ro)
+	if err := ro.Follow(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the committed blocks are backfilled from the store
+	if heights := waitHeights(t, observed, 3); len(heights) != 3 || heights[2] != 4 {
+		t.Fatalf("backfill fail, heights: %v", heights)
+	}
+
+	// a peer can't take over the subscription of another one by claiming its id
+	rv.subMtx.Lock()
+	sub := rv.subs["o"]
+	rv.subMtx.Unlock()
+	rv.AddPeer(&pipePeer{id: "m", from: "v", to: ro})
+	forged, _ := (&pb.ObserverMessage{Module: rv.module, Pid: []byte("o"),
+		Sum: &pb.ObserverMessage_Subscribe{Subscribe: &pb.ObserverSubscribe{FromHeight: 0}}}).Marshal()
+	rv.HandleFuncFrom("m", rv.channel, forged)
+	rv.HandleFunc(rv.channel, forged)
+	rv.subMtx.Lock()
+	if rv.subs["o"] != sub {
+		t.Error("want the subscription of the peer kept")
+	}
+	rv.subMtx.Unlock()
+
+	// the new ones are served once committed
+	live := newSignedChain(t, signedByA, 1, 2, 4, 5, 6)
+	b5, _ := live.Load(5)
+	validator.runCommitHooks(b5)
+	if heights := waitHeights(t, observed, 4); len(heights) != 4 || heights[3] != 5 {
+		t.Fatalf("live block fail, heights: %v", heights)
+	}
+
+	// a block off the QC chain is refused, so is a block without the signed votes
+	qc, _ := NewDefaultQuorumCert("a", nil, 7, []byte{7}, 6, []byte{6})
+	raw, _ := qc.Serialize()
+	validator.runCommitHooks(&storage.CommittedBlock{Height: 7, ID: []byte{7}, ParentRound: 6, ParentID: []byte{6}, Proposer: "a", QC: raw})
+	unsigned, _ := newExportedChain(t, 1, 2, 4, 5, 6).Load(6)
+	validator.runCommitHooks(unsigned)
+	time.Sleep(100 * time.Millisecond)
+	if heights, _ := observed.Heights(0, 0); len(heights) != 4 {
+		t.Errorf("broken block observed, heights: %v", heights)
+	}
+}
+
+func TestObserverAnchor(t *testing.T) {
+	for name, c := range map[string]struct {
+		served  *storage.BlockStore
+		genesis string
+	}{
+		"unanchored": {newSignedChain(t, signedByA, 1, 2, 4), "other"},
+		"unsigned":   {newExportedChain(t, 1, 2, 4), "genesis"},
+	} {
+		observed, _ := storage.NewBlockStore(t.TempDir())
+		validator, observer := newObserverState("v", c.served), newObserverState("o", observed)
+		observer.cfg.StartID = c.genesis
+		rv, ro := NewObserverReactor(validator, 1000), NewObserverReactor(observer, 1000)
+		pipe(rv, ro)
+		if err := ro.Follow(); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		if heights, _ := observed.Heights(0, 0); len(heights) != 0 {
+			t.Errorf("want no %s block observed, heights: %v", name, heights)
+		}
+		close(validator.quit)
+		close(observer.quit)
+	}
+}
+
+func TestObserverResync(t *testing.T) {
+	served := newSignedChain(t, signedByA, 1, 2, 4)
+	observed := newSignedChain(t, signedByA, 1, 2, 4)
+	corrupted, _ := observed.Load(4)
+	corrupted.Txs = [][]byte{[]byte("corrupted")}
+	observed.Save(corrupted)
+
+	validator, observer := newObserverState("v", served), newObserverState("o", observed)
+	defer close(validator.quit)
+	defer close(observer.quit)
+	observer.committedRound = 4
+	rv, ro := NewObserverReactor(validator, 1000), NewObserverReactor(observer, 1000)
+	pipe(rv, ro)
+
+	if _, err := ro.ResyncFrom(5); err == nil {
+		t.Error("want nothing to resync beyond the committed round")
+	}
+	peers, err := ro.ResyncFrom(3)
+	if err != nil || peers != 1 {
+		t.Fatalf("want the peer subscribed, has: %d, err: %v", peers, err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if b, _ := observed.Load(4); string(b.Txs[0]) == "tx" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the corrupted block should be fetched again")
+		}
+	}
+
+	// the host which doesn't follow the chain stops at the committed round
+	ro.mtx.Lock()
+	following := ro.following
+	ro.mtx.Unlock()
+	if following {
+		t.Error("want the resync done at the committed round")
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	b := tokenBucket{rate: 2, burst: 2}
+	now := time.Now()
+	if b.take(now) != 0 || b.take(now) != 0 {
+		t.Fatal("burst is limited")
+	}
+	if wait := b.take(now); wait != 500*time.Millisecond {
+		t.Errorf("want wait 500ms, has: %v", wait)
+	}
+	if wait := b.take(now.Add(2 * time.Second)); wait != 0 {
+		t.Errorf("refilled bucket is limited, wait: %v", wait)
+	}
+}
@@ -0,0 +1,37 @@
+package state
+
+import (
+	"github.com/aucusaga/gohotstuff/storage"
+)
+
+// StateOption substitutes a component which the state otherwise builds from its config,
+// so that the embedding applications can plug in their own, e.g. the stores backed by a database.
+type StateOption func(o *stateOptions)
+
+type stateOptions struct {
+	wal     WAL
+	blocks  storage.BlockStorage
+	results storage.ResultStorage
+}
+
+// WithWAL replaces the wal under ConsensusConfig.WALPath, the votes are replayed from it as well,
+// ConsensusConfig.WALFlush only applies to the BaseWAL.
+func WithWAL(wal WAL) StateOption {
+	return func(o *stateOptions) {
+		o.wal = wal
+	}
+}
+
+// WithBlockStorage replaces the block store under ConsensusConfig.BlocksPath.
+func WithBlockStorage(blocks storage.BlockStorage) StateOption {
+	return func(o *stateOptions) {
+		o.blocks = blocks
+	}
+}
+
+// WithResultStorage replaces the result store under ConsensusConfig.ResultsPath.
+func WithResultStorage(results storage.ResultStorage) StateOption {
+	return func(o *stateOptions) {
+		o.results = results
+	}
+}
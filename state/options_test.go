@@ -0,0 +1,61 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/storage"
+)
+
+// memBlocks is a block storage in memory.
+type memBlocks map[int64]*storage.CommittedBlock
+
+func (m memBlocks) Save(block *storage.CommittedBlock) error {
+	m[block.Height] = block
+	return nil
+}
+
+func (m memBlocks) Load(height int64) (*storage.CommittedBlock, error) {
+	b, ok := m[height]
+	if !ok {
+		return nil, storage.ErrBlockNotFound
+	}
+	return b, nil
+}
+
+func (m memBlocks) Heights(from, to int64) ([]int64, error) {
+	var heights []int64
+	for h := range m {
+		if h >= from && (to <= 0 || h <= to) {
+			heights = append(heights, h)
+		}
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return heights, nil
+}
+
+func TestStateOptions(t *testing.T) {
+	dir := t.TempDir()
+	log := logs.NewLogger()
+	cfg := &ConsensusConfig{ChainID: "test", StartRound: 1, StartID: "genesis", StartValue: []byte("genesis"),
+		StartValidators: keyPeers[:1], BlocksPath: filepath.Join(dir, "blocks")}
+	blocks := memBlocks{1: {Height: 1, ID: []byte("genesis")}}
+	s, err := NewState(keyPeers[0], newKeyClient(t), NewDefaultTimeoutTicker(log), log, cfg, WithBlockStorage(blocks))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cfg.BlocksPath); !os.IsNotExist(err) {
+		t.Errorf("want the block store under the path substituted, err: %v", err)
+	}
+	heights, err := s.blocks.Heights(0, 0)
+	if err != nil || len(heights) != 1 || heights[0] != 1 {
+		t.Errorf("want the given block storage, heights: %v, err: %v", heights, err)
+	}
+	// the results aren't persisted without a path or a substitute
+	if s.results != nil {
+		t.Error("want no result store")
+	}
+}
@@ -0,0 +1,202 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultEpochLength is the number of rounds of an epoch, at whose boundaries the parameter changes take effect.
+const DefaultEpochLength = 100
+
+var (
+	// ParamChangePrefix marks the txs carrying a ParamChange, the rest of the tx is its json encoding.
+	ParamChangePrefix = []byte("gohotstuff/params/v1:")
+
+	ErrInvalidParamChange = errors.New("invalid consensus param change")
+)
+
+// ConsensusParams are the parameters every validator has to agree on,
+// the ones in force at a round are given by State.ParamsAt.
+type ConsensusParams struct {
+	RoundTimeout  time.Duration `json:"round_timeout"`
+	MaxBlockTxs   int           `json:"max_block_txs"`
+	MaxBlockBytes int           `json:"max_block_bytes"`
+	MaxTxBytes    int           `json:"max_tx_bytes"`
+	EpochLength   int64         `json:"epoch_length"`
+	MaxClockSkew  time.Duration `json:"max_clock_skew"`
+}
+
+// ParamChange is the governance-style proposal of new parameters, the zero fields are left unchanged.
+// It's committed as an ordinary tx, see EncodeParamChange, and takes effect at the second epoch
+// boundary after the commit, so that every honest validator has committed it by then.
+type ParamChange struct {
+	RoundTimeoutMs int64 `json:"round_timeout_ms,omitempty"`
+	MaxBlockTxs    int   `json:"max_block_txs,omitempty"`
+	MaxBlockBytes  int   `json:"max_block_bytes,omitempty"`
+	MaxTxBytes     int   `json:"max_tx_bytes,omitempty"`
+	EpochLength    int64 `json:"epoch_length,omitempty"`
+	MaxClockSkewMs int64 `json:"max_clock_skew_ms,omitempty"`
+}
+
+// EncodeParamChange builds the tx carrying the change.
+func EncodeParamChange(c ParamChange) ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, ParamChangePrefix...), raw...), nil
+}
+
+// DecodeParamChange parses the tx, ok is false if the tx doesn't carry a change.
+func DecodeParamChange(tx []byte) (c ParamChange, ok bool, err error) {
+	if !bytes.HasPrefix(tx, ParamChangePrefix) {
+		return c, false, nil
+	}
+	if err := json.Unmarshal(tx[len(ParamChangePrefix):], &c); err != nil {
+		return c, true, fmt.Errorf("%w, err: %v", ErrInvalidParamChange, err)
+	}
+	return c, true, c.Validate()
+}
+
+func (c ParamChange) Validate() error {
+	if c.RoundTimeoutMs < 0 || c.MaxBlockTxs < 0 || c.MaxBlockBytes < 0 || c.MaxTxBytes < 0 || c.EpochLength < 0 ||
+		c.MaxClockSkewMs < 0 {
+		return fmt.Errorf("%w, negative param, change: %+v", ErrInvalidParamChange, c)
+	}
+	if c == (ParamChange{}) {
+		return fmt.Errorf("%w, nothing changed", ErrInvalidParamChange)
+	}
+	return nil
+}
+
+// apply returns the params after the change, the change is refused if the tx limit exceeds the block limit.
+func (c ParamChange) apply(p ConsensusParams) (ConsensusParams, error) {
+	if c.RoundTimeoutMs > 0 {
+		p.RoundTimeout = time.Duration(c.RoundTimeoutMs) * time.Millisecond
+	}
+	if c.MaxBlockTxs > 0 {
+		p.MaxBlockTxs = c.MaxBlockTxs
+	}
+	if c.MaxBlockBytes > 0 {
+		p.MaxBlockBytes = c.MaxBlockBytes
+	}
+	if c.MaxTxBytes > 0 {
+		p.MaxTxBytes = c.MaxTxBytes
+	}
+	if c.EpochLength > 0 {
+		p.EpochLength = c.EpochLength
+	}
+	if c.MaxClockSkewMs > 0 {
+		p.MaxClockSkew = time.Duration(c.MaxClockSkewMs) * time.Millisecond
+	}
+	if p.MaxTxBytes > p.MaxBlockBytes {
+		return p, fmt.Errorf("%w, max tx bytes exceeds max block bytes, params: %+v", ErrInvalidParamChange, p)
+	}
+	return p, nil
+}
+
+// ParamsStep is the params in force from the round Start on.
+type ParamsStep struct {
+	Start  int64           `json:"start"`
+	Params ConsensusParams `json:"params"`
+}
+
+// paramsHistory keeps every step of the params, so that the blocks of any round are verified
+// by the params they were produced with.
+type paramsHistory struct {
+	sync.Mutex
+	steps []ParamsStep
+}
+
+func paramsFromConfig(cfg *ConsensusConfig) ConsensusParams {
+	return ConsensusParams{
+		RoundTimeout:  cfg.RoundTimeout,
+		MaxBlockTxs:   cfg.MaxBlockTxs,
+		MaxBlockBytes: cfg.MaxBlockBytes,
+		MaxTxBytes:    cfg.MaxTxBytes,
+		EpochLength:   cfg.EpochLength,
+		MaxClockSkew:  cfg.MaxClockSkew,
+	}
+}
+
+func newParamsHistory(cfg *ConsensusConfig) paramsHistory {
+	return paramsHistory{steps: []ParamsStep{{Start: cfg.StartRound, Params: paramsFromConfig(cfg)}}}
+}
+
+// ParamsAt returns the params in force at the round.
+func (s *State) ParamsAt(round int64) ConsensusParams {
+	s.params.Lock()
+	defer s.params.Unlock()
+
+	return s.paramsAt(round)
+}
+
+func (s *State) paramsAt(round int64) ConsensusParams {
+	for i := len(s.params.steps) - 1; i >= 0; i-- {
+		if round >= s.params.steps[i].Start {
+			return s.params.steps[i].Params
+		}
+	}
+	return paramsFromConfig(s.cfg)
+}
+
+// ParamsSteps returns the params steps which start in (from, to].
+func (s *State) ParamsSteps(from, to int64) []ParamsStep {
+	s.params.Lock()
+	defer s.params.Unlock()
+
+	var steps []ParamsStep
+	for _, step := range s.params.steps {
+		if step.Start > from && step.Start <= to {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// applyParamChanges schedules the changes committed at the height on top of the pending ones,
+// the invalid ones are ignored by every validator alike.
+func (s *State) applyParamChanges(height int64, txs [][]byte) {
+	s.params.Lock()
+	defer s.params.Unlock()
+
+	if len(s.params.steps) == 0 {
+		s.params.steps = newParamsHistory(s.cfg).steps
+	}
+	current := s.paramsAt(height)
+	last := &s.params.steps[len(s.params.steps)-1]
+	next, changed := last.Params, false
+	for _, tx := range txs {
+		c, ok, err := DecodeParamChange(tx)
+		if !ok {
+			continue
+		}
+		applied := next
+		if err == nil {
+			applied, err = c.apply(next)
+		}
+		if err != nil {
+			s.log.Warn("ignore param change @ state.applyParamChanges, height: %d, err: %v", height, err)
+			continue
+		}
+		next, changed = applied, true
+	}
+	if !changed {
+		return
+	}
+	start := (height/current.EpochLength + 2) * current.EpochLength
+	// the changes scheduled for the same boundary are merged
+	if last.Start == start {
+		last.Params = next
+	} else {
+		s.params.steps = append(s.params.steps, ParamsStep{Start: start, Params: next})
+	}
+	s.log.Info("param change scheduled @ state.applyParamChanges, height: %d, start: %d, params: %+v", height, start, next)
+}
@@ -0,0 +1,60 @@
+package state
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+)
+
+func TestParamChanges(t *testing.T) {
+	cfg := &ConsensusConfig{
+		StartRound:    0,
+		RoundTimeout:  TimeoutT,
+		MaxBlockTxs:   10,
+		MaxBlockBytes: 100,
+		MaxTxBytes:    10,
+		EpochLength:   10,
+	}
+	s := &State{cfg: cfg, params: newParamsHistory(cfg), log: logs.NewLogger()}
+
+	bigger, err := EncodeParamChange(ParamChange{MaxBlockBytes: 200, MaxTxBytes: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	longer, _ := EncodeParamChange(ParamChange{RoundTimeoutMs: 8000, EpochLength: 20})
+	// the tx limit exceeds the block limit, the change is ignored
+	invalid, _ := EncodeParamChange(ParamChange{MaxTxBytes: 1000})
+	if _, err := EncodeParamChange(ParamChange{}); !errors.Is(err, ErrInvalidParamChange) {
+		t.Errorf("want invalid err, has: %v", err)
+	}
+	if _, ok, err := DecodeParamChange([]byte("gohotstuff/params/v1:{")); !ok || err == nil {
+		t.Errorf("malformed change should be refused, ok: %v, err: %v", ok, err)
+	}
+
+	// committed at 5 and 13, both take effect at the second boundary after the commit
+	s.applyParamChanges(5, [][]byte{[]byte("tx"), bigger, invalid})
+	s.applyParamChanges(13, [][]byte{longer})
+	for round, want := range map[int64]ConsensusParams{
+		19: {RoundTimeout: TimeoutT, MaxBlockTxs: 10, MaxBlockBytes: 100, MaxTxBytes: 10, EpochLength: 10},
+		20: {RoundTimeout: TimeoutT, MaxBlockTxs: 10, MaxBlockBytes: 200, MaxTxBytes: 20, EpochLength: 10},
+		30: {RoundTimeout: 8 * time.Second, MaxBlockTxs: 10, MaxBlockBytes: 200, MaxTxBytes: 20, EpochLength: 20},
+	} {
+		if has := s.ParamsAt(round); has != want {
+			t.Errorf("round %d, want: %+v, has: %+v", round, want, has)
+		}
+	}
+	if steps := s.ParamsSteps(0, 100); len(steps) != 2 || steps[0].Start != 20 || steps[1].Start != 30 {
+		t.Errorf("unexpected steps: %+v", steps)
+	}
+
+	// the blocks are verified by the params of their rounds
+	tx := make([]byte, 15)
+	if err := s.checkPayload(19, [][]byte{tx}); !errors.Is(err, ErrProposalTooLarge) {
+		t.Errorf("want too large err, has: %v", err)
+	}
+	if err := s.checkPayload(20, [][]byte{tx}); err != nil {
+		t.Errorf("tx should fit the new params, err: %v", err)
+	}
+}
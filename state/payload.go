@@ -0,0 +1,53 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	DefaultMaxBlockBytes = 512 * 1024
+	DefaultMaxTxBytes    = 64 * 1024
+	// proposalEnvelopeBytes is the room for everything of a proposal msg but the txs,
+	// i.e. the justify qc with the signatures of the validators, the keys and the headers.
+	proposalEnvelopeBytes = 64 * 1024
+	// txFieldBytes is the max encoding overhead of a tx within the proposal msg.
+	txFieldBytes = 8
+)
+
+var (
+	ErrProposalTooLarge = errors.New("proposal exceeds the block limits")
+)
+
+// MaxProposalBytes is the max size of an encoded proposal msg under the limits of cfg,
+// the p2p frames of all the chains sharing a switch must be able to carry it.
+func MaxProposalBytes(cfg *ConsensusConfig) int {
+	maxBlockBytes, maxBlockTxs := cfg.MaxBlockBytes, cfg.MaxBlockTxs
+	if maxBlockBytes <= 0 {
+		maxBlockBytes = DefaultMaxBlockBytes
+	}
+	if maxBlockTxs <= 0 {
+		maxBlockTxs = DefaultMaxBlockTxs
+	}
+	return maxBlockBytes + maxBlockTxs*txFieldBytes + proposalEnvelopeBytes
+}
+
+// checkPayload rejects the txs which exceed the limits in force at the round, it depends on
+// the txs and the consensus parameters only, so that all the honest validators make the same decision.
+func (s *State) checkPayload(round int64, txs [][]byte) error {
+	p := s.ParamsAt(round)
+	if len(txs) > p.MaxBlockTxs {
+		return fmt.Errorf("%w, txs: %d, max: %d", ErrProposalTooLarge, len(txs), p.MaxBlockTxs)
+	}
+	size := 0
+	for i, tx := range txs {
+		if len(tx) > p.MaxTxBytes {
+			return fmt.Errorf("%w, tx: %d, tx_bytes: %d, max: %d", ErrProposalTooLarge, i, len(tx), p.MaxTxBytes)
+		}
+		size += len(tx)
+	}
+	if size > p.MaxBlockBytes {
+		return fmt.Errorf("%w, block_bytes: %d, max: %d", ErrProposalTooLarge, size, p.MaxBlockBytes)
+	}
+	return nil
+}
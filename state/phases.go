@@ -0,0 +1,136 @@
+package state
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/metrics"
+)
+
+// The phases of the progression of a block, each is timed from the end of the previous one:
+// the proposal is received, the host votes for it, the QC of it is formed or received, and it's committed.
+const (
+	PhaseVote   = "vote"   // proposal received -> vote sent
+	PhaseQC     = "qc"     // vote sent -> QC formed
+	PhaseCommit = "commit" // QC formed -> committed
+	PhaseTotal  = "total"  // proposal received -> committed
+)
+
+var phases = []string{PhaseVote, PhaseQC, PhaseCommit, PhaseTotal}
+
+// PhaseTiming is the breakdown of the latency of the latest blocks by the phase, see RoundState.
+type PhaseTiming struct {
+	Phase string        `json:"phase"`
+	Count uint64        `json:"count"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+}
+
+// roundTimes are the times the block of a round has entered the phases.
+type roundTimes struct {
+	proposal time.Time
+	vote     time.Time
+	qc       time.Time
+}
+
+// phaseTracker times the phases of the blocks until they're committed, the rounds which are never
+// committed, e.g. of the forks or the view changes, are pruned by the commits of the later ones.
+type phaseTracker struct {
+	mtx    sync.Mutex
+	rounds map[int64]*roundTimes
+}
+
+func newPhaseSummaries(r *metrics.Registry, chainID string) map[string]*metrics.Summary {
+	m := make(map[string]*metrics.Summary)
+	for _, phase := range phases {
+		m[phase] = r.NewSummary("gohotstuff_consensus_phase_seconds",
+			"Seconds the latest blocks spent in the phases from the proposal to the commit.", "chain", chainID, "phase", phase)
+	}
+	return m
+}
+
+func (t *phaseTracker) times(round int64) *roundTimes {
+	if t.rounds == nil {
+		t.rounds = make(map[int64]*roundTimes)
+	}
+	rt, ok := t.rounds[round]
+	if !ok {
+		rt = &roundTimes{}
+		t.rounds[round] = rt
+	}
+	return rt
+}
+
+// markProposal records the first receipt of the proposal of the round.
+func (s *State) markProposal(round int64) {
+	s.phases.mtx.Lock()
+	defer s.phases.mtx.Unlock()
+	if rt := s.phases.times(round); rt.proposal.IsZero() {
+		rt.proposal = s.clock.Now()
+	}
+}
+
+// markVote records the vote of the host for the block of the round.
+func (s *State) markVote(round int64) {
+	s.phases.mtx.Lock()
+	defer s.phases.mtx.Unlock()
+	if rt := s.phases.times(round); rt.vote.IsZero() {
+		rt.vote = s.clock.Now()
+	}
+}
+
+// markQC records the QC of the block of the round, formed by the host as the leader or carried by a proposal.
+func (s *State) markQC(round int64) {
+	s.phases.mtx.Lock()
+	defer s.phases.mtx.Unlock()
+	if rt := s.phases.times(round); rt.qc.IsZero() {
+		rt.qc = s.clock.Now()
+	}
+}
+
+// markCommit observes the phases of the committed block of the round, the phases whose start or end
+// the host has missed, e.g. it hasn't voted, are skipped.
+func (s *State) markCommit(round int64) {
+	now := s.clock.Now()
+	s.phases.mtx.Lock()
+	rt := s.phases.rounds[round]
+	for r := range s.phases.rounds {
+		if r <= round {
+			delete(s.phases.rounds, r)
+		}
+	}
+	s.phases.mtx.Unlock()
+	if rt == nil {
+		return
+	}
+
+	observe := func(phase string, from, to time.Time) {
+		if from.IsZero() || to.IsZero() || to.Before(from) {
+			return
+		}
+		s.metrics.phases[phase].Observe(to.Sub(from).Seconds())
+	}
+	observe(PhaseVote, rt.proposal, rt.vote)
+	observe(PhaseQC, rt.vote, rt.qc)
+	observe(PhaseCommit, rt.qc, now)
+	observe(PhaseTotal, rt.proposal, now)
+}
+
+// phaseTimings returns the percentiles of the phases of the latest committed blocks.
+func (s *State) phaseTimings() []PhaseTiming {
+	if s.metrics == nil {
+		return nil
+	}
+	var timings []PhaseTiming
+	for _, phase := range phases {
+		sum := s.metrics.phases[phase]
+		if sum.Count() == 0 {
+			continue
+		}
+		seconds := func(q float64) time.Duration { return time.Duration(sum.Quantile(q) * float64(time.Second)) }
+		timings = append(timings, PhaseTiming{Phase: phase, Count: sum.Count(), P50: seconds(0.5), P90: seconds(0.9),
+			P99: seconds(0.99)})
+	}
+	return timings
+}
@@ -0,0 +1,54 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/metrics"
+)
+
+func TestPhaseTimings(t *testing.T) {
+	clock := libs.NewManualClock(time.Unix(100, 0))
+	s := &State{
+		metrics: newConsensusMetrics(metrics.NewRegistry(), "test"),
+		clock:   clock,
+		log:     logs.NewLogger(),
+	}
+	if timings := s.phaseTimings(); len(timings) != 0 {
+		t.Fatalf("want no timing before a commit, has: %+v", timings)
+	}
+
+	s.markProposal(1)
+	clock.Advance(100 * time.Millisecond)
+	s.markVote(1)
+	clock.Advance(200 * time.Millisecond)
+	s.markQC(1)
+	// the host hasn't voted for the block of round 2, its vote and qc phases are skipped
+	s.markProposal(2)
+	s.markQC(2)
+	clock.Advance(300 * time.Millisecond)
+	s.markCommit(1)
+	s.markCommit(2)
+	if len(s.phases.rounds) != 0 {
+		t.Errorf("want the committed rounds pruned, has: %d", len(s.phases.rounds))
+	}
+
+	ms := time.Millisecond
+	want := map[string]PhaseTiming{
+		PhaseVote:   {Phase: PhaseVote, Count: 1, P50: 100 * ms, P90: 100 * ms, P99: 100 * ms},
+		PhaseQC:     {Phase: PhaseQC, Count: 1, P50: 200 * ms, P90: 200 * ms, P99: 200 * ms},
+		PhaseCommit: {Phase: PhaseCommit, Count: 2, P50: 300 * ms, P90: 300 * ms, P99: 300 * ms},
+		PhaseTotal:  {Phase: PhaseTotal, Count: 2, P50: 300 * ms, P90: 600 * ms, P99: 600 * ms},
+	}
+	timings := s.phaseTimings()
+	if len(timings) != len(want) {
+		t.Fatalf("want %d phases, has: %+v", len(want), timings)
+	}
+	for _, pt := range timings {
+		if pt != want[pt.Phase] {
+			t.Errorf("want: %+v, has: %+v", want[pt.Phase], pt)
+		}
+	}
+}
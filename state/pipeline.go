@@ -0,0 +1,142 @@
+package state
+
+import (
+	"sync"
+)
+
+// DefaultExecDepth is the number of committed blocks which may wait for the executor
+// before the host stops voting.
+const DefaultExecDepth = 4
+
+// execPipeline decouples the executor from the consensus, the committed blocks are queued
+// by the receiveRoutine and applied in the order of the commits by the execRoutine, while
+// the consensus goes on with the next rounds. The application state, i.e. the height served
+// by Query, only advances once a block is executed.
+type execPipeline struct {
+	sync.Mutex
+	queue []execJob
+	// the latest height applied to the application state
+	executed int64
+	wake     chan struct{}
+	// the state hashes of the latest executed heights from hashFrom on, see AppHasher
+	hashes   map[int64][]byte
+	hashFrom int64
+}
+
+// execJob is a committed block with the state hash claimed by its proposer.
+type execJob struct {
+	height    int64
+	txs       [][]byte
+	appHeight int64
+	appHash   []byte
+	proposer  string
+	// the vote extensions carried by the block
+	extensions []VoteExtension
+}
+
+func newExecPipeline(executed int64) execPipeline {
+	return execPipeline{executed: executed, wake: make(chan struct{}, 1)}
+}
+
+// ExecutedHeight returns the latest height applied by the executor, which lags behind
+// the committed round while the blocks are in the pipeline.
+func (s *State) ExecutedHeight() int64 {
+	s.pipeline.Lock()
+	defer s.pipeline.Unlock()
+
+	return s.pipeline.executed
+}
+
+// enqueueExec hands the committed block over to the execRoutine, it never blocks the consensus,
+// see execBacklogged for the backpressure.
+func (s *State) enqueueExec(job execJob) {
+	if s.executor == nil {
+		return
+	}
+	s.pipeline.Lock()
+	s.pipeline.queue = append(s.pipeline.queue, job)
+	pending := len(s.pipeline.queue)
+	s.pipeline.Unlock()
+
+	s.metrics.execPending.Set(float64(pending))
+	select {
+	case s.pipeline.wake <- struct{}{}:
+	default:
+	}
+}
+
+// execBacklogged reports whether the executor has fallen behind by ExecDepth blocks, the host
+// doesn't vote until it catches up, the consensus is slowed down once 1/3 of the validators lag.
+func (s *State) execBacklogged() bool {
+	s.pipeline.Lock()
+	defer s.pipeline.Unlock()
+
+	return s.cfg.ExecDepth > 0 && len(s.pipeline.queue) >= s.cfg.ExecDepth
+}
+
+// execRoutine applies the queued blocks one by one, the blocks left at the quit are applied before it returns.
+func (s *State) execRoutine() {
+	for {
+		s.pipeline.Lock()
+		if len(s.pipeline.queue) == 0 {
+			s.pipeline.Unlock()
+			select {
+			case <-s.pipeline.wake:
+				continue
+			case <-s.quit:
+				return
+			}
+		}
+		job := s.pipeline.queue[0]
+		s.pipeline.Unlock()
+
+		// the state the block was proposed on is checked before applying the block,
+		// the application state is left at the divergence
+		if report, ok := s.checkAppHash(job); !ok {
+			s.haltForDivergence(report)
+			return
+		}
+		if !s.execute(job.height, job.txs, job.extensions) {
+			return
+		}
+		s.judgeEjections(job.height)
+
+		s.pipeline.Lock()
+		s.pipeline.queue = s.pipeline.queue[1:]
+		pending := len(s.pipeline.queue)
+		s.pipeline.Unlock()
+		s.metrics.execPending.Set(float64(pending))
+	}
+}
+
+// advanceExecuted moves the application state to the executed height, and records its hash.
+func (s *State) advanceExecuted(height int64) {
+	var hash []byte
+	if hasher, ok := s.executor.(AppHasher); ok {
+		hash = hasher.AppHash()
+	}
+
+	s.pipeline.Lock()
+	defer s.pipeline.Unlock()
+
+	if height <= s.pipeline.executed {
+		return
+	}
+	s.pipeline.executed = height
+	if hash == nil {
+		return
+	}
+	if s.pipeline.hashes == nil {
+		s.pipeline.hashes = make(map[int64][]byte)
+		s.pipeline.hashFrom = height
+	}
+	s.pipeline.hashes[height] = hash
+	if from := height - appHashHistory + 1; from > s.pipeline.hashFrom {
+		for h := range s.pipeline.hashes {
+			if h < from {
+				delete(s.pipeline.hashes, h)
+			}
+		}
+		s.pipeline.hashFrom = from
+	}
+}
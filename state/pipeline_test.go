@@ -0,0 +1,105 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/metrics"
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+// gatedExecutor applies a block once it's released.
+type gatedExecutor struct {
+	heightExecutor
+	release chan struct{}
+}
+
+func (e *gatedExecutor) Execute(height int64, txs [][]byte) ([]types.TxResult, error) {
+	<-e.release
+	return e.heightExecutor.Execute(height, txs)
+}
+
+func TestExecPipeline(t *testing.T) {
+	e := &gatedExecutor{release: make(chan struct{})}
+	s := &State{
+		cfg:      &ConsensusConfig{ExecDepth: 2},
+		pipeline: newExecPipeline(0),
+		metrics:  newConsensusMetrics(metrics.NewRegistry(), "test"),
+		quit:     make(chan struct{}),
+		log:      logs.NewLogger(),
+	}
+	if err := s.SetExecutor(e); err != nil {
+		t.Fatal(err)
+	}
+	go s.execRoutine()
+	defer close(s.quit)
+
+	s.enqueueExec(execJob{height: 1})
+	s.enqueueExec(execJob{height: 3})
+	if !s.execBacklogged() {
+		t.Errorf("want backlogged")
+	}
+	// the application state doesn't advance before the block is executed
+	if s.ExecutedHeight() != 0 {
+		t.Errorf("unexpected executed height: %d", s.ExecutedHeight())
+	}
+	if _, err := s.Query("/key", nil, 1); err == nil {
+		t.Errorf("unexecuted height should not be served")
+	}
+
+	e.release <- struct{}{}
+	e.release <- struct{}{}
+	for deadline := time.Now().Add(5 * time.Second); s.ExecutedHeight() != 3 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s.ExecutedHeight() != 3 {
+		t.Fatalf("want executed height 3, has: %d", s.ExecutedHeight())
+	}
+	for deadline := time.Now().Add(5 * time.Second); s.execBacklogged() && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s.execBacklogged() {
+		t.Errorf("caught up pipeline is backlogged")
+	}
+	if len(e.executed) != 2 || e.executed[0] != 1 || e.executed[1] != 3 {
+		t.Errorf("unexpected executed heights: %v", e.executed)
+	}
+}
+
+func TestWaitForExec(t *testing.T) {
+	e := &gatedExecutor{release: make(chan struct{})}
+	s := &State{
+		cfg:      &ConsensusConfig{},
+		pipeline: newExecPipeline(0),
+		metrics:  newConsensusMetrics(metrics.NewRegistry(), "test"),
+		quit:     make(chan struct{}),
+		log:      logs.NewLogger(),
+	}
+	if err := s.SetExecutor(e); err != nil {
+		t.Fatal(err)
+	}
+	s.routines.Spawn("exec", s.execRoutine)
+	s.enqueueExec(execJob{height: 1})
+	close(s.quit)
+
+	waited := make(chan struct{})
+	go func() {
+		s.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		t.Fatal("wait should block until the queued block is executed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	e.release <- struct{}{}
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait should return once the routines exit")
+	}
+	if s.ExecutedHeight() != 1 {
+		t.Errorf("the queued block should be executed before the exit, executed: %d", s.ExecutedHeight())
+	}
+}
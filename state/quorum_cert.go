@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/types"
 )
 
 type QuorumCert interface {
@@ -12,17 +13,21 @@ type QuorumCert interface {
 	ParentProposal() (round int64, id []byte, err error)
 	Sender() (senderID string)
 	Signatures(peerID string) (signType int, sign []byte, pk []byte, err error)
+	// SignedMsg returns the signed vote or timeout of the peer certifying the QC, it can be verified on its own.
+	SignedMsg(peerID string) ([]byte, error)
+	// Certify returns the QC carrying the signs, indexed by the signers, in place of its own ones.
+	Certify(signs map[string]DefaultSign) (QuorumCert, error)
 	Serialize() ([]byte, error)
 	String() string
 }
 
 // DefaultQuorumCert is the canonical implementation of the QuorumCert interface
 type DefaultQuorumCert struct {
-	Round       int64  `json:"round"`
-	ID          []byte `json:"id"`
-	ParentRound int64  `json:"parent_round"`
-	ParentID    []byte `json:"parent_id"`
-	SenderID    string `json:"sender"`
+	Round       int64         `json:"round"`
+	ID          types.BlockID `json:"id"`
+	ParentRound int64         `json:"parent_round"`
+	ParentID    types.BlockID `json:"parent_id"`
+	SenderID    string        `json:"sender"`
 
 	Signs map[string]DefaultSign `json:"signs"`
 	// lastCommitID []byte
@@ -59,7 +64,7 @@ func (qc DefaultQuorumCert) Proposal() (int64, []byte, error) {
 }
 
 func (qc DefaultQuorumCert) ParentProposal() (int64, []byte, error) {
-	return qc.Round, qc.ParentID, nil
+	return qc.ParentRound, qc.ParentID, nil
 }
 func (qc DefaultQuorumCert) Sender() string {
 	return qc.SenderID
@@ -73,6 +78,22 @@ func (qc DefaultQuorumCert) Signatures(peerID string) (int, []byte, []byte, erro
 	return sign.Type, sign.Sign, sign.PublicKey, nil
 }
 
+func (qc DefaultQuorumCert) SignedMsg(peerID string) ([]byte, error) {
+	sign, ok := qc.Signs[peerID]
+	if !ok || len(sign.Msg) == 0 {
+		return nil, libs.ErrValNotFound
+	}
+	return sign.Msg, nil
+}
+
+func (qc DefaultQuorumCert) Certify(signs map[string]DefaultSign) (QuorumCert, error) {
+	qc.Signs = make(map[string]DefaultSign, len(signs))
+	for peerID, sign := range signs {
+		qc.Signs[peerID] = sign
+	}
+	return qc, nil
+}
+
 func (qc DefaultQuorumCert) Serialize() ([]byte, error) {
 	return json.Marshal(qc)
 }
@@ -83,7 +104,7 @@ func (qc DefaultQuorumCert) String() string {
 		signs += fmt.Sprintf("[peer_id: %s, sign: %s] ", peerID, string(s.Sign))
 	}
 	basic := fmt.Sprintf("round: %d, id: %s, parent_round: %d, parent_id: %s, from: %s, signs: [%s]", qc.Round,
-		libs.F(qc.ID), qc.ParentRound, libs.F(qc.ParentID), qc.SenderID, signs)
+		qc.ID, qc.ParentRound, qc.ParentID, qc.SenderID, signs)
 
 	return basic
 }
@@ -93,4 +114,6 @@ type DefaultSign struct {
 	PublicKey []byte
 	Sign      []byte
 	Type      int
+	// Msg is the signed vote or timeout msg, which the Sign is over.
+	Msg []byte `json:",omitempty"`
 }
@@ -0,0 +1,32 @@
+package state
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestQuorumCertParentProposal(t *testing.T) {
+	// the parent of the proposal after a view change isn't the previous round
+	qc, err := NewDefaultQuorumCert("leader", nil, 5, []byte("b5"), 3, []byte("b3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := qc.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DefaultDeserialize(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, qc := range map[string]QuorumCert{"built": qc, "deserialized": decoded} {
+		round, id, err := qc.Proposal()
+		if err != nil || round != 5 || !bytes.Equal(id, []byte("b5")) {
+			t.Errorf("%s: want the proposal 5/b5, has: %d/%s, err: %v", name, round, id, err)
+		}
+		parentRound, parentID, err := qc.ParentProposal()
+		if err != nil || parentRound != 3 || !bytes.Equal(parentID, []byte("b3")) {
+			t.Errorf("%s: want the parent 3/b3, has: %d/%s, err: %v", name, parentRound, parentID, err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package state
+
+import (
+	"errors"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/storage"
+)
+
+// scrubRoutine re-reads a stored block every ScrubInterval, the heights are scrubbed in passes from the lowest
+// to the committed one, so that the rotten blocks are found before a peer or an auditor asks for them.
+func (s *State) scrubRoutine() {
+	ticker := s.clock.NewTicker(s.cfg.ScrubInterval)
+	defer ticker.Stop()
+
+	var pass []int64
+	for {
+		select {
+		case <-ticker.C():
+			if len(pass) == 0 {
+				s.mtx.RLock()
+				committed := s.committedRound
+				s.mtx.RUnlock()
+				heights, err := s.blocks.Heights(0, committed)
+				if err != nil {
+					s.log.Warn("list blocks fail @ state.scrubRoutine, err: %v", err)
+					continue
+				}
+				if pass = heights; len(pass) == 0 {
+					continue
+				}
+			}
+			s.scrubBlock(pass[0])
+			pass = pass[1:]
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// scrubBlock reports the stored block of the height if it fails its checks, see Auditor.ScrubBlock,
+// the hooks registered by OnCorruptBlock are left to repair it.
+func (s *State) scrubBlock(height int64) bool {
+	err := s.auditor().ScrubBlock(height)
+	if err == nil || errors.Is(err, storage.ErrBlockNotFound) {
+		return true
+	}
+	s.log.Error("corrupted block @ state.scrubBlock, height: %d, err: %v", height, err)
+	s.metrics.corruptBlocks.Inc()
+	s.publish(libs.Event{
+		Type:   libs.EventBlockCorrupted,
+		Module: libs.ConsensusModule,
+		Data:   libs.StorageEventData{ChainID: s.cfg.ChainID, Height: height, Reason: err.Error()},
+	})
+	s.runCorruptHooks(height)
+	return false
+}
+
+// ScrubBlocks checks the stored blocks in [from, to] at once, to <= 0 means the latest one,
+// and returns the heights of the corrupted ones, which are reported and repaired like the scrubber does.
+func (s *State) ScrubBlocks(from, to int64) ([]int64, error) {
+	if s.blocks == nil {
+		return nil, ErrNoBlockStore
+	}
+	heights, err := s.blocks.Heights(from, to)
+	if err != nil {
+		return nil, err
+	}
+	corrupted := []int64{}
+	for _, h := range heights {
+		if !s.scrubBlock(h) {
+			corrupted = append(corrupted, h)
+		}
+	}
+	return corrupted, nil
+}
+
+// repairBlock replaces the corrupted block of the height by the one fetched from the peer,
+// which must pass the checks of the scrubber against the stored parent and the stored child,
+// or the verification of its QC if no stored child links to it.
+func (s *State) repairBlock(pid string, block *storage.CommittedBlock) error {
+	a := s.auditor()
+	parent, err := s.blocks.Load(block.ParentRound)
+	if err != nil {
+		parent = nil
+	}
+	if err := verifyCommittedBlock(a.deserialize(), parent, block); err != nil {
+		return err
+	}
+	anchored := false
+	if heights, err := s.blocks.Heights(block.Height+1, 0); err == nil && len(heights) > 0 {
+		if child, err := s.blocks.Load(heights[0]); err == nil && child.ParentRound == block.Height {
+			if err := verifyCommittedBlock(a.deserialize(), block, child); err != nil {
+				return err
+			}
+			anchored = true
+		}
+	}
+	// the hash the stored child links to pins the block, the latest one is only pinned by its QC
+	if !anchored {
+		if _, err := a.verify(parent, block); err != nil {
+			return err
+		}
+	}
+	if err := s.blocks.Save(block); err != nil {
+		return err
+	}
+	s.log.Warn("corrupted block repaired @ state.repairBlock, height: %d, peer: %s", block.Height, pid)
+	s.metrics.repairedBlocks.Inc()
+	s.publish(libs.Event{
+		Type:   libs.EventBlockRepaired,
+		Module: libs.ConsensusModule,
+		Data:   libs.StorageEventData{ChainID: s.cfg.ChainID, Height: block.Height, Peer: pid},
+	})
+	return nil
+}
@@ -0,0 +1,143 @@
+package state
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/crypto"
+	"github.com/aucusaga/gohotstuff/metrics"
+	"github.com/aucusaga/gohotstuff/storage"
+)
+
+// signedByA signs the blocks of the rounds up to 8 by the validator "a".
+var signedByA = map[int64][]string{1: {"a"}, 2: {"a"}, 3: {"a"}, 4: {"a"}, 5: {"a"}, 6: {"a"}, 7: {"a"}, 8: {"a"}}
+
+// newSignedChain commits the blocks of the rounds on the chain "test", the QC of each carries the votes
+// signed by the signers of its round in the epoch of the validators elected from the round 1.
+func newSignedChain(t *testing.T, signers map[int64][]string, rounds ...int64) *storage.BlockStore {
+	blocks, err := storage.NewBlockStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make(map[string]*crypto.DefaultCryptoClient)
+	parentRound, parentID := int64(0), []byte("genesis")
+	for _, r := range rounds {
+		id := []byte{byte(r)}
+		qc := DefaultQuorumCert{Round: r, ID: id, ParentRound: parentRound, ParentID: parentID, SenderID: "a",
+			Signs: make(map[string]DefaultSign)}
+		for _, v := range signers[r] {
+			if keys[v] == nil {
+				sk, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+				keys[v] = &crypto.DefaultCryptoClient{SK: sk, PK: &sk.PublicKey}
+			}
+			vote := VoteMsg(r, id, parentRound, parentID, "a")
+			vote.SendID = v
+			msgbytes, _ := ForkProtoFromConsMsg(vote, "test", "", 1)
+			signed, err := keys[v].Sign(msgbytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			qc.Signs[v] = DefaultSign{PeerID: v, PublicKey: keys[v].PublicKey(), Msg: signed}
+		}
+		raw, _ := qc.Serialize()
+		b := &storage.CommittedBlock{Height: r, ID: id, ParentRound: parentRound, ParentID: parentID, Proposer: "a",
+			Txs: [][]byte{[]byte("tx")}, QC: raw}
+		if err := blocks.Save(b); err != nil {
+			t.Fatal(err)
+		}
+		parentRound, parentID = r, id
+	}
+	return blocks
+}
+
+// copyChain copies the blocks into a store under dir, so that its files can be corrupted.
+func copyChain(t *testing.T, src *storage.BlockStore, dir string) *storage.BlockStore {
+	dst, err := storage.NewBlockStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	heights, _ := src.Heights(0, 0)
+	for _, h := range heights {
+		b, err := src.Load(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst.Save(b)
+	}
+	return dst
+}
+
+// rot flips the proposer of the stored block of the height without updating its checksum.
+func rot(t *testing.T, dir string, height int64) {
+	path := filepath.Join(dir, fmt.Sprintf("%d.json", height))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(path, bytes.Replace(data, []byte(`"proposer":"a"`), []byte(`"proposer":"b"`), 1), 0644)
+}
+
+func TestScrubBlocks(t *testing.T) {
+	signers := map[int64][]string{2: {"a"}, 3: {"a"}}
+	served := newSignedChain(t, signers, 1, 2, 3)
+	dir := t.TempDir()
+	observed := copyChain(t, served, dir)
+	rot(t, dir, 2)
+
+	a := &Auditor{Blocks: observed, Election: NewDefaultElection(1, []PeerID{"a"}), StartRound: 1, ChainID: "test"}
+	var corrupted []int64
+	n, err := a.Scrub(0, 0, func(height int64, err error) {
+		if !errors.Is(err, storage.ErrBlockCorrupted) {
+			t.Errorf("want: %v, has: %v", storage.ErrBlockCorrupted, err)
+		}
+		corrupted = append(corrupted, height)
+	})
+	// the child of the corrupted block is checked on its own
+	if n != 3 || err != nil || len(corrupted) != 1 || corrupted[0] != 2 {
+		t.Fatalf("want the corrupted block alone, checked: %d, corrupted: %v, err: %v", n, corrupted, err)
+	}
+
+	newScrubState := func(host string, blocks *storage.BlockStore) *State {
+		s := newObserverState(host, blocks)
+		s.election = NewDefaultElection(1, []PeerID{"a"})
+		s.cfg.StartRound = 1
+		s.metrics = newConsensusMetrics(metrics.NewRegistry(), "test")
+		return s
+	}
+	validator, observer := newScrubState("v", served), newScrubState("o", observed)
+	defer close(validator.quit)
+	defer close(observer.quit)
+	rv, ro := NewObserverReactor(validator, 1000), NewObserverReactor(observer, 1000)
+	pipe(rv, ro)
+
+	// the corrupted block is fetched from the peer by the hook of the observer
+	if heights, err := observer.ScrubBlocks(0, 0); err != nil || len(heights) != 1 || heights[0] != 2 {
+		t.Fatalf("want the corrupted block found, has: %v, err: %v", heights, err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if err := observer.auditor().ScrubBlock(2); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the corrupted block should be repaired")
+		}
+	}
+	if heights, _ := observer.ScrubBlocks(0, 0); len(heights) != 0 {
+		t.Errorf("want no corrupted block left, has: %v", heights)
+	}
+
+	// a block which doesn't chain to the stored ones is refused
+	bogus, _ := served.Load(3)
+	bogus.Height, bogus.ID = 2, []byte{9}
+	if err := observer.repairBlock("v", bogus); err == nil {
+		t.Error("want the bogus block refused")
+	}
+}
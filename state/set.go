@@ -1,15 +1,32 @@
 package state
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"sync"
 
 	"github.com/aucusaga/gohotstuff/libs"
 )
 
+const (
+	// maxPendingVoteRounds bounds the memory of the vote set,
+	// the lowest rounds are evicted when it's exceeded.
+	maxPendingVoteRounds = 100
+)
+
+var (
+	ErrDuplicateVote   = errors.New("vote has been collected before")
+	ErrConflictingVote = errors.New("sender has voted for another proposal in the same round")
+)
+
 type VoteSet struct {
 	latestRound   int64                             // for vote set
 	latestID      []byte                            // for vote set
+	prunedRound   int64                             // rounds lower than it have been garbage-collected
 	roundVoteSets map[int64]map[string]RoundVoteSet // map[round][proposal_id]RoundVoteSet for vote set
+	roundVoters   map[int64]map[PeerID]string       // map[round][sender]proposal_id for conflict detection
+	wal           WAL
 	mtx           sync.Mutex
 }
 
@@ -20,21 +37,65 @@ type RoundVoteSet struct {
 	validators map[PeerID]struct{}
 }
 
-// TODO: load from wal
 func NewVoteSet(rootRound int64) *VoteSet {
 	rootRoundMap := make(map[string]RoundVoteSet)
 	set := &VoteSet{
 		latestRound:   rootRound,
+		prunedRound:   rootRound,
 		roundVoteSets: make(map[int64]map[string]RoundVoteSet),
+		roundVoters:   make(map[int64]map[PeerID]string),
 	}
 	set.roundVoteSets[rootRound] = rootRoundMap
 	return set
 }
 
+// SetWAL makes every accepted vote be persisted before it's counted.
+func (s *VoteSet) SetWAL(wal WAL) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.wal = wal
+}
+
+// Replay rebuilds the set from the records of the wal,
+// it should be invoked before SetWAL so that votes won't be written twice.
+func (s *VoteSet) Replay(rd io.Reader) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	dec := NewWALDecoder(rd)
+	for {
+		msg, err := dec.Decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch m := msg.Msg.(type) {
+		case VoteWALMessage:
+			// validators are refilled by the following AddVote
+			if err := s.addVoteWithoutLock(m.Round, m.ID, PeerID(m.Sender), nil); err != nil &&
+				err != ErrDuplicateVote && err != ErrConflictingVote {
+				return err
+			}
+		case EndHeightMessage:
+			s.pruneWithoutLock(m.Height)
+		}
+	}
+}
+
 func (s *VoteSet) AddVote(round int64, id []byte, sender PeerID, validators []PeerID) error {
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
 
+	return s.addVoteWithoutLock(round, id, sender, validators)
+}
+
+func (s *VoteSet) addVoteWithoutLock(round int64, id []byte, sender PeerID, validators []PeerID) error {
+	if round < s.prunedRound {
+		return fmt.Errorf("stale vote, round: %d, pruned_round: %d", round, s.prunedRound)
+	}
 	if _, ok := s.roundVoteSets[round]; !ok {
 		roundMap := make(map[string]RoundVoteSet)
 		s.roundVoteSets[round] = roundMap
@@ -54,17 +115,42 @@ func (s *VoteSet) AddVote(round int64, id []byte, sender PeerID, validators []Pe
 
 	// has inserted before
 	if _, ok := s.roundVoteSets[round][libs.F(id)].count[sender]; ok {
-		return nil
+		return ErrDuplicateVote
+	}
+	if _, ok := s.roundVoters[round]; !ok {
+		s.roundVoters[round] = make(map[PeerID]string)
+	}
+	if voted, ok := s.roundVoters[round][sender]; ok && voted != libs.F(id) {
+		return ErrConflictingVote
+	}
+	if s.wal != nil {
+		if err := s.wal.Write(VoteWALMessage{Round: round, ID: id, Sender: string(sender)}); err != nil {
+			return fmt.Errorf("%w, round: %d, err: %v", ErrWALWrite, round, err)
+		}
 	}
+	s.roundVoters[round][sender] = libs.F(id)
 	s.roundVoteSets[round][libs.F(id)].count[sender] = struct{}{}
 	if s.latestRound <= round {
 		s.latestRound = round
 		s.latestID = id
 	}
+	s.evictWithoutLock()
 	return nil
 }
 
+// Voted returns the proposal which the sender has voted for in the round.
+func (s *VoteSet) Voted(round int64, sender PeerID) (string, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	id, ok := s.roundVoters[round][sender]
+	return id, ok
+}
+
 func (s *VoteSet) HasTwoThirdsAny(round int64, id []byte) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
 	set := s.roundVoteSets[round][libs.F(id)]
 	lens := 0
 	for peer := range set.count {
@@ -79,12 +165,84 @@ func (s *VoteSet) HasTwoThirdsAny(round int64, id []byte) bool {
 	return threshold
 }
 
+// Count returns the number of the collected votes of the validators for the proposal and the number of the validators.
+func (s *VoteSet) Count(round int64, id []byte) (votes int, validators int) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	set := s.roundVoteSets[round][libs.F(id)]
+	for peer := range set.count {
+		if _, ok := set.validators[peer]; ok {
+			votes++
+		}
+	}
+	return votes, len(set.validators)
+}
+
+// PendingVotes returns the senders of the collected votes, map[round][proposal_id][]sender.
+func (s *VoteSet) PendingVotes() map[int64]map[string][]PeerID {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	votes := make(map[int64]map[string][]PeerID)
+	for round, sets := range s.roundVoteSets {
+		votes[round] = make(map[string][]PeerID)
+		for id, set := range sets {
+			for peer := range set.count {
+				votes[round][id] = append(votes[round][id], peer)
+			}
+		}
+	}
+	return votes
+}
+
+// Prune garbage-collects votes of the rounds lower than the committed one.
+func (s *VoteSet) Prune(committedRound int64) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.pruneWithoutLock(committedRound)
+}
+
+func (s *VoteSet) pruneWithoutLock(committedRound int64) {
+	if committedRound <= s.prunedRound {
+		return
+	}
+	for pround := range s.roundVoteSets {
+		if pround < committedRound {
+			delete(s.roundVoteSets, pround)
+		}
+	}
+	for pround := range s.roundVoters {
+		if pround < committedRound {
+			delete(s.roundVoters, pround)
+		}
+	}
+	s.prunedRound = committedRound
+}
+
+// evictWithoutLock drops the lowest rounds when the set holds too many rounds,
+// which happens when nothing has been committed for a long time.
+func (s *VoteSet) evictWithoutLock() {
+	for len(s.roundVoteSets) > maxPendingVoteRounds {
+		lowest := s.latestRound
+		for pround := range s.roundVoteSets {
+			if pround < lowest {
+				lowest = pround
+			}
+		}
+		delete(s.roundVoteSets, lowest)
+		delete(s.roundVoters, lowest)
+	}
+}
+
 // Reset clean up the set storage.
 // Now round is the highQC.
 func (s *VoteSet) reset(round int64, id []byte) error {
 	for pround := range s.roundVoteSets {
 		if pround <= round-libs.HotstuffChaindStep {
 			delete(s.roundVoteSets, pround)
+			delete(s.roundVoters, pround)
 		}
 	}
 	return nil
@@ -0,0 +1,225 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/storage"
+)
+
+const (
+	DefaultSinkTimeout = 5 * time.Second
+	// the redelivery backoff of a failed block doubles up to sinkMaxBackoff
+	sinkMinBackoff = 100 * time.Millisecond
+	sinkMaxBackoff = 30 * time.Second
+)
+
+var (
+	ErrNoOutbox     = errors.New("outbox path is not set")
+	ErrSinksStarted = errors.New("commit sinks can only be added before start")
+)
+
+// CommitSink is an external system receiving every committed block, e.g. a webhook or a kafka producer.
+// The blocks are delivered at least once in the order of the heights, a block is redelivered until
+// Deliver returns nil, even across restarts, so Deliver should be idempotent on the height.
+// WebhookSink is the only sink built in, the ones of the brokers such as Kafka or NATS are left to
+// the applications, which implement CommitSink with the clients of their choice, so that gohotstuff
+// doesn't depend on any broker client.
+type CommitSink interface {
+	// Name identifies the outbox of the sink, it must be stable across restarts.
+	Name() string
+	Deliver(ctx context.Context, block *storage.CommittedBlock) error
+}
+
+// sinkOutbox keeps the blocks of a sink until they're delivered, see storage.Outbox.
+type sinkOutbox interface {
+	Put(seq int64, data []byte) error
+	Get(seq int64) ([]byte, error)
+	Pending() ([]int64, error)
+	Ack(seq int64) error
+}
+
+type sinkWorker struct {
+	sink   CommitSink
+	outbox sinkOutbox
+	notify chan struct{}
+}
+
+type commitSinks struct {
+	sync.Mutex
+	workers []*sinkWorker
+	started bool
+}
+
+// AddCommitSink registers the sink, the blocks are kept in the outbox under cfg.OutboxPath
+// until they are delivered. It should be invoked before state.Start().
+func (s *State) AddCommitSink(sink CommitSink) error {
+	if s.cfg.OutboxPath == "" {
+		return ErrNoOutbox
+	}
+	s.sinks.Lock()
+	defer s.sinks.Unlock()
+
+	if s.sinks.started {
+		return ErrSinksStarted
+	}
+	outbox, err := storage.NewOutbox(filepath.Join(s.cfg.OutboxPath, sink.Name()))
+	if err != nil {
+		return err
+	}
+	s.sinks.workers = append(s.sinks.workers, &sinkWorker{sink: sink, outbox: outbox, notify: make(chan struct{}, 1)})
+	return nil
+}
+
+// startSinks delivers the blocks left in the outboxes by the last run and the new ones.
+func (s *State) startSinks() {
+	s.sinks.Lock()
+	defer s.sinks.Unlock()
+
+	s.sinks.started = true
+	for _, w := range s.sinks.workers {
+		w := w
+		s.routines.Spawn("sink/"+w.sink.Name(), func() { s.deliverRoutine(w) })
+	}
+}
+
+// enqueueCommit puts the committed block into every outbox before it's delivered, it's invoked with
+// the procedure mutex held. A failed put is a storage failure, a disk failure suspends the voting until
+// the put passes, so that no committed block skips the outbox.
+func (s *State) enqueueCommit(block *storage.CommittedBlock) {
+	s.sinks.Lock()
+	workers := s.sinks.workers
+	s.sinks.Unlock()
+	if len(workers) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(block)
+	if err != nil {
+		s.log.Error("marshal committed block fail @ state.enqueueCommit, height: %d, err: %v", block.Height, err)
+		return
+	}
+	for _, w := range workers {
+		w := w
+		put := func() error {
+			if err := w.outbox.Put(block.Height, data); err != nil {
+				return err
+			}
+			select {
+			case w.notify <- struct{}{}:
+			default:
+			}
+			return nil
+		}
+		if err := s.handleFailure(FailureStorage, true, put(), put); err != nil {
+			s.log.Error("put outbox fail @ state.enqueueCommit, sink: %s, height: %d, err: %v", w.sink.Name(), block.Height, err)
+		}
+	}
+}
+
+func (s *State) deliverRoutine(w *sinkWorker) {
+	for {
+		if !s.deliverPending(w) {
+			return
+		}
+		select {
+		case <-w.notify:
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// deliverPending delivers the outbox in order, it returns false once the state is stopped.
+func (s *State) deliverPending(w *sinkWorker) bool {
+	heights, err := w.outbox.Pending()
+	if err != nil {
+		s.log.Error("read outbox fail @ state.deliverPending, sink: %s, err: %v", w.sink.Name(), err)
+		return true
+	}
+	for _, h := range heights {
+		data, err := w.outbox.Get(h)
+		if err != nil {
+			s.log.Error("read outbox fail @ state.deliverPending, sink: %s, height: %d, err: %v", w.sink.Name(), h, err)
+			continue
+		}
+		var block storage.CommittedBlock
+		if err := json.Unmarshal(data, &block); err != nil {
+			// a torn entry is never deliverable, drop it rather than block the sink forever
+			s.log.Error("drop corrupted outbox entry @ state.deliverPending, sink: %s, height: %d, err: %v", w.sink.Name(), h, err)
+			w.outbox.Ack(h)
+			continue
+		}
+		backoff := sinkMinBackoff
+		for attempt := 1; ; attempt++ {
+			ctx, cancel := context.WithTimeout(context.Background(), DefaultSinkTimeout)
+			err := w.sink.Deliver(ctx, &block)
+			cancel()
+			if err == nil {
+				break
+			}
+			s.log.Warn("deliver block fail @ state.deliverPending, sink: %s, height: %d, attempt: %d, err: %v",
+				w.sink.Name(), h, attempt, err)
+			select {
+			case <-s.clock.After(backoff):
+			case <-s.quit:
+				return false
+			}
+			if backoff *= 2; backoff > sinkMaxBackoff {
+				backoff = sinkMaxBackoff
+			}
+		}
+		if err := w.outbox.Ack(h); err != nil {
+			s.log.Error("ack outbox fail @ state.deliverPending, sink: %s, height: %d, err: %v", w.sink.Name(), h, err)
+		}
+	}
+	return true
+}
+
+// WebhookSink posts every committed block in json to the url, a 2xx status acknowledges it.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{url: url, client: &http.Client{}}
+}
+
+// Name is derived from the url, so that the outbox is kept as long as the url isn't changed.
+func (w *WebhookSink) Name() string {
+	sum := sha256.Sum256([]byte(w.url))
+	return "webhook-" + hex.EncodeToString(sum[:8])
+}
+
+func (w *WebhookSink) Deliver(ctx context.Context, block *storage.CommittedBlock) error {
+	body, err := json.Marshal(block)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gohotstuff-Height", fmt.Sprint(block.Height))
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responds %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/storage"
+)
+
+// flakySink fails the first delivery of every block.
+type flakySink struct {
+	mtx       sync.Mutex
+	failed    map[int64]bool
+	delivered []int64
+}
+
+func (f *flakySink) Name() string { return "flaky" }
+
+func (f *flakySink) Deliver(ctx context.Context, block *storage.CommittedBlock) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if !f.failed[block.Height] {
+		f.failed[block.Height] = true
+		return errors.New("unavailable")
+	}
+	f.delivered = append(f.delivered, block.Height)
+	return nil
+}
+
+func (f *flakySink) heights() []int64 {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return append([]int64{}, f.delivered...)
+}
+
+func newSinkState(dir string) *State {
+	return &State{
+		cfg:   &ConsensusConfig{OutboxPath: dir},
+		clock: libs.SystemClock,
+		quit:  make(chan struct{}),
+		log:   logs.NewLogger(),
+	}
+}
+
+func TestCommitSinks(t *testing.T) {
+	dir := t.TempDir()
+	if err := (&State{cfg: &ConsensusConfig{}}).AddCommitSink(&flakySink{}); err != ErrNoOutbox {
+		t.Errorf("want no outbox err, has: %v", err)
+	}
+
+	// the blocks committed before a crash are kept in the outbox
+	s := newSinkState(dir)
+	if err := s.AddCommitSink(&flakySink{failed: make(map[int64]bool)}); err != nil {
+		t.Fatal(err)
+	}
+	s.enqueueCommit(&storage.CommittedBlock{Height: 1})
+	s.enqueueCommit(&storage.CommittedBlock{Height: 2})
+
+	s = newSinkState(dir)
+	sink := &flakySink{failed: make(map[int64]bool)}
+	if err := s.AddCommitSink(sink); err != nil {
+		t.Fatal(err)
+	}
+	s.startSinks()
+	defer close(s.quit)
+	if err := s.AddCommitSink(sink); err != ErrSinksStarted {
+		t.Errorf("want started err, has: %v", err)
+	}
+	s.enqueueCommit(&storage.CommittedBlock{Height: 3})
+
+	outbox := s.sinks.workers[0].outbox
+	pending, _ := outbox.Pending()
+	for deadline := time.Now().Add(5 * time.Second); len(pending) > 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		pending, _ = outbox.Pending()
+	}
+	if len(pending) != 0 {
+		t.Fatalf("delivered blocks should be acked, pending: %v", pending)
+	}
+	if got := sink.heights(); len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 3 {
+		t.Errorf("want blocks delivered in order, has: %v", got)
+	}
+}
+
+// fullOutbox fails the puts with ENOSPC while full is set.
+type fullOutbox struct {
+	sinkOutbox
+	full int32
+}
+
+func (o *fullOutbox) Put(seq int64, data []byte) error {
+	if atomic.LoadInt32(&o.full) == 1 {
+		return &os.PathError{Op: "write", Path: fmt.Sprintf("outbox/%d.msg", seq), Err: syscall.ENOSPC}
+	}
+	return o.sinkOutbox.Put(seq, data)
+}
+
+func TestCommitSinkOutboxFailure(t *testing.T) {
+	s := newSinkState(t.TempDir())
+	s.cfg.DiskProbeInterval = 10 * time.Millisecond
+	sink := &flakySink{failed: make(map[int64]bool)}
+	if err := s.AddCommitSink(sink); err != nil {
+		t.Fatal(err)
+	}
+	outbox := &fullOutbox{sinkOutbox: s.sinks.workers[0].outbox, full: 1}
+	s.sinks.workers[0].outbox = outbox
+	s.startSinks()
+	defer close(s.quit)
+
+	// the block missing the outbox suspends the voting instead of being skipped
+	s.enqueueCommit(&storage.CommittedBlock{Height: 1})
+	if err := s.DiskFailure(); !errors.Is(err, ErrDiskSuspended) {
+		t.Fatalf("want the voting suspended, has: %v", err)
+	}
+	atomic.StoreInt32(&outbox.full, 0)
+	for deadline := time.Now().Add(5 * time.Second); len(sink.heights()) == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := sink.heights(); len(got) != 1 || got[0] != 1 {
+		t.Fatalf("want the block delivered once the put passes, has: %v", got)
+	}
+	if err := s.DiskFailure(); err != nil {
+		t.Errorf("want the voting resumed, has: %v", err)
+	}
+}
@@ -1,14 +1,22 @@
 package state
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"runtime/debug"
 	"sync"
 	"time"
 
 	"github.com/astaxie/beego/logs"
 	"github.com/aucusaga/gohotstuff/crypto"
 	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/libs/service"
+	"github.com/aucusaga/gohotstuff/mempool"
+	"github.com/aucusaga/gohotstuff/metrics"
+	"github.com/aucusaga/gohotstuff/state/bt"
+	"github.com/aucusaga/gohotstuff/storage"
 	"github.com/aucusaga/gohotstuff/types"
 )
 
@@ -19,9 +27,22 @@ const (
 	MsgQueueSize     = 1000
 	NoRollbackTmoIdx = 0
 
-	TimeoutProcess  = "TIMEOUT"
-	ProposalProcess = "PROPOSAL"
-	VoteProcess     = "VOTE"
+	DefaultMaxBlockTxs      = 1000
+	DefaultProposalDeadline = TimeoutT / 4
+	// MaxMinBlockInterval keeps the delayed proposal from being timed out by the followers.
+	MaxMinBlockInterval = TimeoutT / 2
+	// MaxVoteGrace keeps the QC waiting for the extra votes from delaying the next round too long.
+	MaxVoteGrace = TimeoutT / 4
+
+	TimeoutProcess      = "TIMEOUT"
+	ProposalProcess     = "PROPOSAL"
+	VoteProcess         = "VOTE"
+	LocalTimeoutProcess = "LOCAL_TIMEOUT"
+	VoteGraceProcess    = "VOTE_GRACE"
+	ScheduleProcess     = "SCHEDULE"
+	StatusProcess       = "STATUS"
+	FetchProcess        = "FETCH"
+	UnknownProcess      = "UNKNOWN"
 )
 
 var (
@@ -32,8 +53,15 @@ var (
 var (
 	ErrVoteSetOccupied    = errors.New("round occupied")
 	ErrComponentsOccupied = errors.New("components occupied")
+	ErrProviderDeadline   = errors.New("proposal provider exceeds the deadline")
+	ErrHistoryUnsupported = errors.New("election doesn't keep the validator history")
+	ErrHighQCMismatch     = errors.New("piggybacked high qc mismatches the parent proposal")
 )
 
+// ProposalProvider returns the payload of the proposal in the given round,
+// ctx is canceled once the deadline is exceeded.
+type ProposalProvider func(ctx context.Context, round int64) ([][]byte, error)
+
 // State handles execution of the hotstuff consensus algorithm.
 // It processes votes and proposals, and upon reaching agreement,
 // commits blocks to the storage and executes them against the application.
@@ -44,37 +72,172 @@ type State struct {
 
 	host PeerID
 	cfg  *ConsensusConfig
+	// channel carries the consensus msgs of this chain, namespaced by the chain id.
+	channel int32
 	// state changes may be triggered by: msgs from peers,
 	// msgs from ourself, or by timeouts
-	peerMsgQueue  chan MsgInfo
-	senderQueue   chan MsgInfo
+	peerMsgQueue chan MsgInfo
+	senderQueue  chan MsgInfo
+	// flushQueue asks the receiveRoutine to flush the senderQueue and exit, see State.flush.
+	flushQueue    chan chan struct{}
 	timeoutTicker TimeoutTicker
+	// graceQueue hands the ends of the vote graces to the receiveRoutine, see waitVoteGrace.
+	graceQueue chan timeoutInfo
 
 	safetyrules SafetyRules
 	pacemaker   Pacemaker
 	election    ProposerElection
+	mempool     mempool.Mempool
+	provider    ProposalProvider
+	executor    Executor
+	extender    VoteExtender
+	// results of the executed blocks, nil if they are not persisted
+	results storage.ResultStorage
+	// committed blocks, nil if they are not persisted
+	blocks storage.BlockStorage
 
 	tree       *BlockTree
 	voteSet    *VoteSet
 	timeoutSet *TimeoutSet
+	// payloads of the uncommitted proposals, map[proposal_id]pendingPayload
+	payloads map[string]pendingPayload
+	// timestamps of the latest committed blocks, the oldest first, only accessed by the receiveRoutine
+	blockTimes []int64
+	// latest proposal broadcasted by the host, resent to the validators connected later
+	lastProposal struct {
+		sync.Mutex
+		round int64
+		msg   []byte
+	}
+	// when the host proposed last time, only accessed by the receiveRoutine
+	lastProposalTime time.Time
+	// the QC waiting for the extra votes, see ConsensusConfig.VoteGrace
+	grace voteGrace
+	// the votes carrying the extensions collected as the next leader
+	extensions voteExtensions
+	// the signed votes and timeouts certifying the QCs formed by the host
+	certSigns qcSigns
+	// sla tracking, only accessed by the receiveRoutine
+	viewChanges    int
+	lastCommitTime time.Time
+	metrics        *consensusMetrics
+	// latest committed round, only accessed by the receiveRoutine
+	committedRound int64
+	hooks          stepHooks
+	// halted at the upgrade height or on a state divergence, written in the procedure mutex
+	halted bool
+	// the voting suspended by the disk failures, see suspendOnDisk
+	disk diskGuard
+	// the current round updated after every step, for the routines which can't take the procedure mutex
+	view int64
+	// latest statuses gossiped by the peers
+	statuses peerStatuses
+	// why the rounds timed out
+	viewStats viewChangeStats
+	downtime  downtimeTracker
+	ejection  ejectionTracker
+	fetcher   proposalFetcher
+	// the consensus params of every epoch, changed by the committed ParamChange txs
+	params paramsHistory
+	// the registered keys of the validators, changed by the committed KeyRegistration txs
+	keys keyRegistry
+	// the join requests of the candidates pending the admission, see SubmitJoinRequest
+	joins joinPool
+	// hashScheme hashes the ids of the proposals of the host, the legacy ids are accepted below
+	// hashActivation, see ConsensusConfig.HashScheme
+	hashScheme     types.HashScheme
+	hashActivation int64
+	// the external systems which the committed blocks are delivered to
+	sinks commitSinks
+	// the committed blocks waiting for the executor
+	pipeline execPipeline
+	// the msgs of every peer are verified in its own queue, see HandleFuncFrom
+	verifiers peerVerifiers
 	// a Write-Ahead Log ensures we can recover from any kind of crash
 	// and helps us avoid signing conflicting votes
-	// wal WAL
+	wal WAL
 
 	// only for dropping stale msgs.
 	// msgBucket sync.Map
 
+	// phases times the blocks from the proposal to the commit, see RoundState.Phases
+	phases phaseTracker
+
 	// procedure mutex, ensures smr only handle one type msg per step.
-	mtx  sync.RWMutex
-	quit chan struct{}
-	log  libs.Logger
+	mtx      sync.RWMutex
+	quit     chan struct{}
+	routines service.Supervisor
+	eventBus libs.EventBus
+	// clock is the time source of the state machine, the system clock by default
+	clock libs.Clock
+	log   libs.Logger
 }
 
+// NewState builds the state machine of the config, the WAL and the stores are opened under the paths
+// of the config unless they're substituted by the opts.
 func NewState(name PeerID, cc crypto.CryptoClient, timeout TimeoutTicker,
-	logger libs.Logger, cfg *ConsensusConfig) (*State, error) {
+	logger libs.Logger, cfg *ConsensusConfig, opts ...StateOption) (*State, error) {
 	if logger == nil {
 		logger = logs.NewLogger()
 	}
+	if cfg.MaxBlockTxs <= 0 {
+		cfg.MaxBlockTxs = DefaultMaxBlockTxs
+	}
+	if cfg.MaxBlockBytes <= 0 {
+		cfg.MaxBlockBytes = DefaultMaxBlockBytes
+	}
+	if cfg.MaxTxBytes <= 0 {
+		cfg.MaxTxBytes = DefaultMaxTxBytes
+	}
+	if cfg.RoundTimeout <= 0 {
+		cfg.RoundTimeout = TimeoutT
+	}
+	if cfg.EpochLength <= 0 {
+		cfg.EpochLength = DefaultEpochLength
+	}
+	if cfg.MaxClockSkew <= 0 {
+		cfg.MaxClockSkew = DefaultMaxClockSkew
+	}
+	if cfg.ProposalDeadline <= 0 {
+		cfg.ProposalDeadline = DefaultProposalDeadline
+	}
+	if cfg.StatusInterval <= 0 {
+		cfg.StatusInterval = DefaultStatusInterval
+	}
+	if cfg.FlushTimeout <= 0 {
+		cfg.FlushTimeout = DefaultFlushTimeout
+	}
+	if cfg.DiskProbeInterval <= 0 {
+		cfg.DiskProbeInterval = DefaultDiskProbeInterval
+	}
+	if cfg.ExecDepth <= 0 {
+		cfg.ExecDepth = DefaultExecDepth
+	}
+	if cfg.MaxVoteExtensionBytes <= 0 {
+		cfg.MaxVoteExtensionBytes = DefaultMaxVoteExtensionBytes
+	}
+	hashScheme, err := types.ParseHashScheme(cfg.HashScheme)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hash scheme @ state.NewState, err: %w", err)
+	}
+	if cfg.DowntimeWindow > 0 && cfg.MaxMissedRatio <= 0 {
+		cfg.MaxMissedRatio = DefaultMaxMissedRatio
+	}
+	if err := validateFailurePolicies(cfg.FailurePolicies); err != nil {
+		return nil, fmt.Errorf("invalid failure policies @ state.NewState, err: %w", err)
+	}
+	if cfg.MinBlockInterval > MaxMinBlockInterval {
+		return nil, fmt.Errorf("min block interval is too long @ state.NewState, has: %v, max: %v",
+			cfg.MinBlockInterval, MaxMinBlockInterval)
+	}
+	if cfg.VoteGrace < 0 || cfg.VoteGrace > MaxVoteGrace {
+		return nil, fmt.Errorf("invalid vote grace @ state.NewState, has: %v, max: %v", cfg.VoteGrace, MaxVoteGrace)
+	}
+
+	keys, err := genesisKeys(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid validator keys @ state.NewState, err: %w", err)
+	}
 
 	tree, err := NewQCTree(name, cfg.StartRound, cfg.StartID,
 		cfg.StartValue, _unmarshal_qurumcert, _new_qurumcert, logger)
@@ -83,18 +246,95 @@ func NewState(name PeerID, cc crypto.CryptoClient, timeout TimeoutTicker,
 		return nil, err
 	}
 
+	var o stateOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	voteSet := NewVoteSet(cfg.StartRound)
+	wal := o.wal
+	if wal == nil && cfg.WALPath != "" {
+		baseWAL, err := NewBaseWAL(cfg.WALPath, logger)
+		if err != nil {
+			logger.Error("open wal fail @ state.NewState, path: %s, err: %v", cfg.WALPath, err)
+			return nil, err
+		}
+		baseWAL.SetFlushPolicy(cfg.WALFlush)
+		wal = baseWAL
+	}
+	if wal != nil {
+		rd, err := wal.NewReader()
+		if err != nil {
+			return nil, err
+		}
+		err = voteSet.Replay(rd)
+		rd.Close()
+		if err != nil {
+			logger.Error("replay votes fail @ state.NewState, path: %s, err: %v", cfg.WALPath, err)
+			return nil, err
+		}
+		voteSet.SetWAL(wal)
+	}
+
+	results := o.results
+	if results == nil && cfg.ResultsPath != "" {
+		rs, err := storage.NewResultStore(cfg.ResultsPath)
+		if err != nil {
+			logger.Error("open result store fail @ state.NewState, path: %s, err: %v", cfg.ResultsPath, err)
+			return nil, err
+		}
+		results = rs
+	}
+	blocks := o.blocks
+	if blocks == nil && cfg.BlocksPath != "" {
+		bs, err := storage.NewBlockStore(cfg.BlocksPath)
+		if err != nil {
+			logger.Error("open block store fail @ state.NewState, path: %s, err: %v", cfg.BlocksPath, err)
+			return nil, err
+		}
+		blocks = bs
+	}
+
 	s := &State{
-		crypto:        cc,
-		host:          name,
-		cfg:           cfg,
-		peerMsgQueue:  make(chan MsgInfo, MsgQueueSize),
-		senderQueue:   make(chan MsgInfo, MsgQueueSize),
-		timeoutTicker: timeout,
-		tree:          tree,
-		voteSet:       NewVoteSet(cfg.StartRound),
-		timeoutSet:    NewTimeoutSet(cfg.StartRound, cfg.StartTimeoutIdx),
-		quit:          make(chan struct{}),
-		log:           logger,
+		crypto:         cc,
+		host:           name,
+		cfg:            cfg,
+		channel:        libs.ChainChannel(cfg.ChainID, libs.ConsensusChannel),
+		peerMsgQueue:   make(chan MsgInfo, MsgQueueSize),
+		senderQueue:    make(chan MsgInfo, MsgQueueSize),
+		flushQueue:     make(chan chan struct{}),
+		timeoutTicker:  timeout,
+		graceQueue:     make(chan timeoutInfo, 1),
+		tree:           tree,
+		voteSet:        voteSet,
+		timeoutSet:     NewTimeoutSet(cfg.StartRound, cfg.StartTimeoutIdx),
+		payloads:       make(map[string]pendingPayload),
+		committedRound: cfg.StartRound,
+		lastCommitTime: libs.SystemClock.Now(),
+		metrics:        newConsensusMetrics(metrics.DefaultRegistry, cfg.ChainID),
+		statuses:       peerStatuses{m: make(map[PeerID]PeerStatus)},
+		viewStats:      viewChangeStats{byReason: make(map[ViewChangeReason]int64)},
+		downtime:       downtimeTracker{records: make(map[PeerID][]bool), down: make(map[PeerID]bool)},
+		ejection:       ejectionTracker{evidences: make(map[PeerID]Misbehavior)},
+		fetcher:        newProposalFetcher(),
+		params:         newParamsHistory(cfg),
+		keys:           keyRegistry{steps: keys},
+		pipeline:       newExecPipeline(cfg.StartRound),
+		hashScheme:     hashScheme,
+		hashActivation: cfg.HashActivationRound,
+		wal:            wal,
+		results:        results,
+		blocks:         blocks,
+		clock:          libs.SystemClock,
+		quit:           make(chan struct{}),
+		log:            logger,
+	}
+	s.routines.SetLogger(logger)
+
+	for _, url := range cfg.CommitWebhooks {
+		if err := s.AddCommitSink(NewWebhookSink(url)); err != nil {
+			logger.Error("add webhook fail @ state.NewState, url: %s, err: %v", url, err)
+			return nil, err
+		}
 	}
 
 	s.log.Info("init a state succ, no components loaded, s: %+v", s)
@@ -134,55 +374,364 @@ func (s *State) RegisterSaftyrules(safetyrules SafetyRules) error {
 	return nil
 }
 
+// RegisterMempool makes the leader pack txs from the mempool into its proposals,
+// proposals carry no payload if there's no mempool registered.
+func (s *State) RegisterMempool(m mempool.Mempool) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.mempool != nil {
+		return ErrComponentsOccupied
+	}
+	s.mempool = m
+	return nil
+}
+
+// SetProposalProvider makes the leader fetch the payload from the user callback
+// instead of the mempool, which suits the users who generate blocks externally.
+func (s *State) SetProposalProvider(fn ProposalProvider) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.provider = fn
+}
+
+// SetClock replaces the system clock, which should be invoked before State.Start(),
+// e.g. a libs.ManualClock of the simulation harness.
+func (s *State) SetClock(c libs.Clock) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.clock = c
+	s.lastCommitTime = c.Now()
+}
+
+// AddPeer resends the latest proposal of the current round to a newly connected validator,
+// so that it needn't wait for the next round to catch up.
+func (s *State) AddPeer(peer libs.Peer) {
+	s.lastProposal.Lock()
+	round, msg := s.lastProposal.round, s.lastProposal.msg
+	s.lastProposal.Unlock()
+	if msg == nil {
+		return
+	}
+
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	if round != int64(s.pacemaker.GetCurrentRound()) {
+		return
+	}
+	for _, v := range s.election.Validators(round, s.timeoutSet.GetTimeoutIdxMap()) {
+		p2pID, err := s.p2p.GetP2PID(string(v))
+		if err != nil || p2pID != peer.PeerID() {
+			continue
+		}
+		peer.Send(s.channel, msg)
+		s.log.Info("resend proposal to a new peer @ state.AddPeer, round: %d, peer_id: %s, msg: %s", round, peer.PeerID(), libs.GetSum(msg))
+		return
+	}
+}
+
+// RemovePeer stops the verifier of the peer, the msgs are routed by the switch.
+func (s *State) RemovePeer(peer libs.Peer, reason interface{}) {
+	s.stopVerifier(peer.PeerID())
+	s.log.Info("peer removed @ state.RemovePeer, peer_id: %s, reason: %v", peer.PeerID(), reason)
+}
+
+// GetRoundState returns a snapshot of the current round.
+func (s *State) GetRoundState() RoundState {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	round := s.pacemaker.GetCurrentRound()
+	return RoundState{
+		ChainID:        s.cfg.ChainID,
+		Round:          round,
+		CommittedRound: s.committedRound,
+		Leader:         s.election.Leader(round, s.timeoutSet.GetTimeoutIdxMap()),
+		Phases:         s.phaseTimings(),
+	}
+}
+
+// Validators returns the validator set at the height, which is the round in hotstuff.
+func (s *State) Validators(height int64) []PeerID {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	return s.election.Validators(height, s.timeoutSet.GetTimeoutIdxMap())
+}
+
+// ValidatorChanges returns the membership diffs in (from, to].
+func (s *State) ValidatorChanges(from, to int64) ([]ValidatorDiff, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	h, ok := s.election.(ValidatorHistory)
+	if !ok {
+		return nil, ErrHistoryUnsupported
+	}
+	return h.ValidatorChanges(from, to), nil
+}
+
+// Diagnostics collects the in-flight consensus data for the crash dumps,
+// walTail is the max number of the latest wal records included.
+func (s *State) Diagnostics(walTail int) *Diagnostics {
+	d := &Diagnostics{
+		RoundState:   s.GetRoundState(),
+		PendingVotes: s.voteSet.PendingVotes(),
+	}
+	if s.wal == nil {
+		return d
+	}
+	rd, err := s.wal.NewReader()
+	if err != nil {
+		d.WALError = err.Error()
+		return d
+	}
+	defer rd.Close()
+	if d.WAL, err = ReadTail(rd, walTail); err != nil {
+		d.WALError = err.Error()
+	}
+	return d
+}
+
+// Channel returns the p2p channel carrying the consensus msgs of the chain.
+func (s *State) Channel() int32 {
+	return s.channel
+}
+
 func (s *State) SetSwitch(p2p libs.Switch) {
 	s.p2p = p2p
+	p2p.SetValidatorSet(s.channel, s.validatorIDs)
+	p2p.SetMessageExpiry(s.channel, s.msgExpired)
+	p2p.SetPullOnSlow(s.channel, isProposal)
+}
+
+// validatorIDs returns the validators of the current and the next round, to which the proposals and
+// the timeouts are broadcasted. It doesn't take the procedure mutex, which is held by the broadcasts.
+func (s *State) validatorIDs() []string {
+	if s.pacemaker == nil || s.election == nil {
+		return nil
+	}
+	round := s.pacemaker.GetCurrentRound()
+	idxMap := s.timeoutSet.GetTimeoutIdxMap()
+	seen := make(map[PeerID]bool)
+	var ids []string
+	for _, r := range []int64{round, round + 1} {
+		for _, v := range s.election.Validators(r, idxMap) {
+			if !seen[v] {
+				seen[v] = true
+				ids = append(ids, string(v))
+			}
+		}
+	}
+	return ids
+}
+
+// SetEventBus sets the event bus on which the state publishes its events.
+func (s *State) SetEventBus(b libs.EventBus) {
+	s.eventBus = b
 }
 
 func (s *State) Start() {
+	if s.wal != nil {
+		s.wal.Start()
+	}
 	go s.timeoutTicker.Start()
-	go s.receiveRoutine()
+	s.routines.Go("receive", service.Policy{Restart: service.OnFailure}, s.receiveRoutine)
+	s.routines.Spawn("status", s.statusRoutine)
+	s.routines.Spawn("exec", s.execRoutine)
+	if s.cfg.ScrubInterval > 0 && s.blocks != nil {
+		s.routines.Spawn("scrub", s.scrubRoutine)
+	}
+	s.startSinks()
 	// start the very first round timer
 	nextRound := s.pacemaker.GetCurrentRound()
 	s.timeoutTicker.ScheduleTimeout(timeoutInfo{
 		Type:     TypeNextRound,
 		Round:    nextRound,
-		Duration: TimeoutT,
+		Duration: s.firstTimeout(nextRound),
 		Index:    s.timeoutSet.GetCurrentTimeoutIndex(),
 	})
 }
 
+// firstTimeout is the duration of the very first round timer, a new chain started before its
+// genesis time idles until then, so that all the validators begin the first view at once.
+func (s *State) firstTimeout(round int64) time.Duration {
+	timeout := s.ParamsAt(round).RoundTimeout
+	if s.cfg.GenesisTime.IsZero() || s.committedRound != s.cfg.StartRound || round > s.cfg.StartRound+1 {
+		return timeout
+	}
+	wait := s.cfg.GenesisTime.Sub(s.clock.Now())
+	if wait <= 0 {
+		return timeout
+	}
+	s.log.Info("wait for the genesis time @ state.Start, genesis_time: %s, wait: %v",
+		s.cfg.GenesisTime.Format(time.RFC3339), wait)
+	return wait
+}
+
+// Stop stops the state machine. The votes already decided and the other msgs queued for the current view
+// are signed and sent first, see State.flush, then a leader hands over its round by broadcasting
+// a timeout msg, so that the others needn't wait out the timeout.
+// The wal is flushed once the routines writing it have exited, the p2p must outlive it
+// since the routines may still be broadcasting.
+func (s *State) Stop() {
+	s.stopReceiving()
+	s.mtx.Lock()
+	if err := s.abdicate(); err != nil {
+		s.log.Error("abdicate fail @ state.Stop, err: %v", err)
+	}
+	s.mtx.Unlock()
+
+	close(s.quit)
+	s.routines.Stop()
+	s.timeoutTicker.Stop()
+	if err := s.routines.Wait(); err != nil {
+		s.log.Error("routines fail @ state.Stop, err: %v", err)
+	}
+	if s.wal != nil {
+		if err := s.wal.Stop(); err != nil {
+			s.log.Error("stop wal fail @ state.Stop, err: %v", err)
+		}
+	}
+}
+
+// Wait blocks until all the routines of the state have exited, i.e. after Stop.
+func (s *State) Wait() {
+	s.routines.Wait()
+}
+
+// abdicate broadcasts a signed timeout msg of the current round if the host is its leader.
+func (s *State) abdicate() error {
+	round := s.pacemaker.GetCurrentRound()
+	if s.election.Leader(round, s.timeoutSet.GetTimeoutIdxMap()) != s.host {
+		return nil
+	}
+	justify, err := s.tree.GetJustify()
+	if err != nil {
+		return err
+	}
+	highQC, err := s.tree.DeserializeF(justify)
+	if err != nil {
+		return err
+	}
+	highRound, highID, err := highQC.Proposal()
+	if err != nil {
+		return err
+	}
+	if justify, err = bareQC(highQC); err != nil {
+		return err
+	}
+	tmo := TimeoutMsg(round, highRound, highID, justify, s.timeoutSet.GetCurrentTimeoutIndex())
+	tmo.Timestamp = s.clock.Now().Unix()
+	tmo.SendID = string(s.host)
+	newmsg, err := s.signMsg(tmo)
+	if err != nil {
+		return err
+	}
+	s.p2p.BroadcastToValidators(s.channel, newmsg)
+	s.log.Info("leader abdicates @ state.Stop, round: %d, msg: %s", round, libs.GetSum(newmsg))
+	return nil
+}
+
 // Handle define consensus reactor function,
 // NOTE: chID is ignored if it's unknown.
 func (s *State) HandleFunc(chID int32, msgbytes []byte) {
 	switch chID {
-	case libs.ConsensusChannel:
-		s.log.Info("receive msg: %s", libs.GetSum(msgbytes))
-		msg, err := ConsMsgFromProto(msgbytes)
-		if err != nil {
-			s.log.Error("transfer msg from proto fail @ state.Handle, err: %v", err)
-			return
-		}
-		s.peerMsgQueue <- msg
+	case s.channel:
+		s.receive(msgbytes, false)
 	default:
 	}
 }
 
-func (s *State) receiveRoutine() {
+// receive verifies the consensus msg and hands it to the receiveRoutine, the msg is dropped if it's invalid.
+// The msgbytes lent by the switch aren't owned and are copied if they're kept, see libs.Reactor.
+func (s *State) receive(msgbytes []byte, owned bool) {
+	s.log.Info("receive msg: %s", libs.GetSum(msgbytes))
+	valid, err := s.crypto.Verify(nil, nil, msgbytes)
+	if err != nil || !valid {
+		s.log.Error("verify msg fail @ state.Handle, msg: %s, valid: %v, err: %v", libs.GetSum(msgbytes), valid, err)
+		return
+	}
+	msg, err := ConsMsgFromProto(msgbytes)
+	if err != nil {
+		s.log.Error("transfer msg from proto fail @ state.Handle, err: %v", err)
+		return
+	}
+	if err := s.checkDomain(msgbytes, msg); err != nil {
+		s.log.Error("check msg domain fail @ state.Handle, msg: %s, err: %v", libs.GetSum(msgbytes), err)
+		return
+	}
+	if err := s.checkKey(msg); err != nil {
+		s.log.Error("check msg key fail @ state.HandleFunc, msg: %s, err: %v", libs.GetSum(msgbytes), err)
+		return
+	}
+	if p, ok := msg.(*types.ProposalMsg); ok {
+		if !owned {
+			msgbytes = append([]byte(nil), msgbytes...)
+		}
+		s.cacheProposal(p.Round, p.ID, msgbytes)
+	}
+	select {
+	case s.peerMsgQueue <- msg:
+	case <-s.quit:
+	}
+}
+
+// receiveRoutine is restarted by the supervisor once it fails on the panic of a msg,
+// the peer who sent the poisonous msg will be disconnected.
+func (s *State) receiveRoutine() (err error) {
+	var current MsgInfo
+	defer func() {
+		if r := recover(); r != nil {
+			stack := debug.Stack()
+			s.log.Error("receiveRoutine panics @ state.receiveRoutine, msg: %+v, err: %v, stack: %s", current, r, stack)
+			sender := msgSender(current)
+			if sender != "" && PeerID(sender) != s.host {
+				if p2pID, err := s.p2p.GetP2PID(sender); err == nil {
+					s.p2p.StopPeerForError(p2pID, r)
+				}
+			}
+			s.publish(libs.Event{
+				Type:   libs.EventPanic,
+				Module: libs.ConsensusModule,
+				Data: libs.PanicEventData{
+					PeerID: sender,
+					Reason: fmt.Sprintf("%v", r),
+					Stack:  string(stack),
+				},
+			})
+			err = fmt.Errorf("receiveRoutine panics, err: %v", r)
+		}
+	}()
+
 	for {
+		current = nil
 		select {
 		case m := <-s.peerMsgQueue:
-			s.handleMsg(m)
+			current = m
+			s.runStep(stepName(m), func() { s.handleMsg(m) })
 		case m := <-s.senderQueue:
-			s.schedule(m)
+			s.runStep(ScheduleProcess, func() { s.schedule(m) })
 		case m := <-s.timeoutTicker.Chan():
-			s.localTimeout(m)
+			s.runStep(LocalTimeoutProcess, func() { s.localTimeout(m) })
+		case m := <-s.graceQueue:
+			s.runStep(VoteGraceProcess, func() { s.closeVoteGrace(m) })
+		case done := <-s.flushQueue:
+			s.flush()
+			close(done)
+			return nil
 		case <-s.quit:
-			return
+			return nil
 		}
 	}
 }
 
 func (s *State) handleMsg(m MsgInfo) error {
+	if s.halted && haltedMsg(m) {
+		return ErrUpgradeHalted
+	}
 	msgbytes, err := ProtoFromConsMsg(m)
 	if err != nil {
 		s.log.Error("ProtoFromConsMsg fail @ handleMsg,err: %v", err)
@@ -193,6 +742,11 @@ func (s *State) handleMsg(m MsgInfo) error {
 		s.log.Info("receive proposal @ handleMsg, msg: %s, proposal: %s", libs.GetSum(msgbytes), t.String())
 		if err := s.onReceiveProposal(t); err != nil {
 			s.log.Error("receive proposal fail @ state.handleMsg, proposal: %+v, err: %v", t, err)
+			s.viewStats.rejectedRound = t.Round
+		} else {
+			s.viewStats.acceptedRound = t.Round
+			s.trackParticipation(t)
+			s.onProposalFetched(t)
 		}
 	case *types.VoteMsg:
 		s.log.Info("receive vote @ handleMsg, msg: %s, vote: %s", libs.GetSum(msgbytes), t.String())
@@ -204,6 +758,16 @@ func (s *State) handleMsg(m MsgInfo) error {
 		if err := s.onReceiveTimeout(t); err != nil {
 			s.log.Error("receive timeout fail @ state.handleMsg, timeout: %+v, err: %v", t, err)
 		}
+	case *types.StatusMsg:
+		s.log.Debug("receive status @ handleMsg, status: %s", t.String())
+		if err := s.onReceiveStatus(t); err != nil {
+			s.log.Error("receive status fail @ state.handleMsg, status: %+v, err: %v", t, err)
+		}
+	case *types.ProposalRequestMsg:
+		s.log.Info("receive proposal request @ handleMsg, msg: %s, request: %s", libs.GetSum(msgbytes), t.String())
+		if err := s.onReceiveProposalRequest(t); err != nil {
+			s.log.Warn("reply proposal request fail @ state.handleMsg, request: %+v, err: %v", t, err)
+		}
 	default:
 		s.log.Error("unknown msginfo type @ state.handleMsg")
 		return fmt.Errorf("unknown msginfo type @ state.handleMsg, type: %+v", t)
@@ -229,10 +793,29 @@ func (s *State) onReceiveProposal(proposal *types.ProposalMsg) error {
 	if err != nil {
 		return fmt.Errorf("invalid parentQC @ state.onReceiveProposal, parentQC: %s, err: %v", parentQC.String(), err)
 	}
+	if err := s.checkPayload(proposal.Round, proposal.Txs); err != nil {
+		return fmt.Errorf("oversize proposal @ state.onReceiveProposal, proposal: %s, err: %w", proposal.String(), err)
+	}
+	if err := s.verifyBlockID(proposal.ID, proposalContent(proposal, parentRound, parentID)); err != nil {
+		return fmt.Errorf("invalid proposal id @ state.onReceiveProposal, proposal: %s, err: %w", proposal.String(), err)
+	}
+	// the extensions must be signed by the voters certifying the parent
+	exts, err := s.verifyVoteExtensions(proposal.VoteExtensions, parentRound, parentID)
+	if err != nil {
+		return fmt.Errorf("verify vote extensions fail @ state.onReceiveProposal, proposal: %s, err: %w", proposal.String(), err)
+	}
+	// the proposer can only have executed the blocks before the proposal
+	if proposal.AppHeight < 0 || (len(proposal.AppHash) > 0 && proposal.AppHeight >= proposal.Round) {
+		return fmt.Errorf("invalid app height @ state.onReceiveProposal, proposal: %s, app_height: %d",
+			proposal.String(), proposal.AppHeight)
+	}
 	pnode, err := s.tree.Search(parentRound, parentID)
 	if err != nil {
+		// the parent has been certified by the votes we never saw, pull it from the leader
+		s.requestProposal(parentRound, parentID, PeerID(proposal.PeerID), proposal)
 		return fmt.Errorf("cannot find parent node in our local tree, parentQC: %+v, err: %v", parentQC, err)
 	}
+	s.certifyJustify(parentQC)
 
 	newQC, err := s.tree.NewQurumCertF(string(proposal.PeerID), proposal.Signature,
 		proposal.Round, proposal.ID, parentRound, parentID)
@@ -253,15 +836,54 @@ func (s *State) onReceiveProposal(proposal *types.ProposalMsg) error {
 	if err != nil && err != libs.ErrRepeatInsert {
 		return fmt.Errorf("insert qcTree fail @ state.onReceiveProposal, newQC: %+v, err: %v", newQC, err)
 	}
+	s.markProposal(proposal.Round)
+	s.markQC(parentRound)
+	// the timestamp is validated against the branch before the payload of the proposal joins it
+	tsErr := s.checkTimestamp(proposal.Round, proposal.Timestamp, pnode)
+	s.checkDoubleProposal(proposal.Round, libs.F(proposal.ID), proposal.PeerID)
+	s.payloads[libs.F(proposal.ID)] = pendingPayload{round: proposal.Round, txs: proposal.Txs,
+		appHeight: proposal.AppHeight, appHash: proposal.AppHash, proposer: proposal.PeerID, timestamp: proposal.Timestamp,
+		extensions: exts, signedExtensions: proposal.VoteExtensions}
 	if pnode.Parent != nil && pnode.Parent.Parent != nil && pnode.Parent.Parent.Parent != nil {
-		s.tree.ProcessCommit(pnode.Parent.Parent.Parent.ID)
+		commitNode := pnode.Parent.Parent.Parent
+		// the ancestors are detached once the node becomes the root
+		committing := s.committingChain(commitNode)
+		if s.pastUpgrade(commitNode.Round) {
+			s.haltForUpgrade()
+		} else if committed, err := s.tree.ProcessCommit(commitNode.ID); err != nil {
+			s.log.Error("commit fail @ state.onReceiveProposal, round: %d, err: %v", commitNode.Round, err)
+		} else if committed {
+			for _, node := range committing {
+				s.onCommit(node)
+			}
+		}
 	}
 	s.log.Info("receive a proposal ticket, proposal: %s, new_round: %d, high_qc: [%s], root_qc: [%s]",
 		newQC.String(), s.pacemaker.GetCurrentRound(), s.tree.GetCurrentHighQC().String(), s.tree.GetCurrentRoot().String())
 
+	if s.execBacklogged() {
+		s.log.Warn("execution falls behind, skip voting @ state.onReceiveProposal, round: %d, executed: %d",
+			proposal.Round, s.ExecutedHeight())
+		return nil
+	}
+	if s.votingSuspended() {
+		s.log.Warn("disk failure, skip voting @ state.onReceiveProposal, round: %d", proposal.Round)
+		return nil
+	}
+	if tsErr != nil {
+		s.log.Warn("untrusted timestamp, skip voting @ state.onReceiveProposal, round: %d, err: %v", proposal.Round, tsErr)
+		return nil
+	}
+	// the proposal still extends the tree, the others may certify it
+	if s.excluded(PeerID(proposal.PeerID)) {
+		s.log.Warn("excluded proposer, skip voting @ state.onReceiveProposal, round: %d, proposer: %s", proposal.Round, proposal.PeerID)
+		return nil
+	}
 	nextRound := s.pacemaker.GetCurrentRound() + 1
 	nextLeader := s.election.Leader(nextRound, s.timeoutSet.GetTimeoutIdxMap())
-	s.senderQueue <- VoteMsg(proposal.Round, proposal.ID, parentRound, parentID, string(nextLeader))
+	vote := VoteMsg(proposal.Round, proposal.ID, parentRound, parentID, string(nextLeader))
+	s.extendVote(vote)
+	s.senderQueue <- vote
 	return nil
 }
 
@@ -281,25 +903,63 @@ func (s *State) onReceiveVote(vote *types.VoteMsg) error {
 	if err := s.safetyrules.CheckVote(voteQC); err != nil {
 		return fmt.Errorf("check vote fail @ state.onReceiveVote, vote: %+v, err: %v", voteQC.String(), err)
 	}
+	if s.excluded(PeerID(vote.SendID)) {
+		return fmt.Errorf("%w, vote: %s", ErrValidatorExcluded, voteQC.String())
+	}
+	if err := s.checkVoteExtension(vote); err != nil {
+		return fmt.Errorf("check vote extension fail @ state.onReceiveVote, vote: %s, err: %w", voteQC.String(), err)
+	}
 	validators := s.election.Validators(vote.Round, s.timeoutSet.GetTimeoutIdxMap())
 	// add new vote info into the set
-	if err := s.voteSet.AddVote(vote.Round, vote.ID, PeerID(vote.SendID), validators); err != nil {
+	addVote := func() error { return s.voteSet.AddVote(vote.Round, vote.ID, PeerID(vote.SendID), validators) }
+	err = addVote()
+	if errors.Is(err, ErrWALWrite) {
+		err = s.handleFailure(FailureWAL, true, err, addVote)
+	}
+	if err == ErrConflictingVote {
+		voted, _ := s.voteSet.Voted(vote.Round, PeerID(vote.SendID))
+		s.reportMisbehavior(Misbehavior{Validator: PeerID(vote.SendID), Kind: DoubleVote, Round: vote.Round,
+			First: voted, Second: libs.F(vote.ID)})
+	}
+	if errors.Is(err, ErrDuplicateVote) {
+		// the vote is relayed or resent by the peers, it has been counted
+		s.log.Debug("duplicate vote @ state.onReceiveVote, vote: %s", voteQC.String())
+		return nil
+	}
+	if err != nil {
 		return fmt.Errorf("try to add vote fail @ state.onReceiveVote, vote: %+v, err: %v", voteQC.String(), err)
 	}
+	s.collectVoteExtension(vote)
+	s.collectSign(vote.Round, vote.ID, vote)
 	s.log.Info("receive a vote ticket, vote: %s, validators: %+v", voteQC.String(), validators)
 	if !s.voteSet.HasTwoThirdsAny(vote.Round, vote.ID) {
 		return nil
 	}
+	if s.waitVoteGrace(vote, voteQC, validators) {
+		return nil
+	}
+	return s.formQC(vote, voteQC, validators)
+}
 
+// formQC makes the proposal certified by the collected votes the high qc and advances to the next round.
+func (s *State) formQC(vote *types.VoteMsg, voteQC QuorumCert, validators []PeerID) error {
 	// atomic operations
 	// the leader has received 2/3 votes, try to advance to the next round
 	if err := s.tree.ProcessVote(voteQC, validators); err != nil {
+		if _, serr := s.tree.Search(vote.Round, vote.ID); serr != nil {
+			// the proposal is voted by the quorum but missed by the host, our own vote
+			// resumes the qc once the proposal is fetched from the voter
+			s.requestProposal(vote.Round, vote.ID, PeerID(vote.SendID), nil)
+		}
 		return fmt.Errorf("still collecting @ state.onReceiveVote , vote: %+v, err: %v", vote, err)
 	}
+	s.markQC(vote.Round)
+	s.certify(vote.Round, vote.ID)
 	// pacemaker advance to the next round and broadcast new proposal
 	s.pacemaker.AdvanceRound(voteQC)
 	s.log.Info("collect 2f+1 votes, vote: %s, new_round: %d, high_qc: [%s]",
 		voteQC.String(), s.pacemaker.GetCurrentRound(), s.tree.GetCurrentHighQC().String())
+	s.runQCHooks(s.grace.formed(vote.Round, vote.ID, s.voteSet, s.clock.Now()))
 	s.NewRoundEvent(VoteProcess)
 	return nil
 }
@@ -313,7 +973,7 @@ func (s *State) onReceiveTimeout(timeout *types.TimeoutMsg) error {
 
 	// timeout msg cannot predict the next id of the quromcert, so we generate an unique one
 	tmo, err := s.tree.NewQurumCertF(string(timeout.SendID), timeout.Signature,
-		timeout.Round, s.getTimeoutID(timeout.Round, timeout.Index), timeout.ParentRound, timeout.ParentID)
+		timeout.Round, timeoutID(timeout.Round, timeout.Index), timeout.ParentRound, timeout.ParentID)
 	if err != nil {
 		return fmt.Errorf("new a timeout qc fail @ state.onReceiveTimeout, timeout: %+v, err: %v", tmo.String(), err)
 	}
@@ -325,7 +985,19 @@ func (s *State) onReceiveTimeout(timeout *types.TimeoutMsg) error {
 	if err := s.timeoutSet.AddTimeout(timeout.Round, timeout.Index, PeerID(timeout.SendID), validators); err != nil {
 		return fmt.Errorf("try to add timeout fail @ state.onReceiveTimeout, timeout: %+v, err: %v", tmo.String(), err)
 	}
+	s.collectSign(timeout.Round, timeoutID(timeout.Round, timeout.Index), timeout)
 	s.log.Info("receive a timeout ticket: %s, validators: %+v", tmo.String(), validators)
+	if err := s.adoptHighQC(timeout); err != nil {
+		s.log.Warn("adopt the piggybacked high qc fail @ state.onReceiveTimeout, timeout: %s, err: %v", timeout.String(), err)
+	}
+	// the leader abdicates its round, stop waiting for its proposal
+	if s.handover(timeout) {
+		s.timeoutTicker.ScheduleTimeout(timeoutInfo{
+			Type:  TypeNextRound,
+			Round: timeout.Round,
+			Index: s.timeoutSet.GetCurrentTimeoutIndex(),
+		})
+	}
 	if !s.timeoutSet.HasTwoThirdsAny(timeout.Round, timeout.Index) {
 		return nil
 	}
@@ -345,17 +1017,84 @@ func (s *State) onReceiveTimeout(timeout *types.TimeoutMsg) error {
 	if err := s.tree.ProcessVote(tmo, validators); err != nil {
 		return fmt.Errorf("fail to update highQC @ state.onReceiveTimeout , newQC: %+v, err: %v", tmo.String(), err)
 	}
+	s.certify(timeout.Round, timeoutID(timeout.Round, timeout.Index))
 	s.log.Info("collect 2f+1 tmos, tmo: %s, new_round: %d, high_qc: [%s]",
 		tmo.String(), s.pacemaker.GetCurrentRound(), s.tree.GetCurrentHighQC().String())
 	s.NewRoundEvent(TimeoutProcess)
 	return nil
 }
 
+// handover reports whether the timeout msg is sent by the leader of the current round, which abdicates it.
+// The leader may have formed the QC of the previous round before it abdicates, which is piggybacked on the
+// timeout msg as the high qc, the host waiting for the proposal on the QC enters the round of the leader.
+func (s *State) handover(timeout *types.TimeoutMsg) bool {
+	leader := s.election.Leader(timeout.Round, s.timeoutSet.GetTimeoutIdxMap())
+	if PeerID(timeout.SendID) != leader || leader == s.host {
+		return false
+	}
+	current := s.pacemaker.GetCurrentRound()
+	if timeout.Round == current+1 && timeout.ParentRound == current {
+		// the piggybacked qc has been adopted as the high qc, see adoptHighQC
+		highQC := s.tree.GetCurrentHighQC()
+		if highQC == nil {
+			return false
+		}
+		if round, id, err := highQC.Proposal(); err != nil || round != current || !bytes.Equal(id, timeout.ParentID) {
+			return false
+		}
+		if err := s.pacemaker.AdvanceRound(highQC); err != nil {
+			s.log.Warn("advance round fail @ state.handover, timeout: %s, err: %v", timeout.String(), err)
+			return false
+		}
+	}
+	return timeout.Round == s.pacemaker.GetCurrentRound()
+}
+
+// adoptHighQC refreshes the local high qc by the one piggybacked on the timeout msg,
+// so that the next leader proposes on the freshest certified block instead of a stale one.
+// NOTE: the qc is ignored if its block has not been received yet.
+func (s *State) adoptHighQC(timeout *types.TimeoutMsg) error {
+	if len(timeout.HighQC) == 0 {
+		return nil
+	}
+	highQC, err := s.tree.DeserializeF(timeout.HighQC)
+	if err != nil {
+		return err
+	}
+	highRound, highID, err := highQC.Proposal()
+	if err != nil {
+		return err
+	}
+	if highRound != timeout.ParentRound || !bytes.Equal(highID, timeout.ParentID) {
+		return ErrHighQCMismatch
+	}
+	current := s.tree.GetCurrentHighQC()
+	if current != nil {
+		if round, _, err := current.Proposal(); err == nil && round >= highRound {
+			return nil
+		}
+	}
+	if _, err := s.tree.Search(highRound, highID); err != nil {
+		s.requestProposal(highRound, highID, PeerID(timeout.SendID), nil)
+		return err
+	}
+	if err := s.tree.ProcessVote(highQC, nil); err != nil {
+		return err
+	}
+	s.log.Info("adopt a fresher high qc from the timeout msg, from: %s, high_qc: [%s]",
+		timeout.SendID, s.tree.GetCurrentHighQC().String())
+	return nil
+}
+
 // localTimeout handles listening-proposal | collecting-votes timeout
 func (s *State) localTimeout(ti timeoutInfo) error {
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
 
+	if s.halted {
+		return ErrUpgradeHalted
+	}
+
 	justify, err := s.tree.GetJustify()
 	if err != nil {
 		s.log.Error("justify fail @ local timeout, timeout info: %+v, err: %+v", ti, err)
@@ -373,7 +1112,7 @@ func (s *State) localTimeout(ti timeoutInfo) error {
 	}
 
 	tmo, err := s.tree.NewQurumCertF(string(s.host), nil,
-		ti.Round, s.getTimeoutID(ti.Round, ti.Index), highRound, highID)
+		ti.Round, timeoutID(ti.Round, ti.Index), highRound, highID)
 	if err != nil {
 		return fmt.Errorf("new a timeout qc fail @ state.onReceiveTimeout, timeout: %+v, err: %v", tmo.String(), err)
 	}
@@ -387,11 +1126,16 @@ func (s *State) localTimeout(ti timeoutInfo) error {
 		return err
 	}
 
-	s.senderQueue <- TimeoutMsg(int64(ti.Round), highRound, highID, s.timeoutSet.GetCurrentTimeoutIndex())
+	if justify, err = bareQC(highQC); err != nil {
+		s.log.Error("serialize fail @ local timeout, timeout info: %+v, err: %+v", ti, err)
+		return err
+	}
+	s.senderQueue <- TimeoutMsg(int64(ti.Round), highRound, highID, justify, s.timeoutSet.GetCurrentTimeoutIndex())
+	s.onViewChange(ti)
 	// tmo collecting should also follow timeout rules.
 	s.timeoutTicker.ScheduleTimeout(timeoutInfo{
 		Type:     TypeNextRound,
-		Duration: TimeoutT,
+		Duration: s.ParamsAt(ti.Round).RoundTimeout,
 		Round:    ti.Round,
 		Index:    s.timeoutSet.GetCurrentTimeoutIndex(),
 	})
@@ -399,56 +1143,82 @@ func (s *State) localTimeout(ti timeoutInfo) error {
 }
 
 func (s *State) schedule(m MsgInfo) error {
+	if s.halted && haltedMsg(m) {
+		return ErrUpgradeHalted
+	}
+	if haltedMsg(m) && s.votingSuspended() {
+		return ErrDiskSuspended
+	}
 	switch t := m.(type) {
 	case *types.ProposalMsg:
-		t.Timestamp = time.Now().Unix()
+		t.Timestamp = s.proposalTime(t)
 		t.PeerID = string(s.host)
-		s.peerMsgQueue <- m
-		// sign and put pk in the msg
-		msgbytes, err := ProtoFromConsMsg(t)
-		if err != nil {
+		// the id commits to the timestamp as well, so it's hashed once the proposal is sent
+		if err := s.hashProposal(t); err != nil {
 			return err
 		}
-		newmsg, err := s.crypto.Sign(msgbytes)
+		s.peerMsgQueue <- m
+		// sign and put pk in the msg
+		newmsg, err := s.signMsg(t)
 		if err != nil {
 			return err
 		}
-		s.p2p.Broadcast(libs.ConsensusChannel, newmsg)
+		s.lastProposal.Lock()
+		s.lastProposal.round, s.lastProposal.msg = t.Round, newmsg
+		s.lastProposal.Unlock()
+		s.cacheProposal(t.Round, t.ID, newmsg)
+		s.p2p.BroadcastToValidators(s.channel, newmsg)
 		s.log.Info("broadcast proposal msg: %s", libs.GetSum(newmsg))
 	case *types.VoteMsg:
-		t.Timestamp = time.Now().Unix()
+		t.Timestamp = s.clock.Now().Unix()
 		t.SendID = string(s.host)
-		s.peerMsgQueue <- m
 		// sign and put pk in the msg
-		msgbytes, err := ProtoFromConsMsg(t)
+		newmsg, err := s.signMsg(t)
 		if err != nil {
 			return err
 		}
-		newmsg, err := s.crypto.Sign(msgbytes)
-		if err != nil {
-			return err
+		// the own vote certifies the QC and relays the extension along with the signature
+		if signed, err := ConsMsgFromProto(newmsg); err == nil {
+			sv := signed.(*types.VoteMsg)
+			t.PublicKey, t.Signature = sv.PublicKey, sv.Signature
 		}
+		s.peerMsgQueue <- m
 		p2pID, err := s.p2p.GetP2PID(t.To)
 		if err != nil {
 			return err
 		}
-		s.p2p.Send(p2pID, libs.ConsensusChannel, newmsg)
+		s.p2p.Send(p2pID, s.channel, newmsg)
+		s.markVote(t.Round)
 		s.log.Info("send vote msg: %s", libs.GetSum(newmsg))
 	case *types.TimeoutMsg:
-		t.Timestamp = time.Now().Unix()
+		t.Timestamp = s.clock.Now().Unix()
 		t.SendID = string(s.host)
-		s.peerMsgQueue <- m
 		// sign and put pk in the msg
-		msgbytes, err := ProtoFromConsMsg(t)
+		newmsg, err := s.signMsg(t)
 		if err != nil {
 			return err
 		}
-		newmsg, err := s.crypto.Sign(msgbytes)
+		// the own timeout certifies the timeout QC along with the signature
+		if signed, err := ConsMsgFromProto(newmsg); err == nil {
+			st := signed.(*types.TimeoutMsg)
+			t.PublicKey, t.Signature = st.PublicKey, st.Signature
+		}
+		s.peerMsgQueue <- m
+		s.p2p.BroadcastToValidators(s.channel, newmsg)
+		s.log.Info("broadcast timeout msg: %s", libs.GetSum(newmsg))
+	case *types.ProposalRequestMsg:
+		t.Timestamp = s.clock.Now().Unix()
+		t.SendID = string(s.host)
+		newmsg, err := s.signMsg(t)
 		if err != nil {
 			return err
 		}
-		s.p2p.Broadcast(libs.ConsensusChannel, newmsg)
-		s.log.Info("broadcast timeout msg: %s", libs.GetSum(newmsg))
+		p2pID, err := s.p2p.GetP2PID(t.To)
+		if err != nil {
+			return err
+		}
+		s.p2p.Send(p2pID, s.channel, newmsg)
+		s.log.Info("send proposal request msg: %s", libs.GetSum(newmsg))
 	default:
 		return fmt.Errorf("unknown msginfo type @ state.schedule, type: %+v", t)
 	}
@@ -465,7 +1235,7 @@ func (s *State) NewRoundEvent(action string) error {
 			action, int64(nextRound), nextLeader, s.host)
 		s.timeoutTicker.ScheduleTimeout(timeoutInfo{
 			Type:     TypeNextRound,
-			Duration: TimeoutT,
+			Duration: s.ParamsAt(nextRound).RoundTimeout,
 			Round:    nextRound,
 			Index:    s.timeoutSet.GetCurrentTimeoutIndex(),
 		})
@@ -474,6 +1244,7 @@ func (s *State) NewRoundEvent(action string) error {
 
 	// generate a new proposal in a new round as a leader,
 	// it's invoked after the host has collected full votes or full timeout qcs.
+	var delay time.Duration
 	if action == VoteProcess || action == TimeoutProcess {
 		nextID, err := s.GetNextID()
 		if err != nil {
@@ -486,14 +1257,34 @@ func (s *State) NewRoundEvent(action string) error {
 			return err
 		}
 
-		proposal := ProposalMsg(nextRound, nextID, justify)
+		txs, err := s.nextPayload(nextRound)
+		if err != nil {
+			// an empty proposal still keeps the chain alive
+			s.log.Error("fetch payload fail @ state.generateProposal, round: %d, err: %v", nextRound, err)
+		}
+		proposal := ProposalMsg(nextRound, nextID, justify, txs)
+		proposal.AppHeight, proposal.AppHash = s.appHashClaim()
+		proposal.VoteExtensions = s.certifiedExtensions(justify)
 		s.log.Info("process new round as a leader, process: %s, round: %d, id: %s, proposal: %+v", action, int64(nextRound), libs.F(nextID), proposal.String())
-		s.senderQueue <- proposal
+		delay = s.proposalDelay()
+		s.lastProposalTime = s.clock.Now().Add(delay)
+		if delay <= 0 {
+			s.senderQueue <- proposal
+		} else {
+			// keep the blocks spaced by the min block interval
+			s.log.Info("delay the proposal @ state.generateProposal, round: %d, delay: %v", nextRound, delay)
+			s.clock.AfterFunc(delay, func() {
+				select {
+				case s.senderQueue <- proposal:
+				case <-s.quit:
+				}
+			})
+		}
 	}
 
 	s.timeoutTicker.ScheduleTimeout(timeoutInfo{
 		Type:     TypeCollectVotes,
-		Duration: TimeoutT,
+		Duration: s.ParamsAt(nextRound).RoundTimeout + delay,
 		Round:    nextRound,
 		Index:    s.timeoutSet.GetCurrentTimeoutIndex(),
 	})
@@ -501,23 +1292,313 @@ func (s *State) NewRoundEvent(action string) error {
 	return nil
 }
 
+// proposalDelay returns how long the next proposal should wait for the min block interval.
+func (s *State) proposalDelay() time.Duration {
+	if s.cfg.MinBlockInterval <= 0 || s.lastProposalTime.IsZero() {
+		return 0
+	}
+	if d := s.cfg.MinBlockInterval - s.clock.Since(s.lastProposalTime); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// committingChain returns the blocks committed along with the node in the order of their rounds,
+// i.e. the node and its ancestors after the last committed block, which are committed implicitly
+// by the 3-chain rule if their own 3-chains were broken, e.g. by a view change.
+func (s *State) committingChain(node *bt.Node) []*bt.Node {
+	var chain []*bt.Node
+	for n := node; n != nil && n.Round > s.committedRound; n = n.Parent {
+		chain = append(chain, n)
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain
+}
+
+// onCommit applies the committed block, evicts its txs from the mempool and cleans up the stale states,
+// the blocks committed at once are applied one by one in the order of their rounds, see committingChain.
+func (s *State) onCommit(node *bt.Node) {
+	p, ok := s.payloads[node.ID]
+	if ok && s.mempool != nil {
+		txs := make([]mempool.Tx, 0, len(p.txs))
+		for _, tx := range p.txs {
+			txs = append(txs, tx)
+		}
+		if err := s.mempool.Update(node.Round, txs); err != nil {
+			s.log.Error("update mempool fail @ state.onCommit, round: %d, err: %v", node.Round, err)
+		}
+	}
+	for id, p := range s.payloads {
+		if p.round <= node.Round {
+			delete(s.payloads, id)
+		}
+	}
+	if node.Round > s.committedRound {
+		s.committedRound = node.Round
+		if block, err := s.committedBlock(node, p); err != nil {
+			s.log.Error("invalid committed qc @ state.onCommit, round: %d, err: %v", node.Round, err)
+		} else {
+			s.saveBlock(block)
+			s.enqueueCommit(block)
+			s.runCommitHooks(block)
+		}
+		s.recordBlockTime(p.timestamp)
+		s.enqueueExec(execJob{height: node.Round, txs: p.txs, appHeight: p.appHeight, appHash: p.appHash, proposer: p.proposer,
+			extensions: p.extensions})
+		s.applyParamChanges(node.Round, p.txs)
+		s.applyKeyRegistrations(node.Round, p.txs)
+		s.applyAdmissions(node.Round, p.txs)
+	}
+	s.pruneVotes(node.Round)
+	s.pruneFetches(node.Round)
+	s.onCommitSLA(node.Round)
+	s.markCommit(node.Round)
+	if s.cfg.UpgradeHeight > 0 && node.Round >= s.cfg.UpgradeHeight {
+		s.haltForUpgrade()
+	}
+}
+
+// nextPayload asks the proposal provider for the payload if it's set, the mempool otherwise.
+func (s *State) nextPayload(round int64) ([][]byte, error) {
+	if s.provider == nil {
+		return s.buildPayload(round), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.ProposalDeadline)
+	defer cancel()
+
+	type result struct {
+		txs [][]byte
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		txs, err := s.provider(ctx, round)
+		ch <- result{txs, err}
+	}()
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			return nil, r.err
+		}
+		if err := s.checkPayload(round, r.txs); err != nil {
+			return nil, fmt.Errorf("invalid txs from the proposal provider, err: %w", err)
+		}
+		return r.txs, nil
+	case <-ctx.Done():
+		return nil, ErrProviderDeadline
+	}
+}
+
+// buildPayload reaps txs from the mempool for a new proposal, txs which have been committed
+// or packed by the uncommitted ancestors on the high branch are skipped, so that a failed view
+// won't make us pack the same tx twice.
+func (s *State) buildPayload(round int64) [][]byte {
+	if s.mempool == nil {
+		return nil
+	}
+	p := s.ParamsAt(round)
+	inflight := make(map[string]struct{})
+	for _, n := range s.tree.HighBranch() {
+		if p, ok := s.payloads[n.ID]; ok {
+			for _, tx := range p.txs {
+				inflight[mempool.Tx(tx).Key()] = struct{}{}
+			}
+		}
+	}
+	var txs [][]byte
+	size := 0
+	for _, tx := range s.mempool.ReapMaxTxs(-1) {
+		if len(txs) >= p.MaxBlockTxs {
+			break
+		}
+		if len(tx) > p.MaxTxBytes || s.mempool.Committed(tx) {
+			continue
+		}
+		if _, ok := inflight[tx.Key()]; ok {
+			continue
+		}
+		// keep the priority order, the tx is packed by the next proposal
+		if size+len(tx) > p.MaxBlockBytes {
+			break
+		}
+		size += len(tx)
+		txs = append(txs, tx)
+	}
+	return txs
+}
+
+// pruneVotes garbage-collects the votes which are older than the committed round.
+func (s *State) pruneVotes(committedRound int64) {
+	if s.wal != nil {
+		write := func() error { return s.wal.Write(EndHeightMessage{Height: committedRound}) }
+		if err := s.handleFailure(FailureWAL, true, write(), write); err != nil {
+			s.log.Error("write end height fail @ state.pruneVotes, round: %d, err: %v", committedRound, err)
+		} else if err := s.wal.Truncate(committedRound); err != nil {
+			s.log.Warn("truncate wal fail @ state.pruneVotes, round: %d, err: %v", committedRound, err)
+		}
+	}
+	s.voteSet.Prune(committedRound)
+}
+
+func (s *State) publish(e libs.Event) {
+	if s.eventBus == nil {
+		return
+	}
+	s.eventBus.Publish(e)
+}
+
 // GetNextID tries to simulate the data encapsulation.
 func (s *State) GetNextID() ([]byte, error) {
-	// trick, proposal rate limit
-	time.Sleep(2 * time.Second)
+	if !s.cfg.InstantFinality {
+		// trick, proposal rate limit
+		time.Sleep(2 * time.Second)
+	}
 	id := libs.GenRandomID()
 	return []byte(fmt.Sprintf("%d", id)), nil
 }
 
-func (s *State) getTimeoutID(round int64, index int64) []byte {
+func timeoutID(round int64, index int64) []byte {
 	return []byte(fmt.Sprintf("tmo_%d_%d", round, index))
 }
 
 // ----------------------------------------------------------------
 type ConsensusConfig struct {
+	// ChainID identifies the consensus instance, instances of different chains
+	// can share one switch, empty means the default chain.
+	ChainID string
+	// ForkID separates the fork of the chain after an upgrade, the msgs are signed in the domain
+	// of the fork and the ones of the other forks are refused, empty means no fork.
+	ForkID          string
 	StartRound      int64
 	StartTimeoutIdx int64
 	StartID         string
 	StartValue      []byte
 	StartValidators []PeerID
+	// StartKeys are the consensus keys of the validators registered in the genesis, they are rotated
+	// by the committed KeyRegistration txs, the msgs of a registered validator must be signed by its key.
+	StartKeys []ValidatorKey
+	// GenesisTime is when a new chain begins its first view, the host started earlier connects and
+	// syncs the peers but idles until then, zero begins the first view a RoundTimeout after starting.
+	GenesisTime time.Time
+	// WALPath is the dir of the write-ahead log, wal is disabled when it's empty.
+	WALPath string
+	// WALFlush decides when the wal records are synced, votes are always synced.
+	WALFlush WALFlushPolicy
+	// ResultsPath is the dir of the executed block results, they are not persisted when it's empty.
+	ResultsPath string
+	// BlocksPath is the dir of the committed blocks and their QCs, they are not persisted when it's empty.
+	BlocksPath string
+	// ScrubInterval re-reads a stored block every interval in the background and reports the corrupted ones,
+	// which are repaired from the peers by the ObserverReactor, 0 disables the scrubber.
+	ScrubInterval time.Duration
+	// OutboxPath is the dir of the blocks which haven't been delivered to the commit sinks.
+	OutboxPath string
+	// CommitWebhooks are the urls which every committed block is posted to, see WebhookSink.
+	CommitWebhooks []string
+	// Observe follows the chain from the peers on the observer channel instead of the consensus,
+	// ObserverRate limits the blocks served to every subscriber per second, see ObserverReactor.
+	Observe      bool
+	ObserverRate int
+	// MaxBlockTxs is the max number of txs packed into a proposal, it's changed at runtime
+	// by the committed ParamChange txs as well as the other consensus params, see State.ParamsAt.
+	MaxBlockTxs int
+	// MaxBlockBytes and MaxTxBytes bound the total size of the txs of a proposal and the size of
+	// each tx, 512KB and 64KB by default, the proposals exceeding them are rejected by the validators.
+	MaxBlockBytes int
+	MaxTxBytes    int
+	// RoundTimeout is how long a round lasts without a proposal, TimeoutT by default.
+	RoundTimeout time.Duration
+	// EpochLength is the number of rounds of an epoch, the param changes take effect at the boundaries.
+	EpochLength int64
+	// MaxClockSkew is how far the proposal timestamps may be ahead of the local clock, 10s by default,
+	// the host doesn't vote for the proposals beyond it, see State.checkTimestamp.
+	MaxClockSkew time.Duration
+	// FlushTimeout bounds how long Stop flushes the msgs queued by the host, 2s by default, see State.flush.
+	FlushTimeout time.Duration
+	// ProposalDeadline is how long the leader waits for the proposal provider.
+	ProposalDeadline time.Duration
+	// VoteGrace is how long the leader keeps collecting the votes once the quorum is reached, so that
+	// the QC formed by it is certified by the signed votes arriving meanwhile as well, see QuorumCert.Certify.
+	// The QC is formed at once if all the validators have voted, 0 forms it as soon as the quorum is reached,
+	// it's capped by MaxVoteGrace.
+	VoteGrace time.Duration
+	// MinBlockInterval spaces the proposals of the host, and thus the committed blocks,
+	// at least the interval apart, 0 means proposing as fast as possible.
+	MinBlockInterval time.Duration
+	// InstantFinality skips the fixed proposal rate limit of GetNextID,
+	// the proposals are then spaced by MinBlockInterval only.
+	InstantFinality bool
+	// HashScheme hashes the ids of the proposals of the host, sha256 by default, legacy keeps the random ids
+	// of GetNextID. The ids of the received proposals and the stored blocks are verified by their own schemes,
+	// see types.BlockID. The legacy ids of the received proposals are rejected unless the host keeps them
+	// or the rounds are below HashActivationRound, e.g. the chains upgraded from the legacy ids.
+	HashScheme          string
+	HashActivationRound int64
+	// MaxViewChanges and CommitSLA raise the sla alarms when the consecutive view changes
+	// or the commit latency exceed them, 0 disables the alarm.
+	MaxViewChanges int
+	CommitSLA      time.Duration
+	// StatusInterval is how often the host gossips its status, 1s by default.
+	StatusInterval time.Duration
+	// DowntimeWindow is the number of the latest QCs over which the participation of the validators
+	// is tracked, 0 disables it, a validator missing from more than MaxMissedRatio (0.5 by default)
+	// of them is reported down, see State.OnDowntime.
+	DowntimeWindow int
+	MaxMissedRatio float64
+	// UpgradeHeight halts the consensus once the block of the height, or the last one before it,
+	// is committed, 0 disables it, UpgradeInfo is printed with the upgrade instructions,
+	// e.g. the version or the download url of the new binary.
+	UpgradeHeight int64
+	UpgradeInfo   string
+	// MaxVoteExtensionBytes bounds the application data attached to a vote, 1KB by default, see VoteExtender.
+	MaxVoteExtensionBytes int
+	// ExecDepth is the number of committed blocks which may wait for the executor, 4 by default,
+	// the host stops voting once the execution falls further behind.
+	ExecDepth int
+	// FailurePolicies decide whether the wal, storage and executor failures are ignored, retried,
+	// degrade the host to serving the reads only, or halt the node, they are ignored by default.
+	FailurePolicies map[FailureKind]FailurePolicy
+	// DiskProbeInterval retries the writes of the wal and the stores failed for the disk, the host
+	// stops voting until they pass, DefaultDiskProbeInterval by default.
+	DiskProbeInterval time.Duration
+	// EjectMisbehaving is the defensive mode: the validators caught equivocating are excluded locally,
+	// their votes and proposals are ignored until the EjectionJudge rejects the ejection at an epoch boundary.
+	EjectMisbehaving bool
+	// Flags are the features enabled on the node, the experimental subsystems check theirs, nil enables none.
+	Flags *libs.FeatureFlags
+}
+
+// RoundState is a snapshot of the state machine exposed to the users.
+type RoundState struct {
+	ChainID        string `json:"chain_id"`
+	Round          int64  `json:"round"`
+	CommittedRound int64  `json:"committed_round"`
+	Leader         PeerID `json:"leader"`
+	// Phases are the percentiles of the phases of the latest committed blocks, see PhaseTiming.
+	Phases []PhaseTiming `json:"phases,omitempty"`
+}
+
+// Diagnostics is the consensus part of a crash dump.
+type Diagnostics struct {
+	RoundState   RoundState                    `json:"round_state"`
+	PendingVotes map[int64]map[string][]PeerID `json:"pending_votes"`
+	WAL          []*TimedWALMessage            `json:"wal,omitempty"`
+	WALError     string                        `json:"wal_error,omitempty"`
+}
+
+type pendingPayload struct {
+	round int64
+	txs   [][]byte
+	// the state hash claimed by the proposer, see types.ProposalMsg
+	appHeight int64
+	appHash   []byte
+	proposer  string
+	// unix seconds claimed by the proposer
+	timestamp int64
+	// the vote extensions certifying the parent, delivered along with the block,
+	// and the signed votes carrying them which the id of the block commits to
+	extensions       []VoteExtension
+	signedExtensions [][]byte
 }
@@ -0,0 +1,416 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/mempool"
+	"github.com/aucusaga/gohotstuff/storage"
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+func TestAdoptHighQC(t *testing.T) {
+	root, _ := NewDefaultQuorumCert("a", nil, 0, []byte("root"), 0, nil)
+	rootValue, _ := root.Serialize()
+	tree, err := NewQCTree("a", 0, "root", rootValue, nil, nil, logs.NewLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	qc1, _ := NewDefaultQuorumCert("a", nil, 1, []byte("x"), 0, []byte("root"))
+	qc2, _ := NewDefaultQuorumCert("a", nil, 2, []byte("y"), 1, []byte("x"))
+	qc3, _ := NewDefaultQuorumCert("a", nil, 3, []byte("z"), 2, []byte("y"))
+	for _, qc := range []QuorumCert{qc1, qc2} {
+		if err := tree.ExecuteNInsert(qc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := &State{tree: tree, log: logs.NewLogger()}
+	highRound := func() int64 {
+		round, _, _ := s.tree.GetCurrentHighQC().Proposal()
+		return round
+	}
+	if highRound() != 1 {
+		t.Fatalf("unexpected high qc: %s", s.tree.GetCurrentHighQC().String())
+	}
+
+	v2, _ := qc2.Serialize()
+	v3, _ := qc3.Serialize()
+	// the qc must match the parent proposal of the timeout
+	if err := s.adoptHighQC(TimeoutMsg(4, 1, []byte("x"), v2, 0)); err != ErrHighQCMismatch {
+		t.Errorf("want mismatch err, has: %v", err)
+	}
+	// the block of round 3 hasn't been received
+	if err := s.adoptHighQC(TimeoutMsg(4, 3, []byte("z"), v3, 0)); err == nil {
+		t.Errorf("unknown block should not be adopted")
+	}
+	if highRound() != 1 {
+		t.Errorf("high qc should be untouched, has: %d", highRound())
+	}
+	if err := s.adoptHighQC(&types.TimeoutMsg{Round: 4, ParentRound: 2, ParentID: []byte("y"), HighQC: v2}); err != nil {
+		t.Fatal(err)
+	}
+	if highRound() != 2 {
+		t.Errorf("want the fresher high qc, has: %d", highRound())
+	}
+}
+
+func TestProcessCommit(t *testing.T) {
+	root, _ := NewDefaultQuorumCert("a", nil, 0, []byte("root"), 0, nil)
+	rootValue, _ := root.Serialize()
+	tree, err := NewQCTree("a", 0, "root", rootValue, nil, nil, logs.NewLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentRound, parentID := int64(0), []byte("root")
+	insert := func(round int64, id string) {
+		qc, _ := NewDefaultQuorumCert("a", nil, round, []byte(id), parentRound, parentID)
+		if err := tree.ExecuteNInsert(qc); err != nil {
+			t.Fatal(err)
+		}
+		parentRound, parentID = round, []byte(id)
+	}
+	insert(1, "w")
+	insert(2, "x")
+	// no 3-chain has been formed
+	if committed, err := tree.ProcessCommit("root"); committed || err != nil {
+		t.Errorf("want nothing committed, has: %v, err: %v", committed, err)
+	}
+	// the qc of each node certifies its parent, the 3-chain of w is certified by the qc of v
+	insert(3, "y")
+	insert(4, "z")
+	insert(5, "v")
+	if committed, err := tree.ProcessCommit("x"); committed || err != nil {
+		t.Errorf("want nothing committed by another key, has: %v, err: %v", committed, err)
+	}
+	if committed, err := tree.ProcessCommit("w"); !committed || err != nil {
+		t.Fatalf("want the commit node committed, has: %v, err: %v", committed, err)
+	}
+	if round, _, _ := tree.GetCurrentRoot().Proposal(); round != 1 {
+		t.Errorf("want the commit node as the root, has: %d", round)
+	}
+}
+
+type heightExecutor struct {
+	executed []int64
+}
+
+func (e *heightExecutor) Execute(height int64, txs [][]byte) ([]types.TxResult, error) {
+	e.executed = append(e.executed, height)
+	results := make([]types.TxResult, len(txs))
+	for i := range txs {
+		results[i].GasUsed = int64(len(txs[i]))
+	}
+	return results, nil
+}
+
+func (e *heightExecutor) Query(path string, data []byte, height int64) ([]byte, []byte, error) {
+	return []byte(path), []byte{byte(height)}, nil
+}
+
+func TestQuery(t *testing.T) {
+	s := &State{log: logs.NewLogger()}
+	if _, err := s.Query("/key", nil, 0); err != ErrNoExecutor {
+		t.Errorf("want no executor err, has: %v", err)
+	}
+	e := &heightExecutor{}
+	if err := s.SetExecutor(e); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetExecutor(e); err != ErrComponentsOccupied {
+		t.Errorf("want occupied err, has: %v", err)
+	}
+	s.committedRound = 5
+	s.execute(5, nil, nil)
+	if len(e.executed) != 1 || e.executed[0] != 5 {
+		t.Errorf("unexpected executed heights: %v", e.executed)
+	}
+
+	res, err := s.Query("/key", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Height != 5 || string(res.Value) != "/key" || res.Proof[0] != 5 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	if res, err = s.Query("/key", nil, 3); err != nil || res.Height != 3 {
+		t.Errorf("historical height should be served, res: %+v, err: %v", res, err)
+	}
+	if _, err := s.Query("/key", nil, 6); !errors.Is(err, ErrHeightNotCommitted) {
+		t.Errorf("want not committed err, has: %v", err)
+	}
+}
+
+func TestBlockResults(t *testing.T) {
+	results, err := storage.NewResultStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &State{log: logs.NewLogger()}
+	if _, err := s.BlockResults(0); err != ErrNoResultStore {
+		t.Errorf("want no result store err, has: %v", err)
+	}
+	s.results = results
+	if err := s.SetExecutor(&heightExecutor{}); err != nil {
+		t.Fatal(err)
+	}
+	s.committedRound = 2
+	s.execute(2, [][]byte{[]byte("a"), []byte("bcd")}, nil)
+
+	res, err := s.BlockResults(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Height != 2 || len(res.Txs) != 2 || res.Txs[1].GasUsed != 3 {
+		t.Errorf("unexpected results: %+v", res)
+	}
+	if _, err := s.BlockResults(1); !errors.Is(err, storage.ErrResultsNotFound) {
+		t.Errorf("want not found err, has: %v", err)
+	}
+	if _, err := s.BlockResults(3); !errors.Is(err, ErrHeightNotCommitted) {
+		t.Errorf("want not committed err, has: %v", err)
+	}
+}
+
+func TestPayloadLimits(t *testing.T) {
+	root, _ := NewDefaultQuorumCert("a", nil, 0, []byte("root"), 0, nil)
+	rootValue, _ := root.Serialize()
+	tree, err := NewQCTree("a", 0, "root", rootValue, nil, nil, logs.NewLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp := mempool.NewDefaultMempool(nil, nil)
+	for _, tx := range []string{"aaaa", "bbbbbbbbb", "cc", "ddd", "ee"} {
+		if err := mp.CheckTx(mempool.Tx(tx)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := &State{
+		tree:     tree,
+		mempool:  mp,
+		payloads: make(map[string]pendingPayload),
+		cfg:      &ConsensusConfig{MaxBlockTxs: 3, MaxBlockBytes: 8, MaxTxBytes: 4},
+		log:      logs.NewLogger(),
+	}
+	// the oversize tx is skipped, and the reaping stops at the first tx exceeding the budget
+	txs := s.buildPayload(1)
+	if len(txs) != 2 || string(txs[0]) != "aaaa" || string(txs[1]) != "cc" {
+		t.Errorf("unexpected payload: %q", txs)
+	}
+	if err := s.checkPayload(1, txs); err != nil {
+		t.Errorf("built payload should be valid, err: %v", err)
+	}
+
+	for _, txs := range [][][]byte{
+		{[]byte("a"), []byte("b"), []byte("c"), []byte("d")},
+		{[]byte("aaaaa")},
+		{[]byte("aaaa"), []byte("bbbb"), []byte("c")},
+	} {
+		if err := s.checkPayload(1, txs); !errors.Is(err, ErrProposalTooLarge) {
+			t.Errorf("want too large err, txs: %q, has: %v", txs, err)
+		}
+	}
+}
+
+func TestProposalProvider(t *testing.T) {
+	root, _ := NewDefaultQuorumCert("a", nil, 0, []byte("root"), 0, nil)
+	rootValue, _ := root.Serialize()
+	tree, err := NewQCTree("a", 0, "root", rootValue, nil, nil, logs.NewLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp := mempool.NewDefaultMempool(nil, nil)
+	if err := mp.CheckTx(mempool.Tx("pool")); err != nil {
+		t.Fatal(err)
+	}
+	s := &State{
+		tree:     tree,
+		mempool:  mp,
+		payloads: make(map[string]pendingPayload),
+		cfg:      &ConsensusConfig{MaxBlockTxs: 3, MaxBlockBytes: 8, MaxTxBytes: 4, ProposalDeadline: 50 * time.Millisecond},
+		log:      logs.NewLogger(),
+	}
+	// the mempool is bypassed once the provider is set
+	s.SetProposalProvider(func(ctx context.Context, round int64) ([][]byte, error) {
+		return [][]byte{[]byte(fmt.Sprintf("ext%d", round))}, nil
+	})
+	if txs, err := s.nextPayload(2); err != nil || len(txs) != 1 || string(txs[0]) != "ext2" {
+		t.Errorf("want the payload of the provider, has: %q, err: %v", txs, err)
+	}
+
+	canceled := make(chan struct{})
+	s.SetProposalProvider(func(ctx context.Context, round int64) ([][]byte, error) {
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	})
+	if _, err := s.nextPayload(2); !errors.Is(err, ErrProviderDeadline) {
+		t.Errorf("want the deadline exceeded, has: %v", err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(3 * time.Second):
+		t.Error("want the ctx of the provider canceled")
+	}
+
+	// the payload of the provider is checked as well as the one of the peers
+	s.SetProposalProvider(func(ctx context.Context, round int64) ([][]byte, error) {
+		return [][]byte{[]byte("aaaaa")}, nil
+	})
+	if _, err := s.nextPayload(2); !errors.Is(err, ErrProposalTooLarge) {
+		t.Errorf("want too large err, has: %v", err)
+	}
+	fail := errors.New("sequencer is down")
+	s.SetProposalProvider(func(ctx context.Context, round int64) ([][]byte, error) {
+		return nil, fail
+	})
+	if _, err := s.nextPayload(2); err != fail {
+		t.Errorf("want the err of the provider, has: %v", err)
+	}
+}
+
+func TestFirstTimeoutWaitsForGenesis(t *testing.T) {
+	start := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	cfg := &ConsensusConfig{StartRound: 5, RoundTimeout: 3 * time.Second, GenesisTime: start.Add(time.Minute)}
+	s := &State{
+		cfg:            cfg,
+		params:         newParamsHistory(cfg),
+		committedRound: cfg.StartRound,
+		clock:          libs.NewManualClock(start),
+		log:            logs.NewLogger(),
+	}
+	if d := s.firstTimeout(6); d != time.Minute {
+		t.Errorf("the new chain should idle until the genesis time, has: %v", d)
+	}
+	// a restarted chain or a chain started late begins at once
+	if d := s.firstTimeout(8); d != 3*time.Second {
+		t.Errorf("want the round timeout for a restarted chain, has: %v", d)
+	}
+	s.clock.(*libs.ManualClock).Advance(2 * time.Minute)
+	if d := s.firstTimeout(6); d != 3*time.Second {
+		t.Errorf("want the round timeout after the genesis time, has: %v", d)
+	}
+	s.cfg.GenesisTime = time.Time{}
+	if d := s.firstTimeout(6); d != 3*time.Second {
+		t.Errorf("want the round timeout without the genesis time, has: %v", d)
+	}
+}
+
+func TestStepHooks(t *testing.T) {
+	clock := libs.NewManualClock(time.Unix(100, 0))
+	s := &State{
+		pacemaker:      NewDefaultPacemaker(2),
+		committedRound: 1,
+		clock:          clock,
+		log:            logs.NewLogger(),
+	}
+	var steps []string
+	var infos []StepInfo
+	record := func(name string) StepHook {
+		return func(info StepInfo) {
+			steps = append(steps, name)
+			infos = append(infos, info)
+		}
+	}
+	s.BeforeStep(record("before"))
+	s.AfterStep(record("after"))
+	s.AfterStep(record("after2"))
+
+	s.runStep(stepName(&types.VoteMsg{Round: 3}), func() {
+		steps = append(steps, "step")
+		clock.Advance(5 * time.Millisecond)
+		s.committedRound = 2
+	})
+	if fmt.Sprint(steps) != "[before step after after2]" {
+		t.Fatalf("want the hooks in the order registered around the step, has: %v", steps)
+	}
+	// the after hooks see the effects of the step
+	want := []StepInfo{
+		{Step: VoteProcess, Round: 3, Height: 1},
+		{Step: VoteProcess, Round: 3, Height: 2, Duration: 5 * time.Millisecond},
+		{Step: VoteProcess, Round: 3, Height: 2, Duration: 5 * time.Millisecond},
+	}
+	for i, info := range infos {
+		if info != want[i] {
+			t.Errorf("hook %d, want: %+v, has: %+v", i, want[i], info)
+		}
+	}
+	if s.view != 3 {
+		t.Errorf("want the view of the step kept, has: %d", s.view)
+	}
+
+	// a blocking hook pauses the state machine until it's released
+	release, done := make(chan struct{}), make(chan struct{})
+	s.BeforeStep(func(info StepInfo) { <-release })
+	go s.runStep(LocalTimeoutProcess, func() { close(done) })
+	select {
+	case <-done:
+		t.Fatal("want the step paused by the hook")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("want the step run once the hook returns")
+	}
+}
+
+// sendPeer records the msgs sent to it.
+type sendPeer struct {
+	id   string
+	sent [][]byte
+}
+
+func (p *sendPeer) PeerID() string { return p.id }
+
+func (p *sendPeer) Send(chID int32, msgBytes []byte) bool {
+	p.sent = append(p.sent, msgBytes)
+	return true
+}
+
+func TestPeerCallbacks(t *testing.T) {
+	s := &State{
+		host:       "a",
+		p2p:        &stubSwitch{},
+		pacemaker:  NewDefaultPacemaker(2),
+		election:   NewDefaultElection(0, []PeerID{"a", "b", "c"}),
+		timeoutSet: NewTimeoutSet(0, 0),
+		channel:    libs.ConsensusChannel,
+		log:        logs.NewLogger(),
+	}
+	validator, other := &sendPeer{id: "b"}, &sendPeer{id: "x"}
+	// nothing has been proposed yet
+	s.AddPeer(validator)
+	if len(validator.sent) != 0 {
+		t.Fatalf("want nothing resent, has: %d", len(validator.sent))
+	}
+
+	s.lastProposal.round, s.lastProposal.msg = 3, []byte("proposal")
+	s.AddPeer(validator)
+	s.AddPeer(other)
+	if len(validator.sent) != 1 || string(validator.sent[0]) != "proposal" {
+		t.Errorf("want the proposal of the current round resent to the new validator, has: %q", validator.sent)
+	}
+	if len(other.sent) != 0 {
+		t.Errorf("want nothing resent to a non-validator, has: %q", other.sent)
+	}
+	// the proposal of a passed round is useless to the peer
+	s.pacemaker = NewDefaultPacemaker(3)
+	late := &sendPeer{id: "c"}
+	s.AddPeer(late)
+	if len(late.sent) != 0 {
+		t.Errorf("want nothing resent after the round, has: %q", late.sent)
+	}
+
+	// the msgs of the removed peer are no longer verified
+	s.verifier("b")
+	s.RemovePeer(validator, "stopped")
+	if _, ok := s.verifiers.m["b"]; ok {
+		t.Error("want the verifier of the removed peer stopped")
+	}
+}
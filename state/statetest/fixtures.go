@@ -0,0 +1,111 @@
+// Package statetest provides the fixtures of the consensus-level tests for the applications
+// embedding gohotstuff: the builders of the validator sets, blocks, votes and QCs, and a Network
+// which drives several state machines in memory by the script of the test.
+package statetest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/aucusaga/gohotstuff/crypto"
+	"github.com/aucusaga/gohotstuff/state"
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+const (
+	// GenesisID and GenesisValue are the root of the chains built by the kit, see Genesis.
+	GenesisID    = "lets_run_hotstuff"
+	GenesisValue = `{"round":0,"id":"bGV0c19ydW5faG90c3R1ZmY=","sender":"","signs":{}}`
+)
+
+// Validator is a member of the validator set along with its consensus key.
+type Validator struct {
+	ID     state.PeerID
+	Crypto *crypto.DefaultCryptoClient
+}
+
+// NewValidator generates the consensus key of the validator id.
+func NewValidator(id state.PeerID) (Validator, error) {
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return Validator{}, err
+	}
+	return Validator{ID: id, Crypto: &crypto.DefaultCryptoClient{SK: sk, PK: &sk.PublicKey}}, nil
+}
+
+// NewValidatorSet generates n validators named v0, v1... in the order of the leaders.
+func NewValidatorSet(n int) ([]Validator, error) {
+	vs := make([]Validator, 0, n)
+	for i := 0; i < n; i++ {
+		v, err := NewValidator(state.PeerID(fmt.Sprintf("v%d", i)))
+		if err != nil {
+			return nil, err
+		}
+		vs = append(vs, v)
+	}
+	return vs, nil
+}
+
+// IDs returns the ids of the validators in order.
+func IDs(vs []Validator) []state.PeerID {
+	ids := make([]state.PeerID, 0, len(vs))
+	for _, v := range vs {
+		ids = append(ids, v.ID)
+	}
+	return ids
+}
+
+// Genesis returns the config of the chain started by the validators from the root of GenesisID,
+// the proposals aren't rate limited so that the tests don't sleep.
+func Genesis(chainID string, vs []Validator) *state.ConsensusConfig {
+	return &state.ConsensusConfig{
+		ChainID:         chainID,
+		StartID:         GenesisID,
+		StartValue:      []byte(GenesisValue),
+		StartValidators: IDs(vs),
+		InstantFinality: true,
+	}
+}
+
+// QC builds the serialized quorum cert of the block of round and id, which extends the block of
+// parentRound and parentID, e.g. the justify of a proposal.
+func QC(round int64, id []byte, parentRound int64, parentID []byte) ([]byte, error) {
+	qc, err := state.NewDefaultQuorumCert("", nil, round, id, parentRound, parentID)
+	if err != nil {
+		return nil, err
+	}
+	return qc.Serialize()
+}
+
+// Block builds the proposal of the block of round and id justified by the QC of its parent.
+func Block(proposer state.PeerID, round int64, id []byte, justify []byte, txs ...[]byte) *types.ProposalMsg {
+	p := state.ProposalMsg(round, id, justify, txs)
+	p.PeerID = string(proposer)
+	return p
+}
+
+// Vote builds the vote of the voter for the block of round and id, sent to the leader of the next round.
+func Vote(voter state.PeerID, round int64, id []byte, parentRound int64, parentID []byte, to state.PeerID) *types.VoteMsg {
+	v := state.VoteMsg(round, id, parentRound, parentID, string(to))
+	v.SendID = string(voter)
+	return v
+}
+
+// Timeout builds the timeout of the sender for the round, highQC is the serialized QC of its high block.
+func Timeout(sender state.PeerID, round int64, highRound int64, highID []byte, highQC []byte, idx int64) *types.TimeoutMsg {
+	t := state.TimeoutMsg(round, highRound, highID, highQC, idx)
+	t.SendID = string(sender)
+	return t
+}
+
+// Sign encodes and signs the msg by the validator in the domain of the chain and the epoch,
+// the bytes are accepted by state.State.HandleFunc as if they were sent by the validator.
+func Sign(v Validator, chainID string, epoch int64, msg state.MsgInfo) ([]byte, error) {
+	msgbytes, err := state.DomainProtoFromConsMsg(msg, chainID, epoch)
+	if err != nil {
+		return nil, err
+	}
+	return v.Crypto.Sign(msgbytes)
+}
@@ -0,0 +1,378 @@
+package statetest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/state"
+	"github.com/aucusaga/gohotstuff/storage"
+)
+
+var (
+	ErrUnknownNode = errors.New("unknown node of the network")
+	ErrRunTimeout  = errors.New("condition isn't met before the timeout")
+)
+
+const (
+	// runPoll is how often Run delivers the pending msgs and checks its condition.
+	runPoll = time.Millisecond
+	// idlePolls is how many polls without any msg make the network idle.
+	idlePolls = 20
+)
+
+// Envelope is a msg sent by a node and held by the network until the script delivers or drops it.
+type Envelope struct {
+	From    state.PeerID
+	To      state.PeerID
+	Channel int32
+	Bytes   []byte
+}
+
+// Node is a validator running its state machine in the network.
+type Node struct {
+	Validator
+	State *state.State
+
+	mtx       sync.Mutex
+	committed []*storage.CommittedBlock
+	stopped   []string
+}
+
+// Committed returns the blocks committed by the node so far.
+func (n *Node) Committed() []*storage.CommittedBlock {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	return append([]*storage.CommittedBlock(nil), n.committed...)
+}
+
+// Height returns the height of the latest block committed by the node, -1 if there is none.
+func (n *Node) Height() int64 {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	if len(n.committed) == 0 {
+		return -1
+	}
+	return n.committed[len(n.committed)-1].Height
+}
+
+// Stopped returns the reasons of the peers stopped by the node, see libs.Switch.StopPeerForError.
+func (n *Node) Stopped() []string {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	return append([]string(nil), n.stopped...)
+}
+
+// Network connects the state machines of the validators in memory, the msgs sent by them are held
+// until the script of the test delivers or drops them, and the time moves on only by Advance.
+type Network struct {
+	Clock *libs.ManualClock
+	// IdleAdvance is how far Run moves the clock once the network is idle, i.e. all the nodes wait for
+	// their timeouts, the round timeout by default, 0 keeps the clock still.
+	IdleAdvance time.Duration
+
+	nodes   map[state.PeerID]*Node
+	order   []state.PeerID
+	pending []Envelope
+	// blocked[from][to] drops the msgs across the partitions as they are sent
+	blocked map[state.PeerID]map[state.PeerID]bool
+	// stopped are the nodes shut down by StopNode
+	stopped map[state.PeerID]bool
+	mtx     sync.Mutex
+	log     libs.Logger
+}
+
+// NewNetwork builds the state machine of every validator on the config, e.g. the one of Genesis,
+// configure is invoked with every state before it's started, it's optional.
+func NewNetwork(cfg *state.ConsensusConfig, vs []Validator, configure func(*Node) error) (*Network, error) {
+	net := &Network{
+		Clock:       libs.NewManualClock(time.Unix(0, 0)),
+		IdleAdvance: state.TimeoutT,
+		nodes:       make(map[state.PeerID]*Node),
+		blocked:     make(map[state.PeerID]map[state.PeerID]bool),
+		stopped:     make(map[state.PeerID]bool),
+		log:         logs.NewLogger(),
+	}
+	if cfg.RoundTimeout > 0 {
+		net.IdleAdvance = cfg.RoundTimeout
+	}
+	for _, v := range vs {
+		c := *cfg
+		ticker := state.NewClockTimeoutTicker(net.log, net.Clock)
+		smr, err := state.NewState(v.ID, v.Crypto, ticker, net.log, &c)
+		if err != nil {
+			return nil, fmt.Errorf("new state fail, validator: %s, err: %v", v.ID, err)
+		}
+		if err := smr.RegisterPaceMaker(state.NewDefaultPacemaker(c.StartRound)); err != nil {
+			return nil, err
+		}
+		if err := smr.RegisterElection(state.NewDefaultElection(c.StartRound, c.StartValidators)); err != nil {
+			return nil, err
+		}
+		if err := smr.RegisterSaftyrules(state.NewDefaultSafetyRules(smr)); err != nil {
+			return nil, err
+		}
+		smr.SetClock(net.Clock)
+		node := &Node{Validator: v, State: smr}
+		smr.OnCommitBlock(func(block *storage.CommittedBlock) {
+			node.mtx.Lock()
+			defer node.mtx.Unlock()
+			node.committed = append(node.committed, block)
+		})
+		smr.SetSwitch(&netSwitch{net: net, node: node})
+		if configure != nil {
+			if err := configure(node); err != nil {
+				return nil, err
+			}
+		}
+		net.nodes[v.ID] = node
+		net.order = append(net.order, v.ID)
+	}
+	return net, nil
+}
+
+// Start starts the state machines, the leader of the first round proposes once the msgs are delivered.
+func (net *Network) Start() {
+	for _, id := range net.order {
+		net.nodes[id].State.Start()
+	}
+}
+
+// Stop stops the state machines and waits for their routines.
+func (net *Network) Stop() {
+	net.mtx.Lock()
+	var running []state.PeerID
+	for _, id := range net.order {
+		if !net.stopped[id] {
+			running = append(running, id)
+			net.stopped[id] = true
+		}
+	}
+	net.mtx.Unlock()
+
+	for _, id := range running {
+		net.nodes[id].State.Stop()
+	}
+	for _, id := range running {
+		net.nodes[id].State.Wait()
+	}
+}
+
+// StopNode shuts down the state machine of the validator, the msgs it sends on the way out are kept,
+// the ones sent to it are dropped from now on.
+func (net *Network) StopNode(id state.PeerID) error {
+	node, err := net.Node(id)
+	if err != nil {
+		return err
+	}
+	net.mtx.Lock()
+	if net.stopped[id] {
+		net.mtx.Unlock()
+		return nil
+	}
+	net.stopped[id] = true
+	net.mtx.Unlock()
+
+	node.State.Stop()
+	node.State.Wait()
+	return nil
+}
+
+// Node returns the node of the validator.
+func (net *Network) Node(id state.PeerID) (*Node, error) {
+	node, ok := net.nodes[id]
+	if !ok {
+		return nil, fmt.Errorf("%w, id: %s", ErrUnknownNode, id)
+	}
+	return node, nil
+}
+
+// Nodes returns the nodes in the order of the validators.
+func (net *Network) Nodes() []*Node {
+	nodes := make([]*Node, 0, len(net.order))
+	for _, id := range net.order {
+		nodes = append(nodes, net.nodes[id])
+	}
+	return nodes
+}
+
+// Pending returns the msgs sent but neither delivered nor dropped yet.
+func (net *Network) Pending() []Envelope {
+	net.mtx.Lock()
+	defer net.mtx.Unlock()
+
+	return append([]Envelope(nil), net.pending...)
+}
+
+// Inject queues the msg as if it was sent by e.From, e.g. the msgs built and signed by the kit.
+func (net *Network) Inject(e Envelope) {
+	net.send(e)
+}
+
+// Deliver hands the pending msgs matched to their receivers in the order they were sent,
+// the unmatched ones are kept, match nil matches all. It returns the number of the delivered msgs.
+func (net *Network) Deliver(match func(Envelope) bool) int {
+	deliver := net.take(match)
+	for _, e := range deliver {
+		net.nodes[e.To].State.HandleFunc(e.Channel, e.Bytes)
+	}
+	return len(deliver)
+}
+
+// Drop discards the pending msgs matched, match nil matches all. It returns the number of the dropped msgs.
+func (net *Network) Drop(match func(Envelope) bool) int {
+	return len(net.take(match))
+}
+
+func (net *Network) take(match func(Envelope) bool) []Envelope {
+	net.mtx.Lock()
+	defer net.mtx.Unlock()
+
+	var taken, kept []Envelope
+	for _, e := range net.pending {
+		if net.stopped[e.To] {
+			continue
+		}
+		if match == nil || match(e) {
+			taken = append(taken, e)
+		} else {
+			kept = append(kept, e)
+		}
+	}
+	net.pending = kept
+	return taken
+}
+
+// Partition drops the msgs sent across the groups from now on, the nodes out of any group
+// are isolated from all the others.
+func (net *Network) Partition(groups ...[]state.PeerID) {
+	net.mtx.Lock()
+	defer net.mtx.Unlock()
+
+	group := make(map[state.PeerID]int)
+	for i, g := range groups {
+		for _, id := range g {
+			group[id] = i + 1
+		}
+	}
+	net.blocked = make(map[state.PeerID]map[state.PeerID]bool)
+	for _, from := range net.order {
+		net.blocked[from] = make(map[state.PeerID]bool)
+		for _, to := range net.order {
+			if from != to && (group[from] == 0 || group[from] != group[to]) {
+				net.blocked[from][to] = true
+			}
+		}
+	}
+}
+
+// Heal removes the partitions, the msgs dropped by them are lost.
+func (net *Network) Heal() {
+	net.mtx.Lock()
+	defer net.mtx.Unlock()
+
+	net.blocked = make(map[state.PeerID]map[state.PeerID]bool)
+}
+
+// Advance moves the clock of all the nodes forward, e.g. to fire the round timeouts.
+func (net *Network) Advance(d time.Duration) {
+	net.Clock.Advance(d)
+}
+
+// Run keeps delivering all the pending msgs until cond is met or the timeout of the wall clock expires,
+// the clock is moved by IdleAdvance once the network is idle, e.g. the very first round starts on
+// the timeouts of the validators.
+func (net *Network) Run(cond func() bool, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	idle := 0
+	for !cond() {
+		if time.Now().After(deadline) {
+			return ErrRunTimeout
+		}
+		if net.Deliver(nil) > 0 {
+			idle = 0
+			continue
+		}
+		if idle++; idle >= idlePolls && net.IdleAdvance > 0 {
+			idle = 0
+			net.Advance(net.IdleAdvance)
+		}
+		time.Sleep(runPoll)
+	}
+	return nil
+}
+
+func (net *Network) send(e Envelope) {
+	net.mtx.Lock()
+	defer net.mtx.Unlock()
+
+	if _, ok := net.nodes[e.To]; !ok || e.From == e.To || net.blocked[e.From][e.To] || net.stopped[e.To] {
+		return
+	}
+	// the switch recycles the msg bytes once they're sent
+	e.Bytes = append([]byte(nil), e.Bytes...)
+	net.pending = append(net.pending, e)
+}
+
+// netSwitch is the libs.Switch of a node, which sends the msgs into the network.
+type netSwitch struct {
+	net  *Network
+	node *Node
+}
+
+func (sw *netSwitch) Broadcast(chID int32, msgBytes []byte) {
+	for _, id := range sw.net.order {
+		sw.net.send(Envelope{From: sw.node.ID, To: id, Channel: chID, Bytes: msgBytes})
+	}
+}
+
+func (sw *netSwitch) BroadcastToValidators(chID int32, msgBytes []byte) {
+	sw.Broadcast(chID, msgBytes)
+}
+
+func (sw *netSwitch) SetValidatorSet(chID int32, set func() []string)        {}
+func (sw *netSwitch) SetMessageExpiry(chID int32, expired func([]byte) bool) {}
+func (sw *netSwitch) SetPullOnSlow(chID int32, pull func([]byte) bool)       {}
+
+func (sw *netSwitch) Send(peerID string, chID int32, msgBytes []byte) error {
+	if _, ok := sw.net.nodes[state.PeerID(peerID)]; !ok {
+		return fmt.Errorf("%w, id: %s", ErrUnknownNode, peerID)
+	}
+	sw.net.send(Envelope{From: sw.node.ID, To: state.PeerID(peerID), Channel: chID, Bytes: msgBytes})
+	return nil
+}
+
+// SendWithResult resolves once the msg is handed to the network, which delivers it unless it's partitioned.
+func (sw *netSwitch) SendWithResult(peerID string, chID int32, msgBytes []byte) <-chan error {
+	result := make(chan error, 1)
+	result <- sw.Send(peerID, chID, msgBytes)
+	return result
+}
+
+func (sw *netSwitch) GetP2PID(peerID string) (string, error) {
+	return peerID, nil
+}
+
+func (sw *netSwitch) StopPeerForError(peerID string, reason interface{}) {
+	sw.node.mtx.Lock()
+	defer sw.node.mtx.Unlock()
+	sw.node.stopped = append(sw.node.stopped, fmt.Sprintf("%s: %v", peerID, reason))
+}
+
+func (sw *netSwitch) PeerFeatures(peerID string) (libs.Features, bool) {
+	return 0, false
+}
+
+// Reactor returns the consensus of the node, the only reactor of the network.
+func (sw *netSwitch) Reactor(name string) (libs.Reactor, bool) {
+	if m, _, err := libs.ParseModule(name); err != nil || m != libs.ModuleConsensus {
+		return nil, false
+	}
+	return sw.node.State, true
+}
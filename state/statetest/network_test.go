@@ -0,0 +1,389 @@
+package statetest
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/state"
+	"github.com/aucusaga/gohotstuff/storage"
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+func TestNetworkCommits(t *testing.T) {
+	vs, err := NewValidatorSet(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	net, err := NewNetwork(Genesis("test", vs), vs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	net.Start()
+	defer net.Stop()
+
+	committed := func(height int64, nodes ...*Node) func() bool {
+		return func() bool {
+			for _, n := range nodes {
+				if n.Height() < height {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	nodes := net.Nodes()
+	if err := net.Run(committed(3, nodes...), 10*time.Second); err != nil {
+		t.Fatalf("the network should commit, heights: %d %d %d %d", nodes[0].Height(), nodes[1].Height(), nodes[2].Height(), nodes[3].Height())
+	}
+	for _, n := range nodes[1:] {
+		if string(n.Committed()[0].ID) != string(nodes[0].Committed()[0].ID) {
+			t.Errorf("the nodes diverge, %s: %s, %s: %s", nodes[0].ID, nodes[0].Committed()[0].ID, n.ID, n.Committed()[0].ID)
+		}
+	}
+
+	// the isolated node misses the blocks committed by the quorum
+	net.Partition(IDs(vs[:3]))
+	height := nodes[0].Height()
+	stale := nodes[3].Height()
+	// the rounds led by the isolated node time out
+	if err := net.Run(committed(height+3, nodes[:3]...), 10*time.Second); err != nil {
+		t.Fatalf("the quorum should commit without the isolated node, height: %d", nodes[0].Height())
+	}
+	if nodes[3].Height() > stale+1 {
+		t.Errorf("the isolated node should fall behind, height: %d", nodes[3].Height())
+	}
+}
+
+func TestNetworkCommitContinuity(t *testing.T) {
+	vs, err := NewValidatorSet(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	net, err := NewNetwork(Genesis("test", vs), vs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	net.Start()
+	defer net.Stop()
+
+	nodes := net.Nodes()
+	if err := net.Run(func() bool { return nodes[0].Height() >= 3 }, 10*time.Second); err != nil {
+		t.Fatalf("the network should commit, height: %d", nodes[0].Height())
+	}
+	// the rounds led by the isolated node break the 3-chains, their ancestors are committed implicitly
+	net.Partition(IDs(vs[:3]))
+	height := nodes[0].Height()
+	if err := net.Run(func() bool { return nodes[0].Height() >= height+8 }, 10*time.Second); err != nil {
+		t.Fatalf("the quorum should commit without the isolated node, height: %d", nodes[0].Height())
+	}
+	for _, n := range nodes[:3] {
+		var prev *storage.CommittedBlock
+		for _, b := range n.Committed() {
+			if prev != nil && (b.ParentRound != prev.Height || string(b.ParentID) != string(prev.ID)) {
+				t.Fatalf("%s skips the blocks between %d and %d, parent: %d", n.ID, prev.Height, b.Height, b.ParentRound)
+			}
+			prev = b
+		}
+	}
+}
+
+func TestNetworkDowntime(t *testing.T) {
+	vs, err := NewValidatorSet(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := Genesis("test", vs)
+	cfg.DowntimeWindow, cfg.MaxMissedRatio = 4, 0.5
+	net, err := NewNetwork(cfg, vs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	net.Start()
+	defer net.Stop()
+
+	// the votes of the isolated node are missing from the QCs of the quorum
+	nodes := net.Nodes()
+	net.Partition(IDs(vs[:3]))
+	if err := net.Run(func() bool { return nodes[0].Height() >= 8 }, 10*time.Second); err != nil {
+		t.Fatalf("the quorum should commit without the isolated node, height: %d", nodes[0].Height())
+	}
+	for _, p := range nodes[0].State.Participation() {
+		isolated := p.Validator == vs[3].ID
+		if p.Down != isolated || (!isolated && p.Signed == 0) {
+			t.Errorf("unexpected participation: %+v", p)
+		}
+	}
+}
+
+func TestNetworkVoteGrace(t *testing.T) {
+	vs, err := NewValidatorSet(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := Genesis("test", vs)
+	cfg.VoteGrace = state.MaxVoteGrace
+	net, err := NewNetwork(cfg, vs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	net.Start()
+	defer net.Stop()
+
+	signs := func(b *storage.CommittedBlock) int {
+		qc, err := state.DefaultDeserialize(b.QC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(qc.(state.DefaultQuorumCert).Signs)
+	}
+	// the leaders wait for the last vote, which certifies their QCs as well
+	nodes := net.Nodes()
+	if err := net.Run(func() bool { return nodes[0].Height() >= 5 }, 10*time.Second); err != nil {
+		t.Fatalf("the network should commit, height: %d", nodes[0].Height())
+	}
+	for _, b := range nodes[0].Committed()[1:] {
+		if n := signs(b); n != len(vs) {
+			t.Errorf("want the QC of %d signed by all the validators, has: %d", b.Height, n)
+		}
+	}
+
+	// the isolated validator never votes, the QCs are formed once the graces end
+	net.Partition(IDs(vs[:3]))
+	net.IdleAdvance = cfg.VoteGrace
+	height := nodes[0].Height()
+	if err := net.Run(func() bool { return nodes[0].Height() >= height+5 }, 10*time.Second); err != nil {
+		t.Fatalf("the quorum should commit once the graces end, height: %d", nodes[0].Height())
+	}
+}
+
+func TestFixtures(t *testing.T) {
+	vs, err := NewValidatorSet(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	justify, err := QC(0, []byte(GenesisID), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := Block(vs[0].ID, 1, []byte("x"), justify, []byte("tx"))
+	if block.PeerID != "v0" || len(block.Txs) != 1 {
+		t.Errorf("unexpected block: %s", block.String())
+	}
+	vote := Vote(vs[1].ID, 1, []byte("x"), 0, []byte(GenesisID), vs[0].ID)
+	msg, err := Sign(vs[1], "test", 0, vote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := vs[0].Crypto.Verify(nil, nil, msg); !ok || err != nil {
+		t.Errorf("the signed vote should be verified, err: %v", err)
+	}
+}
+
+// priceOracle attaches the id of the voter to its votes and records the extensions delivered with the blocks.
+type priceOracle struct {
+	state.NopExecutor
+	id state.PeerID
+
+	mtx       sync.Mutex
+	delivered map[int64][]state.VoteExtension
+}
+
+func (o *priceOracle) ExtendVote(round int64, id []byte) ([]byte, error) {
+	return []byte("price@" + string(o.id)), nil
+}
+
+func (o *priceOracle) VerifyVoteExtension(validator state.PeerID, round int64, id []byte, ext []byte) error {
+	if string(ext) != "price@"+string(validator) {
+		return fmt.Errorf("unexpected extension of %s: %s", validator, ext)
+	}
+	return nil
+}
+
+func (o *priceOracle) ExecuteExtended(height int64, txs [][]byte, exts []state.VoteExtension) ([]types.TxResult, error) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	o.delivered[height] = exts
+	return make([]types.TxResult, len(txs)), nil
+}
+
+func (o *priceOracle) extensions() map[int64][]state.VoteExtension {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	m := make(map[int64][]state.VoteExtension, len(o.delivered))
+	for h, exts := range o.delivered {
+		m[h] = exts
+	}
+	return m
+}
+
+func TestNetworkVoteExtensions(t *testing.T) {
+	vs, err := NewValidatorSet(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oracles := make(map[state.PeerID]*priceOracle)
+	net, err := NewNetwork(Genesis("test", vs), vs, func(n *Node) error {
+		o := &priceOracle{id: n.ID, delivered: make(map[int64][]state.VoteExtension)}
+		oracles[n.ID] = o
+		if err := n.State.RegisterVoteExtender(o); err != nil {
+			return err
+		}
+		return n.State.SetExecutor(o)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	net.Start()
+	defer net.Stop()
+
+	nodes := net.Nodes()
+	executed := func() bool {
+		for _, n := range nodes {
+			if n.State.ExecutedHeight() < 4 {
+				return false
+			}
+		}
+		return true
+	}
+	if err := net.Run(executed, 10*time.Second); err != nil {
+		t.Fatalf("the network should execute, height: %d", nodes[0].State.ExecutedHeight())
+	}
+
+	// every validator is delivered the same extensions of the quorum certifying the parent
+	want := oracles[nodes[0].ID].extensions()
+	extended := 0
+	for height, exts := range want {
+		if len(exts) == 0 {
+			continue
+		}
+		extended++
+		if len(exts) < 3 {
+			t.Errorf("want the extensions of the quorum at %d, has: %d", height, len(exts))
+		}
+		for _, ext := range exts {
+			if string(ext.Extension) != "price@"+string(ext.Validator) || ext.Round >= height {
+				t.Errorf("unexpected extension at %d: %+v", height, ext)
+			}
+		}
+	}
+	if extended == 0 {
+		t.Fatalf("want the blocks carrying the extensions, has: %+v", want)
+	}
+	for _, n := range nodes[1:] {
+		has := oracles[n.ID].extensions()
+		for height := int64(1); height <= 4; height++ {
+			if fmt.Sprint(has[height]) != fmt.Sprint(want[height]) {
+				t.Errorf("%s diverges at %d, want: %+v, has: %+v", n.ID, height, want[height], has[height])
+			}
+		}
+	}
+}
+
+func TestNetworkPanic(t *testing.T) {
+	vs, err := NewValidatorSet(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := libs.NewDefaultEventBus(nil)
+	events := bus.Subscribe(libs.EventPanic)
+	var once sync.Once
+	net, err := NewNetwork(Genesis("test", vs), vs, func(n *Node) error {
+		if n.ID != vs[1].ID {
+			return nil
+		}
+		n.State.SetEventBus(bus)
+		// the first proposal received is poisonous
+		n.State.BeforeStep(func(info state.StepInfo) {
+			if info.Step == state.ProposalProcess {
+				once.Do(func() { panic("poisonous proposal") })
+			}
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	net.Start()
+	defer net.Stop()
+
+	nodes := net.Nodes()
+	// the receive routine of the node is restarted, it keeps committing with the others
+	if err := net.Run(func() bool { return nodes[1].Height() >= 3 }, 10*time.Second); err != nil {
+		t.Fatalf("the node should commit after the panic, height: %d", nodes[1].Height())
+	}
+	var e libs.Event
+	select {
+	case e = <-events:
+	default:
+		t.Fatal("want the panic published")
+	}
+	data, ok := e.Data.(libs.PanicEventData)
+	if !ok || data.PeerID == "" || data.Reason != "poisonous proposal" || data.Stack == "" {
+		t.Errorf("unexpected panic event: %+v", e)
+	}
+	// only the sender of the poisonous msg is disconnected
+	if stopped := nodes[1].Stopped(); len(stopped) != 1 || stopped[0] != data.PeerID+": poisonous proposal" {
+		t.Errorf("want the proposer stopped, has: %v", stopped)
+	}
+}
+
+func TestNetworkAbdicate(t *testing.T) {
+	vs, err := NewValidatorSet(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	net, err := NewNetwork(Genesis("test", vs), vs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	net.Start()
+	defer net.Stop()
+
+	nodes := net.Nodes()
+	if err := net.Run(func() bool { return nodes[0].Height() >= 3 }, 10*time.Second); err != nil {
+		t.Fatalf("the network should commit, height: %d", nodes[0].Height())
+	}
+	// the proposals of the leader are lost and the clock stands still, the others wait for it forever
+	leader := nodes[3].ID
+	isProposal := func(e Envelope) bool {
+		m, err := state.ConsMsgFromProto(e.Bytes)
+		_, ok := m.(*types.ProposalMsg)
+		return err == nil && ok
+	}
+	net.IdleAdvance = 0
+	idle := 0
+	stalled := func() bool {
+		net.Drop(func(e Envelope) bool { return e.From == leader && isProposal(e) })
+		if len(net.Pending()) > 0 || nodes[3].State.GetRoundState().Leader != leader {
+			idle = 0
+			return false
+		}
+		idle++
+		return idle > 50
+	}
+	if err := net.Run(stalled, 10*time.Second); err != nil {
+		t.Fatalf("want the network waiting for the leader, pending: %d", len(net.Pending()))
+	}
+	height := nodes[0].Height()
+
+	if err := net.StopNode(leader); err != nil {
+		t.Fatal(err)
+	}
+	var handover bool
+	for _, e := range net.Pending() {
+		m, err := state.ConsMsgFromProto(e.Bytes)
+		if tmo, ok := m.(*types.TimeoutMsg); err == nil && ok && e.From == leader && tmo.SendID == string(leader) {
+			handover = true
+		}
+	}
+	if !handover {
+		t.Fatalf("want the timeout of the leader sent on the shutdown, pending: %d", len(net.Pending()))
+	}
+	// the others advance the view on the timeout of the leader instead of waiting out theirs
+	if err := net.Run(func() bool { return nodes[0].Height() > height }, 10*time.Second); err != nil {
+		t.Fatalf("the others should commit without the leader, height: %d", nodes[0].Height())
+	}
+}
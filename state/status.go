@@ -0,0 +1,161 @@
+package state
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+const (
+	DefaultStatusInterval = time.Second
+	// statuses not refreshed in statusTTLFactor intervals are ignored.
+	statusTTLFactor = 3
+)
+
+// PeerStatus is the latest status gossiped by a peer.
+type PeerStatus struct {
+	Peer           PeerID    `json:"peer"`
+	CommittedRound int64     `json:"committed_round"`
+	Round          int64     `json:"round"`
+	HighQCRound    int64     `json:"high_qc_round"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type peerStatuses struct {
+	sync.Mutex
+	m map[PeerID]PeerStatus
+	// the latest round of ours which has been reported behind
+	behindRound int64
+}
+
+// PeerStatuses returns the fresh statuses of the peers sorted by the peer id.
+func (s *State) PeerStatuses() []PeerStatus {
+	s.statuses.Lock()
+	defer s.statuses.Unlock()
+
+	var list []PeerStatus
+	for _, st := range s.statuses.m {
+		if s.statusFresh(st) {
+			list = append(list, st)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Peer < list[j].Peer })
+	return list
+}
+
+// BestPeer returns the peer with the highest committed round, which the block sync fetches from.
+func (s *State) BestPeer() (PeerStatus, bool) {
+	var best PeerStatus
+	found := false
+	for _, st := range s.PeerStatuses() {
+		if !found || st.CommittedRound > best.CommittedRound {
+			best, found = st, true
+		}
+	}
+	return best, found
+}
+
+func (s *State) statusFresh(st PeerStatus) bool {
+	return s.clock.Since(st.UpdatedAt) <= statusTTLFactor*s.cfg.StatusInterval
+}
+
+// statusRoutine gossips the status of the host periodically.
+func (s *State) statusRoutine() {
+	ticker := s.clock.NewTicker(s.cfg.StatusInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			if err := s.broadcastStatus(); err != nil {
+				s.log.Warn("broadcast status fail @ state.statusRoutine, err: %v", err)
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+func (s *State) broadcastStatus() error {
+	s.mtx.RLock()
+	status := &types.StatusMsg{
+		CommittedRound: s.committedRound,
+		Round:          s.pacemaker.GetCurrentRound(),
+		SendID:         string(s.host),
+		Timestamp:      s.clock.Now().Unix(),
+	}
+	highQC := s.tree.GetCurrentHighQC()
+	s.mtx.RUnlock()
+	if highQC == nil {
+		return ErrNilQC
+	}
+	highRound, _, err := highQC.Proposal()
+	if err != nil {
+		return err
+	}
+	status.HighQCRound = highRound
+
+	newmsg, err := s.signMsg(status)
+	if err != nil {
+		return err
+	}
+	s.p2p.Broadcast(s.channel, newmsg)
+	return nil
+}
+
+// onReceiveStatus records the status of the peer, and reports the host is behind
+// once more than 1/3 of the validators are in a higher round, at least one of them is honest.
+func (s *State) onReceiveStatus(status *types.StatusMsg) error {
+	peer := PeerID(status.SendID)
+	if peer == s.host {
+		return nil
+	}
+	s.statuses.Lock()
+	s.statuses.m[peer] = PeerStatus{
+		Peer:           peer,
+		CommittedRound: status.CommittedRound,
+		Round:          status.Round,
+		HighQCRound:    status.HighQCRound,
+		UpdatedAt:      s.clock.Now(),
+	}
+	s.statuses.Unlock()
+
+	s.mtx.RLock()
+	round := s.pacemaker.GetCurrentRound()
+	validators := s.election.Validators(round, s.timeoutSet.GetTimeoutIdxMap())
+	s.mtx.RUnlock()
+
+	var ahead []int64
+	s.statuses.Lock()
+	defer s.statuses.Unlock()
+	for _, v := range validators {
+		st, ok := s.statuses.m[v]
+		if ok && s.statusFresh(st) && st.Round > round {
+			ahead = append(ahead, st.Round)
+		}
+	}
+	if len(ahead)*3 <= len(validators) {
+		s.metrics.roundsBehind.Set(0)
+		return nil
+	}
+	// the highest round reached by more than 1/3 validators
+	sort.Slice(ahead, func(i, j int) bool { return ahead[i] > ahead[j] })
+	peerRound := ahead[len(validators)/3]
+	s.metrics.roundsBehind.Set(float64(peerRound - round))
+	if s.statuses.behindRound >= round {
+		return nil
+	}
+	s.statuses.behindRound = round
+	s.log.Warn("pacemaker is behind the peers @ state.onReceiveStatus, round: %d, peer_round: %d", round, peerRound)
+	s.publish(libs.Event{
+		Type:   libs.EventBehind,
+		Module: libs.ChainModule(s.cfg.ChainID, libs.ConsensusModule),
+		Data: libs.BehindEventData{
+			Round:     round,
+			PeerRound: peerRound,
+		},
+	})
+	return nil
+}
@@ -5,11 +5,14 @@ import (
 
 	"github.com/astaxie/beego/logs"
 	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/libs/service"
 )
 
 const (
 	TypeCollectVotes = 1 // "collect_votes_type"
 	TypeNextRound    = 2 // "next_round_type"
+	TypeForced       = 3 // "forced_type", scheduled by ForceViewChange
+	TypeVoteGrace    = 4 // "vote_grace_type", posted once the grace of a QC ends, see closeVoteGrace
 
 	MaxTimeoutSec = 60 * 60
 )
@@ -36,30 +39,40 @@ type timeoutInfo struct {
 }
 
 type DefaultTimeoutTicker struct {
-	timer    *time.Timer
+	timer    libs.Timer
 	tickChan chan timeoutInfo // for scheduling timeouts
 	tockChan chan timeoutInfo // for notifying about them
 
 	quit chan struct{}
-	log  libs.Logger
+	// routines is the timeoutRoutine
+	routines service.Supervisor
+	log      libs.Logger
 }
 
 // NewDefaultTimeoutTicker returns a new DefaultTimeoutTicker and invoke timeoutTicker.Start().
 func NewDefaultTimeoutTicker(logger libs.Logger) TimeoutTicker {
+	return NewClockTimeoutTicker(logger, libs.SystemClock)
+}
+
+// NewClockTimeoutTicker returns a DefaultTimeoutTicker driven by the clock,
+// e.g. a libs.ManualClock of the simulation harness.
+func NewClockTimeoutTicker(logger libs.Logger, clock libs.Clock) TimeoutTicker {
 	if logger == nil {
 		logger = logs.NewLogger()
 	}
 	tt := &DefaultTimeoutTicker{
-		timer:    time.NewTimer(MaxTimeoutSec * time.Second),
+		timer:    clock.NewTimer(MaxTimeoutSec * time.Second),
 		tickChan: make(chan timeoutInfo, tickTockBufferSize),
 		tockChan: make(chan timeoutInfo, tickTockBufferSize),
+		quit:     make(chan struct{}),
 		log:      logger,
 	}
+	tt.routines.SetLogger(logger)
 	return tt
 }
 
 func (t *DefaultTimeoutTicker) Start() {
-	go t.timeoutRoutine()
+	t.routines.Spawn("timeout", t.timeoutRoutine)
 }
 
 // ScheduleTimeout schedules a new timeout by sending on the internal tickChan.
@@ -75,11 +88,12 @@ func (t *DefaultTimeoutTicker) Chan() <-chan timeoutInfo {
 	return t.tockChan
 }
 
+// Stop stops the timeoutRoutine, channels are left open,
+// the timeouts still being relayed to the tockChan are dropped.
 func (t *DefaultTimeoutTicker) Stop() {
 	defer t.timer.Stop()
-	defer close(t.tickChan)
-	defer close(t.tockChan)
-	t.quit <- struct{}{}
+	close(t.quit)
+	t.routines.Stop()
 }
 
 // send on tickChan to start a new timer.
@@ -103,13 +117,18 @@ func (t *DefaultTimeoutTicker) timeoutRoutine() {
 			// NOTE time.Timer allows duration to be non-positive
 			t.timer.Reset(newti.Duration)
 			ti = newti
-		case <-t.timer.C:
+		case <-t.timer.C():
 			t.log.Info("Timed out, dur: %+v, round: %+v", ti.Duration, ti.Round)
 			// go routine here guarantees timeoutRoutine doesn't block.
 			// Determinism comes from playback in the receiveRoutine.
 			// We can eliminate it by merging the timeoutRoutine into receiveRoutine
 			//  and managing the timeouts ourselves with a millisecond ticker
-			go func() { t.tockChan <- ti }()
+			go func(ti timeoutInfo) {
+				select {
+				case t.tockChan <- ti:
+				case <-t.quit:
+				}
+			}(ti)
 		case <-t.quit:
 			return
 		}
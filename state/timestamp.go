@@ -0,0 +1,99 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/state/bt"
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+const (
+	DefaultMaxClockSkew = 10 * time.Second
+	// medianTimeSpan is the number of the latest blocks of a branch whose median time
+	// the next timestamp of the branch mustn't be earlier than.
+	medianTimeSpan = 11
+)
+
+var (
+	ErrInvalidTimestamp = errors.New("invalid proposal timestamp")
+)
+
+// checkTimestamp validates the timestamp of the proposal extending the parent, it mustn't be earlier than
+// the parent nor the median time of the branch, and mustn't be ahead of the local clock by more than the
+// max clock skew in force at the round, so that the block times are trustworthy for the applications.
+func (s *State) checkTimestamp(round, timestamp int64, parent *bt.Node) error {
+	times := s.branchTimes(parent)
+	if len(times) > 0 && timestamp < times[0] {
+		return fmt.Errorf("%w, earlier than the parent, timestamp: %d, parent: %d", ErrInvalidTimestamp, timestamp, times[0])
+	}
+	if median := medianTime(times); timestamp < median {
+		return fmt.Errorf("%w, earlier than the median time, timestamp: %d, median: %d", ErrInvalidTimestamp, timestamp, median)
+	}
+	skew := s.ParamsAt(round).MaxClockSkew
+	if now := s.clock.Now(); time.Unix(timestamp, 0).After(now.Add(skew)) {
+		return fmt.Errorf("%w, ahead of the local clock, timestamp: %d, now: %d, max_skew: %v",
+			ErrInvalidTimestamp, timestamp, now.Unix(), skew)
+	}
+	return nil
+}
+
+// proposalTime returns the timestamp of the host's proposal, it's the local clock unless the clock
+// lags behind the branch which the proposal extends.
+func (s *State) proposalTime(proposal *types.ProposalMsg) int64 {
+	now := s.clock.Now().Unix()
+	parentQC, err := s.tree.DeserializeF(proposal.JustifyParent)
+	if err != nil {
+		return now
+	}
+	parentRound, parentID, err := parentQC.Proposal()
+	if err != nil {
+		return now
+	}
+	pnode, err := s.tree.Search(parentRound, parentID)
+	if err != nil {
+		return now
+	}
+	for _, t := range s.branchTimes(pnode) {
+		if t > now {
+			now = t
+		}
+	}
+	return now
+}
+
+// branchTimes returns the timestamps of the node and its latest ancestors, the newest first,
+// the uncommitted ones are taken from the payloads and the committed ones from the latest commits.
+func (s *State) branchTimes(node *bt.Node) []int64 {
+	var times []int64
+	for n := node; n != nil && len(times) < medianTimeSpan; n = n.Parent {
+		p, ok := s.payloads[n.ID]
+		if !ok {
+			break
+		}
+		times = append(times, p.timestamp)
+	}
+	for i := len(s.blockTimes) - 1; i >= 0 && len(times) < medianTimeSpan; i-- {
+		times = append(times, s.blockTimes[i])
+	}
+	return times
+}
+
+// recordBlockTime keeps the timestamp of the committed block for the median time of the later ones.
+func (s *State) recordBlockTime(timestamp int64) {
+	s.blockTimes = append(s.blockTimes, timestamp)
+	if len(s.blockTimes) > medianTimeSpan {
+		s.blockTimes = s.blockTimes[len(s.blockTimes)-medianTimeSpan:]
+	}
+}
+
+func medianTime(times []int64) int64 {
+	if len(times) == 0 {
+		return 0
+	}
+	sorted := append([]int64{}, times...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
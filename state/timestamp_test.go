@@ -0,0 +1,65 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/state/bt"
+)
+
+func TestCheckTimestamp(t *testing.T) {
+	clock := libs.NewManualClock(time.Unix(1000, 0))
+	cfg := &ConsensusConfig{EpochLength: DefaultEpochLength, MaxClockSkew: 5 * time.Second}
+	s := &State{
+		cfg:      cfg,
+		params:   newParamsHistory(cfg),
+		payloads: make(map[string]pendingPayload),
+		clock:    clock,
+		log:      logs.NewLogger(),
+	}
+	// the committed blocks are at 100..104 and the uncommitted branch at 990, 991, 992
+	for ts := int64(100); ts < 105; ts++ {
+		s.recordBlockTime(ts)
+	}
+	var parent *bt.Node
+	for i, ts := range []int64{990, 991, 992} {
+		parent = &bt.Node{Round: int64(i + 1), ID: fmt.Sprint(i), Parent: parent}
+		s.payloads[parent.ID] = pendingPayload{round: parent.Round, timestamp: ts}
+	}
+	if has := medianTime(s.branchTimes(parent)); has != 104 {
+		t.Errorf("want median 104, has: %d", has)
+	}
+
+	for ts, want := range map[int64]error{
+		992:  nil,
+		1005: nil,
+		991:  ErrInvalidTimestamp,
+		1006: ErrInvalidTimestamp,
+	} {
+		if err := s.checkTimestamp(4, ts, parent); !errors.Is(err, want) {
+			t.Errorf("timestamp: %d, want: %v, has: %v", ts, want, err)
+		}
+	}
+	// no earlier than the median time even if the parent went back
+	for i := range s.blockTimes {
+		s.blockTimes[i] += 900
+	}
+	if err := s.checkTimestamp(4, 992, parent); !errors.Is(err, ErrInvalidTimestamp) {
+		t.Errorf("want invalid timestamp, has: %v", err)
+	}
+
+	// the skew is a consensus param
+	change, _ := EncodeParamChange(ParamChange{MaxClockSkewMs: 30000})
+	s.applyParamChanges(1, [][]byte{change})
+	clock.Advance(20 * time.Second)
+	if err := s.checkTimestamp(2*DefaultEpochLength, 1030, parent); err != nil {
+		t.Errorf("want the skew changed, has: %v", err)
+	}
+	if err := s.checkTimestamp(4, 1030, parent); !errors.Is(err, ErrInvalidTimestamp) {
+		t.Errorf("want invalid timestamp, has: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+var (
+	ErrUpgradeHalted = errors.New("consensus is halted at the upgrade height")
+)
+
+// pastUpgrade reports whether the block of the round is beyond the upgrade height,
+// such a block is never committed by this binary.
+func (s *State) pastUpgrade(round int64) bool {
+	return s.cfg.UpgradeHeight > 0 && round > s.cfg.UpgradeHeight
+}
+
+// Halted reports whether the consensus has stopped at the upgrade height or on a state divergence.
+func (s *State) Halted() bool {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	return s.halted
+}
+
+// haltForUpgrade stops the host from producing and committing any further block,
+// the operators can replace the binary at the same height without racing each other.
+func (s *State) haltForUpgrade() {
+	if s.halted {
+		return
+	}
+	s.halted = true
+	instructions := fmt.Sprintf("consensus halted at the upgrade height %d, committed round: %d, "+
+		"stop the node, install the new binary and restart it with the next upgradeheight", s.cfg.UpgradeHeight, s.committedRound)
+	if s.cfg.UpgradeInfo != "" {
+		instructions += ", upgrade: " + s.cfg.UpgradeInfo
+	}
+	s.log.Warn("%s @ state.haltForUpgrade", instructions)
+	s.publish(libs.Event{
+		Type:   libs.EventUpgrade,
+		Module: libs.ChainModule(s.cfg.ChainID, libs.ConsensusModule),
+		Data: libs.UpgradeEventData{
+			Height:         s.cfg.UpgradeHeight,
+			CommittedRound: s.committedRound,
+			Info:           s.cfg.UpgradeInfo,
+		},
+	})
+}
+
+// haltedMsg reports whether the msg takes part in producing blocks, which is refused once halted,
+// the statuses and the proposal requests are still served so that the peers reach the height as well.
+func haltedMsg(m MsgInfo) bool {
+	switch m.(type) {
+	case *types.ProposalMsg, *types.VoteMsg, *types.TimeoutMsg:
+		return true
+	}
+	return false
+}
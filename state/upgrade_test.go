@@ -0,0 +1,52 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+func TestHaltForUpgrade(t *testing.T) {
+	bus := libs.NewDefaultEventBus(nil)
+	events := bus.Subscribe(libs.EventUpgrade)
+	s := &State{
+		cfg:            &ConsensusConfig{UpgradeHeight: 10, UpgradeInfo: "v2.0.0"},
+		committedRound: 9,
+		eventBus:       bus,
+		log:            logs.NewLogger(),
+	}
+	if s.pastUpgrade(10) || !s.pastUpgrade(11) {
+		t.Errorf("only the blocks beyond the height should be refused")
+	}
+	s.haltForUpgrade()
+	s.haltForUpgrade()
+	if !s.Halted() {
+		t.Fatal("consensus should be halted")
+	}
+	select {
+	case e := <-events:
+		data := e.Data.(libs.UpgradeEventData)
+		if data.Height != 10 || data.CommittedRound != 9 || data.Info != "v2.0.0" {
+			t.Errorf("unexpected event: %+v", data)
+		}
+	default:
+		t.Fatal("upgrade event should be published")
+	}
+	if len(events) != 0 {
+		t.Errorf("the halt should be reported once")
+	}
+
+	for _, m := range []MsgInfo{&types.ProposalMsg{Round: 11}, &types.VoteMsg{Round: 11}, &types.TimeoutMsg{Round: 11}} {
+		if err := s.handleMsg(m); err != ErrUpgradeHalted {
+			t.Errorf("want halted err, msg: %T, has: %v", m, err)
+		}
+		if err := s.schedule(m); err != ErrUpgradeHalted {
+			t.Errorf("want halted err, msg: %T, has: %v", m, err)
+		}
+	}
+	if (&State{cfg: &ConsensusConfig{}}).pastUpgrade(11) {
+		t.Errorf("upgrade height 0 should never halt")
+	}
+}
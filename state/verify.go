@@ -0,0 +1,110 @@
+package state
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/libs/service"
+)
+
+// verifyQueueSize bounds the msgs of a peer waiting for the verification, the later ones are dropped,
+// a peer flooding us only loses its own msgs.
+const verifyQueueSize = 256
+
+// peerVerifier verifies the msgs of a peer in the order received and hands them to the receiveRoutine,
+// so that a peer sending the msgs expensive to verify only delays its own ones.
+type peerVerifier struct {
+	queue chan []byte
+	quit  chan struct{}
+}
+
+type peerVerifiers struct {
+	sync.Mutex
+	m map[string]*peerVerifier
+}
+
+// HandleFuncFrom queues the consensus msg to the verifier of the peer, see libs.PeerReactor.
+func (s *State) HandleFuncFrom(peerID string, chID int32, msgbytes []byte) {
+	if chID != s.channel {
+		return
+	}
+	v := s.verifier(peerID)
+	// the msg bytes are recycled by the switch once we return, while the msg waits in the queue,
+	// the copy is owned by the verifier and kept by the proposal cache without copying it again
+	select {
+	case v.queue <- append([]byte(nil), msgbytes...):
+	default:
+		s.log.Warn("verify queue is full @ state.HandleFuncFrom, peer_id: %s, msg: %s", peerID, libs.GetSum(msgbytes))
+	}
+}
+
+// verifier returns the verifier of the peer, which is started on the first msg of the peer.
+func (s *State) verifier(peerID string) *peerVerifier {
+	s.verifiers.Lock()
+	defer s.verifiers.Unlock()
+
+	if v, ok := s.verifiers.m[peerID]; ok {
+		return v
+	}
+	if s.verifiers.m == nil {
+		s.verifiers.m = make(map[string]*peerVerifier)
+	}
+	v := &peerVerifier{
+		queue: make(chan []byte, verifyQueueSize),
+		quit:  make(chan struct{}),
+	}
+	// the verifiers are run by the supervisor so that State.Wait waits for them as well,
+	// the msgs queued after Stop are dropped with the stopped verifier
+	if !s.routines.Go("verify/"+peerID, service.Policy{}, func() error { return s.verifyRoutine(peerID, v) }) {
+		close(v.quit)
+		return v
+	}
+	s.verifiers.m[peerID] = v
+	return v
+}
+
+// stopVerifier drops the msgs of the peer which are still waiting.
+func (s *State) stopVerifier(peerID string) {
+	s.verifiers.Lock()
+	defer s.verifiers.Unlock()
+
+	if v, ok := s.verifiers.m[peerID]; ok {
+		close(v.quit)
+		delete(s.verifiers.m, peerID)
+	}
+}
+
+// verifyRoutine isn't restarted once it panics on a msg of the peer, the peer who sent
+// the poisonous msg is disconnected and its waiting msgs are dropped.
+func (s *State) verifyRoutine(peerID string, v *peerVerifier) error {
+	defer func() {
+		if r := recover(); r != nil {
+			stack := debug.Stack()
+			s.log.Error("verifyRoutine panics @ state.verifyRoutine, peer_id: %s, err: %v, stack: %s", peerID, r, stack)
+			s.p2p.StopPeerForError(peerID, r)
+			s.publish(libs.Event{
+				Type:   libs.EventPanic,
+				Module: libs.ConsensusModule,
+				Data: libs.PanicEventData{
+					PeerID: peerID,
+					Reason: fmt.Sprintf("%v", r),
+					Stack:  string(stack),
+				},
+			})
+			s.stopVerifier(peerID)
+		}
+	}()
+
+	for {
+		select {
+		case msgbytes := <-v.queue:
+			s.receive(msgbytes, true)
+		case <-v.quit:
+			return nil
+		case <-s.quit:
+			return nil
+		}
+	}
+}
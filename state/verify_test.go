@@ -0,0 +1,148 @@
+package state
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+)
+
+// stallingCrypto rejects every msg, the msgs prefixed with "slow" are verified once released.
+type stallingCrypto struct {
+	mtx      sync.Mutex
+	verified []string
+	release  chan struct{}
+}
+
+func (c *stallingCrypto) Sign(msgBytes []byte) ([]byte, error) { return msgBytes, nil }
+
+func (c *stallingCrypto) Verify(sign []byte, pk []byte, msgBytes []byte) (bool, error) {
+	if len(msgBytes) >= 4 && string(msgBytes[:4]) == "slow" {
+		<-c.release
+	}
+	c.mtx.Lock()
+	c.verified = append(c.verified, string(msgBytes))
+	c.mtx.Unlock()
+	return false, nil
+}
+
+func (c *stallingCrypto) list() string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return fmt.Sprint(c.verified)
+}
+
+func TestHandleFuncFrom(t *testing.T) {
+	cc := &stallingCrypto{release: make(chan struct{})}
+	s := &State{
+		channel: libs.ConsensusChannel,
+		crypto:  cc,
+		quit:    make(chan struct{}),
+		log:     logs.NewLogger(),
+	}
+	defer close(s.quit)
+
+	msg := []byte("slow-a1")
+	s.HandleFuncFrom("a", libs.ConsensusChannel, msg)
+	// the lent bytes are recycled once it returns
+	copy(msg, "garbage")
+	s.HandleFuncFrom("a", libs.ConsensusChannel, []byte("a2"))
+	s.HandleFuncFrom("b", libs.ConsensusChannel, []byte("b1"))
+	s.HandleFuncFrom("b", libs.ConsensusChannel, []byte("b2"))
+	s.HandleFuncFrom("b", libs.MempoolChannel, []byte("b3"))
+
+	waitVerified := func(want string) {
+		for deadline := time.Now().Add(3 * time.Second); cc.list() != want; time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("want verified: %s, has: %s", want, cc.list())
+			}
+		}
+	}
+	// the stalled peer doesn't delay the others
+	waitVerified("[b1 b2]")
+	close(cc.release)
+	waitVerified("[b1 b2 slow-a1 a2]")
+
+	// the queue of the removed peer is dropped
+	s.stopVerifier("a")
+	s.verifiers.Lock()
+	_, ok := s.verifiers.m["a"]
+	s.verifiers.Unlock()
+	if ok {
+		t.Error("want the verifier of the removed peer stopped")
+	}
+}
+
+// panicCrypto panics on the msgs prefixed with "poison".
+type panicCrypto struct {
+	stallingCrypto
+}
+
+func (c *panicCrypto) Verify(sign []byte, pk []byte, msgBytes []byte) (bool, error) {
+	if len(msgBytes) >= 6 && string(msgBytes[:6]) == "poison" {
+		panic("malformed msg")
+	}
+	return c.stallingCrypto.Verify(sign, pk, msgBytes)
+}
+
+func TestVerifierPanic(t *testing.T) {
+	cc := &panicCrypto{}
+	sw := &stubSwitch{}
+	bus := libs.NewDefaultEventBus(nil)
+	events := bus.Subscribe(libs.EventPanic)
+	s := &State{
+		channel:  libs.ConsensusChannel,
+		crypto:   cc,
+		p2p:      sw,
+		eventBus: bus,
+		quit:     make(chan struct{}),
+		log:      logs.NewLogger(),
+	}
+
+	s.HandleFuncFrom("a", libs.ConsensusChannel, []byte("poison"))
+	select {
+	case e := <-events:
+		data, ok := e.Data.(libs.PanicEventData)
+		if !ok || data.PeerID != "a" || data.Reason != "malformed msg" || data.Stack == "" {
+			t.Errorf("unexpected panic event: %+v", e)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("want the panic published")
+	}
+	// only the peer who sent the poisonous msg is stopped, the others are still verified
+	s.HandleFuncFrom("b", libs.ConsensusChannel, []byte("b1"))
+	for deadline := time.Now().Add(3 * time.Second); cc.list() != "[b1]"; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("want the msg of the other peer verified, has: %s", cc.list())
+		}
+	}
+	sw.mtx.Lock()
+	stopped := fmt.Sprint(sw.stopped)
+	sw.mtx.Unlock()
+	if stopped != "[a: malformed msg]" {
+		t.Errorf("want the sender stopped, has: %s", stopped)
+	}
+	s.verifiers.Lock()
+	_, ok := s.verifiers.m["a"]
+	s.verifiers.Unlock()
+	if ok {
+		t.Error("want the verifier of the sender stopped")
+	}
+
+	// the verifiers are waited for as well as the other routines
+	close(s.quit)
+	s.routines.Stop()
+	done := make(chan struct{})
+	go func() {
+		s.routines.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("want the verifiers exited")
+	}
+}
@@ -0,0 +1,142 @@
+package state
+
+import (
+	"sync"
+	"time"
+)
+
+// ViewChangeReason classifies why the host timed out a round.
+type ViewChangeReason string
+
+const (
+	// ReasonLeaderTimeout means no proposal of the round has been received.
+	ReasonLeaderTimeout ViewChangeReason = "leader_timeout"
+	// ReasonInvalidProposal means the proposal of the round has been rejected.
+	ReasonInvalidProposal ViewChangeReason = "invalid_proposal"
+	// ReasonNoQuorum means the proposal has been accepted or sent by the host,
+	// but its votes didn't come to 2f+1 in time.
+	ReasonNoQuorum ViewChangeReason = "no_quorum"
+	// ReasonPartitionSuspected means less than 2f+1 validators are reachable, judged by their statuses.
+	ReasonPartitionSuspected ViewChangeReason = "partition_suspected"
+	// ReasonForced means the round has been timed out by the operator, see ForceViewChange.
+	ReasonForced ViewChangeReason = "forced"
+
+	// maxRecentViewChanges is the size of the rolling window.
+	maxRecentViewChanges = 100
+)
+
+var viewChangeReasons = []ViewChangeReason{
+	ReasonLeaderTimeout, ReasonInvalidProposal, ReasonNoQuorum, ReasonPartitionSuspected, ReasonForced,
+}
+
+// ViewChange is a round timed out by the host.
+type ViewChange struct {
+	Round  int64            `json:"round"`
+	Leader PeerID           `json:"leader"`
+	Reason ViewChangeReason `json:"reason"`
+	Time   time.Time        `json:"time"`
+}
+
+// ViewChangeStats counts the view changes by the reason since the start,
+// and keeps the recent ones from the oldest to the latest.
+type ViewChangeStats struct {
+	Total    int64                      `json:"total"`
+	ByReason map[ViewChangeReason]int64 `json:"by_reason"`
+	Recent   []ViewChange               `json:"recent"`
+}
+
+type viewChangeStats struct {
+	sync.Mutex
+	byReason map[ViewChangeReason]int64
+	recent   []ViewChange
+	// the latest rounds whose proposal has been accepted or rejected, only accessed by the receiveRoutine
+	acceptedRound int64
+	rejectedRound int64
+}
+
+// ViewChangeStats returns a snapshot of the view change statistics.
+func (s *State) ViewChangeStats() ViewChangeStats {
+	s.viewStats.Lock()
+	defer s.viewStats.Unlock()
+
+	stats := ViewChangeStats{
+		ByReason: make(map[ViewChangeReason]int64, len(s.viewStats.byReason)),
+		Recent:   append([]ViewChange(nil), s.viewStats.recent...),
+	}
+	for reason, n := range s.viewStats.byReason {
+		stats.ByReason[reason] = n
+		stats.Total += n
+	}
+	return stats
+}
+
+// classifyViewChange decides why the round timed out, a suspected partition overrides the others
+// since the leader and the votes are likely to be unreachable then.
+func (s *State) classifyViewChange(ti timeoutInfo, validators []PeerID) ViewChangeReason {
+	if ti.Type == TypeForced {
+		return ReasonForced
+	}
+	if !s.quorumReachable(validators) {
+		return ReasonPartitionSuspected
+	}
+	switch {
+	case ti.Type == TypeCollectVotes || s.viewStats.acceptedRound == ti.Round:
+		return ReasonNoQuorum
+	case s.viewStats.rejectedRound == ti.Round:
+		return ReasonInvalidProposal
+	}
+	return ReasonLeaderTimeout
+}
+
+// ForceViewChange times out the current round at once instead of waiting for its timer,
+// which recovers a host stuck in the round without restarting it. The timeout is handled by
+// the receiveRoutine like the one of the timer, and the round is returned.
+func (s *State) ForceViewChange() (int64, error) {
+	s.mtx.RLock()
+	halted := s.halted
+	ti := timeoutInfo{
+		Type:  TypeForced,
+		Round: s.pacemaker.GetCurrentRound(),
+		Index: s.timeoutSet.GetCurrentTimeoutIndex(),
+	}
+	s.mtx.RUnlock()
+
+	if halted {
+		return 0, ErrUpgradeHalted
+	}
+	s.log.Warn("force view change @ state.ForceViewChange, round: %d", ti.Round)
+	s.timeoutTicker.ScheduleTimeout(ti)
+	return ti.Round, nil
+}
+
+// quorumReachable reports whether the host and the validators with fresh statuses come to 2f+1.
+func (s *State) quorumReachable(validators []PeerID) bool {
+	s.statuses.Lock()
+	defer s.statuses.Unlock()
+
+	reachable := 0
+	for _, v := range validators {
+		if v == s.host {
+			reachable++
+			continue
+		}
+		if st, ok := s.statuses.m[v]; ok && s.statusFresh(st) {
+			reachable++
+		}
+	}
+	return reachable*3 > len(validators)*2
+}
+
+func (s *State) recordViewChange(vc ViewChange) {
+	s.viewStats.Lock()
+	defer s.viewStats.Unlock()
+
+	s.viewStats.byReason[vc.Reason]++
+	s.viewStats.recent = append(s.viewStats.recent, vc)
+	if len(s.viewStats.recent) > maxRecentViewChanges {
+		s.viewStats.recent = s.viewStats.recent[len(s.viewStats.recent)-maxRecentViewChanges:]
+	}
+	if c, ok := s.metrics.viewChangesByReason[vc.Reason]; ok {
+		c.Inc()
+	}
+}
@@ -0,0 +1,69 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/metrics"
+)
+
+func TestClassifyViewChange(t *testing.T) {
+	clock := libs.NewManualClock(time.Unix(100, 0))
+	s := &State{
+		host:      "a",
+		cfg:       &ConsensusConfig{StatusInterval: time.Second},
+		statuses:  peerStatuses{m: make(map[PeerID]PeerStatus)},
+		viewStats: viewChangeStats{byReason: make(map[ViewChangeReason]int64)},
+		metrics:   newConsensusMetrics(metrics.NewRegistry(), "test"),
+		clock:     clock,
+		log:       logs.NewLogger(),
+	}
+	validators := []PeerID{"a", "b", "c", "d"}
+	next := timeoutInfo{Type: TypeNextRound, Round: 5}
+	if has := s.classifyViewChange(next, validators); has != ReasonPartitionSuspected {
+		t.Errorf("want partition suspected, has: %s", has)
+	}
+
+	for _, v := range []PeerID{"b", "c"} {
+		s.statuses.m[v] = PeerStatus{Peer: v, UpdatedAt: clock.Now()}
+	}
+	if has := s.classifyViewChange(next, validators); has != ReasonLeaderTimeout {
+		t.Errorf("want leader timeout, has: %s", has)
+	}
+	s.viewStats.rejectedRound = 5
+	if has := s.classifyViewChange(next, validators); has != ReasonInvalidProposal {
+		t.Errorf("want invalid proposal, has: %s", has)
+	}
+	s.viewStats.acceptedRound = 5
+	if has := s.classifyViewChange(next, validators); has != ReasonNoQuorum {
+		t.Errorf("want no quorum, has: %s", has)
+	}
+	if has := s.classifyViewChange(timeoutInfo{Type: TypeCollectVotes, Round: 6}, validators); has != ReasonNoQuorum {
+		t.Errorf("want no quorum, has: %s", has)
+	}
+	if has := s.classifyViewChange(timeoutInfo{Type: TypeForced, Round: 6}, validators); has != ReasonForced {
+		t.Errorf("want forced, has: %s", has)
+	}
+	// statuses expire
+	clock.Advance(statusTTLFactor*time.Second + 1)
+	if has := s.classifyViewChange(next, validators); has != ReasonPartitionSuspected {
+		t.Errorf("want partition suspected, has: %s", has)
+	}
+
+	for i := 0; i < maxRecentViewChanges+10; i++ {
+		s.recordViewChange(ViewChange{Round: int64(i), Reason: ReasonLeaderTimeout})
+	}
+	s.recordViewChange(ViewChange{Round: 999, Reason: ReasonNoQuorum})
+	stats := s.ViewChangeStats()
+	if stats.Total != maxRecentViewChanges+11 || stats.ByReason[ReasonNoQuorum] != 1 {
+		t.Errorf("unexpected stats: %+v", stats.ByReason)
+	}
+	if len(stats.Recent) != maxRecentViewChanges || stats.Recent[len(stats.Recent)-1].Round != 999 {
+		t.Errorf("unexpected recent view changes, len: %d", len(stats.Recent))
+	}
+	if has := s.metrics.viewChangesByReason[ReasonLeaderTimeout].Value(); has != maxRecentViewChanges+10 {
+		t.Errorf("unexpected metric: %v", has)
+	}
+}
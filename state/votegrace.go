@@ -0,0 +1,99 @@
+package state
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+// voteGrace is the QC of the leader waiting out ConsensusConfig.VoteGrace for the extra votes
+// after the quorum, it's guarded by the procedure mutex.
+type voteGrace struct {
+	pending bool
+	round   int64
+	id      []byte
+	// start is when the quorum was reached
+	start time.Time
+	timer libs.Timer
+	// the vote reaching the quorum, the QC is formed by it once the grace ends
+	vote       *types.VoteMsg
+	voteQC     QuorumCert
+	validators []PeerID
+	// formedRound is the latest round certified by the host, the votes arriving later form no QC again
+	formedRound int64
+}
+
+func (g *voteGrace) waiting(round int64, id []byte) bool {
+	return g.pending && g.round == round && bytes.Equal(g.id, id)
+}
+
+// waitVoteGrace returns true if the QC of the vote reaching the quorum should wait for the extra votes,
+// the QC is formed by closeVoteGrace once the grace ends, or at once when all the validators have voted.
+func (s *State) waitVoteGrace(vote *types.VoteMsg, voteQC QuorumCert, validators []PeerID) bool {
+	if s.cfg.VoteGrace <= 0 || vote.Round <= s.grace.formedRound {
+		return false
+	}
+	if votes, total := s.voteSet.Count(vote.Round, vote.ID); votes >= total {
+		return false
+	}
+	if s.grace.waiting(vote.Round, vote.ID) {
+		return true
+	}
+	if s.grace.pending {
+		// the grace of a stale round is superseded
+		s.grace.timer.Stop()
+	}
+	round := vote.Round
+	s.grace = voteGrace{
+		pending:     true,
+		round:       round,
+		id:          vote.ID,
+		start:       s.clock.Now(),
+		vote:        vote,
+		voteQC:      voteQC,
+		validators:  validators,
+		formedRound: s.grace.formedRound,
+	}
+	// the QC is formed by the receiveRoutine, which owns the tree and the rounds
+	s.grace.timer = s.clock.AfterFunc(s.cfg.VoteGrace, func() {
+		select {
+		case s.graceQueue <- timeoutInfo{Type: TypeVoteGrace, Round: round, Duration: s.cfg.VoteGrace}:
+		case <-s.quit:
+		}
+	})
+	s.log.Info("quorum reached, wait for the extra votes @ state.waitVoteGrace, round: %d, id: %s, grace: %v",
+		round, libs.F(vote.ID), s.cfg.VoteGrace)
+	return true
+}
+
+// closeVoteGrace forms the QC waiting for the extra votes once its grace ends, it's invoked by the receiveRoutine.
+func (s *State) closeVoteGrace(ti timeoutInfo) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if !s.grace.pending || s.grace.round != ti.Round {
+		return
+	}
+	vote, voteQC, validators := s.grace.vote, s.grace.voteQC, s.grace.validators
+	if err := s.formQC(vote, voteQC, validators); err != nil {
+		s.grace.pending = false
+		s.log.Error("form qc fail @ state.closeVoteGrace, round: %d, id: %s, err: %v", ti.Round, libs.F(vote.ID), err)
+	}
+}
+
+// formed ends the grace of the QC formed for the proposal and describes the QC.
+func (g *voteGrace) formed(round int64, id []byte, votes *VoteSet, now time.Time) QCInfo {
+	info := QCInfo{Round: round, ID: id}
+	info.Voters, info.Validators = votes.Count(round, id)
+	if g.waiting(round, id) {
+		info.Waited = now.Sub(g.start)
+		g.timer.Stop()
+		*g = voteGrace{formedRound: g.formedRound}
+	}
+	if round > g.formedRound {
+		g.formedRound = round
+	}
+	return info
+}
@@ -0,0 +1,85 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+func TestVoteGrace(t *testing.T) {
+	clock := libs.NewManualClock(time.Unix(100, 0))
+	s := &State{
+		cfg:        &ConsensusConfig{VoteGrace: 100 * time.Millisecond},
+		voteSet:    NewVoteSet(0),
+		graceQueue: make(chan timeoutInfo, 1),
+		clock:      clock,
+		quit:       make(chan struct{}),
+		log:        logs.NewLogger(),
+	}
+	validators := []PeerID{"a", "b", "c", "d"}
+	voteFor := func(round int64, id string, sender string) *types.VoteMsg {
+		if err := s.voteSet.AddVote(round, []byte(id), PeerID(sender), validators); err != nil {
+			t.Fatal(err)
+		}
+		return &types.VoteMsg{Round: round, ID: []byte(id), SendID: sender}
+	}
+	vote := func(sender string) *types.VoteMsg { return voteFor(1, "x", sender) }
+	vote("a")
+	vote("b")
+	// the quorum is reached, the QC waits for the last validator
+	if !s.waitVoteGrace(vote("c"), nil, validators) {
+		t.Fatal("the quorum should wait out the grace")
+	}
+	if !s.grace.waiting(1, []byte("x")) || !s.grace.start.Equal(clock.Now()) {
+		t.Errorf("unexpected grace: %+v", s.grace)
+	}
+	clock.Advance(40 * time.Millisecond)
+	// every validator has voted, the QC is formed at once
+	if s.waitVoteGrace(vote("d"), nil, validators) {
+		t.Fatal("the full votes should not wait")
+	}
+	info := s.grace.formed(1, []byte("x"), s.voteSet, clock.Now())
+	if info.Voters != 4 || info.Validators != 4 || info.Waited != 40*time.Millisecond {
+		t.Errorf("unexpected qc info: %+v", info)
+	}
+	if s.grace.pending || s.grace.formedRound != 1 {
+		t.Errorf("the grace should end, has: %+v", s.grace)
+	}
+	// the grace timer is stopped, nothing is formed again
+	clock.Advance(time.Second)
+
+	// the certified round doesn't wait again
+	if s.waitVoteGrace(&types.VoteMsg{Round: 1, ID: []byte("x"), SendID: "c"}, nil, validators) {
+		t.Errorf("the certified round should not wait")
+	}
+
+	// the end of the grace is handed to the receiveRoutine instead of forming the QC on the timer
+	voteFor(2, "y", "a")
+	voteFor(2, "y", "b")
+	if !s.waitVoteGrace(voteFor(2, "y", "c"), nil, validators) {
+		t.Fatal("the quorum should wait out the grace")
+	}
+	clock.Advance(100 * time.Millisecond)
+	select {
+	case ti := <-s.graceQueue:
+		if ti.Type != TypeVoteGrace || ti.Round != 2 {
+			t.Errorf("unexpected end of the grace: %+v", ti)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("want the end of the grace posted")
+	}
+	if !s.grace.waiting(2, []byte("y")) {
+		t.Errorf("the QC should be left to the receiveRoutine, has: %+v", s.grace)
+	}
+	s.grace.timer.Stop()
+	s.grace = voteGrace{formedRound: 2}
+
+	// no grace is waited if it's disabled
+	s.cfg.VoteGrace = 0
+	if s.waitVoteGrace(&types.VoteMsg{Round: 3, ID: []byte("z"), SendID: "c"}, nil, validators) {
+		t.Errorf("the disabled grace should not wait")
+	}
+}
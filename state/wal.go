@@ -0,0 +1,555 @@
+package state
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/aucusaga/gohotstuff/libs"
+	"github.com/aucusaga/gohotstuff/libs/service"
+)
+
+const (
+	walFileName = "wal"
+	// maxMsgSizeBytes is the max size of a single wal record.
+	maxMsgSizeBytes       = 1024 * 1024
+	walDefaultFlushPeriod = 2 * time.Second
+	// walCompactSize is the size of the wal beyond which Truncate rewrites it.
+	walCompactSize = 4 * 1024 * 1024
+
+	walTypeVote      = "vote"
+	walTypeEndHeight = "end_height"
+)
+
+var (
+	crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+	ErrWALCorrupted = errors.New("wal record corrupted")
+	ErrWALUnknown   = errors.New("unknown wal record type")
+)
+
+// VoteWALMessage records a vote accepted by the vote set.
+type VoteWALMessage struct {
+	Round  int64  `json:"round"`
+	ID     []byte `json:"id"`
+	Sender string `json:"sender"`
+}
+
+// EndHeightMessage marks the end of the given height, which is the committed round in hotstuff.
+type EndHeightMessage struct {
+	Height int64 `json:"height"`
+}
+
+// TimedWALMessage wraps WALMessage and adds Time for debugging purposes.
+type TimedWALMessage struct {
+	Time int64      `json:"time"`
+	Msg  WALMessage `json:"msg"`
+}
+
+type walRecord struct {
+	Time int64           `json:"time"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// WALEncoder writes custom-encoded WAL messages to an output stream.
+// Format: 4 bytes CRC sum + 4 bytes length + json-encoded record.
+type WALEncoder struct {
+	wr io.Writer
+}
+
+func NewWALEncoder(wr io.Writer) *WALEncoder {
+	return &WALEncoder{wr: wr}
+}
+
+func (enc *WALEncoder) Encode(v *TimedWALMessage) error {
+	var typ string
+	switch v.Msg.(type) {
+	case VoteWALMessage, *VoteWALMessage:
+		typ = walTypeVote
+	case EndHeightMessage, *EndHeightMessage:
+		typ = walTypeEndHeight
+	default:
+		return ErrWALUnknown
+	}
+	data, err := json.Marshal(v.Msg)
+	if err != nil {
+		return err
+	}
+	record, err := json.Marshal(walRecord{Time: v.Time, Type: typ, Data: data})
+	if err != nil {
+		return err
+	}
+	if len(record) > maxMsgSizeBytes {
+		return fmt.Errorf("msg is too big: %d bytes, max: %d bytes", len(record), maxMsgSizeBytes)
+	}
+
+	msg := make([]byte, 8+len(record))
+	binary.BigEndian.PutUint32(msg[0:4], crc32.Checksum(record, crc32c))
+	binary.BigEndian.PutUint32(msg[4:8], uint32(len(record)))
+	copy(msg[8:], record)
+	_, err = enc.wr.Write(msg)
+	return err
+}
+
+// WALDecoder reads and decodes custom-encoded WAL messages from an input stream.
+type WALDecoder struct {
+	rd io.Reader
+}
+
+func NewWALDecoder(rd io.Reader) *WALDecoder {
+	return &WALDecoder{rd: rd}
+}
+
+// Decode reads the next custom-encoded value from its reader and returns it,
+// io.EOF is returned at the end of the stream.
+func (dec *WALDecoder) Decode() (*TimedWALMessage, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(dec.rd, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, ErrWALCorrupted
+		}
+		return nil, err
+	}
+	crc := binary.BigEndian.Uint32(header[0:4])
+	length := binary.BigEndian.Uint32(header[4:8])
+	if length > maxMsgSizeBytes {
+		return nil, ErrWALCorrupted
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(dec.rd, data); err != nil {
+		return nil, ErrWALCorrupted
+	}
+	if crc32.Checksum(data, crc32c) != crc {
+		return nil, ErrWALCorrupted
+	}
+
+	var record walRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, ErrWALCorrupted
+	}
+	msg := &TimedWALMessage{Time: record.Time}
+	switch record.Type {
+	case walTypeVote:
+		var v VoteWALMessage
+		if err := json.Unmarshal(record.Data, &v); err != nil {
+			return nil, ErrWALCorrupted
+		}
+		msg.Msg = v
+	case walTypeEndHeight:
+		var e EndHeightMessage
+		if err := json.Unmarshal(record.Data, &e); err != nil {
+			return nil, ErrWALCorrupted
+		}
+		msg.Msg = e
+	default:
+		return nil, ErrWALUnknown
+	}
+	return msg, nil
+}
+
+// WALFlushPolicy decides when the buffered records are synced to disk, the safety-critical
+// records, e.g. the votes, are always synced before Write returns regardless of the policy.
+type WALFlushPolicy struct {
+	// PerMessage syncs every record before Write returns.
+	PerMessage bool
+	// Interval syncs the buffered records periodically, 2s by default.
+	Interval time.Duration
+	// MaxBufferSize syncs once the unsynced records exceed the bytes, 0 disables it.
+	MaxBufferSize int
+}
+
+// walCritical reports whether the record must be synced before it takes effect,
+// a lost vote may let the leader count a conflicting one after the crash.
+func walCritical(msg WALMessage) bool {
+	switch msg.(type) {
+	case VoteWALMessage, *VoteWALMessage:
+		return true
+	}
+	return false
+}
+
+// BaseWAL is the canonical implementation of the WAL interface,
+// all records are appended to a single file under the given dir.
+type BaseWAL struct {
+	path string
+	file *os.File
+	buf  *walBuffer
+	enc  *WALEncoder
+
+	policy WALFlushPolicy
+	// unsynced counts the bytes written since the last sync
+	unsynced *countingWriter
+	// compactSize is the size of the wal beyond which Truncate rewrites it
+	compactSize int64
+
+	mtx  sync.Mutex
+	quit chan struct{}
+	// routines is the flusher
+	routines service.Supervisor
+	log      libs.Logger
+}
+
+func NewBaseWAL(dir string, logger libs.Logger) (*BaseWAL, error) {
+	if logger == nil {
+		logger = logs.NewLogger()
+	}
+	if err := libs.MakeDir(dir); err != nil {
+		return nil, fmt.Errorf("fail to create wal dir, dir: %s, err: %v", dir, err)
+	}
+	path := filepath.Join(dir, walFileName)
+	if err := repairTail(path, logger); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	wal := &BaseWAL{
+		path:        path,
+		file:        file,
+		buf:         &walBuffer{w: file},
+		policy:      WALFlushPolicy{Interval: walDefaultFlushPeriod},
+		compactSize: walCompactSize,
+		quit:        make(chan struct{}),
+		log:         logger,
+	}
+	wal.routines.SetLogger(logger)
+	wal.unsynced = &countingWriter{w: wal.buf}
+	wal.enc = NewWALEncoder(wal.unsynced)
+	return wal, nil
+}
+
+// repairTail truncates the wal to its last good record if the record after it is the last one and it's torn
+// or fails its checksum, i.e. the write interrupted by a crash, which is the end of the log. The corrupted
+// records followed by the others aren't repaired, the replay fails with ErrWALCorrupted instead.
+func repairTail(path string, logger libs.Logger) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	rd := &countingReader{r: bufio.NewReader(file)}
+	dec := NewWALDecoder(rd)
+	var good int64
+	for {
+		_, err := dec.Decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err == nil {
+			good = rd.n
+			continue
+		}
+		if !errors.Is(err, ErrWALCorrupted) {
+			return err
+		}
+		break
+	}
+	// the bad record is the last one if it reaches the end of the file
+	header := make([]byte, 8)
+	if n, _ := file.ReadAt(header, good); n == len(header) {
+		if end := good + 8 + int64(binary.BigEndian.Uint32(header[4:8])); end < info.Size() {
+			return fmt.Errorf("%w, offset: %d, size: %d", ErrWALCorrupted, good, info.Size())
+		}
+	}
+	logger.Warn("truncate the torn wal tail @ wal.repairTail, path: %s, offset: %d, size: %d", path, good, info.Size())
+	return os.Truncate(path, good)
+}
+
+// SetFlushPolicy should be invoked before Start, the default interval is kept if p.Interval isn't positive.
+func (wal *BaseWAL) SetFlushPolicy(p WALFlushPolicy) {
+	wal.mtx.Lock()
+	defer wal.mtx.Unlock()
+
+	if p.Interval <= 0 {
+		p.Interval = walDefaultFlushPeriod
+	}
+	wal.policy = p
+}
+
+func (wal *BaseWAL) Start() error {
+	wal.routines.Spawn("wal_flush", wal.flushRoutine)
+	return nil
+}
+
+func (wal *BaseWAL) Stop() error {
+	close(wal.quit)
+	wal.routines.Stop()
+	wal.mtx.Lock()
+	defer wal.mtx.Unlock()
+
+	if err := wal.flushAndSyncWithoutLock(); err != nil {
+		wal.log.Error("flush fail @ wal.Stop, err: %v", err)
+	}
+	return wal.file.Close()
+}
+
+// Wait blocks until the flush routine exits.
+func (wal *BaseWAL) Wait() {
+	wal.routines.Wait()
+}
+
+// Write buffers the record, it's synced by the flush policy or FlushAndSync,
+// the safety-critical records are synced before returning.
+func (wal *BaseWAL) Write(msg WALMessage) error {
+	wal.mtx.Lock()
+	defer wal.mtx.Unlock()
+
+	if err := wal.enc.Encode(&TimedWALMessage{Time: time.Now().UnixNano(), Msg: msg}); err != nil {
+		wal.log.Error("encode msg fail @ wal.Write, msg: %+v, err: %v", msg, err)
+		return err
+	}
+	if wal.policy.PerMessage || walCritical(msg) ||
+		(wal.policy.MaxBufferSize > 0 && wal.unsynced.n >= wal.policy.MaxBufferSize) {
+		return wal.flushAndSyncWithoutLock()
+	}
+	return nil
+}
+
+// walBuffer keeps the records until they are flushed, unlike bufio.Writer a failed flush doesn't stick,
+// the unwritten bytes are kept in order for the next flush, e.g. once the full disk has space again.
+type walBuffer struct {
+	w   io.Writer
+	buf []byte
+}
+
+func (b *walBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
+
+func (b *walBuffer) Flush() error {
+	for len(b.buf) > 0 {
+		n, err := b.w.Write(b.buf)
+		b.buf = b.buf[n:]
+		if err != nil {
+			return err
+		}
+	}
+	b.buf = nil
+	return nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += n
+	return n, err
+}
+
+// WriteSync writes the record to disk before returning.
+func (wal *BaseWAL) WriteSync(msg WALMessage) error {
+	if err := wal.Write(msg); err != nil {
+		return err
+	}
+	return wal.FlushAndSync()
+}
+
+func (wal *BaseWAL) FlushAndSync() error {
+	wal.mtx.Lock()
+	defer wal.mtx.Unlock()
+
+	return wal.flushAndSyncWithoutLock()
+}
+
+func (wal *BaseWAL) flushAndSyncWithoutLock() error {
+	if err := wal.buf.Flush(); err != nil {
+		return err
+	}
+	if err := wal.file.Sync(); err != nil {
+		return err
+	}
+	wal.unsynced.n = 0
+	return nil
+}
+
+// SearchForEndHeight searches for the EndHeightMessage with the given height
+// and returns a reader positioned at the record right after it.
+func (wal *BaseWAL) SearchForEndHeight(height int64) (io.ReadCloser, bool, error) {
+	if err := wal.FlushAndSync(); err != nil {
+		return nil, false, err
+	}
+	rd, err := wal.NewReader()
+	if err != nil {
+		return nil, false, err
+	}
+	dec := NewWALDecoder(rd)
+	for {
+		msg, err := dec.Decode()
+		if err == io.EOF {
+			rd.Close()
+			return nil, false, nil
+		}
+		if err != nil {
+			rd.Close()
+			return nil, false, err
+		}
+		if m, ok := msg.Msg.(EndHeightMessage); ok && m.Height == height {
+			return rd, true, nil
+		}
+	}
+}
+
+// Truncate rewrites the wal with the EndHeightMessage of the committed round followed by the votes of
+// the rounds not pruned by it, see VoteSet.Prune. The rewritten file replaces the wal by a rename, so
+// that a crash leaves either of them. It's skipped until the wal exceeds walCompactSize, the replay
+// prunes the votes by the EndHeightMessage written on every commit meanwhile.
+func (wal *BaseWAL) Truncate(committedRound int64) error {
+	wal.mtx.Lock()
+	defer wal.mtx.Unlock()
+
+	info, err := wal.file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size()+int64(len(wal.buf.buf)) < wal.compactSize {
+		return nil
+	}
+	if err := wal.flushAndSyncWithoutLock(); err != nil {
+		return err
+	}
+	rd, err := os.Open(wal.path)
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+	var kept []*TimedWALMessage
+	dec := NewWALDecoder(rd)
+	for {
+		msg, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("decode fail, round: %d, err: %w", committedRound, err)
+		}
+		switch m := msg.Msg.(type) {
+		case VoteWALMessage:
+			if m.Round >= committedRound {
+				kept = append(kept, msg)
+			}
+		case EndHeightMessage:
+			// a later end height is kept by the prune of the replay
+			if m.Height > committedRound {
+				kept = append(kept, msg)
+			}
+		}
+	}
+
+	tmpPath := wal.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	buf := &walBuffer{w: tmp}
+	enc := NewWALEncoder(buf)
+	err = enc.Encode(&TimedWALMessage{Time: time.Now().UnixNano(), Msg: EndHeightMessage{Height: committedRound}})
+	for _, msg := range kept {
+		if err != nil {
+			break
+		}
+		err = enc.Encode(msg)
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, wal.path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	file, err := os.OpenFile(wal.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if err := wal.file.Close(); err != nil {
+		wal.log.Warn("close the truncated wal fail @ wal.Truncate, path: %s, err: %v", wal.path, err)
+	}
+	wal.file = file
+	wal.buf.w = file
+	return nil
+}
+
+// NewReader returns a reader from the very beginning of the wal.
+func (wal *BaseWAL) NewReader() (io.ReadCloser, error) {
+	return os.Open(wal.path)
+}
+
+// ReadTail decodes the last n records of the stream,
+// it stops at the first corrupted record and returns what has been read.
+func ReadTail(rd io.Reader, n int) ([]*TimedWALMessage, error) {
+	var tail []*TimedWALMessage
+	dec := NewWALDecoder(rd)
+	for {
+		msg, err := dec.Decode()
+		if err == io.EOF {
+			return tail, nil
+		}
+		if err != nil {
+			return tail, err
+		}
+		tail = append(tail, msg)
+		if len(tail) > n {
+			tail = tail[1:]
+		}
+	}
+}
+
+func (wal *BaseWAL) flushRoutine() {
+	wal.mtx.Lock()
+	interval := wal.policy.Interval
+	wal.mtx.Unlock()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := wal.FlushAndSync(); err != nil {
+				wal.log.Error("periodic flush fail @ wal.flushRoutine, err: %v", err)
+			}
+		case <-wal.quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package state
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWALEncodeDecode(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := NewWALEncoder(buf)
+	msgs := []WALMessage{
+		VoteWALMessage{Round: 1, ID: []byte("a"), Sender: "peer_a"},
+		EndHeightMessage{Height: 1},
+	}
+	for _, m := range msgs {
+		if err := enc.Encode(&TimedWALMessage{Time: 1, Msg: m}); err != nil {
+			t.Fatalf("encode err, err: %v", err)
+		}
+	}
+
+	dec := NewWALDecoder(bytes.NewReader(buf.Bytes()))
+	vote, err := dec.Decode()
+	if err != nil {
+		t.Fatalf("decode err, err: %v", err)
+	}
+	if v, ok := vote.Msg.(VoteWALMessage); !ok || v.Round != 1 || string(v.ID) != "a" || v.Sender != "peer_a" {
+		t.Errorf("decode vote fail, msg: %+v", vote.Msg)
+	}
+	end, err := dec.Decode()
+	if err != nil {
+		t.Fatalf("decode err, err: %v", err)
+	}
+	if e, ok := end.Msg.(EndHeightMessage); !ok || e.Height != 1 {
+		t.Errorf("decode end height fail, msg: %+v", end.Msg)
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("want EOF, has: %v", err)
+	}
+
+	// flip one byte of the payload
+	corrupted := buf.Bytes()
+	corrupted[10] ^= 0xff
+	if _, err := NewWALDecoder(bytes.NewReader(corrupted)).Decode(); err != ErrWALCorrupted {
+		t.Errorf("want corrupted err, has: %v", err)
+	}
+}
+
+func TestVoteSetReplay(t *testing.T) {
+	wal, err := NewBaseWAL(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("new wal err, err: %v", err)
+	}
+	validators := []PeerID{"a", "b", "c"}
+	set := NewVoteSet(0)
+	set.SetWAL(wal)
+	if err := set.AddVote(1, []byte("x"), "a", validators); err != nil {
+		t.Fatalf("add vote err, err: %v", err)
+	}
+	if err := set.AddVote(1, []byte("x"), "a", validators); err != ErrDuplicateVote {
+		t.Errorf("want duplicate err, has: %v", err)
+	}
+	if err := set.AddVote(1, []byte("y"), "a", validators); err != ErrConflictingVote {
+		t.Errorf("want conflicting err, has: %v", err)
+	}
+	if err := set.AddVote(2, []byte("z"), "b", validators); err != nil {
+		t.Fatalf("add vote err, err: %v", err)
+	}
+	wal.Write(EndHeightMessage{Height: 2})
+	if err := wal.FlushAndSync(); err != nil {
+		t.Fatalf("flush err, err: %v", err)
+	}
+
+	rd, err := wal.NewReader()
+	if err != nil {
+		t.Fatalf("open reader err, err: %v", err)
+	}
+	defer rd.Close()
+	replayed := NewVoteSet(0)
+	if err := replayed.Replay(rd); err != nil {
+		t.Fatalf("replay err, err: %v", err)
+	}
+	if _, ok := replayed.roundVoteSets[1]; ok {
+		t.Errorf("round 1 should be pruned")
+	}
+	if _, ok := replayed.roundVoteSets[2]["z"].count["b"]; !ok {
+		t.Errorf("vote of round 2 should be replayed")
+	}
+}
+
+func TestWALFlushPolicy(t *testing.T) {
+	dir := t.TempDir()
+	wal, err := NewBaseWAL(dir, nil)
+	if err != nil {
+		t.Fatalf("new wal err, err: %v", err)
+	}
+	defer wal.Stop()
+	wal.SetFlushPolicy(WALFlushPolicy{MaxBufferSize: 100})
+	size := func() int64 {
+		info, err := os.Stat(filepath.Join(dir, walFileName))
+		if err != nil {
+			t.Fatalf("stat wal err, err: %v", err)
+		}
+		return info.Size()
+	}
+
+	if err := wal.Write(EndHeightMessage{Height: 1}); err != nil {
+		t.Fatalf("write err, err: %v", err)
+	}
+	if size() != 0 {
+		t.Errorf("end height should be buffered, size: %d", size())
+	}
+	// votes are synced regardless of the policy
+	if err := wal.Write(VoteWALMessage{Round: 1, ID: []byte("x"), Sender: "a"}); err != nil {
+		t.Fatalf("write err, err: %v", err)
+	}
+	synced := size()
+	if synced == 0 {
+		t.Fatalf("vote should be synced")
+	}
+	for i := int64(2); size() == synced; i++ {
+		if i > 10 {
+			t.Fatalf("buffer size exceeded but not synced")
+		}
+		if err := wal.Write(EndHeightMessage{Height: i}); err != nil {
+			t.Fatalf("write err, err: %v", err)
+		}
+	}
+
+	wal.SetFlushPolicy(WALFlushPolicy{PerMessage: true})
+	synced = size()
+	if err := wal.Write(EndHeightMessage{Height: 11}); err != nil {
+		t.Fatalf("write err, err: %v", err)
+	}
+	if size() == synced {
+		t.Errorf("per-message policy should sync every record")
+	}
+}
+
+func TestWALTruncate(t *testing.T) {
+	dir := t.TempDir()
+	wal, err := NewBaseWAL(dir, nil)
+	if err != nil {
+		t.Fatalf("new wal err, err: %v", err)
+	}
+	defer wal.Stop()
+	validators := []PeerID{"a", "b", "c"}
+	set := NewVoteSet(0)
+	set.SetWAL(wal)
+	for round := int64(1); round <= 4; round++ {
+		if err := set.AddVote(round, []byte{byte(round)}, "a", validators); err != nil {
+			t.Fatalf("add vote err, err: %v", err)
+		}
+	}
+	size := func() int64 {
+		info, err := os.Stat(filepath.Join(dir, walFileName))
+		if err != nil {
+			t.Fatalf("stat wal err, err: %v", err)
+		}
+		return info.Size()
+	}
+	before := size()
+	if err := wal.Write(EndHeightMessage{Height: 3}); err != nil {
+		t.Fatalf("write err, err: %v", err)
+	}
+	// the small wal isn't rewritten
+	if err := wal.Truncate(3); err != nil {
+		t.Fatalf("truncate err, err: %v", err)
+	}
+	if err := wal.FlushAndSync(); err != nil {
+		t.Fatalf("flush err, err: %v", err)
+	}
+	if size() <= before {
+		t.Errorf("want the wal kept below the compact size, size before: %d, after: %d", before, size())
+	}
+	wal.compactSize = 0
+	if err := wal.Truncate(3); err != nil {
+		t.Fatalf("truncate err, err: %v", err)
+	}
+	if size() >= before {
+		t.Errorf("want the wal truncated, size before: %d, after: %d", before, size())
+	}
+	// the wal is still appended after the truncation
+	if err := set.AddVote(5, []byte{5}, "a", validators); err != nil {
+		t.Fatalf("add vote err, err: %v", err)
+	}
+
+	rd, err := wal.NewReader()
+	if err != nil {
+		t.Fatalf("open reader err, err: %v", err)
+	}
+	defer rd.Close()
+	msgs, err := ReadTail(rd, 10)
+	if err != nil {
+		t.Fatalf("read err, err: %v", err)
+	}
+	var rounds []int64
+	for _, msg := range msgs {
+		if m, ok := msg.Msg.(VoteWALMessage); ok {
+			rounds = append(rounds, m.Round)
+		}
+	}
+	if len(msgs) != 4 || msgs[0].Msg != (EndHeightMessage{Height: 3}) || fmt.Sprint(rounds) != "[3 4 5]" {
+		t.Errorf("want the end height followed by the votes of round 3 to 5, has: %+v", msgs)
+	}
+	if _, found, err := wal.SearchForEndHeight(3); !found || err != nil {
+		t.Errorf("want the end height found, err: %v", err)
+	}
+}
+
+func TestWALTornTail(t *testing.T) {
+	records := func() []byte {
+		buf := new(bytes.Buffer)
+		enc := NewWALEncoder(buf)
+		for round := int64(1); round <= 3; round++ {
+			if err := enc.Encode(&TimedWALMessage{Time: 1, Msg: VoteWALMessage{Round: round, ID: []byte{byte(round)}, Sender: "a"}}); err != nil {
+				t.Fatalf("encode err, err: %v", err)
+			}
+		}
+		return buf.Bytes()
+	}
+	good := records()
+	// the offset of the last record
+	var last int
+	for n := 0; n < 2; n++ {
+		last += 8 + int(binary.BigEndian.Uint32(good[last+4:last+8]))
+	}
+	for _, c := range []struct {
+		name string
+		data []byte
+		// the rounds replayed, nil if the wal is refused
+		rounds []int64
+	}{
+		{"torn header", append(append([]byte{}, good...), 1, 2, 3), []int64{1, 2, 3}},
+		{"torn record", good[:len(good)-3], []int64{1, 2}},
+		{"bad crc", flip(good, len(good)-2), []int64{1, 2}},
+		{"corrupted in the middle", flip(good, last-2), nil},
+	} {
+		dir := t.TempDir()
+		path := filepath.Join(dir, walFileName)
+		if err := os.WriteFile(path, c.data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		wal, err := NewBaseWAL(dir, nil)
+		if c.rounds == nil {
+			if !errors.Is(err, ErrWALCorrupted) {
+				t.Errorf("%s: want the wal refused, err: %v", c.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: want the tail truncated, err: %v", c.name, err)
+			continue
+		}
+		// the repaired wal is appended after the last good record
+		if err := wal.WriteSync(EndHeightMessage{Height: 4}); err != nil {
+			t.Fatalf("write err, err: %v", err)
+		}
+		rd, err := wal.NewReader()
+		if err != nil {
+			t.Fatalf("open reader err, err: %v", err)
+		}
+		msgs, err := ReadTail(rd, 10)
+		rd.Close()
+		wal.Stop()
+		if err != nil {
+			t.Errorf("%s: read err, err: %v", c.name, err)
+			continue
+		}
+		var rounds []int64
+		for _, msg := range msgs {
+			if m, ok := msg.Msg.(VoteWALMessage); ok {
+				rounds = append(rounds, m.Round)
+			}
+		}
+		if fmt.Sprint(rounds) != fmt.Sprint(c.rounds) || msgs[len(msgs)-1].Msg != (EndHeightMessage{Height: 4}) {
+			t.Errorf("%s: want the rounds %v followed by the end height, has: %+v", c.name, c.rounds, msgs)
+		}
+	}
+}
+
+func flip(data []byte, i int) []byte {
+	flipped := append([]byte{}, data...)
+	flipped[i] ^= 0xff
+	return flipped
+}
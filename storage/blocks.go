@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+var (
+	ErrBlockNotFound  = errors.New("committed block not found")
+	ErrBlockCorrupted = errors.New("committed block is corrupted")
+)
+
+// CommittedBlock is a block committed by the consensus, the height is its round.
+type CommittedBlock struct {
+	Height      int64         `json:"height"`
+	ID          types.BlockID `json:"id"`
+	ParentRound int64         `json:"parent_round"`
+	ParentID    types.BlockID `json:"parent_id"`
+	Proposer    string        `json:"proposer"`
+	Txs         [][]byte      `json:"txs"`
+	// QC is the serialized quorum cert of the block kept by the block tree
+	QC []byte `json:"qc"`
+	// AppHash is the state hash after the block of AppHeight claimed by the proposer
+	AppHeight int64  `json:"app_height,omitempty"`
+	AppHash   []byte `json:"app_hash,omitempty"`
+	// Time is the timestamp of the proposal in unix seconds, no earlier than the one of its parent
+	Time int64 `json:"time,omitempty"`
+	// VoteExtensions are the signed votes carried by the proposal, see types.ProposalMsg
+	VoteExtensions [][]byte `json:"vote_extensions,omitempty"`
+	// Sum is the sha256 of the block without it, it's only kept on the disk: set by Save and checked
+	// and cleared by Load, the blocks saved before it was introduced carry none.
+	Sum []byte `json:"sum,omitempty"`
+}
+
+// Content is what the versioned id of the block commits to, see types.BlockID.
+func (b *CommittedBlock) Content() *types.BlockContent {
+	return &types.BlockContent{Round: b.Height, ParentRound: b.ParentRound, ParentID: b.ParentID, Txs: b.Txs,
+		AppHeight: b.AppHeight, AppHash: b.AppHash, Timestamp: b.Time, VoteExtensions: b.VoteExtensions}
+}
+
+// BlockStorage keeps the committed blocks by the height, BlockStore is the default one,
+// the embedding applications may substitute their own, e.g. backed by a database.
+type BlockStorage interface {
+	// Save writes the block, the block of the height is overwritten if exists.
+	Save(block *CommittedBlock) error
+	// Load returns ErrBlockNotFound if the block of the height isn't stored,
+	// or ErrBlockCorrupted if it fails its checksum.
+	Load(height int64) (*CommittedBlock, error)
+	// Heights returns the heights of the stored blocks in [from, to] ascending, to <= 0 means no upper bound.
+	Heights(from, to int64) ([]int64, error)
+}
+
+var _ BlockStorage = (*BlockStore)(nil)
+
+// BlockStore persists the committed blocks in a file per height under its dir.
+type BlockStore struct {
+	dir string
+}
+
+func NewBlockStore(dir string) (*BlockStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &BlockStore{dir: dir}, nil
+}
+
+// Save writes the block atomically, the block of the height is overwritten if exists.
+func (s *BlockStore) Save(block *CommittedBlock) error {
+	b := *block
+	sum, err := b.checksum()
+	if err != nil {
+		return err
+	}
+	b.Sum = sum
+	data, err := json.Marshal(&b)
+	if err != nil {
+		return err
+	}
+	tmp := s.path(block.Height) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		// the partial file is removed, it would hold the space of a full disk
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, s.path(block.Height))
+}
+
+func (s *BlockStore) Load(height int64) (*CommittedBlock, error) {
+	data, err := os.ReadFile(s.path(height))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w, height: %d", ErrBlockNotFound, height)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var block CommittedBlock
+	if err := json.Unmarshal(data, &block); err != nil {
+		return nil, fmt.Errorf("%w, undecodable, height: %d, err: %v", ErrBlockCorrupted, height, err)
+	}
+	if block.Sum == nil {
+		return &block, nil
+	}
+	sum, err := block.checksum()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(sum, block.Sum) {
+		return nil, fmt.Errorf("%w, checksum mismatch, height: %d, stored: %x, has: %x", ErrBlockCorrupted, height, block.Sum, sum)
+	}
+	block.Sum = nil
+	return &block, nil
+}
+
+// checksum returns the sha256 of the json encoding of the block without its sum.
+func (b *CommittedBlock) checksum() ([]byte, error) {
+	c := *b
+	c.Sum = nil
+	data, err := json.Marshal(&c)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
+
+// Heights returns the heights of the persisted blocks in [from, to] ascending, to <= 0 means no upper bound.
+// The rounds without a committed block are skipped.
+func (s *BlockStore) Heights(from, to int64) ([]int64, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var heights []int64
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		h, err := strconv.ParseInt(strings.TrimSuffix(name, ".json"), 10, 64)
+		if err != nil || h < from || (to > 0 && h > to) {
+			continue
+		}
+		heights = append(heights, h)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return heights, nil
+}
+
+func (s *BlockStore) path(height int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%d.json", height))
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBlockStoreChecksum(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewBlockStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := &CommittedBlock{Height: 1, ID: []byte{1}, Proposer: "a", Txs: [][]byte{[]byte("tx")}}
+	if err := s.Save(block); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := s.Load(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Sum != nil || string(loaded.Txs[0]) != "tx" {
+		t.Errorf("want the block without its sum, has: %+v", loaded)
+	}
+
+	// a rotten byte of the file fails the checksum
+	path := filepath.Join(dir, "1.json")
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, bytes.Replace(data, []byte(`"proposer":"a"`), []byte(`"proposer":"b"`), 1), 0644)
+	if _, err := s.Load(1); !errors.Is(err, ErrBlockCorrupted) {
+		t.Errorf("want: %v, has: %v", ErrBlockCorrupted, err)
+	}
+	os.WriteFile(path, data[:len(data)/2], 0644)
+	if _, err := s.Load(1); !errors.Is(err, ErrBlockCorrupted) {
+		t.Errorf("want: %v, has: %v", ErrBlockCorrupted, err)
+	}
+
+	// the blocks saved before the checksum load as they are
+	os.WriteFile(filepath.Join(dir, "2.json"), []byte(`{"height":2,"id":"Ag==","proposer":"a"}`), 0644)
+	if b, err := s.Load(2); err != nil || b.Height != 2 {
+		t.Errorf("want the legacy block loaded, has: %+v, err: %v", b, err)
+	}
+}
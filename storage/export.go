@@ -0,0 +1,345 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+const (
+	// ExportJSON writes a json object per line.
+	ExportJSON = "json"
+	// ExportCSV writes a row per block, the txs and the results are summarized.
+	ExportCSV = "csv"
+	// ExportCAR writes a CARv1 archive, every block is a raw json node rooted by the last one.
+	ExportCAR = "car"
+)
+
+var (
+	ErrUnknownExportFormat = errors.New("unknown export format")
+)
+
+// ExportedBlock is a committed block with its tx results, which are empty if they aren't persisted.
+type ExportedBlock struct {
+	CommittedBlock
+	Results []types.TxResult `json:"results,omitempty"`
+}
+
+type blockWriter interface {
+	write(b *ExportedBlock) error
+	flush() error
+}
+
+// Export streams the committed blocks in [from, to] to w in the format, to <= 0 means the latest one,
+// the blocks are loaded one by one so that a long chain is exported in constant memory.
+// results may be nil, it returns the number of the exported blocks.
+func Export(blocks BlockStorage, results ResultStorage, from, to int64, format string, w io.Writer) (int, error) {
+	heights, err := blocks.Heights(from, to)
+	if err != nil {
+		return 0, err
+	}
+	load := func(height int64) (*ExportedBlock, error) {
+		block, err := blocks.Load(height)
+		if err != nil {
+			return nil, err
+		}
+		eb := &ExportedBlock{CommittedBlock: *block}
+		if results == nil {
+			return eb, nil
+		}
+		r, err := results.Load(height)
+		if err != nil && !errors.Is(err, ErrResultsNotFound) {
+			return nil, err
+		}
+		if r != nil {
+			eb.Results = r.Txs
+		}
+		return eb, nil
+	}
+
+	buf := bufio.NewWriter(w)
+	var bw blockWriter
+	switch format {
+	case ExportJSON:
+		bw = &jsonBlockWriter{enc: json.NewEncoder(buf), w: buf}
+	case ExportCSV:
+		bw, err = newCSVBlockWriter(buf)
+	case ExportCAR:
+		var root *ExportedBlock
+		if len(heights) > 0 {
+			if root, err = load(heights[len(heights)-1]); err != nil {
+				return 0, err
+			}
+		}
+		bw, err = newCARBlockWriter(buf, root)
+	default:
+		return 0, fmt.Errorf("%w, format: %s", ErrUnknownExportFormat, format)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	for i, h := range heights {
+		b, err := load(h)
+		if err != nil {
+			return i, err
+		}
+		if err := bw.write(b); err != nil {
+			return i, err
+		}
+	}
+	return len(heights), bw.flush()
+}
+
+type jsonBlockWriter struct {
+	enc *json.Encoder
+	w   *bufio.Writer
+}
+
+func (j *jsonBlockWriter) write(b *ExportedBlock) error {
+	return j.enc.Encode(b)
+}
+
+func (j *jsonBlockWriter) flush() error {
+	return j.w.Flush()
+}
+
+var csvHeader = []string{"height", "id", "parent_round", "parent_id", "proposer", "txs", "tx_bytes", "failed_txs", "gas_used", "qc"}
+
+type csvBlockWriter struct {
+	w *csv.Writer
+}
+
+func newCSVBlockWriter(w io.Writer) (*csvBlockWriter, error) {
+	c := &csvBlockWriter{w: csv.NewWriter(w)}
+	return c, c.w.Write(csvHeader)
+}
+
+func (c *csvBlockWriter) write(b *ExportedBlock) error {
+	txBytes := 0
+	for _, tx := range b.Txs {
+		txBytes += len(tx)
+	}
+	var failed, gas int64
+	for _, r := range b.Results {
+		if !r.IsOK() {
+			failed++
+		}
+		gas += r.GasUsed
+	}
+	return c.w.Write([]string{
+		strconv.FormatInt(b.Height, 10),
+		hex.EncodeToString(b.ID),
+		strconv.FormatInt(b.ParentRound, 10),
+		hex.EncodeToString(b.ParentID),
+		b.Proposer,
+		strconv.Itoa(len(b.Txs)),
+		strconv.Itoa(txBytes),
+		strconv.FormatInt(failed, 10),
+		strconv.FormatInt(gas, 10),
+		hex.EncodeToString(b.QC),
+	})
+}
+
+func (c *csvBlockWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// carBlockWriter writes the CARv1 format, see https://ipld.io/specs/transport/car/carv1/,
+// the blocks are addressed by the CIDv1 of their json encoding.
+type carBlockWriter struct {
+	w *bufio.Writer
+}
+
+const (
+	cidVersion1     = 0x01
+	multicodecJSON  = 0x0200
+	multihashSHA256 = 0x12
+)
+
+func newCARBlockWriter(w *bufio.Writer, root *ExportedBlock) (*carBlockWriter, error) {
+	c := &carBlockWriter{w: w}
+	var roots [][]byte
+	if root != nil {
+		data, err := json.Marshal(root)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, blockCID(data))
+	}
+	return c, c.writeSection(nil, carHeader(roots))
+}
+
+func (c *carBlockWriter) write(b *ExportedBlock) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	return c.writeSection(blockCID(data), data)
+}
+
+func (c *carBlockWriter) flush() error {
+	return c.w.Flush()
+}
+
+// writeSection writes the uvarint length of the cid and the data followed by them.
+func (c *carBlockWriter) writeSection(cid, data []byte) error {
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], uint64(len(cid)+len(data)))
+	for _, p := range [][]byte{lenBuf[:n], cid, data} {
+		if _, err := c.w.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blockCID returns the binary CIDv1 of the json data with the sha2-256 multihash.
+func blockCID(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	var cid [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(cid[:], cidVersion1)
+	n += binary.PutUvarint(cid[n:], multicodecJSON)
+	return append(append(cid[:n:n], multihashSHA256, byte(len(sum))), sum[:]...)
+}
+
+// carHeader encodes {"roots": [...], "version": 1} in dag-cbor, the cids are tagged by 42.
+func carHeader(roots [][]byte) []byte {
+	h := []byte{0xa2}
+	h = appendCBORHead(h, 3, 5)
+	h = append(h, "roots"...)
+	h = appendCBORHead(h, 4, uint64(len(roots)))
+	for _, cid := range roots {
+		h = append(h, 0xd8, 42)
+		h = appendCBORHead(h, 2, uint64(len(cid)+1))
+		// the multibase prefix of the binary cids
+		h = append(h, 0x00)
+		h = append(h, cid...)
+	}
+	h = appendCBORHead(h, 3, 7)
+	h = append(h, "version"...)
+	return append(h, 0x01)
+}
+
+// appendCBORHead appends the head of a cbor item with the major type and the argument.
+func appendCBORHead(b []byte, major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return append(b, major<<5|byte(arg))
+	case arg <= 0xff:
+		return append(b, major<<5|24, byte(arg))
+	case arg <= 0xffff:
+		return append(b, major<<5|25, byte(arg>>8), byte(arg))
+	case arg <= 0xffffffff:
+		return append(b, major<<5|26, byte(arg>>24), byte(arg>>16), byte(arg>>8), byte(arg))
+	}
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], arg)
+	return append(append(b, major<<5|27), n[:]...)
+}
+
+var (
+	ErrCorruptedExport = errors.New("corrupted export")
+)
+
+// BlockReader reads back the blocks written by Export, in the json or the car format.
+type BlockReader struct {
+	r      *bufio.Reader
+	format string
+	header bool
+}
+
+func NewBlockReader(r io.Reader, format string) (*BlockReader, error) {
+	if format != ExportJSON && format != ExportCAR {
+		return nil, fmt.Errorf("%w, format: %s, only json and car can be read back", ErrUnknownExportFormat, format)
+	}
+	return &BlockReader{r: bufio.NewReader(r), format: format}, nil
+}
+
+// Next returns the next block, io.EOF once all the blocks have been read.
+func (br *BlockReader) Next() (*ExportedBlock, error) {
+	var data []byte
+	switch br.format {
+	case ExportJSON:
+		line, err := br.r.ReadBytes('\n')
+		if err == io.EOF && len(bytes.TrimSpace(line)) > 0 {
+			err = nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			return br.Next()
+		}
+		data = line
+	case ExportCAR:
+		if !br.header {
+			if _, err := br.readSection(); err != nil {
+				if err == io.EOF {
+					return nil, fmt.Errorf("%w, missing car header", ErrCorruptedExport)
+				}
+				return nil, err
+			}
+			br.header = true
+		}
+		section, err := br.readSection()
+		if err != nil {
+			return nil, err
+		}
+		if data, err = verifyCARSection(section); err != nil {
+			return nil, err
+		}
+	}
+	var b ExportedBlock
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("%w, err: %v", ErrCorruptedExport, err)
+	}
+	return &b, nil
+}
+
+func (br *BlockReader) readSection() ([]byte, error) {
+	size, err := binary.ReadUvarint(br.r)
+	if err != nil {
+		return nil, err
+	}
+	section := make([]byte, size)
+	if _, err := io.ReadFull(br.r, section); err != nil {
+		return nil, fmt.Errorf("%w, truncated car section, err: %v", ErrCorruptedExport, err)
+	}
+	return section, nil
+}
+
+// verifyCARSection checks the data of the section against its cid and returns the data.
+func verifyCARSection(section []byte) ([]byte, error) {
+	rd := bytes.NewReader(section)
+	version, err1 := binary.ReadUvarint(rd)
+	codec, err2 := binary.ReadUvarint(rd)
+	hash, err3 := binary.ReadUvarint(rd)
+	size, err4 := binary.ReadUvarint(rd)
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+		return nil, fmt.Errorf("%w, invalid cid", ErrCorruptedExport)
+	}
+	if version != cidVersion1 || codec != multicodecJSON || hash != multihashSHA256 || size != sha256.Size {
+		return nil, fmt.Errorf("%w, unsupported cid, version: %d, codec: %x, hash: %x", ErrCorruptedExport, version, codec, hash)
+	}
+	digest := make([]byte, size)
+	if _, err := io.ReadFull(rd, digest); err != nil {
+		return nil, fmt.Errorf("%w, invalid cid", ErrCorruptedExport)
+	}
+	data := section[len(section)-rd.Len():]
+	if sum := sha256.Sum256(data); !bytes.Equal(sum[:], digest) {
+		return nil, fmt.Errorf("%w, block digest mismatch", ErrCorruptedExport)
+	}
+	return data, nil
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+func newTestStores(t *testing.T) (*BlockStore, *ResultStore) {
+	blocks, err := NewBlockStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := NewResultStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// round 3 has no committed block
+	for _, h := range []int64{1, 2, 4, 5} {
+		b := &CommittedBlock{Height: h, ID: []byte{byte(h)}, ParentRound: h - 1, Proposer: "a", Txs: [][]byte{[]byte("tx")}, QC: []byte("{}")}
+		if err := blocks.Save(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := results.Save(&BlockResults{Height: 2, Txs: []types.TxResult{{Code: 1, GasUsed: 7}}}); err != nil {
+		t.Fatal(err)
+	}
+	return blocks, results
+}
+
+func TestBlockStore(t *testing.T) {
+	blocks, _ := newTestStores(t)
+	heights, err := blocks.Heights(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(heights) != 2 || heights[0] != 2 || heights[1] != 4 {
+		t.Errorf("want heights [2 4], has: %v", heights)
+	}
+	if _, err := blocks.Load(3); !errors.Is(err, ErrBlockNotFound) {
+		t.Errorf("want not found err, has: %v", err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	blocks, results := newTestStores(t)
+
+	var buf bytes.Buffer
+	n, err := Export(blocks, results, 2, 0, ExportJSON, &buf)
+	if err != nil || n != 3 {
+		t.Fatalf("export json fail, n: %d, err: %v", n, err)
+	}
+	dec := json.NewDecoder(&buf)
+	var first ExportedBlock
+	if err := dec.Decode(&first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Height != 2 || len(first.Results) != 1 || first.Results[0].GasUsed != 7 {
+		t.Errorf("unexpected json record: %+v", first)
+	}
+
+	buf.Reset()
+	if _, err := Export(blocks, results, 0, 4, ExportCSV, &buf); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 || rows[2][0] != "2" || rows[2][7] != "1" || rows[2][8] != "7" {
+		t.Errorf("unexpected csv rows: %v", rows)
+	}
+
+	if _, err := Export(blocks, results, 0, 0, "xml", &buf); !errors.Is(err, ErrUnknownExportFormat) {
+		t.Errorf("want unknown format err, has: %v", err)
+	}
+}
+
+func TestExportCAR(t *testing.T) {
+	blocks, results := newTestStores(t)
+	var buf bytes.Buffer
+	if _, err := Export(blocks, results, 0, 0, ExportCAR, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	rd := bufio.NewReader(&buf)
+	readSection := func() []byte {
+		size, err := binary.ReadUvarint(rd)
+		if err != nil {
+			return nil
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(rd, data); err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	header := readSection()
+	var sections [][]byte
+	for s := readSection(); s != nil; s = readSection() {
+		sections = append(sections, s)
+	}
+	if len(sections) != 4 {
+		t.Fatalf("want 4 blocks, has: %d", len(sections))
+	}
+	// cidv1, json codec, sha2-256
+	const cidLen = 1 + 2 + 2 + sha256.Size
+	for _, s := range sections {
+		sum := sha256.Sum256(s[cidLen:])
+		if !bytes.Equal(s[cidLen-sha256.Size:cidLen], sum[:]) {
+			t.Errorf("block digest mismatch")
+		}
+	}
+	// the root is the last block
+	last := sections[len(sections)-1]
+	if !bytes.Contains(header, last[:cidLen]) {
+		t.Errorf("header should root the last block, header: %x", header)
+	}
+}
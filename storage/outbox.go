@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrOutboxEntryNotFound = errors.New("outbox entry not found")
+)
+
+// Outbox keeps the entries which haven't been acknowledged in a file per sequence under its dir,
+// so that they are delivered after a restart as well.
+type Outbox struct {
+	dir string
+}
+
+func NewOutbox(dir string) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Outbox{dir: dir}, nil
+}
+
+// Put writes the entry atomically, the entry of the seq is overwritten if exists.
+func (o *Outbox) Put(seq int64, data []byte) error {
+	tmp := o.path(seq) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, o.path(seq))
+}
+
+func (o *Outbox) Get(seq int64) ([]byte, error) {
+	data, err := os.ReadFile(o.path(seq))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w, seq: %d", ErrOutboxEntryNotFound, seq)
+	}
+	return data, err
+}
+
+// Pending returns the sequences which haven't been acknowledged in ascending order.
+func (o *Outbox) Pending() ([]int64, error) {
+	entries, err := os.ReadDir(o.dir)
+	if err != nil {
+		return nil, err
+	}
+	var seqs []int64
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".msg") {
+			continue
+		}
+		seq, err := strconv.ParseInt(strings.TrimSuffix(name, ".msg"), 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+// Ack removes the entry once it has been delivered.
+func (o *Outbox) Ack(seq int64) error {
+	err := os.Remove(o.path(seq))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (o *Outbox) path(seq int64) string {
+	return filepath.Join(o.dir, fmt.Sprintf("%d.msg", seq))
+}
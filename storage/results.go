@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+var (
+	ErrResultsNotFound = errors.New("block results not found")
+)
+
+// BlockResults are the receipts of a committed block, indexed by the tx index in the block.
+type BlockResults struct {
+	Height int64            `json:"height"`
+	Txs    []types.TxResult `json:"txs"`
+}
+
+// ResultStorage keeps the block results by the height, ResultStore is the default one.
+type ResultStorage interface {
+	Save(results *BlockResults) error
+	// Load returns ErrResultsNotFound if the results of the height aren't stored.
+	Load(height int64) (*BlockResults, error)
+}
+
+var _ ResultStorage = (*ResultStore)(nil)
+
+// ResultStore persists the block results in a file per height under its dir.
+type ResultStore struct {
+	dir string
+}
+
+func NewResultStore(dir string) (*ResultStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &ResultStore{dir: dir}, nil
+}
+
+// Save writes the results atomically, the results of the height are overwritten if exist.
+func (s *ResultStore) Save(results *BlockResults) error {
+	data, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	tmp := s.path(results.Height) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, s.path(results.Height))
+}
+
+func (s *ResultStore) Load(height int64) (*BlockResults, error) {
+	data, err := os.ReadFile(s.path(height))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w, height: %d", ErrResultsNotFound, height)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var results BlockResults
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("decode block results fail, height: %d, err: %v", height, err)
+	}
+	return &results, nil
+}
+
+func (s *ResultStore) path(height int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%d.json", height))
+}
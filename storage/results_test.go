@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aucusaga/gohotstuff/types"
+)
+
+func TestResultStore(t *testing.T) {
+	s, err := NewResultStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &BlockResults{
+		Height: 3,
+		Txs: []types.TxResult{
+			{Code: 0, Data: []byte("ok"), GasUsed: 21},
+			{Code: 1, Log: "insufficient funds", Events: []types.Event{{Type: "transfer", Attributes: map[string]string{"to": "a"}}}},
+		},
+	}
+	if err := s.Save(want); err != nil {
+		t.Fatal(err)
+	}
+	has, err := s.Load(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(has, want) {
+		t.Errorf("want: %+v, has: %+v", want, has)
+	}
+	if _, err := s.Load(4); !errors.Is(err, ErrResultsNotFound) {
+		t.Errorf("want not found err, has: %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package types
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// HashScheme is the version of the hash function of a BlockID, it's the first byte of the id,
+// so that the hash function can evolve, e.g. to blake3 by the next version, while the ids
+// of the historical blocks are still verified by the schemes they were hashed with.
+type HashScheme byte
+
+const (
+	// HashSchemeLegacy is the scheme of the unversioned ids, e.g. the random ids of the earlier
+	// proposals and the genesis, they commit to nothing and aren't verified.
+	HashSchemeLegacy HashScheme = 0
+	HashSchemeSHA256 HashScheme = 1
+)
+
+var (
+	ErrUnknownHashScheme = errors.New("unknown hash scheme")
+	ErrBlockIDMismatch   = errors.New("block id mismatches the block")
+	ErrLegacyBlockID     = errors.New("legacy block id isn't accepted")
+)
+
+var hashSchemeNames = map[HashScheme]string{
+	HashSchemeLegacy: "legacy",
+	HashSchemeSHA256: "sha256",
+}
+
+func (s HashScheme) String() string {
+	if name, ok := hashSchemeNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("scheme(%d)", byte(s))
+}
+
+// ParseHashScheme parses the scheme name used in the config file, empty means HashSchemeSHA256.
+func ParseHashScheme(name string) (HashScheme, error) {
+	if name == "" {
+		return HashSchemeSHA256, nil
+	}
+	for s, n := range hashSchemeNames {
+		if strings.EqualFold(n, name) {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s", ErrUnknownHashScheme, name)
+}
+
+// digestSize is the size of the digest of the scheme, 0 if it's unknown.
+func (s HashScheme) digestSize() int {
+	switch s {
+	case HashSchemeSHA256:
+		return sha256.Size
+	}
+	return 0
+}
+
+func (s HashScheme) sum(data []byte) ([]byte, error) {
+	switch s {
+	case HashSchemeSHA256:
+		sum := sha256.Sum256(data)
+		return sum[:], nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownHashScheme, s)
+}
+
+// BlockContent is what a versioned BlockID commits to, the fields every replica knows of a proposal
+// and every store keeps of a committed block, see storage.CommittedBlock.
+type BlockContent struct {
+	Round       int64
+	ParentRound int64
+	ParentID    []byte
+	Txs         [][]byte
+	AppHeight   int64
+	AppHash     []byte
+	// Timestamp and VoteExtensions are the ones of the proposal, the extensions are the signed votes.
+	Timestamp      int64
+	VoteExtensions [][]byte
+}
+
+// Bytes is the canonical encoding hashed by the schemes, the integers are big endian and
+// the byte slices are prefixed by their lengths.
+func (c *BlockContent) Bytes() []byte {
+	var buf bytes.Buffer
+	writeInt := func(v int64) {
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], uint64(v))
+		buf.Write(b[:])
+	}
+	writeBytes := func(b []byte) {
+		writeInt(int64(len(b)))
+		buf.Write(b)
+	}
+	writeInt(c.Round)
+	writeInt(c.ParentRound)
+	writeBytes(c.ParentID)
+	writeInt(int64(len(c.Txs)))
+	for _, tx := range c.Txs {
+		writeBytes(tx)
+	}
+	writeInt(c.AppHeight)
+	writeBytes(c.AppHash)
+	writeInt(c.Timestamp)
+	writeInt(int64(len(c.VoteExtensions)))
+	for _, ext := range c.VoteExtensions {
+		writeBytes(ext)
+	}
+	return buf.Bytes()
+}
+
+// BlockID identifies a block, the versioned ones are the scheme byte followed by the digest
+// of the BlockContent, the others are HashSchemeLegacy.
+type BlockID []byte
+
+// NewBlockID hashes the content by the scheme.
+func NewBlockID(scheme HashScheme, c *BlockContent) (BlockID, error) {
+	if scheme == HashSchemeLegacy {
+		return nil, fmt.Errorf("%w, legacy ids aren't hashed", ErrUnknownHashScheme)
+	}
+	sum, err := scheme.sum(c.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return append(BlockID{byte(scheme)}, sum...), nil
+}
+
+// Scheme returns the scheme of the id, the ids which don't start with a known scheme
+// followed by a digest of its size, e.g. the printable legacy ids, are HashSchemeLegacy.
+func (id BlockID) Scheme() HashScheme {
+	if len(id) == 0 {
+		return HashSchemeLegacy
+	}
+	s := HashScheme(id[0])
+	if size := s.digestSize(); size == 0 || len(id) != 1+size {
+		return HashSchemeLegacy
+	}
+	return s
+}
+
+// Verify checks the versioned id is the hash of the content by its own scheme, the legacy ids pass,
+// the callers enforcing the versioned ids reject them by Scheme, see ErrLegacyBlockID.
+func (id BlockID) Verify(c *BlockContent) error {
+	scheme := id.Scheme()
+	if scheme == HashSchemeLegacy {
+		return nil
+	}
+	want, err := NewBlockID(scheme, c)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(id, want) {
+		return fmt.Errorf("%w, round: %d, id: %s, want: %s", ErrBlockIDMismatch, c.Round, id, want)
+	}
+	return nil
+}
+
+// String prints the versioned id as <scheme>:<hex digest> and the legacy one as it is.
+func (id BlockID) String() string {
+	scheme := id.Scheme()
+	if scheme == HashSchemeLegacy {
+		return string(id)
+	}
+	return scheme.String() + ":" + hex.EncodeToString(id[1:])
+}
@@ -2,16 +2,22 @@ package types
 
 import (
 	"fmt"
-
-	"github.com/aucusaga/gohotstuff/libs"
 )
 
 type ProposalMsg struct {
 	Round         int64
-	ID            []byte
+	ID            BlockID
 	JustifyParent []byte
 	PeerID        string
 	Timestamp     int64
+	Txs           [][]byte
+	// AppHash is the application state hash after the block of AppHeight executed by the proposer,
+	// both are empty if the executor doesn't hash its state.
+	AppHeight int64
+	AppHash   []byte
+	// VoteExtensions are the signed votes certifying the block of JustifyParent which carry
+	// the application data of the voters, see state.VoteExtender.
+	VoteExtensions [][]byte
 
 	PublicKey []byte
 	Signature []byte
@@ -22,5 +28,5 @@ func (p *ProposalMsg) Validate() error {
 }
 
 func (p *ProposalMsg) String() string {
-	return fmt.Sprintf("round: %d, id: %s, from: %s, timestamp: %d", p.Round, libs.F(p.ID), p.PeerID, p.Timestamp)
+	return fmt.Sprintf("round: %d, id: %s, from: %s, timestamp: %d", p.Round, p.ID, p.PeerID, p.Timestamp)
 }
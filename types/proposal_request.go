@@ -0,0 +1,30 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ProposalRequestMsg asks a peer for the proposal of the id, which the sender has seen
+// the votes or the qc of but never received.
+type ProposalRequestMsg struct {
+	Round     int64
+	ID        BlockID
+	SendID    string
+	To        string
+	Timestamp int64
+
+	PublicKey []byte
+	Signature []byte
+}
+
+func (r *ProposalRequestMsg) Validate() error {
+	if len(r.ID) == 0 {
+		return errors.New("empty proposal id")
+	}
+	return nil
+}
+
+func (r *ProposalRequestMsg) String() string {
+	return fmt.Sprintf("round: %d, id: %s, from: %s, to: %s, timestamp: %d", r.Round, r.ID, r.SendID, r.To, r.Timestamp)
+}
@@ -0,0 +1,20 @@
+package types
+
+// Event is emitted by the application while executing a tx, e.g. a transfer.
+type Event struct {
+	Type       string            `json:"type"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// TxResult is the receipt of a tx executed in a committed block, a zero Code means success.
+type TxResult struct {
+	Code    uint32  `json:"code"`
+	Data    []byte  `json:"data,omitempty"`
+	Log     string  `json:"log,omitempty"`
+	Events  []Event `json:"events,omitempty"`
+	GasUsed int64   `json:"gas_used"`
+}
+
+func (r *TxResult) IsOK() bool {
+	return r.Code == 0
+}
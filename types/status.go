@@ -0,0 +1,30 @@
+package types
+
+import (
+	"fmt"
+)
+
+// StatusMsg tells the peers how far the sender is, it's gossiped periodically.
+type StatusMsg struct {
+	CommittedRound int64
+	Round          int64
+	HighQCRound    int64
+	SendID         string
+	Timestamp      int64
+
+	PublicKey []byte
+	Signature []byte
+}
+
+func (s *StatusMsg) Validate() error {
+	if s.CommittedRound > s.HighQCRound || s.HighQCRound >= s.Round {
+		return fmt.Errorf("inconsistent status, committed_round: %d, high_qc_round: %d, round: %d",
+			s.CommittedRound, s.HighQCRound, s.Round)
+	}
+	return nil
+}
+
+func (s *StatusMsg) String() string {
+	return fmt.Sprintf("round: %d, high_qc_round: %d, committed_round: %d, from: %s, timestamp: %d",
+		s.Round, s.HighQCRound, s.CommittedRound, s.SendID, s.Timestamp)
+}
@@ -2,17 +2,17 @@ package types
 
 import (
 	"fmt"
-
-	"github.com/aucusaga/gohotstuff/libs"
 )
 
 type TimeoutMsg struct {
 	Round       int64
 	Index       int64
 	ParentRound int64
-	ParentID    []byte
-	SendID      string
-	Timestamp   int64
+	ParentID    BlockID
+	// HighQC is the serialized qc of the parent proposal.
+	HighQC    []byte
+	SendID    string
+	Timestamp int64
 
 	PublicKey []byte
 	Signature []byte
@@ -23,6 +23,6 @@ func (t *TimeoutMsg) Validate() error {
 }
 
 func (t *TimeoutMsg) String() string {
-	return fmt.Sprintf("round: %d, index: %d, parent_round: %d, parent_id: %s, from: %s, timestamp: %d",
-		t.Round, t.Index, t.ParentRound, libs.F(t.ParentID), t.SendID, t.Timestamp)
+	return fmt.Sprintf("round: %d, index: %d, parent_round: %d, parent_id: %s, high_qc: %d bytes, from: %s, timestamp: %d",
+		t.Round, t.Index, t.ParentRound, t.ParentID, len(t.HighQC), t.SendID, t.Timestamp)
 }
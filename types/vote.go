@@ -2,19 +2,19 @@ package types
 
 import (
 	"fmt"
-
-	"github.com/aucusaga/gohotstuff/libs"
 )
 
 type VoteMsg struct {
 	Round       int64
-	ID          []byte
+	ID          BlockID
 	ParentRound int64
-	ParentID    []byte
+	ParentID    BlockID
 	CommitInfo  []byte
 	SendID      string
 	To          string
 	Timestamp   int64
+	// Extension is the application data attached by the voter, see state.VoteExtender.
+	Extension []byte
 
 	PublicKey []byte
 	Signature []byte
@@ -26,5 +26,5 @@ func (v *VoteMsg) Validate() error {
 
 func (v *VoteMsg) String() string {
 	return fmt.Sprintf("round: %d, id: %s, parent_round: %d, parent_id: %s, from: %s, to: %s, timestamp: %d",
-		v.Round, libs.F(v.ID), v.ParentRound, libs.F(v.ParentID), v.SendID, v.To, v.Timestamp)
+		v.Round, v.ID, v.ParentRound, v.ParentID, v.SendID, v.To, v.Timestamp)
 }